@@ -1,3 +1,5 @@
+import { WS_SUBPROTOCOL } from '@/types/websocket.gen';
+
 export interface WebSocketMessage {
   type: string;
   channel?: string;
@@ -19,7 +21,7 @@ export class WebSocketService {
     return new Promise((resolve, reject) => {
       try {
         console.log('Connecting to WebSocket:', this.url);
-        this.ws = new WebSocket(this.url);
+        this.ws = new WebSocket(this.url, [WS_SUBPROTOCOL]);
         
         this.ws.onopen = () => {
           console.log('✅ WebSocket connected successfully');
@@ -0,0 +1,189 @@
+// Code generated by cmd/wsschema; DO NOT EDIT.
+// AIR WebSocket protocol v1
+
+export const WS_PROTOCOL_VERSION = 1;
+export const WS_SUBPROTOCOL = "air.v1";
+
+export interface AIErrorPayload {
+  code: string;
+  error: string;
+  prompt_id?: number;
+  model?: string;
+  allowed_models?: string[];
+  limit_per_minute?: number;
+  retry_after_seconds?: number;
+}
+
+export interface ChatMessagePayload {
+  content: string;
+  model?: string;
+}
+
+export interface ChatResponsePayload {
+  content: string;
+  model: string;
+}
+
+export interface ChatTypingPayload {
+  is_typing: boolean;
+}
+
+export interface ErrorPayload {
+  code: string;
+  error: string;
+  type?: string;
+  details?: string;
+}
+
+export interface FileAnalysisCompletePayload {
+  analysis_id?: number;
+  file_id: string;
+  query: string;
+  model: string;
+  analysis: string;
+  insights: string[];
+  suggestions: string[];
+}
+
+export interface FileAnalysisErrorPayload {
+  file_id?: string;
+  error: string;
+}
+
+export interface FileAnalysisPayload {
+  file_id: string;
+  query: string;
+  model?: string;
+}
+
+export interface FileAnalysisStartedPayload {
+  file_id: string;
+  query: string;
+  model: string;
+}
+
+export interface FileInfo {
+  file_id: string;
+  filename: string;
+  file_size: number;
+  upload_time: string;
+  file_type: string;
+}
+
+export interface FileLoadedPayload {
+  file_id: string;
+  filename: string;
+  file_size: number;
+}
+
+export interface FileNeededPayload {
+  message: string;
+  files: FileInfo[];
+}
+
+export interface FileSelectPayload {
+  file_id: string;
+}
+
+export interface LoadDatasetErrorPayload {
+  error: string;
+}
+
+export interface LoadDatasetPayload {
+  filename: string;
+}
+
+export interface LoadDatasetSuccessPayload {
+  filename: string;
+  message: string;
+}
+
+export interface PingPayload {
+}
+
+export interface PromptSuggestPayload {
+  prefix?: string;
+}
+
+export interface PromptSuggestion {
+  id: number;
+  name: string;
+  description?: string;
+  text: string;
+  params: string[];
+  shared: boolean;
+}
+
+export interface PromptSuggestionsPayload {
+  prefix: string;
+  prompts: PromptSuggestion[];
+}
+
+export interface RunPromptPayload {
+  prompt_id: number;
+  params?: Record<string, string>;
+  datasource_id?: string;
+}
+
+export interface SubscribePayload {
+  channel: string;
+}
+
+export interface WelcomePayload {
+  protocol: number;
+  supported_versions: number[];
+  client_id: string;
+  user_id: string;
+  message_types: string[];
+}
+
+interface Envelope<T extends string, P> {
+  type: T;
+  channel?: string;
+  payload: P;
+  timestamp?: string;
+  user_id?: string;
+}
+
+export type ClientMessage =
+  | Envelope<"subscribe", SubscribePayload> // Receive messages published to a channel
+  | Envelope<"unsubscribe", SubscribePayload> // Stop receiving a channel
+  | Envelope<"ping", PingPayload> // Liveness check; answered with pong
+  | Envelope<"file_analysis", FileAnalysisPayload> // Analyze an uploaded file with AI
+  | Envelope<"load_dataset", LoadDatasetPayload> // Select an uploaded file for chat questions
+  | Envelope<"chat_message", ChatMessagePayload> // Ask the assistant a question
+  | Envelope<"raw_ai_message", ChatMessagePayload> // Send a prompt to an allowed model without system prompts
+  | Envelope<"ephemeral_file_select", FileSelectPayload> // Pick a file offered by ephemeral_file_needed
+  | Envelope<"prompt_suggest", PromptSuggestPayload> // List saved prompts whose names start with a prefix
+  | Envelope<"run_prompt", RunPromptPayload> // Run a saved prompt; answered with chat_response or chat_error
+;
+
+export type ServerMessage =
+  | Envelope<"welcome", WelcomePayload> // First message of a connection, with the negotiated protocol version
+  | Envelope<"error", ErrorPayload> // A client message was rejected
+  | Envelope<"pong", PingPayload> // Answer to ping
+  | Envelope<"file_analysis_started", FileAnalysisStartedPayload> // File analysis accepted
+  | Envelope<"file_analysis_complete", FileAnalysisCompletePayload> // File analysis result
+  | Envelope<"file_analysis_error", FileAnalysisErrorPayload> // File analysis failed or timed out
+  | Envelope<"chat_typing", ChatTypingPayload> // Assistant typing indicator
+  | Envelope<"chat_response", ChatResponsePayload> // Answer to chat_message
+  | Envelope<"chat_error", AIErrorPayload> // chat_message or run_prompt was rejected
+  | Envelope<"raw_ai_response", ChatResponsePayload> // Answer to raw_ai_message
+  | Envelope<"raw_ai_error", AIErrorPayload> // raw_ai_message was rejected
+  | Envelope<"load_dataset_success", LoadDatasetSuccessPayload> // Dataset selected
+  | Envelope<"load_dataset_error", LoadDatasetErrorPayload> // Dataset could not be selected
+  | Envelope<"ephemeral_file_needed", FileNeededPayload> // A question needs a file; offers the uploaded files
+  | Envelope<"ephemeral_file_loaded", FileLoadedPayload> // File picked with ephemeral_file_select
+  | Envelope<"prompt_suggestions", PromptSuggestionsPayload> // Saved prompts matching prompt_suggest
+  | Envelope<"report.run.started", Record<string, unknown>> // A report run started executing
+  | Envelope<"report.run.completed", Record<string, unknown>> // A report run completed
+  | Envelope<"report.run.failed", Record<string, unknown>> // A report run failed
+  | Envelope<"report.snapshot.refreshed", Record<string, unknown>> // A report snapshot was refreshed
+  | Envelope<"analysis.completed", Record<string, unknown>> // An analysis job completed
+  | Envelope<"schema.drift.detected", Record<string, unknown>> // A datasource schema changed since it was learned
+  | Envelope<"report.stale", Record<string, unknown>> // Schema drift dropped a table or column a report version reads
+  | Envelope<"data.stale", Record<string, unknown>> // A monitored table's newest row is older than its freshness max age
+  | Envelope<"datasource.unhealthy", Record<string, unknown>> // A datasource health check failed
+;
+
+export type WebSocketProtocolMessage = ClientMessage | ServerMessage;
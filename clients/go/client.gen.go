@@ -29,6 +29,13 @@ const (
 	CreateDatasourceRequestKindTimescaledb CreateDatasourceRequestKind = "timescaledb"
 )
 
+// Defines values for DatasourceResponseCircuit.
+const (
+	Closed   DatasourceResponseCircuit = "closed"
+	HalfOpen DatasourceResponseCircuit = "half_open"
+	Open     DatasourceResponseCircuit = "open"
+)
+
 // Defines values for DatasourceResponseHealthStatus.
 const (
 	DatasourceResponseHealthStatusHealthy   DatasourceResponseHealthStatus = "healthy"
@@ -127,15 +134,32 @@ type CreateScopeVersionRequest struct {
 
 // DatasourceResponse defines model for DatasourceResponse.
 type DatasourceResponse struct {
-	DisplayName  *string                         `json:"display_name,omitempty"`
-	Error        *string                         `json:"error,omitempty"`
+	// Circuit Connection circuit; open while reconnects back off after failures
+	Circuit     *DatasourceResponseCircuit `json:"circuit,omitempty"`
+	DisplayName *string                    `json:"display_name,omitempty"`
+	Error       *string                    `json:"error,omitempty"`
+
+	// Failures Consecutive failed connection checks
+	Failures     *int                            `json:"failures,omitempty"`
 	HealthStatus *DatasourceResponseHealthStatus `json:"health_status,omitempty"`
 	Id           *string                         `json:"id,omitempty"`
 	IsDefault    *bool                           `json:"is_default,omitempty"`
 	Kind         *DatasourceResponseKind         `json:"kind,omitempty"`
 	LastHealth   *time.Time                      `json:"last_health,omitempty"`
+
+	// RequireApproval Report versions need a reviewer's approval before they run or are snapshotted here
+	RequireApproval *bool `json:"require_approval,omitempty"`
+
+	// RetryAt While the circuit is open, when the next checkout may reconnect
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+
+	// Sandbox Staging copy report versions must pass a validate run on before they run here
+	Sandbox *string `json:"sandbox,omitempty"`
 }
 
+// DatasourceResponseCircuit defines model for DatasourceResponse.Circuit.
+type DatasourceResponseCircuit string
+
 // DatasourceResponseHealthStatus defines model for DatasourceResponse.HealthStatus.
 type DatasourceResponseHealthStatus string
 
@@ -163,8 +187,11 @@ type GenerateSQLRequest struct {
 
 // HealthCheckResponse defines model for HealthCheckResponse.
 type HealthCheckResponse struct {
-	Error  *string                    `json:"error,omitempty"`
-	Status *HealthCheckResponseStatus `json:"status,omitempty"`
+	Error *string `json:"error,omitempty"`
+
+	// LatencyMs Time the connection check took
+	LatencyMs *int64                     `json:"latency_ms,omitempty"`
+	Status    *HealthCheckResponseStatus `json:"status,omitempty"`
 }
 
 // HealthCheckResponseStatus defines model for HealthCheckResponse.Status.
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/NubeDev/air/internal/backup"
+	"github.com/NubeDev/air/internal/config"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// runBackup implements `air backup`: write one control-plane archive to a
+// file, or to the configured backup directory and S3 bucket with -scheduled.
+// It can run while the server is up.
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dataDir := fs.String("data", "data", "Path to data directory containing config files")
+	configFile := fs.String("config", "config.yaml", "Configuration file name (relative to data dir)")
+	out := fs.String("out", "", "Archive file to write (default air-backup-<time>.tar.gz)")
+	redact := fs.Bool("redact", false, "Blank DSN passwords, webhook secrets and config secrets")
+	scheduled := fs.Bool("scheduled", false, "Write to backup.dir and backup.s3 like a scheduled backup")
+	fs.Parse(args)
+
+	configPath := *dataDir + "/" + *configFile
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		return 1
+	}
+	db, err := gorm.Open(sqlite.Open(cfg.ControlPlane.DSN), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open control-plane database: %v\n", err)
+		return 1
+	}
+
+	if *scheduled {
+		path, err := backup.NewScheduler(db, cfg.Backup, configPath, "uploads").Backup(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+			return 1
+		}
+		fmt.Println(path)
+		return 0
+	}
+
+	var buf bytes.Buffer
+	manifest, err := backup.Write(db, &buf, backup.Options{Redact: *redact, ConfigPath: configPath, UploadsDir: "uploads"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "backup failed: %v\n", err)
+		return 1
+	}
+	path := *out
+	if path == "" {
+		path = backup.ArchiveName(time.Now())
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write archive: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s (%d bytes, %d uploads listed, redacted=%t)\n", path, buf.Len(), manifest.Uploads, manifest.Redacted)
+	return 0
+}
+
+// runRestore implements `air restore <archive>`: replace the control-plane
+// database with the archived one. Stop the server first.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dataDir := fs.String("data", "data", "Path to data directory containing config files")
+	configFile := fs.String("config", "config.yaml", "Configuration file name (relative to data dir)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: air restore [-data dir] [-config file] <archive.tar.gz>")
+		return 1
+	}
+
+	configPath := *dataDir + "/" + *configFile
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		return 1
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open archive: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	manifest, err := backup.Restore(f, backup.RestoreOptions{DSN: cfg.ControlPlane.DSN, ConfigPath: configPath})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("restored control plane from backup taken %s\n", manifest.CreatedAt.Format(time.RFC3339))
+	if manifest.Redacted {
+		fmt.Println("the archive was redacted: re-enter datasource passwords and webhook secrets")
+	}
+	for _, name := range manifest.Files {
+		if name == "config.yaml" {
+			fmt.Printf("archived config written to %s.restored; review and move it into place\n", configPath)
+		}
+	}
+	return 0
+}
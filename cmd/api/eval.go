@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/eval"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+)
+
+// runEval implements `air eval`: run an NL->SQL suite and report accuracy per
+// model. It returns the process exit code: 1 when the suite could not run,
+// 2 when accuracy is below --min-accuracy or cases regressed against --baseline.
+func runEval(args []string) int {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	dataDir := fs.String("data", "data", "Path to data directory containing config files")
+	configFile := fs.String("config", "config.yaml", "Configuration file name (relative to data dir)")
+	suitePath := fs.String("suite", "testdata/eval/sales.yaml", "Eval suite YAML file")
+	models := fs.String("models", "", "Comma-separated models to compare, each \"chat\" or \"chat+sql\" (default: configured models)")
+	only := fs.String("only", "", "Comma-separated case IDs or tags to run")
+	kind := fs.String("kind", "", "Override the suite datasource kind")
+	dsn := fs.String("dsn", "", "Override the suite datasource DSN, e.g. a dockerized sample DB in CI")
+	out := fs.String("out", "", "Write the JSON report to this file")
+	baseline := fs.String("baseline", "", "Previous JSON report to detect regressions against")
+	minAccuracy := fs.Float64("min-accuracy", 0, "Fail when any model's accuracy (0-1) is below this")
+	replayMode := fs.String("replay", "", "Record model responses (record) or answer from recordings offline (replay)")
+	replayDir := fs.String("recordings", "", "Recordings directory (default models.replay.dir)")
+	verbose := fs.Bool("verbose", false, "Log pipeline details")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*dataDir + "/" + *configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load configuration: %v\n", err)
+		return 1
+	}
+	if *replayMode != "" {
+		cfg.Models.Replay.Mode = *replayMode
+	}
+	if *replayDir != "" {
+		cfg.Models.Replay.Dir = *replayDir
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid replay options: %v\n", err)
+		return 1
+	}
+	level := "warn"
+	if *verbose {
+		level = cfg.Telemetry.Level
+	}
+	logger.SetupLogger(&logger.LoggerConfig{
+		Level:      level,
+		Format:     cfg.Telemetry.Format,
+		TimeFormat: cfg.Telemetry.TimeFormat,
+		Color:      cfg.Telemetry.Color,
+	})
+	llm.SetLocalOnly(cfg.Privacy.LocalOnly)
+
+	suite, err := eval.LoadSuite(*suitePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *kind != "" {
+		suite.Datasource.Kind = *kind
+	}
+	if *dsn != "" {
+		suite.Datasource.DSN = *dsn
+	}
+	suite.Filter(splitList(*only))
+	if len(suite.Cases) == 0 {
+		fmt.Fprintln(os.Stderr, "no cases selected")
+		return 1
+	}
+
+	report, err := eval.NewRunner(cfg, suite).Run(splitList(*models))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval failed: %v\n", err)
+		return 1
+	}
+
+	if *baseline != "" {
+		previous, err := eval.LoadReport(*baseline)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		report.CompareBaseline(previous)
+	}
+	report.WriteText(os.Stdout)
+
+	if *out != "" {
+		if err := report.WriteJSON(*out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	code := 0
+	for _, m := range report.Models {
+		if m.Accuracy < *minAccuracy {
+			fmt.Fprintf(os.Stderr, "%s accuracy %.1f%% is below %.1f%%\n", m.Model, m.Accuracy*100, *minAccuracy*100)
+			code = 2
+		}
+	}
+	if n := report.Regressions(); n > 0 {
+		fmt.Fprintf(os.Stderr, "%d case(s) regressed against %s\n", n, *baseline)
+		code = 2
+	}
+	return code
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,61 @@
+package grpcapi
+
+import (
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/services"
+	airv1 "github.com/NubeDev/air/internal/transport/grpc/airv1"
+)
+
+// chatServer serves ChatService
+type chatServer struct {
+	airv1.UnimplementedChatServiceServer
+	service *services.AIService
+	models  ChatModels
+}
+
+// Chat runs one completion and streams the reply. Without a model the
+// caller's preferred chat model is used, as for REST chat.
+func (s *chatServer) Chat(req *airv1.ChatRequest, stream airv1.ChatService_ChatServer) error {
+	if len(req.GetMessages()) == 0 {
+		return invalidArgument("messages are required")
+	}
+	messages := make([]llm.Message, len(req.GetMessages()))
+	for i, msg := range req.GetMessages() {
+		messages[i] = llm.Message{Role: msg.GetRole(), Content: msg.GetContent()}
+	}
+
+	var resp *llm.ChatResponse
+	var err error
+	if req.GetModel() == "" {
+		resp, err = s.service.ChatCompletion(stream.Context(), messages)
+	} else {
+		model, ok := s.resolveModel(req.GetModel())
+		if !ok {
+			return invalidArgument("model " + req.GetModel() + " is not allowed")
+		}
+		resp, err = s.service.AiRaw(stream.Context(), messages, model)
+	}
+	if err != nil {
+		return rpcError("chat completion failed", err)
+	}
+
+	if err := stream.Send(&airv1.ChatEvent{Event: &airv1.ChatEvent_Delta{Delta: resp.Message.Content}}); err != nil {
+		return err
+	}
+	return stream.Send(&airv1.ChatEvent{Event: &airv1.ChatEvent_Done{Done: &airv1.ChatDone{Model: resp.Model}}})
+}
+
+// resolveModel maps a requested model or alias to a model name on the
+// allow-list
+func (s *chatServer) resolveModel(requested string) (string, bool) {
+	model := requested
+	if alias, ok := s.models.Aliases[model]; ok {
+		model = alias
+	}
+	for _, allowed := range s.models.Allowed {
+		if model == allowed {
+			return model, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,107 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	airv1 "github.com/NubeDev/air/internal/transport/grpc/airv1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// datasourceServer serves DatasourceService
+type datasourceServer struct {
+	airv1.UnimplementedDatasourceServiceServer
+	service      *services.DatasourceService
+	requireAdmin func(ctx context.Context) error // creating and deleting datasources is admin only
+}
+
+func (s *datasourceServer) ListDatasources(ctx context.Context, req *airv1.ListDatasourcesRequest) (*airv1.ListDatasourcesResponse, error) {
+	datasources, err := s.service.ListDatasources()
+	if err != nil {
+		return nil, rpcError("failed to list datasources", err)
+	}
+	resp := &airv1.ListDatasourcesResponse{Datasources: make([]*airv1.Datasource, len(datasources))}
+	for i := range datasources {
+		resp.Datasources[i] = datasourceMessage(&datasources[i])
+	}
+	return resp, nil
+}
+
+func (s *datasourceServer) GetDatasource(ctx context.Context, req *airv1.GetDatasourceRequest) (*airv1.Datasource, error) {
+	return s.find(req.GetId())
+}
+
+func (s *datasourceServer) CreateDatasource(ctx context.Context, req *airv1.CreateDatasourceRequest) (*airv1.Datasource, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.GetId()) == "" || req.GetKind() == "" || req.GetDsn() == "" || req.GetDisplayName() == "" {
+		return nil, invalidArgument("id, kind, dsn and display_name are required")
+	}
+	err := s.service.CreateDatasource(store.CreateDatasourceRequest{
+		ID:          req.GetId(),
+		Kind:        req.GetKind(),
+		DSN:         req.GetDsn(),
+		DisplayName: req.GetDisplayName(),
+		IsDefault:   req.GetIsDefault(),
+	})
+	if err != nil {
+		return nil, rpcError("failed to create datasource", err)
+	}
+	return s.find(req.GetId())
+}
+
+func (s *datasourceServer) DeleteDatasource(ctx context.Context, req *airv1.DeleteDatasourceRequest) (*airv1.DeleteDatasourceResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.service.DeleteDatasource(req.GetId()); err != nil {
+		return nil, rpcError("failed to delete datasource", err)
+	}
+	return &airv1.DeleteDatasourceResponse{}, nil
+}
+
+func (s *datasourceServer) Learn(ctx context.Context, req *airv1.LearnRequest) (*airv1.LearnResponse, error) {
+	if req.GetDatasourceId() == "" {
+		return nil, invalidArgument("datasource_id is required")
+	}
+	err := s.service.LearnDatasource(store.LearnDatasourceRequest{
+		DatasourceID: req.GetDatasourceId(),
+		Schemas:      req.GetSchemas(),
+	})
+	if err != nil {
+		return nil, rpcError("failed to learn datasource", err)
+	}
+	return &airv1.LearnResponse{Message: "Schema learned successfully"}, nil
+}
+
+// find returns the datasource with id from the registry's listing
+func (s *datasourceServer) find(id string) (*airv1.Datasource, error) {
+	datasources, err := s.service.ListDatasources()
+	if err != nil {
+		return nil, rpcError("failed to get datasource", err)
+	}
+	for i := range datasources {
+		if datasources[i].ID == id {
+			return datasourceMessage(&datasources[i]), nil
+		}
+	}
+	return nil, rpcError("failed to get datasource", fmt.Errorf("%w: datasource %s", services.ErrNotFound, id))
+}
+
+func datasourceMessage(ds *store.DatasourceResponse) *airv1.Datasource {
+	msg := &airv1.Datasource{
+		Id:           ds.ID,
+		Kind:         ds.Kind,
+		DisplayName:  ds.DisplayName,
+		IsDefault:    ds.IsDefault,
+		HealthStatus: ds.HealthStatus,
+	}
+	if !ds.LastHealth.IsZero() {
+		msg.LastHealth = timestamppb.New(ds.LastHealth)
+	}
+	return msg
+}
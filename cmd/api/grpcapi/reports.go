@@ -0,0 +1,170 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	airv1 "github.com/NubeDev/air/internal/transport/grpc/airv1"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultBatchSize is the rows per StreamReportRun batch when unset
+	defaultBatchSize = 500
+	// runPollInterval is how often StreamReportRun checks on a run that
+	// continues in the background
+	runPollInterval = 250 * time.Millisecond
+)
+
+// reportServer serves ReportService
+type reportServer struct {
+	airv1.UnimplementedReportServiceServer
+	service *services.ReportsService
+}
+
+// RunReport runs a report to completion, as POST /v1/reports/{id}/execute
+// without the async hand-off
+func (s *reportServer) RunReport(ctx context.Context, req *airv1.RunReportRequest) (*airv1.ReportRun, error) {
+	run, err := s.run(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	set, err := services.ParseRunResults(run.Results)
+	if err != nil {
+		return nil, rpcError("failed to read run results", err)
+	}
+	msg := runMessage(run)
+	if msg.Results, err = rowStructs(set, set.Rows); err != nil {
+		return nil, rpcError("failed to encode run results", err)
+	}
+	return msg, nil
+}
+
+// StreamReportRun runs a report, then sends its rows in batches and the
+// finished run last. A run that outlives safety.sync_run_threshold is sent
+// first as running and followed until it finishes.
+func (s *reportServer) StreamReportRun(req *airv1.RunReportRequest, stream airv1.ReportService_StreamReportRunServer) error {
+	ctx := stream.Context()
+	run, err := s.run(ctx, req, true)
+	if err != nil {
+		return err
+	}
+
+	if run.Status == "running" {
+		if err := stream.Send(&airv1.ReportRunEvent{Event: &airv1.ReportRunEvent_Run{Run: runMessage(run)}}); err != nil {
+			return err
+		}
+		ticker := time.NewTicker(runPollInterval)
+		defer ticker.Stop()
+		for run.Status == "running" {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+			if run, err = s.service.GetReportRun(run.ID); err != nil {
+				return rpcError("failed to get report run", err)
+			}
+		}
+	}
+
+	set, err := services.ParseRunResults(run.Results)
+	if err != nil {
+		return rpcError("failed to read run results", err)
+	}
+	batchSize := int(req.GetBatchSize())
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	columns := make([]string, len(set.Columns))
+	for i, column := range set.Columns {
+		columns[i] = column.Name
+	}
+	for start := 0; start < len(set.Rows); start += batchSize {
+		end := min(start+batchSize, len(set.Rows))
+		rows, err := rowStructs(set, set.Rows[start:end])
+		if err != nil {
+			return rpcError("failed to encode run results", err)
+		}
+		if err := stream.Send(&airv1.ReportRunEvent{Event: &airv1.ReportRunEvent_Rows{Rows: &airv1.RowBatch{Columns: columns, Rows: rows}}}); err != nil {
+			return err
+		}
+	}
+	return stream.Send(&airv1.ReportRunEvent{Event: &airv1.ReportRunEvent_Run{Run: runMessage(run)}})
+}
+
+// run starts the report a request names, as the authenticated caller
+func (s *reportServer) run(ctx context.Context, req *airv1.RunReportRequest, allowAsync bool) (*store.ReportRun, error) {
+	runReq := store.RunReportRequest{
+		Params:     req.GetParams().AsMap(),
+		User:       auth.Username(ctx),
+		AllowAsync: allowAsync,
+	}
+	if req.GetDatasourceId() != "" {
+		datasourceID := req.GetDatasourceId()
+		runReq.DatasourceID = &datasourceID
+	}
+
+	var run *store.ReportRun
+	var err error
+	switch report := req.GetReport().(type) {
+	case *airv1.RunReportRequest_ReportId:
+		run, err = s.service.RunReportByID(uint(report.ReportId), runReq)
+	case *airv1.RunReportRequest_ReportKey:
+		run, err = s.service.RunReport(report.ReportKey, runReq)
+	default:
+		return nil, invalidArgument("report_id or report_key is required")
+	}
+	if err != nil {
+		return nil, rpcError("failed to run report", err)
+	}
+	return run, nil
+}
+
+// runMessage converts a run, leaving its results out
+func runMessage(run *store.ReportRun) *airv1.ReportRun {
+	msg := &airv1.ReportRun{
+		Id:              uint32(run.ID),
+		ReportId:        uint32(run.ReportID),
+		ReportVersionId: uint32(run.ReportVersionID),
+		DatasourceId:    run.DatasourceID,
+		SqlText:         run.SQLText,
+		RowCount:        int64(run.RowCount),
+		Status:          run.Status,
+		ErrorText:       run.ErrorText,
+		StartedAt:       timestamppb.New(run.StartedAt),
+		TraceId:         run.TraceID,
+	}
+	for _, warning := range strings.Split(run.Warnings, "\n") {
+		if warning = strings.TrimSpace(warning); warning != "" {
+			msg.Warnings = append(msg.Warnings, warning)
+		}
+	}
+	if run.FinishedAt != nil {
+		msg.FinishedAt = timestamppb.New(*run.FinishedAt)
+	}
+	return msg
+}
+
+// rowStructs converts rows of set to objects keyed by column name
+func rowStructs(set *store.ResultSet, rows [][]interface{}) ([]*structpb.Struct, error) {
+	structs := make([]*structpb.Struct, len(rows))
+	for i, row := range rows {
+		fields := make(map[string]interface{}, len(set.Columns))
+		for j, column := range set.Columns {
+			if j < len(row) {
+				fields[column.Name] = row[j]
+			}
+		}
+		var err error
+		if structs[i], err = structpb.NewStruct(fields); err != nil {
+			return nil, err
+		}
+	}
+	return structs, nil
+}
@@ -0,0 +1,154 @@
+// Package grpcapi serves the gRPC surface of the API defined in
+// api/proto/air/v1/air.proto, on the same services as the REST routes.
+package grpcapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/cmd/api/routes"
+	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	airv1 "github.com/NubeDev/air/internal/transport/grpc/airv1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of the ErrorInfo detail of every error
+const errorDomain = "air"
+
+// ChatModels are the models the Chat RPC may request, as for WebSocket AI
+// messages: provider aliases and the allow-list
+type ChatModels struct {
+	Aliases map[string]string
+	Allowed []string
+}
+
+// NewServer returns a gRPC server of the datasource, report and chat
+// services. With jwtManager set, every call must carry a valid token, and
+// admin-only calls a token of one of admins.
+func NewServer(svc *routes.Services, jwtManager *auth.JWTManager, admins []string, models ChatModels) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryAuth(jwtManager)),
+		grpc.ChainStreamInterceptor(streamAuth(jwtManager)),
+	)
+	airv1.RegisterDatasourceServiceServer(server, &datasourceServer{service: svc.Datasources, requireAdmin: adminOnly(jwtManager, admins)})
+	airv1.RegisterReportServiceServer(server, &reportServer{service: svc.Reports})
+	airv1.RegisterChatServiceServer(server, &chatServer{service: svc.AI, models: models})
+	return server
+}
+
+// authenticate checks the bearer token of a call's metadata and returns ctx
+// carrying the caller's username, as AuthMiddleware does for REST
+func authenticate(ctx context.Context, jwtManager *auth.JWTManager) (context.Context, error) {
+	if jwtManager == nil {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := md.Get("authorization")
+	if len(header) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata required")
+	}
+	token := strings.TrimPrefix(header[0], "Bearer ")
+	if token == header[0] {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+	claims, err := jwtManager.ValidateToken(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	return auth.WithUsername(ctx, claims.Username), nil
+}
+
+// adminOnly returns a check refusing callers other than admins, as
+// RequireAdmin does for REST. With auth disabled every caller passes.
+func adminOnly(jwtManager *auth.JWTManager, admins []string) func(ctx context.Context) error {
+	allowed := make(map[string]bool, len(admins))
+	for _, username := range admins {
+		allowed[username] = true
+	}
+	return func(ctx context.Context) error {
+		if jwtManager == nil || allowed[auth.Username(ctx)] {
+			return nil
+		}
+		return status.Error(codes.PermissionDenied, "admin access required")
+	}
+}
+
+func unaryAuth(jwtManager *auth.JWTManager) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, jwtManager)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuth(jwtManager *auth.JWTManager) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), jwtManager)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticatedStream is a stream whose context carries the caller
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context { return s.ctx }
+
+// rpcError converts a service error to a status with the code of its class
+// and the REST error code as the reason of an ErrorInfo detail; message says
+// what failed
+func rpcError(message string, err error) error {
+	code, httpStatus := apierror.Classify(err)
+	var grpcCode codes.Code
+	switch httpStatus {
+	case http.StatusNotFound:
+		grpcCode = codes.NotFound
+	case http.StatusBadRequest:
+		grpcCode = codes.InvalidArgument
+	case http.StatusConflict:
+		grpcCode = codes.Aborted
+	case http.StatusUnprocessableEntity:
+		grpcCode = codes.FailedPrecondition
+	case http.StatusServiceUnavailable:
+		grpcCode = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		grpcCode = codes.DeadlineExceeded
+	case http.StatusInternalServerError:
+		grpcCode = codes.Internal
+	default:
+		grpcCode = codes.Canceled
+	}
+	if grpcCode == codes.Internal {
+		logger.LogError(logger.ServiceGRPC, message, err)
+	}
+
+	st := status.New(grpcCode, message+": "+err.Error())
+	if detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{Reason: code, Domain: errorDomain}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// invalidArgument is a VALIDATION error of a malformed request
+func invalidArgument(message string) error {
+	st := status.New(codes.InvalidArgument, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: store.ErrCodeValidation, Domain: errorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
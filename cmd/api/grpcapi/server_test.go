@@ -0,0 +1,279 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NubeDev/air/cmd/api/routes"
+	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	airv1 "github.com/NubeDev/air/internal/transport/grpc/airv1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const testConfig = `
+server:
+  auth:
+    enabled: true
+    jwt_secret: "grpc-test-secret"
+control_plane:
+  dsn: "%s"
+analytics_sources:
+  - id: "readings"
+    kind: "sqlite"
+    dsn: "%s"
+    display_name: "Readings"
+    default: true
+`
+
+// testEnv is a gRPC server over the services of a SQLite datasource holding
+// three readings, with a SQL report "readings" over them
+type testEnv struct {
+	conn  *grpc.ClientConn
+	token string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	dir := t.TempDir()
+	sourceDSN := "file:" + filepath.Join(dir, "readings.db")
+	source, err := gorm.Open(sqlite.Open(sourceDSN), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Exec("CREATE TABLE readings (id INTEGER PRIMARY KEY, value REAL); INSERT INTO readings VALUES (1, 1.5), (2, 2.5), (3, 3.5)").Error; err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := source.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf(testConfig, filepath.Join(dir, "air.db"), sourceDSN)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(cfg.ControlPlane.DSN), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	registry := datasource.NewRegistry(cfg, db)
+	t.Cleanup(func() { registry.Close() })
+
+	svc := &routes.Services{
+		Datasources: services.NewDatasourceService(registry, db),
+		Reports:     services.NewReportsService(registry, db, cfg),
+	}
+	if svc.AI, err = services.NewAIService(registry, db, cfg, svc.Datasources); err != nil {
+		t.Fatal(err)
+	}
+	mock := llm.NewMockClient(func(req llm.ChatRequest) (string, error) { return "hello", nil })
+	if err := svc.AI.SetLLMClients(mock, mock); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Reports.CreateSQLReport(store.CreateSQLReportRequest{
+		Key:          "readings",
+		Title:        "Readings",
+		DatasourceID: "readings",
+		SQL:          "SELECT id, value FROM readings ORDER BY id",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	jwtManager := auth.NewJWTManager(cfg.Server.Auth.JWTSecret, time.Hour)
+	token, err := jwtManager.GenerateToken("1", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(svc, jwtManager, nil, ChatModels{Aliases: map[string]string{"llama": "llama3"}, Allowed: []string{"llama3"}})
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testEnv{conn: conn, token: token}
+}
+
+// ctx carries the test user's token
+func (e *testEnv) ctx() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+e.token)
+}
+
+// errorReason returns the REST error code of err's ErrorInfo detail
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestUnauthenticatedCallsAreRefused(t *testing.T) {
+	env := newTestEnv(t)
+	_, err := airv1.NewDatasourceServiceClient(env.conn).ListDatasources(context.Background(), &airv1.ListDatasourcesRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got %v, want Unauthenticated", err)
+	}
+}
+
+func TestDatasources(t *testing.T) {
+	env := newTestEnv(t)
+	client := airv1.NewDatasourceServiceClient(env.conn)
+
+	list, err := client.ListDatasources(env.ctx(), &airv1.ListDatasourcesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Datasources) != 1 || list.Datasources[0].Id != "readings" || !list.Datasources[0].IsDefault {
+		t.Fatalf("unexpected datasources %v", list.Datasources)
+	}
+
+	_, err = client.GetDatasource(env.ctx(), &airv1.GetDatasourceRequest{Id: "missing"})
+	if status.Code(err) != codes.NotFound || errorReason(err) != store.ErrCodeNotFound {
+		t.Fatalf("got %v (reason %q), want NotFound with reason %s", err, errorReason(err), store.ErrCodeNotFound)
+	}
+
+	if _, err := client.Learn(env.ctx(), &airv1.LearnRequest{DatasourceId: "readings"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDatasourceChangesAreAdminOnly(t *testing.T) {
+	env := newTestEnv(t)
+	client := airv1.NewDatasourceServiceClient(env.conn)
+
+	_, err := client.CreateDatasource(env.ctx(), &airv1.CreateDatasourceRequest{Id: "other", Kind: "sqlite", Dsn: ":memory:", DisplayName: "Other"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("create: got %v, want PermissionDenied", err)
+	}
+	_, err = client.DeleteDatasource(env.ctx(), &airv1.DeleteDatasourceRequest{Id: "readings"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("delete: got %v, want PermissionDenied", err)
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	env := newTestEnv(t)
+	run, err := airv1.NewReportServiceClient(env.conn).RunReport(env.ctx(), &airv1.RunReportRequest{
+		Report: &airv1.RunReportRequest_ReportKey{ReportKey: "readings"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != "completed" || run.RowCount != 3 || len(run.Results) != 3 {
+		t.Fatalf("unexpected run: status %s, %d rows, %d results", run.Status, run.RowCount, len(run.Results))
+	}
+	if value := run.Results[1].Fields["value"].GetNumberValue(); value != 2.5 {
+		t.Fatalf("second row value is %v, want 2.5", value)
+	}
+
+	_, err = airv1.NewReportServiceClient(env.conn).RunReport(env.ctx(), &airv1.RunReportRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument for a request naming no report", err)
+	}
+}
+
+func TestStreamReportRunSendsRowBatchesThenTheRun(t *testing.T) {
+	env := newTestEnv(t)
+	stream, err := airv1.NewReportServiceClient(env.conn).StreamReportRun(env.ctx(), &airv1.RunReportRequest{
+		Report:    &airv1.RunReportRequest_ReportKey{ReportKey: "readings"},
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batches []int
+	var final *airv1.ReportRun
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatal(err)
+			}
+			break
+		}
+		switch e := event.Event.(type) {
+		case *airv1.ReportRunEvent_Rows:
+			if final != nil {
+				t.Fatal("rows sent after the finished run")
+			}
+			if len(e.Rows.Columns) != 2 || e.Rows.Columns[0] != "id" {
+				t.Fatalf("unexpected columns %v", e.Rows.Columns)
+			}
+			batches = append(batches, len(e.Rows.Rows))
+		case *airv1.ReportRunEvent_Run:
+			if e.Run.Status != "running" {
+				final = e.Run
+			}
+		}
+	}
+	if len(batches) != 2 || batches[0] != 2 || batches[1] != 1 {
+		t.Fatalf("got row batches %v, want [2 1]", batches)
+	}
+	if final == nil || final.Status != "completed" || len(final.Results) != 0 {
+		t.Fatalf("unexpected final run %v", final)
+	}
+}
+
+func TestChat(t *testing.T) {
+	env := newTestEnv(t)
+	client := airv1.NewChatServiceClient(env.conn)
+
+	stream, err := client.Chat(env.ctx(), &airv1.ChatRequest{Messages: []*airv1.ChatMessage{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetDelta() != "hello" {
+		t.Fatalf("got first event %v, want the reply as a delta", event)
+	}
+	if event, err = stream.Recv(); err != nil || event.GetDone() == nil {
+		t.Fatalf("got %v, %v, want done", event, err)
+	}
+
+	stream, err = client.Chat(env.ctx(), &airv1.ChatRequest{Messages: []*airv1.ChatMessage{{Role: "user", Content: "hi"}}, Model: "gpt-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument for a model off the allow-list", err)
+	}
+}
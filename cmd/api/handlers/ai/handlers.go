@@ -3,7 +3,9 @@ package ai
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
 	"github.com/NubeDev/air/internal/llm"
 	"github.com/NubeDev/air/internal/services"
 	"github.com/NubeDev/air/internal/store"
@@ -15,19 +17,13 @@ func BuildIR(service *services.AIService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req store.BuildIRRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
-		ir, err := service.BuildIR(req)
+		ir, err := service.BuildIR(c.Request.Context(), req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to build IR",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to build IR", err)
 			return
 		}
 
@@ -43,19 +39,13 @@ func GenerateSQLFromIR(service *services.AIService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req store.GenerateSQLRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
-		sql, safetyReport, err := service.GenerateSQLFromIR(req)
+		sql, safetyReport, err := service.GenerateSQLFromIR(c.Request.Context(), req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to generate SQL",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to generate SQL", err)
 			return
 		}
 
@@ -73,27 +63,19 @@ func AnalyzeRun(service *services.AIService) gin.HandlerFunc {
 		runIDStr := c.Param("run_id")
 		var runID uint
 		if _, err := fmt.Sscanf(runIDStr, "%d", &runID); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error: "Invalid run ID",
-			})
+			apierror.BadRequest(c, "Invalid run ID", nil)
 			return
 		}
 
 		var req store.AnalyzeRunRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
-		analysis, err := service.AnalyzeRun(runID, req)
+		analysis, err := service.AnalyzeRun(c.Request.Context(), runID, req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to analyze run",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to analyze run", err)
 			return
 		}
 
@@ -101,15 +83,40 @@ func AnalyzeRun(service *services.AIService) gin.HandlerFunc {
 	}
 }
 
+// RegenerateReport proposes regenerated SQL for a stale report
+func RegenerateReport(service *services.AIService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reportID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", err)
+			return
+		}
+
+		// The body is optional; datasource_id is only needed for portable reports
+		var req store.RegenerateReportRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.BadRequest(c, "Invalid request", err)
+				return
+			}
+		}
+
+		result, err := service.RegenerateReport(c.Request.Context(), uint(reportID), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to regenerate report", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
 // GetAITools returns available AI tools
 func GetAITools(service *services.AIService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tools, err := service.GetAITools()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get AI tools",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to get AI tools", err)
 			return
 		}
 
@@ -127,19 +134,13 @@ func ChatCompletion(service *services.AIService) gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
-		response, err := service.ChatCompletion(req.Messages)
+		response, err := service.ChatCompletion(c.Request.Context(), req.Messages)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Chat completion failed",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Chat completion failed", err)
 			return
 		}
 
@@ -151,24 +152,19 @@ func ChatCompletion(service *services.AIService) gin.HandlerFunc {
 func GenerateSQL(service *services.AIService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
-			Prompt string `json:"prompt"`
-			Schema string `json:"schema"`
+			Prompt  string `json:"prompt"`
+			Schema  string `json:"schema"`
+			Dialect string `json:"dialect"` // datasource kind; defaults to postgres
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
-		sql, err := service.GenerateSQL(req.Prompt, req.Schema)
+		sql, err := service.GenerateSQL(c.Request.Context(), req.Prompt, req.Schema, req.Dialect)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "SQL generation failed",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "SQL generation failed", err)
 			return
 		}
 
@@ -187,10 +183,7 @@ func AiRaw(service *services.AIService) gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
@@ -206,12 +199,9 @@ func AiRaw(service *services.AIService) gin.HandlerFunc {
 		}
 
 		// Use raw AI service that bypasses all system prompts
-		response, err := service.AiRaw(req.Messages, model)
+		response, err := service.AiRaw(c.Request.Context(), req.Messages, model)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Raw AI request failed",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Raw AI request failed", err)
 			return
 		}
 
@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// ListModels lists installed Ollama models
+func ListModels(service *services.ModelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		models, err := service.ListModels(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusBadGateway, store.ErrorResponse{
+				Code:    store.ErrCodeInternal,
+				Error:   "Failed to list models",
+				Details: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"models": models,
+			"count":  len(models),
+		})
+	}
+}
+
+// PullModel pulls a model, streaming progress as newline-delimited JSON
+func PullModel(service *services.ModelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.PullModelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Cache-Control", "no-cache")
+		c.Status(http.StatusOK)
+
+		encoder := json.NewEncoder(c.Writer)
+		err := service.PullModel(c.Request.Context(), req.Model, func(progress llm.PullProgress) error {
+			if err := encoder.Encode(progress); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		})
+
+		// Headers are already sent, so failures are reported as a final stream line
+		if err != nil {
+			logger.LogError(logger.ServiceREST, "Model pull failed", err, map[string]interface{}{
+				"model": req.Model,
+			})
+			encoder.Encode(gin.H{"status": "error", "error": err.Error()})
+		} else {
+			encoder.Encode(gin.H{"status": "success"})
+		}
+		c.Writer.Flush()
+	}
+}
+
+// DeleteModel deletes a model that is not in use
+func DeleteModel(service *services.ModelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		force := c.Query("force") == "true"
+
+		if err := service.DeleteModel(c.Request.Context(), name, force); err != nil {
+			apierror.BadRequest(c, "Failed to delete model", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, store.SuccessResponse{
+			Message: "Model deleted successfully",
+		})
+	}
+}
+
+// WarmUpModels loads models into memory
+func WarmUpModels(service *services.ModelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.WarmUpModelsRequest
+		// Body is optional; an empty body warms the configured models
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.BadRequest(c, "Invalid request", err)
+				return
+			}
+		}
+
+		results := service.WarmUp(c.Request.Context(), req.Models)
+
+		c.JSON(http.StatusOK, gin.H{
+			"models": results,
+		})
+	}
+}
+
+// VerifyModels checks the configured models exist with their providers
+func VerifyModels(service *services.ModelService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checks := service.VerifyModels(c.Request.Context())
+
+		healthy := true
+		for _, check := range checks {
+			if check.Status != "ok" && check.Status != "pulled" {
+				healthy = false
+				break
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"healthy": healthy,
+			"checks":  checks,
+		})
+	}
+}
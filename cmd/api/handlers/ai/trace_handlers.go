@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAITraces returns recorded model calls, newest first. Supports
+// ?operation=, ?run_id=, ?scope_version_id= and ?limit= filters.
+func ListAITraces(service *services.AIService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ids [2]uint
+		for i, name := range []string{"run_id", "scope_version_id"} {
+			if raw := c.Query(name); raw != "" {
+				id, err := strconv.ParseUint(raw, 10, 32)
+				if err != nil {
+					apierror.BadRequest(c, "Invalid "+name, nil)
+					return
+				}
+				ids[i] = uint(id)
+			}
+		}
+		limit, _ := strconv.Atoi(c.Query("limit"))
+
+		traces, err := service.ListAITraces(c.Query("operation"), ids[0], ids[1], limit)
+		if err != nil {
+			apierror.Respond(c, "Failed to list AI traces", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, traces)
+	}
+}
+
+// GetAITrace returns one recorded model call with its full prompt and response
+func GetAITrace(service *services.AIService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		traceID, err := strconv.ParseUint(c.Param("trace_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid trace ID", nil)
+			return
+		}
+
+		trace, err := service.GetAITrace(uint(traceID))
+		if err != nil {
+			apierror.Respond(c, "Failed to get AI trace", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, trace)
+	}
+}
+
+// ReplayAITrace re-issues a recorded prompt, optionally against another model
+func ReplayAITrace(service *services.AIService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		traceID, err := strconv.ParseUint(c.Param("trace_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid trace ID", nil)
+			return
+		}
+
+		var req store.ReplayAITraceRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.BadRequest(c, "Invalid request", err)
+				return
+			}
+		}
+
+		result, err := service.ReplayAITrace(c.Request.Context(), uint(traceID), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to replay AI trace", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ExportAITraces streams recorded model calls as JSON Lines for compliance
+// review. Supports ?from= and ?to= (RFC 3339 or YYYY-MM-DD), ?user=,
+// ?report= (key) and ?operation=. Prompts and responses are passed through the
+// PII redaction hooks unless ?redact=false.
+func ExportAITraces(service *services.AIService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := store.AITraceExportFilter{
+			User:      c.Query("user"),
+			ReportKey: c.Query("report"),
+			Operation: c.Query("operation"),
+			Redact:    c.Query("redact") != "false",
+		}
+		for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+			raw := c.Query(name)
+			if raw == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				if t, err = time.Parse("2006-01-02", raw); err != nil {
+					apierror.BadRequest(c, "Invalid "+name+": use RFC 3339 or YYYY-MM-DD", nil)
+					return
+				}
+			}
+			*target = &t
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="ai-traces.jsonl"`)
+		if _, err := service.ExportAITraces(c.Request.Context(), c.Writer, filter); err != nil {
+			if !c.Writer.Written() {
+				c.Writer.Header().Del("Content-Disposition")
+				apierror.Respond(c, "Failed to export AI traces", err)
+			}
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+}
@@ -0,0 +1,65 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// statusClientClosedRequest is logged when the caller disconnected before the
+// response was ready; nobody reads the body
+const statusClientClosedRequest = 499
+
+// Classify returns the error code and HTTP status for a service error.
+// Errors that match no class are INTERNAL.
+func Classify(err error) (string, int) {
+	switch {
+	case errors.Is(err, services.ErrAITimeout):
+		return store.ErrCodeLLMTimeout, http.StatusGatewayTimeout
+	case errors.Is(err, services.ErrAICanceled):
+		return store.ErrCodeCanceled, statusClientClosedRequest
+	case errors.Is(err, services.ErrSafetyBlocked):
+		return store.ErrCodeSafetyBlocked, http.StatusUnprocessableEntity
+	case errors.Is(err, datasource.ErrDatasourceUnavailable):
+		return store.ErrCodeDatasourceUnavailable, http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrNotFound),
+		errors.Is(err, datasource.ErrDatasourceNotFound),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		return store.ErrCodeNotFound, http.StatusNotFound
+	case errors.Is(err, services.ErrValidation):
+		return store.ErrCodeValidation, http.StatusBadRequest
+	case errors.Is(err, services.ErrConflict):
+		return store.ErrCodeConflict, http.StatusConflict
+	}
+	return store.ErrCodeInternal, http.StatusInternalServerError
+}
+
+// Respond writes err as an ErrorResponse with the code and status of its
+// class; message says what failed
+func Respond(c *gin.Context, message string, err error) {
+	code, status := Classify(err)
+	if status == statusClientClosedRequest {
+		c.AbortWithStatus(status)
+		return
+	}
+	c.JSON(status, store.ErrorResponse{Code: code, Error: message, Details: err.Error()})
+}
+
+// BadRequest writes a VALIDATION error for a malformed request; err may be nil
+func BadRequest(c *gin.Context, message string, err error) {
+	resp := store.ErrorResponse{Code: store.ErrCodeValidation, Error: message}
+	if err != nil {
+		resp.Details = err.Error()
+	}
+	c.JSON(http.StatusBadRequest, resp)
+}
+
+// NotFound writes a NOT_FOUND error
+func NotFound(c *gin.Context, message string) {
+	c.JSON(http.StatusNotFound, store.ErrorResponse{Code: store.ErrCodeNotFound, Error: message})
+}
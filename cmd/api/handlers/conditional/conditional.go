@@ -0,0 +1,67 @@
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hash returns a short content hash of body's JSON encoding, for use in an
+// entity tag
+func Hash(body interface{}) (string, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// JSON writes body with a content-hash ETag and, when modified is set, a
+// Last-Modified header. A client that already holds it gets 304.
+func JSON(c *gin.Context, body interface{}, modified time.Time) {
+	hash, err := Hash(body)
+	if err != nil {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+	Write(c, `"`+hash+`"`, modified, body)
+}
+
+// Write is JSON with a caller-built entity tag
+func Write(c *gin.Context, etag string, modified time.Time, body interface{}) {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c.Request, etag, modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is no
+// If-None-Match, as RFC 9110 orders them
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !modified.IsZero() {
+		since, err := http.ParseTime(header)
+		return err == nil && !modified.Truncate(time.Second).After(since)
+	}
+	return false
+}
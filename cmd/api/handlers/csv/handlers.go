@@ -11,14 +11,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
 	"github.com/NubeDev/air/internal/datasource"
 	"github.com/NubeDev/air/internal/logger"
-	"github.com/NubeDev/air/internal/store"
 	"github.com/gin-gonic/gin"
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
-	_ "github.com/mattn/go-sqlite3"
-	"gorm.io/gorm"
 )
 
 // ImportCSVRequest represents the request to import CSV data
@@ -44,14 +40,11 @@ type ImportCSVResponse struct {
 }
 
 // ImportCSV imports CSV data into a database table
-func ImportCSV(registry *datasource.Registry, db *gorm.DB) gin.HandlerFunc {
+func ImportCSV(registry *datasource.Registry) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req ImportCSVRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
@@ -67,32 +60,15 @@ func ImportCSV(registry *datasource.Registry, db *gorm.DB) gin.HandlerFunc {
 		connector, err := registry.GetDatasource(req.DatasourceID)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to get datasource connector", err)
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error:   "Datasource not found",
-				Details: err.Error(),
-			})
-			return
-		}
-
-		// Get DSN from database
-		var datasource store.Datasource
-		if err := db.Where("id = ?", req.DatasourceID).First(&datasource).Error; err != nil {
-			logger.LogError(logger.ServiceREST, "Failed to get datasource DSN", err)
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error:   "Datasource DSN not found",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Datasource not found", err)
 			return
 		}
 
 		// Import CSV data
-		result, err := importCSVToDatabase(connector, datasource.DSN, req)
+		result, err := importCSVToDatabase(connector, req)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to import CSV", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to import CSV",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to import CSV", err)
 			return
 		}
 
@@ -101,7 +77,7 @@ func ImportCSV(registry *datasource.Registry, db *gorm.DB) gin.HandlerFunc {
 }
 
 // importCSVToDatabase performs the actual CSV import
-func importCSVToDatabase(connector *datasource.DatasourceConnector, dsn string, req ImportCSVRequest) (*ImportCSVResponse, error) {
+func importCSVToDatabase(connector *datasource.DatasourceConnector, req ImportCSVRequest) (*ImportCSVResponse, error) {
 	// Open CSV file
 	file, err := os.Open(req.FilePath)
 	if err != nil {
@@ -127,23 +103,11 @@ func importCSVToDatabase(connector *datasource.DatasourceConnector, dsn string,
 		cleanColumns[i] = cleanColumnName(col)
 	}
 
-	// Map connector kind to driver name
-	driverName := connector.Kind
-	if connector.Kind == "sqlite" {
-		driverName = "sqlite3"
-	}
-
-	// Get database connection
-	db, err := sql.Open(driverName, dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-	defer db.Close()
-
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	// Import over the datasource's own pool, which knows its driver, tunnel and credentials
+	if err := connector.Connected(); err != nil {
+		return nil, err
 	}
+	db := connector.DB
 
 	// Create table if requested
 	if req.CreateTable {
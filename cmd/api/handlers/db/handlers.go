@@ -2,7 +2,12 @@ package db
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/cmd/api/handlers/conditional"
+	"github.com/NubeDev/air/cmd/api/handlers/listing"
 	"github.com/NubeDev/air/internal/services"
 	"github.com/NubeDev/air/internal/store"
 	"github.com/gin-gonic/gin"
@@ -13,10 +18,7 @@ func GetDatasources(service *services.DatasourceService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		datasources, err := service.ListDatasources()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to list datasources",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to list datasources", err)
 			return
 		}
 
@@ -30,18 +32,12 @@ func CreateDatasource(service *services.DatasourceService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req store.CreateDatasourceRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
 		if err := service.CreateDatasource(req); err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to create datasource",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to create datasource", err)
 			return
 		}
 
@@ -51,15 +47,33 @@ func CreateDatasource(service *services.DatasourceService) gin.HandlerFunc {
 	}
 }
 
+// ApplyDatasources creates, updates and, with prune, removes datasources to
+// match the declared list
+func ApplyDatasources(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.ApplyDatasourcesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		resp, err := service.ApplyDatasources(req)
+		if err != nil {
+			apierror.Respond(c, "Failed to apply datasources", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
 // GetDatasourceHealth checks the health of a specific datasource
 func GetDatasourceHealth(service *services.DatasourceService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		response, err := service.GetDatasourceHealth(id)
 		if err != nil {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error: err.Error(),
-			})
+			apierror.Respond(c, "Failed to check datasource health", err)
 			return
 		}
 
@@ -67,15 +81,65 @@ func GetDatasourceHealth(service *services.DatasourceService) gin.HandlerFunc {
 	}
 }
 
+// GetDatasourceStats returns table, view and size figures, learn and run
+// history and connection pool state for a datasource
+func GetDatasourceStats(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := service.GetDatasourceStats(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			apierror.Respond(c, "Failed to get datasource stats", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// TestQuery runs a read-only statement against a datasource and returns the
+// first rows, for debugging connectivity and permissions
+func TestQuery(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.QueryTestRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		resp, err := service.QueryTest(c.Request.Context(), c.Param("id"), c.GetString("username"), req)
+		if err != nil {
+			apierror.Respond(c, "Query test failed", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// RegisterLakeTable registers an Iceberg or Delta table with a Trino datasource
+func RegisterLakeTable(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.RegisterLakeTableRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		resp, err := service.RegisterLakeTable(c.Request.Context(), c.Param("id"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to register lake table", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, resp)
+	}
+}
+
 // DeleteDatasource removes a datasource
 func DeleteDatasource(service *services.DatasourceService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		if err := service.DeleteDatasource(id); err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to delete datasource",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to delete datasource", err)
 			return
 		}
 
@@ -90,18 +154,12 @@ func LearnDatasource(service *services.DatasourceService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req store.LearnDatasourceRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
 		if err := service.LearnDatasource(req); err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to learn datasource",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to learn datasource", err)
 			return
 		}
 
@@ -111,23 +169,451 @@ func LearnDatasource(service *services.DatasourceService) gin.HandlerFunc {
 	}
 }
 
-// GetSchema returns schema information for a datasource
+// GetSchema returns a page of schema notes for a datasource
 func GetSchema(service *services.DatasourceService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		datasourceID := c.Param("datasource_id")
+		opts, ok := listing.Options(c)
+		if !ok {
+			return
+		}
+
+		schema, page, err := service.ListSchemaNotes(datasourceID, opts)
+		if err != nil {
+			apierror.Respond(c, "Failed to get schema", err)
+			return
+		}
+
+		// Notes are replaced on learn, so the newest one dates the whole set,
+		// unless a note was curated since
+		var modified time.Time
+		for _, note := range schema {
+			if note.CreatedAt.After(modified) {
+				modified = note.CreatedAt
+			}
+			if note.CuratedAt != nil && note.CuratedAt.After(modified) {
+				modified = *note.CuratedAt
+			}
+		}
+
+		body := listing.Body("schema_notes", schema, page)
+		body["datasource_id"] = datasourceID
+		conditional.JSON(c, body, modified)
+	}
+}
+
+// ListColumnAnnotations returns unit and metadata annotations for a datasource's columns
+func ListColumnAnnotations(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasourceID := c.Param("id")
+
+		annotations, err := service.ListColumnAnnotations(datasourceID)
+		if err != nil {
+			apierror.Respond(c, "Failed to list column annotations", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"datasource_id": datasourceID,
+			"annotations":   annotations,
+		})
+	}
+}
+
+// UpsertColumnAnnotations sets units, display names and descriptions for columns
+func UpsertColumnAnnotations(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasourceID := c.Param("id")
+
+		var req store.UpsertColumnAnnotationsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		annotations, err := service.UpsertColumnAnnotations(datasourceID, req.Annotations)
+		if err != nil {
+			apierror.Respond(c, "Failed to save column annotations", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"datasource_id": datasourceID,
+			"annotations":   annotations,
+		})
+	}
+}
+
+// DeleteColumnAnnotation removes a column annotation
+func DeleteColumnAnnotation(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		annotationID, err := strconv.ParseUint(c.Param("annotation_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid annotation ID", nil)
+			return
+		}
+
+		if err := service.DeleteColumnAnnotation(c.Param("id"), uint(annotationID)); err != nil {
+			apierror.Respond(c, "Failed to delete column annotation", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, store.SuccessResponse{
+			Message: "Column annotation deleted successfully",
+		})
+	}
+}
 
-		schema, err := service.GetSchema(datasourceID)
+// ListGlossary returns the business glossary of a datasource
+func ListGlossary(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasourceID := c.Param("id")
+
+		terms, err := service.ListGlossary(datasourceID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get schema",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to list glossary", err)
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"datasource_id": datasourceID,
-			"schema_notes":  schema,
+			"terms":         terms,
+		})
+	}
+}
+
+// UpsertGlossary adds or replaces business terms for a datasource
+func UpsertGlossary(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasourceID := c.Param("id")
+
+		var req store.UpsertGlossaryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		terms, err := service.UpsertGlossary(datasourceID, req.Terms)
+		if err != nil {
+			apierror.Respond(c, "Failed to save glossary", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"datasource_id": datasourceID,
+			"terms":         terms,
+		})
+	}
+}
+
+// DeleteGlossaryTerm removes a business term from a datasource's glossary
+func DeleteGlossaryTerm(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		termID, err := strconv.ParseUint(c.Param("term_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid term ID", nil)
+			return
+		}
+
+		if err := service.DeleteGlossaryTerm(c.Param("id"), uint(termID)); err != nil {
+			apierror.Respond(c, "Failed to delete glossary term", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, store.SuccessResponse{
+			Message: "Glossary term deleted successfully",
+		})
+	}
+}
+
+// GenerateDataDictionary has the model write a data dictionary for a
+// datasource's learned tables and saves it as a new version
+func GenerateDataDictionary(service *services.AIService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.GenerateDataDictionaryRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.BadRequest(c, "Invalid request", err)
+				return
+			}
+		}
+
+		dictionary, err := service.GenerateDataDictionary(c.Request.Context(), c.Param("id"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to generate data dictionary", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, dictionary)
+	}
+}
+
+// GetDataDictionary returns the latest data dictionary of a datasource
+func GetDataDictionary(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dictionary, err := service.GetDataDictionary(c.Param("id"), 0)
+		if err != nil {
+			apierror.Respond(c, "Failed to get data dictionary", err)
+			return
+		}
+
+		conditional.JSON(c, dictionary, dictionary.CreatedAt)
+	}
+}
+
+// UpdateDataDictionary saves an edited data dictionary as a new version
+func UpdateDataDictionary(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.UpdateDataDictionaryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		dictionary, err := service.UpdateDataDictionary(c.Param("id"), req.MD)
+		if err != nil {
+			apierror.Respond(c, "Failed to save data dictionary", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, dictionary)
+	}
+}
+
+// ListDataDictionaryVersions lists a datasource's data dictionary versions,
+// newest first by default. ?source=generated or ?source=user narrows the list.
+func ListDataDictionaryVersions(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, ok := listing.Options(c)
+		if !ok {
+			return
+		}
+
+		versions, page, err := service.ListDataDictionaryVersions(c.Param("id"), opts)
+		if err != nil {
+			apierror.Respond(c, "Failed to list data dictionary versions", err)
+			return
+		}
+
+		body := listing.Body("versions", versions, page)
+		body["datasource_id"] = c.Param("id")
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+// GetDataDictionaryVersion returns one version of a datasource's data dictionary
+func GetDataDictionaryVersion(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil || version < 1 {
+			apierror.BadRequest(c, "Invalid version", nil)
+			return
+		}
+
+		dictionary, err := service.GetDataDictionary(c.Param("id"), version)
+		if err != nil {
+			apierror.Respond(c, "Failed to get data dictionary", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, dictionary)
+	}
+}
+
+// CurateSchemaNote edits, annotates, pins or hides a schema note. Only the
+// fields sent are changed; the caller is recorded as the author.
+func CurateSchemaNote(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		noteID, err := strconv.ParseUint(c.Param("note_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid note ID", nil)
+			return
+		}
+
+		var req store.CurateSchemaNoteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+		if req.MD == nil && req.Annotation == nil && req.Pinned == nil && req.Hidden == nil {
+			apierror.BadRequest(c, "Nothing to change: send md, annotation, pinned or hidden", nil)
+			return
+		}
+
+		note, err := service.CurateSchemaNote(c.Param("datasource_id"), uint(noteID), c.GetString("username"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to curate schema note", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, note)
+	}
+}
+
+// GetSchemaNoteHistory lists the curation changes made to a schema note's
+// object, newest first
+func GetSchemaNoteHistory(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		noteID, err := strconv.ParseUint(c.Param("note_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid note ID", nil)
+			return
+		}
+
+		revisions, err := service.SchemaNoteHistory(c.Param("datasource_id"), uint(noteID))
+		if err != nil {
+			apierror.Respond(c, "Failed to get schema note history", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"datasource_id": c.Param("datasource_id"),
+			"note_id":       noteID,
+			"history":       revisions,
+		})
+	}
+}
+
+// SeedDemo creates the demo analytics datasource with its scopes and reports.
+// The body is optional; {"reset": true} rebuilds an existing demo.
+func SeedDemo(service *services.DemoService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.SeedDemoRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.BadRequest(c, "Invalid request", err)
+				return
+			}
+		}
+
+		resp, err := service.SeedDemo(req)
+		if err != nil {
+			apierror.Respond(c, "Failed to seed demo data", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, resp)
+	}
+}
+
+// ListFreshnessMonitors returns a datasource's freshness monitors with their
+// last check
+func ListFreshnessMonitors(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasourceID := c.Param("id")
+
+		monitors, err := service.ListFreshnessMonitors(datasourceID)
+		if err != nil {
+			apierror.Respond(c, "Failed to list freshness monitors", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"datasource_id": datasourceID,
+			"monitors":      monitors,
+		})
+	}
+}
+
+// CreateFreshnessMonitor watches the newest timestamp of a datasource table
+func CreateFreshnessMonitor(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.CreateFreshnessMonitorRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		monitor, err := service.CreateFreshnessMonitor(c.Param("id"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to create freshness monitor", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, monitor)
+	}
+}
+
+// DeleteFreshnessMonitor stops watching a datasource table
+func DeleteFreshnessMonitor(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		monitorID, err := strconv.ParseUint(c.Param("monitor_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid monitor ID", nil)
+			return
+		}
+
+		if err := service.DeleteFreshnessMonitor(c.Param("id"), uint(monitorID)); err != nil {
+			apierror.Respond(c, "Failed to delete freshness monitor", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, store.SuccessResponse{
+			Message: "Freshness monitor deleted successfully",
+		})
+	}
+}
+
+// ListPIIColumns returns the columns learning tagged as personal data
+func ListPIIColumns(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasourceID := c.Param("id")
+
+		columns, err := service.ListPIIColumns(datasourceID)
+		if err != nil {
+			apierror.Respond(c, "Failed to list PII columns", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"datasource_id": datasourceID,
+			"columns":       columns,
+		})
+	}
+}
+
+// UpdatePIIColumn allows a PII-tagged column into prompts and results, or
+// masks it again
+func UpdatePIIColumn(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		piiID, err := strconv.ParseUint(c.Param("pii_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid PII column ID", nil)
+			return
+		}
+
+		var req store.UpdatePIIColumnRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		column, err := service.SetPIIColumnAllowed(c.Param("id"), uint(piiID), *req.Allowed, c.GetString("username"))
+		if err != nil {
+			apierror.Respond(c, "Failed to update PII column", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, column)
+	}
+}
+
+// DeletePIIColumn removes a PII tag, e.g. a false positive
+func DeletePIIColumn(service *services.DatasourceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		piiID, err := strconv.ParseUint(c.Param("pii_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid PII column ID", nil)
+			return
+		}
+
+		if err := service.DeletePIIColumn(c.Param("id"), uint(piiID)); err != nil {
+			apierror.Respond(c, "Failed to delete PII column", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, store.SuccessResponse{
+			Message: "PII column tag deleted successfully",
 		})
 	}
 }
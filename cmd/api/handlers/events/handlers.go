@@ -0,0 +1,150 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval keeps idle streams from being closed by proxies
+const heartbeatInterval = 15 * time.Second
+
+// Stream serves lifecycle events (run started, completed and failed, analysis
+// completed and so on) as Server-Sent Events, for clients behind proxies that
+// block WebSockets. ?types= takes a comma-separated list of event names or
+// prefixes such as report.run.*; ?report_id= limits events to one report.
+// Reconnecting clients resume after Last-Event-ID while it is still kept.
+func Stream(stream *services.EventStream) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var filter services.EventFilter
+		if types := c.Query("types"); types != "" {
+			for _, t := range strings.Split(types, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					filter.Types = append(filter.Types, t)
+				}
+			}
+		}
+		if raw := c.Query("report_id"); raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				apierror.BadRequest(c, "Invalid report_id", err)
+				return
+			}
+			filter.ReportID = uint(id)
+		}
+
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID == "" {
+			lastID = c.Query("last_event_id")
+		}
+		var since uint64
+		if lastID != "" {
+			id, err := strconv.ParseUint(lastID, 10, 64)
+			if err != nil {
+				apierror.BadRequest(c, "Invalid Last-Event-ID", err)
+				return
+			}
+			since = id
+		}
+
+		events, cancel := stream.Subscribe(filter, since)
+		defer cancel()
+
+		startStream(c)
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			var err error
+			select {
+			case e := <-events:
+				err = writeEvent(c.Writer, strconv.FormatUint(e.ID, 10), e.Event, e)
+			case <-heartbeat.C:
+				_, err = io.WriteString(c.Writer, ": ping\n\n")
+			case <-c.Request.Context().Done():
+				return
+			}
+			if err != nil {
+				logger.LogWarn(logger.ServiceREST, "Event stream closed", map[string]interface{}{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// Chat answers a chat completion as Server-Sent Events carrying the same
+// chat_typing, chat_response and chat_error messages as the chat WebSocket
+func Chat(service *services.AIService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Messages []llm.Message `json:"messages" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		startStream(c)
+		send := func(event string, payload gin.H) {
+			writeEvent(c.Writer, "", event, gin.H{
+				"type":      event,
+				"payload":   payload,
+				"timestamp": time.Now(),
+			})
+			c.Writer.Flush()
+		}
+
+		send("chat_typing", gin.H{"is_typing": true})
+		response, err := service.ChatCompletion(c.Request.Context(), req.Messages)
+		send("chat_typing", gin.H{"is_typing": false})
+
+		// Headers are already sent, so failures are reported as an event
+		if err != nil {
+			logger.LogError(logger.ServiceREST, "Streamed chat completion failed", err)
+			send("chat_error", gin.H{"error": err.Error()})
+			return
+		}
+		send("chat_response", gin.H{
+			"content": response.Message.Content,
+			"model":   response.Model,
+		})
+	}
+}
+
+// startStream sends the Server-Sent Events headers
+func startStream(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+}
+
+// writeEvent writes one event; data is JSON so it always fits on one line
+func writeEvent(w io.Writer, id, event string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	return err
+}
@@ -0,0 +1,63 @@
+package examples
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// ListSQLExamples returns the few-shot example library of a datasource
+func ListSQLExamples(service *services.ExampleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		examples, err := service.ListExamples(c.Param("id"))
+		if err != nil {
+			apierror.Respond(c, "Failed to list SQL examples", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, examples)
+	}
+}
+
+// CreateSQLExample adds a verified question -> SQL example to a datasource
+func CreateSQLExample(service *services.ExampleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.CreateSQLExampleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		example, err := service.AddExample(c.Param("id"), req.Question, req.SQL, "manual", nil)
+		if err != nil {
+			apierror.Respond(c, "Failed to add SQL example", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, example)
+	}
+}
+
+// DeleteSQLExample removes an example from a datasource's library
+func DeleteSQLExample(service *services.ExampleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exampleID, err := strconv.ParseUint(c.Param("example_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid example ID", nil)
+			return
+		}
+
+		if err := service.DeleteExample(c.Param("id"), uint(exampleID)); err != nil {
+			apierror.Respond(c, "Failed to delete SQL example", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, store.SuccessResponse{
+			Message: "SQL example deleted successfully",
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package feedback
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateRunFeedback records a thumbs up/down on a run's SQL or analysis
+func CreateRunFeedback(service *services.FeedbackService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runID, err := strconv.ParseUint(c.Param("run_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid run ID", nil)
+			return
+		}
+
+		var req store.CreateRunFeedbackRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+		req.User = c.GetString("username")
+
+		feedback, err := service.CreateFeedback(uint(runID), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to record feedback", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, feedback)
+	}
+}
+
+// ListRunFeedback returns the feedback recorded for a run
+func ListRunFeedback(service *services.FeedbackService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runID, err := strconv.ParseUint(c.Param("run_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid run ID", nil)
+			return
+		}
+
+		feedback, err := service.ListRunFeedback(uint(runID))
+		if err != nil {
+			apierror.Respond(c, "Failed to list feedback", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, feedback)
+	}
+}
+
+// ExportFeedback returns rated prompt/response pairs as JSON Lines (default)
+// or a JSON array with ?format=json
+func ExportFeedback(service *services.FeedbackService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target := c.Query("target")
+		if target != "" && target != "sql" && target != "analysis" {
+			apierror.BadRequest(c, "target must be sql or analysis", nil)
+			return
+		}
+		rating := 0
+		if r := c.Query("rating"); r != "" {
+			var err error
+			if rating, err = strconv.Atoi(r); err != nil || (rating != 1 && rating != -1) {
+				apierror.BadRequest(c, "rating must be 1 or -1", nil)
+				return
+			}
+		}
+
+		pairs, err := service.ExportPairs(target, rating)
+		if err != nil {
+			apierror.Respond(c, "Failed to export feedback", err)
+			return
+		}
+
+		if c.Query("format") == "json" {
+			c.JSON(http.StatusOK, pairs)
+			return
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, pair := range pairs {
+			if err := enc.Encode(pair); err != nil {
+				apierror.Respond(c, "Failed to export feedback", err)
+				return
+			}
+		}
+		c.Header("Content-Disposition", `attachment; filename="feedback.jsonl"`)
+		c.Data(http.StatusOK, "application/x-ndjson", buf.Bytes())
+	}
+}
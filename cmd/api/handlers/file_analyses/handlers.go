@@ -0,0 +1,49 @@
+package file_analyses
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/cmd/api/handlers/listing"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListFileAnalyses lists saved file analyses, newest first by default.
+// ?file_id=, ?user_id= and ?model= narrow the list.
+func ListFileAnalyses(service *services.FileAnalysisService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		opts, ok := listing.Options(c)
+		if !ok {
+			return
+		}
+
+		analyses, page, err := service.ListFileAnalyses(opts)
+		if err != nil {
+			apierror.Respond(c, "Failed to list file analyses", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, listing.Body("analyses", analyses, page))
+	}
+}
+
+// GetFileAnalysis returns one saved file analysis with its insights and suggestions
+func GetFileAnalysis(service *services.FileAnalysisService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid analysis ID", nil)
+			return
+		}
+
+		analysis, err := service.GetFileAnalysis(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to get file analysis", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, analysis)
+	}
+}
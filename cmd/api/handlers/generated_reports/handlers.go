@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
 	"github.com/NubeDev/air/internal/logger"
 	"github.com/NubeDev/air/internal/store"
 	"github.com/gin-gonic/gin"
@@ -18,10 +19,7 @@ func ListReports(db *gorm.DB) gin.HandlerFunc {
 		var reports []store.GeneratedReport
 		if err := db.Where("status = ?", "active").Order("created_at DESC").Find(&reports).Error; err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to list reports", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to list reports",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to list reports", err)
 			return
 		}
 
@@ -37,26 +35,18 @@ func GetReport(db *gorm.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid report ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid report ID", err)
 			return
 		}
 
 		var report store.GeneratedReport
 		if err := db.Preload("Session").First(&report, uint(id)).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, store.ErrorResponse{
-					Error: "Report not found",
-				})
+				apierror.NotFound(c, "Report not found")
 				return
 			}
 			logger.LogError(logger.ServiceREST, "Failed to get report", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get report",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to get report", err)
 			return
 		}
 
@@ -69,10 +59,7 @@ func CreateReport(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req store.CreateGeneratedReportRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
@@ -90,10 +77,7 @@ func CreateReport(db *gorm.DB) gin.HandlerFunc {
 
 		if err := db.Create(&report).Error; err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to create report", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to create report",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to create report", err)
 			return
 		}
 
@@ -112,10 +96,7 @@ func UpdateReport(db *gorm.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid report ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid report ID", err)
 			return
 		}
 
@@ -126,10 +107,7 @@ func UpdateReport(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
@@ -149,17 +127,12 @@ func UpdateReport(db *gorm.DB) gin.HandlerFunc {
 		result := db.Model(&store.GeneratedReport{}).Where("id = ?", uint(id)).Updates(updates)
 		if result.Error != nil {
 			logger.LogError(logger.ServiceREST, "Failed to update report", result.Error)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to update report",
-				Details: result.Error.Error(),
-			})
+			apierror.Respond(c, "Failed to update report", result.Error)
 			return
 		}
 
 		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error: "Report not found",
-			})
+			apierror.NotFound(c, "Report not found")
 			return
 		}
 
@@ -167,10 +140,7 @@ func UpdateReport(db *gorm.DB) gin.HandlerFunc {
 		var report store.GeneratedReport
 		if err := db.First(&report, uint(id)).Error; err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to get updated report", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get updated report",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to get updated report", err)
 			return
 		}
 
@@ -184,10 +154,7 @@ func DeleteReport(db *gorm.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid report ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid report ID", err)
 			return
 		}
 
@@ -195,17 +162,12 @@ func DeleteReport(db *gorm.DB) gin.HandlerFunc {
 		result := db.Model(&store.GeneratedReport{}).Where("id = ?", uint(id)).Update("status", "archived")
 		if result.Error != nil {
 			logger.LogError(logger.ServiceREST, "Failed to delete report", result.Error)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to delete report",
-				Details: result.Error.Error(),
-			})
+			apierror.Respond(c, "Failed to delete report", result.Error)
 			return
 		}
 
 		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error: "Report not found",
-			})
+			apierror.NotFound(c, "Report not found")
 			return
 		}
 
@@ -225,19 +187,13 @@ func ExecuteReport(db *gorm.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid report ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid report ID", err)
 			return
 		}
 
 		var req store.ExecuteReportRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
@@ -245,16 +201,11 @@ func ExecuteReport(db *gorm.DB) gin.HandlerFunc {
 		var report store.GeneratedReport
 		if err := db.First(&report, uint(id)).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, store.ErrorResponse{
-					Error: "Report not found",
-				})
+				apierror.NotFound(c, "Report not found")
 				return
 			}
 			logger.LogError(logger.ServiceREST, "Failed to get report", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get report",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to get report", err)
 			return
 		}
 
@@ -0,0 +1,44 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/graphql"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Query executes a read-only GraphQL query, sent as a JSON body on POST or as
+// ?query=, ?variables= and ?operationName= on GET. Requests that cannot run
+// at all get 400; field errors come back with 200 next to the data.
+func Query(service *services.GraphQLService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req graphql.Request
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if raw := c.Query("variables"); raw != "" {
+				if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+					apierror.BadRequest(c, "Invalid variables", err)
+					return
+				}
+			}
+			if req.Query == "" {
+				apierror.BadRequest(c, "Missing query", nil)
+				return
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		resp := service.Execute(c.Request.Context(), req)
+		status := http.StatusOK
+		if resp.Data == nil {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, resp)
+	}
+}
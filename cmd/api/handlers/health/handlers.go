@@ -21,6 +21,7 @@ func WebSocketHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// TODO: Implement WebSocket handler
 		c.JSON(http.StatusNotImplemented, store.ErrorResponse{
+			Code:  store.ErrCodeInternal,
 			Error: "Not implemented",
 		})
 	}
@@ -0,0 +1,35 @@
+package ingest
+
+import (
+	"net/http"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListHistorySources returns the configured history sources and their point cursors
+func ListHistorySources(service *services.HistoryIngestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sources, err := service.ListSources()
+		if err != nil {
+			apierror.Respond(c, "Failed to list history sources", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"sources": sources})
+	}
+}
+
+// RunHistorySource ingests one history source immediately
+func RunHistorySource(service *services.HistoryIngestService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := service.RunSource(c.Param("name"))
+		if err != nil {
+			apierror.Respond(c, "Failed to ingest history source", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,65 @@
+package listing
+
+import (
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// reserved query parameters; every other parameter is a field filter
+var reserved = map[string]bool{
+	"cursor":        true,
+	"limit":         true,
+	"sort":          true,
+	"include_total": true,
+	"fields":        true,
+	"include":       true,
+}
+
+// Options reads ?cursor=, ?limit=, ?sort= and ?include_total= plus field
+// filters such as ?owner=ops. It writes a 400 and reports false when a value
+// is malformed.
+func Options(c *gin.Context) (store.ListOptions, bool) {
+	opts := store.ListOptions{
+		Cursor:  c.Query("cursor"),
+		Sort:    c.Query("sort"),
+		Filters: make(map[string]string),
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			apierror.BadRequest(c, "Invalid limit", nil)
+			return opts, false
+		}
+		opts.Limit = limit
+	}
+	if raw := c.Query("include_total"); raw != "" {
+		include, err := strconv.ParseBool(raw)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid include_total", err)
+			return opts, false
+		}
+		opts.IncludeTotal = include
+	}
+	for name, values := range c.Request.URL.Query() {
+		if !reserved[name] && len(values) > 0 {
+			opts.Filters[name] = values[0]
+		}
+	}
+	return opts, true
+}
+
+// Body is the response body of a page: the items under key, next_cursor when
+// there are more and total when it was counted
+func Body(key string, items interface{}, page *store.PageInfo) gin.H {
+	body := gin.H{key: items}
+	if page.NextCursor != "" {
+		body["next_cursor"] = page.NextCursor
+	}
+	if page.Total != nil {
+		body["total"] = *page.Total
+	}
+	return body
+}
@@ -0,0 +1,78 @@
+package notifications
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// ListReportNotifications returns the Slack/Teams notifiers for a report
+func ListReportNotifications(service *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		notifications, err := service.ListReportNotifications(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to list notifications", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, notifications)
+	}
+}
+
+// CreateReportNotification adds a Slack/Teams notifier to a report
+func CreateReportNotification(service *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		var req store.CreateReportNotificationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		notification, err := service.CreateReportNotification(uint(id), req)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to create notification", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, notification)
+	}
+}
+
+// DeleteReportNotification removes a notifier from a report
+func DeleteReportNotification(service *services.NotificationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+		notificationID, err := strconv.ParseUint(c.Param("notification_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid notification ID", nil)
+			return
+		}
+
+		if err := service.DeleteReportNotification(uint(id), uint(notificationID)); err != nil {
+			apierror.Respond(c, "Failed to delete notification", err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,43 @@
+package preferences
+
+import (
+	"net/http"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// GetPreferences returns the caller's preferences
+func GetPreferences(service *services.PreferenceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prefs, err := service.GetPreferences(c.GetString("username"))
+		if err != nil {
+			apierror.Respond(c, "Failed to get preferences", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, prefs)
+	}
+}
+
+// UpdatePreferences changes the fields of the caller's preferences that the
+// request sets
+func UpdatePreferences(service *services.PreferenceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.UpdateUserPreferencesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		prefs, err := service.UpdatePreferences(c.GetString("username"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to update preferences", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, prefs)
+	}
+}
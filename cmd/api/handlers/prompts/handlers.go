@@ -0,0 +1,135 @@
+package prompts
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// ListPrompts returns the caller's and shared saved prompts; ?q= narrows them
+// to names starting with it, for autocomplete
+func ListPrompts(service *services.PromptService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prompts, err := service.ListPrompts(c.GetString("username"), c.Query("q"))
+		if err != nil {
+			apierror.Respond(c, "Failed to list saved prompts", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"prompts": prompts})
+	}
+}
+
+// CreatePrompt saves a prompt to the caller's library
+func CreatePrompt(service *services.PromptService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.CreateSavedPromptRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		prompt, err := service.CreatePrompt(c.GetString("username"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to save prompt", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, prompt)
+	}
+}
+
+// GetPrompt returns one saved prompt
+func GetPrompt(service *services.PromptService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := promptID(c)
+		if !ok {
+			return
+		}
+
+		prompt, err := service.GetPrompt(id, c.GetString("username"))
+		if err != nil {
+			apierror.Respond(c, "Failed to get saved prompt", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, prompt)
+	}
+}
+
+// UpdatePrompt changes a prompt the caller owns
+func UpdatePrompt(service *services.PromptService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := promptID(c)
+		if !ok {
+			return
+		}
+		var req store.UpdateSavedPromptRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		prompt, err := service.UpdatePrompt(id, c.GetString("username"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to update saved prompt", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, prompt)
+	}
+}
+
+// DeletePrompt removes a prompt the caller owns
+func DeletePrompt(service *services.PromptService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := promptID(c)
+		if !ok {
+			return
+		}
+
+		if err := service.DeletePrompt(id, c.GetString("username")); err != nil {
+			apierror.Respond(c, "Failed to delete saved prompt", err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// RunPrompt runs a saved prompt against a session's files or a datasource
+func RunPrompt(service *services.PromptService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := promptID(c)
+		if !ok {
+			return
+		}
+		var req store.RunSavedPromptRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		run, err := service.RunPrompt(c.Request.Context(), id, c.GetString("username"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to run saved prompt", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, run)
+	}
+}
+
+// promptID parses the :id path parameter, answering 400 when it is invalid
+func promptID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierror.BadRequest(c, "Invalid prompt ID", nil)
+		return 0, false
+	}
+	return uint(id), true
+}
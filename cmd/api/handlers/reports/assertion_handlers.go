@@ -0,0 +1,97 @@
+package reports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateReportAssertion attaches an assertion to a report
+func CreateReportAssertion(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		var req store.CreateReportAssertionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		assertion, err := service.CreateReportAssertion(uint(id), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to create assertion", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, assertion)
+	}
+}
+
+// ListReportAssertions lists a report's assertions with their latest results
+func ListReportAssertions(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		assertions, err := service.ListReportAssertions(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to list assertions", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"assertions": assertions})
+	}
+}
+
+// DeleteReportAssertion removes an assertion from a report
+func DeleteReportAssertion(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+		assertionID, err := strconv.ParseUint(c.Param("assertion_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid assertion ID", nil)
+			return
+		}
+
+		if err := service.DeleteReportAssertion(uint(id), uint(assertionID)); err != nil {
+			apierror.Respond(c, "Failed to delete assertion", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, store.SuccessResponse{Message: "Assertion deleted successfully"})
+	}
+}
+
+// ListRunAssertionResults lists the assertion results of a run
+func ListRunAssertionResults(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runID, err := strconv.ParseUint(c.Param("run_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid run ID", nil)
+			return
+		}
+
+		results, err := service.ListRunAssertionResults(uint(runID))
+		if err != nil {
+			apierror.Respond(c, "Failed to list assertion results", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	}
+}
@@ -0,0 +1,122 @@
+package reports
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// BatchRunReports runs a set of reports with shared params and returns the batch ID
+func BatchRunReports(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.BatchRunReportsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+		req.User = c.GetString("username")
+
+		batch, err := service.StartBatchRun(req)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to start batch run", err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, batch)
+	}
+}
+
+// GetBatch returns a batch run with per-report statuses
+func GetBatch(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("batch_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid batch ID", nil)
+			return
+		}
+
+		batch, err := service.GetBatch(uint(id))
+		if err != nil {
+			apierror.NotFound(c, "Batch not found")
+			return
+		}
+
+		c.JSON(http.StatusOK, batch)
+	}
+}
+
+// BatchArchiveReports archives many reports at once
+func BatchArchiveReports(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.BatchArchiveReportsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		keys, err := service.ArchiveReports(req.ReportSelector)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to archive reports", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"archived": keys,
+			"count":    len(keys),
+		})
+	}
+}
+
+// BatchExportReports exports the selected reports as a single JSON bundle
+func BatchExportReports(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.BatchExportReportsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		bundle, err := service.ExportReports(req)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to export reports", err)
+			return
+		}
+
+		filename := fmt.Sprintf("reports_%s.json", time.Now().Format("20060102_150405"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.JSON(http.StatusOK, bundle)
+	}
+}
+
+// RunReportFanOut runs a portable report against several datasources in
+// parallel and returns each one's status and their merged rows
+func RunReportFanOut(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+		var req store.FanOutRunRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+		req.User = c.GetString("username")
+		req.TraceID = c.GetString("request_id")
+
+		run, err := service.RunReportFanOut(uint(id), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to run report on datasources", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, run)
+	}
+}
@@ -0,0 +1,92 @@
+package reports
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/cmd/api/handlers/conditional"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// versionETag formats a version number as a strong entity tag
+func versionETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// reportETag tags a report representation with its latest version and a hash
+// of the body, so If-Match can use the version and If-None-Match the whole tag
+func reportETag(version int, hash string) string {
+	return strconv.Quote(strconv.Itoa(version) + "-" + hash)
+}
+
+// ifMatchVersion parses the If-Match header into the version an edit is based
+// on. A missing header or "*" returns nil, meaning no check.
+func ifMatchVersion(c *gin.Context) (*int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	tag, _, _ = strings.Cut(tag, "-")
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 0 {
+		return nil, errors.New(`expected a version ETag such as "3"`)
+	}
+	return &version, nil
+}
+
+// bindIfMatch sets base from If-Match when the header is present, taking
+// precedence over base_version in the body. It reports false after writing a
+// 400 for a malformed header.
+func bindIfMatch(c *gin.Context, base **int) bool {
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		apierror.BadRequest(c, "Invalid If-Match header", err)
+		return false
+	}
+	if version != nil {
+		*base = version
+	}
+	return true
+}
+
+// respondReport writes a report tagged with reportETag, or 304 when the
+// client's copy is current
+func respondReport(c *gin.Context, service *services.ReportsService, report *store.Report) {
+	latest, err := service.LatestReportVersion(report.ID)
+	if err != nil {
+		apierror.Respond(c, "Failed to get report", err)
+		return
+	}
+	hash, err := conditional.Hash(report)
+	if err != nil {
+		apierror.Respond(c, "Failed to encode report", err)
+		return
+	}
+	conditional.Write(c, reportETag(latest, hash), report.UpdatedAt, report)
+}
+
+// respondVersionError writes a VersionConflictResponse with the latest version
+// for stale If-Match edits and falls back to apierror.Respond otherwise
+func respondVersionError(c *gin.Context, message string, err error) {
+	var conflict *services.VersionConflictError
+	if !errors.As(err, &conflict) {
+		apierror.Respond(c, message, err)
+		return
+	}
+	c.Header("ETag", versionETag(conflict.Current))
+	c.JSON(http.StatusConflict, store.VersionConflictResponse{
+		ErrorResponse: store.ErrorResponse{
+			Code:    store.ErrCodeConflict,
+			Error:   message,
+			Details: err.Error(),
+		},
+		CurrentVersion: conflict.Current,
+		Current:        conflict.Latest,
+	})
+}
@@ -0,0 +1,72 @@
+package reports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ListRecentReports lists the reports the caller opened or ran lately, or
+// their favorites with ?favorites=true
+func ListRecentReports(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 0
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				apierror.BadRequest(c, "Invalid limit", nil)
+				return
+			}
+			limit = parsed
+		}
+		favorites := false
+		if raw := c.Query("favorites"); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				apierror.BadRequest(c, "Invalid favorites", err)
+				return
+			}
+			favorites = parsed
+		}
+
+		reports, err := service.RecentReports(c.GetString("username"), favorites, limit)
+		if err != nil {
+			apierror.Respond(c, "Failed to list recent reports", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"reports": reports})
+	}
+}
+
+// FavoriteReport adds a report to the caller's favorites
+func FavoriteReport(service *services.ReportsService) gin.HandlerFunc {
+	return setFavorite(service, true)
+}
+
+// UnfavoriteReport removes a report from the caller's favorites
+func UnfavoriteReport(service *services.ReportsService) gin.HandlerFunc {
+	return setFavorite(service, false)
+}
+
+// setFavorite marks or unmarks the report in the path as a favorite
+func setFavorite(service *services.ReportsService, favorite bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", err)
+			return
+		}
+
+		report, err := service.SetReportFavorite(uint(id), c.GetString("username"), favorite)
+		if err != nil {
+			apierror.Respond(c, "Failed to update favorite", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -1,10 +1,13 @@
 package reports
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/cmd/api/handlers/listing"
+	"github.com/NubeDev/air/cmd/api/handlers/sparse"
 	"github.com/NubeDev/air/internal/logger"
 	"github.com/NubeDev/air/internal/services"
 	"github.com/NubeDev/air/internal/store"
@@ -16,20 +19,14 @@ func CreateScope(service *services.ReportsService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req store.CreateScopeRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
 		scope, err := service.CreateScope(req)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to create scope", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to create scope",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to create scope", err)
 			return
 		}
 
@@ -43,21 +40,22 @@ func GetScope(service *services.ReportsService) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid scope ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid scope ID", err)
 			return
 		}
 
 		scope, err := service.GetScope(uint(id))
 		if err != nil {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error: "Scope not found",
-			})
+			apierror.NotFound(c, "Scope not found")
+			return
+		}
+		latest, err := service.LatestScopeVersion(scope.ID)
+		if err != nil {
+			apierror.Respond(c, "Failed to get scope", err)
 			return
 		}
 
+		c.Header("ETag", versionETag(latest))
 		c.JSON(http.StatusOK, scope)
 	}
 }
@@ -68,55 +66,107 @@ func CreateScopeVersion(service *services.ReportsService) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid scope ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid scope ID", err)
 			return
 		}
 
 		var req store.CreateScopeVersionRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+		if !bindIfMatch(c, &req.BaseVersion) {
 			return
 		}
 
 		version, err := service.CreateScopeVersion(uint(id), req)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to create scope version", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to create scope version",
-				Details: err.Error(),
-			})
+			respondVersionError(c, "Failed to create scope version", err)
 			return
 		}
 
+		c.Header("ETag", versionETag(version.Version))
 		c.JSON(http.StatusCreated, version)
 	}
 }
 
+// ListScopeVersions lists the versions of a scope
+func ListScopeVersions(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid scope ID", err)
+			return
+		}
+
+		versions, err := service.ListScopeVersions(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to list scope versions", err)
+			return
+		}
+		if len(versions) > 0 {
+			c.Header("ETag", versionETag(versions[0].Version))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"versions": versions,
+			"count":    len(versions),
+		})
+	}
+}
+
+// DiffScopeVersions compares two scope versions (?from=1&to=2)
+func DiffScopeVersions(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid scope ID", err)
+			return
+		}
+
+		from, err := strconv.Atoi(c.Query("from"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, store.ErrorResponse{
+				Code:    store.ErrCodeValidation,
+				Error:   "Invalid from version",
+				Details: "from must be a version number",
+			})
+			return
+		}
+		to, err := strconv.Atoi(c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, store.ErrorResponse{
+				Code:    store.ErrCodeValidation,
+				Error:   "Invalid to version",
+				Details: "to must be a version number",
+			})
+			return
+		}
+
+		diff, err := service.DiffScopeVersions(uint(id), from, to)
+		if err != nil {
+			apierror.Respond(c, "Failed to diff scope versions", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
 // CreateReport creates a new report
 func CreateReport(service *services.ReportsService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req store.CreateReportRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
 		report, err := service.CreateReport(req)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to create report", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to create report",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to create report", err)
 			return
 		}
 
@@ -130,28 +180,28 @@ func GetReport(service *services.ReportsService) gin.HandlerFunc {
 		key := c.Param("key")
 		report, err := service.GetReport(key)
 		if err != nil {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error: "Report not found",
-			})
+			apierror.NotFound(c, "Report not found")
 			return
 		}
+		service.TrackReportAccess(c.GetString("username"), report.ID)
 
-		c.JSON(http.StatusOK, report)
+		respondReport(c, service, report)
 	}
 }
 
-// ListReports lists all reports
+// ListReports lists reports a page at a time
 func ListReports(service *services.ReportsService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		reports, err := service.ListReports()
+		opts, ok := listing.Options(c)
+		if !ok {
+			return
+		}
+		reports, page, err := service.ListReports(opts, c.GetString("username"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to list reports",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to list reports", err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"reports": reports})
+		c.JSON(http.StatusOK, listing.Body("reports", reports, page))
 	}
 }
 
@@ -161,15 +211,16 @@ func GetReportByID(service *services.ReportsService) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{Error: "Invalid report ID"})
+			apierror.BadRequest(c, "Invalid report ID", nil)
 			return
 		}
 		report, err := service.GetReportByID(uint(id))
 		if err != nil {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{Error: "Report not found"})
+			apierror.NotFound(c, "Report not found")
 			return
 		}
-		c.JSON(http.StatusOK, report)
+		service.TrackReportAccess(c.GetString("username"), report.ID)
+		respondReport(c, service, report)
 	}
 }
 
@@ -179,51 +230,75 @@ func CreateReportVersion(service *services.ReportsService) gin.HandlerFunc {
 		key := c.Param("key")
 		var req store.CreateReportVersionRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
+		if !bindIfMatch(c, &req.BaseVersion) {
+			return
+		}
+		req.User = c.GetString("username")
 
 		version, err := service.CreateReportVersion(key, req)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to create report version", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to create report version",
-				Details: err.Error(),
-			})
+			respondVersionError(c, "Failed to create report version", err)
 			return
 		}
+		c.Header("ETag", versionETag(version.Version))
 
 		c.JSON(http.StatusCreated, version)
 	}
 }
 
+// CreateSQLReport registers hand-written SQL as a report without scopes or IR
+func CreateSQLReport(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.CreateSQLReportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+		req.User = c.GetString("username")
+
+		result, err := service.CreateSQLReport(req)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to create SQL report", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
 // CreateReportVersionByID creates a report version using report ID
 func CreateReportVersionByID(service *services.ReportsService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{Error: "Invalid report ID"})
+			apierror.BadRequest(c, "Invalid report ID", nil)
 			return
 		}
 		var req store.CreateReportVersionRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{Error: "Invalid request", Details: err.Error()})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
+		if !bindIfMatch(c, &req.BaseVersion) {
+			return
+		}
+		req.User = c.GetString("username")
 		report, err := service.GetReportByID(uint(id))
 		if err != nil {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{Error: "Report not found"})
+			apierror.NotFound(c, "Report not found")
 			return
 		}
 		version, err := service.CreateReportVersion(report.Key, req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{Error: "Failed to create report version", Details: err.Error()})
+			respondVersionError(c, "Failed to create report version", err)
 			return
 		}
+		c.Header("ETag", versionETag(version.Version))
 		c.JSON(http.StatusCreated, version)
 	}
 }
@@ -236,28 +311,34 @@ func RunReport(service *services.ReportsService) gin.HandlerFunc {
 
 		var req store.RunReportRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
 		if datasourceID != "" {
 			req.DatasourceID = &datasourceID
 		}
+		req.User = c.GetString("username")
+		req.TraceID = c.GetString("request_id")
+		req.AllowAsync = true
+		view := sparse.RunView(c, sparse.AllRunIncludes())
+		if err := services.ValidateRunView(view); err != nil {
+			apierror.Respond(c, "Invalid run view", err)
+			return
+		}
 
 		run, err := service.RunReport(key, req)
+		if errors.Is(err, services.ErrRowEstimateExceeded) {
+			apierror.Respond(c, "Report refused by row estimate", err)
+			return
+		}
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to run report", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to run report",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to run report", err)
 			return
 		}
 
-		c.JSON(http.StatusOK, run)
+		respondRun(c, run, view)
 	}
 }
 
@@ -267,24 +348,36 @@ func ExecuteReportByID(service *services.ReportsService) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{Error: "Invalid report ID"})
+			apierror.BadRequest(c, "Invalid report ID", nil)
 			return
 		}
 		datasourceID := c.Query("datasource_id")
 		var req store.RunReportRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{Error: "Invalid request", Details: err.Error()})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 		if datasourceID != "" {
 			req.DatasourceID = &datasourceID
 		}
+		req.User = c.GetString("username")
+		req.TraceID = c.GetString("request_id")
+		req.AllowAsync = true
+		view := sparse.RunView(c, sparse.AllRunIncludes())
+		if err := services.ValidateRunView(view); err != nil {
+			apierror.Respond(c, "Invalid run view", err)
+			return
+		}
 		run, err := service.RunReportByID(uint(id), req)
+		if errors.Is(err, services.ErrRowEstimateExceeded) {
+			apierror.Respond(c, "Report refused by row estimate", err)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{Error: "Failed to execute report", Details: err.Error()})
+			apierror.Respond(c, "Failed to execute report", err)
 			return
 		}
-		c.JSON(http.StatusOK, run)
+		respondRun(c, run, view)
 	}
 }
 
@@ -294,11 +387,11 @@ func DeleteReportByID(service *services.ReportsService) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{Error: "Invalid report ID"})
+			apierror.BadRequest(c, "Invalid report ID", nil)
 			return
 		}
 		if err := service.DeleteReportByID(uint(id)); err != nil {
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{Error: "Failed to delete report", Details: err.Error()})
+			apierror.Respond(c, "Failed to delete report", err)
 			return
 		}
 		c.JSON(http.StatusOK, store.SuccessResponse{Message: "Report deleted successfully"})
@@ -314,10 +407,7 @@ func ExportReport(service *services.ReportsService) gin.HandlerFunc {
 		export, err := service.ExportReport(key, format)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to export report", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to export report",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to export report", err)
 			return
 		}
 
@@ -331,39 +421,46 @@ func GetReportData(service *services.ReportsService) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{Error: "Invalid report ID"})
+			apierror.BadRequest(c, "Invalid report ID", nil)
 			return
 		}
 
+		// Materialized reports are served from their snapshot unless ?source=live
+		if c.Query("source") != "live" {
+			snapshot, err := service.GetSnapshotData(uint(id))
+			if err != nil {
+				apierror.Respond(c, "Failed to read report snapshot", err)
+				return
+			}
+			if snapshot != nil {
+				c.JSON(http.StatusOK, snapshot)
+				return
+			}
+		}
+
 		// Get the latest report run for this report
 		run, err := service.GetLatestReportRun(uint(id))
 		if err != nil {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error:   "Report data not found",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Report data not found", err)
 			return
 		}
 
-		// Parse the results JSON
-		var results []map[string]interface{}
-		if run.Results != "" {
-			if err := json.Unmarshal([]byte(run.Results), &results); err != nil {
-				c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-					Error:   "Failed to parse report data",
-					Details: err.Error(),
-				})
-				return
-			}
+		results, err := services.ParseRunResults(run.Results)
+		if err != nil {
+			apierror.Respond(c, "Failed to parse report data", err)
+			return
 		}
 
-		// Return the data in a clean format
+		// Columns keep the select order; each row has one value per column
 		response := map[string]interface{}{
 			"report_id":    run.ReportID,
+			"source":       "run",
 			"run_id":       run.ID,
 			"status":       run.Status,
 			"row_count":    run.RowCount,
-			"data":         results,
+			"truncated":    run.Truncated,
+			"columns":      results.Columns,
+			"rows":         results.Rows,
 			"executed_at":  run.StartedAt,
 			"completed_at": run.FinishedAt,
 			"sql":          run.SQLText,
@@ -0,0 +1,107 @@
+package reports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// GetLineage lists the reports reading a table or column, or the columns a
+// report reads
+func GetLineage(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lineage, err := service.Lineage(store.LineageQuery{
+			DatasourceID: c.Query("datasource_id"),
+			Table:        c.Query("table"),
+			Column:       c.Query("column"),
+			Report:       c.Query("report"),
+		})
+		if err != nil {
+			apierror.Respond(c, "Failed to query lineage", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, lineage)
+	}
+}
+
+// RevalidateReport clears a report's stale versions once the schema they read
+// is back, unblocking scheduled snapshot refreshes
+func RevalidateReport(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", err)
+			return
+		}
+
+		result, err := service.RevalidateReport(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to revalidate report", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ListPendingReviews lists the report versions waiting for a reviewer
+func ListPendingReviews(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviews, err := service.ListPendingReviews()
+		if err != nil {
+			apierror.Respond(c, "Failed to list pending reviews", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, reviews)
+	}
+}
+
+// ApproveReportVersion approves a report version's SQL
+func ApproveReportVersion(service *services.ReportsService) gin.HandlerFunc {
+	return reviewReportVersion("approve", service.ApproveReportVersion)
+}
+
+// RejectReportVersion rejects a report version's SQL
+func RejectReportVersion(service *services.ReportsService) gin.HandlerFunc {
+	return reviewReportVersion("reject", service.RejectReportVersion)
+}
+
+func reviewReportVersion(action string, review func(uint, int, store.ReviewReportVersionRequest) (*store.ReportVersion, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", err)
+			return
+		}
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil || version < 1 {
+			apierror.BadRequest(c, "Invalid version", nil)
+			return
+		}
+
+		var req store.ReviewReportVersionRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.BadRequest(c, "Invalid request", err)
+				return
+			}
+		}
+		if username := c.GetString("username"); username != "" {
+			req.Reviewer = username
+		}
+
+		result, err := review(uint(id), version, req)
+		if err != nil {
+			apierror.Respond(c, "Failed to "+action+" report version", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
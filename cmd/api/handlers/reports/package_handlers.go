@@ -0,0 +1,76 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPackageUploadSize caps .airpkg uploads
+const maxPackageUploadSize = 50 << 20
+
+// ExportPackage exports the selected reports as a .airpkg archive
+func ExportPackage(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.ExportPackageRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		data, err := service.ExportPackage(req)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to export package", err)
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", req.Name+".airpkg"))
+		c.Data(http.StatusOK, "application/zip", data)
+	}
+}
+
+// ImportPackage installs a .airpkg archive sent as multipart "file" or as the raw body
+func ImportPackage(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasourceID := c.Query("datasource_id")
+		if datasourceID == "" {
+			c.JSON(http.StatusBadRequest, store.ErrorResponse{
+				Code:    store.ErrCodeValidation,
+				Error:   "datasource_id is required",
+				Details: "Packages are installed against a target datasource",
+			})
+			return
+		}
+		overwrite := c.Query("overwrite") == "true"
+
+		var reader io.Reader = c.Request.Body
+		if file, err := c.FormFile("file"); err == nil {
+			f, err := file.Open()
+			if err != nil {
+				apierror.BadRequest(c, "Failed to read uploaded package", err)
+				return
+			}
+			defer f.Close()
+			reader = f
+		}
+
+		data, err := io.ReadAll(io.LimitReader(reader, maxPackageUploadSize))
+		if err != nil || len(data) == 0 {
+			apierror.BadRequest(c, "Package body is empty or unreadable", nil)
+			return
+		}
+
+		result, err := service.InstallPackage(data, datasourceID, overwrite)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to install package", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,117 @@
+package reports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/cmd/api/handlers/listing"
+	"github.com/NubeDev/air/cmd/api/handlers/sparse"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// ListReportRuns lists a report's runs a page at a time. Relations are left
+// out unless named in ?include=, and ?fields=id,status,row_count returns
+// summaries without the result blob.
+func ListReportRuns(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+		opts, ok := listing.Options(c)
+		if !ok {
+			return
+		}
+		view := sparse.RunView(c, nil)
+
+		runs, page, err := service.ListReportRuns(uint(id), opts, view)
+		if err != nil {
+			apierror.Respond(c, "Failed to list report runs", err)
+			return
+		}
+
+		items, err := sparse.Runs(runs, view)
+		if err != nil {
+			apierror.Respond(c, "Failed to encode report runs", err)
+			return
+		}
+		c.JSON(http.StatusOK, listing.Body("runs", items, page))
+	}
+}
+
+// GetReportRun returns one run, which is how callers handed a 202 for a
+// slow run poll for its results
+func GetReportRun(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("run_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid run ID", nil)
+			return
+		}
+		view := sparse.RunView(c, sparse.AllRunIncludes())
+		if err := services.ValidateRunView(view); err != nil {
+			apierror.Respond(c, "Invalid run view", err)
+			return
+		}
+
+		run, err := service.GetReportRun(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to get report run", err)
+			return
+		}
+		body, err := sparse.Runs(run, view)
+		if err != nil {
+			apierror.Respond(c, "Failed to encode report run", err)
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+// respondRun writes a run trimmed to view. A run still executing after the
+// sync threshold is answered with 202; its completion arrives as a
+// report.run.completed or report.run.failed event.
+func respondRun(c *gin.Context, run *store.ReportRun, view store.RunView) {
+	body, err := sparse.Runs(run, view)
+	if err != nil {
+		apierror.Respond(c, "Failed to encode report run", err)
+		return
+	}
+	if run.Status == "running" {
+		c.JSON(http.StatusAccepted, body)
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// EstimateReportCost dry-runs a report on a warehouse billed by bytes
+// scanned and returns the estimate against the budgets, without running it
+func EstimateReportCost(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+		var req store.RunReportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+		if datasourceID := c.Query("datasource_id"); datasourceID != "" {
+			req.DatasourceID = &datasourceID
+		}
+		req.User = c.GetString("username")
+
+		estimate, err := service.EstimateReportCost(uint(id), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to estimate report cost", err)
+			return
+		}
+		c.JSON(http.StatusOK, estimate)
+	}
+}
@@ -0,0 +1,59 @@
+package reports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateReport runs a report version on its datasource's sandbox and checks
+// the result shape
+func ValidateReport(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", err)
+			return
+		}
+
+		var req store.ValidateReportRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				apierror.BadRequest(c, "Invalid request", err)
+				return
+			}
+		}
+		req.User = c.GetString("username")
+
+		validation, err := service.ValidateReport(uint(id), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to validate report", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, validation)
+	}
+}
+
+// ListReportValidations lists a report's validate runs
+func ListReportValidations(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", err)
+			return
+		}
+
+		validations, err := service.ListReportValidations(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to list validations", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"validations": validations})
+	}
+}
@@ -0,0 +1,166 @@
+package reports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateShareLink shares a run of the report, or the live report with frozen
+// params. The token is only returned here.
+func CreateShareLink(service *services.ShareService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		var req store.CreateShareLinkRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		link, err := service.CreateShareLink(uint(id), c.GetString("username"), req)
+		if err != nil {
+			apierror.Respond(c, "Failed to create share link", err)
+			return
+		}
+		c.JSON(http.StatusCreated, link)
+	}
+}
+
+// ListShareLinks lists a report's share links
+func ListShareLinks(service *services.ShareService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		links, err := service.ListShareLinks(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to list share links", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"shares": links})
+	}
+}
+
+// RevokeShareLink ends a share link
+func RevokeShareLink(service *services.ShareService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, shareID, ok := shareIDs(c)
+		if !ok {
+			return
+		}
+
+		link, err := service.RevokeShareLink(id, shareID, c.GetString("username"))
+		if err != nil {
+			apierror.Respond(c, "Failed to revoke share link", err)
+			return
+		}
+		c.JSON(http.StatusOK, link)
+	}
+}
+
+// ListShareAccesses lists the newest requests made with a share link
+func ListShareAccesses(service *services.ShareService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, shareID, ok := shareIDs(c)
+		if !ok {
+			return
+		}
+		limit := 0
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				apierror.BadRequest(c, "Invalid limit", nil)
+				return
+			}
+			limit = parsed
+		}
+
+		accesses, err := service.ListShareAccesses(id, shareID, limit)
+		if err != nil {
+			apierror.Respond(c, "Failed to list share accesses", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"accesses": accesses})
+	}
+}
+
+// GetSharedLink describes what a share token gives access to. No account is
+// needed; the token is the credential.
+func GetSharedLink(service *services.ShareService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link, err := service.SharedLink(c.Param("token"), shareRequest(c, "link"))
+		if err != nil {
+			apierror.Respond(c, "Share link not available", err)
+			return
+		}
+		c.JSON(http.StatusOK, link)
+	}
+}
+
+// GetSharedData returns the results a share token gives access to
+func GetSharedData(service *services.ShareService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := service.SharedData(c.Param("token"), shareRequest(c, "data"))
+		if err != nil {
+			apierror.Respond(c, "Share link not available", err)
+			return
+		}
+		c.JSON(http.StatusOK, data)
+	}
+}
+
+// GetSharedSummary renders the run summary a share token gives access to, as
+// Markdown or HTML with ?format=html
+func GetSharedSummary(service *services.ShareService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", services.SummaryMarkdown)
+		summary, err := service.SharedSummary(c.Param("token"), format, shareRequest(c, "summary"))
+		if err != nil {
+			apierror.Respond(c, "Share link not available", err)
+			return
+		}
+
+		contentType := "text/markdown; charset=utf-8"
+		if format == services.SummaryHTML {
+			contentType = "text/html; charset=utf-8"
+			c.Header("Content-Security-Policy", summaryCSP)
+		}
+		c.Data(http.StatusOK, contentType, []byte(summary))
+	}
+}
+
+// shareIDs parses the report and share IDs in the path
+func shareIDs(c *gin.Context) (uint, uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		apierror.BadRequest(c, "Invalid report ID", nil)
+		return 0, 0, false
+	}
+	shareID, err := strconv.ParseUint(c.Param("share_id"), 10, 32)
+	if err != nil {
+		apierror.BadRequest(c, "Invalid share ID", nil)
+		return 0, 0, false
+	}
+	return uint(id), uint(shareID), true
+}
+
+// shareRequest describes the request for the share access log
+func shareRequest(c *gin.Context, endpoint string) services.ShareRequest {
+	return services.ShareRequest{
+		Endpoint:   endpoint,
+		RemoteAddr: c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+}
@@ -0,0 +1,92 @@
+package reports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// MaterializeReport marks a report version for scheduled snapshotting
+func MaterializeReport(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		var req store.MaterializeReportRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		m, err := service.MaterializeReport(uint(id), req)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to materialize report", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// GetMaterialization returns a report's snapshot settings and refresh state
+func GetMaterialization(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		m, err := service.GetMaterialization(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Materialization not found", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// DematerializeReport stops snapshotting a report and drops its snapshot table
+func DematerializeReport(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		if err := service.DematerializeReport(uint(id)); err != nil {
+			apierror.Respond(c, "Failed to dematerialize report", err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// RefreshSnapshot refreshes a materialized report immediately
+func RefreshSnapshot(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		m, err := service.RefreshSnapshot(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to refresh snapshot", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, m)
+	}
+}
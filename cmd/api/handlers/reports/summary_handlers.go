@@ -0,0 +1,101 @@
+package reports
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// summaryCSP keeps a custom HTML summary template from running scripts or
+// loading anything when the summary is opened in a browser
+const summaryCSP = "default-src 'none'; style-src 'unsafe-inline'; img-src data: https:"
+
+// GetRunSummary renders a run's digest as Markdown, or HTML with ?format=html
+func GetRunSummary(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("run_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid run ID", nil)
+			return
+		}
+		format := c.DefaultQuery("format", services.SummaryMarkdown)
+
+		summary, err := service.RenderRunSummary(uint(id), format)
+		if err != nil {
+			apierror.Respond(c, "Failed to render run summary", err)
+			return
+		}
+
+		contentType := "text/markdown; charset=utf-8"
+		if format == services.SummaryHTML {
+			contentType = "text/html; charset=utf-8"
+			c.Header("Content-Security-Policy", summaryCSP)
+		}
+		c.Data(http.StatusOK, contentType, []byte(summary))
+	}
+}
+
+// ListSummaryTemplates lists a report's summary template overrides
+func ListSummaryTemplates(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		templates, err := service.ListSummaryTemplates(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to list summary templates", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"templates": templates})
+	}
+}
+
+// SetSummaryTemplate overrides a report's summary template for the format in
+// the path
+func SetSummaryTemplate(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		var req store.SetSummaryTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		tmpl, err := service.SetSummaryTemplate(uint(id), c.Param("format"), req.Template, c.GetString("username"))
+		if err != nil {
+			apierror.Respond(c, "Failed to set summary template", err)
+			return
+		}
+		c.JSON(http.StatusOK, tmpl)
+	}
+}
+
+// DeleteSummaryTemplate restores a report's default summary template for the
+// format in the path
+func DeleteSummaryTemplate(service *services.ReportsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid report ID", nil)
+			return
+		}
+
+		if err := service.DeleteSummaryTemplate(uint(id), c.Param("format")); err != nil {
+			apierror.Respond(c, "Failed to delete summary template", err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
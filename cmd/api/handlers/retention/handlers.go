@@ -0,0 +1,41 @@
+package retention
+
+import (
+	"net/http"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetRetention returns the retention policy and the last cleanup's outcome
+func GetRetention(service *services.RetentionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := service.Policy()
+		c.JSON(http.StatusOK, gin.H{
+			"policy": gin.H{
+				"interval":             policy.Interval.String(),
+				"keep_runs_per_report": policy.KeepRunsPerReport,
+				"results_max_age":      policy.ResultsMaxAge.String(),
+				"traces_max_age":       policy.TracesMaxAge.String(),
+				"orphan_uploads":       policy.OrphanUploads,
+				"orphan_upload_age":    policy.OrphanUploadAge.String(),
+			},
+			"last_run": service.LastReport(),
+		})
+	}
+}
+
+// RunRetention applies the retention policy now; ?dry_run=true only reports
+// what would be removed
+func RunRetention(service *services.RetentionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := service.Run(c.Query("dry_run") == "true")
+		if err != nil {
+			apierror.Respond(c, "Failed to run retention cleanup", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
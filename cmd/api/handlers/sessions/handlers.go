@@ -5,7 +5,10 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/cmd/api/handlers/listing"
 	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/services"
 	"github.com/NubeDev/air/internal/store"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -16,20 +19,14 @@ func StartSession(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req store.StartSessionRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid request",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid request", err)
 			return
 		}
 
 		// Convert options to JSON string
 		optionsJSON, err := json.Marshal(req.Options)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid options",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid options", err)
 			return
 		}
 
@@ -44,10 +41,7 @@ func StartSession(db *gorm.DB) gin.HandlerFunc {
 
 		if err := db.Create(&session).Error; err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to create session", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to create session",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to create session", err)
 			return
 		}
 
@@ -67,26 +61,18 @@ func GetSession(db *gorm.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid session ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid session ID", err)
 			return
 		}
 
 		var session store.Session
 		if err := db.First(&session, uint(id)).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, store.ErrorResponse{
-					Error: "Session not found",
-				})
+				apierror.NotFound(c, "Session not found")
 				return
 			}
 			logger.LogError(logger.ServiceREST, "Failed to get session", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get session",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to get session", err)
 			return
 		}
 
@@ -94,22 +80,38 @@ func GetSession(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// ListSessions lists all sessions
+// sessionList is the sort and filter fields of ListSessions
+var sessionList = services.ListSpec{
+	Table: "sessions",
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"name":       "name",
+	},
+	Filters: map[string]string{
+		"status":          "status",
+		"datasource_type": "datasource_type",
+	},
+	DefaultSort: "-created_at",
+}
+
+// ListSessions lists sessions a page at a time, newest first by default
 func ListSessions(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		opts, ok := listing.Options(c)
+		if !ok {
+			return
+		}
+
 		var sessions []store.Session
-		if err := db.Order("created_at DESC").Find(&sessions).Error; err != nil {
+		page, err := services.ListPage(db, sessionList, opts, &sessions)
+		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to list sessions", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to list sessions",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to list sessions", err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"sessions": sessions,
-		})
+		c.JSON(http.StatusOK, listing.Body("sessions", sessions, page))
 	}
 }
 
@@ -119,26 +121,18 @@ func GetSessionStatus(db *gorm.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid session ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid session ID", err)
 			return
 		}
 
 		var session store.Session
 		if err := db.Select("id, status, created_at, updated_at").First(&session, uint(id)).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, store.ErrorResponse{
-					Error: "Session not found",
-				})
+				apierror.NotFound(c, "Session not found")
 				return
 			}
 			logger.LogError(logger.ServiceREST, "Failed to get session status", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get session status",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to get session status", err)
 			return
 		}
 
@@ -157,10 +151,7 @@ func EndSession(db *gorm.DB) gin.HandlerFunc {
 		idStr := c.Param("id")
 		id, err := strconv.ParseUint(idStr, 10, 32)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "Invalid session ID",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "Invalid session ID", err)
 			return
 		}
 
@@ -168,17 +159,12 @@ func EndSession(db *gorm.DB) gin.HandlerFunc {
 		result := db.Model(&store.Session{}).Where("id = ?", uint(id)).Update("status", "completed")
 		if result.Error != nil {
 			logger.LogError(logger.ServiceREST, "Failed to end session", result.Error)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to end session",
-				Details: result.Error.Error(),
-			})
+			apierror.Respond(c, "Failed to end session", result.Error)
 			return
 		}
 
 		if result.RowsAffected == 0 {
-			c.JSON(http.StatusNotFound, store.ErrorResponse{
-				Error: "Session not found",
-			})
+			apierror.NotFound(c, "Session not found")
 			return
 		}
 
@@ -191,3 +177,132 @@ func EndSession(db *gorm.DB) gin.HandlerFunc {
 		})
 	}
 }
+
+// GetActiveDataset returns the dataset selected for a session
+func GetActiveDataset(service *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid session ID", err)
+			return
+		}
+
+		fileID, err := service.ActiveDataset(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to get session dataset", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"session_id":     id,
+			"active_dataset": fileID,
+		})
+	}
+}
+
+// SetActiveDataset selects the uploaded file a session's chat questions are
+// answered from, for every connection using the session. An empty file_id
+// clears it.
+func SetActiveDataset(service *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid session ID", err)
+			return
+		}
+
+		var req store.SetActiveDatasetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		session, err := service.SetActiveDataset(uint(id), req.FileID)
+		if err != nil {
+			apierror.Respond(c, "Failed to set session dataset", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// ListDatasets lists the files loaded into a session's workspace and which
+// of them is active
+func ListDatasets(service *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid session ID", err)
+			return
+		}
+
+		active, err := service.ActiveDataset(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to get session datasets", err)
+			return
+		}
+		files, err := service.Datasets(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to get session datasets", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"session_id":     id,
+			"active_dataset": active,
+			"datasets":       files,
+		})
+	}
+}
+
+// AddDataset loads another uploaded file into a session's workspace so
+// questions can span it and the files already loaded
+func AddDataset(service *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid session ID", err)
+			return
+		}
+
+		var req store.AddSessionDatasetRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		files, err := service.AddDataset(uint(id), req.FileID)
+		if err != nil {
+			apierror.Respond(c, "Failed to add session dataset", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": id,
+			"datasets":   files,
+		})
+	}
+}
+
+// RemoveDataset unloads a file from a session's workspace
+func RemoveDataset(service *services.SessionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid session ID", err)
+			return
+		}
+
+		files, err := service.RemoveDataset(uint(id), c.Param("file_id"))
+		if err != nil {
+			apierror.Respond(c, "Failed to remove session dataset", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": id,
+			"datasets":   files,
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package sparse
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// runIncludes are the ReportRun keys only present when included
+var runIncludes = []string{"report", "report_version", "datasource", "columns"}
+
+// RunView reads ?fields= and ?include= as comma-separated lists. Without
+// ?include= the view includes defaults; an empty ?include= includes nothing.
+func RunView(c *gin.Context, defaults []string) store.RunView {
+	view := store.RunView{Fields: split(c.Query("fields")), Include: defaults}
+	if raw, ok := c.GetQuery("include"); ok {
+		view.Include = split(raw)
+	}
+	return view
+}
+
+// AllRunIncludes is the default view of endpoints that always returned every
+// relation
+func AllRunIncludes() []string {
+	return append([]string(nil), runIncludes...)
+}
+
+// Runs trims runs, a ReportRun or a slice of them, to view
+func Runs(runs interface{}, view store.RunView) (interface{}, error) {
+	raw, err := json.Marshal(runs)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(string(raw), "[") {
+		var items []map[string]interface{}
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			trim(item, view)
+		}
+		return items, nil
+	}
+	var item map[string]interface{}
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, err
+	}
+	trim(item, view)
+	return item, nil
+}
+
+// trim drops relations that are not included and, when fields are listed,
+// every other column that is not
+func trim(item map[string]interface{}, view store.RunView) {
+	keep := make(map[string]bool, len(view.Fields)+len(view.Include))
+	for _, name := range view.Include {
+		keep[name] = true
+	}
+	for _, name := range runIncludes {
+		if !keep[name] {
+			delete(item, name)
+		}
+	}
+	if len(view.Fields) == 0 {
+		return
+	}
+	for _, name := range view.Fields {
+		keep[name] = true
+	}
+	for name := range item {
+		if !keep[name] {
+			delete(item, name)
+		}
+	}
+}
+
+func split(raw string) []string {
+	var names []string
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
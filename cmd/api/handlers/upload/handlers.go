@@ -1,14 +1,19 @@
 package upload
 
 import (
+	"cmp"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/cmd/api/handlers/listing"
 	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/services"
 	"github.com/NubeDev/air/internal/store"
 	"github.com/gin-gonic/gin"
 )
@@ -48,10 +53,7 @@ func UploadFile() gin.HandlerFunc {
 		file, err := c.FormFile("file")
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to get file from form", err)
-			c.JSON(http.StatusBadRequest, store.ErrorResponse{
-				Error:   "No file provided",
-				Details: err.Error(),
-			})
+			apierror.BadRequest(c, "No file provided", err)
 			return
 		}
 
@@ -69,6 +71,7 @@ func UploadFile() gin.HandlerFunc {
 
 		if !contains(allowedTypes, fileExt) {
 			c.JSON(http.StatusBadRequest, store.ErrorResponse{
+				Code:    store.ErrCodeValidation,
 				Error:   "Unsupported file type",
 				Details: fmt.Sprintf("Supported types: %s", strings.Join(allowedTypes, ", ")),
 			})
@@ -79,10 +82,7 @@ func UploadFile() gin.HandlerFunc {
 		uploadDir := "uploads"
 		if err := os.MkdirAll(uploadDir, 0755); err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to create uploads directory", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to create upload directory",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to create upload directory", err)
 			return
 		}
 
@@ -94,10 +94,7 @@ func UploadFile() gin.HandlerFunc {
 		// Save file
 		if err := c.SaveUploadedFile(file, filePath); err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to save uploaded file", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to save file",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to save file", err)
 			return
 		}
 
@@ -105,10 +102,7 @@ func UploadFile() gin.HandlerFunc {
 		fileInfo, err := os.Stat(filePath)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to get file info", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get file info",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to get file info", err)
 			return
 		}
 
@@ -132,10 +126,15 @@ func UploadFile() gin.HandlerFunc {
 	}
 }
 
-// ListUploadedFiles lists all uploaded files
+// ListUploadedFiles lists uploaded files a page at a time, by name by default.
+// Sorts by name, upload_time or size and filters by file_type.
 func ListUploadedFiles() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uploadDir := "uploads"
+		opts, ok := listing.Options(c)
+		if !ok {
+			return
+		}
 
 		// Check if uploads directory exists
 		if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
@@ -150,10 +149,7 @@ func ListUploadedFiles() gin.HandlerFunc {
 		files, err := os.ReadDir(uploadDir)
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to read uploads directory", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to list files",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to list files", err)
 			return
 		}
 
@@ -177,19 +173,89 @@ func ListUploadedFiles() gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"files": fileList,
-			"count": len(fileList),
-		})
+		fileList, page, err := pageFiles(fileList, opts)
+		if err != nil {
+			apierror.Respond(c, "Failed to list files", err)
+			return
+		}
+
+		body := listing.Body("files", fileList, page)
+		body["count"] = len(fileList)
+		c.JSON(http.StatusOK, body)
 	}
 }
 
+// fileSorts orders uploaded files by each sortable field
+var fileSorts = map[string]func(a, b UploadedFile) int{
+	"name":        func(a, b UploadedFile) int { return strings.Compare(a.Filename, b.Filename) },
+	"upload_time": func(a, b UploadedFile) int { return strings.Compare(a.UploadTime, b.UploadTime) },
+	"size":        func(a, b UploadedFile) int { return cmp.Compare(a.FileSize, b.FileSize) },
+}
+
+// pageFiles sorts, filters and pages a directory listing the way
+// services.ListPage pages table rows, keyed on the file name
+func pageFiles(files []UploadedFile, opts store.ListOptions) ([]UploadedFile, *store.PageInfo, error) {
+	sort := opts.Sort
+	if sort == "" {
+		sort = "name"
+	}
+	compare, ok := fileSorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: cannot sort by %q", services.ErrValidation, strings.TrimPrefix(sort, "-"))
+	}
+	for name := range opts.Filters {
+		if name != "file_type" {
+			return nil, nil, fmt.Errorf("%w: cannot filter by %q", services.ErrValidation, name)
+		}
+	}
+
+	if fileType, ok := opts.Filters["file_type"]; ok {
+		files = slices.DeleteFunc(files, func(f UploadedFile) bool { return f.FileType != fileType })
+	}
+	slices.SortFunc(files, func(a, b UploadedFile) int {
+		order := compare(a, b)
+		if order == 0 {
+			order = strings.Compare(a.Filename, b.Filename)
+		}
+		if strings.HasPrefix(sort, "-") {
+			order = -order
+		}
+		return order
+	})
+
+	page := &store.PageInfo{}
+	if opts.IncludeTotal {
+		total := int64(len(files))
+		page.Total = &total
+	}
+
+	if opts.Cursor != "" {
+		after, err := services.DecodeCursor(opts.Cursor, sort)
+		if err != nil {
+			return nil, nil, err
+		}
+		index := slices.IndexFunc(files, func(f UploadedFile) bool { return f.Filename == after })
+		if index < 0 {
+			return nil, nil, fmt.Errorf("%w: cursor item no longer exists", services.ErrValidation)
+		}
+		files = files[index+1:]
+	}
+
+	limit := services.PageLimit(opts.Limit)
+	if len(files) > limit {
+		files = files[:limit]
+		page.NextCursor = services.EncodeCursor(sort, files[limit-1].Filename)
+	}
+	return files, page, nil
+}
+
 // GetUploadedFile gets details of a specific uploaded file
 func GetUploadedFile() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		fileID := c.Param("id")
 		if fileID == "" {
 			c.JSON(http.StatusBadRequest, store.ErrorResponse{
+				Code:    store.ErrCodeValidation,
 				Error:   "File ID required",
 				Details: "No file ID provided",
 			})
@@ -202,6 +268,7 @@ func GetUploadedFile() gin.HandlerFunc {
 		fileInfo, err := os.Stat(filePath)
 		if os.IsNotExist(err) {
 			c.JSON(http.StatusNotFound, store.ErrorResponse{
+				Code:    store.ErrCodeNotFound,
 				Error:   "File not found",
 				Details: fmt.Sprintf("File %s does not exist", fileID),
 			})
@@ -209,10 +276,7 @@ func GetUploadedFile() gin.HandlerFunc {
 		}
 		if err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to get file info", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to get file info",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to get file info", err)
 			return
 		}
 
@@ -235,6 +299,7 @@ func DeleteUploadedFile() gin.HandlerFunc {
 		fileID := c.Param("id")
 		if fileID == "" {
 			c.JSON(http.StatusBadRequest, store.ErrorResponse{
+				Code:    store.ErrCodeValidation,
 				Error:   "File ID required",
 				Details: "No file ID provided",
 			})
@@ -246,6 +311,7 @@ func DeleteUploadedFile() gin.HandlerFunc {
 		// Check if file exists
 		if _, err := os.Stat(filePath); os.IsNotExist(err) {
 			c.JSON(http.StatusNotFound, store.ErrorResponse{
+				Code:    store.ErrCodeNotFound,
 				Error:   "File not found",
 				Details: fmt.Sprintf("File %s does not exist", fileID),
 			})
@@ -255,10 +321,7 @@ func DeleteUploadedFile() gin.HandlerFunc {
 		// Delete file
 		if err := os.Remove(filePath); err != nil {
 			logger.LogError(logger.ServiceREST, "Failed to delete file", err)
-			c.JSON(http.StatusInternalServerError, store.ErrorResponse{
-				Error:   "Failed to delete file",
-				Details: err.Error(),
-			})
+			apierror.Respond(c, "Failed to delete file", err)
 			return
 		}
 
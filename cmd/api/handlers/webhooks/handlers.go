@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhook subscribes an endpoint to lifecycle events
+func CreateWebhook(service *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req store.CreateWebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.BadRequest(c, "Invalid request", err)
+			return
+		}
+
+		sub, err := service.CreateSubscription(req)
+		if err != nil {
+			apierror.BadRequest(c, "Failed to create webhook", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, sub)
+	}
+}
+
+// ListWebhooks returns all webhook subscriptions and the supported events
+func ListWebhooks(service *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subs, err := service.ListSubscriptions()
+		if err != nil {
+			apierror.Respond(c, "Failed to list webhooks", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"webhooks": subs,
+			"events":   services.WebhookEvents,
+		})
+	}
+}
+
+// DeleteWebhook removes a subscription and its delivery log
+func DeleteWebhook(service *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid webhook ID", nil)
+			return
+		}
+
+		if err := service.DeleteSubscription(uint(id)); err != nil {
+			apierror.Respond(c, "Failed to delete webhook", err)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ListDeliveries returns the delivery log for a subscription (?status=&limit=)
+func ListDeliveries(service *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid webhook ID", nil)
+			return
+		}
+		limit, _ := strconv.Atoi(c.Query("limit"))
+
+		deliveries, err := service.ListDeliveries(uint(id), c.Query("status"), limit)
+		if err != nil {
+			apierror.Respond(c, "Failed to list deliveries", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"deliveries": deliveries,
+			"count":      len(deliveries),
+		})
+	}
+}
+
+// Redeliver queues a past delivery to be sent again
+func Redeliver(service *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("delivery_id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid delivery ID", nil)
+			return
+		}
+
+		if err := service.Redeliver(uint(id)); err != nil {
+			apierror.Respond(c, "Failed to redeliver", err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"status": "pending"})
+	}
+}
+
+// PingWebhook sends a signed test event and returns the delivery result
+func PingWebhook(service *services.WebhookService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			apierror.BadRequest(c, "Invalid webhook ID", nil)
+			return
+		}
+
+		delivery, err := service.Ping(uint(id))
+		if err != nil {
+			apierror.Respond(c, "Failed to ping webhook", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, delivery)
+	}
+}
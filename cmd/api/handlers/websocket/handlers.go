@@ -6,9 +6,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/NubeDev/air/cmd/api/handlers/apierror"
 	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/llm"
 	"github.com/NubeDev/air/internal/logger"
 	"github.com/NubeDev/air/internal/redis"
 	"github.com/NubeDev/air/internal/services"
@@ -28,16 +31,21 @@ type Handler struct {
 func NewHandler(redisClient *redis.Client, wsConfig *config.WebSocketConfig, aiService *services.AIService) *Handler {
 	// Create WebSocket hub configuration
 	hubConfig := &ws.Config{
-		ReadBufferSize:    wsConfig.ReadBufferSize,
-		WriteBufferSize:   wsConfig.WriteBufferSize,
-		HandshakeTimeout:  wsConfig.HandshakeTimeout,
-		PingPeriod:        wsConfig.PingPeriod,
-		PongWait:          wsConfig.PongWait,
-		MaxMessageSize:    wsConfig.MaxMessageSize,
-		EnableCompression: wsConfig.EnableCompression,
+		ReadBufferSize:      wsConfig.ReadBufferSize,
+		WriteBufferSize:     wsConfig.WriteBufferSize,
+		HandshakeTimeout:    wsConfig.HandshakeTimeout,
+		PingPeriod:          wsConfig.PingPeriod,
+		PongWait:            wsConfig.PongWait,
+		MaxMessageSize:      wsConfig.MaxMessageSize,
+		EnableCompression:   wsConfig.EnableCompression,
+		IdleTimeout:         wsConfig.IdleTimeout,
+		AIMessagesPerMinute: wsConfig.AIMessagesPerMinute,
+	}
+	if aiService != nil {
+		hubConfig.DefaultModel, hubConfig.ModelAliases, hubConfig.AllowedModels = AIModels(wsConfig, aiService.Config)
 	}
 
-	hub := ws.NewHub(redisClient, hubConfig, aiService)
+	hub := ws.NewHub(hubTransport(wsConfig.Transport, redisClient), hubConfig, aiService)
 
 	return &Handler{
 		hub:    hub,
@@ -46,6 +54,62 @@ func NewHandler(redisClient *redis.Client, wsConfig *config.WebSocketConfig, aiS
 	}
 }
 
+// SetFileAnalyses saves file analyses completed over the WebSocket
+func (h *Handler) SetFileAnalyses(recorder ws.FileAnalysisRecorder) {
+	h.hub.FileAnalyses = recorder
+}
+
+// SetSessions keeps the active dataset of clients connecting with
+// ?session_id= on that session
+func (h *Handler) SetSessions(sessions ws.SessionStore) {
+	h.hub.Sessions = sessions
+}
+
+// SetPrompts offers and runs saved prompts in chat
+func (h *Handler) SetPrompts(prompts ws.PromptLibrary) {
+	h.hub.Prompts = prompts
+}
+
+// hubTransport picks the hub transport for websocket.transport. Redis is
+// used when asked for or, with "auto", when it is available; a single node
+// without Redis keeps working on the in-memory transport.
+func hubTransport(mode string, redisClient *redis.Client) ws.Transport {
+	switch {
+	case mode == "memory":
+		return ws.NewMemoryTransport()
+	case redisClient != nil:
+		return ws.NewRedisTransport(redisClient)
+	case mode == "redis":
+		logger.LogWarn(logger.ServiceWS, "websocket.transport is redis but Redis is unavailable; using the in-memory transport")
+	}
+	return ws.NewMemoryTransport()
+}
+
+// AIModels returns the default model, provider aliases and allow-list for
+// WebSocket AI messages. Without websocket.allowed_models only the configured
+// models are allowed, and OpenAI only when it is usable.
+func AIModels(wsConfig *config.WebSocketConfig, cfg *config.Config) (string, map[string]string, []string) {
+	models := cfg.Models
+	aliases := map[string]string{
+		"llama":    models.Ollama.Llama3Model,
+		"sqlcoder": models.Ollama.SQLCoderModel,
+	}
+	allowed := wsConfig.AllowedModels
+	if len(allowed) == 0 {
+		allowed = []string{models.Ollama.Llama3Model, models.Ollama.SQLCoderModel}
+	}
+
+	openAIUsable := models.OpenAI.APIKey != "" && !cfg.Privacy.LocalOnly && !llm.IsLocalOnly()
+	if openAIUsable {
+		aliases["openai"] = models.OpenAI.Model
+		if len(wsConfig.AllowedModels) == 0 {
+			allowed = append(allowed, models.OpenAI.Model)
+		}
+	}
+
+	return llm.GetModelName(cfg, "chat"), aliases, allowed
+}
+
 // Upgrader handles WebSocket upgrades
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
@@ -60,6 +124,7 @@ var upgrader = websocket.Upgrader{
 	},
 	EnableCompression: true,
 	HandshakeTimeout:  10 * time.Second,
+	Subprotocols:      ws.Subprotocols(),
 }
 
 // HandleWebSocket handles WebSocket connections
@@ -78,6 +143,15 @@ func (h *Handler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
+	protocol, ok := negotiateProtocol(c)
+	if !ok {
+		return
+	}
+	sessionID, ok := h.sessionID(c)
+	if !ok {
+		return
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -102,12 +176,14 @@ func (h *Handler) HandleWebSocket(c *gin.Context) {
 
 	// Create client
 	client := &ws.Client{
-		ID:       clientID,
-		UserID:   userID,
-		Conn:     conn,
-		Send:     make(chan []byte, 256),
-		Hub:      h.hub,
-		Channels: make(map[string]bool),
+		ID:        clientID,
+		UserID:    userID,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		Hub:       h.hub,
+		Channels:  make(map[string]bool),
+		Protocol:  protocol,
+		SessionID: sessionID,
 	}
 
 	// Register client with hub
@@ -129,6 +205,15 @@ func (h *Handler) HandleChat(c *gin.Context) {
 		return
 	}
 
+	protocol, ok := negotiateProtocol(c)
+	if !ok {
+		return
+	}
+	sessionID, ok := h.sessionID(c)
+	if !ok {
+		return
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -150,12 +235,14 @@ func (h *Handler) HandleChat(c *gin.Context) {
 
 	// Create client
 	client := &ws.Client{
-		ID:       clientID,
-		UserID:   userID,
-		Conn:     conn,
-		Send:     make(chan []byte, 256),
-		Hub:      h.hub,
-		Channels: make(map[string]bool),
+		ID:        clientID,
+		UserID:    userID,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		Hub:       h.hub,
+		Channels:  make(map[string]bool),
+		Protocol:  protocol,
+		SessionID: sessionID,
 	}
 
 	// Register client with hub
@@ -181,6 +268,11 @@ func (h *Handler) HandlePresence(c *gin.Context) {
 		return
 	}
 
+	protocol, ok := negotiateProtocol(c)
+	if !ok {
+		return
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -208,6 +300,7 @@ func (h *Handler) HandlePresence(c *gin.Context) {
 		Send:     make(chan []byte, 256),
 		Hub:      h.hub,
 		Channels: make(map[string]bool),
+		Protocol: protocol,
 	}
 
 	// Register client with hub
@@ -237,7 +330,7 @@ func (h *Handler) HandlePresence(c *gin.Context) {
 func (h *Handler) GetOnlineUsers(c *gin.Context) {
 	if h.redis == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "Redis service is not available",
+			"error": "Presence is disabled because Redis is not available",
 		})
 		return
 	}
@@ -295,12 +388,11 @@ func (h *Handler) SendMessage(c *gin.Context) {
 			return
 		}
 	} else if req.Channel != "" {
-		// Send to channel via Redis
+		// Send to channel subscribers on every hub
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		redisChannel := fmt.Sprintf("websocket:%s", req.Channel)
-		if err := h.hub.PublishToRedis(ctx, redisChannel, message); err != nil {
+		if err := h.hub.Publish(ctx, req.Channel, message); err != nil {
 			logger.LogError(logger.ServiceWS, "Failed to send message to channel", err, map[string]interface{}{
 				"channel": req.Channel,
 			})
@@ -321,23 +413,67 @@ func (h *Handler) SendMessage(c *gin.Context) {
 	})
 }
 
-// GetHubStats returns WebSocket hub statistics
+// GetHubStats returns WebSocket hub statistics: channel subscriber counts,
+// per-user connection counts and per-client traffic and idle time
 func (h *Handler) GetHubStats(c *gin.Context) {
-	h.hub.Mu.RLock()
-	defer h.hub.Mu.RUnlock()
+	hubStats := h.hub.Stats()
 
-	stats := gin.H{
-		"total_clients":  len(h.hub.Clients),
-		"total_channels": len(h.hub.Channels),
-		"channels":       make(map[string]int),
+	h.hub.Mu.RLock()
+	channels := make(map[string]int, len(h.hub.Channels))
+	for channel, clients := range h.hub.Channels {
+		channels[channel] = len(clients)
 	}
+	h.hub.Mu.RUnlock()
 
-	// Count clients per channel
-	for channel, clients := range h.hub.Channels {
-		stats["channels"].(map[string]int)[channel] = len(clients)
+	c.JSON(http.StatusOK, gin.H{
+		"transport":          h.hub.Transport.Name(),
+		"protocol":           ws.ProtocolVersion,
+		"total_clients":      len(hubStats.Clients),
+		"total_users":        len(hubStats.UserConnections),
+		"total_channels":     len(channels),
+		"channels":           channels,
+		"user_connections":   hubStats.UserConnections,
+		"clients":            hubStats.Clients,
+		"idle_timeout":       hubStats.IdleTimeout,
+		"reaped_total":       hubStats.ReapedTotal,
+		"slow_dropped_total": hubStats.SlowDropped,
+	})
+}
+
+// negotiateProtocol picks the protocol version from ?protocol= or the
+// air.v<N> subprotocols, answering 400 before the upgrade when none of the
+// requested versions is supported
+func negotiateProtocol(c *gin.Context) (int, bool) {
+	version, err := ws.NegotiateProtocol(c.Query("protocol"), websocket.Subprotocols(c.Request))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":              err.Error(),
+			"supported_versions": ws.SupportedProtocolVersions,
+		})
+		return 0, false
 	}
+	return version, true
+}
 
-	c.JSON(http.StatusOK, stats)
+// sessionID reads the optional ?session_id= a client keeps its active dataset
+// on, answering 400 or 404 before the upgrade when it is unusable
+func (h *Handler) sessionID(c *gin.Context) (uint, bool) {
+	raw := c.Query("session_id")
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || id == 0 {
+		apierror.BadRequest(c, "Invalid session ID", nil)
+		return 0, false
+	}
+	if h.hub.Sessions != nil {
+		if _, err := h.hub.Sessions.ActiveDataset(uint(id)); err != nil {
+			apierror.Respond(c, "Failed to load session", err)
+			return 0, false
+		}
+	}
+	return uint(id), true
 }
 
 // generateClientID generates a unique client ID
@@ -351,3 +487,31 @@ func generateClientID() string {
 func (h *Handler) StartHub(ctx context.Context) {
 	go h.hub.Run(ctx)
 }
+
+// ForwardEvents publishes lifecycle events from this process, such as run
+// progress, to the hub channel "events:<event>" (for example
+// "events:report.run.completed"). Each replica forwards only its own events.
+func (h *Handler) ForwardEvents(ctx context.Context, stream *services.EventStream) {
+	events, cancel := stream.Subscribe(services.EventFilter{}, 0)
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case e := <-events:
+				message := ws.Message{
+					Type:      e.Event,
+					Channel:   "events:" + e.Event,
+					Payload:   e.Data,
+					Timestamp: e.At,
+				}
+				if err := h.hub.Publish(ctx, message.Channel, message); err != nil {
+					logger.LogError(logger.ServiceWS, "Failed to forward event", err, map[string]interface{}{
+						"event": e.Event,
+					})
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/NubeDev/air/cmd/api/handlers/fastapi"
 	"github.com/NubeDev/air/cmd/api/handlers/health"
 	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/backup"
 	"github.com/NubeDev/air/internal/config"
 	"github.com/NubeDev/air/internal/datasource"
 	"github.com/NubeDev/air/internal/redis"
@@ -14,16 +15,79 @@ import (
 	"gorm.io/gorm"
 )
 
+// Services are the services behind the routes that other transports, such
+// as gRPC, serve too
+type Services struct {
+	Datasources *services.DatasourceService
+	Reports     *services.ReportsService
+	AI          *services.AIService
+}
+
 // SetupRoutes configures all API routes
-func SetupRoutes(router *gin.Engine, cfg *config.Config, db *gorm.DB, registry *datasource.Registry, jwtManager *auth.JWTManager, redisClient *redis.Client) {
+func SetupRoutes(router *gin.Engine, cfg *config.Config, db *gorm.DB, registry *datasource.Registry, jwtManager *auth.JWTManager, redisClient *redis.Client) *Services {
 	// Initialize services
 	datasourceService := services.NewDatasourceService(registry, db)
 	aiService, err := services.NewAIService(registry, db, cfg, datasourceService)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize AI service: %v", err))
 	}
-	reportsService := services.NewReportsService(registry, db)
+	datasourceService.SetLocks(redisClient)
+	reportsService := services.NewReportsService(registry, db, cfg)
+	reportsService.SetLocks(redisClient)
+	reportsService.FailOrphanedRuns()
+	reportsService.StartSnapshotScheduler()
+	reportsService.BackfillLineage()
+	webhookService := services.NewWebhookService(db, cfg)
+	webhookService.Start()
+	reportsService.SetWebhooks(webhookService)
+	aiService.SetWebhooks(webhookService)
+	datasourceService.SetWebhooks(webhookService)
+	eventStream := services.NewEventStream()
+	webhookService.Subscribe(eventStream.HandleEvent)
+	reportsService.SetEvents(eventStream)
+	notificationService := services.NewNotificationService(db, cfg)
+	webhookService.Subscribe(notificationService.HandleEvent)
+	mqttPublisher, err := services.NewMQTTPublisher(db, reportsService, cfg)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize MQTT publisher: %v", err))
+	}
+	if mqttPublisher != nil {
+		webhookService.Subscribe(mqttPublisher.HandleEvent)
+	}
+	historyIngestService, err := services.NewHistoryIngestService(registry, db, cfg)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize history ingestion: %v", err))
+	}
+	historyIngestService.SetLocks(redisClient)
+	historyIngestService.Start()
+	if cfg.Webhooks.Enabled {
+		datasourceService.StartHealthMonitor(cfg.Webhooks.HealthInterval)
+	}
+	datasourceService.StartFreshnessMonitor(cfg.Freshness.PollInterval)
+	reportsService.StartParamEnumRefresh(cfg.ParamEnums.RefreshInterval)
+	retentionService := services.NewRetentionService(db, cfg)
+	retentionService.SetLocks(redisClient)
+	retentionService.Start()
+	backupScheduler := backup.NewScheduler(db, cfg.Backup, config.ConfigFile(), "uploads")
+	backupScheduler.SetLocks(redisClient)
+	backupScheduler.Start()
+	exampleService := services.NewExampleService(db, cfg)
+	aiService.SetExamples(exampleService)
+	feedbackService := services.NewFeedbackService(db)
+	feedbackService.SetExamples(exampleService)
+	graphqlService := services.NewGraphQLService(db)
+	fileAnalysisService := services.NewFileAnalysisService(db)
+	sessionService := services.NewSessionService(db)
+	preferenceService := services.NewPreferenceService(registry, db)
+	promptService := services.NewPromptService(db, aiService, sessionService)
+	shareService := services.NewShareService(db, reportsService, cfg)
 	healthService := services.NewHealthService(cfg, registry)
+	demoService := services.NewDemoService(db, datasourceService, reportsService)
+	modelService, err := services.NewModelService(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize model service: %v", err))
+	}
+	modelService.RunStartupTasks()
 	fastapiHandler := fastapi.NewFastAPIHandler("http://localhost:9001")
 
 	// Health check endpoint
@@ -33,30 +97,53 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, db *gorm.DB, registry *
 	v1 := router.Group("/v1")
 	{
 		// Authentication middleware
-		var authMiddleware gin.HandlerFunc
+		var authMiddleware, adminMiddleware, reviewerMiddleware gin.HandlerFunc
 		if cfg.Server.Auth.Enabled && jwtManager != nil {
 			authMiddleware = auth.AuthMiddleware(jwtManager, true)
+			adminMiddleware = auth.RequireAdmin(cfg.Server.Auth.Admins, true)
+			reviewerMiddleware = auth.RequireReviewer(cfg.Server.Auth.Reviewers, cfg.Server.Auth.Admins, true)
 		} else {
 			authMiddleware = func(c *gin.Context) { c.Next() }
+			adminMiddleware = auth.RequireAdmin(nil, false)
+			reviewerMiddleware = auth.RequireReviewer(nil, nil, false)
 		}
 
 		// Setup API groups
-		SetupDatasourceRoutes(v1, datasourceService, authMiddleware)
+		SetupDatasourceRoutes(v1, datasourceService, aiService, authMiddleware, adminMiddleware)
 		SetupLearnRoutes(v1, datasourceService, authMiddleware)
 		SetupSchemaRoutes(v1, datasourceService, authMiddleware)
+		SetupDemoRoutes(v1, demoService, authMiddleware, adminMiddleware)
+		SetupRetentionRoutes(v1, retentionService, authMiddleware, adminMiddleware)
 		SetupScopeRoutes(v1, reportsService, authMiddleware)
 		SetupIRRoutes(v1, aiService, authMiddleware)
 		SetupSQLRoutes(v1, aiService, authMiddleware)
-		SetupReportRoutes(v1, reportsService, authMiddleware)
+		SetupReportRoutes(v1, reportsService, authMiddleware, reviewerMiddleware)
+		SetupPackageRoutes(v1, reportsService, authMiddleware)
+		SetupLineageRoutes(v1, reportsService, authMiddleware)
+		SetupReviewRoutes(v1, reportsService, authMiddleware)
+		SetupRunSummaryRoutes(v1, reportsService, authMiddleware)
+		SetupShareRoutes(v1, shareService, authMiddleware)
+		SetupWebhookRoutes(v1, webhookService, authMiddleware, adminMiddleware)
+		SetupNotificationRoutes(v1, notificationService, authMiddleware, adminMiddleware)
+		SetupIngestRoutes(v1, historyIngestService, authMiddleware)
 		SetupAnalysisRoutes(v1, aiService, authMiddleware)
+		SetupReportRegenerationRoutes(v1, aiService, authMiddleware)
+		SetupFeedbackRoutes(v1, feedbackService, authMiddleware)
+		SetupFileAnalysisRoutes(v1, fileAnalysisService, authMiddleware)
+		SetupExampleRoutes(v1, exampleService, authMiddleware)
+		SetupAITraceRoutes(v1, aiService, authMiddleware, adminMiddleware)
 		SetupAIToolsRoutes(v1, aiService, authMiddleware)
 		SetupChatRoutes(v1, aiService, authMiddleware)
-		SetupSessionRoutes(v1, db, authMiddleware)
+		SetupSessionRoutes(v1, db, sessionService, authMiddleware)
+		SetupPreferenceRoutes(v1, preferenceService, authMiddleware)
+		SetupPromptRoutes(v1, promptService, authMiddleware)
 		SetupGeneratedReportRoutes(v1, db, authMiddleware)
-		SetupCSVRoutes(v1, registry, db, authMiddleware)
+		SetupCSVRoutes(v1, registry, authMiddleware)
+		SetupGraphQLRoutes(v1, graphqlService, authMiddleware)
+		SetupEventRoutes(v1, eventStream, aiService, authMiddleware)
 
 		// New AI model and datasource routes
-		SetupAIModelRoutes(v1, aiService)
+		SetupAIModelRoutes(v1, aiService, modelService, authMiddleware, adminMiddleware)
 		SetupDatasourceAPIRoutes(v1, datasourceService)
 		SetupChatAPIRoutes(v1, aiService, reportsService, datasourceService)
 		SetupUploadRoutes(v1)
@@ -72,6 +159,8 @@ func SetupRoutes(router *gin.Engine, cfg *config.Config, db *gorm.DB, registry *
 
 	// WebSocket routes
 	if cfg.Server.WSEnabled {
-		SetupWebSocketRoutes(router, redisClient, &cfg.WebSocket, aiService)
+		SetupWebSocketRoutes(router, redisClient, &cfg.WebSocket, aiService, eventStream, fileAnalysisService, sessionService, promptService)
 	}
+
+	return &Services{Datasources: datasourceService, Reports: reportsService, AI: aiService}
 }
@@ -34,6 +34,27 @@ func SetupAnalysisRoutes(rg *gin.RouterGroup, service *services.AIService, authM
 	}
 }
 
+// SetupReportRegenerationRoutes configures routes for regenerating stale reports
+func SetupReportRegenerationRoutes(rg *gin.RouterGroup, service *services.AIService, authMiddleware gin.HandlerFunc) {
+	reports := rg.Group("/reports")
+	reports.Use(authMiddleware)
+	{
+		reports.POST("/:id/regenerate", ai.RegenerateReport(service))
+	}
+}
+
+// SetupAITraceRoutes configures routes for inspecting and replaying model calls
+func SetupAITraceRoutes(rg *gin.RouterGroup, service *services.AIService, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	traces := rg.Group("/ai/traces")
+	traces.Use(authMiddleware)
+	{
+		traces.GET("", ai.ListAITraces(service))
+		traces.GET("/export", adminMiddleware, ai.ExportAITraces(service))
+		traces.GET("/:trace_id", ai.GetAITrace(service))
+		traces.POST("/:trace_id/replay", ai.ReplayAITrace(service))
+	}
+}
+
 // SetupAIToolsRoutes configures AI tools routes
 func SetupAIToolsRoutes(rg *gin.RouterGroup, service *services.AIService, authMiddleware gin.HandlerFunc) {
 	rg.GET("/ai/tools", ai.GetAITools(service))
@@ -4,12 +4,13 @@ import (
 	"context"
 	"time"
 
+	"github.com/NubeDev/air/cmd/api/handlers/ai"
 	"github.com/NubeDev/air/internal/llm"
 	"github.com/NubeDev/air/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupAIModelRoutes(router *gin.RouterGroup, aiService *services.AIService) {
+func SetupAIModelRoutes(router *gin.RouterGroup, aiService *services.AIService, modelService *services.ModelService, authMiddleware, adminMiddleware gin.HandlerFunc) {
 	aiGroup := router.Group("/ai")
 	{
 		aiGroup.GET("/models/status", func(c *gin.Context) {
@@ -17,7 +18,12 @@ func SetupAIModelRoutes(router *gin.RouterGroup, aiService *services.AIService)
 			status := make(map[string]interface{})
 
 			// Check OpenAI
-			if aiService.Config.Models.ChatPrimary == "openai" && aiService.Config.Models.OpenAI.APIKey != "" {
+			if llm.IsLocalOnly() {
+				status["openai"] = gin.H{
+					"connected": false,
+					"error":     "Disabled by local-only mode",
+				}
+			} else if aiService.Config.Models.ChatPrimary == "openai" && aiService.Config.Models.OpenAI.APIKey != "" {
 				// Try to create OpenAI client and check health
 				openaiClient, err := llm.NewOpenAIClient(aiService.Config.Models.OpenAI)
 				if err == nil {
@@ -60,9 +66,21 @@ func SetupAIModelRoutes(router *gin.RouterGroup, aiService *services.AIService)
 
 			// SQLCoder is typically the same as Ollama
 			status["sqlcoder"] = status["llama"]
+			status["pool"] = aiService.PoolStats()
 
 			c.JSON(200, status)
 		})
+
+		// Ollama model management; changing the installed models is admin only
+		modelsGroup := aiGroup.Group("/models")
+		modelsGroup.Use(authMiddleware)
+		{
+			modelsGroup.GET("", ai.ListModels(modelService))
+			modelsGroup.GET("/verify", ai.VerifyModels(modelService))
+			modelsGroup.POST("/pull", adminMiddleware, ai.PullModel(modelService))
+			modelsGroup.POST("/warmup", adminMiddleware, ai.WarmUpModels(modelService))
+			modelsGroup.DELETE("/:name", adminMiddleware, ai.DeleteModel(modelService))
+		}
 	}
 }
 
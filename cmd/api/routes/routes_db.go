@@ -7,14 +7,35 @@ import (
 )
 
 // SetupDatasourceRoutes configures datasource management routes
-func SetupDatasourceRoutes(rg *gin.RouterGroup, service *services.DatasourceService, authMiddleware gin.HandlerFunc) {
+func SetupDatasourceRoutes(rg *gin.RouterGroup, service *services.DatasourceService, aiService *services.AIService, authMiddleware, adminMiddleware gin.HandlerFunc) {
 	datasources := rg.Group("/datasources")
 	datasources.Use(authMiddleware)
 	{
 		datasources.GET("", db.GetDatasources(service))
-		datasources.POST("", db.CreateDatasource(service))
+		datasources.POST("", adminMiddleware, db.CreateDatasource(service))
+		datasources.POST("/apply", adminMiddleware, db.ApplyDatasources(service))
 		datasources.GET("/:id/health", db.GetDatasourceHealth(service))
-		datasources.DELETE("/:id", db.DeleteDatasource(service))
+		datasources.GET("/:id/stats", db.GetDatasourceStats(service))
+		datasources.POST("/:id/query-test", adminMiddleware, db.TestQuery(service))
+		datasources.POST("/:id/lake-tables", adminMiddleware, db.RegisterLakeTable(service))
+		datasources.DELETE("/:id", adminMiddleware, db.DeleteDatasource(service))
+		datasources.GET("/:id/annotations", db.ListColumnAnnotations(service))
+		datasources.PUT("/:id/annotations", db.UpsertColumnAnnotations(service))
+		datasources.DELETE("/:id/annotations/:annotation_id", db.DeleteColumnAnnotation(service))
+		datasources.GET("/:id/glossary", db.ListGlossary(service))
+		datasources.PUT("/:id/glossary", db.UpsertGlossary(service))
+		datasources.DELETE("/:id/glossary/:term_id", db.DeleteGlossaryTerm(service))
+		datasources.GET("/:id/dictionary", db.GetDataDictionary(service))
+		datasources.PUT("/:id/dictionary", db.UpdateDataDictionary(service))
+		datasources.POST("/:id/dictionary/generate", db.GenerateDataDictionary(aiService))
+		datasources.GET("/:id/dictionary/versions", db.ListDataDictionaryVersions(service))
+		datasources.GET("/:id/dictionary/versions/:version", db.GetDataDictionaryVersion(service))
+		datasources.GET("/:id/freshness", db.ListFreshnessMonitors(service))
+		datasources.POST("/:id/freshness", db.CreateFreshnessMonitor(service))
+		datasources.DELETE("/:id/freshness/:monitor_id", db.DeleteFreshnessMonitor(service))
+		datasources.GET("/:id/pii", db.ListPIIColumns(service))
+		datasources.PATCH("/:id/pii/:pii_id", adminMiddleware, db.UpdatePIIColumn(service))
+		datasources.DELETE("/:id/pii/:pii_id", adminMiddleware, db.DeletePIIColumn(service))
 	}
 }
 
@@ -33,5 +54,16 @@ func SetupSchemaRoutes(rg *gin.RouterGroup, service *services.DatasourceService,
 	schema.Use(authMiddleware)
 	{
 		schema.GET("/:datasource_id", db.GetSchema(service))
+		schema.PATCH("/:datasource_id/notes/:note_id", db.CurateSchemaNote(service))
+		schema.GET("/:datasource_id/notes/:note_id/history", db.GetSchemaNoteHistory(service))
+	}
+}
+
+// SetupDemoRoutes configures demo data routes
+func SetupDemoRoutes(rg *gin.RouterGroup, service *services.DemoService, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	demo := rg.Group("/demo")
+	demo.Use(authMiddleware, adminMiddleware)
+	{
+		demo.POST("/seed", db.SeedDemo(service))
 	}
 }
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/events"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupEventRoutes configures the Server-Sent Events fallback for clients
+// that cannot hold a WebSocket connection
+func SetupEventRoutes(rg *gin.RouterGroup, stream *services.EventStream, aiService *services.AIService, authMiddleware gin.HandlerFunc) {
+	group := rg.Group("/events")
+	group.Use(authMiddleware)
+	{
+		group.GET("", events.Stream(stream))
+		group.POST("/chat", events.Chat(aiService))
+	}
+}
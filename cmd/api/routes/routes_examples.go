@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/examples"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupExampleRoutes configures the per-datasource few-shot SQL example routes
+func SetupExampleRoutes(rg *gin.RouterGroup, service *services.ExampleService, authMiddleware gin.HandlerFunc) {
+	datasources := rg.Group("/datasources")
+	datasources.Use(authMiddleware)
+	{
+		datasources.GET("/:id/examples", examples.ListSQLExamples(service))
+		datasources.POST("/:id/examples", examples.CreateSQLExample(service))
+		datasources.DELETE("/:id/examples/:example_id", examples.DeleteSQLExample(service))
+	}
+}
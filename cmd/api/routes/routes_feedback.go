@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/feedback"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupFeedbackRoutes configures run feedback and feedback export routes
+func SetupFeedbackRoutes(rg *gin.RouterGroup, service *services.FeedbackService, authMiddleware gin.HandlerFunc) {
+	runs := rg.Group("/runs")
+	runs.Use(authMiddleware)
+	{
+		runs.GET("/:run_id/feedback", feedback.ListRunFeedback(service))
+		runs.POST("/:run_id/feedback", feedback.CreateRunFeedback(service))
+	}
+
+	feedbackGroup := rg.Group("/feedback")
+	feedbackGroup.Use(authMiddleware)
+	{
+		feedbackGroup.GET("/export", feedback.ExportFeedback(service))
+	}
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/file_analyses"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupFileAnalysisRoutes configures routes for saved WebSocket file analyses
+func SetupFileAnalysisRoutes(rg *gin.RouterGroup, service *services.FileAnalysisService, authMiddleware gin.HandlerFunc) {
+	analyses := rg.Group("/file-analyses")
+	analyses.Use(authMiddleware)
+	{
+		analyses.GET("", file_analyses.ListFileAnalyses(service))
+		analyses.GET("/:id", file_analyses.GetFileAnalysis(service))
+	}
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/graphql"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupGraphQLRoutes configures the read-only GraphQL endpoint
+func SetupGraphQLRoutes(rg *gin.RouterGroup, service *services.GraphQLService, authMiddleware gin.HandlerFunc) {
+	group := rg.Group("/graphql")
+	group.Use(authMiddleware)
+	{
+		group.GET("", graphql.Query(service))
+		group.POST("", graphql.Query(service))
+	}
+}
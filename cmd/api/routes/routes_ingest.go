@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/ingest"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupIngestRoutes configures history ingestion routes
+func SetupIngestRoutes(rg *gin.RouterGroup, service *services.HistoryIngestService, authMiddleware gin.HandlerFunc) {
+	ingestGroup := rg.Group("/ingest")
+	ingestGroup.Use(authMiddleware)
+	{
+		ingestGroup.GET("/histories", ingest.ListHistorySources(service))
+		ingestGroup.POST("/histories/:name/run", ingest.RunHistorySource(service))
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/notifications"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupNotificationRoutes configures per-report Slack/Teams notifier routes.
+// Admin only: the server posts to the webhook URLs they register.
+func SetupNotificationRoutes(rg *gin.RouterGroup, service *services.NotificationService, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	reportsGroup := rg.Group("/reports")
+	reportsGroup.Use(authMiddleware, adminMiddleware)
+	{
+		reportsGroup.GET("/:id/notifications", notifications.ListReportNotifications(service))
+		reportsGroup.POST("/:id/notifications", notifications.CreateReportNotification(service))
+		reportsGroup.DELETE("/:id/notifications/:notification_id", notifications.DeleteReportNotification(service))
+	}
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/preferences"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPreferenceRoutes configures the caller's preference routes
+func SetupPreferenceRoutes(rg *gin.RouterGroup, service *services.PreferenceService, authMiddleware gin.HandlerFunc) {
+	me := rg.Group("/me")
+	me.Use(authMiddleware)
+	{
+		me.GET("/preferences", preferences.GetPreferences(service))
+		me.PUT("/preferences", preferences.UpdatePreferences(service))
+	}
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/prompts"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupPromptRoutes configures the saved prompts library routes
+func SetupPromptRoutes(rg *gin.RouterGroup, service *services.PromptService, authMiddleware gin.HandlerFunc) {
+	promptGroup := rg.Group("/prompts")
+	promptGroup.Use(authMiddleware)
+	{
+		promptGroup.GET("", prompts.ListPrompts(service))
+		promptGroup.POST("", prompts.CreatePrompt(service))
+		promptGroup.GET("/:id", prompts.GetPrompt(service))
+		promptGroup.PUT("/:id", prompts.UpdatePrompt(service))
+		promptGroup.DELETE("/:id", prompts.DeletePrompt(service))
+		promptGroup.POST("/:id/run", prompts.RunPrompt(service))
+	}
+}
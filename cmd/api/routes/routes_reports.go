@@ -14,22 +14,55 @@ func SetupScopeRoutes(rg *gin.RouterGroup, service *services.ReportsService, aut
 		scopes.POST("", reports.CreateScope(service))
 		scopes.GET("/:id", reports.GetScope(service))
 		scopes.POST("/:id/version", reports.CreateScopeVersion(service))
+		scopes.GET("/:id/versions", reports.ListScopeVersions(service))
+		scopes.GET("/:id/versions/diff", reports.DiffScopeVersions(service))
 	}
 }
 
 // SetupReportRoutes configures report management routes
-func SetupReportRoutes(rg *gin.RouterGroup, service *services.ReportsService, authMiddleware gin.HandlerFunc) {
+func SetupReportRoutes(rg *gin.RouterGroup, service *services.ReportsService, authMiddleware, reviewerMiddleware gin.HandlerFunc) {
 	reportsGroup := rg.Group("/reports")
 	reportsGroup.Use(authMiddleware)
 	{
 		// ID-based endpoints
 		reportsGroup.GET("", reports.ListReports(service))
 		reportsGroup.POST("", reports.CreateReport(service))
+		reportsGroup.POST("/sql", reports.CreateSQLReport(service))
+		reportsGroup.GET("/recent", reports.ListRecentReports(service))
+
+		// Bulk operations
+		reportsGroup.POST("/batch/run", reports.BatchRunReports(service))
+		reportsGroup.GET("/batch/:batch_id", reports.GetBatch(service))
+		reportsGroup.GET("/runs/:run_id", reports.GetReportRun(service))
+		reportsGroup.GET("/runs/:run_id/assertions", reports.ListRunAssertionResults(service))
+		reportsGroup.POST("/batch/archive", reports.BatchArchiveReports(service))
+		reportsGroup.POST("/batch/export", reports.BatchExportReports(service))
 		reportsGroup.GET("/:id", reports.GetReportByID(service))
 		reportsGroup.GET("/:id/data", reports.GetReportData(service))
 		reportsGroup.GET("/:id/schema", reports.GetReportSchema(service))
+		reportsGroup.POST("/:id/params/options", reports.GetParamOptions(service))
+		reportsGroup.PUT("/:id/materialize", reports.MaterializeReport(service))
+		reportsGroup.GET("/:id/materialize", reports.GetMaterialization(service))
+		reportsGroup.DELETE("/:id/materialize", reports.DematerializeReport(service))
+		reportsGroup.POST("/:id/materialize/refresh", reports.RefreshSnapshot(service))
 		reportsGroup.POST("/:id/versions", reports.CreateReportVersionByID(service))
+		reportsGroup.POST("/:id/revalidate", reports.RevalidateReport(service))
+		reportsGroup.POST("/:id/validate", reports.ValidateReport(service))
+		reportsGroup.GET("/:id/validations", reports.ListReportValidations(service))
+		reportsGroup.POST("/:id/assertions", reports.CreateReportAssertion(service))
+		reportsGroup.GET("/:id/assertions", reports.ListReportAssertions(service))
+		reportsGroup.DELETE("/:id/assertions/:assertion_id", reports.DeleteReportAssertion(service))
+		reportsGroup.POST("/:id/versions/:version/approve", reviewerMiddleware, reports.ApproveReportVersion(service))
+		reportsGroup.POST("/:id/versions/:version/reject", reviewerMiddleware, reports.RejectReportVersion(service))
 		reportsGroup.POST("/:id/execute", reports.ExecuteReportByID(service))
+		reportsGroup.POST("/:id/fanout", reports.RunReportFanOut(service))
+		reportsGroup.POST("/:id/cost-estimate", reports.EstimateReportCost(service))
+		reportsGroup.GET("/:id/runs", reports.ListReportRuns(service))
+		reportsGroup.PUT("/:id/favorite", reports.FavoriteReport(service))
+		reportsGroup.DELETE("/:id/favorite", reports.UnfavoriteReport(service))
+		reportsGroup.GET("/:id/summary-templates", reports.ListSummaryTemplates(service))
+		reportsGroup.PUT("/:id/summary-templates/:format", reports.SetSummaryTemplate(service))
+		reportsGroup.DELETE("/:id/summary-templates/:format", reports.DeleteSummaryTemplate(service))
 		reportsGroup.DELETE("/:id", reports.DeleteReportByID(service))
 
 		// Legacy key-based (compat)
@@ -39,3 +72,40 @@ func SetupReportRoutes(rg *gin.RouterGroup, service *services.ReportsService, au
 		reportsGroup.GET("/key/:key/export", reports.ExportReport(service))
 	}
 }
+
+// SetupPackageRoutes configures .airpkg report package routes
+func SetupPackageRoutes(rg *gin.RouterGroup, service *services.ReportsService, authMiddleware gin.HandlerFunc) {
+	packages := rg.Group("/packages")
+	packages.Use(authMiddleware)
+	{
+		packages.POST("/export", reports.ExportPackage(service))
+		packages.POST("/import", reports.ImportPackage(service))
+	}
+}
+
+// SetupLineageRoutes configures report lineage routes
+func SetupLineageRoutes(rg *gin.RouterGroup, service *services.ReportsService, authMiddleware gin.HandlerFunc) {
+	lineage := rg.Group("/lineage")
+	lineage.Use(authMiddleware)
+	{
+		lineage.GET("", reports.GetLineage(service))
+	}
+}
+
+// SetupReviewRoutes configures the queue of report versions awaiting review
+func SetupReviewRoutes(rg *gin.RouterGroup, service *services.ReportsService, authMiddleware gin.HandlerFunc) {
+	reviews := rg.Group("/reviews")
+	reviews.Use(authMiddleware)
+	{
+		reviews.GET("", reports.ListPendingReviews(service))
+	}
+}
+
+// SetupRunSummaryRoutes configures rendered run digests
+func SetupRunSummaryRoutes(rg *gin.RouterGroup, service *services.ReportsService, authMiddleware gin.HandlerFunc) {
+	runs := rg.Group("/runs")
+	runs.Use(authMiddleware)
+	{
+		runs.GET("/:run_id/summary", reports.GetRunSummary(service))
+	}
+}
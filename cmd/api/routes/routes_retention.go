@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/retention"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRetentionRoutes configures retention policy and cleanup routes
+func SetupRetentionRoutes(rg *gin.RouterGroup, service *services.RetentionService, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	retentionGroup := rg.Group("/retention")
+	retentionGroup.Use(authMiddleware, adminMiddleware)
+	{
+		retentionGroup.GET("", retention.GetRetention(service))
+		retentionGroup.POST("/run", retention.RunRetention(service))
+	}
+}
@@ -2,12 +2,13 @@ package routes
 
 import (
 	"github.com/NubeDev/air/cmd/api/handlers/sessions"
+	"github.com/NubeDev/air/internal/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // SetupSessionRoutes configures session management routes
-func SetupSessionRoutes(rg *gin.RouterGroup, db *gorm.DB, authMiddleware gin.HandlerFunc) {
+func SetupSessionRoutes(rg *gin.RouterGroup, db *gorm.DB, sessionService *services.SessionService, authMiddleware gin.HandlerFunc) {
 	sessionGroup := rg.Group("/sessions")
 	sessionGroup.Use(authMiddleware)
 	{
@@ -16,5 +17,10 @@ func SetupSessionRoutes(rg *gin.RouterGroup, db *gorm.DB, authMiddleware gin.Han
 		sessionGroup.GET("/:id", sessions.GetSession(db))
 		sessionGroup.GET("/:id/status", sessions.GetSessionStatus(db))
 		sessionGroup.DELETE("/:id", sessions.EndSession(db))
+		sessionGroup.GET("/:id/dataset", sessions.GetActiveDataset(sessionService))
+		sessionGroup.PUT("/:id/dataset", sessions.SetActiveDataset(sessionService))
+		sessionGroup.GET("/:id/datasets", sessions.ListDatasets(sessionService))
+		sessionGroup.POST("/:id/datasets", sessions.AddDataset(sessionService))
+		sessionGroup.DELETE("/:id/datasets/:file_id", sessions.RemoveDataset(sessionService))
 	}
 }
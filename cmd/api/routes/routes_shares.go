@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/reports"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupShareRoutes configures report share links. Links are managed by
+// authenticated users; /shared is public, the token being the credential.
+func SetupShareRoutes(rg *gin.RouterGroup, service *services.ShareService, authMiddleware gin.HandlerFunc) {
+	shares := rg.Group("/reports/:id/shares")
+	shares.Use(authMiddleware)
+	{
+		shares.POST("", reports.CreateShareLink(service))
+		shares.GET("", reports.ListShareLinks(service))
+		shares.DELETE("/:share_id", reports.RevokeShareLink(service))
+		shares.GET("/:share_id/accesses", reports.ListShareAccesses(service))
+	}
+
+	shared := rg.Group("/shared")
+	{
+		shared.GET("/:token", reports.GetSharedLink(service))
+		shared.GET("/:token/data", reports.GetSharedData(service))
+		shared.GET("/:token/summary", reports.GetSharedSummary(service))
+	}
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/NubeDev/air/cmd/api/handlers/webhooks"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupWebhookRoutes configures webhook subscription and delivery log routes.
+// Admin only: subscriptions receive every event and the server posts to them.
+func SetupWebhookRoutes(rg *gin.RouterGroup, service *services.WebhookService, authMiddleware, adminMiddleware gin.HandlerFunc) {
+	webhookGroup := rg.Group("/webhooks")
+	webhookGroup.Use(authMiddleware, adminMiddleware)
+	{
+		webhookGroup.POST("", webhooks.CreateWebhook(service))
+		webhookGroup.GET("", webhooks.ListWebhooks(service))
+		webhookGroup.DELETE("/:id", webhooks.DeleteWebhook(service))
+		webhookGroup.GET("/:id/deliveries", webhooks.ListDeliveries(service))
+		webhookGroup.POST("/:id/ping", webhooks.PingWebhook(service))
+		webhookGroup.POST("/deliveries/:delivery_id/redeliver", webhooks.Redeliver(service))
+	}
+}
@@ -12,7 +12,7 @@ import (
 )
 
 // SetupWebSocketRoutes sets up WebSocket routes
-func SetupWebSocketRoutes(router *gin.Engine, redisClient *redis.Client, wsConfig *config.WebSocketConfig, aiService interface{}) {
+func SetupWebSocketRoutes(router *gin.Engine, redisClient *redis.Client, wsConfig *config.WebSocketConfig, aiService interface{}, eventStream *services.EventStream, fileAnalyses *services.FileAnalysisService, sessions *services.SessionService, prompts *services.PromptService) {
 	if !wsConfig.Enabled {
 		logger.LogWarn(logger.ServiceWS, "WebSocket routes disabled")
 		return
@@ -25,10 +25,14 @@ func SetupWebSocketRoutes(router *gin.Engine, redisClient *redis.Client, wsConfi
 		return
 	}
 	wsHandler := websocket.NewHandler(redisClient, wsConfig, aiServiceTyped)
+	wsHandler.SetFileAnalyses(fileAnalyses)
+	wsHandler.SetSessions(sessions)
+	wsHandler.SetPrompts(prompts)
 
 	// Start WebSocket hub
 	ctx := context.Background()
 	wsHandler.StartHub(ctx)
+	wsHandler.ForwardEvents(ctx, eventStream)
 
 	// WebSocket group
 	wsGroup := router.Group("/v1/ws")
@@ -1,19 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
 	"time"
 
+	"github.com/NubeDev/air/cmd/api/grpcapi"
+	"github.com/NubeDev/air/cmd/api/handlers/websocket"
 	"github.com/NubeDev/air/cmd/api/middleware"
 	"github.com/NubeDev/air/cmd/api/routes"
 	"github.com/NubeDev/air/internal/auth"
 	"github.com/NubeDev/air/internal/config"
 	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/llm"
 	"github.com/NubeDev/air/internal/logger"
 	"github.com/NubeDev/air/internal/redis"
+	"github.com/NubeDev/air/internal/services"
 	"github.com/NubeDev/air/internal/store"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -26,8 +33,14 @@ type Server struct {
 	jwtMgr   *auth.JWTManager
 	redis    *redis.Client
 	router   *gin.Engine
+	grpc     *grpc.Server // nil unless server.grpc_port is set
+	reports  *services.ReportsService
 }
 
+// runShutdownGrace is how long Close waits for report runs executing in the
+// background before cancelling them
+const runShutdownGrace = 10 * time.Second
+
 // NewServer creates a new server instance
 func NewServer(cfg *config.Config) (*Server, error) {
 	// Setup logging
@@ -41,6 +54,9 @@ func NewServer(cfg *config.Config) (*Server, error) {
 
 	logger.LogInfo(logger.ServiceServer, "Initializing AIR server")
 
+	// Apply egress policy before any model client is created
+	llm.SetLocalOnly(cfg.Privacy.LocalOnly)
+
 	// Initialize database
 	logger.LogInfo(logger.ServiceDB, "Initializing database connection")
 
@@ -81,22 +97,34 @@ func NewServer(cfg *config.Config) (*Server, error) {
 
 	redisClient, err := redis.NewClient(&cfg.Redis)
 	if err != nil {
-		logger.LogError(logger.ServiceRedis, "Failed to initialize Redis client", err)
-		return nil, fmt.Errorf("failed to initialize Redis client: %w", err)
+		if cfg.Redis.Required {
+			logger.LogError(logger.ServiceRedis, "Failed to initialize Redis client", err)
+			return nil, fmt.Errorf("failed to initialize Redis client: %w", err)
+		}
+		logger.LogWarn(logger.ServiceRedis, "Redis is unreachable; continuing without it", map[string]interface{}{
+			"error": err.Error(),
+		})
 	}
 
 	if redisClient != nil {
 		logger.LogInfo(logger.ServiceRedis, "Redis client initialized successfully")
 	} else {
-		logger.LogWarn(logger.ServiceRedis, "Redis client is disabled")
+		logRedisFallbacks()
 	}
 
 	// Setup router
 	logger.LogInfo(logger.ServiceREST, "Setting up HTTP router")
 
-	router := setupRouter(cfg, db, registry, jwtManager, redisClient)
+	router, svc := setupRouter(cfg, db, registry, jwtManager, redisClient)
 	logger.LogInfo(logger.ServiceREST, "HTTP router setup complete")
 
+	var grpcServer *grpc.Server
+	if cfg.Server.GRPCPort != 0 {
+		_, aliases, allowed := websocket.AIModels(&cfg.WebSocket, cfg)
+		grpcServer = grpcapi.NewServer(svc, jwtManager, cfg.Server.Auth.Admins, grpcapi.ChatModels{Aliases: aliases, Allowed: allowed})
+		logger.LogInfo(logger.ServiceGRPC, "gRPC server setup complete")
+	}
+
 	logger.LogInfo(logger.ServiceServer, "AIR server initialization complete")
 	return &Server{
 		config:   cfg,
@@ -105,9 +133,22 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		jwtMgr:   jwtManager,
 		redis:    redisClient,
 		router:   router,
+		grpc:     grpcServer,
+		reports:  svc.Reports,
 	}, nil
 }
 
+// logRedisFallbacks warns once at startup about each feature that runs
+// degraded without Redis, so it is not discovered through runtime errors
+func logRedisFallbacks() {
+	logger.LogWarn(logger.ServiceRedis, "Running without Redis", map[string]interface{}{
+		"websocket_channels": "memory transport; clients on other replicas do not receive them",
+		"presence":           "disabled; /v1/websocket/users returns 503",
+		"caching":            "skipped",
+		"job_locks":          "local to this replica; run a single replica to avoid duplicate scheduled jobs",
+	})
+}
+
 // Start starts the server
 func (s *Server) Start() error {
 	addr := s.config.GetServerAddr()
@@ -116,8 +157,25 @@ func (s *Server) Start() error {
 		"address":           addr,
 		"auth_enabled":      s.config.Server.Auth.Enabled,
 		"websocket_enabled": s.config.Server.WSEnabled,
+		"local_only":        s.config.Privacy.LocalOnly,
 	})
 
+	if s.grpc != nil {
+		grpcAddr := s.config.GetGRPCAddr()
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for gRPC on %s: %w", grpcAddr, err)
+		}
+		logger.LogInfo(logger.ServiceGRPC, "Starting gRPC server", map[string]interface{}{
+			"address": grpcAddr,
+		})
+		go func() {
+			if err := s.grpc.Serve(listener); err != nil {
+				logger.LogError(logger.ServiceGRPC, "gRPC server stopped", err)
+			}
+		}()
+	}
+
 	return s.router.Run(addr)
 }
 
@@ -125,6 +183,16 @@ func (s *Server) Start() error {
 func (s *Server) Close() error {
 	var err error
 
+	// Finish in-flight gRPC calls
+	if s.grpc != nil {
+		s.grpc.GracefulStop()
+	}
+
+	// Let background report runs finish, or record them as failed
+	ctx, cancel := context.WithTimeout(context.Background(), runShutdownGrace)
+	s.reports.Shutdown(ctx)
+	cancel()
+
 	// Close Redis connection
 	if s.redis != nil {
 		if redisErr := s.redis.Close(); redisErr != nil {
@@ -172,7 +240,7 @@ func initDatabase(cfg *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
-func setupRouter(cfg *config.Config, db *gorm.DB, registry *datasource.Registry, jwtManager *auth.JWTManager, redisClient *redis.Client) *gin.Engine {
+func setupRouter(cfg *config.Config, db *gorm.DB, registry *datasource.Registry, jwtManager *auth.JWTManager, redisClient *redis.Client) (*gin.Engine, *routes.Services) {
 	// Set Gin mode
 	if os.Getenv("GIN_MODE") == "" {
 		gin.SetMode(gin.ReleaseMode)
@@ -189,7 +257,7 @@ func setupRouter(cfg *config.Config, db *gorm.DB, registry *datasource.Registry,
 	setupMiddleware(router)
 
 	// Setup routes
-	routes.SetupRoutes(router, cfg, db, registry, jwtManager, redisClient)
+	svc := routes.SetupRoutes(router, cfg, db, registry, jwtManager, redisClient)
 
-	return router
+	return router, svc
 }
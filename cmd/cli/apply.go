@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// datasourceSpec is one datasource of an apply file
+type datasourceSpec struct {
+	ID          string `yaml:"id" json:"id"`
+	Kind        string `yaml:"kind" json:"kind"`
+	DSN         string `yaml:"dsn" json:"dsn"`
+	DisplayName string `yaml:"display_name" json:"display_name"`
+	IsDefault   bool   `yaml:"is_default" json:"is_default"`
+}
+
+func applyDatasourcesCmd() *cobra.Command {
+	var file string
+	var dryRun, prune bool
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Make datasources match a YAML file",
+		Long: `Create and update datasources to match a YAML file, and with --prune remove the ones it does not list.
+$VAR and ${VAR} in the file are replaced from the environment, so DSN secrets can stay out of it:
+
+  datasources:
+    - id: ts-dev
+      kind: timescaledb
+      dsn: ${TS_DEV_DSN}
+      display_name: TimescaleDB (dev)
+      is_default: true
+
+Exits non-zero when any change fails.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := readInput(file)
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", file, err)
+			}
+
+			var spec struct {
+				Datasources []datasourceSpec `yaml:"datasources"`
+			}
+			if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &spec); err != nil {
+				log.Fatalf("Invalid %s: %v", file, err)
+			}
+
+			reqBody, _ := json.Marshal(map[string]interface{}{
+				"datasources": spec.Datasources,
+				"prune":       prune,
+				"dry_run":     dryRun,
+			})
+			body, err := doAPIRequest(http.MethodPost, "/v1/datasources/apply", "application/json", bytes.NewReader(reqBody))
+			if err != nil {
+				log.Fatalf("Apply failed: %v", err)
+			}
+
+			var result struct {
+				Changes []struct {
+					ID     string   `json:"id"`
+					Action string   `json:"action"`
+					Fields []string `json:"fields"`
+					Error  string   `json:"error"`
+				} `json:"changes"`
+				Failed int `json:"failed"`
+			}
+			if err := json.Unmarshal(body, &result); err != nil {
+				log.Fatalf("Failed to parse response: %v", err)
+			}
+
+			if dryRun {
+				fmt.Println("Dry run, nothing was changed:")
+			}
+			symbols := map[string]string{"create": "+", "update": "~", "delete": "-", "unchanged": "="}
+			for _, change := range result.Changes {
+				line := fmt.Sprintf("  %s %s", symbols[change.Action], change.ID)
+				if len(change.Fields) > 0 {
+					line += " (" + strings.Join(change.Fields, ", ") + ")"
+				}
+				if change.Error != "" {
+					line += "  ❌ " + change.Error
+				}
+				fmt.Println(line)
+			}
+			if result.Failed > 0 {
+				fmt.Fprintf(os.Stderr, "%d change(s) failed\n", result.Failed)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "YAML file of datasources, or - for stdin")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes without making them")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Remove datasources the file does not list")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
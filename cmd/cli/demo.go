@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func demoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "demo",
+		Short: "Manage demo data",
+	}
+	cmd.AddCommand(demoSeedCmd())
+	return cmd
+}
+
+func demoSeedCmd() *cobra.Command {
+	var reset bool
+
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Create the demo analytics datasource",
+		Long: `Create a SQLite datasource "demo" holding a year of sample sales orders and 90 days of hourly energy readings, learn it, and add a glossary and pre-built scopes and reports.
+The sales tables share the eval suite's schema. Dates run up to yesterday, so "last 30 days" questions find data.
+--reset rebuilds an existing demo and adds new scope and report versions. Requires an admin.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			reqBody, _ := json.Marshal(map[string]bool{"reset": reset})
+			body, err := doAPIRequest(http.MethodPost, "/v1/demo/seed", "application/json", bytes.NewReader(reqBody))
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict && !reset {
+				log.Fatalf("Demo seed failed: %v\nRun with --reset to rebuild it", err)
+			}
+			if err != nil {
+				log.Fatalf("Demo seed failed: %v", err)
+			}
+
+			var result struct {
+				DatasourceID string         `json:"datasource_id"`
+				DSN          string         `json:"dsn"`
+				Rows         map[string]int `json:"rows"`
+				Reports      []string       `json:"reports"`
+			}
+			if err := json.Unmarshal(body, &result); err != nil {
+				log.Fatalf("Failed to parse response: %v", err)
+			}
+
+			fmt.Printf("Seeded datasource %s (%s)\n", result.DatasourceID, result.DSN)
+			tables := make([]string, 0, len(result.Rows))
+			for table := range result.Rows {
+				tables = append(tables, table)
+			}
+			sort.Strings(tables)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TABLE\tROWS")
+			for _, table := range tables {
+				fmt.Fprintf(w, "%s\t%d\n", table, result.Rows[table])
+			}
+			w.Flush()
+
+			fmt.Println("Reports:")
+			for _, key := range result.Reports {
+				fmt.Printf("  %s\n", key)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&reset, "reset", false, "Rebuild the demo if it already exists")
+
+	return cmd
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of "datasource health", for deployment pipelines
+const (
+	exitHealthy   = 0
+	exitUnhealthy = 1 // a datasource failed its check
+	exitNoCheck   = 2 // the check itself could not be made
+)
+
+// healthResult is one row of the health table
+type healthResult struct {
+	ID        string
+	Status    string
+	LatencyMS int64
+	Error     string
+}
+
+func healthCheckCmd() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "health [id|--all]",
+		Short: "Check datasource health",
+		Long: `Check the connection of a datasource, the profile's default when none is given, or of every datasource with --all.
+Exits 0 when all are healthy, 1 when any is unhealthy and 2 when a check could not be made, so it can gate deployment pipelines.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeDatasourceIDs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var ids []string
+			switch {
+			case all && len(args) > 0:
+				log.Fatalf("Pass a datasource ID or --all, not both")
+			case all:
+				var err error
+				if ids, err = listDatasourceIDs(); err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to list datasources: %v\n", err)
+					os.Exit(exitNoCheck)
+				}
+			case len(args) == 1:
+				ids = []string{args[0]}
+			default:
+				ids = []string{datasourceOrDefault("")}
+			}
+			if len(ids) == 0 {
+				fmt.Println("No datasources registered")
+				return
+			}
+
+			code := exitHealthy
+			results := make([]healthResult, 0, len(ids))
+			for _, id := range ids {
+				result, err := checkDatasourceHealth(id)
+				if err != nil {
+					result = healthResult{ID: id, Status: "error", Error: err.Error()}
+					code = max(code, exitNoCheck)
+				} else if result.Status != "healthy" {
+					code = max(code, exitUnhealthy)
+				}
+				results = append(results, result)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "DATASOURCE\tSTATUS\tLATENCY\tERROR")
+			for _, result := range results {
+				status, latency := "❌ "+result.Status, "-"
+				if result.Status == "healthy" {
+					status = "✅ healthy"
+				}
+				if result.Status != "error" {
+					latency = fmt.Sprintf("%dms", result.LatencyMS)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.ID, status, latency, result.Error)
+			}
+			w.Flush()
+			os.Exit(code)
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Check every registered datasource")
+
+	return cmd
+}
+
+// checkDatasourceHealth has the server test a datasource's connection
+func checkDatasourceHealth(id string) (healthResult, error) {
+	body, err := doAPIRequest(http.MethodGet, "/v1/datasources/"+url.PathEscape(id)+"/health", "", nil)
+	if err != nil {
+		return healthResult{}, err
+	}
+
+	var resp struct {
+		Status    string `json:"status"`
+		LatencyMS int64  `json:"latency_ms"`
+		Error     string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return healthResult{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return healthResult{ID: id, Status: resp.Status, LatencyMS: resp.LatencyMS, Error: resp.Error}, nil
+}
+
+// listDatasourceIDs returns the IDs of every registered datasource
+func listDatasourceIDs() ([]string, error) {
+	body, err := doAPIRequest(http.MethodGet, "/v1/datasources", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Datasources []struct {
+			ID string `json:"id"`
+		} `json:"datasources"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	ids := make([]string, 0, len(resp.Datasources))
+	for _, ds := range resp.Datasources {
+		ids = append(ids, ds.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	colorReset   = "\033[0m"
+	colorKey     = "\033[34m" // blue
+	colorString  = "\033[32m" // green
+	colorNumber  = "\033[33m" // yellow
+	colorKeyword = "\033[35m" // magenta
+	colorComment = "\033[90m" // grey
+)
+
+// sqlKeywords are the words highlightSQL colors
+var sqlKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"GROUP": true, "BY": true, "ORDER": true, "HAVING": true, "LIMIT": true, "OFFSET": true,
+	"JOIN": true, "LEFT": true, "RIGHT": true, "INNER": true, "OUTER": true, "FULL": true,
+	"CROSS": true, "ON": true, "AS": true, "WITH": true, "DISTINCT": true, "UNION": true,
+	"ALL": true, "IN": true, "IS": true, "NULL": true, "LIKE": true, "BETWEEN": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true, "ASC": true,
+	"DESC": true, "INTERVAL": true, "OVER": true, "PARTITION": true, "TRUE": true, "FALSE": true,
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true, "COALESCE": true, "CAST": true,
+}
+
+// useColor reports whether output to stdout should be highlighted: not when
+// --no-color or NO_COLOR is set, or when stdout is not a terminal
+func useColor() bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// highlightJSON colors keys, strings, numbers and literals of indented JSON
+func highlightJSON(text string) string {
+	if !useColor() {
+		return text
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == '"':
+			end := i + 1
+			for end < len(text) && text[end] != '"' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(text))
+			color := colorString
+			if rest := strings.TrimLeft(text[end:], " "); strings.HasPrefix(rest, ":") {
+				color = colorKey
+			}
+			b.WriteString(color + text[i:end] + colorReset)
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(text) && strings.IndexByte("0123456789.eE+-", text[end]) >= 0 {
+				end++
+			}
+			b.WriteString(colorNumber + text[i:end] + colorReset)
+			i = end
+		case strings.HasPrefix(text[i:], "true"), strings.HasPrefix(text[i:], "null"):
+			b.WriteString(colorKeyword + text[i:i+4] + colorReset)
+			i += 4
+		case strings.HasPrefix(text[i:], "false"):
+			b.WriteString(colorKeyword + text[i:i+5] + colorReset)
+			i += 5
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// highlightSQL colors keywords, string literals, numbers and comments
+func highlightSQL(text string) string {
+	if !useColor() {
+		return text
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case strings.HasPrefix(text[i:], "--"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			b.WriteString(colorComment + text[i:i+end] + colorReset)
+			i += end
+		case c == '\'':
+			end := i + 1
+			for end < len(text) {
+				if text[end] == '\'' {
+					if end+1 < len(text) && text[end+1] == '\'' {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			end = min(end+1, len(text))
+			b.WriteString(colorString + text[i:end] + colorReset)
+			i = end
+		case c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(text) && (text[end] == '.' || (text[end] >= '0' && text[end] <= '9')) {
+				end++
+			}
+			b.WriteString(colorNumber + text[i:end] + colorReset)
+			i = end
+		case c == '_' || unicode.IsLetter(rune(c)):
+			end := i + 1
+			for end < len(text) && (text[end] == '_' || unicode.IsLetter(rune(text[end])) || unicode.IsDigit(rune(text[end]))) {
+				end++
+			}
+			word := text[i:end]
+			if sqlKeywords[strings.ToUpper(word)] {
+				word = colorKeyword + word + colorReset
+			}
+			b.WriteString(word)
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// schemaNote is the part of a learned schema note the CLI reads
+type schemaNote struct {
+	ID     uint   `json:"id"`
+	Object string `json:"object"`
+	MD     string `json:"md"`
+	MDHash string `json:"md_hash"`
+}
+
+// schemaColumn is one row of the column table in a schema note
+type schemaColumn struct {
+	Name     string
+	Type     string
+	Nullable string
+}
+
+func learnCmd() *cobra.Command {
+	var schemas, tables []string
+	var diff bool
+	var format string
+	var wait time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "learn [datasource_id]",
+		Short: "Learn database schema",
+		Long: `Introspect a datasource, the profile's default when none is given, and learn its schema structure, then show the tables and columns found.
+--tables filters what is shown and takes glob patterns; --diff shows what changed since the last learn.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeDatasourceIDs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var datasourceID string
+			if len(args) == 1 {
+				datasourceID = args[0]
+			}
+			datasourceID = datasourceOrDefault(datasourceID)
+			if format != "tree" && format != "table" {
+				log.Fatalf("Invalid --format %q: use tree or table", format)
+			}
+
+			before, err := fetchSchemaNotes(datasourceID)
+			if err != nil {
+				log.Fatalf("Failed to get schema: %v", err)
+			}
+
+			fmt.Printf("Learning datasource %s...\n", datasourceID)
+			if err := triggerLearn(datasourceID, schemas, wait); err != nil {
+				log.Fatalf("Learn failed: %v", err)
+			}
+
+			after, err := fetchSchemaNotes(datasourceID)
+			if err != nil {
+				log.Fatalf("Failed to get schema: %v", err)
+			}
+
+			// Learning appends notes, so the ones past the previous newest are this learn's
+			var lastID uint
+			for _, note := range before {
+				lastID = max(lastID, note.ID)
+			}
+			var learned []schemaNote
+			for _, note := range latestNotes(after) {
+				if note.ID > lastID {
+					learned = append(learned, note)
+				}
+			}
+
+			shown := filterNotes(learned, tables)
+			fmt.Printf("Learned %d object(s) from %s", len(learned), datasourceID)
+			if len(tables) > 0 {
+				fmt.Printf(", showing %d matching %s", len(shown), strings.Join(tables, ", "))
+			}
+			fmt.Println()
+
+			if diff {
+				// Objects outside a --schemas filter were not looked at, so they are not removed
+				printSchemaDiff(filterNotes(latestNotes(before), tables), shown, len(schemas) == 0)
+				return
+			}
+			if format == "table" {
+				printSchemaTable(shown)
+			} else {
+				printSchemaTree(shown)
+			}
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&schemas, "schemas", nil, "Database schemas to learn (default: the datasource's default schema)")
+	cmd.Flags().StringSliceVar(&tables, "tables", nil, "Only show tables matching these glob patterns")
+	cmd.Flags().BoolVar(&diff, "diff", false, "Show tables and columns added, removed or changed since the last learn")
+	cmd.Flags().StringVar(&format, "format", "tree", "Output format: tree or table")
+	cmd.Flags().DurationVar(&wait, "wait", 10*time.Minute, "How long to wait for a learn already running on the server")
+
+	return cmd
+}
+
+// triggerLearn learns a datasource. The server learns in the request; when a
+// learn of the datasource is already running it answers 409, and this waits
+// for that learn to finish instead.
+func triggerLearn(datasourceID string, schemas []string, wait time.Duration) error {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"datasource_id": datasourceID,
+		"schemas":       schemas,
+	})
+
+	since, err := lastLearnAt(datasourceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = doAPIRequest(http.MethodPost, "/v1/learn", "application/json", bytes.NewReader(reqBody))
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		return err
+	}
+
+	fmt.Println("A learn of this datasource is already running, waiting for it to finish...")
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		time.Sleep(2 * time.Second)
+		latest, err := lastLearnAt(datasourceID)
+		if err != nil {
+			return err
+		}
+		if latest.After(since) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the running learn did not finish within %s", wait)
+}
+
+// lastLearnAt reads when the datasource was last learned; zero if never
+func lastLearnAt(datasourceID string) (time.Time, error) {
+	body, err := doAPIRequest(http.MethodGet, "/v1/datasources/"+url.PathEscape(datasourceID)+"/stats", "", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var stats struct {
+		LastLearnAt *time.Time `json:"last_learn_at"`
+	}
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse stats: %w", err)
+	}
+	if stats.LastLearnAt == nil {
+		return time.Time{}, nil
+	}
+	return *stats.LastLearnAt, nil
+}
+
+// fetchSchemaNotes reads every schema note of a datasource, page by page
+func fetchSchemaNotes(datasourceID string) ([]schemaNote, error) {
+	var notes []schemaNote
+	cursor := ""
+	for {
+		query := url.Values{}
+		query.Set("limit", "500")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		body, err := doAPIRequest(http.MethodGet, "/v1/schema/"+url.PathEscape(datasourceID)+"?"+query.Encode(), "", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			SchemaNotes []schemaNote `json:"schema_notes"`
+			NextCursor  string       `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse schema notes: %w", err)
+		}
+		notes = append(notes, page.SchemaNotes...)
+		if page.NextCursor == "" {
+			return notes, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// latestNotes keeps the newest note of each object, ordered by object
+func latestNotes(notes []schemaNote) []schemaNote {
+	latest := make(map[string]schemaNote)
+	for _, note := range notes {
+		if prev, ok := latest[note.Object]; !ok || note.ID > prev.ID {
+			latest[note.Object] = note
+		}
+	}
+
+	result := make([]schemaNote, 0, len(latest))
+	for _, note := range latest {
+		result = append(result, note)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Object < result[j].Object })
+	return result
+}
+
+// filterNotes keeps the notes whose object matches one of the glob
+// patterns; no patterns keeps every note
+func filterNotes(notes []schemaNote, patterns []string) []schemaNote {
+	if len(patterns) == 0 {
+		return notes
+	}
+
+	var result []schemaNote
+	for _, note := range notes {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, note.Object); ok {
+				result = append(result, note)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// noteColumns reads the column table of a schema note: the first Markdown
+// table whose header starts with "Column"
+func noteColumns(md string) []schemaColumn {
+	var columns []schemaColumn
+	inTable := false
+	for _, line := range strings.Split(md, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			if inTable {
+				break
+			}
+			continue
+		}
+
+		cells := strings.Split(strings.Trim(line, "|"), "|")
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+		}
+		switch {
+		case !inTable:
+			inTable = strings.EqualFold(cells[0], "Column")
+		case strings.HasPrefix(cells[0], "---"):
+		case len(cells) >= 2:
+			column := schemaColumn{Name: cells[0], Type: cells[1]}
+			if len(cells) >= 3 && (cells[2] == "Yes" || cells[2] == "No") {
+				column.Nullable = cells[2]
+			}
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// printSchemaTree prints each object with its columns as a tree
+func printSchemaTree(notes []schemaNote) {
+	for i, note := range notes {
+		branch, indent := "├── ", "│   "
+		if i == len(notes)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		columns := noteColumns(note.MD)
+		fmt.Printf("%s%s (%d columns)\n", branch, note.Object, len(columns))
+		for j, column := range columns {
+			leaf := "├── "
+			if j == len(columns)-1 {
+				leaf = "└── "
+			}
+			nullable := ""
+			if column.Nullable == "Yes" {
+				nullable = ", nullable"
+			}
+			fmt.Printf("%s%s%s (%s%s)\n", indent, leaf, column.Name, column.Type, nullable)
+		}
+	}
+}
+
+// printSchemaTable prints one row per column
+func printSchemaTable(notes []schemaNote) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tCOLUMN\tTYPE\tNULLABLE")
+	for _, note := range notes {
+		for _, column := range noteColumns(note.MD) {
+			nullable := column.Nullable
+			if nullable == "" {
+				nullable = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", note.Object, column.Name, column.Type, nullable)
+		}
+	}
+	w.Flush()
+}
+
+// printSchemaDiff prints the objects and columns added, removed or changed
+// between the previous learn and this one
+func printSchemaDiff(before, after []schemaNote, reportRemoved bool) {
+	previous := make(map[string]schemaNote, len(before))
+	for _, note := range before {
+		previous[note.Object] = note
+	}
+	current := make(map[string]bool, len(after))
+
+	changes := 0
+	for _, note := range after {
+		current[note.Object] = true
+		prev, ok := previous[note.Object]
+		switch {
+		case !ok:
+			fmt.Printf("  + %s (%d columns)\n", note.Object, len(noteColumns(note.MD)))
+			changes++
+		case prev.MDHash != note.MDHash:
+			fmt.Printf("  ~ %s\n", note.Object)
+			for _, line := range columnChanges(noteColumns(prev.MD), noteColumns(note.MD)) {
+				fmt.Printf("      %s\n", line)
+			}
+			changes++
+		}
+	}
+	if reportRemoved {
+		for _, note := range before {
+			if !current[note.Object] {
+				fmt.Printf("  - %s\n", note.Object)
+				changes++
+			}
+		}
+	}
+
+	if changes == 0 {
+		fmt.Println("No schema changes since the last learn")
+	}
+}
+
+// columnChanges lists the columns added, removed or retyped between two
+// versions of a table
+func columnChanges(before, after []schemaColumn) []string {
+	previous := make(map[string]schemaColumn, len(before))
+	for _, column := range before {
+		previous[column.Name] = column
+	}
+	current := make(map[string]bool, len(after))
+
+	var lines []string
+	for _, column := range after {
+		current[column.Name] = true
+		prev, ok := previous[column.Name]
+		switch {
+		case !ok:
+			lines = append(lines, fmt.Sprintf("+ %s (%s)", column.Name, column.Type))
+		case prev.Type != column.Type:
+			lines = append(lines, fmt.Sprintf("~ %s: %s → %s", column.Name, prev.Type, column.Type))
+		case prev.Nullable != column.Nullable:
+			lines = append(lines, fmt.Sprintf("~ %s: nullable %s → %s", column.Name, prev.Nullable, column.Nullable))
+		}
+	}
+	for _, column := range before {
+		if !current[column.Name] {
+			lines = append(lines, fmt.Sprintf("- %s (%s)", column.Name, column.Type))
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "description changed")
+	}
+	return lines
+}
@@ -2,31 +2,42 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"time"
 
 	apiclient "github.com/NubeDev/air/clients/go"
 	"github.com/spf13/cobra"
 )
 
-var (
-	serverURL    = flag.String("server", "http://localhost:9000", "AIR server URL")
-	authToken    = flag.String("token", "", "JWT authentication token")
-	authDisabled = flag.Bool("auth", false, "Disable authentication")
-)
-
 func main() {
 	var rootCmd = &cobra.Command{
 		Use:   "aircli",
 		Short: "AIR CLI - AI Reporter command line interface",
 		Long:  `AIR CLI provides command-line access to the AIR (AI Reporter) system for managing datasources, reports, and analytics.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyProfile(cmd)
+		},
 	}
 
-	// Global flags
-	rootCmd.PersistentFlags().StringVar(serverURL, "server", "http://localhost:9000", "AIR server URL")
-	rootCmd.PersistentFlags().StringVar(authToken, "token", "", "JWT authentication token")
-	rootCmd.PersistentFlags().BoolVar(authDisabled, "auth", false, "Disable authentication")
+	// Global flags; --server and --token override AIR_SERVER and AIR_TOKEN,
+	// which override the selected profile
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "Connection profile from ~/.air/config (default: AIR_PROFILE or the current profile)")
+	rootCmd.PersistentFlags().StringVar(&serverURL, "server", defaultServerURL, "AIR server URL (env AIR_SERVER)")
+	rootCmd.PersistentFlags().StringVar(&authToken, "token", "", "JWT authentication token (env AIR_TOKEN)")
+	rootCmd.PersistentFlags().BoolVar(&authDisabled, "auth", false, "Send no token, for servers with authentication disabled")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable syntax highlighting")
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfileNames)
+
+	// Profile commands
+	rootCmd.AddCommand(profileCmd())
+
+	// Preference commands
+	rootCmd.AddCommand(prefsCmd())
+
+	// Saved prompt commands
+	rootCmd.AddCommand(promptCmd())
 
 	// Datasource commands
 	datasourceCmd := &cobra.Command{
@@ -37,11 +48,17 @@ func main() {
 	datasourceCmd.AddCommand(createDatasourceCmd())
 	datasourceCmd.AddCommand(listDatasourcesCmd())
 	datasourceCmd.AddCommand(healthCheckCmd())
+	datasourceCmd.AddCommand(applyDatasourcesCmd())
 	rootCmd.AddCommand(datasourceCmd)
 
 	// Learn commands
 	rootCmd.AddCommand(learnCmd())
 
+	// Authoring workflow commands
+	rootCmd.AddCommand(scopeCmd())
+	rootCmd.AddCommand(irCmd())
+	rootCmd.AddCommand(sqlCmd())
+
 	// Report commands
 	reportCmd := &cobra.Command{
 		Use:   "report",
@@ -53,6 +70,12 @@ func main() {
 	reportCmd.AddCommand(runReportCmd())
 	rootCmd.AddCommand(reportCmd)
 
+	// Package commands
+	rootCmd.AddCommand(pkgCmd())
+
+	// Demo data
+	rootCmd.AddCommand(demoCmd())
+
 	// Generic HTTP commands
 	rootCmd.AddCommand(createGenericCmd())
 
@@ -79,7 +102,10 @@ func listDatasourcesCmd() *cobra.Command {
 		Long:  `List all registered analytics datasources with their health status.`,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Create API client
-			client, err := apiclient.NewClientWithResponses(*serverURL)
+			client, err := apiclient.NewClientWithResponses(serverURL, apiclient.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+				authorize(req)
+				return nil
+			}))
 			if err != nil {
 				log.Fatalf("Failed to create API client: %v", err)
 			}
@@ -116,35 +142,21 @@ func listDatasourcesCmd() *cobra.Command {
 				if ds.Error != nil && *ds.Error != "" {
 					fmt.Printf("    Error: %s\n", *ds.Error)
 				}
+				if ds.Circuit != nil && *ds.Circuit != apiclient.Closed {
+					fmt.Printf("    Circuit: %s", *ds.Circuit)
+					if ds.Failures != nil {
+						fmt.Printf(" after %d failed checks", *ds.Failures)
+					}
+					if ds.RetryAt != nil {
+						fmt.Printf(", retrying after %s", ds.RetryAt.Local().Format(time.RFC3339))
+					}
+					fmt.Println()
+				}
 			}
 		},
 	}
 }
 
-func healthCheckCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "health [id]",
-		Short: "Check datasource health",
-		Long:  `Check the health status of a specific datasource.`,
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("Health check for datasource %s not implemented yet\n", args[0])
-		},
-	}
-}
-
-func learnCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "learn [datasource_id]",
-		Short: "Learn database schema",
-		Long:  `Introspect a datasource and learn its schema structure.`,
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("Learning from datasource %s not implemented yet\n", args[0])
-		},
-	}
-}
-
 func createReportCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "create",
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func pkgCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pkg",
+		Short: "Manage report packages",
+		Long:  `Export and install .airpkg report packages to share report libraries between AIR instances.`,
+	}
+	cmd.AddCommand(pkgInstallCmd())
+	cmd.AddCommand(pkgExportCmd())
+	return cmd
+}
+
+func pkgInstallCmd() *cobra.Command {
+	var datasourceID string
+	var overwrite bool
+
+	cmd := &cobra.Command{
+		Use:   "install [file.airpkg]",
+		Short: "Install a report package",
+		Long:  `Install a .airpkg report package, binding its reports to a datasource on the target server.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				log.Fatalf("Failed to read package: %v", err)
+			}
+
+			query := url.Values{}
+			query.Set("datasource_id", datasourceOrDefault(datasourceID))
+			if overwrite {
+				query.Set("overwrite", "true")
+			}
+
+			body, err := doAPIRequest(http.MethodPost, "/v1/packages/import?"+query.Encode(), "application/zip", bytes.NewReader(data))
+			if err != nil {
+				log.Fatalf("Package install failed: %v", err)
+			}
+
+			var result struct {
+				Package   string   `json:"package"`
+				Installed []string `json:"installed"`
+				Skipped   []string `json:"skipped"`
+				Warnings  []string `json:"warnings"`
+			}
+			if err := json.Unmarshal(body, &result); err != nil {
+				log.Fatalf("Failed to parse response: %v", err)
+			}
+
+			fmt.Printf("Installed package %s: %d report(s)\n", result.Package, len(result.Installed))
+			for _, key := range result.Installed {
+				fmt.Printf("  ✅ %s\n", key)
+			}
+			for _, key := range result.Skipped {
+				fmt.Printf("  ⏭️  %s (already exists, use --overwrite to add new versions)\n", key)
+			}
+			for _, warning := range result.Warnings {
+				fmt.Printf("  ⚠️  %s\n", warning)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&datasourceID, "datasource", "", "Target datasource ID (default: the profile's)")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Add packaged versions to reports that already exist")
+	cmd.RegisterFlagCompletionFunc("datasource", completeDatasourceIDs)
+
+	return cmd
+}
+
+func pkgExportCmd() *cobra.Command {
+	var keys []string
+	var folder, tag, description, output string
+
+	cmd := &cobra.Command{
+		Use:   "export [name]",
+		Short: "Export reports as a package",
+		Long:  `Export reports selected by key, folder or tag as a .airpkg report package.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			reqBody, _ := json.Marshal(map[string]interface{}{
+				"name":        args[0],
+				"description": description,
+				"keys":        keys,
+				"folder":      folder,
+				"tag":         tag,
+			})
+
+			body, err := doAPIRequest(http.MethodPost, "/v1/packages/export", "application/json", bytes.NewReader(reqBody))
+			if err != nil {
+				log.Fatalf("Package export failed: %v", err)
+			}
+
+			if output == "" {
+				output = args[0] + ".airpkg"
+			}
+			if err := os.WriteFile(output, body, 0644); err != nil {
+				log.Fatalf("Failed to write package: %v", err)
+			}
+			fmt.Printf("Exported package to %s (%d bytes)\n", output, len(body))
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&keys, "key", nil, "Report key to include (repeatable)")
+	cmd.Flags().StringVar(&folder, "folder", "", "Export every report in this folder")
+	cmd.Flags().StringVar(&tag, "tag", "", "Export every report with this tag")
+	cmd.Flags().StringVar(&description, "description", "", "Package description")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default <name>.airpkg)")
+
+	return cmd
+}
+
+// doAPIRequest sends a request to the AIR server and returns the body of a 2xx response
+func doAPIRequest(method, path, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimSuffix(serverURL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	authorize(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: respBody}
+	}
+	return respBody, nil
+}
+
+// authorize attaches the token as a bearer Authorization header, unless
+// authentication is disabled with --auth
+func authorize(req *http.Request) {
+	if authToken != "" && !authDisabled {
+		req.Header.Set("Authorization", "Bearer "+authToken)
+	}
+}
+
+// apiError is a non-2xx response from the AIR server
+type apiError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
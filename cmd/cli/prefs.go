@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// userPreferences is the caller's preferences as the server stores them
+type userPreferences struct {
+	Username          string          `json:"username"`
+	DefaultDatasource string          `json:"default_datasource,omitempty"`
+	ChatModel         string          `json:"chat_model,omitempty"`
+	Timezone          string          `json:"timezone,omitempty"`
+	DefaultRowLimit   int             `json:"default_row_limit,omitempty"`
+	UILayout          json.RawMessage `json:"ui_layout,omitempty"`
+}
+
+// fetchPreferences reads the caller's preferences from the server
+func fetchPreferences() (*userPreferences, error) {
+	body, err := doAPIRequest(http.MethodGet, "/v1/me/preferences", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var prefs userPreferences
+	if err := json.Unmarshal(body, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to parse preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+func prefsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prefs",
+		Short: "Manage your preferences",
+		Long: `Show or change your preferences kept on the server. Chat, report runs and this CLI
+use them when a request does not say otherwise; a profile's datasource still wins over the server's default.`,
+	}
+	cmd.AddCommand(prefsGetCmd())
+	cmd.AddCommand(prefsSetCmd())
+	return cmd
+}
+
+func prefsGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get",
+		Short: "Show your preferences",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			prefs, err := fetchPreferences()
+			if err != nil {
+				log.Fatalf("Failed to get preferences: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "user\t%s\n", prefs.Username)
+			fmt.Fprintf(w, "datasource\t%s\n", prefs.DefaultDatasource)
+			fmt.Fprintf(w, "chat model\t%s\n", prefs.ChatModel)
+			fmt.Fprintf(w, "timezone\t%s\n", prefs.Timezone)
+			fmt.Fprintf(w, "row limit\t%d\n", prefs.DefaultRowLimit)
+			fmt.Fprintf(w, "ui layout\t%s\n", prefs.UILayout)
+			w.Flush()
+		},
+	}
+}
+
+func prefsSetCmd() *cobra.Command {
+	var datasource, chatModel, timezone, layoutFile string
+	var rowLimit int
+
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Change your preferences",
+		Long:  `Change the preferences given as flags; pass an empty value to clear one. --ui-layout reads a JSON file, or stdin with -.`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			req := map[string]interface{}{}
+			flags := cmd.Flags()
+			if flags.Changed("datasource") {
+				req["default_datasource"] = datasource
+			}
+			if flags.Changed("chat-model") {
+				req["chat_model"] = chatModel
+			}
+			if flags.Changed("timezone") {
+				req["timezone"] = timezone
+			}
+			if flags.Changed("row-limit") {
+				req["default_row_limit"] = rowLimit
+			}
+			if flags.Changed("ui-layout") {
+				layout, err := readInput(layoutFile)
+				if err != nil {
+					log.Fatalf("Failed to read UI layout: %v", err)
+				}
+				if !json.Valid(layout) {
+					log.Fatalf("UI layout is not valid JSON")
+				}
+				req["ui_layout"] = json.RawMessage(layout)
+			}
+			if len(req) == 0 {
+				log.Fatalf("Nothing to change; see \"aircli prefs set --help\"")
+			}
+
+			reqBody, _ := json.Marshal(req)
+			if _, err := doAPIRequest(http.MethodPut, "/v1/me/preferences", "application/json", bytes.NewReader(reqBody)); err != nil {
+				log.Fatalf("Failed to update preferences: %v", err)
+			}
+			fmt.Println("Preferences updated")
+		},
+	}
+
+	cmd.Flags().StringVar(&datasource, "datasource", "", "Default datasource ID")
+	cmd.Flags().StringVar(&chatModel, "chat-model", "", "Chat model, e.g. gpt-4o-mini or llama3:latest")
+	cmd.Flags().StringVar(&timezone, "timezone", "", "IANA timezone for reports that set none")
+	cmd.Flags().IntVar(&rowLimit, "row-limit", 0, "Most rows a report run stores; 0 uses the server limit")
+	cmd.Flags().StringVar(&layoutFile, "ui-layout", "", "UI layout JSON file, or - for stdin")
+	cmd.RegisterFlagCompletionFunc("datasource", completeDatasourceIDs)
+
+	return cmd
+}
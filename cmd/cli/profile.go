@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultServerURL = "http://localhost:9000"
+
+// profile is a named AIR connection
+type profile struct {
+	Server     string `yaml:"server,omitempty"`
+	Token      string `yaml:"token,omitempty"`
+	Datasource string `yaml:"datasource,omitempty"` // used when a command's --datasource is not given
+}
+
+// cliConfig is ~/.air/config
+type cliConfig struct {
+	Current  string              `yaml:"current,omitempty"`
+	Profiles map[string]*profile `yaml:"profiles,omitempty"`
+}
+
+// Global settings, from flags or the selected profile
+var (
+	serverURL         string
+	authToken         string
+	authDisabled      bool
+	noColor           bool
+	profileName       string
+	defaultDatasource string
+)
+
+// configPath is where profiles are kept; AIR_CONFIG overrides it
+func configPath() (string, error) {
+	if path := os.Getenv("AIR_CONFIG"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".air", "config"), nil
+}
+
+// loadConfig reads the profiles file; a missing file is an empty config
+func loadConfig() (*cliConfig, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &cliConfig{Profiles: make(map[string]*profile)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]*profile)
+	}
+	return cfg, nil
+}
+
+// saveConfig writes the profiles file readable by the user only, since it
+// holds tokens
+func saveConfig(cfg *cliConfig) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// applyProfile fills the global settings the command line left unset, from
+// AIR_SERVER and AIR_TOKEN, then from the profile named by --profile,
+// AIR_PROFILE or the config's current one
+func applyProfile(cmd *cobra.Command) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	name := profileName
+	if name == "" {
+		name = os.Getenv("AIR_PROFILE")
+	}
+	if name == "" {
+		name = cfg.Current
+	}
+
+	selected := &profile{}
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return fmt.Errorf("profile %q not found; see \"aircli profile list\"", name)
+		}
+		selected = p
+	}
+
+	flags := cmd.Flags()
+	if !flags.Changed("server") {
+		serverURL = firstNonEmpty(os.Getenv("AIR_SERVER"), selected.Server, defaultServerURL)
+	}
+	if !flags.Changed("token") {
+		authToken = firstNonEmpty(os.Getenv("AIR_TOKEN"), selected.Token)
+	}
+	defaultDatasource = selected.Datasource
+	return nil
+}
+
+// firstNonEmpty returns the first value that is set
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// datasourceOrDefault returns id, or when id is empty the profile's default
+// datasource, then the one in the user's server-side preferences
+func datasourceOrDefault(id string) string {
+	if id != "" {
+		return id
+	}
+	if defaultDatasource != "" {
+		return defaultDatasource
+	}
+	if prefs, err := fetchPreferences(); err == nil && prefs.DefaultDatasource != "" {
+		return prefs.DefaultDatasource
+	}
+	log.Fatalf("No datasource given: pass --datasource, set one on the profile or with \"aircli prefs set --datasource\"")
+	return ""
+}
+
+// completeProfileNames offers profile names for shell completion
+func completeProfileNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeDatasourceIDs offers the server's datasource IDs for shell
+// completion. Completion skips the pre-run hooks, so the profile is applied here.
+func completeDatasourceIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || applyProfile(cmd) != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	body, err := doAPIRequest(http.MethodGet, "/v1/datasources", "", nil)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var result struct {
+		Datasources []struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		} `json:"datasources"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	ids := make([]string, 0, len(result.Datasources))
+	for _, ds := range result.Datasources {
+		ids = append(ids, ds.ID+"\t"+ds.DisplayName)
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+func profileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage connection profiles",
+		Long: `Manage named connection profiles kept in ~/.air/config (or $AIR_CONFIG).
+A profile holds a server URL, a token and a default datasource. Select one with --profile or AIR_PROFILE, or make it current with "profile use".`,
+		// Profiles are edited, not used, here; a missing current profile must not block fixing it
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+	cmd.AddCommand(profileListCmd())
+	cmd.AddCommand(profileSetCmd())
+	cmd.AddCommand(profileUseCmd())
+	cmd.AddCommand(profileRemoveCmd())
+	return cmd
+}
+
+func profileListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig()
+			if err != nil {
+				log.Fatalf("Failed to read profiles: %v", err)
+			}
+			if len(cfg.Profiles) == 0 {
+				fmt.Println("No profiles; add one with \"aircli profile set <name> --server <url>\"")
+				return
+			}
+
+			names, _ := completeProfileNames(cmd, nil, "")
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "\tNAME\tSERVER\tDATASOURCE\tTOKEN")
+			for _, name := range names {
+				p := cfg.Profiles[name]
+				current, token := "", "-"
+				if name == cfg.Current {
+					current = "*"
+				}
+				if p.Token != "" {
+					token = "set"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", current, name, p.Server, p.Datasource, token)
+			}
+			w.Flush()
+		},
+	}
+}
+
+func profileSetCmd() *cobra.Command {
+	var server, token, datasource string
+
+	cmd := &cobra.Command{
+		Use:   "set [name]",
+		Short: "Add or update a profile",
+		Long:  `Add a profile, or change the fields given on an existing one. The first profile added becomes current.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig()
+			if err != nil {
+				log.Fatalf("Failed to read profiles: %v", err)
+			}
+
+			p, ok := cfg.Profiles[args[0]]
+			if !ok {
+				p = &profile{}
+				cfg.Profiles[args[0]] = p
+			}
+			if cmd.Flags().Changed("server") {
+				p.Server = server
+			}
+			if cmd.Flags().Changed("token") {
+				p.Token = token
+			}
+			if cmd.Flags().Changed("datasource") {
+				p.Datasource = datasource
+			}
+			if cfg.Current == "" {
+				cfg.Current = args[0]
+			}
+
+			if err := saveConfig(cfg); err != nil {
+				log.Fatalf("Failed to save profiles: %v", err)
+			}
+			fmt.Printf("Saved profile %s\n", args[0])
+		},
+	}
+
+	// Local flags shadow the global --server and --token for this command
+	cmd.Flags().StringVar(&server, "server", "", "AIR server URL")
+	cmd.Flags().StringVar(&token, "token", "", "JWT authentication token")
+	cmd.Flags().StringVar(&datasource, "datasource", "", "Default datasource ID")
+
+	return cmd
+}
+
+func profileUseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "use [name]",
+		Short:             "Make a profile current",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfileNames,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig()
+			if err != nil {
+				log.Fatalf("Failed to read profiles: %v", err)
+			}
+			if _, ok := cfg.Profiles[args[0]]; !ok {
+				log.Fatalf("Profile %s not found", args[0])
+			}
+
+			cfg.Current = args[0]
+			if err := saveConfig(cfg); err != nil {
+				log.Fatalf("Failed to save profiles: %v", err)
+			}
+			fmt.Printf("Using profile %s\n", args[0])
+		},
+	}
+}
+
+func profileRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "remove [name]",
+		Short:             "Remove a profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfileNames,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := loadConfig()
+			if err != nil {
+				log.Fatalf("Failed to read profiles: %v", err)
+			}
+			if _, ok := cfg.Profiles[args[0]]; !ok {
+				log.Fatalf("Profile %s not found", args[0])
+			}
+
+			delete(cfg.Profiles, args[0])
+			if cfg.Current == args[0] {
+				cfg.Current = ""
+			}
+			if err := saveConfig(cfg); err != nil {
+				log.Fatalf("Failed to save profiles: %v", err)
+			}
+			fmt.Printf("Removed profile %s\n", args[0])
+		},
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// savedPrompt is a prompt in the server's saved prompts library
+type savedPrompt struct {
+	ID          uint     `json:"id"`
+	Owner       string   `json:"owner"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Text        string   `json:"text"`
+	Params      []string `json:"params"`
+	Shared      bool     `json:"shared"`
+}
+
+func promptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Manage saved prompts",
+		Long: `Save questions you ask often, with {{param}} placeholders, and run them against a
+chat session's files or a datasource. Shared prompts are visible to every user.`,
+	}
+	cmd.AddCommand(promptListCmd())
+	cmd.AddCommand(promptSaveCmd())
+	cmd.AddCommand(promptRunCmd())
+	cmd.AddCommand(promptDeleteCmd())
+	return cmd
+}
+
+func promptListCmd() *cobra.Command {
+	var prefix string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List your prompts and the shared ones",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			path := "/v1/prompts"
+			if prefix != "" {
+				path += "?q=" + url.QueryEscape(prefix)
+			}
+			body, err := doAPIRequest(http.MethodGet, path, "", nil)
+			if err != nil {
+				log.Fatalf("Failed to list prompts: %v", err)
+			}
+			var result struct {
+				Prompts []savedPrompt `json:"prompts"`
+			}
+			if err := json.Unmarshal(body, &result); err != nil {
+				log.Fatalf("Failed to parse prompts: %v", err)
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tOWNER\tSHARED\tPARAMS")
+			for _, p := range result.Prompts {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%t\t%s\n", p.ID, p.Name, p.Owner, p.Shared, strings.Join(p.Params, ", "))
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&prefix, "q", "", "Only prompts whose names start with this")
+	return cmd
+}
+
+func promptSaveCmd() *cobra.Command {
+	var text, file, description string
+	var shared bool
+
+	cmd := &cobra.Command{
+		Use:   "save <name>",
+		Short: "Save a prompt",
+		Long:  `Save a prompt from --text, or a file with --file (- for stdin). Placeholders like {{region}} become params given at run time.`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if file != "" {
+				data, err := readInput(file)
+				if err != nil {
+					log.Fatalf("Failed to read prompt: %v", err)
+				}
+				text = string(data)
+			}
+			if strings.TrimSpace(text) == "" {
+				log.Fatalf("Give the prompt with --text or --file")
+			}
+
+			reqBody, _ := json.Marshal(map[string]interface{}{
+				"name":        args[0],
+				"description": description,
+				"text":        text,
+				"shared":      shared,
+			})
+			body, err := doAPIRequest(http.MethodPost, "/v1/prompts", "application/json", bytes.NewReader(reqBody))
+			if err != nil {
+				log.Fatalf("Failed to save prompt: %v", err)
+			}
+			var prompt savedPrompt
+			if err := json.Unmarshal(body, &prompt); err != nil {
+				log.Fatalf("Failed to parse prompt: %v", err)
+			}
+			fmt.Printf("Saved prompt %d %q", prompt.ID, prompt.Name)
+			if len(prompt.Params) > 0 {
+				fmt.Printf(" with params %s", strings.Join(prompt.Params, ", "))
+			}
+			fmt.Println()
+		},
+	}
+
+	cmd.Flags().StringVar(&text, "text", "", "Prompt text")
+	cmd.Flags().StringVar(&file, "file", "", "Read the prompt text from a file, or - for stdin")
+	cmd.Flags().StringVar(&description, "description", "", "What the prompt is for")
+	cmd.Flags().BoolVar(&shared, "shared", false, "Share the prompt with every user")
+	return cmd
+}
+
+func promptRunCmd() *cobra.Command {
+	var params []string
+	var datasource string
+	var sessionID uint
+
+	cmd := &cobra.Command{
+		Use:   "run <id>",
+		Short: "Run a saved prompt",
+		Long: `Run a saved prompt against a chat session's CSV and TSV files with --session, or a
+datasource. Without either it uses the profile's datasource, then the one in your preferences.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			values := map[string]string{}
+			for _, param := range params {
+				name, value, ok := strings.Cut(param, "=")
+				if !ok {
+					log.Fatalf("Invalid --param %q, expected name=value", param)
+				}
+				values[name] = value
+			}
+			req := map[string]interface{}{"params": values}
+			if sessionID != 0 {
+				req["session_id"] = sessionID
+			} else if datasource != "" || defaultDatasource != "" {
+				req["datasource_id"] = datasourceOrDefault(datasource)
+			}
+
+			reqBody, _ := json.Marshal(req)
+			body, err := doAPIRequest(http.MethodPost, "/v1/prompts/"+args[0]+"/run", "application/json", bytes.NewReader(reqBody))
+			if err != nil {
+				log.Fatalf("Failed to run prompt: %v", err)
+			}
+			var run struct {
+				Question string `json:"question"`
+				Answer   string `json:"answer"`
+				SQL      string `json:"sql"`
+			}
+			if err := json.Unmarshal(body, &run); err != nil {
+				log.Fatalf("Failed to parse answer: %v", err)
+			}
+			fmt.Println(run.Answer)
+			if run.SQL != "" {
+				fmt.Println()
+				fmt.Println(highlightSQL(run.SQL))
+			}
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&params, "param", nil, "Prompt param as name=value (repeatable)")
+	cmd.Flags().StringVar(&datasource, "datasource", "", "Datasource to ask")
+	cmd.Flags().UintVar(&sessionID, "session", 0, "Chat session whose files to ask")
+	cmd.RegisterFlagCompletionFunc("datasource", completeDatasourceIDs)
+	return cmd
+}
+
+func promptDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <id>",
+		Short: "Delete one of your prompts",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := doAPIRequest(http.MethodDelete, "/v1/prompts/"+args[0], "", nil); err != nil {
+				log.Fatalf("Failed to delete prompt: %v", err)
+			}
+			fmt.Printf("Deleted prompt %s\n", args[0])
+		},
+	}
+}
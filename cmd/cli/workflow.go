@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func scopeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scope",
+		Short: "Manage scopes",
+		Long:  `Create scopes and add Markdown scope versions, the first step of authoring a report.`,
+	}
+	cmd.AddCommand(scopeCreateCmd())
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Manage scope versions",
+	}
+	versionCmd.AddCommand(scopeVersionAddCmd())
+	cmd.AddCommand(versionCmd)
+
+	return cmd
+}
+
+func scopeCreateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "create [name]",
+		Short: "Create a scope",
+		Long:  `Create a draft scope. Add its content with "scope version add".`,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			reqBody, _ := json.Marshal(map[string]string{"name": args[0]})
+			body, err := doAPIRequest(http.MethodPost, "/v1/scopes", "application/json", bytes.NewReader(reqBody))
+			if err != nil {
+				log.Fatalf("Scope creation failed: %v", err)
+			}
+
+			var scope struct {
+				ID     uint   `json:"id"`
+				Name   string `json:"name"`
+				Status string `json:"status"`
+			}
+			if err := json.Unmarshal(body, &scope); err != nil {
+				log.Fatalf("Failed to parse response: %v", err)
+			}
+			fmt.Printf("Created scope %d: %s (%s)\n", scope.ID, scope.Name, scope.Status)
+		},
+	}
+}
+
+func scopeVersionAddCmd() *cobra.Command {
+	var file string
+	var baseVersion int
+
+	cmd := &cobra.Command{
+		Use:   "add [scope_id]",
+		Short: "Add a scope version",
+		Long: `Add a version to a scope from a Markdown file, or stdin with --file -.
+With --base-version the version is only added if no other was added since that one.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			md, err := readInput(file)
+			if err != nil {
+				log.Fatalf("Failed to read scope: %v", err)
+			}
+
+			req := map[string]interface{}{"scope_md": string(md)}
+			if baseVersion > 0 {
+				req["base_version"] = baseVersion
+			}
+			reqBody, _ := json.Marshal(req)
+
+			body, err := doAPIRequest(http.MethodPost, "/v1/scopes/"+args[0]+"/version", "application/json", bytes.NewReader(reqBody))
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+				log.Fatalf("Scope %s changed since version %d; fetch the latest version and retry", args[0], baseVersion)
+			}
+			if err != nil {
+				log.Fatalf("Adding scope version failed: %v", err)
+			}
+
+			var version struct {
+				ID      uint `json:"id"`
+				ScopeID uint `json:"scope_id"`
+				Version int  `json:"version"`
+			}
+			if err := json.Unmarshal(body, &version); err != nil {
+				log.Fatalf("Failed to parse response: %v", err)
+			}
+			fmt.Printf("Added version %d to scope %d (scope_version_id %d)\n", version.Version, version.ScopeID, version.ID)
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "", "Markdown scope file, or - for stdin")
+	cmd.Flags().IntVar(&baseVersion, "base-version", 0, "Fail if the scope has versions newer than this one")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+func irCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ir",
+		Short: "Build report IR",
+		Long:  `Build the intermediate representation (IR) of a scope version.`,
+	}
+	cmd.AddCommand(irBuildCmd())
+	return cmd
+}
+
+func irBuildCmd() *cobra.Command {
+	var scopeVersionID uint
+	var datasourceID string
+
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build IR from a scope version",
+		Long:  `Build IR JSON from a scope version against a datasource's learned schema. The IR is printed to stdout, ready for "sql generate --ir -".`,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			reqBody, _ := json.Marshal(map[string]interface{}{
+				"scope_version_id": scopeVersionID,
+				"datasource_id":    datasourceOrDefault(datasourceID),
+			})
+			body, err := doAPIRequest(http.MethodPost, "/v1/ir/build", "application/json", bytes.NewReader(reqBody))
+			if err != nil {
+				log.Fatalf("IR build failed: %v", err)
+			}
+
+			var result struct {
+				IR json.RawMessage `json:"ir"`
+			}
+			if err := json.Unmarshal(body, &result); err != nil {
+				log.Fatalf("Failed to parse response: %v", err)
+			}
+			var pretty bytes.Buffer
+			if err := json.Indent(&pretty, result.IR, "", "  "); err != nil {
+				log.Fatalf("Failed to format IR: %v", err)
+			}
+			fmt.Println(highlightJSON(pretty.String()))
+		},
+	}
+
+	cmd.Flags().UintVar(&scopeVersionID, "scope-version", 0, "Scope version ID")
+	cmd.Flags().StringVar(&datasourceID, "datasource", "", "Datasource ID (default: the profile's)")
+	cmd.MarkFlagRequired("scope-version")
+	cmd.RegisterFlagCompletionFunc("datasource", completeDatasourceIDs)
+
+	return cmd
+}
+
+func sqlCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sql",
+		Short: "Generate SQL",
+		Long:  `Generate SQL for a datasource from report IR.`,
+	}
+	cmd.AddCommand(sqlGenerateCmd())
+	return cmd
+}
+
+func sqlGenerateCmd() *cobra.Command {
+	var irFile string
+	var datasourceID string
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate SQL from IR",
+		Long: `Generate SQL from an IR JSON file, or stdin with --ir -, for a datasource's dialect.
+The file may hold the IR itself or the output of "ir build". Safety warnings are printed to stderr.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := readInput(irFile)
+			if err != nil {
+				log.Fatalf("Failed to read IR: %v", err)
+			}
+
+			var ir map[string]interface{}
+			if err := json.Unmarshal(data, &ir); err != nil {
+				log.Fatalf("Invalid IR JSON: %v", err)
+			}
+			if wrapped, ok := ir["ir"].(map[string]interface{}); ok {
+				ir = wrapped
+			}
+
+			reqBody, _ := json.Marshal(map[string]interface{}{
+				"ir":            ir,
+				"datasource_id": datasourceOrDefault(datasourceID),
+			})
+			body, err := doAPIRequest(http.MethodPost, "/v1/sql", "application/json", bytes.NewReader(reqBody))
+			if err != nil {
+				log.Fatalf("SQL generation failed: %v", err)
+			}
+
+			var result struct {
+				SQL          string `json:"sql"`
+				SafetyReport struct {
+					Warnings []string `json:"warnings"`
+				} `json:"safety_report"`
+			}
+			if err := json.Unmarshal(body, &result); err != nil {
+				log.Fatalf("Failed to parse response: %v", err)
+			}
+			fmt.Println(highlightSQL(strings.TrimSpace(result.SQL)))
+			for _, warning := range result.SafetyReport.Warnings {
+				fmt.Fprintf(os.Stderr, "⚠️  %s\n", warning)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&irFile, "ir", "", "IR JSON file, or - for stdin")
+	cmd.Flags().StringVar(&datasourceID, "datasource", "", "Datasource ID (default: the profile's)")
+	cmd.MarkFlagRequired("ir")
+	cmd.RegisterFlagCompletionFunc("datasource", completeDatasourceIDs)
+
+	return cmd
+}
+
+// readInput reads a file, or stdin when name is "-"
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
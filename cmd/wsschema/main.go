@@ -0,0 +1,247 @@
+// Command wsschema generates the JSON Schema and TypeScript types of the
+// WebSocket protocol from the message registry in internal/websocket.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	ws "github.com/NubeDev/air/internal/websocket"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "api/websocket.schema.json", "JSON Schema output file")
+	tsPath := flag.String("ts", "air-ui/src/types/websocket.gen.ts", "TypeScript output file")
+	flag.Parse()
+
+	payloads := collectPayloads()
+
+	schema, err := json.MarshalIndent(jsonSchema(payloads), "", "  ")
+	if err != nil {
+		log.Fatalf("failed to encode schema: %v", err)
+	}
+	if err := os.WriteFile(*schemaPath, append(schema, '\n'), 0644); err != nil {
+		log.Fatalf("failed to write %s: %v", *schemaPath, err)
+	}
+	if err := os.WriteFile(*tsPath, []byte(typeScript(payloads)), 0644); err != nil {
+		log.Fatalf("failed to write %s: %v", *tsPath, err)
+	}
+	fmt.Printf("Wrote %s and %s (protocol v%d)\n", *schemaPath, *tsPath, ws.ProtocolVersion)
+}
+
+// field is one JSON property of a payload struct
+type field struct {
+	Name     string
+	Type     reflect.Type
+	Optional bool
+}
+
+// collectPayloads returns every struct reachable from the registry, by name
+func collectPayloads() map[string]reflect.Type {
+	types := map[string]reflect.Type{}
+	var visit func(t reflect.Type)
+	visit = func(t reflect.Type) {
+		for t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		if _, ok := types[t.Name()]; ok {
+			return
+		}
+		types[t.Name()] = t
+		for _, f := range fields(t) {
+			visit(f.Type)
+		}
+	}
+	for _, spec := range ws.Messages {
+		if spec.Payload != nil {
+			visit(reflect.TypeOf(spec.Payload))
+		}
+	}
+	return types
+}
+
+// fields lists the JSON properties of a struct
+func fields(t reflect.Type) []field {
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, field{Name: name, Type: f.Type, Optional: strings.Contains(opts, "omitempty")})
+	}
+	return out
+}
+
+// sortedNames returns map keys in a stable order
+func sortedNames(types map[string]reflect.Type) []string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func jsonSchema(payloads map[string]reflect.Type) map[string]interface{} {
+	defs := map[string]interface{}{}
+	for name, t := range payloads {
+		props := map[string]interface{}{}
+		required := []string{}
+		for _, f := range fields(t) {
+			props[f.Name] = schemaType(f.Type)
+			if !f.Optional {
+				required = append(required, f.Name)
+			}
+		}
+		defs[name] = map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+			"required":   required,
+		}
+	}
+
+	var client, server []interface{}
+	for _, spec := range ws.Messages {
+		payload := map[string]interface{}{"type": "object"}
+		if spec.Payload != nil {
+			payload = map[string]interface{}{"$ref": "#/$defs/" + reflect.TypeOf(spec.Payload).Name()}
+		}
+		message := map[string]interface{}{
+			"title":       spec.Type,
+			"description": fmt.Sprintf("%s (since v%d)", spec.Description, spec.Since),
+			"type":        "object",
+			"properties": map[string]interface{}{
+				"type":      map[string]interface{}{"const": spec.Type},
+				"channel":   map[string]interface{}{"type": "string"},
+				"payload":   payload,
+				"timestamp": map[string]interface{}{"type": "string", "format": "date-time"},
+				"user_id":   map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"type"},
+		}
+		if spec.Direction == ws.FromClient {
+			client = append(client, message)
+		} else {
+			server = append(server, message)
+		}
+	}
+	defs["ClientMessage"] = map[string]interface{}{"oneOf": client}
+	defs["ServerMessage"] = map[string]interface{}{"oneOf": server}
+
+	return map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         fmt.Sprintf("https://github.com/NubeDev/air/api/websocket.v%d.schema.json", ws.ProtocolVersion),
+		"title":       fmt.Sprintf("AIR WebSocket protocol v%d", ws.ProtocolVersion),
+		"description": fmt.Sprintf("Generated by cmd/wsschema; do not edit. Negotiate with the %q subprotocol or ?protocol=%d.", ws.Subprotocol(ws.ProtocolVersion), ws.ProtocolVersion),
+		"oneOf": []interface{}{
+			map[string]interface{}{"$ref": "#/$defs/ClientMessage"},
+			map[string]interface{}{"$ref": "#/$defs/ServerMessage"},
+		},
+		"$defs": defs,
+	}
+}
+
+func schemaType(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaType(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaType(t.Elem())}
+	case reflect.Pointer:
+		return schemaType(t.Elem())
+	case reflect.Struct:
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+func typeScript(payloads map[string]reflect.Type) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by cmd/wsschema; DO NOT EDIT.\n")
+	fmt.Fprintf(&b, "// AIR WebSocket protocol v%d\n\n", ws.ProtocolVersion)
+	fmt.Fprintf(&b, "export const WS_PROTOCOL_VERSION = %d;\n", ws.ProtocolVersion)
+	fmt.Fprintf(&b, "export const WS_SUBPROTOCOL = %q;\n\n", ws.Subprotocol(ws.ProtocolVersion))
+
+	for _, name := range sortedNames(payloads) {
+		fmt.Fprintf(&b, "export interface %s {\n", name)
+		for _, f := range fields(payloads[name]) {
+			opt := ""
+			if f.Optional {
+				opt = "?"
+			}
+			fmt.Fprintf(&b, "  %s%s: %s;\n", f.Name, opt, tsType(f.Type))
+		}
+		b.WriteString("}\n\n")
+	}
+
+	b.WriteString("interface Envelope<T extends string, P> {\n")
+	b.WriteString("  type: T;\n  channel?: string;\n  payload: P;\n  timestamp?: string;\n  user_id?: string;\n}\n\n")
+
+	for _, direction := range []string{ws.FromClient, ws.FromServer} {
+		union := "ClientMessage"
+		if direction == ws.FromServer {
+			union = "ServerMessage"
+		}
+		fmt.Fprintf(&b, "export type %s =\n", union)
+		for _, spec := range ws.Messages {
+			if spec.Direction != direction {
+				continue
+			}
+			payload := "Record<string, unknown>"
+			if spec.Payload != nil {
+				payload = reflect.TypeOf(spec.Payload).Name()
+			}
+			fmt.Fprintf(&b, "  | Envelope<%q, %s> // %s\n", spec.Type, payload, spec.Description)
+		}
+		b.WriteString(";\n\n")
+	}
+
+	b.WriteString("export type WebSocketProtocolMessage = ClientMessage | ServerMessage;\n")
+	return b.String()
+}
+
+func tsType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return tsType(t.Elem()) + "[]"
+	case reflect.Map:
+		return "Record<" + tsType(t.Key()) + ", " + tsType(t.Elem()) + ">"
+	case reflect.Pointer:
+		return tsType(t.Elem())
+	case reflect.Struct:
+		return t.Name()
+	}
+	return "unknown"
+}
@@ -190,12 +190,12 @@ async def _process_discover(token: int, datasource_id: str, uri: str,
     try:
         job_manager.update_job_status(token, "running", "Starting file discovery")
         
-        files = data_processor.discover_files(datasource_id, uri, recurse, max_files)
+        found = data_processor.discover(datasource_id, uri, recurse, max_files)
         
         job_manager.update_job_status(
             token, "completed", 
-            f"Found {len(files)} files",
-            data={"files": files}
+            f"Found {len(found['files'])} files and {len(found['tables'])} tables",
+            data=found
         )
     except Exception as e:
         job_manager.update_job_status(token, "failed", error=str(e))
@@ -36,6 +36,10 @@ class Settings(BaseSettings):
         return [ext.strip() for ext in self.allowed_extensions.split(",")]
     temp_dir: str = "/tmp/air-py"
     
+    # S3 access for s3:// URIs; credentials come from the AWS_* environment
+    s3_region: str = ""
+    s3_endpoint: str = ""  # host[:port] of an S3-compatible store such as MinIO
+    
     # Go backend communication
     go_backend_url: str = "http://localhost:9000"
     
@@ -9,6 +9,7 @@ from typing import Dict, Any, List, Optional, Union
 import json
 import os
 from app.core.config import settings
+from app.services import lake_tables
 
 
 class DataProcessor:
@@ -18,31 +19,41 @@ class DataProcessor:
         self.temp_dir = Path(settings.temp_dir)
         self.temp_dir.mkdir(exist_ok=True)
     
+    def discover(self, datasource_id: str, uri: str, recurse: bool = True,
+                 max_files: Optional[int] = None) -> Dict[str, List[Dict[str, Any]]]:
+        """Discover files and Iceberg/Delta tables in the given path or s3:// URI.
+        Table directories are reported with their metadata instead of as the
+        data files inside them."""
+        lfs = lake_tables.LakeFS(uri)
+        files, tables = [], []
+        for kind, item in lake_tables.walk(lfs, recurse):
+            if kind == "table":
+                path, fmt = item
+                tables.append(lake_tables.read_table(lfs, path, fmt))
+            elif self._is_supported_file(Path(item.path)):
+                if max_files and len(files) >= max_files:
+                    continue
+                files.append(self._get_file_info(lfs, item))
+        return {"files": files, "tables": tables}
+
     def discover_files(self, datasource_id: str, uri: str, recurse: bool = True, 
                       max_files: Optional[int] = None) -> List[Dict[str, Any]]:
         """Discover files in the given URI."""
-        files = []
-        path = Path(uri)
-        
-        if not path.exists():
-            raise FileNotFoundError(f"Path not found: {uri}")
-        
-        if path.is_file():
-            if self._is_supported_file(path):
-                files.append(self._get_file_info(path))
-        else:
-            pattern = "**/*" if recurse else "*"
-            for file_path in path.glob(pattern):
-                if file_path.is_file() and self._is_supported_file(file_path):
-                    files.append(self._get_file_info(file_path))
-                    if max_files and len(files) >= max_files:
-                        break
-        
-        return files
+        return self.discover(datasource_id, uri, recurse, max_files)["files"]
     
     def infer_schema(self, datasource_id: str, uri: str, sample_files: Optional[int] = None,
                     infer_rows: int = 20000) -> Dict[str, Any]:
-        """Infer schema from files."""
+        """Infer schema from files. A table directory's schema and partitioning
+        are read from its metadata instead of sampled."""
+        table = self._lake_table(uri)
+        if table is not None:
+            return {
+                "schema": table["schema"],
+                "partitioning": table["partitioning"],
+                "stats": {"rows": table.get("rows"), "columns": len(table["schema"]["fields"])},
+                "table": table,
+            }
+
         files = self.discover_files(datasource_id, uri, max_files=sample_files)
         
         if not files:
@@ -83,7 +94,11 @@ class DataProcessor:
                 raise ValueError("No files found")
             path = files[0]["path"]
         
-        df = self._load_file(path, n_rows=limit)
+        table = self._lake_table(path)
+        if table is not None:
+            df = self._scan_table(table, {"dataset": path, "limit": limit})
+        else:
+            df = self._load_file(path, n_rows=limit)
         
         return {
             "rows": df.to_dicts()[:limit],
@@ -102,12 +117,18 @@ class DataProcessor:
         # This is a simplified implementation
         # In production, you'd implement full query execution logic
         
-        files = self.discover_files(datasource_id, plan["dataset"])
-        if not files:
-            raise ValueError("No files found for dataset")
-        
-        # Load and process first file as example
-        df = self._load_file(files[0]["path"])
+        table = self._lake_table(plan["dataset"])
+        if table is not None:
+            # DuckDB applies the whole plan, so partitions are pruned by the filters
+            df = self._scan_table(table, plan)
+            plan = {}
+        else:
+            files = self.discover_files(datasource_id, plan["dataset"])
+            if not files:
+                raise ValueError("No files found for dataset")
+            
+            # Load and process first file as example
+            df = self._load_file(files[0]["path"])
         
         # Apply filters if specified
         if plan.get("filters"):
@@ -230,29 +251,82 @@ class DataProcessor:
         """Check if file has supported extension."""
         return file_path.suffix.lower() in settings.allowed_extensions_list
     
-    def _get_file_info(self, file_path: Path) -> Dict[str, Any]:
+    def _get_file_info(self, lfs: lake_tables.LakeFS, entry: lake_tables.Entry) -> Dict[str, Any]:
         """Get file information."""
-        stat = file_path.stat()
+        name = Path(entry.path).name
         return {
-            "path": str(file_path),
-            "name": file_path.name,
-            "size": stat.st_size,
-            "modified": stat.st_mtime,
-            "extension": file_path.suffix
+            "path": lfs.uri(entry.path),
+            "name": name,
+            "size": entry.size,
+            "modified": entry.modified,
+            "extension": Path(name).suffix
         }
     
     def _load_file(self, file_path: str, n_rows: Optional[int] = None) -> pl.DataFrame:
         """Load file into Polars DataFrame."""
         path = Path(file_path)
+        source: Any = file_path
+        if file_path.startswith("s3://"):
+            lfs = lake_tables.LakeFS(file_path)
+            source = lfs.open(lfs.root)
         
         if path.suffix.lower() == ".csv":
-            return pl.read_csv(file_path, n_rows=n_rows)
+            return pl.read_csv(source, n_rows=n_rows)
         elif path.suffix.lower() == ".parquet":
-            return pl.read_parquet(file_path, n_rows=n_rows)
+            return pl.read_parquet(source, n_rows=n_rows)
         elif path.suffix.lower() == ".jsonl":
-            return pl.read_ndjson(file_path, n_rows=n_rows)
+            return pl.read_ndjson(source, n_rows=n_rows)
         else:
             raise ValueError(f"Unsupported file format: {path.suffix}")
+
+    def _lake_table(self, uri: Optional[str]) -> Optional[Dict[str, Any]]:
+        """Return the metadata of an Iceberg or Delta table directory, or None
+        when uri is not one."""
+        if not uri:
+            return None
+        lfs = lake_tables.LakeFS(uri)
+        entry = lfs.info(lfs.root)
+        if entry is None or not entry.is_dir:
+            return None
+        fmt = lake_tables.table_format(lfs, lfs.root)
+        if fmt is None:
+            return None
+        return lake_tables.read_table(lfs, lfs.root, fmt)
+
+    def _scan_table(self, table: Dict[str, Any], plan: Dict[str, Any]) -> pl.DataFrame:
+        """Run a query plan against a lake table with DuckDB."""
+        ident = lake_tables.sql_ident
+        columns = [ident(c) for c in plan.get("select") or []]
+        group_cols = [ident(c) for c in plan.get("groupby") or []]
+        if group_cols and plan.get("aggs"):
+            columns = list(group_cols)
+            for agg in plan["aggs"]:
+                fn = agg["fn"].lower()
+                if fn not in ("sum", "count", "avg", "min", "max"):
+                    raise ValueError(f"Unsupported aggregation: {fn}")
+                columns.append(f"{fn}({ident(agg['col'])}) AS {ident(fn + '_' + agg['col'])}")
+
+        where, params = [], []
+        for filter_cond in plan.get("filters") or []:
+            op = filter_cond["op"]
+            if op not in (">=", "<=", "==", ">", "<", "!="):
+                raise ValueError(f"Unsupported filter operator: {op}")
+            where.append(f"{ident(filter_cond['col'])} {'=' if op == '==' else op} ?")
+            params.append(filter_cond["val"])
+
+        query = f"SELECT {', '.join(columns) or '*'} FROM {lake_tables.scan_sql(table)}"
+        if where:
+            query += " WHERE " + " AND ".join(where)
+        if group_cols and plan.get("aggs"):
+            query += " GROUP BY " + ", ".join(group_cols)
+        if plan.get("limit"):
+            query += f" LIMIT {int(plan['limit'])}"
+
+        con = lake_tables.duckdb_connection()
+        try:
+            return pl.from_arrow(con.execute(query, params).arrow())
+        finally:
+            con.close()
     
     def _load_from_frame_ref(self, frame_ref: Dict[str, Any]) -> pl.DataFrame:
         """Load DataFrame from frame reference."""
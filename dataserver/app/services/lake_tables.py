@@ -0,0 +1,354 @@
+"""Iceberg and Delta Lake table discovery.
+
+A lake table is a directory rather than a file: its data files are only
+meaningful through the table metadata beside them, which also carries the
+exact schema and partitioning. Discovery reports such directories as tables
+instead of listing their data files, schemas are read from the metadata
+rather than sampled, and queries scan the table through DuckDB.
+"""
+
+import json
+import os
+import re
+from dataclasses import dataclass
+from typing import Any, Dict, Iterator, List, Optional, Tuple
+
+from app.core.config import settings
+
+ICEBERG = "iceberg"
+DELTA = "delta"
+
+# Iceberg metadata files are v<N>.metadata.json (Hadoop tables) or
+# <NNNNN>-<uuid>.metadata.json (catalog-managed tables)
+_ICEBERG_METADATA_RE = re.compile(r"^v?(\d+)(?:-[0-9a-fA-F-]+)?\.metadata\.json$")
+# Delta commits are <20-digit version>.json
+_DELTA_COMMIT_RE = re.compile(r"^(\d{20})\.json$")
+
+
+@dataclass
+class Entry:
+    """A directory entry of a lake filesystem."""
+    path: str
+    is_dir: bool
+    size: int = 0
+    modified: float = 0.0
+
+
+class LakeFS:
+    """Local or S3 filesystem, addressed by path or s3:// URI."""
+
+    def __init__(self, uri: str):
+        from pyarrow import fs as pafs
+
+        if uri.startswith("s3://"):
+            self.scheme = "s3://"
+            self.fs = pafs.S3FileSystem(
+                region=settings.s3_region or None,
+                endpoint_override=settings.s3_endpoint or None,
+            )
+            self.root = uri[len(self.scheme):].rstrip("/")
+        else:
+            self.scheme = ""
+            self.fs = pafs.LocalFileSystem()
+            self.root = os.path.abspath(uri)
+
+    def uri(self, path: str) -> str:
+        """Return the URI of a filesystem path."""
+        return self.scheme + path
+
+    def info(self, path: str) -> Optional[Entry]:
+        """Return an entry for path, or None when it does not exist."""
+        from pyarrow import fs as pafs
+
+        info = self.fs.get_file_info(path)
+        if info.type == pafs.FileType.NotFound:
+            return None
+        return self._entry(info)
+
+    def list(self, path: str) -> List[Entry]:
+        """List a directory's entries."""
+        from pyarrow import fs as pafs
+
+        selector = pafs.FileSelector(path, allow_not_found=True)
+        return [self._entry(info) for info in self.fs.get_file_info(selector)]
+
+    def read_text(self, path: str) -> str:
+        """Read a small file as text."""
+        with self.fs.open_input_stream(path) as stream:
+            return stream.read().decode("utf-8")
+
+    def open(self, path: str):
+        """Open a file for random access."""
+        return self.fs.open_input_file(path)
+
+    @staticmethod
+    def _entry(info) -> Entry:
+        from pyarrow import fs as pafs
+
+        modified = info.mtime.timestamp() if info.mtime else 0.0
+        return Entry(
+            path=info.path,
+            is_dir=info.type == pafs.FileType.Directory,
+            size=info.size or 0,
+            modified=modified,
+        )
+
+
+def table_format(lfs: LakeFS, path: str) -> Optional[str]:
+    """Return "iceberg" or "delta" when path is a table directory."""
+    if lfs.info(f"{path}/_delta_log") is not None:
+        return DELTA
+    metadata = lfs.info(f"{path}/metadata")
+    if metadata is not None and metadata.is_dir:
+        names = [os.path.basename(e.path) for e in lfs.list(f"{path}/metadata")]
+        if any(_ICEBERG_METADATA_RE.match(name) for name in names):
+            return ICEBERG
+    return None
+
+
+def walk(lfs: LakeFS, recurse: bool = True) -> Iterator[Tuple[str, Any]]:
+    """Walk the filesystem root, yielding ("table", (path, format)) for table
+    directories, whose contents are not descended into, and ("file", Entry)
+    for other files."""
+    root = lfs.info(lfs.root)
+    if root is None:
+        raise FileNotFoundError(f"Path not found: {lfs.uri(lfs.root)}")
+    if not root.is_dir:
+        yield "file", root
+        return
+
+    pending = [lfs.root]
+    while pending:
+        directory = pending.pop(0)
+        fmt = table_format(lfs, directory)
+        if fmt:
+            yield "table", (directory, fmt)
+            continue
+        for entry in sorted(lfs.list(directory), key=lambda e: e.path):
+            if entry.is_dir:
+                if recurse or table_format(lfs, entry.path):
+                    pending.append(entry.path)
+            else:
+                yield "file", entry
+
+
+def read_table(lfs: LakeFS, path: str, fmt: str) -> Dict[str, Any]:
+    """Read a table's metadata: schema, partitioning and, where recorded,
+    row and file counts."""
+    if fmt == ICEBERG:
+        table = _read_iceberg(lfs, path)
+    elif fmt == DELTA:
+        table = _read_delta(lfs, path)
+    else:
+        raise ValueError(f"Unsupported table format: {fmt}")
+    table.update({
+        "name": os.path.basename(path.rstrip("/")),
+        "path": lfs.uri(path),
+        "format": fmt,
+    })
+    return table
+
+
+def _read_iceberg(lfs: LakeFS, path: str) -> Dict[str, Any]:
+    """Read the current schema and partition spec of an Iceberg table."""
+    metadata_dir = f"{path}/metadata"
+    metadata_file = None
+
+    hint = lfs.info(f"{metadata_dir}/version-hint.text")
+    if hint is not None:
+        version = lfs.read_text(hint.path).strip()
+        candidate = f"{metadata_dir}/v{version}.metadata.json"
+        if lfs.info(candidate) is not None:
+            metadata_file = candidate
+    if metadata_file is None:
+        versions = []
+        for entry in lfs.list(metadata_dir):
+            match = _ICEBERG_METADATA_RE.match(os.path.basename(entry.path))
+            if match:
+                versions.append((int(match.group(1)), entry.modified, entry.path))
+        if not versions:
+            raise ValueError(f"No Iceberg metadata found in {lfs.uri(metadata_dir)}")
+        metadata_file = max(versions)[2]
+
+    metadata = json.loads(lfs.read_text(metadata_file))
+    return parse_iceberg_metadata(metadata, lfs.uri(metadata_file))
+
+
+def parse_iceberg_metadata(metadata: Dict[str, Any], metadata_uri: str) -> Dict[str, Any]:
+    """Extract schema, partitioning and snapshot counts from Iceberg table
+    metadata of format version 1 or 2."""
+    schema = metadata.get("schema")
+    if "schemas" in metadata:
+        current = metadata.get("current-schema-id", 0)
+        schema = next((s for s in metadata["schemas"] if s.get("schema-id") == current), schema)
+    if not schema:
+        raise ValueError("Iceberg metadata has no schema")
+
+    fields = schema.get("fields", [])
+    names_by_id = {f["id"]: f["name"] for f in fields}
+
+    spec_fields = metadata.get("partition-spec", [])
+    if "partition-specs" in metadata:
+        default = metadata.get("default-spec-id", 0)
+        spec = next((s for s in metadata["partition-specs"] if s.get("spec-id") == default), None)
+        if spec is not None:
+            spec_fields = spec.get("fields", [])
+    partitioning = [
+        {
+            "column": names_by_id.get(f.get("source-id"), f.get("name")),
+            "transform": f.get("transform", "identity"),
+            "name": f.get("name"),
+        }
+        for f in spec_fields
+    ]
+
+    table: Dict[str, Any] = {
+        "schema": {"fields": [
+            {"name": f["name"], "type": _iceberg_type(f["type"]), "nullable": not f.get("required", False)}
+            for f in fields
+        ]},
+        "partitioning": partitioning,
+        "metadata_location": metadata_uri,
+        "location": metadata.get("location"),
+    }
+
+    snapshot_id = metadata.get("current-snapshot-id")
+    snapshot = next((s for s in metadata.get("snapshots", []) if s.get("snapshot-id") == snapshot_id), None)
+    if snapshot is not None:
+        summary = snapshot.get("summary", {})
+        table["snapshot_id"] = snapshot_id
+        if "total-records" in summary:
+            table["rows"] = int(summary["total-records"])
+        if "total-data-files" in summary:
+            table["data_files"] = int(summary["total-data-files"])
+    return table
+
+
+def _iceberg_type(t: Any) -> str:
+    """Render an Iceberg type; nested types are named by kind."""
+    if isinstance(t, dict):
+        if t.get("type") == "list":
+            return f"list<{_iceberg_type(t.get('element'))}>"
+        if t.get("type") == "map":
+            return f"map<{_iceberg_type(t.get('key'))}, {_iceberg_type(t.get('value'))}>"
+        return t.get("type", "struct")
+    return str(t)
+
+
+def _read_delta(lfs: LakeFS, path: str) -> Dict[str, Any]:
+    """Read the latest metaData action of a Delta table. Commits are read
+    newest first back to the last checkpoint, which holds the metadata as of
+    its version when no later commit changed it."""
+    log_dir = f"{path}/_delta_log"
+    commits = []
+    for entry in lfs.list(log_dir):
+        match = _DELTA_COMMIT_RE.match(os.path.basename(entry.path))
+        if match:
+            commits.append((int(match.group(1)), entry.path))
+    commits.sort(reverse=True)
+
+    checkpoint_version = -1
+    last_checkpoint = lfs.info(f"{log_dir}/_last_checkpoint")
+    if last_checkpoint is not None:
+        checkpoint_version = int(json.loads(lfs.read_text(last_checkpoint.path))["version"])
+
+    version = commits[0][0] if commits else checkpoint_version
+    if version < 0:
+        raise ValueError(f"No Delta commits found in {lfs.uri(log_dir)}")
+
+    for commit_version, commit_path in commits:
+        if commit_version <= checkpoint_version:
+            break
+        for line in lfs.read_text(commit_path).splitlines():
+            if line.strip():
+                action = json.loads(line)
+                if "metaData" in action:
+                    return parse_delta_metadata(action["metaData"], version)
+
+    if checkpoint_version >= 0:
+        meta = _delta_checkpoint_metadata(lfs, log_dir, checkpoint_version)
+        if meta is not None:
+            return parse_delta_metadata(meta, version)
+    raise ValueError(f"No Delta metadata found in {lfs.uri(log_dir)}")
+
+
+def _delta_checkpoint_metadata(lfs: LakeFS, log_dir: str, version: int) -> Optional[Dict[str, Any]]:
+    """Read the metaData action from a single-file checkpoint."""
+    import pyarrow.parquet as pq
+
+    checkpoint = f"{log_dir}/{version:020d}.checkpoint.parquet"
+    if lfs.info(checkpoint) is None:
+        return None
+    with lfs.open(checkpoint) as f:
+        rows = pq.read_table(f, columns=["metaData"]).column("metaData").to_pylist()
+    return next((row for row in rows if row), None)
+
+
+def parse_delta_metadata(meta: Dict[str, Any], version: int) -> Dict[str, Any]:
+    """Extract schema and partition columns from a Delta metaData action."""
+    schema = json.loads(meta["schemaString"])
+    return {
+        "schema": {"fields": [
+            {"name": f["name"], "type": _delta_type(f["type"]), "nullable": f.get("nullable", True)}
+            for f in schema.get("fields", [])
+        ]},
+        "partitioning": [
+            {"column": column, "transform": "identity", "name": column}
+            for column in meta.get("partitionColumns", [])
+        ],
+        "version": version,
+    }
+
+
+def _delta_type(t: Any) -> str:
+    """Render a Delta type; nested types are named by kind."""
+    if isinstance(t, dict):
+        if t.get("type") == "array":
+            return f"array<{_delta_type(t.get('elementType'))}>"
+        if t.get("type") == "map":
+            return f"map<{_delta_type(t.get('keyType'))}, {_delta_type(t.get('valueType'))}>"
+        return t.get("type", "struct")
+    return str(t)
+
+
+def scan_sql(table: Dict[str, Any]) -> str:
+    """Return the DuckDB table function that reads a table. Iceberg tables
+    are read from their current metadata file, so tables without a version
+    hint need no catalog."""
+    if table["format"] == ICEBERG:
+        return f"iceberg_scan({_sql_string(table['metadata_location'])})"
+    return f"delta_scan({_sql_string(table['path'])})"
+
+
+def duckdb_connection():
+    """Open a DuckDB connection that can scan lake tables, locally or on S3
+    with the credentials in AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+    AWS_SESSION_TOKEN."""
+    import duckdb
+
+    con = duckdb.connect()
+    for extension in ("httpfs", "iceberg", "delta"):
+        con.install_extension(extension)
+        con.load_extension(extension)
+
+    options = []
+    if os.environ.get("AWS_ACCESS_KEY_ID"):
+        options.append(f"KEY_ID {_sql_string(os.environ['AWS_ACCESS_KEY_ID'])}")
+        options.append(f"SECRET {_sql_string(os.environ.get('AWS_SECRET_ACCESS_KEY', ''))}")
+        if os.environ.get("AWS_SESSION_TOKEN"):
+            options.append(f"SESSION_TOKEN {_sql_string(os.environ['AWS_SESSION_TOKEN'])}")
+    if settings.s3_region:
+        options.append(f"REGION {_sql_string(settings.s3_region)}")
+    if settings.s3_endpoint:
+        options.append(f"ENDPOINT {_sql_string(settings.s3_endpoint)}")
+    if options:
+        con.execute(f"CREATE SECRET lake (TYPE S3, {', '.join(options)})")
+    return con
+
+
+def _sql_string(value: str) -> str:
+    return "'" + value.replace("'", "''") + "'"
+
+
+def sql_ident(name: str) -> str:
+    return '"' + name.replace('"', '""') + '"'
@@ -0,0 +1,18 @@
+package auth
+
+import "context"
+
+// usernameKey keys the authenticated username in a request context
+type usernameKey struct{}
+
+// WithUsername returns ctx carrying the authenticated username, so services
+// can attribute work to the caller without a gin context
+func WithUsername(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, usernameKey{}, username)
+}
+
+// Username returns the authenticated username in ctx, or "" when there is none
+func Username(ctx context.Context) string {
+	username, _ := ctx.Value(usernameKey{}).(string)
+	return username
+}
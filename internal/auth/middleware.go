@@ -44,6 +44,7 @@ func AuthMiddleware(jwtManager *JWTManager, authEnabled bool) gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("claims", claims)
+		c.Request = c.Request.WithContext(WithUsername(c.Request.Context(), claims.Username))
 
 		c.Next()
 	}
@@ -83,6 +84,44 @@ func OptionalAuthMiddleware(jwtManager *JWTManager, authEnabled bool) gin.Handle
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("claims", claims)
+		c.Request = c.Request.WithContext(WithUsername(c.Request.Context(), claims.Username))
+
+		c.Next()
+	}
+}
+
+// RequireAdmin creates a Gin middleware that lets only the configured admin
+// usernames through. It must run after AuthMiddleware. With authentication
+// disabled there is no caller to check, so every request passes.
+func RequireAdmin(admins []string, authEnabled bool) gin.HandlerFunc {
+	return requireUsers(admins, authEnabled, "Admin access required")
+}
+
+// RequireReviewer creates a Gin middleware that lets only the configured
+// reviewers and admins through, for approving and rejecting report
+// versions. Like RequireAdmin, it must run after AuthMiddleware.
+func RequireReviewer(reviewers, admins []string, authEnabled bool) gin.HandlerFunc {
+	return requireUsers(append(append([]string(nil), reviewers...), admins...), authEnabled, "Reviewer access required")
+}
+
+// requireUsers lets only usernames through, answering others with message
+func requireUsers(usernames []string, authEnabled bool, message string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		allowed[username] = true
+	}
+
+	return func(c *gin.Context) {
+		if !authEnabled {
+			c.Next()
+			return
+		}
+
+		if !allowed[c.GetString("username")] {
+			c.JSON(http.StatusForbidden, gin.H{"error": message})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,368 @@
+// Package backup writes and restores control-plane archives: a consistent
+// copy of the SQLite control-plane database, the uploads listing and the
+// config file, in one tar.gz.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Archive entry names
+const (
+	manifestFile = "manifest.json"
+	databaseFile = "control-plane.db"
+	uploadsFile  = "uploads.json"
+	configFile   = "config.yaml"
+)
+
+// formatVersion is bumped when the archive layout changes
+const formatVersion = 1
+
+// redacted replaces secrets in redacted archives
+const redacted = "REDACTED"
+
+// secretConfigKeys are config keys whose values are blanked in redacted archives
+var secretConfigKeys = map[string]bool{
+	"password": true, "secret": true, "jwt_secret": true, "api_key": true,
+	"token": true, "key_passphrase": true, "webhook_url": true,
+}
+
+// Options selects what goes into an archive
+type Options struct {
+	Redact     bool   // blank DSN passwords, webhook secrets and config secrets
+	ConfigPath string // config file to include; skipped when empty
+	UploadsDir string // directory whose files are listed; skipped when empty
+}
+
+// Manifest describes an archive
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Redacted  bool      `json:"redacted"`
+	Files     []string  `json:"files"`
+	Uploads   int       `json:"uploads"`
+}
+
+// Upload is one file listed in uploads.json. Only metadata is archived; the
+// files themselves are not.
+type Upload struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// archiveEntry is one file written to an archive
+type archiveEntry struct {
+	name string
+	data []byte
+}
+
+// Write streams an archive of db to w. The database is copied with VACUUM
+// INTO, so a backup can be taken while the server is running.
+func Write(db *gorm.DB, w io.Writer, opts Options) (*Manifest, error) {
+	tmp, err := os.MkdirTemp("", "air-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	dbCopy := filepath.Join(tmp, databaseFile)
+	if err := db.Exec("VACUUM INTO ?", dbCopy).Error; err != nil {
+		return nil, fmt.Errorf("failed to copy control-plane database: %w", err)
+	}
+	if opts.Redact {
+		if err := redactDatabase(dbCopy); err != nil {
+			return nil, err
+		}
+	}
+
+	manifest := &Manifest{Version: formatVersion, CreatedAt: time.Now().UTC(), Redacted: opts.Redact}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := os.ReadFile(dbCopy)
+	if err != nil {
+		return nil, err
+	}
+	entries := []archiveEntry{{databaseFile, data}}
+
+	if opts.UploadsDir != "" {
+		uploads, err := listUploads(opts.UploadsDir)
+		if err != nil {
+			return nil, err
+		}
+		listing, _ := json.MarshalIndent(uploads, "", "  ")
+		entries = append(entries, archiveEntry{uploadsFile, listing})
+		manifest.Uploads = len(uploads)
+	}
+	if opts.ConfigPath != "" {
+		cfg, err := os.ReadFile(opts.ConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		if opts.Redact {
+			if cfg, err = redactConfig(cfg); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, archiveEntry{configFile, cfg})
+	}
+
+	for _, entry := range entries {
+		manifest.Files = append(manifest.Files, entry.name)
+	}
+	manifestJSON, _ := json.MarshalIndent(manifest, "", "  ")
+	entries = append([]archiveEntry{{manifestFile, manifestJSON}}, entries...)
+
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0600, Size: int64(len(entry.data)), ModTime: manifest.CreatedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// RestoreOptions controls where an archive is restored to
+type RestoreOptions struct {
+	DSN        string // control-plane DSN whose database file is replaced
+	ConfigPath string // the archived config is written beside it as <path>.restored
+}
+
+// Restore replaces the control-plane database with the archived one. The
+// current database is kept as <file>.pre-restore-<time>. The server must be
+// stopped first. Secrets of a redacted archive have to be entered again.
+func Restore(r io.Reader, opts RestoreOptions) (*Manifest, error) {
+	target, err := sqlitePath(opts.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := readArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(files[manifestFile], &manifest); err != nil {
+		return nil, fmt.Errorf("archive has no valid %s: %w", manifestFile, err)
+	}
+	if manifest.Version > formatVersion {
+		return nil, fmt.Errorf("archive format %d is newer than this server supports (%d)", manifest.Version, formatVersion)
+	}
+	data, ok := files[databaseFile]
+	if !ok {
+		return nil, fmt.Errorf("archive has no %s", databaseFile)
+	}
+
+	staged := target + ".restoring"
+	if err := os.WriteFile(staged, data, 0600); err != nil {
+		return nil, err
+	}
+	if err := checkDatabase(staged); err != nil {
+		os.Remove(staged)
+		return nil, err
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		kept := fmt.Sprintf("%s.pre-restore-%s", target, time.Now().UTC().Format("20060102T150405Z"))
+		if err := os.Rename(target, kept); err != nil {
+			os.Remove(staged)
+			return nil, fmt.Errorf("failed to keep current database: %w", err)
+		}
+	}
+	if err := os.Rename(staged, target); err != nil {
+		return nil, fmt.Errorf("failed to restore database: %w", err)
+	}
+
+	if cfg, ok := files[configFile]; ok && opts.ConfigPath != "" {
+		if err := os.WriteFile(opts.ConfigPath+".restored", cfg, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write restored config: %w", err)
+		}
+	}
+	return &manifest, nil
+}
+
+// readArchive reads every regular file of a tar.gz into memory
+func readArchive(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Base(header.Name)] = data
+	}
+}
+
+// checkDatabase opens a restored database and checks it is an intact AIR
+// control plane
+func checkDatabase(path string) error {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("archived database does not open: %w", err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil || result != "ok" {
+		return fmt.Errorf("archived database failed its integrity check: %s", result)
+	}
+	if !db.Migrator().HasTable("reports") || !db.Migrator().HasTable("datasources") {
+		return fmt.Errorf("archived database is not an AIR control plane")
+	}
+	return nil
+}
+
+// redactDatabase blanks secrets in a database copy
+func redactDatabase(path string) error {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to open database copy: %w", err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	if db.Migrator().HasTable("datasources") {
+		var rows []struct {
+			ID  string
+			DSN string
+		}
+		if err := db.Table("datasources").Select("id, dsn").Scan(&rows).Error; err != nil {
+			return fmt.Errorf("failed to read datasources: %w", err)
+		}
+		for _, row := range rows {
+			if err := db.Table("datasources").Where("id = ?", row.ID).Update("dsn", RedactDSN(row.DSN)).Error; err != nil {
+				return fmt.Errorf("failed to redact datasource: %w", err)
+			}
+		}
+	}
+	secrets := map[string]string{"webhook_subscriptions": "secret", "report_notifications": "webhook_url"}
+	for table, column := range secrets {
+		if !db.Migrator().HasTable(table) {
+			continue
+		}
+		if err := db.Table(table).Where("1 = 1").Update(column, redacted).Error; err != nil {
+			return fmt.Errorf("failed to redact %s: %w", table, err)
+		}
+	}
+	return db.Exec("VACUUM").Error
+}
+
+// redactConfig blanks secret values and DSN passwords in a config file
+func redactConfig(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i].Value, node.Content[i+1]
+				switch {
+				case value.Kind != yaml.ScalarNode:
+				case secretConfigKeys[key] && value.Value != "":
+					value.Value = redacted
+				case key == "dsn":
+					value.Value = RedactDSN(value.Value)
+				}
+			}
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(&doc)
+	return yaml.Marshal(&doc)
+}
+
+// mysqlCredentials matches the user:password@ prefix of a MySQL DSN
+var mysqlCredentials = regexp.MustCompile(`^([^:@/]+):[^@]*@`)
+
+// RedactDSN replaces the password of a URL or MySQL-style DSN
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+		return dsn
+	}
+	return mysqlCredentials.ReplaceAllString(dsn, "${1}:"+redacted+"@")
+}
+
+// sqlitePath returns the database file of a SQLite DSN such as file:air.db?_fk=1
+func sqlitePath(dsn string) (string, error) {
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" || path == ":memory:" {
+		return "", fmt.Errorf("control-plane DSN %q has no database file", dsn)
+	}
+	return path, nil
+}
+
+// listUploads lists the files in dir; a missing directory lists none
+func listUploads(dir string) ([]Upload, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []Upload{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read uploads: %w", err)
+	}
+	uploads := make([]Upload, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		uploads = append(uploads, Upload{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime().UTC()})
+	}
+	return uploads, nil
+}
@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+	"gorm.io/gorm"
+)
+
+// archivePrefix starts the name of every archive the scheduler writes
+const archivePrefix = "air-backup-"
+
+// ArchiveName names an archive taken at t
+func ArchiveName(t time.Time) string {
+	return archivePrefix + t.UTC().Format("20060102T150405Z") + ".tar.gz"
+}
+
+// Scheduler takes backups on the configured interval, writing them to the
+// local backup directory and, when a bucket is set, to S3
+type Scheduler struct {
+	db         *gorm.DB
+	cfg        config.BackupConfig
+	configPath string
+	uploadsDir string
+	locks      *redis.Client
+}
+
+// NewScheduler creates a backup scheduler
+func NewScheduler(db *gorm.DB, cfg config.BackupConfig, configPath, uploadsDir string) *Scheduler {
+	return &Scheduler{db: db, cfg: cfg, configPath: configPath, uploadsDir: uploadsDir}
+}
+
+// SetLocks takes scheduled backups once across API replicas
+func (s *Scheduler) SetLocks(locks *redis.Client) {
+	s.locks = locks
+}
+
+// Start takes a backup every interval in the background. Backups run under a
+// lock so only one replica takes each one.
+func (s *Scheduler) Start() {
+	if s.cfg.Interval <= 0 {
+		return
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Backup scheduler started", map[string]interface{}{
+		"interval": s.cfg.Interval.String(),
+		"dir":      s.cfg.Dir,
+		"s3":       s.cfg.S3.Bucket != "",
+	})
+
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			err := s.locks.WithLock(context.Background(), "backup", time.Hour, func(ctx context.Context, _ int64) error {
+				_, err := s.Backup(ctx)
+				return err
+			})
+			if err != nil && !errors.Is(err, redis.ErrLockHeld) {
+				logger.LogError(logger.ServiceDB, "Scheduled backup failed", err)
+			}
+		}
+	}()
+}
+
+// Backup takes one backup and returns the local archive path
+func (s *Scheduler) Backup(ctx context.Context) (string, error) {
+	var buf bytes.Buffer
+	manifest, err := Write(s.db, &buf, Options{Redact: s.cfg.Redact, ConfigPath: s.configPath, UploadsDir: s.uploadsDir})
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.cfg.Dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := ArchiveName(manifest.CreatedAt)
+	path := filepath.Join(s.cfg.Dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	if s.cfg.S3.Bucket != "" {
+		if err := uploadS3(ctx, s.cfg.S3, name, buf.Bytes()); err != nil {
+			return path, err
+		}
+	}
+	s.prune()
+
+	logger.LogInfo(logger.ServiceDB, "Backup written", map[string]interface{}{
+		"path":     path,
+		"bytes":    buf.Len(),
+		"redacted": manifest.Redacted,
+		"s3":       s.cfg.S3.Bucket != "",
+	})
+	return path, nil
+}
+
+// prune deletes local archives beyond the newest Keep
+func (s *Scheduler) prune() {
+	if s.cfg.Keep <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return
+	}
+	var archives []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), archivePrefix) && strings.HasSuffix(entry.Name(), ".tar.gz") {
+			archives = append(archives, entry.Name())
+		}
+	}
+	sort.Strings(archives) // names sort by time
+	for len(archives) > s.cfg.Keep {
+		if err := os.Remove(filepath.Join(s.cfg.Dir, archives[0])); err != nil {
+			logger.LogWarn(logger.ServiceDB, "Failed to remove old backup", map[string]interface{}{
+				"name":  archives[0],
+				"error": err.Error(),
+			})
+		}
+		archives = archives[1:]
+	}
+}
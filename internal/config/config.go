@@ -2,8 +2,14 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/NubeDev/air/internal/secrets"
 	"github.com/spf13/viper"
 )
 
@@ -18,6 +24,18 @@ type Config struct {
 	Redis            RedisConfig             `mapstructure:"redis"`
 	WebSocket        WebSocketConfig         `mapstructure:"websocket"`
 	Chat             ChatConfig              `mapstructure:"chat"`
+	Privacy          PrivacyConfig           `mapstructure:"privacy"`
+	Snapshots        SnapshotsConfig         `mapstructure:"snapshots"`
+	Webhooks         WebhooksConfig          `mapstructure:"webhooks"`
+	Notifications    NotificationsConfig     `mapstructure:"notifications"`
+	MQTT             MQTTConfig              `mapstructure:"mqtt"`
+	Ingestion        IngestionConfig         `mapstructure:"ingestion"`
+	Freshness        FreshnessConfig         `mapstructure:"freshness"`
+	ParamEnums       ParamEnumsConfig        `mapstructure:"param_enums"`
+	Sharing          SharingConfig           `mapstructure:"sharing"`
+	WarehouseCost    WarehouseCostConfig     `mapstructure:"warehouse_cost"`
+	Retention        RetentionConfig         `mapstructure:"retention"`
+	Backup           BackupConfig            `mapstructure:"backup"`
 }
 
 // ServerConfig holds server configuration
@@ -25,6 +43,7 @@ type ServerConfig struct {
 	Host      string     `mapstructure:"host"`
 	Port      int        `mapstructure:"port"`
 	WSEnabled bool       `mapstructure:"ws_enabled"`
+	GRPCPort  int        `mapstructure:"grpc_port"` // serves the gRPC API beside HTTP; 0 disables it
 	Auth      AuthConfig `mapstructure:"auth"`
 }
 
@@ -33,6 +52,8 @@ type AuthConfig struct {
 	Enabled     bool          `mapstructure:"enabled"`
 	JWTSecret   string        `mapstructure:"jwt_secret"`
 	TokenExpiry time.Duration `mapstructure:"token_expiry"`
+	Admins      []string      `mapstructure:"admins"`    // usernames allowed on admin-only endpoints
+	Reviewers   []string      `mapstructure:"reviewers"` // usernames, beside admins, allowed to approve or reject report versions
 }
 
 // ControlPlaneConfig holds control plane database configuration
@@ -41,36 +62,177 @@ type ControlPlaneConfig struct {
 	DSN    string `mapstructure:"dsn"`
 }
 
+// sqlSourceKinds are the analytics source kinds with a SQL connector. The
+// built-in ones are listed; the datasource package adds connectors it
+// registers beyond those.
+var (
+	sqlSourceKinds   = map[string]bool{"postgres": true, "timescaledb": true, "mysql": true, "sqlite": true}
+	sqlSourceKindsMu sync.RWMutex
+)
+
+// sessionPropertyKinds are the source kinds whose connections take
+// session_properties
+var sessionPropertyKinds = map[string]bool{"trino": true, "postgres": true, "timescaledb": true, "mysql": true}
+
+var (
+	// sessionPropertyName is a setting name, optionally qualified, e.g. hive.compression_codec
+	sessionPropertyName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	// labelName and labelValue are the forms BigQuery accepts for job labels
+	labelName  = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	labelValue = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+)
+
+// RegisterSourceKind accepts kind as a SQL analytics source kind
+func RegisterSourceKind(kind string) {
+	sqlSourceKindsMu.Lock()
+	defer sqlSourceKindsMu.Unlock()
+	sqlSourceKinds[kind] = true
+}
+
+// SourceKinds returns the accepted SQL analytics source kinds in order
+func SourceKinds() []string {
+	sqlSourceKindsMu.RLock()
+	defer sqlSourceKindsMu.RUnlock()
+	kinds := make([]string, 0, len(sqlSourceKinds))
+	for kind := range sqlSourceKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
 // AnalyticsSourceConfig holds analytics database configuration
 type AnalyticsSourceConfig struct {
-	ID          string `mapstructure:"id"`
-	Kind        string `mapstructure:"kind"`
-	DSN         string `mapstructure:"dsn"`
-	DisplayName string `mapstructure:"display_name"`
-	Default     bool   `mapstructure:"default"`
+	ID                string               `mapstructure:"id"`
+	Kind              string               `mapstructure:"kind"`
+	DSN               string               `mapstructure:"dsn"`
+	DisplayName       string               `mapstructure:"display_name"`
+	Default           bool                 `mapstructure:"default"`
+	Timezone          string               `mapstructure:"timezone"`           // zone naive timestamps are stored in; UTC when empty
+	SQLGenerator      SQLGeneratorConfig   `mapstructure:"sql_generator"`      // overrides models.sql_generator
+	MaxRows           int                  `mapstructure:"max_rows"`           // overrides safety.max_row_limit for report results
+	MaxResultBytes    int                  `mapstructure:"max_result_bytes"`   // overrides safety.max_result_bytes
+	SSHTunnel         SSHTunnelConfig      `mapstructure:"ssh_tunnel"`         // reach the database through an SSH bastion
+	Proxy             string               `mapstructure:"proxy"`              // socks5://[user:pass@]host:port to dial the database through
+	Auth              DatasourceAuthConfig `mapstructure:"auth"`               // token auth in place of a password in the DSN
+	SessionProperties []string             `mapstructure:"session_properties"` // name=value settings of every session, e.g. statement_timeout=30s
+	Labels            []string             `mapstructure:"labels"`             // bigquery: name=value labels on every job
+	RequireApproval   bool                 `mapstructure:"require_approval"`   // report versions run or scheduled here need a reviewer's approval
+	Sandbox           string               `mapstructure:"sandbox"`            // id of a staging copy; runs here need a passed validate run on it
+	NoExternalAI      bool                 `mapstructure:"no_external_ai"`     // schema, samples and results go only to local (Ollama) models
+	AllowWrites       bool                 `mapstructure:"allow_writes"`       // open read-write and accept credentials that can modify data
+	Credentials       []CredentialConfig   `mapstructure:"credentials"`        // alternate sign-ins report versions can run as
+}
+
+// CredentialConfig is a named database user a report version can run as in
+// place of the datasource's, e.g. a narrowly-granted role for sensitive
+// reports. The password stays in the secrets layer.
+type CredentialConfig struct {
+	Name     string `mapstructure:"name"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"` // secret reference: env:NAME or file:PATH
+}
+
+// WritableSource reports whether an analytics source may be written to: it
+// sets allow_writes, or holds snapshot tables or ingested histories
+func (c *Config) WritableSource(id string) bool {
+	for _, source := range c.AnalyticsSources {
+		if source.ID == id && source.AllowWrites {
+			return true
+		}
+	}
+	if c.Snapshots.Enabled && c.Snapshots.DatasourceID == id {
+		return true
+	}
+	for _, history := range c.Ingestion.Histories {
+		if history.DatasourceID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// DatasourceAuthConfig signs in to a datasource with a short-lived token
+// fetched for each new connection instead of a password kept in the DSN
+type DatasourceAuthConfig struct {
+	Method     string `mapstructure:"method"`      // "" (DSN password), "aws_rds_iam", "gcp_iam" or "kerberos"
+	Region     string `mapstructure:"region"`      // aws_rds_iam region; AWS_REGION when empty
+	Principal  string `mapstructure:"principal"`   // kerberos user[@REALM]; the DSN user when empty
+	Realm      string `mapstructure:"realm"`       // kerberos realm; krb5.conf's default_realm when empty
+	Keytab     string `mapstructure:"keytab"`      // kerberos keytab; the credential cache (KRB5CCNAME) when empty
+	KRB5Config string `mapstructure:"krb5_config"` // krb5.conf path; KRB5_CONFIG or /etc/krb5.conf when empty
+}
+
+// SSHTunnelConfig holds the SSH bastion a datasource is reached through. The
+// tunnel is used when Host is set.
+type SSHTunnelConfig struct {
+	Host           string `mapstructure:"host"` // host[:port]; port 22 when omitted
+	User           string `mapstructure:"user"`
+	KeyFile        string `mapstructure:"key_file"`
+	KeyPassphrase  string `mapstructure:"key_passphrase"`   // for an encrypted key_file
+	KnownHostsFile string `mapstructure:"known_hosts_file"` // bastion host keys; ~/.ssh/known_hosts when empty
 }
 
 // ModelsConfig holds AI model configuration
 type ModelsConfig struct {
-	ChatPrimary string           `mapstructure:"chat_primary"`
-	ChatBackup  string           `mapstructure:"chat_backup"`
-	SQLPrimary  string           `mapstructure:"sql_primary"`
-	OpenAI      OpenAIConfig     `mapstructure:"openai"`
-	Ollama      OllamaConfig     `mapstructure:"ollama"`
-	Embeddings  EmbeddingsConfig `mapstructure:"embeddings"`
+	ChatPrimary  string             `mapstructure:"chat_primary"`
+	ChatBackup   string             `mapstructure:"chat_backup"`
+	SQLPrimary   string             `mapstructure:"sql_primary"`
+	OpenAI       OpenAIConfig       `mapstructure:"openai"`
+	Ollama       OllamaConfig       `mapstructure:"ollama"`
+	Mock         MockConfig         `mapstructure:"mock"`
+	Replay       ReplayConfig       `mapstructure:"replay"`
+	Embeddings   EmbeddingsConfig   `mapstructure:"embeddings"`
+	SQLGenerator SQLGeneratorConfig `mapstructure:"sql_generator"`
+	Seed         int                `mapstructure:"seed"` // fixed sampling seed for IR, SQL and analysis calls; 0 leaves sampling random
+	Timeouts     LLMTimeoutsConfig  `mapstructure:"timeouts"`
+}
+
+// LLMTimeoutsConfig bounds each kind of model call. A call also ends early
+// when the HTTP client that triggered it disconnects.
+type LLMTimeoutsConfig struct {
+	IRBuild     time.Duration `mapstructure:"ir_build"`
+	SQLGenerate time.Duration `mapstructure:"sql_generate"`
+	Analyze     time.Duration `mapstructure:"analyze"`
+	Chat        time.Duration `mapstructure:"chat"`
+}
+
+// SQLGeneratorConfig selects the backend that turns IR into SQL
+type SQLGeneratorConfig struct {
+	Type    string        `mapstructure:"type"`    // sqlcoder | openai | deterministic | http; empty follows sql_primary
+	URL     string        `mapstructure:"url"`     // endpoint for the http backend
+	Timeout time.Duration `mapstructure:"timeout"` // request timeout for the http backend
 }
 
 // OpenAIConfig holds OpenAI configuration
 type OpenAIConfig struct {
-	Model  string `mapstructure:"model"`
-	APIKey string `mapstructure:"api_key"`
+	Model       string `mapstructure:"model"`
+	APIKey      string `mapstructure:"api_key"`
+	MaxInFlight int    `mapstructure:"max_in_flight"` // concurrent calls before new ones queue
 }
 
 // OllamaConfig holds Ollama configuration
 type OllamaConfig struct {
-	Host          string `mapstructure:"host"`
-	Llama3Model   string `mapstructure:"llama3_model"`
-	SQLCoderModel string `mapstructure:"sqlcoder_model"`
+	Host          string        `mapstructure:"host"`
+	Llama3Model   string        `mapstructure:"llama3_model"`
+	SQLCoderModel string        `mapstructure:"sqlcoder_model"`
+	WarmUp        bool          `mapstructure:"warm_up"`       // load chat/SQL models at server start
+	KeepAlive     time.Duration `mapstructure:"keep_alive"`    // how long warmed models stay loaded
+	AutoPull      bool          `mapstructure:"auto_pull"`     // pull missing models during startup verification
+	MaxInFlight   int           `mapstructure:"max_in_flight"` // concurrent calls before new ones queue
+}
+
+// MockConfig configures the mock model provider, selected by routing
+// chat_primary or sql_primary to "mock"
+type MockConfig struct {
+	Fixtures string `mapstructure:"fixtures"` // YAML file of responses by prompt hash; built-in replies otherwise
+}
+
+// ReplayConfig records provider responses to disk, or answers from those
+// recordings instead of calling the provider
+type ReplayConfig struct {
+	Mode string `mapstructure:"mode"` // "" (off) | record | replay
+	Dir  string `mapstructure:"dir"`  // one JSON file per model and prompt hash
 }
 
 // EmbeddingsConfig holds embeddings configuration
@@ -81,9 +243,15 @@ type EmbeddingsConfig struct {
 
 // SafetyConfig holds safety guardrails configuration
 type SafetyConfig struct {
-	DefaultRowLimit       int `mapstructure:"default_row_limit"`
-	MaxRowLimit           int `mapstructure:"max_row_limit"`
-	EnforceTimeFilterDays int `mapstructure:"enforce_time_filter_days"`
+	DefaultRowLimit       int           `mapstructure:"default_row_limit"`
+	MaxRowLimit           int           `mapstructure:"max_row_limit"`
+	EnforceTimeFilterDays int           `mapstructure:"enforce_time_filter_days"`
+	RowEstimate           string        `mapstructure:"row_estimate"`        // "off", "warn" or "deny" when the estimate exceeds max_row_limit
+	MaxResultBytes        int           `mapstructure:"max_result_bytes"`    // JSON size a run's stored results are truncated to
+	SyncRunThreshold      time.Duration `mapstructure:"sync_run_threshold"`  // API runs still going after this finish in the background; 0 waits
+	RunTimeout            time.Duration `mapstructure:"run_timeout"`         // how long a run's query may take while its caller waits
+	AsyncRunTimeout       time.Duration `mapstructure:"async_run_timeout"`   // how long a run's query may take when it may finish in the background
+	FanOutConcurrency     int           `mapstructure:"fan_out_concurrency"` // datasources a fan-out run queries at once
 }
 
 // TelemetryConfig holds logging configuration
@@ -97,6 +265,7 @@ type TelemetryConfig struct {
 // RedisConfig holds Redis configuration
 type RedisConfig struct {
 	Enabled      bool          `mapstructure:"enabled"`
+	Required     bool          `mapstructure:"required"` // fail startup when Redis is unreachable instead of running degraded
 	URL          string        `mapstructure:"url"`
 	Password     string        `mapstructure:"password"`
 	DB           int           `mapstructure:"db"`
@@ -106,6 +275,7 @@ type RedisConfig struct {
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
 	PoolSize     int           `mapstructure:"pool_size"`
 	MinIdleConns int           `mapstructure:"min_idle_conns"`
+	KeyPrefix    string        `mapstructure:"key_prefix"` // namespace for keys, channels and locks when environments share a Redis
 }
 
 // WebSocketConfig holds WebSocket configuration
@@ -119,6 +289,15 @@ type WebSocketConfig struct {
 	PongWait          time.Duration `mapstructure:"pong_wait"`
 	MaxMessageSize    int64         `mapstructure:"max_message_size"`
 	EnableCompression bool          `mapstructure:"enable_compression"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"` // close connections that send nothing for this long; 0 disables
+	// Transport carries channel messages between hubs: "redis" across
+	// replicas, "memory" for a single node, or "auto" to use Redis when it is
+	// available
+	Transport string `mapstructure:"transport"`
+	// AllowedModels limits the models raw AI messages may request; empty
+	// allows the configured Ollama models and, when usable, the OpenAI model
+	AllowedModels       []string `mapstructure:"allowed_models"`
+	AIMessagesPerMinute int      `mapstructure:"ai_messages_per_minute"` // per user; 0 disables the quota
 }
 
 // ChatConfig holds live chat configuration
@@ -132,6 +311,146 @@ type ChatConfig struct {
 	AIResponseTimeout time.Duration `mapstructure:"ai_response_timeout"`
 }
 
+// PrivacyConfig holds data egress controls
+type PrivacyConfig struct {
+	// LocalOnly disables every external model provider (OpenAI chat and embeddings)
+	// so that no prompt or data leaves the host. Required for air-gapped installs.
+	LocalOnly bool `mapstructure:"local_only"`
+}
+
+// SnapshotsConfig holds materialized report snapshot configuration
+type SnapshotsConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	DatasourceID string        `mapstructure:"datasource_id"` // writable analytics source that holds snapshot tables
+	TablePrefix  string        `mapstructure:"table_prefix"`
+	PollInterval time.Duration `mapstructure:"poll_interval"` // how often the scheduler looks for due snapshots
+}
+
+// FreshnessConfig holds the data freshness monitor schedule; monitors are
+// managed via /v1/datasources/:id/freshness
+type FreshnessConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"` // how often every monitor is checked; 0 disables checks
+}
+
+// ParamEnumsConfig holds the cache of distinct column values offered as
+// report param enums
+type ParamEnumsConfig struct {
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // how often cached values are queried again; 0 disables refreshes
+	MaxValues       int           `mapstructure:"max_values"`       // columns with more distinct values get no enum
+}
+
+// SharingConfig holds public share links to report results
+type SharingConfig struct {
+	Secret      string        `mapstructure:"secret"`       // signs share tokens; server.auth.jwt_secret when empty
+	DefaultTTL  time.Duration `mapstructure:"default_ttl"`  // lifetime of a link created without one
+	MaxTTL      time.Duration `mapstructure:"max_ttl"`      // longest lifetime a link may ask for
+	LiveRefresh time.Duration `mapstructure:"live_refresh"` // how long a live link serves its last run before running again
+}
+
+// WarehouseCostConfig budgets report runs on warehouses that bill by the
+// bytes a query scans, such as BigQuery. Zero budgets are unlimited.
+type WarehouseCostConfig struct {
+	MaxRunBytes   int64   `mapstructure:"max_run_bytes"`   // a run estimated to scan more is refused
+	MaxDailyBytes int64   `mapstructure:"max_daily_bytes"` // bytes a datasource's report runs may scan per UTC day
+	PricePerTiB   float64 `mapstructure:"price_per_tib"`   // price of a tebibyte scanned, for the cost shown on runs
+}
+
+// RetentionConfig bounds how much run, trace and upload data the control
+// plane keeps. Zero values keep everything.
+type RetentionConfig struct {
+	Interval          time.Duration `mapstructure:"interval"`             // how often the cleanup worker runs; 0 disables it
+	KeepRunsPerReport int           `mapstructure:"keep_runs_per_report"` // newest runs kept per report
+	ResultsMaxAge     time.Duration `mapstructure:"results_max_age"`      // run results and samples are purged after this; metadata stays
+	TracesMaxAge      time.Duration `mapstructure:"traces_max_age"`       // AI traces are deleted after this
+	OrphanUploads     bool          `mapstructure:"orphan_uploads"`       // delete uploads no session, analysis or generated report uses
+	OrphanUploadAge   time.Duration `mapstructure:"orphan_upload_age"`    // grace period before an unused upload counts as orphaned
+}
+
+// BackupConfig schedules control-plane backups by the server; `air backup`
+// and `air restore` run them by hand
+type BackupConfig struct {
+	Interval time.Duration  `mapstructure:"interval"` // how often a backup is taken; 0 disables scheduled backups
+	Dir      string         `mapstructure:"dir"`      // local directory archives are written to
+	Keep     int            `mapstructure:"keep"`     // newest local archives kept; 0 keeps all
+	Redact   bool           `mapstructure:"redact"`   // blank DSN passwords, webhook secrets and config secrets
+	S3       BackupS3Config `mapstructure:"s3"`       // also upload each archive here when Bucket is set
+}
+
+// BackupS3Config is the bucket backups are uploaded to. Credentials come from
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+type BackupS3Config struct {
+	Bucket   string `mapstructure:"bucket"`
+	Region   string `mapstructure:"region"`   // AWS_REGION when empty
+	Endpoint string `mapstructure:"endpoint"` // S3-compatible endpoint, e.g. http://minio:9000; AWS when empty
+	Prefix   string `mapstructure:"prefix"`   // key prefix, e.g. "air/"
+}
+
+// WebhooksConfig holds outbound lifecycle webhook configuration
+type WebhooksConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	RetryDelay  time.Duration `mapstructure:"retry_delay"` // doubled after each failed attempt
+	// HealthInterval controls the datasource probe that emits datasource.unhealthy
+	HealthInterval time.Duration `mapstructure:"health_interval"`
+	// AllowPrivateTargets lets webhooks and notifiers post to loopback,
+	// private and link-local addresses
+	AllowPrivateTargets bool `mapstructure:"allow_private_targets"`
+}
+
+// NotificationsConfig holds chat notifier configuration
+type NotificationsConfig struct {
+	BaseURL string                 `mapstructure:"base_url"` // used to build report links in messages
+	Targets []NotifierTargetConfig `mapstructure:"targets"`  // global targets applied to every report
+}
+
+// NotifierTargetConfig describes a Slack or Teams incoming webhook
+type NotifierTargetConfig struct {
+	Name       string   `mapstructure:"name"`
+	Type       string   `mapstructure:"type"` // "slack" or "teams"
+	WebhookURL string   `mapstructure:"webhook_url"`
+	Events     []string `mapstructure:"events"`   // defaults to run completed/failed and report stale
+	Template   string   `mapstructure:"template"` // Go text/template; built-in default when empty
+}
+
+// MQTTConfig holds MQTT publishing configuration for on-site consumers
+type MQTTConfig struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	Broker             string        `mapstructure:"broker"` // tcp://host:1883 or ssl://host:8883
+	ClientID           string        `mapstructure:"client_id"`
+	Username           string        `mapstructure:"username"`
+	Password           string        `mapstructure:"password"`
+	QoS                int           `mapstructure:"qos"` // 0 or 1
+	Retain             bool          `mapstructure:"retain"`
+	Timeout            time.Duration `mapstructure:"timeout"`
+	TopicTemplate      string        `mapstructure:"topic_template"`       // Go template for results
+	AlertTopicTemplate string        `mapstructure:"alert_topic_template"` // Go template for alerts
+	PayloadFormat      string        `mapstructure:"payload_format"`       // "json", "summary" or "values"
+	MaxRows            int           `mapstructure:"max_rows"`             // rows included in "json" payloads
+}
+
+// IngestionConfig holds scheduled history ingestion from building automation systems
+type IngestionConfig struct {
+	PollInterval time.Duration         `mapstructure:"poll_interval"`
+	Histories    []HistorySourceConfig `mapstructure:"histories"`
+}
+
+// HistorySourceConfig describes a Niagara or Haystack endpoint whose point
+// histories are copied into an analytics source
+type HistorySourceConfig struct {
+	Name         string        `mapstructure:"name"`
+	Kind         string        `mapstructure:"kind"` // "haystack" or "niagara" (oBIX histories)
+	URL          string        `mapstructure:"url"`  // haystack API base or niagara /obix/histories/<station>
+	Username     string        `mapstructure:"username"`
+	Password     string        `mapstructure:"password"`
+	Token        string        `mapstructure:"token"`  // bearer token; takes precedence over username/password
+	Points       []string      `mapstructure:"points"` // haystack point ids or niagara history names
+	DatasourceID string        `mapstructure:"datasource_id"`
+	Table        string        `mapstructure:"table"`
+	Interval     time.Duration `mapstructure:"interval"`
+	Backfill     time.Duration `mapstructure:"backfill"` // history pulled on the first run of a point
+}
+
 // Load loads configuration from file and environment variables
 func Load(configPath string) (*Config, error) {
 	viper.SetConfigFile(configPath)
@@ -141,6 +460,7 @@ func Load(configPath string) (*Config, error) {
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("server.ws_enabled", true)
+	viper.SetDefault("server.grpc_port", 0)
 	viper.SetDefault("server.auth.enabled", true)
 	viper.SetDefault("server.auth.token_expiry", "24h")
 	viper.SetDefault("control_plane.driver", "sqlite")
@@ -149,14 +469,32 @@ func Load(configPath string) (*Config, error) {
 	viper.SetDefault("models.chat_backup", "llama3")
 	viper.SetDefault("models.sql_primary", "sqlcoder")
 	viper.SetDefault("models.openai.model", "gpt-4o-mini")
+	viper.SetDefault("models.openai.max_in_flight", 16)
 	viper.SetDefault("models.ollama.host", "http://localhost:11434")
 	viper.SetDefault("models.ollama.llama3_model", "llama3")
 	viper.SetDefault("models.ollama.sqlcoder_model", "sqlcoder")
+	viper.SetDefault("models.ollama.warm_up", true)
+	viper.SetDefault("models.ollama.keep_alive", "30m")
+	viper.SetDefault("models.ollama.auto_pull", false)
+	viper.SetDefault("models.ollama.max_in_flight", 4)
 	viper.SetDefault("models.embeddings.provider", "openai")
 	viper.SetDefault("models.embeddings.model", "text-embedding-3-small")
+	viper.SetDefault("models.sql_generator.timeout", "60s")
+	viper.SetDefault("models.seed", 0)
+	viper.SetDefault("models.replay.dir", "testdata/llm_recordings")
+	viper.SetDefault("models.timeouts.ir_build", "60s")
+	viper.SetDefault("models.timeouts.sql_generate", "60s")
+	viper.SetDefault("models.timeouts.analyze", "60s")
+	viper.SetDefault("models.timeouts.chat", "60s")
 	viper.SetDefault("safety.default_row_limit", 5000)
 	viper.SetDefault("safety.max_row_limit", 100000)
+	viper.SetDefault("safety.max_result_bytes", 10485760)
 	viper.SetDefault("safety.enforce_time_filter_days", 370)
+	viper.SetDefault("safety.row_estimate", "warn")
+	viper.SetDefault("safety.sync_run_threshold", "10s")
+	viper.SetDefault("safety.run_timeout", "60s")
+	viper.SetDefault("safety.async_run_timeout", "30m")
+	viper.SetDefault("safety.fan_out_concurrency", 4)
 	viper.SetDefault("telemetry.level", "info")
 	viper.SetDefault("telemetry.format", "console")
 	viper.SetDefault("telemetry.time_format", "15:04:05")
@@ -164,6 +502,7 @@ func Load(configPath string) (*Config, error) {
 
 	// Redis defaults
 	viper.SetDefault("redis.enabled", true)
+	viper.SetDefault("redis.required", false)
 	viper.SetDefault("redis.url", "redis://localhost:6379/0")
 	viper.SetDefault("redis.password", "")
 	viper.SetDefault("redis.db", 0)
@@ -173,6 +512,7 @@ func Load(configPath string) (*Config, error) {
 	viper.SetDefault("redis.write_timeout", "3s")
 	viper.SetDefault("redis.pool_size", 10)
 	viper.SetDefault("redis.min_idle_conns", 5)
+	viper.SetDefault("redis.key_prefix", "")
 
 	// WebSocket defaults
 	viper.SetDefault("websocket.enabled", true)
@@ -184,6 +524,9 @@ func Load(configPath string) (*Config, error) {
 	viper.SetDefault("websocket.pong_wait", "60s")
 	viper.SetDefault("websocket.max_message_size", 512)
 	viper.SetDefault("websocket.enable_compression", true)
+	viper.SetDefault("websocket.idle_timeout", "30m")
+	viper.SetDefault("websocket.transport", "auto")
+	viper.SetDefault("websocket.ai_messages_per_minute", 20)
 
 	// Chat defaults
 	viper.SetDefault("chat.enabled", true)
@@ -194,6 +537,61 @@ func Load(configPath string) (*Config, error) {
 	viper.SetDefault("chat.ai_streaming", true)
 	viper.SetDefault("chat.ai_response_timeout", "30s")
 
+	// Privacy defaults
+	viper.SetDefault("privacy.local_only", false)
+
+	// Snapshot defaults
+	viper.SetDefault("snapshots.enabled", false)
+	viper.SetDefault("snapshots.table_prefix", "air_snapshot_")
+	viper.SetDefault("snapshots.poll_interval", "1m")
+
+	// Freshness defaults
+	viper.SetDefault("freshness.poll_interval", "5m")
+
+	// Param enum defaults
+	viper.SetDefault("param_enums.refresh_interval", "1h")
+	viper.SetDefault("param_enums.max_values", 200)
+
+	// Sharing defaults
+	viper.SetDefault("sharing.default_ttl", "168h")
+	viper.SetDefault("sharing.max_ttl", "2160h")
+	viper.SetDefault("sharing.live_refresh", "15m")
+
+	// Warehouse cost defaults
+	viper.SetDefault("warehouse_cost.price_per_tib", 6.25)
+
+	// Retention defaults
+	viper.SetDefault("retention.interval", "24h")
+	viper.SetDefault("retention.orphan_upload_age", "24h")
+
+	// Backup defaults
+	viper.SetDefault("backup.interval", "0")
+	viper.SetDefault("backup.dir", "backups")
+	viper.SetDefault("backup.keep", 7)
+	viper.SetDefault("backup.redact", true)
+
+	// MQTT defaults
+	viper.SetDefault("mqtt.enabled", false)
+	viper.SetDefault("mqtt.client_id", "air")
+	viper.SetDefault("mqtt.qos", 1)
+	viper.SetDefault("mqtt.retain", true)
+	viper.SetDefault("mqtt.timeout", "10s")
+	viper.SetDefault("mqtt.topic_template", "air/reports/{{.ReportKey}}/results")
+	viper.SetDefault("mqtt.alert_topic_template", "air/alerts/{{.ReportKey}}")
+	viper.SetDefault("mqtt.payload_format", "json")
+	viper.SetDefault("mqtt.max_rows", 1000)
+
+	// Ingestion defaults
+	viper.SetDefault("ingestion.poll_interval", "1m")
+
+	// Webhook defaults
+	viper.SetDefault("webhooks.enabled", true)
+	viper.SetDefault("webhooks.max_attempts", 5)
+	viper.SetDefault("webhooks.timeout", "10s")
+	viper.SetDefault("webhooks.allow_private_targets", false)
+	viper.SetDefault("webhooks.retry_delay", "30s")
+	viper.SetDefault("webhooks.health_interval", "1m")
+
 	// Enable reading from environment variables
 	viper.AutomaticEnv()
 
@@ -227,18 +625,18 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Server.GRPCPort < 0 || c.Server.GRPCPort > 65535 {
+		return fmt.Errorf("server.grpc_port must be between 0 and 65535")
+	}
+	if c.Server.GRPCPort != 0 && c.Server.GRPCPort == c.Server.Port {
+		return fmt.Errorf("server.grpc_port must differ from server.port")
+	}
+
 	if len(c.AnalyticsSources) == 0 {
 		return fmt.Errorf("at least one analytics source is required")
 	}
 
 	// Validate each analytics source
-	validKinds := map[string]bool{
-		"postgres":    true,
-		"timescaledb": true,
-		"mysql":       true,
-		"sqlite":      true,
-		"files":       true,
-	}
 
 	ids := make(map[string]bool)
 	defaultCount := 0
@@ -253,12 +651,12 @@ func (c *Config) Validate() error {
 		}
 		ids[source.ID] = true
 
-		if !validKinds[source.Kind] {
-			return fmt.Errorf("analytics_sources[%d].kind must be one of: postgres, timescaledb, mysql, sqlite, files", i)
+		if source.Kind != "files" && !sqlSourceKinds[source.Kind] {
+			return fmt.Errorf("analytics_sources[%d].kind must be one of: %s, files", i, strings.Join(SourceKinds(), ", "))
 		}
 
 		// DSN required for SQL engines; files uses base_path
-		if (source.Kind == "postgres" || source.Kind == "timescaledb" || source.Kind == "mysql" || source.Kind == "sqlite") && source.DSN == "" {
+		if sqlSourceKinds[source.Kind] && source.DSN == "" {
 			return fmt.Errorf("analytics_sources[%d].dsn is required for kind %s", i, source.Kind)
 		}
 
@@ -266,9 +664,133 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("analytics_sources[%d].display_name is required", i)
 		}
 
+		if source.Timezone != "" {
+			if _, err := time.LoadLocation(source.Timezone); err != nil {
+				return fmt.Errorf("analytics_sources[%d].timezone is invalid: %w", i, err)
+			}
+		}
+
+		if source.MaxRows < 0 || source.MaxResultBytes < 0 {
+			return fmt.Errorf("analytics_sources[%d] max_rows and max_result_bytes must not be negative", i)
+		}
+
+		if err := source.validateRoute(fmt.Sprintf("analytics_sources[%d]", i)); err != nil {
+			return err
+		}
+
+		switch source.Auth.Method {
+		case "":
+		case "aws_rds_iam", "gcp_iam":
+			if source.Kind != "postgres" && source.Kind != "timescaledb" && source.Kind != "mysql" {
+				return fmt.Errorf("analytics_sources[%d].auth is only supported for postgres, timescaledb and mysql", i)
+			}
+		case "kerberos":
+			if source.Kind != "postgres" && source.Kind != "timescaledb" {
+				return fmt.Errorf("analytics_sources[%d].auth kerberos is only supported for postgres and timescaledb", i)
+			}
+		default:
+			return fmt.Errorf("analytics_sources[%d].auth.method must be one of: aws_rds_iam, gcp_iam, kerberos", i)
+		}
+
+		credentials := make(map[string]bool, len(source.Credentials))
+		for j, credential := range source.Credentials {
+			if source.Kind != "postgres" && source.Kind != "timescaledb" && source.Kind != "mysql" {
+				return fmt.Errorf("analytics_sources[%d].credentials is only supported for postgres, timescaledb and mysql", i)
+			}
+			if credential.Name == "" || credential.User == "" {
+				return fmt.Errorf("analytics_sources[%d].credentials[%d] needs a name and user", i, j)
+			}
+			if credentials[credential.Name] {
+				return fmt.Errorf("analytics_sources[%d] has duplicate credential %q", i, credential.Name)
+			}
+			credentials[credential.Name] = true
+			if err := secrets.Check(credential.Password); err != nil {
+				return fmt.Errorf("analytics_sources[%d].credentials[%d].password: %w", i, j, err)
+			}
+		}
+
+		if len(source.SessionProperties) > 0 && !sessionPropertyKinds[source.Kind] {
+			return fmt.Errorf("analytics_sources[%d].session_properties is only supported for trino, postgres, timescaledb and mysql", i)
+		}
+		for _, prop := range source.SessionProperties {
+			if name, _, ok := strings.Cut(prop, "="); !ok || !sessionPropertyName.MatchString(strings.TrimSpace(name)) {
+				return fmt.Errorf("analytics_sources[%d].session_properties entry %q must look like name=value", i, prop)
+			}
+		}
+
+		if len(source.Labels) > 0 && source.Kind != "bigquery" {
+			return fmt.Errorf("analytics_sources[%d].labels is only supported for bigquery", i)
+		}
+		for _, label := range source.Labels {
+			name, value, ok := strings.Cut(label, "=")
+			if !ok || !labelName.MatchString(strings.TrimSpace(name)) || !labelValue.MatchString(strings.TrimSpace(value)) {
+				return fmt.Errorf("analytics_sources[%d].labels entry %q must look like name=value in lowercase letters, digits, _ and -", i, label)
+			}
+		}
+
 		if source.Default {
 			defaultCount++
 		}
+
+		if err := source.SQLGenerator.validate(fmt.Sprintf("analytics_sources[%d].sql_generator", i)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Models.SQLGenerator.validate("models.sql_generator"); err != nil {
+		return err
+	}
+
+	if c.Models.OpenAI.MaxInFlight < 0 || c.Models.Ollama.MaxInFlight < 0 {
+		return fmt.Errorf("models max_in_flight must not be negative")
+	}
+
+	switch c.Models.Replay.Mode {
+	case "", "record", "replay":
+	default:
+		return fmt.Errorf("models.replay.mode must be record or replay, got %q", c.Models.Replay.Mode)
+	}
+	if c.Models.Replay.Mode != "" && c.Models.Replay.Dir == "" {
+		return fmt.Errorf("models.replay.dir is required when models.replay.mode is set")
+	}
+
+	for name, timeout := range map[string]time.Duration{
+		"ir_build":     c.Models.Timeouts.IRBuild,
+		"sql_generate": c.Models.Timeouts.SQLGenerate,
+		"analyze":      c.Models.Timeouts.Analyze,
+		"chat":         c.Models.Timeouts.Chat,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("models.timeouts.%s must not be negative", name)
+		}
+	}
+
+	switch c.Safety.RowEstimate {
+	case "", "off", "warn", "deny":
+	default:
+		return fmt.Errorf("safety.row_estimate must be one of: off, warn, deny")
+	}
+	if c.Safety.SyncRunThreshold < 0 {
+		return fmt.Errorf("safety.sync_run_threshold must not be negative")
+	}
+	if c.Safety.RunTimeout < 0 || c.Safety.AsyncRunTimeout < 0 {
+		return fmt.Errorf("safety.run_timeout and safety.async_run_timeout must not be negative")
+	}
+	if c.Safety.FanOutConcurrency < 1 {
+		return fmt.Errorf("safety.fan_out_concurrency must be at least 1")
+	}
+	if c.WarehouseCost.MaxRunBytes < 0 || c.WarehouseCost.MaxDailyBytes < 0 || c.WarehouseCost.PricePerTiB < 0 {
+		return fmt.Errorf("warehouse_cost budgets and price must not be negative")
+	}
+
+	if c.WebSocket.IdleTimeout < 0 {
+		return fmt.Errorf("websocket.idle_timeout must not be negative")
+	}
+
+	switch c.WebSocket.Transport {
+	case "", "auto", "memory", "redis":
+	default:
+		return fmt.Errorf("websocket.transport must be one of: auto, memory, redis")
 	}
 
 	if defaultCount == 0 {
@@ -279,14 +801,165 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("only one analytics source can be marked as default")
 	}
 
+	if c.Snapshots.Enabled {
+		if c.Snapshots.DatasourceID == "" {
+			return fmt.Errorf("snapshots.datasource_id is required when snapshots are enabled")
+		}
+		if !ids[c.Snapshots.DatasourceID] {
+			return fmt.Errorf("snapshots.datasource_id %q is not a configured analytics source", c.Snapshots.DatasourceID)
+		}
+		if c.Snapshots.PollInterval <= 0 {
+			return fmt.Errorf("snapshots.poll_interval must be positive")
+		}
+	}
+
+	for i, target := range c.Notifications.Targets {
+		if target.Type != "slack" && target.Type != "teams" {
+			return fmt.Errorf("notifications.targets[%d].type must be one of: slack, teams", i)
+		}
+		if target.WebhookURL == "" {
+			return fmt.Errorf("notifications.targets[%d].webhook_url is required", i)
+		}
+	}
+
+	if c.MQTT.Enabled {
+		if c.MQTT.Broker == "" {
+			return fmt.Errorf("mqtt.broker is required when mqtt is enabled")
+		}
+		if c.MQTT.QoS != 0 && c.MQTT.QoS != 1 {
+			return fmt.Errorf("mqtt.qos must be 0 or 1")
+		}
+		switch c.MQTT.PayloadFormat {
+		case "json", "summary", "values":
+		default:
+			return fmt.Errorf("mqtt.payload_format must be one of: json, summary, values")
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, source := range c.Ingestion.Histories {
+		if source.Name == "" {
+			return fmt.Errorf("ingestion.histories[%d].name is required", i)
+		}
+		if names[source.Name] {
+			return fmt.Errorf("duplicate ingestion history source name: %s", source.Name)
+		}
+		names[source.Name] = true
+		if source.Kind != "haystack" && source.Kind != "niagara" {
+			return fmt.Errorf("ingestion.histories[%d].kind must be one of: haystack, niagara", i)
+		}
+		if source.URL == "" {
+			return fmt.Errorf("ingestion.histories[%d].url is required", i)
+		}
+		if len(source.Points) == 0 {
+			return fmt.Errorf("ingestion.histories[%d].points must list at least one point", i)
+		}
+		if !ids[source.DatasourceID] {
+			return fmt.Errorf("ingestion.histories[%d].datasource_id %q is not a configured analytics source", i, source.DatasourceID)
+		}
+	}
+	if len(c.Ingestion.Histories) > 0 && c.Ingestion.PollInterval <= 0 {
+		return fmt.Errorf("ingestion.poll_interval must be positive")
+	}
+
+	if c.Webhooks.Enabled && c.Webhooks.MaxAttempts < 1 {
+		return fmt.Errorf("webhooks.max_attempts must be at least 1")
+	}
+
+	if c.Privacy.LocalOnly {
+		if err := c.validateLocalOnly(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// validateLocalOnly ensures all model routing points to Ollama when local-only mode is on
+func (c *Config) validateLocalOnly() error {
+	type route struct {
+		key   string
+		value string
+	}
+	routes := []route{
+		{"models.chat_primary", c.Models.ChatPrimary},
+		{"models.chat_backup", c.Models.ChatBackup},
+		{"models.sql_primary", c.Models.SQLPrimary},
+		{"models.sql_generator.type", c.Models.SQLGenerator.Type},
+	}
+	for _, source := range c.AnalyticsSources {
+		routes = append(routes, route{fmt.Sprintf("analytics_sources[%s].sql_generator.type", source.ID), source.SQLGenerator.Type})
+	}
+	for _, route := range routes {
+		if strings.EqualFold(route.value, "openai") {
+			return fmt.Errorf("%s cannot be \"openai\" when privacy.local_only is enabled", route.key)
+		}
+	}
+
+	if c.Models.Embeddings.Provider != "" && !strings.EqualFold(c.Models.Embeddings.Provider, "ollama") {
+		return fmt.Errorf("models.embeddings.provider must be \"ollama\" when privacy.local_only is enabled")
+	}
+
+	if c.Models.Ollama.Host == "" {
+		return fmt.Errorf("models.ollama.host is required when privacy.local_only is enabled")
+	}
+
+	return nil
+}
+
+// validate checks the generator type and its required settings
+func (g SQLGeneratorConfig) validate(key string) error {
+	switch strings.ToLower(g.Type) {
+	case "", "sqlcoder", "openai", "deterministic":
+		return nil
+	case "http":
+		if g.URL == "" {
+			return fmt.Errorf("%s.url is required for the http generator", key)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s.type must be one of: sqlcoder, openai, deterministic, http", key)
+	}
+}
+
+// validateRoute checks the SSH tunnel and proxy a source is reached through
+func (s AnalyticsSourceConfig) validateRoute(key string) error {
+	if s.SSHTunnel.Host == "" && s.Proxy == "" {
+		return nil
+	}
+	if s.SSHTunnel.Host != "" && s.Proxy != "" {
+		return fmt.Errorf("%s may set ssh_tunnel or proxy, not both", key)
+	}
+	if s.Kind == "sqlite" {
+		return fmt.Errorf("%s is a local sqlite file and cannot use ssh_tunnel or proxy", key)
+	}
+	if s.SSHTunnel.Host != "" && (s.SSHTunnel.User == "" || s.SSHTunnel.KeyFile == "") {
+		return fmt.Errorf("%s.ssh_tunnel.user and key_file are required", key)
+	}
+	if s.Proxy != "" {
+		u, err := url.Parse(s.Proxy)
+		if err != nil || (u.Scheme != "socks5" && u.Scheme != "socks5h") || u.Host == "" {
+			return fmt.Errorf("%s.proxy must look like socks5://host:port", key)
+		}
+	}
+	return nil
+}
+
+// ConfigFile returns the path of the config file Load read
+func ConfigFile() string {
+	return viper.ConfigFileUsed()
+}
+
 // GetServerAddr returns the server address
 func (c *Config) GetServerAddr() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
 
+// GetGRPCAddr returns the gRPC server address
+func (c *Config) GetGRPCAddr() string {
+	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.GRPCPort)
+}
+
 // GetDefaultDatasource returns the default analytics source
 func (c *Config) GetDefaultDatasource() *AnalyticsSourceConfig {
 	for _, source := range c.AnalyticsSources {
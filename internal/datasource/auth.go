@@ -0,0 +1,283 @@
+package datasource
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// tokenSource supplies the password for a new database connection
+type tokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// newTokenSource returns the token source a source's auth method asks for,
+// or nil when the DSN carries the password or, for kerberos, lib/pq signs in
+// through the registered GSS provider
+func newTokenSource(source config.AnalyticsSourceConfig) (tokenSource, error) {
+	switch source.Auth.Method {
+	case "":
+		return nil, nil
+	case "kerberos":
+		return nil, registerKerberos(source)
+	case "aws_rds_iam":
+		addr, user, err := dsnEndpoint(source.Kind, source.DSN)
+		if err != nil {
+			return nil, err
+		}
+		region := source.Auth.Region
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			return nil, fmt.Errorf("aws_rds_iam: auth.region or AWS_REGION is required")
+		}
+		return &rdsIAMTokens{addr: addr, user: user, region: region}, nil
+	case "gcp_iam":
+		return &gcpIAMTokens{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported auth method: %s", source.Auth.Method)
+	}
+}
+
+// rdsIAMTokens signs AWS RDS IAM auth tokens. A token is a SigV4 presigned
+// connect request, valid for 15 minutes; signing is local, so each new
+// connection simply gets a new one. Credentials come from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and, for temporary credentials, AWS_SESSION_TOKEN.
+type rdsIAMTokens struct {
+	addr   string
+	user   string
+	region string
+}
+
+// Token signs a token for the source's endpoint and user
+func (t *rdsIAMTokens) Token(ctx context.Context) (string, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return "", fmt.Errorf("aws_rds_iam: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+	return signRDSToken(t.addr, t.region, t.user, accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), time.Now().UTC()), nil
+}
+
+// signRDSToken presigns GET https://addr/?Action=connect&DBUser=user for the
+// rds-db service and returns it without the scheme
+func signRDSToken(addr, region, user, accessKey, secretKey, sessionToken string, now time.Time) string {
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	scope := date + "/" + region + "/rds-db/aws4_request"
+
+	params := map[string]string{
+		"Action":              "connect",
+		"DBUser":              user,
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    accessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       "900",
+		"X-Amz-SignedHeaders": "host",
+	}
+	if sessionToken != "" {
+		params["X-Amz-Security-Token"] = sessionToken
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = awsEscape(k) + "=" + awsEscape(params[k])
+	}
+	query := strings.Join(pairs, "&")
+
+	emptyHash := sha256.Sum256(nil)
+	canonical := strings.Join([]string{
+		"GET", "/", query, "host:" + addr + "\n", "host", hex.EncodeToString(emptyHash[:]),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "rds-db", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return addr + "/?" + query + "&X-Amz-Signature=" + signature
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscape percent-encodes everything but unreserved characters, as SigV4
+// requires
+func awsEscape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// gcpIAMTokens fetches OAuth access tokens of the instance's service account
+// from the GCP metadata server, for Cloud SQL IAM database authentication.
+// Tokens are reused until a minute before they expire. GCE_METADATA_HOST
+// overrides the metadata server address.
+type gcpIAMTokens struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token returns the cached access token or fetches a new one
+func (t *gcpIAMTokens) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("gcp_iam: failed to fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gcp_iam: metadata server returned %s", resp.Status)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("gcp_iam: failed to decode access token: %w", err)
+	}
+	t.token = body.AccessToken
+	t.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - time.Minute)
+	return t.token, nil
+}
+
+// dsnEndpoint returns the host:port and user a DSN connects with
+func dsnEndpoint(kind, dsn string) (addr, user string, err error) {
+	if kind == "mysql" {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return "", "", err
+		}
+		return cfg.Addr, cfg.User, nil
+	}
+
+	opts, err := pgOptions(dsn)
+	if err != nil {
+		return "", "", err
+	}
+	host, port := opts["host"], opts["port"]
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = "5432"
+	}
+	return net.JoinHostPort(host, port), opts["user"], nil
+}
+
+// pgOptions parses a postgres URL or key=value DSN into its settings
+func pgOptions(dsn string) (map[string]string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		converted, err := pq.ParseURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+		dsn = converted
+	}
+
+	opts := make(map[string]string)
+	rest := strings.TrimSpace(dsn)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid postgres dsn setting %q", rest)
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = strings.TrimLeft(rest[eq+1:], " ")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, "'") {
+			i := 1
+			for ; i < len(rest) && rest[i] != '\''; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			if i >= len(rest) {
+				return nil, fmt.Errorf("unterminated quote in postgres dsn setting %q", key)
+			}
+			rest = rest[i+1:]
+		} else {
+			end := strings.IndexByte(rest, ' ')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(rest[:end])
+			rest = rest[end:]
+		}
+		opts[key] = value.String()
+		rest = strings.TrimLeft(rest, " ")
+	}
+	return opts, nil
+}
+
+// pgWithPassword returns dsn in key=value form with password set, which
+// takes precedence over any password already in it
+func pgWithPassword(dsn, password string) (string, error) {
+	return pgWithSetting(dsn, "password", password)
+}
+
+// pgWithSetting returns dsn in key=value form with name set to value. Later
+// keys take precedence, and lib/pq sends keys it doesn't know to the server
+// as run-time parameters.
+func pgWithSetting(dsn, name, value string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		converted, err := pq.ParseURL(dsn)
+		if err != nil {
+			return "", err
+		}
+		dsn = converted
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return dsn + " " + name + "='" + escaped + "'", nil
+}
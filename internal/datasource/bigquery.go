@@ -0,0 +1,751 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BigQuery is queried over its REST API (jobs.query, then getQueryResults
+// for further pages), as the Google client libraries are not dependencies
+// of this module. It bills by the bytes a query scans, so the connector
+// offers dry runs to estimate them and reports what each query was billed.
+
+func init() {
+	Register("bigquery", func() Connector { return bigqueryConnector{} })
+}
+
+const (
+	bigqueryEndpoint = "https://bigquery.googleapis.com"
+	bigqueryPageSize = 10000 // rows per page
+	bigqueryWaitMs   = 10000 // how long one request waits for a job to complete
+)
+
+// bigqueryConfig is a parsed BigQuery DSN:
+// bigquery://[token@]project/dataset?location=EU&endpoint=https://...
+// The token is a static OAuth access token; sources normally set auth.method
+// gcp_iam instead, so tokens come from the metadata server.
+type bigqueryConfig struct {
+	endpoint string
+	project  string
+	dataset  string
+	location string
+	token    string
+	labels   map[string]string // job labels from the datasource's config
+}
+
+// parseBigQueryDSN parses a BigQuery DSN; the project is required, as jobs
+// run and are billed in it
+func parseBigQueryDSN(dsn string) (*bigqueryConfig, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("bigquery: invalid dsn: %w", err)
+	}
+	if u.Scheme != "bigquery" {
+		return nil, fmt.Errorf("bigquery: dsn must start with bigquery://")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("bigquery: dsn must name a project")
+	}
+	cfg := &bigqueryConfig{
+		endpoint: bigqueryEndpoint,
+		project:  u.Host,
+		dataset:  strings.Trim(u.Path, "/"),
+		location: u.Query().Get("location"),
+	}
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		cfg.endpoint = strings.TrimRight(endpoint, "/")
+	}
+	if u.User != nil {
+		cfg.token = u.User.Username()
+	}
+	return cfg, nil
+}
+
+// bigqueryConnector serves BigQuery
+type bigqueryConnector struct{}
+
+// Open opens a pool of BigQuery REST clients. A Password supplies the OAuth
+// access token of each request, replacing any token in the DSN; Labels are
+// set on every job.
+func (bigqueryConnector) Open(dsn string, opts OpenOptions) (*sql.DB, error) {
+	cfg, err := parseBigQueryDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.labels = opts.Labels
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Dial != nil {
+		transport.Proxy = nil
+		transport.DialContext = opts.Dial
+	}
+	return sql.OpenDB(&bigqueryDriverConnector{
+		cfg:    cfg,
+		client: &http.Client{Transport: transport},
+		token:  opts.Password,
+	}), nil
+}
+
+// Introspect lists the tables and views of the given datasets, each either
+// "dataset" in the DSN's project or "project.dataset", with one query per
+// dataset. Without datasets the DSN's dataset is read. Tables outside it are
+// named dataset.table, or project.dataset.table in another project.
+func (bigqueryConnector) Introspect(ctx context.Context, db *sql.DB, schemas []string) ([]Table, error) {
+	var project, dataset string
+	err := withBigQueryConn(ctx, db, func(c *bigqueryConn) error {
+		project, dataset = c.cfg.project, c.cfg.dataset
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(schemas) == 0 {
+		if dataset == "" {
+			return nil, fmt.Errorf("bigquery: set a dataset in the dsn, or name the datasets to learn")
+		}
+		schemas = []string{dataset}
+	}
+
+	var tables []Table
+	for _, name := range schemas {
+		proj, ds := project, name
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			proj, ds = name[:i], name[i+1:]
+		}
+		rows, err := db.QueryContext(ctx, `
+			SELECT table_name, column_name, data_type, is_nullable
+			FROM `+bigqueryQuote(proj+"."+ds)+`.INFORMATION_SCHEMA.COLUMNS
+			ORDER BY table_name, ordinal_position`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query columns: %w", err)
+		}
+		prefix := ""
+		if proj != project {
+			prefix = proj + "." + ds + "."
+		} else if ds != dataset {
+			prefix = ds + "."
+		}
+		for rows.Next() {
+			var table string
+			var col Column
+			if err := rows.Scan(&table, &col.Name, &col.Type, &col.Nullable); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan column: %w", err)
+			}
+			if n := len(tables); n == 0 || tables[n-1].Name != prefix+table {
+				tables = append(tables, Table{Name: prefix + table})
+			}
+			tables[len(tables)-1].Columns = append(tables[len(tables)-1].Columns, col)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// Dialect is "bigquery"
+func (bigqueryConnector) Dialect() string {
+	return "bigquery"
+}
+
+// Quote quotes an identifier with backticks
+func (bigqueryConnector) Quote(ident string) string {
+	return bigqueryQuote(ident)
+}
+
+func bigqueryQuote(ident string) string {
+	return "`" + strings.ReplaceAll(ident, "`", "\\`") + "`"
+}
+
+// Explain has no row estimate to offer: a dry run reports bytes, not rows
+func (bigqueryConnector) Explain(ctx context.Context, db *sql.DB, query string) (int64, error) {
+	return 0, ErrNoEstimate
+}
+
+// EstimateScan dry-runs query and returns the bytes it would process
+func (bigqueryConnector) EstimateScan(ctx context.Context, db *sql.DB, query string) (int64, error) {
+	var bytes int64
+	err := withBigQueryConn(ctx, db, func(c *bigqueryConn) error {
+		resp, err := c.query(ctx, query, nil, true)
+		if err != nil {
+			return err
+		}
+		bytes = resp.TotalBytesProcessed.int64()
+		return nil
+	})
+	return bytes, err
+}
+
+// withBigQueryConn calls fn with one of the pool's connections
+func withBigQueryConn(ctx context.Context, db *sql.DB, fn func(c *bigqueryConn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*bigqueryConn)
+		if !ok {
+			return fmt.Errorf("bigquery: unexpected connection type %T", driverConn)
+		}
+		return fn(c)
+	})
+}
+
+// bigqueryDriverConnector opens BigQuery connections, which are HTTP clients
+// sharing a transport
+type bigqueryDriverConnector struct {
+	cfg    *bigqueryConfig
+	client *http.Client
+	token  func(ctx context.Context) (string, error)
+}
+
+// Connect returns a new connection; tokens are fetched per request since
+// they expire sooner than pooled connections
+func (c *bigqueryDriverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &bigqueryConn{cfg: c.cfg, client: c.client, token: c.token}, nil
+}
+
+// Driver returns the BigQuery driver
+func (c *bigqueryDriverConnector) Driver() driver.Driver {
+	return bigqueryDriver{}
+}
+
+// bigqueryDriver opens connections from a DSN
+type bigqueryDriver struct{}
+
+// Open connects with dsn
+func (bigqueryDriver) Open(dsn string) (driver.Conn, error) {
+	cfg, err := parseBigQueryDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c := &bigqueryDriverConnector{cfg: cfg, client: &http.Client{}}
+	return c.Connect(context.Background())
+}
+
+// errBigQueryTx is returned by Begin; queries run as independent jobs
+var errBigQueryTx = errors.New("bigquery: transactions are not supported")
+
+// bigqueryConn runs queries as jobs over HTTP. It holds no server-side
+// state, so closing it is free.
+type bigqueryConn struct {
+	cfg    *bigqueryConfig
+	client *http.Client
+	token  func(ctx context.Context) (string, error)
+}
+
+// Prepare returns a statement that is sent when executed
+func (c *bigqueryConn) Prepare(query string) (driver.Stmt, error) {
+	return &bigqueryStmt{conn: c, query: query}, nil
+}
+
+// Close does nothing
+func (c *bigqueryConn) Close() error {
+	return nil
+}
+
+// Begin fails; see errBigQueryTx
+func (c *bigqueryConn) Begin() (driver.Tx, error) {
+	return nil, errBigQueryTx
+}
+
+// Ping dry-runs SELECT 1, which checks access without running a job
+func (c *bigqueryConn) Ping(ctx context.Context) error {
+	_, err := c.query(ctx, "SELECT 1", nil, true)
+	return err
+}
+
+// QueryContext runs query and returns its rows as they are paged in
+func (c *bigqueryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	resp, err := c.query(ctx, query, args, false)
+	if err != nil {
+		return nil, err
+	}
+	rows := &bigqueryRows{conn: c, ctx: ctx, job: resp.JobReference}
+	if err := rows.take(resp); err != nil {
+		return nil, err
+	}
+	for !rows.complete {
+		if err := rows.fetch(); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// ExecContext runs query to completion
+func (c *bigqueryConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return driver.RowsAffected(rows.(*bigqueryRows).affected), nil
+}
+
+// bigqueryInt64 is an int64 the API encodes as a JSON string
+type bigqueryInt64 string
+
+func (n bigqueryInt64) int64() int64 {
+	v, _ := strconv.ParseInt(string(n), 10, 64)
+	return v
+}
+
+// bigqueryJob identifies a query job
+type bigqueryJob struct {
+	ProjectID string `json:"projectId"`
+	JobID     string `json:"jobId"`
+	Location  string `json:"location"`
+}
+
+// bigqueryResponse is a jobs.query or getQueryResults response
+type bigqueryResponse struct {
+	JobReference        bigqueryJob     `json:"jobReference"`
+	JobComplete         bool            `json:"jobComplete"`
+	Schema              *bigquerySchema `json:"schema"`
+	Rows                []bigqueryRow   `json:"rows"`
+	PageToken           string          `json:"pageToken"`
+	TotalBytesProcessed bigqueryInt64   `json:"totalBytesProcessed"`
+	TotalBytesBilled    bigqueryInt64   `json:"totalBytesBilled"`
+	NumDMLAffectedRows  bigqueryInt64   `json:"numDmlAffectedRows"`
+	Errors              []struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type bigquerySchema struct {
+	Fields []bigqueryField `json:"fields"`
+}
+
+type bigqueryField struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Mode   string          `json:"mode"`
+	Fields []bigqueryField `json:"fields"`
+}
+
+type bigqueryRow struct {
+	F []struct {
+		V interface{} `json:"v"`
+	} `json:"f"`
+}
+
+// query submits a query job, or with dryRun only validates and prices it.
+// Arguments are sent as positional query parameters.
+func (c *bigqueryConn) query(ctx context.Context, query string, args []driver.NamedValue, dryRun bool) (*bigqueryResponse, error) {
+	body := map[string]interface{}{
+		"query":        query,
+		"useLegacySql": false,
+		"dryRun":       dryRun,
+		"timeoutMs":    bigqueryWaitMs,
+		"maxResults":   bigqueryPageSize,
+		"labels":       c.labels(ctx),
+	}
+	if c.cfg.dataset != "" {
+		body["defaultDataset"] = map[string]string{"projectId": c.cfg.project, "datasetId": c.cfg.dataset}
+	}
+	if c.cfg.location != "" {
+		body["location"] = c.cfg.location
+	}
+	if len(args) > 0 {
+		params := make([]interface{}, len(args))
+		for i, arg := range args {
+			param, err := bigqueryParam(arg.Value)
+			if err != nil {
+				return nil, err
+			}
+			params[i] = param
+		}
+		body["parameterMode"] = "POSITIONAL"
+		body["queryParameters"] = params
+	}
+	var resp bigqueryResponse
+	if err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(c.cfg.project)+"/queries", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return checkBigQueryResponse(&resp)
+}
+
+// labels are the job labels of a query: source=air, the datasource's
+// labels and the context's, such as the report and run, made into valid
+// label values
+func (c *bigqueryConn) labels(ctx context.Context) map[string]string {
+	labels := map[string]string{"source": "air"}
+	for name, value := range c.cfg.labels {
+		labels[name] = value
+	}
+	for name, value := range queryLabels(ctx) {
+		labels[name] = bigqueryLabelValue(value)
+	}
+	return labels
+}
+
+// bigqueryLabelValue lowercases value and replaces what a label value may
+// not hold with _, keeping at most 63 characters
+func bigqueryLabelValue(value string) string {
+	label := []rune(strings.ToLower(value))
+	for i, r := range label {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			label[i] = '_'
+		}
+	}
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return string(label)
+}
+
+// checkBigQueryResponse fails a completed job that reports errors; errors of
+// a running job are only warnings
+func checkBigQueryResponse(resp *bigqueryResponse) (*bigqueryResponse, error) {
+	if len(resp.Errors) > 0 && resp.JobComplete {
+		return nil, fmt.Errorf("bigquery: %s: %s", resp.Errors[0].Reason, resp.Errors[0].Message)
+	}
+	return resp, nil
+}
+
+// do sends an API request and decodes its response into out, retrying while
+// the service is busy
+func (c *bigqueryConn) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	target := c.cfg.endpoint + "/bigquery/v2" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	token := c.cfg.token
+	if c.token != nil {
+		var err error
+		if token, err = c.token(ctx); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(string(payload)))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("bigquery: %w", err)
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			defer resp.Body.Close()
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("bigquery: failed to decode response: %w", err)
+			}
+			return nil
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
+			resp.Body.Close()
+			if attempt == 5 {
+				return fmt.Errorf("bigquery: service returned %s", resp.Status)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 200 * time.Millisecond):
+			}
+		default:
+			defer resp.Body.Close()
+			var failure struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			if json.Unmarshal(raw, &failure) == nil && failure.Error.Message != "" {
+				return fmt.Errorf("bigquery: %s: %s", resp.Status, failure.Error.Message)
+			}
+			return fmt.Errorf("bigquery: %s: %s", resp.Status, strings.TrimSpace(string(raw)))
+		}
+	}
+}
+
+// bigqueryStmt is a statement sent on execution
+type bigqueryStmt struct {
+	conn  *bigqueryConn
+	query string
+}
+
+func (s *bigqueryStmt) Close() error  { return nil }
+func (s *bigqueryStmt) NumInput() int { return -1 }
+
+func (s *bigqueryStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *bigqueryStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *bigqueryStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *bigqueryStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// bigqueryRows pages in a job's results as they are read
+type bigqueryRows struct {
+	conn      *bigqueryConn
+	ctx       context.Context
+	job       bigqueryJob
+	complete  bool
+	pageToken string
+	fields    []bigqueryField
+	data      []bigqueryRow
+	affected  int64
+}
+
+// take records a page of results. Once the job completes, the bytes it was
+// billed are reported to the context's WithBilledBytes counter.
+func (r *bigqueryRows) take(page *bigqueryResponse) error {
+	wasComplete := r.complete
+	r.complete = page.JobComplete
+	if !r.complete {
+		return nil
+	}
+	if r.fields == nil && page.Schema != nil {
+		r.fields = page.Schema.Fields
+	}
+	r.data = append(r.data, page.Rows...)
+	r.pageToken = page.PageToken
+	if wasComplete {
+		return nil
+	}
+	r.affected = page.NumDMLAffectedRows.int64()
+
+	billed := page.TotalBytesBilled
+	if billed == "" {
+		// getQueryResults leaves billing out; the job has it
+		job, err := r.conn.job(r.ctx, r.job)
+		if err != nil {
+			return err
+		}
+		billed = job
+	}
+	recordBilledBytes(r.ctx, billed.int64())
+	return nil
+}
+
+// fetch waits for the job to complete, or reads its next page
+func (r *bigqueryRows) fetch() error {
+	query := url.Values{}
+	query.Set("timeoutMs", strconv.Itoa(bigqueryWaitMs))
+	query.Set("maxResults", strconv.Itoa(bigqueryPageSize))
+	if r.job.Location != "" {
+		query.Set("location", r.job.Location)
+	}
+	if r.pageToken != "" {
+		query.Set("pageToken", r.pageToken)
+	}
+	var page bigqueryResponse
+	err := r.conn.do(r.ctx, http.MethodGet,
+		"/projects/"+url.PathEscape(r.job.ProjectID)+"/queries/"+url.PathEscape(r.job.JobID), query, nil, &page)
+	if err != nil {
+		return err
+	}
+	if _, err := checkBigQueryResponse(&page); err != nil {
+		return err
+	}
+	return r.take(&page)
+}
+
+// job reads the bytes a completed job was billed
+func (c *bigqueryConn) job(ctx context.Context, ref bigqueryJob) (bigqueryInt64, error) {
+	query := url.Values{}
+	if ref.Location != "" {
+		query.Set("location", ref.Location)
+	}
+	var job struct {
+		Statistics struct {
+			Query struct {
+				TotalBytesBilled bigqueryInt64 `json:"totalBytesBilled"`
+			} `json:"query"`
+		} `json:"statistics"`
+	}
+	err := c.do(ctx, http.MethodGet, "/projects/"+url.PathEscape(ref.ProjectID)+"/jobs/"+url.PathEscape(ref.JobID), query, nil, &job)
+	if err != nil {
+		return "", err
+	}
+	return job.Statistics.Query.TotalBytesBilled, nil
+}
+
+// Columns returns the column names
+func (r *bigqueryRows) Columns() []string {
+	names := make([]string, len(r.fields))
+	for i, field := range r.fields {
+		names[i] = field.Name
+	}
+	return names
+}
+
+// ColumnTypeDatabaseTypeName returns the column's type, or ARRAY for a
+// repeated one
+func (r *bigqueryRows) ColumnTypeDatabaseTypeName(i int) string {
+	if r.fields[i].Mode == "REPEATED" {
+		return "ARRAY"
+	}
+	return strings.ToUpper(r.fields[i].Type)
+}
+
+// Close stops reading; a job that finished has nothing to cancel
+func (r *bigqueryRows) Close() error {
+	r.data = nil
+	r.pageToken = ""
+	return nil
+}
+
+// Next reads the next row, fetching a page when the current one is used up
+func (r *bigqueryRows) Next(dest []driver.Value) error {
+	for len(r.data) == 0 {
+		if r.pageToken == "" {
+			return io.EOF
+		}
+		if err := r.fetch(); err != nil {
+			return err
+		}
+	}
+	row := r.data[0]
+	r.data = r.data[1:]
+	for i := range dest {
+		if i >= len(row.F) {
+			dest[i] = nil
+			continue
+		}
+		value, err := bigqueryValue(row.F[i].V, r.fields[i])
+		if err != nil {
+			return fmt.Errorf("bigquery: column %s: %w", r.fields[i].Name, err)
+		}
+		dest[i] = value
+	}
+	return nil
+}
+
+// bigqueryValue converts a result cell, which the API sends as a string, to
+// a driver value. Exact numerics and JSON stay strings; repeated and record
+// values are returned as JSON text.
+func bigqueryValue(v interface{}, field bigqueryField) (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if field.Mode == "REPEATED" || field.Type == "RECORD" || field.Type == "STRUCT" {
+		raw, err := json.Marshal(bigqueryPlain(v, field))
+		return string(raw), err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Sprint(v), nil
+	}
+	switch field.Type {
+	case "INTEGER", "INT64":
+		return strconv.ParseInt(s, 10, 64)
+	case "FLOAT", "FLOAT64":
+		return strconv.ParseFloat(s, 64)
+	case "BOOLEAN", "BOOL":
+		return strconv.ParseBool(s)
+	case "TIMESTAMP":
+		// Seconds since the epoch, e.g. 1.7040672E9
+		seconds, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMicro(int64(seconds * 1e6)).UTC(), nil
+	case "DATE":
+		return time.Parse("2006-01-02", s)
+	case "DATETIME":
+		return time.Parse("2006-01-02T15:04:05.999999", s)
+	case "BYTES":
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return s, nil
+}
+
+// bigqueryPlain unwraps the {"v": ...} and {"f": [...]} cells of a repeated
+// or record value into a list or an object keyed by field name
+func bigqueryPlain(v interface{}, field bigqueryField) interface{} {
+	if field.Mode == "REPEATED" {
+		items, _ := v.([]interface{})
+		list := make([]interface{}, len(items))
+		element := field
+		element.Mode = ""
+		for i, item := range items {
+			cell, _ := item.(map[string]interface{})
+			list[i] = bigqueryPlain(cell["v"], element)
+		}
+		return list
+	}
+	if field.Type != "RECORD" && field.Type != "STRUCT" {
+		return v
+	}
+	record, _ := v.(map[string]interface{})
+	cells, _ := record["f"].([]interface{})
+	object := make(map[string]interface{}, len(cells))
+	for i, item := range cells {
+		if i >= len(field.Fields) {
+			break
+		}
+		cell, _ := item.(map[string]interface{})
+		object[field.Fields[i].Name] = bigqueryPlain(cell["v"], field.Fields[i])
+	}
+	return object
+}
+
+// bigqueryParam encodes an argument as a positional query parameter; nil is
+// a NULL string
+func bigqueryParam(v driver.Value) (map[string]interface{}, error) {
+	param := func(typ string, value interface{}) map[string]interface{} {
+		p := map[string]interface{}{"parameterType": map[string]string{"type": typ}, "parameterValue": map[string]interface{}{}}
+		if value != nil {
+			p["parameterValue"] = map[string]interface{}{"value": value}
+		}
+		return p
+	}
+	switch x := v.(type) {
+	case nil:
+		return param("STRING", nil), nil
+	case int64:
+		return param("INT64", strconv.FormatInt(x, 10)), nil
+	case float64:
+		return param("FLOAT64", strconv.FormatFloat(x, 'g', -1, 64)), nil
+	case bool:
+		return param("BOOL", strconv.FormatBool(x)), nil
+	case []byte:
+		return param("BYTES", base64.StdEncoding.EncodeToString(x)), nil
+	case string:
+		return param("STRING", x), nil
+	case time.Time:
+		return param("TIMESTAMP", x.UTC().Format("2006-01-02 15:04:05.999999-07:00")), nil
+	default:
+		return nil, fmt.Errorf("bigquery: unsupported argument type %T", v)
+	}
+}
@@ -0,0 +1,425 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	Register("postgres", func() Connector { return postgresConnector{dialect: "postgres"} })
+	Register("timescaledb", func() Connector { return postgresConnector{dialect: "timescaledb"} })
+	Register("mysql", func() Connector { return mysqlConnector{} })
+	Register("sqlite", func() Connector { return sqliteConnector{} })
+}
+
+// sourceConnector opens each connection through a driver connector built
+// for it, so every connection picks up a current password
+type sourceConnector struct {
+	driver driver.Driver
+	open   func(ctx context.Context) (driver.Connector, error)
+}
+
+// Connect opens a connection with a freshly built driver connector
+func (c *sourceConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := c.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver returns the underlying driver
+func (c *sourceConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// scanTables collects the table names a query returns
+func scanTables(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// scanColumns collects the name, type, nullability and default a column
+// query returns
+func scanColumns(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]Column, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []Column
+	for rows.Next() {
+		var col Column
+		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &col.Default); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// introspectTables reads the columns of each table. A table whose columns
+// cannot be read is returned without them rather than failing the rest.
+func introspectTables(ctx context.Context, db *sql.DB, names []string, columns func(table string) (string, []interface{})) []Table {
+	tables := make([]Table, 0, len(names))
+	for _, name := range names {
+		query, args := columns(name)
+		cols, err := scanColumns(ctx, db, query, args...)
+		if err != nil {
+			logger.LogError(logger.ServiceDB, "Failed to get columns for table", err, map[string]interface{}{
+				"table": name,
+			})
+			continue
+		}
+		tables = append(tables, Table{Name: name, Columns: cols})
+	}
+	return tables
+}
+
+// postgresConnector serves Postgres and, with dialect "timescaledb",
+// TimescaleDB
+type postgresConnector struct {
+	dialect string
+}
+
+// Open opens a lib/pq pool. Session properties, such as statement_timeout
+// or search_path, are sent as run-time parameters when each session starts;
+// read-only pools also start with default_transaction_read_only on. Sessions
+// are named "air" in pg_stat_activity unless the DSN or session properties
+// set application_name.
+func (c postgresConnector) Open(dsn string, opts OpenOptions) (*sql.DB, error) {
+	dsn, err := pgWithSetting(dsn, "fallback_application_name", "air")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(opts.SessionProperties))
+	for name := range opts.SessionProperties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if dsn, err = pgWithSetting(dsn, name, opts.SessionProperties[name]); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ReadOnly {
+		if dsn, err = pgWithSetting(dsn, "default_transaction_read_only", "on"); err != nil {
+			return nil, err
+		}
+	}
+	if opts.User != "" {
+		if dsn, err = pgWithSetting(dsn, "user", opts.User); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Dial == nil && opts.Password == nil {
+		return sql.Open("postgres", dsn)
+	}
+	return sql.OpenDB(&sourceConnector{driver: &pq.Driver{}, open: func(ctx context.Context) (driver.Connector, error) {
+		connDSN := dsn
+		if opts.Password != nil {
+			password, err := opts.Password(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if connDSN, err = pgWithPassword(dsn, password); err != nil {
+				return nil, err
+			}
+		}
+		connector, err := pq.NewConnector(connDSN)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Dial != nil {
+			connector.Dialer(pqDialer(opts.Dial))
+		}
+		return connector, nil
+	}}), nil
+}
+
+// Introspect lists tables and views of the given schemas, public by default
+func (c postgresConnector) Introspect(ctx context.Context, db *sql.DB, schemas []string) ([]Table, error) {
+	if len(schemas) == 0 {
+		schemas = []string{"public"}
+	}
+	names, err := scanTables(ctx, db,
+		"SELECT tablename FROM pg_tables WHERE schemaname = ANY($1) UNION SELECT viewname FROM pg_views WHERE schemaname = ANY($1)",
+		pq.Array(schemas))
+	if err != nil {
+		return nil, err
+	}
+	return introspectTables(ctx, db, names, func(table string) (string, []interface{}) {
+		return `
+			SELECT column_name, data_type, is_nullable, COALESCE(column_default, '')
+			FROM information_schema.columns
+			WHERE table_name = $1
+			ORDER BY ordinal_position`, []interface{}{table}
+	}), nil
+}
+
+// Dialect is "postgres" or "timescaledb"
+func (c postgresConnector) Dialect() string {
+	return c.dialect
+}
+
+// Quote double-quotes an identifier
+func (c postgresConnector) Quote(ident string) string {
+	return quoteANSI(ident)
+}
+
+// Explain reads the top-level "Plan Rows" from EXPLAIN (FORMAT JSON)
+func (c postgresConnector) Explain(ctx context.Context, db *sql.DB, query string) (int64, error) {
+	var raw []byte
+	if err := db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query).Scan(&raw); err != nil {
+		return 0, err
+	}
+
+	var plans []struct {
+		Plan struct {
+			PlanRows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return 0, fmt.Errorf("failed to parse EXPLAIN output: %w", err)
+	}
+	if len(plans) == 0 {
+		return 0, fmt.Errorf("empty EXPLAIN output")
+	}
+	return int64(plans[0].Plan.PlanRows), nil
+}
+
+// CanWrite reports whether the session user is a superuser or may insert,
+// update, delete or truncate any table outside the system schemas
+func (c postgresConnector) CanWrite(ctx context.Context, db *sql.DB) (bool, error) {
+	var canWrite bool
+	err := db.QueryRowContext(ctx, `
+		SELECT rolsuper OR EXISTS (
+			SELECT 1 FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.relkind IN ('r', 'p')
+			  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			  AND n.nspname NOT LIKE 'pg_toast%'
+			  AND has_table_privilege(c.oid, 'INSERT, UPDATE, DELETE, TRUNCATE'))
+		FROM pg_roles WHERE rolname = session_user`).Scan(&canWrite)
+	return canWrite, err
+}
+
+// mysqlConnector serves MySQL
+type mysqlConnector struct{}
+
+// Open opens a go-sql-driver/mysql pool. Session properties are set as
+// session variables on connect, e.g. max_execution_time=30000; string values
+// need their quotes, as in time_zone='+00:00'. Read-only pools also set
+// transaction_read_only, as SET SESSION TRANSACTION READ ONLY does.
+func (mysqlConnector) Open(dsn string, opts OpenOptions) (*sql.DB, error) {
+	if opts.Dial == nil && opts.Password == nil && !opts.ReadOnly && opts.User == "" && len(opts.SessionProperties) == 0 {
+		return sql.Open("mysql", dsn)
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if opts.User != "" {
+		cfg.User = opts.User
+	}
+	// Params the driver does not know are set as session variables on connect
+	if cfg.Params == nil && (opts.ReadOnly || len(opts.SessionProperties) > 0) {
+		cfg.Params = make(map[string]string)
+	}
+	for name, value := range opts.SessionProperties {
+		cfg.Params[name] = value
+	}
+	if opts.ReadOnly {
+		cfg.Params["transaction_read_only"] = "1"
+	}
+	if opts.Dial == nil && opts.Password == nil {
+		connector, err := mysql.NewConnector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
+	}
+	return sql.OpenDB(&sourceConnector{driver: &mysql.MySQLDriver{}, open: func(ctx context.Context) (driver.Connector, error) {
+		connCfg := cfg.Clone()
+		if opts.Password != nil {
+			password, err := opts.Password(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// Tokens are sent with the cleartext plugin; the DSN's tls setting protects them
+			connCfg.Passwd = password
+			connCfg.AllowCleartextPasswords = opts.Token
+		}
+		if opts.Dial != nil {
+			connCfg.DialFunc = opts.Dial
+		}
+		return mysql.NewConnector(connCfg)
+	}}), nil
+}
+
+// CanWrite reports whether the current account holds a privilege to modify
+// data or tables, globally or on the connection's database
+func (mysqlConnector) CanWrite(ctx context.Context, db *sql.DB) (bool, error) {
+	var account string
+	if err := db.QueryRowContext(ctx, "SELECT CURRENT_USER()").Scan(&account); err != nil {
+		return false, err
+	}
+	// Privilege tables name the account as 'user'@'host'
+	user, host := account, "%"
+	if at := strings.LastIndex(account, "@"); at >= 0 {
+		user, host = account[:at], account[at+1:]
+	}
+	grantee := "'" + user + "'@'" + host + "'"
+
+	const writes = "privilege_type IN ('INSERT', 'UPDATE', 'DELETE', 'CREATE', 'DROP', 'ALTER')"
+	var canWrite bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.user_privileges WHERE grantee = ? AND `+writes+`
+			UNION ALL
+			SELECT 1 FROM information_schema.schema_privileges WHERE grantee = ? AND table_schema = DATABASE() AND `+writes+`
+			UNION ALL
+			SELECT 1 FROM information_schema.table_privileges WHERE grantee = ? AND table_schema = DATABASE() AND `+writes+`)`,
+		grantee, grantee, grantee).Scan(&canWrite)
+	return canWrite, err
+}
+
+// Introspect lists the tables and views of the connection's database;
+// MySQL has no schemas within a database, so schemas is ignored
+func (mysqlConnector) Introspect(ctx context.Context, db *sql.DB, schemas []string) ([]Table, error) {
+	names, err := scanTables(ctx, db,
+		"SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type IN ('BASE TABLE', 'VIEW')")
+	if err != nil {
+		return nil, err
+	}
+	return introspectTables(ctx, db, names, func(table string) (string, []interface{}) {
+		return `
+			SELECT column_name, data_type, is_nullable, COALESCE(column_default, '')
+			FROM information_schema.columns
+			WHERE table_name = ? AND table_schema = DATABASE()
+			ORDER BY ordinal_position`, []interface{}{table}
+	}), nil
+}
+
+// Dialect is "mysql"
+func (mysqlConnector) Dialect() string {
+	return "mysql"
+}
+
+// Quote backtick-quotes an identifier
+func (mysqlConnector) Quote(ident string) string {
+	return "`" + strings.ReplaceAll(ident, "`", "``") + "`"
+}
+
+// Explain takes the largest per-table "rows" estimate from EXPLAIN
+func (mysqlConnector) Explain(ctx context.Context, db *sql.DB, query string) (int64, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN "+query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	rowsCol := -1
+	for i, col := range cols {
+		if strings.EqualFold(col, "rows") {
+			rowsCol = i
+		}
+	}
+	if rowsCol < 0 {
+		return 0, fmt.Errorf("EXPLAIN output has no rows column")
+	}
+
+	values := make([]sql.RawBytes, len(cols))
+	scanArgs := make([]interface{}, len(cols))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+
+	var estimate int64
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return 0, err
+		}
+		n, err := strconv.ParseInt(string(values[rowsCol]), 10, 64)
+		if err == nil && n > estimate {
+			estimate = n
+		}
+	}
+	return estimate, rows.Err()
+}
+
+// sqliteConnector serves SQLite files
+type sqliteConnector struct{}
+
+// Open opens a go-sqlite3 pool; a local file has nothing to dial or sign in to
+func (sqliteConnector) Open(dsn string, opts OpenOptions) (*sql.DB, error) {
+	if opts.Dial != nil || opts.Password != nil {
+		return nil, fmt.Errorf("sqlite datasources cannot use an ssh tunnel, proxy or token auth")
+	}
+	if opts.ReadOnly {
+		// PRAGMA query_only refuses every statement that changes the file
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "_query_only=1"
+	}
+	return sql.Open("sqlite3", dsn)
+}
+
+// Introspect lists the file's tables and views; schemas is ignored
+func (sqliteConnector) Introspect(ctx context.Context, db *sql.DB, schemas []string) ([]Table, error) {
+	names, err := scanTables(ctx, db,
+		"SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	return introspectTables(ctx, db, names, func(table string) (string, []interface{}) {
+		// PRAGMA statements can't take bound parameters; the table-valued form can
+		return `SELECT name, type, CASE WHEN "notnull" = 0 THEN 'YES' ELSE 'NO' END, COALESCE(dflt_value, '') FROM pragma_table_info(?)`,
+			[]interface{}{table}
+	}), nil
+}
+
+// Dialect is "sqlite"
+func (sqliteConnector) Dialect() string {
+	return "sqlite"
+}
+
+// Quote double-quotes an identifier
+func (sqliteConnector) Quote(ident string) string {
+	return quoteANSI(ident)
+}
+
+// Explain has no estimate to offer; SQLite's planner does not count rows
+func (sqliteConnector) Explain(ctx context.Context, db *sql.DB, query string) (int64, error) {
+	return 0, ErrNoEstimate
+}
@@ -0,0 +1,133 @@
+package datasource
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+)
+
+// Circuit states of a datasource connection
+const (
+	CircuitClosed   = "closed"    // checkouts go through
+	CircuitOpen     = "open"      // checkouts fail fast until the retry time
+	CircuitHalfOpen = "half_open" // the retry time has passed; the next checkout tries to reconnect
+)
+
+const (
+	// prePingInterval is how long a connection check stays good for. A
+	// checkout after that pings first, so a database that restarted while
+	// the pool sat idle is reconnected before a query fails on it.
+	prePingInterval = 30 * time.Second
+	// reconnectBackoffMin and reconnectBackoffMax bound the wait between
+	// reconnect attempts, which doubles with each consecutive failure
+	reconnectBackoffMin = time.Second
+	reconnectBackoffMax = time.Minute
+)
+
+// circuit tracks consecutive connection failures of a connector
+type circuit struct {
+	mu        sync.Mutex // also serializes reconnect attempts
+	failures  int
+	retryAt   time.Time
+	checkedAt time.Time
+}
+
+// Circuit returns the connector's circuit state, its consecutive failed
+// connection checks and, while open, when the next checkout may retry
+func (c *DatasourceConnector) Circuit() (state string, failures int, retryAt *time.Time) {
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+
+	switch {
+	case c.breaker.failures == 0:
+		return CircuitClosed, 0, nil
+	case time.Now().Before(c.breaker.retryAt):
+		at := c.breaker.retryAt
+		return CircuitOpen, c.breaker.failures, &at
+	default:
+		return CircuitHalfOpen, c.breaker.failures, nil
+	}
+}
+
+// checkout readies the connection for use. It pings when the last check is
+// older than prePingInterval and fails fast while the circuit is open.
+func (c *DatasourceConnector) checkout() error {
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+
+	now := time.Now()
+	if c.breaker.failures > 0 && now.Before(c.breaker.retryAt) {
+		return fmt.Errorf("%w: %s: reconnecting after %s: %v", ErrDatasourceUnavailable, c.ID, c.breaker.retryAt.Format(time.RFC3339), c.Error)
+	}
+	if c.breaker.failures == 0 && now.Sub(c.breaker.checkedAt) < prePingInterval {
+		return nil
+	}
+	if err := c.reconnect(); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrDatasourceUnavailable, c.ID, err)
+	}
+	return nil
+}
+
+// Probe checks the connection now, reconnecting if needed whatever the
+// backoff, and records the outcome on the circuit and health status
+func (c *DatasourceConnector) Probe() error {
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+	return c.reconnect()
+}
+
+// reconnect pings the pool. When that fails the idle connections, which may
+// predate a database restart, are dropped and the pool pinged again on a new
+// one. Until they pass, the credentials are checked for write privileges.
+// The caller holds c.breaker.mu.
+func (c *DatasourceConnector) reconnect() error {
+	err := c.TestConnection()
+	if err != nil && c.DB != nil {
+		c.DB.SetMaxIdleConns(0)
+		c.DB.SetMaxIdleConns(maxIdleConns)
+		err = c.TestConnection()
+	}
+	if err == nil {
+		err = c.guardWrites()
+	}
+	c.record(err)
+	return err
+}
+
+// record updates the circuit and health status with a connection check
+func (c *DatasourceConnector) record(err error) {
+	now := time.Now()
+	c.breaker.checkedAt = now
+
+	if err == nil {
+		if c.breaker.failures > 0 {
+			logger.LogInfo(logger.ServiceDB, "Datasource reconnected", map[string]interface{}{
+				"id":       c.ID,
+				"failures": c.breaker.failures,
+			})
+		}
+		c.breaker.failures = 0
+		c.breaker.retryAt = time.Time{}
+		c.HealthStatus = "healthy"
+		c.Error = nil
+		c.LastHealth = now
+		return
+	}
+
+	c.breaker.failures++
+	backoff := reconnectBackoffMax
+	if c.breaker.failures <= 6 {
+		backoff = reconnectBackoffMin << (c.breaker.failures - 1)
+	}
+	c.breaker.retryAt = now.Add(backoff)
+	c.HealthStatus = "unhealthy"
+	c.Error = err
+	logger.LogWarn(logger.ServiceDB, "Datasource connection check failed", map[string]interface{}{
+		"id":       c.ID,
+		"failures": c.breaker.failures,
+		"retry_in": backoff.String(),
+		"error":    err.Error(),
+	})
+}
@@ -0,0 +1,230 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/NubeDev/air/internal/config"
+)
+
+// Connector adds support for a kind of database. Postgres, TimescaleDB,
+// MySQL and SQLite are built in; other kinds are added with Register, from
+// an init function, before the registry is created.
+type Connector interface {
+	// Open opens a connection pool for dsn. When opts sets Dial or Password,
+	// every connection must be dialed and signed in with them.
+	Open(dsn string, opts OpenOptions) (*sql.DB, error)
+	// Introspect lists the tables and views in schemas, or in the
+	// connection's default schema when none are given, with their columns
+	Introspect(ctx context.Context, db *sql.DB, schemas []string) ([]Table, error)
+	// Dialect is the SQL dialect generated for the database: "postgres",
+	// "timescaledb", "mysql", "sqlite" or "sqlserver" get rewrites for that
+	// dialect, and any other name gets PostgreSQL-flavoured ANSI SQL
+	Dialect() string
+	// Quote quotes an identifier
+	Quote(ident string) string
+	// Explain returns the planner's row estimate for query without running
+	// it, or ErrNoEstimate when the database cannot give one
+	Explain(ctx context.Context, db *sql.DB, query string) (int64, error)
+}
+
+// OpenOptions are how a connector's connections reach and sign in to the
+// database
+type OpenOptions struct {
+	// Dial opens the network connection, through an SSH tunnel or proxy
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// Password returns the password for a new connection, replacing any in
+	// the DSN
+	Password func(ctx context.Context) (string, error)
+	// Token marks Password as a short-lived auth token; MySQL sends tokens
+	// with the cleartext plugin
+	Token bool
+	// User replaces the DSN's user, for a credential a report version runs
+	// as. Only Postgres, TimescaleDB and MySQL connectors support it.
+	User string
+	// SessionProperties are set on every connection: Postgres and MySQL
+	// session settings, or Trino session properties
+	SessionProperties map[string]string
+	// Labels tag every query job, for warehouses that take labels
+	Labels map[string]string
+	// ReadOnly opens every connection in read-only mode, where the driver
+	// supports it
+	ReadOnly bool
+}
+
+// Table is an introspected table or view
+type Table struct {
+	Name    string
+	Columns []Column
+}
+
+// Column is an introspected column. Nullable is "YES" or "NO".
+type Column struct {
+	Name     string
+	Type     string
+	Nullable string
+	Default  string
+}
+
+// ScanEstimator is implemented by connectors of warehouses that bill by the
+// bytes a query scans, such as BigQuery. Their queries report the bytes they
+// were billed to a context made with WithBilledBytes.
+type ScanEstimator interface {
+	// EstimateScan returns the bytes query would scan, from a dry run that
+	// is not billed
+	EstimateScan(ctx context.Context, db *sql.DB, query string) (int64, error)
+}
+
+// Factory creates the connector of a kind
+type Factory func() Connector
+
+// ErrNoEstimate is returned by Connector.Explain when the database has no
+// planner estimate to offer
+var ErrNoEstimate = errors.New("row estimate not supported")
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a kind of datasource available. Like sql.Register it panics
+// when the kind is registered twice or factory is nil.
+func Register(kind string, factory Factory) {
+	kind = strings.ToLower(kind)
+	if factory == nil {
+		panic("datasource: Register factory is nil for " + kind)
+	}
+
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, dup := factories[kind]; dup {
+		panic("datasource: Register called twice for " + kind)
+	}
+	factories[kind] = factory
+	config.RegisterSourceKind(kind)
+}
+
+// Lookup returns a new connector for kind
+func Lookup(kind string) (Connector, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[strings.ToLower(kind)]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported database kind: %s", kind)
+	}
+	return factory(), nil
+}
+
+// Kinds returns the registered kinds in order
+func Kinds() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	kinds := make([]string, 0, len(factories))
+	for kind := range factories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// QuoteIdent quotes an identifier for a kind of datasource, with ANSI double
+// quotes for kinds that are not registered
+func QuoteIdent(kind, ident string) string {
+	if c, err := Lookup(kind); err == nil {
+		return c.Quote(ident)
+	}
+	return quoteANSI(ident)
+}
+
+// quoteANSI quotes an identifier with double quotes
+func quoteANSI(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
+
+// Dialect is the SQL dialect of the datasource, or its kind when it has no
+// connector
+func (c *DatasourceConnector) Dialect() string {
+	if c.driver == nil {
+		return strings.ToLower(c.Kind)
+	}
+	return c.driver.Dialect()
+}
+
+// Quote quotes an identifier for the datasource
+func (c *DatasourceConnector) Quote(ident string) string {
+	if c.driver == nil {
+		return quoteANSI(ident)
+	}
+	return c.driver.Quote(ident)
+}
+
+// Introspect lists the datasource's tables and views with their columns
+func (c *DatasourceConnector) Introspect(ctx context.Context, schemas []string) ([]Table, error) {
+	if err := c.Connected(); err != nil {
+		return nil, err
+	}
+	return c.driver.Introspect(ctx, c.DB, schemas)
+}
+
+// Explain returns the datasource's row estimate for query, or ErrNoEstimate
+func (c *DatasourceConnector) Explain(ctx context.Context, query string) (int64, error) {
+	if c.driver == nil {
+		return 0, ErrNoEstimate
+	}
+	return c.driver.Explain(ctx, c.DB, query)
+}
+
+// BillsByScan reports whether the datasource bills queries by the bytes they
+// scan, so dry runs can estimate their cost
+func (c *DatasourceConnector) BillsByScan() bool {
+	_, ok := c.driver.(ScanEstimator)
+	return ok
+}
+
+// EstimateScan returns the bytes query would scan, or ErrNoEstimate when the
+// datasource does not bill by scan
+func (c *DatasourceConnector) EstimateScan(ctx context.Context, query string) (int64, error) {
+	estimator, ok := c.driver.(ScanEstimator)
+	if !ok {
+		return 0, ErrNoEstimate
+	}
+	return estimator.EstimateScan(ctx, c.DB, query)
+}
+
+type queryLabelsKey struct{}
+
+// WithQueryLabels returns a context whose queries carry labels, such as the
+// report and run they belong to, on warehouses that take labels. They are
+// added to the datasource's own labels.
+func WithQueryLabels(ctx context.Context, labels map[string]string) context.Context {
+	return context.WithValue(ctx, queryLabelsKey{}, labels)
+}
+
+// queryLabels returns the labels of a WithQueryLabels context
+func queryLabels(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(queryLabelsKey{}).(map[string]string)
+	return labels
+}
+
+type billedBytesKey struct{}
+
+// WithBilledBytes returns a context whose queries add the bytes their
+// warehouse billed them to *billed
+func WithBilledBytes(ctx context.Context, billed *int64) context.Context {
+	return context.WithValue(ctx, billedBytesKey{}, billed)
+}
+
+// recordBilledBytes adds bytes to the counter of a WithBilledBytes context
+func recordBilledBytes(ctx context.Context, bytes int64) {
+	if billed, ok := ctx.Value(billedBytesKey{}).(*int64); ok {
+		atomic.AddInt64(billed, bytes)
+	}
+}
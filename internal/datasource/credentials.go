@@ -0,0 +1,70 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/NubeDev/air/internal/config"
+)
+
+// ErrCredentialNotFound is returned for a credential the datasource does not
+// configure
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// As returns the pool that signs in as the named credential, or the
+// datasource's own pool for "". A credential's pool is opened on first use,
+// with the datasource's route and read-only mode, and refused like the
+// datasource's own when its user can write.
+func (c *DatasourceConnector) As(name string) (*sql.DB, error) {
+	if name == "" {
+		return c.DB, nil
+	}
+	var credential *config.CredentialConfig
+	for i := range c.Credentials {
+		if c.Credentials[i].Name == name {
+			credential = &c.Credentials[i]
+			break
+		}
+	}
+	if credential == nil {
+		return nil, fmt.Errorf("%w: datasource %s has no credential %q", ErrCredentialNotFound, c.ID, name)
+	}
+
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	if pool, ok := c.pools[name]; ok {
+		return pool, nil
+	}
+	if c.openAs == nil {
+		return nil, fmt.Errorf("%w: %s", ErrDatasourceUnavailable, c.ID)
+	}
+	pool, err := c.openAs(*credential)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credential %q: %w", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeCheckTimeout)
+	defer cancel()
+	if err := pool.PingContext(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to sign in as credential %q: %w", name, err)
+	}
+	if checker, ok := c.driver.(WriteChecker); ok && !c.AllowWrites {
+		canWrite, err := checker.CanWrite(ctx, pool)
+		if err == nil && canWrite {
+			err = fmt.Errorf("%w; grant it read-only access or set allow_writes", ErrWritableCredentials)
+		}
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("credential %q: %w", name, err)
+		}
+	}
+
+	if c.pools == nil {
+		c.pools = make(map[string]*sql.DB)
+	}
+	c.pools[name] = pool
+	return pool, nil
+}
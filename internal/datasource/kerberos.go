@@ -0,0 +1,205 @@
+package datasource
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/lib/pq"
+)
+
+func init() {
+	pq.RegisterGSSProvider(func() (pq.GSS, error) { return &kerberosGSS{}, nil })
+}
+
+// kerberosIdentity is who a kerberos datasource signs in as
+type kerberosIdentity struct {
+	source     string // datasource ID, for errors
+	principal  string
+	realm      string
+	keytab     string // "" signs in from the credential cache
+	krb5Config string
+
+	mu     sync.Mutex
+	client *client.Client // keytab clients are kept; gokrb5 renews their tickets
+}
+
+// kerberosSPNs maps the service principal of each Postgres server a
+// kerberos datasource signs in to onto its identity. lib/pq's GSS provider
+// is process-wide and only told the SPN, so the SPN picks the identity.
+var kerberosSPNs = struct {
+	sync.Mutex
+	identities map[string]*kerberosIdentity
+}{identities: make(map[string]*kerberosIdentity)}
+
+// registerKerberos records the identity a kerberos source signs in to its
+// server as. The keytab and krb5.conf are checked now; the KDC is first
+// contacted when a connection is opened. Sources signing in to the same
+// server must use the same principal.
+func registerKerberos(source config.AnalyticsSourceConfig) error {
+	if source.Kind != "postgres" && source.Kind != "timescaledb" {
+		return fmt.Errorf("kerberos: only postgres and timescaledb datasources are supported")
+	}
+	opts, err := pgOptions(source.DSN)
+	if err != nil {
+		return err
+	}
+	spn := opts["krbspn"]
+	if spn == "" {
+		service, host := opts["krbsrvname"], opts["host"]
+		if service == "" {
+			service = "postgres"
+		}
+		if host == "" {
+			host = "localhost"
+		}
+		spn = service + "/" + host
+	}
+
+	identity := &kerberosIdentity{
+		source:     source.ID,
+		principal:  source.Auth.Principal,
+		realm:      source.Auth.Realm,
+		keytab:     source.Auth.Keytab,
+		krb5Config: source.Auth.KRB5Config,
+	}
+	if identity.principal == "" {
+		identity.principal = opts["user"]
+	}
+	if name, realm, ok := strings.Cut(identity.principal, "@"); ok {
+		identity.principal, identity.realm = name, realm
+	}
+	if identity.krb5Config == "" {
+		identity.krb5Config = os.Getenv("KRB5_CONFIG")
+	}
+	if identity.krb5Config == "" {
+		identity.krb5Config = "/etc/krb5.conf"
+	}
+	krbConf, err := krb5config.Load(identity.krb5Config)
+	if err != nil {
+		return fmt.Errorf("kerberos: failed to load %s: %w", identity.krb5Config, err)
+	}
+	if identity.realm == "" {
+		identity.realm = krbConf.LibDefaults.DefaultRealm
+	}
+	if identity.keytab != "" {
+		if identity.principal == "" || identity.realm == "" {
+			return fmt.Errorf("kerberos: a keytab needs auth.principal (or a DSN user) and a realm")
+		}
+		if _, err := keytab.Load(identity.keytab); err != nil {
+			return fmt.Errorf("kerberos: failed to load keytab %s: %w", identity.keytab, err)
+		}
+	}
+
+	kerberosSPNs.Lock()
+	defer kerberosSPNs.Unlock()
+	if other, ok := kerberosSPNs.identities[spn]; ok && other.source != identity.source && !other.same(identity) {
+		return fmt.Errorf("kerberos: datasource %s already signs in to %s as %s@%s", other.source, spn, other.principal, other.realm)
+	}
+	kerberosSPNs.identities[spn] = identity
+	return nil
+}
+
+// same reports whether two identities sign in as the same principal with
+// the same credentials
+func (k *kerberosIdentity) same(other *kerberosIdentity) bool {
+	return k.principal == other.principal && k.realm == other.realm && k.keytab == other.keytab && k.krb5Config == other.krb5Config
+}
+
+// signedIn returns a client signed in as the identity. Clients from the
+// credential cache are rebuilt each time, so a cache renewed by kinit is
+// picked up without a restart.
+func (k *kerberosIdentity) signedIn() (*client.Client, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.client != nil {
+		return k.client, nil
+	}
+
+	krbConf, err := krb5config.Load(k.krb5Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", k.krb5Config, err)
+	}
+	if k.keytab == "" {
+		ccache, err := credentials.LoadCCache(credentialCachePath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credential cache: %w", err)
+		}
+		return client.NewFromCCache(ccache, krbConf, client.DisablePAFXFAST(true))
+	}
+
+	kt, err := keytab.Load(k.keytab)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keytab %s: %w", k.keytab, err)
+	}
+	cl := client.NewWithKeytab(k.principal, k.realm, kt, krbConf, client.DisablePAFXFAST(true))
+	if err := cl.Login(); err != nil {
+		return nil, fmt.Errorf("failed to sign in as %s@%s: %w", k.principal, k.realm, err)
+	}
+	k.client = cl
+	return cl, nil
+}
+
+// credentialCachePath returns the file KRB5CCNAME names, or the default
+// /tmp/krb5cc_<uid>
+func credentialCachePath() string {
+	if name := os.Getenv("KRB5CCNAME"); name != "" {
+		return strings.TrimPrefix(name, "FILE:")
+	}
+	return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+}
+
+// kerberosGSS answers a Postgres server's GSSAPI authentication request
+// with a ticket for the identity registered for its SPN
+type kerberosGSS struct{}
+
+// GetInitToken returns the initial token for service on host, the SPN
+// lib/pq uses when the DSN sets no krbspn
+func (g *kerberosGSS) GetInitToken(host, service string) ([]byte, error) {
+	return g.GetInitTokenFromSpn(service + "/" + host)
+}
+
+// GetInitTokenFromSpn returns an AP-REQ token for the SPN
+func (g *kerberosGSS) GetInitTokenFromSpn(spn string) ([]byte, error) {
+	kerberosSPNs.Lock()
+	identity, ok := kerberosSPNs.identities[spn]
+	kerberosSPNs.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("kerberos: no datasource with auth.method kerberos signs in to %s", spn)
+	}
+
+	cl, err := identity.signedIn()
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: datasource %s: %w", identity.source, err)
+	}
+	ticket, key, err := cl.GetServiceTicket(spn)
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: failed to get a service ticket for %s: %w", spn, err)
+	}
+	token, err := spnego.NewKRB5TokenAPREQ(cl, ticket, key, []int{gssapi.ContextFlagInteg, gssapi.ContextFlagConf}, []int{})
+	if err != nil {
+		return nil, fmt.Errorf("kerberos: failed to build the authentication token: %w", err)
+	}
+	return token.Marshal()
+}
+
+// Continue accepts the server's reply, ending the exchange unless it is a
+// Kerberos error
+func (g *kerberosGSS) Continue(inToken []byte) (bool, []byte, error) {
+	var token spnego.KRB5Token
+	if err := token.Unmarshal(inToken); err != nil {
+		return true, nil, fmt.Errorf("kerberos: invalid server token: %w", err)
+	}
+	if token.IsKRBError() {
+		return true, nil, fmt.Errorf("kerberos: server refused the ticket: %s", token.KRBError.EText)
+	}
+	return true, nil, nil
+}
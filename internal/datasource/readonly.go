@@ -0,0 +1,48 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWritableCredentials is returned for a datasource whose credentials can
+// modify data when it does not set allow_writes
+var ErrWritableCredentials = errors.New("datasource credentials have write privileges")
+
+// writeCheckTimeout bounds the privilege check of a new connection
+const writeCheckTimeout = 5 * time.Second
+
+// WriteChecker is implemented by connectors that can tell whether a
+// connection's credentials may modify data
+type WriteChecker interface {
+	CanWrite(ctx context.Context, db *sql.DB) (bool, error)
+}
+
+// guardWrites refuses a connection whose credentials can write, unless the
+// datasource allows writes. Kinds without a WriteChecker pass. Once the
+// credentials are found read-only they are not checked again.
+func (c *DatasourceConnector) guardWrites() error {
+	if c.AllowWrites || c.writesChecked {
+		return nil
+	}
+	checker, ok := c.driver.(WriteChecker)
+	if !ok {
+		c.writesChecked = true
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeCheckTimeout)
+	defer cancel()
+	canWrite, err := checker.CanWrite(ctx, c.DB)
+	if err != nil {
+		return fmt.Errorf("failed to check write privileges: %w", err)
+	}
+	if canWrite {
+		return fmt.Errorf("%w; grant it read-only access or set allow_writes", ErrWritableCredentials)
+	}
+	c.writesChecked = true
+	return nil
+}
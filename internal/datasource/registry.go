@@ -3,18 +3,29 @@ package datasource
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/secrets"
 	"github.com/NubeDev/air/internal/store"
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
-	_ "github.com/mattn/go-sqlite3"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrDatasourceNotFound is returned for datasource IDs that are not registered
+	ErrDatasourceNotFound = errors.New("datasource not found")
+	// ErrDatasourceUnavailable is returned when a registered datasource has no
+	// open connection
+	ErrDatasourceUnavailable = errors.New("datasource unavailable")
+)
+
+// maxIdleConns is the idle connections each datasource pool keeps
+const maxIdleConns = 5
+
 // Registry manages multiple datasource connections
 type Registry struct {
 	config      *config.Config
@@ -29,10 +40,34 @@ type DatasourceConnector struct {
 	Kind         string
 	DisplayName  string
 	IsDefault    bool
+	Timezone     string // zone naive timestamps are stored in
+	MaxRows      int    // result row limit; 0 uses safety.max_row_limit
+	MaxBytes     int    // result size limit in bytes; 0 uses safety.max_result_bytes
+	SSHTunnel    config.SSHTunnelConfig
+	Proxy        string
+	Auth         config.DatasourceAuthConfig
+	Session      []string // name=value session properties set on every connection
+	Labels       []string // name=value labels on every query job
+	NeedsReview  bool     // report versions run here need a reviewer's approval
+	Sandbox      string   // staging copy report versions are validated on first
+	NoExternalAI bool     // data from here may only be sent to local models
+	AllowWrites  bool     // opened read-write; credentials that can write are accepted
+	Credentials  []config.CredentialConfig
 	DB           *sql.DB
 	LastHealth   time.Time
 	HealthStatus string // "healthy", "unhealthy", "unknown"
 	Error        error
+
+	driver  Connector // nil when the kind has no registered connector
+	dialer  dialer    // nil when the database is dialed directly
+	breaker circuit
+
+	writesChecked bool // the credentials were found unable to write
+
+	// openAs opens a pool signed in as a credential; pools holds those open
+	openAs  func(credential config.CredentialConfig) (*sql.DB, error)
+	poolsMu sync.Mutex
+	pools   map[string]*sql.DB
 }
 
 // NewRegistry creates a new datasource registry
@@ -90,13 +125,39 @@ func (r *Registry) initializeFromConfig() {
 
 // createConnector creates a new datasource connector
 func (r *Registry) createConnector(sourceConfig config.AnalyticsSourceConfig) (*DatasourceConnector, error) {
-	db, err := r.openConnection(sourceConfig.Kind, sourceConfig.DSN)
+	sourceConfig.AllowWrites = sourceConfig.AllowWrites || r.config.WritableSource(sourceConfig.ID)
+	dial, err := newDialer(sourceConfig)
+	var tokens tokenSource
+	if err == nil {
+		tokens, err = newTokenSource(sourceConfig)
+	}
+	var db *sql.DB
+	var conn Connector
+	if err == nil {
+		db, conn, err = r.openConnection(sourceConfig, dial, tokens)
+	}
 	if err != nil {
+		if dial != nil {
+			dial.Close()
+		}
 		return &DatasourceConnector{
 			ID:           sourceConfig.ID,
 			Kind:         sourceConfig.Kind,
 			DisplayName:  sourceConfig.DisplayName,
 			IsDefault:    sourceConfig.Default,
+			Timezone:     sourceConfig.Timezone,
+			MaxRows:      sourceConfig.MaxRows,
+			MaxBytes:     sourceConfig.MaxResultBytes,
+			SSHTunnel:    sourceConfig.SSHTunnel,
+			Proxy:        sourceConfig.Proxy,
+			Auth:         sourceConfig.Auth,
+			Session:      sourceConfig.SessionProperties,
+			Labels:       sourceConfig.Labels,
+			NeedsReview:  sourceConfig.RequireApproval,
+			Sandbox:      sourceConfig.Sandbox,
+			NoExternalAI: sourceConfig.NoExternalAI,
+			AllowWrites:  sourceConfig.AllowWrites,
+			Credentials:  sourceConfig.Credentials,
 			HealthStatus: "unhealthy",
 			Error:        err,
 		}, err
@@ -107,45 +168,117 @@ func (r *Registry) createConnector(sourceConfig config.AnalyticsSourceConfig) (*
 		Kind:         sourceConfig.Kind,
 		DisplayName:  sourceConfig.DisplayName,
 		IsDefault:    sourceConfig.Default,
+		Timezone:     sourceConfig.Timezone,
+		MaxRows:      sourceConfig.MaxRows,
+		MaxBytes:     sourceConfig.MaxResultBytes,
+		SSHTunnel:    sourceConfig.SSHTunnel,
+		Proxy:        sourceConfig.Proxy,
+		Auth:         sourceConfig.Auth,
+		Session:      sourceConfig.SessionProperties,
+		Labels:       sourceConfig.Labels,
+		NeedsReview:  sourceConfig.RequireApproval,
+		Sandbox:      sourceConfig.Sandbox,
+		NoExternalAI: sourceConfig.NoExternalAI,
+		AllowWrites:  sourceConfig.AllowWrites,
+		Credentials:  sourceConfig.Credentials,
 		DB:           db,
+		driver:       conn,
+		dialer:       dial,
 		LastHealth:   time.Now(),
 		HealthStatus: "healthy",
 	}
 
-	// Test connection
-	if err := connector.TestConnection(); err != nil {
-		connector.HealthStatus = "unhealthy"
-		connector.Error = err
+	connector.openAs = func(credential config.CredentialConfig) (*sql.DB, error) {
+		opts := openOptions(sourceConfig, dial)
+		opts.User = credential.User
+		opts.Password = func(ctx context.Context) (string, error) {
+			return secrets.Resolve(credential.Password)
+		}
+		db, err := conn.Open(sourceConfig.DSN, opts)
+		if err != nil {
+			return nil, err
+		}
+		setPoolLimits(db)
+		return db, nil
+	}
+
+	// Test connection; credentials that can write are refused outright
+	if err := connector.Probe(); errors.Is(err, ErrWritableCredentials) {
+		connector.close()
+		return connector, err
 	}
 
 	return connector, nil
 }
 
-// openConnection opens a database connection based on the kind
-func (r *Registry) openConnection(kind, dsn string) (*sql.DB, error) {
-	var driver string
-	switch kind {
-	case "postgres", "timescaledb":
-		driver = "postgres"
-	case "mysql":
-		driver = "mysql"
-	case "sqlite":
-		driver = "sqlite3"
-	default:
-		return nil, fmt.Errorf("unsupported database kind: %s", kind)
-	}
-
-	db, err := sql.Open(driver, dsn)
+// openConnection opens a pool with the source kind's connector. With a
+// dialer, connections go through its SSH tunnel or proxy; with a token
+// source, each connection signs in with a fresh token.
+func (r *Registry) openConnection(source config.AnalyticsSourceConfig, dial dialer, tokens tokenSource) (*sql.DB, Connector, error) {
+	conn, err := Lookup(source.Kind)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := openOptions(source, dial)
+	if tokens != nil {
+		opts.Password, opts.Token = tokens.Token, true
+	}
+	db, err := conn.Open(source.DSN, opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	setPoolLimits(db)
+
+	return db, conn, nil
+}
 
-	// Set connection pool settings
+// openOptions are the options every pool of a source opens with, whoever
+// it signs in as
+func openOptions(source config.AnalyticsSourceConfig, dial dialer) OpenOptions {
+	opts := OpenOptions{
+		ReadOnly:          !source.AllowWrites,
+		SessionProperties: nameValues(source.SessionProperties),
+		Labels:            nameValues(source.Labels),
+	}
+	if dial != nil {
+		opts.Dial = dial.DialContext
+	}
+	return opts
+}
+
+// nameValues parses name=value entries, or returns nil for none
+func nameValues(entries []string) map[string]string {
+	if len(entries) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name, value, _ := strings.Cut(entry, "=")
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+// setPoolLimits applies the connection pool settings
+func setPoolLimits(db *sql.DB) {
 	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
+	db.SetMaxIdleConns(maxIdleConns)
 	db.SetConnMaxLifetime(time.Hour)
+}
 
-	return db, nil
+// Connected readies the connector for a query. It returns
+// ErrDatasourceUnavailable, with the connect error if any, when the connector
+// has no open connection, cannot be reconnected, or is backing off after
+// failed reconnects.
+func (c *DatasourceConnector) Connected() error {
+	if c.DB != nil {
+		return c.checkout()
+	}
+	if c.Error != nil {
+		return fmt.Errorf("%w: %s: %v", ErrDatasourceUnavailable, c.ID, c.Error)
+	}
+	return fmt.Errorf("%w: %s", ErrDatasourceUnavailable, c.ID)
 }
 
 // TestConnection tests the datasource connection
@@ -157,9 +290,35 @@ func (c *DatasourceConnector) TestConnection() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// A dead tunnel is reported as such rather than as a failed ping
+	if c.dialer != nil {
+		if err := c.dialer.Check(ctx); err != nil {
+			return err
+		}
+	}
 	return c.DB.PingContext(ctx)
 }
 
+// close closes the connection pool and any tunnel under it
+func (c *DatasourceConnector) close() error {
+	var err error
+	if c.DB != nil {
+		err = c.DB.Close()
+	}
+	c.poolsMu.Lock()
+	for name, pool := range c.pools {
+		pool.Close()
+		delete(c.pools, name)
+	}
+	c.poolsMu.Unlock()
+	if c.dialer != nil {
+		if dialErr := c.dialer.Close(); err == nil {
+			err = dialErr
+		}
+	}
+	return err
+}
+
 // GetDatasource returns a datasource connector by ID
 func (r *Registry) GetDatasource(id string) (*DatasourceConnector, error) {
 	r.mu.RLock()
@@ -167,7 +326,7 @@ func (r *Registry) GetDatasource(id string) (*DatasourceConnector, error) {
 
 	connector, exists := r.datasources[id]
 	if !exists {
-		return nil, fmt.Errorf("datasource not found: %s", id)
+		return nil, fmt.Errorf("%w: %s", ErrDatasourceNotFound, id)
 	}
 
 	return connector, nil
@@ -236,6 +395,79 @@ func (r *Registry) AddDatasource(id, kind, dsn, displayName string, isDefault bo
 	return nil
 }
 
+// UpdateDatasource changes a datasource's settings and reconnects it. When the
+// new connection cannot be opened the previous settings and connection stay.
+func (r *Registry) UpdateDatasource(id, kind, dsn, displayName string, isDefault bool) error {
+	var previous store.Datasource
+	if err := r.db.Where("id = ?", id).First(&previous).Error; err != nil {
+		return fmt.Errorf("%w: %s", ErrDatasourceNotFound, id)
+	}
+
+	r.mu.RLock()
+	old := r.datasources[id]
+	r.mu.RUnlock()
+	// Settings that only come from config carry over to the new connection
+	var timezone, proxy string
+	var maxRows, maxBytes int
+	var tunnel config.SSHTunnelConfig
+	var auth config.DatasourceAuthConfig
+	var session, labels []string
+	var needsReview bool
+	var sandbox string
+	var noExternalAI, allowWrites bool
+	var credentials []config.CredentialConfig
+	if old != nil {
+		timezone, maxRows, maxBytes = old.Timezone, old.MaxRows, old.MaxBytes
+		tunnel, proxy, auth, session, labels = old.SSHTunnel, old.Proxy, old.Auth, old.Session, old.Labels
+		needsReview, sandbox, noExternalAI = old.NeedsReview, old.Sandbox, old.NoExternalAI
+		allowWrites, credentials = old.AllowWrites, old.Credentials
+	}
+
+	connector, err := r.createConnector(config.AnalyticsSourceConfig{
+		ID:                id,
+		Kind:              kind,
+		DSN:               dsn,
+		DisplayName:       displayName,
+		Default:           isDefault,
+		Timezone:          timezone,
+		MaxRows:           maxRows,
+		MaxResultBytes:    maxBytes,
+		SSHTunnel:         tunnel,
+		Proxy:             proxy,
+		Auth:              auth,
+		SessionProperties: session,
+		Labels:            labels,
+		RequireApproval:   needsReview,
+		Sandbox:           sandbox,
+		NoExternalAI:      noExternalAI,
+		AllowWrites:       allowWrites,
+		Credentials:       credentials,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create connector: %w", err)
+	}
+
+	err = r.db.Model(&previous).Select("kind", "dsn", "display_name", "is_default").Updates(store.Datasource{
+		Kind:        kind,
+		DSN:         dsn,
+		DisplayName: displayName,
+		IsDefault:   isDefault,
+	}).Error
+	if err != nil {
+		connector.close()
+		return fmt.Errorf("failed to update datasource in database: %w", err)
+	}
+
+	r.mu.Lock()
+	r.datasources[id] = connector
+	r.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
+
+	return nil
+}
+
 // RemoveDatasource removes a datasource from the registry
 func (r *Registry) RemoveDatasource(id string) error {
 	// Check if datasource is in use
@@ -248,9 +480,7 @@ func (r *Registry) RemoveDatasource(id string) error {
 	// Close connection
 	r.mu.Lock()
 	if connector, exists := r.datasources[id]; exists {
-		if connector.DB != nil {
-			connector.DB.Close()
-		}
+		connector.close()
 		delete(r.datasources, id)
 	}
 	r.mu.Unlock()
@@ -271,14 +501,9 @@ func (r *Registry) HealthCheck() map[string]string {
 	results := make(map[string]string)
 
 	for id, connector := range r.datasources {
-		if err := connector.TestConnection(); err != nil {
-			connector.HealthStatus = "unhealthy"
-			connector.Error = err
+		if err := connector.Probe(); err != nil {
 			results[id] = fmt.Sprintf("unhealthy: %v", err)
 		} else {
-			connector.HealthStatus = "healthy"
-			connector.Error = nil
-			connector.LastHealth = time.Now()
 			results[id] = "healthy"
 		}
 	}
@@ -293,10 +518,8 @@ func (r *Registry) Close() error {
 
 	var lastErr error
 	for _, connector := range r.datasources {
-		if connector.DB != nil {
-			if err := connector.DB.Close(); err != nil {
-				lastErr = err
-			}
+		if err := connector.close(); err != nil {
+			lastErr = err
 		}
 	}
 
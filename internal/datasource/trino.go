@@ -0,0 +1,627 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trino is queried over its HTTP client protocol, which database/sql has no
+// driver for in this module, so the connector carries a small one: a
+// statement is POSTed to /v1/statement and its results paged in by following
+// nextUri until the query finishes.
+
+func init() {
+	Register("trino", func() Connector { return trinoConnector{} })
+}
+
+// trinoStatementName names the prepared statement queries with arguments run as
+const trinoStatementName = "air_stmt"
+
+// trinoConfig is a parsed Trino DSN:
+// http[s]://user[:password]@host:port?catalog=hive&schema=default
+type trinoConfig struct {
+	server   string
+	user     string
+	password string
+	catalog  string
+	schema   string
+	session  map[string]string
+}
+
+// parseTrinoDSN parses a Trino DSN; the user is required, as Trino names
+// every query's owner
+func parseTrinoDSN(dsn string) (*trinoConfig, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("trino: invalid dsn: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("trino: dsn must be an http or https url")
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("trino: dsn must name a user")
+	}
+	password, _ := u.User.Password()
+	query := u.Query()
+	return &trinoConfig{
+		server:   u.Scheme + "://" + u.Host,
+		user:     u.User.Username(),
+		password: password,
+		catalog:  query.Get("catalog"),
+		schema:   query.Get("schema"),
+	}, nil
+}
+
+// trinoConnector serves Trino
+type trinoConnector struct{}
+
+// Open opens a pool of Trino HTTP clients. Session properties are sent with
+// every statement; a Password replaces the DSN password for basic auth.
+func (trinoConnector) Open(dsn string, opts OpenOptions) (*sql.DB, error) {
+	cfg, err := parseTrinoDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.session = opts.SessionProperties
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Dial != nil {
+		transport.Proxy = nil
+		transport.DialContext = opts.Dial
+	}
+	return sql.OpenDB(&trinoDriverConnector{
+		cfg:      cfg,
+		client:   &http.Client{Transport: transport},
+		password: opts.Password,
+	}), nil
+}
+
+// Introspect lists the tables and views of the given schemas, each either
+// "schema" in the session catalog or "catalog.schema". Without schemas the
+// session schema is read. Tables outside the session catalog and schema are
+// named catalog.schema.table, as queries must reference them.
+func (trinoConnector) Introspect(ctx context.Context, db *sql.DB, schemas []string) ([]Table, error) {
+	var catalog, schema sql.NullString
+	if err := db.QueryRowContext(ctx, "SELECT current_catalog, current_schema").Scan(&catalog, &schema); err != nil {
+		return nil, fmt.Errorf("failed to read session catalog: %w", err)
+	}
+	if len(schemas) == 0 {
+		if !schema.Valid {
+			return nil, fmt.Errorf("trino: set catalog and schema in the dsn, or name the schemas to learn")
+		}
+		schemas = []string{schema.String}
+	}
+
+	var tables []Table
+	for _, name := range schemas {
+		cat, sch := catalog.String, name
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			cat, sch = name[:i], name[i+1:]
+		}
+		if cat == "" {
+			return nil, fmt.Errorf("trino: schema %q needs a catalog; use catalog.schema", name)
+		}
+		infoSchema := quoteANSI(cat) + ".information_schema"
+
+		names, err := scanTables(ctx, db,
+			"SELECT table_name FROM "+infoSchema+".tables WHERE table_schema = ? ORDER BY table_name", sch)
+		if err != nil {
+			return nil, err
+		}
+		found := introspectTables(ctx, db, names, func(table string) (string, []interface{}) {
+			return `
+				SELECT column_name, data_type, is_nullable, ''
+				FROM ` + infoSchema + `.columns
+				WHERE table_schema = ? AND table_name = ?
+				ORDER BY ordinal_position`, []interface{}{sch, table}
+		})
+		if cat != catalog.String || sch != schema.String {
+			for i := range found {
+				found[i].Name = cat + "." + sch + "." + found[i].Name
+			}
+		}
+		tables = append(tables, found...)
+	}
+	return tables, nil
+}
+
+// Dialect is "trino"
+func (trinoConnector) Dialect() string {
+	return "trino"
+}
+
+// Quote double-quotes an identifier
+func (trinoConnector) Quote(ident string) string {
+	return quoteANSI(ident)
+}
+
+// Explain reads the output row estimate from EXPLAIN (TYPE IO, FORMAT JSON).
+// Connectors without table statistics leave it unknown.
+func (trinoConnector) Explain(ctx context.Context, db *sql.DB, query string) (int64, error) {
+	var raw string
+	if err := db.QueryRowContext(ctx, "EXPLAIN (TYPE IO, FORMAT JSON) "+query).Scan(&raw); err != nil {
+		return 0, err
+	}
+
+	var plan struct {
+		Estimate struct {
+			OutputRowCount json.RawMessage `json:"outputRowCount"`
+		} `json:"estimate"`
+	}
+	if err := json.Unmarshal([]byte(raw), &plan); err != nil {
+		return 0, fmt.Errorf("failed to parse EXPLAIN output: %w", err)
+	}
+	rows, err := strconv.ParseFloat(strings.Trim(string(plan.Estimate.OutputRowCount), `"`), 64)
+	if err != nil || math.IsNaN(rows) || math.IsInf(rows, 0) {
+		return 0, ErrNoEstimate
+	}
+	return int64(rows), nil
+}
+
+// trinoDriverConnector opens Trino connections, which are HTTP clients
+// sharing a transport
+type trinoDriverConnector struct {
+	cfg      *trinoConfig
+	client   *http.Client
+	password func(ctx context.Context) (string, error)
+}
+
+// Connect signs a new connection in with the current password
+func (c *trinoDriverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password := c.cfg.password
+	if c.password != nil {
+		var err error
+		if password, err = c.password(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return &trinoConn{cfg: c.cfg, client: c.client, password: password}, nil
+}
+
+// Driver returns the Trino driver
+func (c *trinoDriverConnector) Driver() driver.Driver {
+	return trinoDriver{}
+}
+
+// trinoDriver opens connections from a DSN
+type trinoDriver struct{}
+
+// Open connects with dsn
+func (trinoDriver) Open(dsn string) (driver.Conn, error) {
+	cfg, err := parseTrinoDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c := &trinoDriverConnector{cfg: cfg, client: &http.Client{}}
+	return c.Connect(context.Background())
+}
+
+// errTrinoTx is returned by Begin; Trino statements run in autocommit mode
+var errTrinoTx = errors.New("trino: transactions are not supported")
+
+// trinoConn runs statements over HTTP. It holds no server-side state, so
+// closing it is free.
+type trinoConn struct {
+	cfg      *trinoConfig
+	client   *http.Client
+	password string
+}
+
+// Prepare returns a statement that is sent when executed
+func (c *trinoConn) Prepare(query string) (driver.Stmt, error) {
+	return &trinoStmt{conn: c, query: query}, nil
+}
+
+// Close does nothing
+func (c *trinoConn) Close() error {
+	return nil
+}
+
+// Begin fails; see errTrinoTx
+func (c *trinoConn) Begin() (driver.Tx, error) {
+	return nil, errTrinoTx
+}
+
+// Ping runs SELECT 1
+func (c *trinoConn) Ping(ctx context.Context) error {
+	rows, err := c.start(ctx, "SELECT 1", nil)
+	if err != nil {
+		return err
+	}
+	return rows.drain()
+}
+
+// QueryContext starts query and returns its rows as they are paged in
+func (c *trinoConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.start(ctx, query, args)
+}
+
+// ExecContext runs query to completion
+func (c *trinoConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.start(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := rows.drain(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(rows.updateCount), nil
+}
+
+// start submits a statement and reads until its columns are known. A query
+// with arguments is sent as a prepared statement executed with them as
+// literals, since the protocol has no parameter binding of its own.
+func (c *trinoConn) start(ctx context.Context, query string, args []driver.NamedValue) (*trinoRows, error) {
+	body := query
+	header := http.Header{}
+	if len(args) > 0 {
+		literals := make([]string, len(args))
+		for i, arg := range args {
+			literal, err := trinoLiteral(arg.Value)
+			if err != nil {
+				return nil, err
+			}
+			literals[i] = literal
+		}
+		header.Set("X-Trino-Prepared-Statement", trinoStatementName+"="+url.QueryEscape(query))
+		body = "EXECUTE " + trinoStatementName + " USING " + strings.Join(literals, ", ")
+	}
+	if c.cfg.catalog != "" {
+		header.Set("X-Trino-Catalog", c.cfg.catalog)
+	}
+	if c.cfg.schema != "" {
+		header.Set("X-Trino-Schema", c.cfg.schema)
+	}
+	if len(c.cfg.session) > 0 {
+		names := make([]string, 0, len(c.cfg.session))
+		for name := range c.cfg.session {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pairs := make([]string, len(names))
+		for i, name := range names {
+			pairs[i] = name + "=" + url.QueryEscape(c.cfg.session[name])
+		}
+		header.Set("X-Trino-Session", strings.Join(pairs, ","))
+	}
+	header.Set("X-Trino-Source", "air")
+
+	resp, err := c.do(ctx, http.MethodPost, c.cfg.server+"/v1/statement", header, body)
+	if err != nil {
+		return nil, err
+	}
+	rows := &trinoRows{conn: c, ctx: ctx}
+	if err := rows.take(resp); err != nil {
+		return nil, err
+	}
+	for rows.columns == nil && rows.next != "" {
+		if err := rows.fetch(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// trinoResponse is one page of a statement's results
+type trinoResponse struct {
+	NextURI     string          `json:"nextUri"`
+	Columns     []trinoColumn   `json:"columns"`
+	Data        [][]interface{} `json:"data"`
+	UpdateCount *int64          `json:"updateCount"`
+	Error       *struct {
+		Message   string `json:"message"`
+		ErrorName string `json:"errorName"`
+	} `json:"error"`
+}
+
+type trinoColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// do sends a protocol request, retrying while the coordinator is busy
+func (c *trinoConn) do(ctx context.Context, method, target string, header http.Header, body string) (*trinoResponse, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("X-Trino-User", c.cfg.user)
+		if c.password != "" {
+			req.SetBasicAuth(c.cfg.user, c.password)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("trino: %w", err)
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			defer resp.Body.Close()
+			var page trinoResponse
+			decoder := json.NewDecoder(resp.Body)
+			decoder.UseNumber()
+			if err := decoder.Decode(&page); err != nil {
+				return nil, fmt.Errorf("trino: failed to decode response: %w", err)
+			}
+			return &page, nil
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			resp.Body.Close()
+			if attempt == 5 {
+				return nil, fmt.Errorf("trino: coordinator returned %s", resp.Status)
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			}
+		default:
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			return nil, fmt.Errorf("trino: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+		}
+	}
+}
+
+// trinoStmt is a statement sent on execution
+type trinoStmt struct {
+	conn  *trinoConn
+	query string
+}
+
+func (s *trinoStmt) Close() error  { return nil }
+func (s *trinoStmt) NumInput() int { return -1 }
+
+func (s *trinoStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *trinoStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *trinoStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *trinoStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// namedValues numbers positional arguments
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// trinoRows pages in a statement's results as they are read
+type trinoRows struct {
+	conn        *trinoConn
+	ctx         context.Context
+	next        string
+	columns     []trinoColumn
+	data        [][]interface{}
+	updateCount int64
+}
+
+// take records a page of results
+func (r *trinoRows) take(page *trinoResponse) error {
+	if page.Error != nil {
+		r.next = ""
+		return fmt.Errorf("trino: %s: %s", page.Error.ErrorName, page.Error.Message)
+	}
+	r.next = page.NextURI
+	if r.columns == nil && len(page.Columns) > 0 {
+		r.columns = page.Columns
+	}
+	r.data = append(r.data, page.Data...)
+	if page.UpdateCount != nil {
+		r.updateCount = *page.UpdateCount
+	}
+	return nil
+}
+
+// fetch follows nextUri for the next page
+func (r *trinoRows) fetch() error {
+	page, err := r.conn.do(r.ctx, http.MethodGet, r.next, nil, "")
+	if err != nil {
+		return err
+	}
+	return r.take(page)
+}
+
+// drain reads the statement to completion
+func (r *trinoRows) drain() error {
+	for r.next != "" {
+		r.data = nil
+		if err := r.fetch(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Columns returns the column names
+func (r *trinoRows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// ColumnTypeDatabaseTypeName returns the column's type without parameters,
+// e.g. VARCHAR or TIMESTAMP WITH TIME ZONE
+func (r *trinoRows) ColumnTypeDatabaseTypeName(i int) string {
+	return strings.ToUpper(trinoBaseType(r.columns[i].Type))
+}
+
+// Close cancels the query when it has not finished
+func (r *trinoRows) Close() error {
+	if r.next == "" {
+		return nil
+	}
+	next := r.next
+	r.next = ""
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, next, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Trino-User", r.conn.cfg.user)
+	if r.conn.password != "" {
+		req.SetBasicAuth(r.conn.cfg.user, r.conn.password)
+	}
+	resp, err := r.conn.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	return resp.Body.Close()
+}
+
+// Next reads the next row, fetching a page when the current one is used up
+func (r *trinoRows) Next(dest []driver.Value) error {
+	for len(r.data) == 0 {
+		if r.next == "" {
+			return io.EOF
+		}
+		if err := r.fetch(); err != nil {
+			return err
+		}
+	}
+	row := r.data[0]
+	r.data = r.data[1:]
+	for i := range dest {
+		value, err := trinoValue(row[i], r.columns[i].Type)
+		if err != nil {
+			return fmt.Errorf("trino: column %s: %w", r.columns[i].Name, err)
+		}
+		dest[i] = value
+	}
+	return nil
+}
+
+// trinoTypeParamsRe matches numeric type parameters such as (10, 2) or (3)
+var trinoTypeParamsRe = regexp.MustCompile(`\(\s*\d+\s*(,\s*\d+\s*)?\)`)
+
+// trinoBaseType strips a type's parameters: decimal(10,2) is decimal,
+// timestamp(3) with time zone is timestamp with time zone and array(varchar)
+// is array
+func trinoBaseType(typ string) string {
+	base := strings.ToLower(trinoTypeParamsRe.ReplaceAllString(typ, ""))
+	if i := strings.IndexByte(base, '('); i >= 0 {
+		base = base[:i]
+	}
+	return strings.TrimSpace(base)
+}
+
+// trinoValue converts a JSON result value to a driver value. Exact decimals
+// stay strings; arrays, maps and rows are returned as JSON text.
+func trinoValue(v interface{}, typ string) (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch trinoBaseType(typ) {
+	case "tinyint", "smallint", "integer", "bigint":
+		if n, ok := v.(json.Number); ok {
+			return n.Int64()
+		}
+	case "real", "double":
+		switch x := v.(type) {
+		case json.Number:
+			return x.Float64()
+		case string:
+			return strconv.ParseFloat(x, 64) // NaN, Infinity and -Infinity come as strings
+		}
+	case "boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "date":
+		if s, ok := v.(string); ok {
+			return time.Parse("2006-01-02", s)
+		}
+	case "timestamp":
+		if s, ok := v.(string); ok {
+			return time.Parse("2006-01-02 15:04:05.999999999", s)
+		}
+	case "timestamp with time zone":
+		if s, ok := v.(string); ok {
+			return parseTrinoTimestampTZ(s)
+		}
+	case "array", "map", "row":
+		raw, err := json.Marshal(v)
+		return string(raw), err
+	default:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	}
+	return fmt.Sprint(v), nil
+}
+
+// parseTrinoTimestampTZ parses "2024-01-02 03:04:05.000 UTC", where the zone
+// is a zone name or an offset such as +01:00
+func parseTrinoTimestampTZ(s string) (time.Time, error) {
+	i := strings.LastIndexByte(s, ' ')
+	if i < 0 {
+		return time.Time{}, fmt.Errorf("invalid timestamp with time zone %q", s)
+	}
+	local, zone := s[:i], s[i+1:]
+	if loc, err := time.LoadLocation(zone); err == nil {
+		return time.ParseInLocation("2006-01-02 15:04:05.999999999", local, loc)
+	}
+	return time.Parse("2006-01-02 15:04:05.999999999 -07:00", s)
+}
+
+// trinoLiteral renders an argument as a SQL literal for EXECUTE ... USING
+func trinoLiteral(v driver.Value) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "NULL", nil
+	case int64:
+		return strconv.FormatInt(x, 10), nil
+	case float64:
+		switch {
+		case math.IsNaN(x):
+			return "nan()", nil
+		case math.IsInf(x, 1):
+			return "infinity()", nil
+		case math.IsInf(x, -1):
+			return "-infinity()", nil
+		}
+		return strings.ToUpper(strconv.FormatFloat(x, 'e', -1, 64)), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case []byte:
+		return "X'" + hex.EncodeToString(x) + "'", nil
+	case string:
+		return "'" + strings.ReplaceAll(x, "'", "''") + "'", nil
+	case time.Time:
+		return "TIMESTAMP '" + x.Format("2006-01-02 15:04:05.999999999") + "'", nil
+	default:
+		return "", fmt.Errorf("trino: unsupported argument type %T", v)
+	}
+}
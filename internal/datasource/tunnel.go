@@ -0,0 +1,253 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+)
+
+// tunnelDialTimeout bounds connecting to a bastion or proxy
+const tunnelDialTimeout = 10 * time.Second
+
+// dialer opens a datasource's database connections over an SSH tunnel or
+// proxy instead of directly
+type dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	// Check reports whether the tunnel or proxy itself is reachable
+	Check(ctx context.Context) error
+	Close() error
+}
+
+// newDialer returns the dialer a source's route asks for, or nil to dial
+// the database directly
+func newDialer(source config.AnalyticsSourceConfig) (dialer, error) {
+	switch {
+	case source.SSHTunnel.Host != "":
+		tunnel, err := newSSHTunnel(source.SSHTunnel)
+		if err != nil {
+			return nil, err
+		}
+		return tunnel, nil
+	case source.Proxy != "":
+		socks, err := newSOCKSProxy(source.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		return socks, nil
+	default:
+		return nil, nil
+	}
+}
+
+// sshTunnel forwards connections through an SSH bastion. The SSH connection
+// is opened on first use and reopened after it drops.
+type sshTunnel struct {
+	addr   string
+	config *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// newSSHTunnel reads the tunnel's key and known hosts; it does not connect
+func newSSHTunnel(cfg config.SSHTunnelConfig) (*sshTunnel, error) {
+	key, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: failed to read key: %w", err)
+	}
+	var signer ssh.Signer
+	if cfg.KeyPassphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.KeyPassphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: failed to parse key %s: %w", cfg.KeyFile, err)
+	}
+
+	knownHostsFile := cfg.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("ssh tunnel: known_hosts_file is required: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeys, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: failed to read known hosts: %w", err)
+	}
+
+	addr := cfg.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	return &sshTunnel{
+		addr: addr,
+		config: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeys,
+			Timeout:         tunnelDialTimeout,
+		},
+	}, nil
+}
+
+// DialContext opens addr from the bastion, reconnecting to the bastion once
+// when its connection has gone
+func (t *sshTunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, addr)
+	if err == nil {
+		return conn, nil
+	}
+	if t.alive(client) {
+		return nil, fmt.Errorf("ssh tunnel: %w", err)
+	}
+	t.drop(client)
+	if client, err = t.connect(); err != nil {
+		return nil, err
+	}
+	conn, err = client.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: %w", err)
+	}
+	return conn, nil
+}
+
+// Check connects to the bastion if needed and sends it a keepalive
+func (t *sshTunnel) Check(ctx context.Context) error {
+	client, err := t.connect()
+	if err != nil {
+		return err
+	}
+	if !t.alive(client) {
+		t.drop(client)
+		return fmt.Errorf("ssh tunnel: %s stopped responding", t.addr)
+	}
+	return nil
+}
+
+// Close closes the bastion connection
+func (t *sshTunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
+}
+
+// connect returns the open bastion connection, opening one if needed
+func (t *sshTunnel) connect() (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	client, err := ssh.Dial("tcp", t.addr, t.config)
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: failed to connect to %s: %w", t.addr, err)
+	}
+	t.client = client
+	return client, nil
+}
+
+// alive sends client a keepalive request
+func (t *sshTunnel) alive(client *ssh.Client) bool {
+	_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
+
+// drop closes client and forgets it, unless another caller already has
+func (t *sshTunnel) drop(client *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	client.Close()
+	if t.client == client {
+		t.client = nil
+	}
+}
+
+// socksProxy dials through a SOCKS5 proxy
+type socksProxy struct {
+	addr   string
+	dialer proxy.ContextDialer
+}
+
+// newSOCKSProxy parses a socks5:// or socks5h:// proxy URL
+func newSOCKSProxy(rawURL string) (*socksProxy, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	d, err := proxy.FromURL(u, &net.Dialer{Timeout: tunnelDialTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	contextDialer, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, fmt.Errorf("proxy: %s does not support dialing with a context", u.Scheme)
+	}
+	return &socksProxy{addr: u.Host, dialer: contextDialer}, nil
+}
+
+// DialContext opens addr through the proxy
+func (p *socksProxy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := p.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	return conn, nil
+}
+
+// Check opens and closes a connection to the proxy
+func (p *socksProxy) Check(ctx context.Context) error {
+	d := net.Dialer{Timeout: tunnelDialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("proxy: %s unreachable: %w", p.addr, err)
+	}
+	return conn.Close()
+}
+
+// Close is a no-op; the proxy keeps no connection of its own
+func (p *socksProxy) Close() error {
+	return nil
+}
+
+// pqDialer adapts a dial function to lib/pq, which prefers DialContext
+// when present
+type pqDialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// DialContext opens address through the tunnel or proxy
+func (d pqDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d(ctx, network, address)
+}
+
+// Dial opens address through the tunnel or proxy
+func (d pqDialer) Dial(network, address string) (net.Conn, error) {
+	return d(context.Background(), network, address)
+}
+
+// DialTimeout opens address through the tunnel or proxy within timeout
+func (d pqDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d(ctx, network, address)
+}
@@ -0,0 +1,10 @@
+// Package e2e is an end-to-end suite: it starts throwaway datasources in
+// Docker, loads the sales fixture and takes each flow through learn, IR,
+// SQL, report run and analysis with a mock model, so a change can be checked
+// against every dialect. Containers are driven through the docker CLI.
+//
+// The suite is behind the e2e build tag:
+//
+//	go test -tags e2e ./internal/e2e
+//	go test -tags e2e ./internal/e2e -run TestFlows/postgres -args -redis=false
+package e2e
@@ -0,0 +1,77 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// container is a throwaway Docker container started for one test
+type container struct {
+	id   string
+	addr string // host:port the container's service port is published on
+}
+
+// startContainer runs image detached with port published on a random local
+// port, skipping t when docker is not installed. The container is removed
+// when t finishes.
+func startContainer(t *testing.T, ctx context.Context, image, port string, env []string, args ...string) *container {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not installed")
+	}
+
+	runArgs := []string{"run", "-d", "--rm", "-p", "127.0.0.1::" + port}
+	for _, e := range env {
+		runArgs = append(runArgs, "-e", e)
+	}
+	runArgs = append(runArgs, image)
+	runArgs = append(runArgs, args...)
+
+	out, err := docker(ctx, runArgs...)
+	if err != nil {
+		t.Fatalf("failed to start %s: %v", image, err)
+	}
+	c := &container{id: strings.TrimSpace(out)}
+	t.Cleanup(c.stop)
+
+	out, err = docker(ctx, "port", c.id, port+"/tcp")
+	if err != nil {
+		t.Fatalf("failed to find published port of %s: %v", image, err)
+	}
+	// One line per address family; the first is the IPv4 one asked for
+	c.addr = strings.TrimSpace(strings.SplitN(out, "\n", 2)[0])
+	return c
+}
+
+// exec runs a command inside the container
+func (c *container) exec(ctx context.Context, command ...string) error {
+	_, err := docker(ctx, append([]string{"exec", c.id}, command...)...)
+	return err
+}
+
+// stop removes the container; errors are ignored since it is disposable
+func (c *container) stop() {
+	docker(context.Background(), "rm", "-f", c.id)
+}
+
+// docker runs the docker CLI and returns its output, with stderr in the
+// error when it fails
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("docker %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("docker %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
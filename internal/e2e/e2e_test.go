@@ -0,0 +1,85 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+)
+
+// testConfig is loaded unless -config names another file; each dialect
+// replaces its analytics source
+const testConfig = `
+server:
+  auth:
+    enabled: false
+analytics_sources:
+  - id: "e2e"
+    kind: "sqlite"
+    dsn: "file::memory:"
+    display_name: "e2e"
+    default: true
+`
+
+var (
+	configPath = flag.String("config", "", "Configuration file; its control plane, analytics sources and models are replaced")
+	withRedis  = flag.Bool("redis", true, "Start Redis and take learn and run locks through it")
+)
+
+// TestFlows runs every flow against every dialect; -run TestFlows/postgres
+// picks one. SQLite needs no container, so with -redis=false it runs
+// without docker.
+func TestFlows(t *testing.T) {
+	path := *configPath
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load configuration: %v", err)
+	}
+	level := "warn"
+	if testing.Verbose() {
+		level = cfg.Telemetry.Level
+	}
+	logger.SetupLogger(&logger.LoggerConfig{
+		Level:      level,
+		Format:     cfg.Telemetry.Format,
+		TimeFormat: cfg.Telemetry.TimeFormat,
+		Color:      cfg.Telemetry.Color,
+	})
+
+	ctx := context.Background()
+	var locks *redis.Client
+	if *withRedis {
+		locks = startRedis(t, ctx)
+	}
+
+	names := make([]string, 0, len(Dialects))
+	for name := range Dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			env := setupDialect(t, ctx, *cfg, name, Dialects[name], locks)
+			for _, flow := range Flows {
+				t.Run(flow.ID, func(t *testing.T) {
+					runFlow(t, ctx, env, flow)
+				})
+			}
+		})
+	}
+}
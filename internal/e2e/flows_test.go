@@ -0,0 +1,101 @@
+//go:build e2e
+
+package e2e
+
+// Dialect is a datasource kind the harness can run against, and how to get
+// a throwaway database of that kind
+type Dialect struct {
+	Kind  string
+	Image string // empty for SQLite, which needs no container
+	Port  string
+	Env   []string
+	DSN   func(addr string) string
+	// Init runs in the container once it accepts connections
+	Init [][]string
+}
+
+// Dialects are the supported datasource kinds, by name
+var Dialects = map[string]Dialect{
+	"sqlite": {Kind: "sqlite"},
+	"postgres": {
+		Kind:  "postgres",
+		Image: "postgres:16",
+		Port:  "5432",
+		Env:   []string{"POSTGRES_USER=air", "POSTGRES_PASSWORD=air", "POSTGRES_DB=sales"},
+		DSN: func(addr string) string {
+			return "postgres://air:air@" + addr + "/sales?sslmode=disable"
+		},
+	},
+	"mysql": {
+		Kind:  "mysql",
+		Image: "mysql:8.0",
+		Port:  "3306",
+		Env:   []string{"MYSQL_ROOT_PASSWORD=air", "MYSQL_DATABASE=sales"},
+		// The fixture is one multi-statement script
+		DSN: func(addr string) string {
+			return "root:air@tcp(" + addr + ")/sales?multiStatements=true"
+		},
+		// CONVERT_TZ needs the zone tables, which the image leaves empty
+		Init: [][]string{{"sh", "-c", "mysql_tzinfo_to_sql /usr/share/zoneinfo 2>/dev/null | mysql -uroot -pair mysql"}},
+	},
+}
+
+// redisImage backs the learn and run locks
+const redisImage = "redis:7-alpine"
+
+// Flow is one question taken from scope to analysis. The mock model answers
+// IR building with IR, so the flow checks what AIR does with it: SQL
+// generation for the dialect, running the report and analyzing the run.
+type Flow struct {
+	ID       string
+	Question string
+	IR       string // JSON the mock model returns for the scope
+	Params   map[string]interface{}
+	Expect   Expectation
+}
+
+// Expectation is what a flow's report run must return on the sales fixture
+type Expectation struct {
+	Rows   int
+	Column string  // numeric column whose values are summed
+	Sum    float64 // expected total of Column, to the cent
+}
+
+// Flows run against testdata/eval/sales.sql in every dialect
+var Flows = []Flow{
+	{
+		ID:       "revenue-by-region",
+		Question: "Revenue and order count per customer region for orders with a given status.",
+		IR: `{"dataset": "orders",
+			"select": ["customers.region", {"SUM(orders.total_amount)": "revenue"}, {"COUNT(*)": "order_count"}],
+			"joins": [{"dataset": "customers", "type": "inner", "on": [{"left": "orders.customer_id", "right": "customers.customer_id"}]}],
+			"filters": [{"field": "orders.order_status", "op": "=", "value": "{{status}}"}],
+			"group_by": ["customers.region"],
+			"order_by": [{"field": "revenue", "dir": "DESC"}]}`,
+		Params: map[string]interface{}{"status": "delivered"},
+		Expect: Expectation{Rows: 3, Column: "revenue", Sum: 7590.83},
+	},
+	{
+		ID:       "units-by-category",
+		Question: "Units sold per product category in the first quarter of 2024.",
+		IR: `{"dataset": "orders",
+			"select": ["product_category", {"SUM(quantity)": "units"}],
+			"filters": [{"field": "order_date", "op": "BETWEEN", "value": ["2024-01-01", "2024-03-31"]}],
+			"group_by": ["product_category"],
+			"order_by": [{"field": "product_category", "dir": "ASC"}],
+			"limit": 10}`,
+		Expect: Expectation{Rows: 3, Column: "units", Sum: 26},
+	},
+	{
+		ID:       "monthly-revenue",
+		Question: "Monthly revenue between a start and end date.",
+		IR: `{"dataset": "orders",
+			"timeseries": {"time_field": "order_date", "metrics": [{"field": "total_amount", "agg": "sum", "as": "revenue"}], "resample": "1mo"}}`,
+		Params: map[string]interface{}{"start_date": "2024-01-01", "end_date": "2024-12-31"},
+		Expect: Expectation{Rows: 3, Column: "revenue", Sum: 8850.78},
+	},
+}
+
+// analysisReply is the mock model's verdict on every run
+const analysisReply = `{"verdict": {"score": 90, "severity": "info", "key_findings": ["results returned"], "anomalies": [], "recommendations": []},
+"analysis_md": "Results look consistent with the question."}`
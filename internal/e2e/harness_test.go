@@ -0,0 +1,325 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// readyTimeout bounds how long a container may take to accept connections
+const readyTimeout = 2 * time.Minute
+
+// fixture is the setup script loaded into every datasource
+const fixture = "../../testdata/eval/sales.sql"
+
+// dialectEnv is what the flows of one dialect share
+type dialectEnv struct {
+	datasourceID string
+	sources      *services.DatasourceService
+	reports      *services.ReportsService
+	ai           *services.AIService
+	mock         *llm.MockClient
+}
+
+// setupDialect starts the dialect's database with its own throwaway control
+// plane, loads the fixture and learns it. cfg supplies everything but the
+// control plane, analytics sources and models, which are replaced.
+func setupDialect(t *testing.T, ctx context.Context, cfg config.Config, name string, dialect Dialect, locks *redis.Client) *dialectEnv {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	dsn := filepath.Join(tmpDir, "sales.db")
+	if dialect.Image != "" {
+		c := startContainer(t, ctx, dialect.Image, dialect.Port, dialect.Env)
+		dsn = dialect.DSN(c.addr)
+		if err := waitForDatabase(ctx, dialect.Kind, dsn); err != nil {
+			t.Fatal(err)
+		}
+		for _, command := range dialect.Init {
+			if err := c.exec(ctx, command...); err != nil {
+				t.Fatalf("container init failed: %v", err)
+			}
+		}
+	}
+
+	datasourceID := "e2e_" + name
+	cfg.ControlPlane.DSN = filepath.Join(tmpDir, "air.db")
+	cfg.AnalyticsSources = []config.AnalyticsSourceConfig{{
+		ID:          datasourceID,
+		Kind:        dialect.Kind,
+		DSN:         dsn,
+		DisplayName: "e2e " + name,
+		Default:     true,
+		AllowWrites: true, // the fixture script seeds it
+	}}
+	// The IR is scripted, so SQL comes from the dialect's own compiler
+	cfg.Models.SQLGenerator = config.SQLGeneratorConfig{Type: "deterministic"}
+
+	db, err := gorm.Open(sqlite.Open(cfg.ControlPlane.DSN), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open control plane: %v", err)
+	}
+	if err := store.AutoMigrate(db); err != nil {
+		t.Fatalf("failed to migrate control plane: %v", err)
+	}
+
+	registry := datasource.NewRegistry(&cfg, db)
+	t.Cleanup(func() { registry.Close() })
+	connector, err := registry.GetDatasource(datasourceID)
+	if err != nil {
+		t.Fatalf("datasource unavailable: %v", err)
+	}
+	if err := connector.Connected(); err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	if _, err := connector.DB.ExecContext(ctx, string(script)); err != nil {
+		t.Fatalf("fixture %s failed: %v", fixture, err)
+	}
+
+	env := &dialectEnv{datasourceID: datasourceID}
+	env.sources = services.NewDatasourceService(registry, db)
+	env.reports = services.NewReportsService(registry, db, &cfg)
+	if locks != nil {
+		env.sources.SetLocks(locks)
+		env.reports.SetLocks(locks)
+	}
+
+	if err := env.sources.LearnDatasource(store.LearnDatasourceRequest{DatasourceID: datasourceID}); err != nil {
+		t.Fatalf("learn failed: %v", err)
+	}
+	if err := checkLearned(env.sources, datasourceID, "customers", "orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	env.ai, err = services.NewAIService(registry, db, &cfg, env.sources)
+	if err != nil {
+		t.Fatalf("failed to create AI service: %v", err)
+	}
+	env.mock = llm.NewMockClient(nil)
+	if err := env.ai.SetLLMClients(env.mock, env.mock); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Running e2e flows", map[string]interface{}{
+		"dialect": name,
+		"flows":   len(Flows),
+	})
+	return env
+}
+
+// runFlow takes one flow from scope to analysis, failing t with the step
+// that went wrong
+func runFlow(t *testing.T, ctx context.Context, env *dialectEnv, flow Flow) {
+	t.Helper()
+	env.mock.SetReply(mockReply(flow))
+
+	scope, err := env.reports.CreateScope(store.CreateScopeRequest{Name: "e2e: " + flow.ID})
+	if err != nil {
+		t.Fatalf("scope: %v", err)
+	}
+	version, err := env.reports.CreateScopeVersion(scope.ID, store.CreateScopeVersionRequest{ScopeMD: flow.Question})
+	if err != nil {
+		t.Fatalf("scope: %v", err)
+	}
+
+	ir, err := env.ai.BuildIR(ctx, store.BuildIRRequest{ScopeVersionID: version.ID, DatasourceID: env.datasourceID})
+	if err != nil {
+		t.Fatalf("ir: %v", err)
+	}
+
+	sqlText, _, err := env.ai.GenerateSQLFromIR(ctx, store.GenerateSQLRequest{IR: ir, DatasourceID: env.datasourceID})
+	if err != nil {
+		t.Fatalf("sql: %v", err)
+	}
+	t.Logf("sql: %s", sqlText)
+
+	key := "e2e_" + strings.ReplaceAll(flow.ID, "-", "_")
+	if _, err := env.reports.CreateSQLReport(store.CreateSQLReportRequest{
+		Key:          key,
+		Title:        flow.Question,
+		DatasourceID: env.datasourceID,
+		SQL:          sqlText,
+	}); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+
+	params := flow.Params
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	run, err := env.reports.RunReport(key, store.RunReportRequest{Params: params})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if run.Status != "completed" {
+		t.Fatalf("run: run %s: %s", run.Status, run.ErrorText)
+	}
+	if err := checkResults(run.Results, flow.Expect); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+
+	if _, err := env.ai.AnalyzeRun(ctx, run.ID, store.AnalyzeRunRequest{}); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+}
+
+// mockReply answers the model calls of a flow, told apart by their system
+// prompts: IR building gets the flow's IR and run analysis a fixed verdict
+func mockReply(flow Flow) llm.MockReply {
+	return func(req llm.ChatRequest) (string, error) {
+		var system string
+		if len(req.Messages) > 0 && req.Messages[0].Role == "system" {
+			system = req.Messages[0].Content
+		}
+		switch {
+		case strings.Contains(system, "Intermediate Representation"):
+			return flow.IR, nil
+		case strings.Contains(system, "Analyze the SQL execution results"):
+			return analysisReply, nil
+		}
+		return "", fmt.Errorf("mock model has no reply for this request")
+	}
+}
+
+// checkLearned fails unless learning found each of the objects
+func checkLearned(sources *services.DatasourceService, datasourceID string, objects ...string) error {
+	notes, err := sources.GetSchema(datasourceID)
+	if err != nil {
+		return fmt.Errorf("failed to read learned schema: %w", err)
+	}
+	learned := make(map[string]bool, len(notes))
+	for _, note := range notes {
+		learned[strings.ToLower(note.Object)] = true
+	}
+	for _, object := range objects {
+		if !learned[object] {
+			return fmt.Errorf("learn did not find table %s", object)
+		}
+	}
+	return nil
+}
+
+// checkResults compares a run's rows with the expectation. Drivers return
+// numeric columns as numbers or strings, so both are summed.
+func checkResults(resultsJSON string, expect Expectation) error {
+	results, err := services.ParseRunResults(resultsJSON)
+	if err != nil {
+		return err
+	}
+	if len(results.Rows) != expect.Rows {
+		return fmt.Errorf("got %d rows, want %d", len(results.Rows), expect.Rows)
+	}
+	if expect.Column == "" {
+		return nil
+	}
+
+	column := -1
+	for i, c := range results.Columns {
+		if c.Name == expect.Column {
+			column = i
+		}
+	}
+	if column < 0 {
+		return fmt.Errorf("results have no column %s", expect.Column)
+	}
+
+	var sum float64
+	for _, row := range results.Rows {
+		value := row[column]
+		switch v := value.(type) {
+		case float64:
+			sum += v
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("column %s value %q is not a number", expect.Column, v)
+			}
+			sum += f
+		default:
+			return fmt.Errorf("column %s value %v is not a number", expect.Column, value)
+		}
+	}
+	if math.Abs(sum-expect.Sum) > 0.005 {
+		return fmt.Errorf("%s totals %.2f, want %.2f", expect.Column, sum, expect.Sum)
+	}
+	return nil
+}
+
+// startRedis starts Redis for the locks and waits until it answers
+func startRedis(t *testing.T, ctx context.Context) *redis.Client {
+	t.Helper()
+	c := startContainer(t, ctx, redisImage, "6379", nil)
+	client, err := redis.NewClient(&config.RedisConfig{
+		Enabled:   true,
+		URL:       "redis://" + c.addr + "/0",
+		KeyPrefix: "air-e2e",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	deadline := time.Now().Add(readyTimeout)
+	for {
+		err := client.Ping(ctx)
+		if err == nil {
+			return client
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("redis not ready after %s: %v", readyTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// waitForDatabase polls a freshly started database until it accepts
+// connections; servers restart once while initializing, so the first
+// successful ping can come late
+func waitForDatabase(ctx context.Context, kind, dsn string) error {
+	driver := kind
+	if kind == "timescaledb" {
+		driver = "postgres"
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", kind, err)
+	}
+	defer db.Close()
+
+	deadline := time.Now().Add(readyTimeout)
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s not ready after %s: %w", kind, readyTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
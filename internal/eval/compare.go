@@ -0,0 +1,208 @@
+package eval
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// floatTolerance absorbs rounding differences between equivalent aggregates
+const floatTolerance = 1e-6
+
+// resultSet is a query result with values normalized for comparison
+type resultSet struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// runQuery executes sqlText and normalizes driver-specific value types
+func runQuery(ctx context.Context, db *sql.DB, sqlText string) (*resultSet, error) {
+	rows, err := db.QueryContext(ctx, sqlText)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &resultSet{Columns: cols}
+	values := make([]interface{}, len(cols))
+	scanArgs := make([]interface{}, len(cols))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, err
+		}
+		row := make([]interface{}, len(cols))
+		for i, v := range values {
+			row[i] = normalizeValue(v)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+// normalizeValue maps driver values onto float64, string, bool or nil so
+// NUMERIC-as-text (Postgres) and REAL (SQLite) compare equal
+func normalizeValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return normalizeValue(string(val))
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			return f
+		}
+		return val
+	case int64:
+		return float64(val)
+	case int32:
+		return float64(val)
+	case int:
+		return float64(val)
+	case float32:
+		return float64(val)
+	case float64:
+		return val
+	case bool:
+		return val
+	case time.Time:
+		if val.Hour() == 0 && val.Minute() == 0 && val.Second() == 0 && val.Nanosecond() == 0 {
+			return val.Format("2006-01-02")
+		}
+		return val.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+func valuesEqual(a, b interface{}) bool {
+	fa, aNum := a.(float64)
+	fb, bNum := b.(float64)
+	if aNum && bNum {
+		diff := math.Abs(fa - fb)
+		return diff <= floatTolerance || diff <= floatTolerance*math.Max(math.Abs(fa), math.Abs(fb))
+	}
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return strings.EqualFold(strings.TrimSpace(as), strings.TrimSpace(bs))
+		}
+	}
+	return a == b
+}
+
+// compareResults checks that got reproduces want. Column names are ignored
+// since aliases are free; columns are matched by position. Unless ordered,
+// rows are compared as multisets.
+func compareResults(want, got *resultSet, ordered bool) []string {
+	if len(want.Columns) != len(got.Columns) {
+		return []string{fmt.Sprintf("expected %d columns, got %d (%s)", len(want.Columns), len(got.Columns), strings.Join(got.Columns, ", "))}
+	}
+	if len(want.Rows) != len(got.Rows) {
+		return []string{fmt.Sprintf("expected %d rows, got %d", len(want.Rows), len(got.Rows))}
+	}
+
+	wantRows, gotRows := want.Rows, got.Rows
+	if !ordered {
+		wantRows, gotRows = sortedRows(wantRows), sortedRows(gotRows)
+	}
+	for i := range wantRows {
+		for j := range wantRows[i] {
+			if !valuesEqual(wantRows[i][j], gotRows[i][j]) {
+				return []string{fmt.Sprintf("row %d column %d: expected %v, got %v", i+1, j+1, wantRows[i][j], gotRows[i][j])}
+			}
+		}
+	}
+	return nil
+}
+
+func sortedRows(rows [][]interface{}) [][]interface{} {
+	sorted := make([][]interface{}, len(rows))
+	copy(sorted, rows)
+	key := func(row []interface{}) string {
+		parts := make([]string, len(row))
+		for i, v := range row {
+			if f, ok := v.(float64); ok {
+				parts[i] = strconv.FormatFloat(f, 'f', 4, 64)
+			} else {
+				parts[i] = strings.ToLower(fmt.Sprintf("%v", v))
+			}
+		}
+		return strings.Join(parts, "\x00")
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return key(sorted[i]) < key(sorted[j]) })
+	return sorted
+}
+
+// checkInvariants returns a message for every invariant the result violates
+func checkInvariants(inv Invariants, sqlText string, got *resultSet) []string {
+	var failures []string
+	n := len(got.Rows)
+	if inv.RowCount != nil && n != *inv.RowCount {
+		failures = append(failures, fmt.Sprintf("expected %d rows, got %d", *inv.RowCount, n))
+	}
+	if inv.MinRows != nil && n < *inv.MinRows {
+		failures = append(failures, fmt.Sprintf("expected at least %d rows, got %d", *inv.MinRows, n))
+	}
+	if inv.MaxRows != nil && n > *inv.MaxRows {
+		failures = append(failures, fmt.Sprintf("expected at most %d rows, got %d", *inv.MaxRows, n))
+	}
+	if inv.Columns > 0 && len(got.Columns) != inv.Columns {
+		failures = append(failures, fmt.Sprintf("expected %d columns, got %d", inv.Columns, len(got.Columns)))
+	}
+
+	for _, want := range inv.Contains {
+		if !containsRow(got, want) {
+			failures = append(failures, fmt.Sprintf("no row matches %v", want))
+		}
+	}
+
+	for _, pattern := range inv.SQLMatch {
+		if re, err := regexp.Compile("(?i)" + pattern); err != nil {
+			failures = append(failures, fmt.Sprintf("invalid sql_match pattern %q: %v", pattern, err))
+		} else if !re.MatchString(sqlText) {
+			failures = append(failures, fmt.Sprintf("SQL does not match %q", pattern))
+		}
+	}
+	for _, pattern := range inv.SQLAvoid {
+		if re, err := regexp.Compile("(?i)" + pattern); err != nil {
+			failures = append(failures, fmt.Sprintf("invalid sql_avoid pattern %q: %v", pattern, err))
+		} else if re.MatchString(sqlText) {
+			failures = append(failures, fmt.Sprintf("SQL matches avoided pattern %q", pattern))
+		}
+	}
+	return failures
+}
+
+// containsRow reports whether some row has every column/value pair in want
+func containsRow(got *resultSet, want map[string]interface{}) bool {
+	index := map[string]int{}
+	for i, col := range got.Columns {
+		index[strings.ToLower(col)] = i
+	}
+	for _, row := range got.Rows {
+		match := true
+		for col, val := range want {
+			i, ok := index[strings.ToLower(col)]
+			if !ok || !valuesEqual(row[i], normalizeValue(val)) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
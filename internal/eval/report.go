@@ -0,0 +1,163 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report is the outcome of running a suite for one or more models
+type Report struct {
+	Suite         string         `json:"suite"`
+	DatasourceID  string         `json:"datasource_id"`
+	Kind          string         `json:"kind"`
+	PromptVersion string         `json:"prompt_version"`
+	StartedAt     time.Time      `json:"started_at"`
+	Duration      string         `json:"duration"`
+	Models        []ModelSummary `json:"models"`
+	Cases         []CaseResult   `json:"cases"`
+}
+
+// ModelSummary is a model's accuracy and its changes against a baseline
+type ModelSummary struct {
+	Model          string   `json:"model"` // label combining the IR and SQL models
+	ChatModel      string   `json:"chat_model"`
+	SQLModel       string   `json:"sql_model"`
+	Generator      string   `json:"generator"`
+	Total          int      `json:"total"`
+	Passed         int      `json:"passed"`
+	Accuracy       float64  `json:"accuracy"`
+	Baseline       *float64 `json:"baseline_accuracy,omitempty"`
+	BaselinePrompt string   `json:"baseline_prompt_version,omitempty"`
+	Regressions    []string `json:"regressions,omitempty"` // cases that passed in the baseline
+	Fixed          []string `json:"fixed,omitempty"`       // cases that failed in the baseline
+}
+
+// CaseResult is one case run with one model
+type CaseResult struct {
+	CaseID   string                 `json:"case_id"`
+	Model    string                 `json:"model"`
+	Passed   bool                   `json:"passed"`
+	IR       map[string]interface{} `json:"ir,omitempty"`
+	SQL      string                 `json:"sql,omitempty"`
+	Rows     int                    `json:"rows"`
+	Error    string                 `json:"error,omitempty"`
+	Failures []string               `json:"failures,omitempty"`
+	Duration string                 `json:"duration"`
+}
+
+// LoadReport reads a report written by WriteJSON, typically a baseline
+func LoadReport(path string) (*Report, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// WriteJSON saves the report so later runs can use it as a baseline
+func (r *Report) WriteJSON(path string) error {
+	raw, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// CompareBaseline records, per model, the baseline accuracy and which cases
+// regressed or were fixed. Models missing from the baseline are left as-is.
+func (r *Report) CompareBaseline(baseline *Report) {
+	previous := map[string]map[string]bool{} // model -> case -> passed
+	for _, c := range baseline.Cases {
+		if previous[c.Model] == nil {
+			previous[c.Model] = map[string]bool{}
+		}
+		previous[c.Model][c.CaseID] = c.Passed
+	}
+	accuracy := map[string]float64{}
+	for _, m := range baseline.Models {
+		accuracy[m.Model] = m.Accuracy
+	}
+
+	for i := range r.Models {
+		m := &r.Models[i]
+		cases, ok := previous[m.Model]
+		if !ok {
+			continue
+		}
+		acc := accuracy[m.Model]
+		m.Baseline = &acc
+		m.BaselinePrompt = baseline.PromptVersion
+		for _, c := range r.Cases {
+			if c.Model != m.Model {
+				continue
+			}
+			passedBefore, known := cases[c.CaseID]
+			switch {
+			case known && passedBefore && !c.Passed:
+				m.Regressions = append(m.Regressions, c.CaseID)
+			case known && !passedBefore && c.Passed:
+				m.Fixed = append(m.Fixed, c.CaseID)
+			}
+		}
+		sort.Strings(m.Regressions)
+		sort.Strings(m.Fixed)
+	}
+}
+
+// Regressions counts regressed cases across all models
+func (r *Report) Regressions() int {
+	n := 0
+	for _, m := range r.Models {
+		n += len(m.Regressions)
+	}
+	return n
+}
+
+// WriteText prints a human-readable summary with failing cases
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Suite %s on %s (%s), prompt %s, %s\n", r.Suite, r.DatasourceID, r.Kind, r.PromptVersion, r.Duration)
+	for _, m := range r.Models {
+		label := m.Model
+		if m.Generator != "" {
+			label += " [" + m.Generator + "]"
+		}
+		fmt.Fprintf(w, "\n%s: %d/%d passed (%.1f%%)", label, m.Passed, m.Total, m.Accuracy*100)
+		if m.Baseline != nil {
+			fmt.Fprintf(w, ", baseline %.1f%% (prompt %s)", *m.Baseline*100, m.BaselinePrompt)
+		}
+		fmt.Fprintln(w)
+		if len(m.Regressions) > 0 {
+			fmt.Fprintf(w, "  regressions: %s\n", strings.Join(m.Regressions, ", "))
+		}
+		if len(m.Fixed) > 0 {
+			fmt.Fprintf(w, "  fixed: %s\n", strings.Join(m.Fixed, ", "))
+		}
+		for _, c := range r.Cases {
+			if c.Model != m.Model || c.Passed {
+				continue
+			}
+			fmt.Fprintf(w, "  FAIL %s\n", c.CaseID)
+			if c.Error != "" {
+				fmt.Fprintf(w, "    error: %s\n", c.Error)
+			}
+			for _, f := range c.Failures {
+				fmt.Fprintf(w, "    %s\n", f)
+			}
+			if c.SQL != "" {
+				fmt.Fprintf(w, "    sql: %s\n", strings.Join(strings.Fields(c.SQL), " "))
+			}
+		}
+	}
+}
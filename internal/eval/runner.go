@@ -0,0 +1,287 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// caseTimeout bounds IR building, SQL generation and execution for one case
+const caseTimeout = 3 * time.Minute
+
+// Runner runs a suite through the same BuildIR -> GenerateSQLFromIR pipeline
+// the API uses, against a throwaway control plane so learned schema, scopes
+// and glossary never touch the real one
+type Runner struct {
+	cfg   *config.Config
+	suite *Suite
+}
+
+// NewRunner creates a runner; cfg supplies model settings, its control plane
+// and analytics sources are replaced by the suite's fixture
+func NewRunner(cfg *config.Config, suite *Suite) *Runner {
+	return &Runner{cfg: cfg, suite: suite}
+}
+
+// evalEnv is the per-run control plane and fixture connection
+type evalEnv struct {
+	cfg       config.Config
+	db        *gorm.DB
+	registry  *datasource.Registry
+	connector *datasource.DatasourceConnector
+	sources   *services.DatasourceService
+	reports   *services.ReportsService
+}
+
+// Run evaluates every case for each model. A model spec is "chat" or
+// "chat+sql"; no specs runs the configured models once.
+func (r *Runner) Run(models []string) (*Report, error) {
+	start := time.Now()
+
+	tmpDir, err := os.MkdirTemp("", "air-eval-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create control plane dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	env, err := r.setup(filepath.Join(tmpDir, "air.db"))
+	if err != nil {
+		return nil, err
+	}
+	defer env.registry.Close()
+
+	expected, err := r.expectedResults(env)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Suite:         r.suite.Name,
+		DatasourceID:  r.suite.Datasource.ID,
+		Kind:          r.suite.Datasource.Kind,
+		PromptVersion: services.PromptVersion,
+		StartedAt:     start,
+	}
+	if len(models) == 0 {
+		models = []string{""}
+	}
+	for _, spec := range models {
+		modelCfg := withModel(env.cfg, spec)
+		ai, err := services.NewAIService(env.registry, env.db, modelCfg, env.sources)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AI service for model %q: %w", spec, err)
+		}
+
+		summary := ModelSummary{
+			ChatModel: llm.GetModelName(modelCfg, "chat"),
+			SQLModel:  llm.GetModelName(modelCfg, "sql"),
+		}
+		summary.Model = summary.ChatModel + "+" + summary.SQLModel
+
+		logger.LogInfo(logger.ServiceAI, "Running eval suite", map[string]interface{}{
+			"suite": r.suite.Name,
+			"model": summary.Model,
+			"cases": len(r.suite.Cases),
+		})
+
+		for _, c := range r.suite.Cases {
+			result, generator := r.runCase(env, ai, c, expected[c.ID])
+			result.Model = summary.Model
+			if summary.Generator == "" {
+				summary.Generator = generator
+			}
+			summary.Total++
+			if result.Passed {
+				summary.Passed++
+			}
+			report.Cases = append(report.Cases, result)
+		}
+		summary.Accuracy = float64(summary.Passed) / float64(summary.Total)
+		report.Models = append(report.Models, summary)
+	}
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	return report, nil
+}
+
+// setup opens the throwaway control plane, seeds the fixture and learns its
+// schema and glossary
+func (r *Runner) setup(controlPlaneDSN string) (*evalEnv, error) {
+	fixture := r.suite.Datasource
+	env := &evalEnv{cfg: *r.cfg}
+	env.cfg.ControlPlane.DSN = controlPlaneDSN
+	env.cfg.AnalyticsSources = []config.AnalyticsSourceConfig{{
+		ID:          fixture.ID,
+		Kind:        fixture.Kind,
+		DSN:         fixture.DSN,
+		DisplayName: r.suite.Name,
+		Default:     true,
+		Timezone:    fixture.Timezone,
+		AllowWrites: true, // setup scripts seed the fixture
+	}}
+
+	db, err := gorm.Open(sqlite.Open(controlPlaneDSN), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open control plane: %w", err)
+	}
+	if err := store.AutoMigrate(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate control plane: %w", err)
+	}
+	env.db = db
+
+	env.registry = datasource.NewRegistry(&env.cfg, db)
+	connector, err := env.registry.GetDatasource(fixture.ID)
+	if err != nil {
+		env.registry.Close()
+		return nil, fmt.Errorf("fixture datasource unavailable: %w", err)
+	}
+	if connector.DB == nil || connector.Error != nil {
+		env.registry.Close()
+		return nil, fmt.Errorf("fixture datasource %s unreachable: %v", fixture.ID, connector.Error)
+	}
+	env.connector = connector
+
+	for _, path := range fixture.Setup {
+		script, err := os.ReadFile(path)
+		if err != nil {
+			env.registry.Close()
+			return nil, fmt.Errorf("failed to read setup script: %w", err)
+		}
+		if _, err := connector.DB.Exec(string(script)); err != nil {
+			env.registry.Close()
+			return nil, fmt.Errorf("setup script %s failed: %w", path, err)
+		}
+	}
+
+	env.sources = services.NewDatasourceService(env.registry, db)
+	if err := env.sources.LearnDatasource(store.LearnDatasourceRequest{DatasourceID: fixture.ID}); err != nil {
+		env.registry.Close()
+		return nil, fmt.Errorf("failed to learn fixture schema: %w", err)
+	}
+	if len(r.suite.Glossary) > 0 {
+		if _, err := env.sources.UpsertGlossary(fixture.ID, r.suite.Glossary); err != nil {
+			env.registry.Close()
+			return nil, fmt.Errorf("failed to load suite glossary: %w", err)
+		}
+	}
+	env.reports = services.NewReportsService(env.registry, db, &env.cfg)
+	return env, nil
+}
+
+// expectedResults runs each case's expected SQL once; a failure here is a
+// broken suite rather than a failing case
+func (r *Runner) expectedResults(env *evalEnv) (map[string]*resultSet, error) {
+	results := map[string]*resultSet{}
+	for _, c := range r.suite.Cases {
+		if strings.TrimSpace(c.ExpectedSQL) == "" {
+			continue
+		}
+		sqlText, err := services.PrepareAdHocSQL(env.connector, c.ExpectedSQL, c.Params, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("case %s: %w", c.ID, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+		result, err := runQuery(ctx, env.connector.DB, sqlText)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("case %s: expected SQL failed: %w", c.ID, err)
+		}
+		results[c.ID] = result
+	}
+	return results, nil
+}
+
+// runCase generates and executes SQL for one case; it also returns the name
+// of the SQL generator that was used
+func (r *Runner) runCase(env *evalEnv, ai *services.AIService, c Case, want *resultSet) (CaseResult, string) {
+	start := time.Now()
+	result := CaseResult{CaseID: c.ID}
+	fail := func(format string, args ...interface{}) CaseResult {
+		result.Error = fmt.Sprintf(format, args...)
+		result.Duration = time.Since(start).Round(time.Millisecond).String()
+		return result
+	}
+
+	scope, err := env.reports.CreateScope(store.CreateScopeRequest{Name: "eval: " + c.ID})
+	if err != nil {
+		return fail("%v", err), ""
+	}
+	version, err := env.reports.CreateScopeVersion(scope.ID, store.CreateScopeVersionRequest{ScopeMD: c.Question})
+	if err != nil {
+		return fail("%v", err), ""
+	}
+
+	ir, err := ai.BuildIR(context.Background(), store.BuildIRRequest{ScopeVersionID: version.ID, DatasourceID: r.suite.Datasource.ID})
+	if err != nil {
+		return fail("build IR: %v", err), ""
+	}
+	result.IR = ir
+
+	sqlText, safety, err := ai.GenerateSQLFromIR(context.Background(), store.GenerateSQLRequest{IR: ir, DatasourceID: r.suite.Datasource.ID})
+	generator := ""
+	if checks, ok := safety["checks"].(map[string]any); ok {
+		generator, _ = checks["generated_by"].(string)
+	}
+	if err != nil {
+		return fail("generate SQL: %v", err), generator
+	}
+	result.SQL = sqlText
+
+	if _, err := services.ValidateReadOnlySQL(sqlText); err != nil {
+		return fail("unsafe SQL: %v", err), generator
+	}
+	prepared, err := services.PrepareAdHocSQL(env.connector, sqlText, c.Params, time.Now())
+	if err != nil {
+		return fail("prepare SQL: %v", err), generator
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), caseTimeout)
+	defer cancel()
+	got, err := runQuery(ctx, env.connector.DB, prepared)
+	if err != nil {
+		return fail("execute SQL: %v", err), generator
+	}
+	result.Rows = len(got.Rows)
+
+	if want != nil {
+		result.Failures = append(result.Failures, compareResults(want, got, c.Ordered)...)
+	}
+	result.Failures = append(result.Failures, checkInvariants(c.Expect, sqlText, got)...)
+	result.Passed = len(result.Failures) == 0
+	result.Duration = time.Since(start).Round(time.Millisecond).String()
+	return result, generator
+}
+
+// withModel returns a copy of cfg whose chat route (IR building) and,
+// optionally, SQL route use the models in spec ("chat" or "chat+sql")
+func withModel(cfg config.Config, spec string) *config.Config {
+	if spec == "" {
+		return &cfg
+	}
+	chatModel, sqlModel, _ := strings.Cut(spec, "+")
+	setRoute := func(route, model string, ollamaModel *string) {
+		if route == "openai" && cfg.Models.OpenAI.APIKey != "" && !cfg.Privacy.LocalOnly {
+			cfg.Models.OpenAI.Model = model
+		} else {
+			*ollamaModel = model
+		}
+	}
+	setRoute(cfg.Models.ChatPrimary, chatModel, &cfg.Models.Ollama.Llama3Model)
+	if sqlModel != "" {
+		setRoute(cfg.Models.SQLPrimary, sqlModel, &cfg.Models.Ollama.SQLCoderModel)
+	}
+	return &cfg
+}
@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NubeDev/air/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a set of NL->SQL cases run against one datasource fixture
+type Suite struct {
+	Name       string                      `yaml:"name"`
+	Datasource Fixture                     `yaml:"datasource"`
+	Glossary   []store.GlossaryTermRequest `yaml:"glossary"`
+	Cases      []Case                      `yaml:"cases"`
+}
+
+// Fixture describes the datasource cases run against. Setup scripts are
+// executed in order before the schema is learned, so a fixture can be seeded
+// from scratch on every run.
+type Fixture struct {
+	ID       string   `yaml:"id"`
+	Kind     string   `yaml:"kind"`
+	DSN      string   `yaml:"dsn"`
+	Timezone string   `yaml:"timezone"`
+	Setup    []string `yaml:"setup"` // SQL files, relative to the suite file
+}
+
+// Case is one question with either the SQL whose result the generated SQL
+// must reproduce or invariants its result must satisfy (or both)
+type Case struct {
+	ID          string                 `yaml:"id"`
+	Question    string                 `yaml:"question"`
+	Params      map[string]interface{} `yaml:"params"`
+	ExpectedSQL string                 `yaml:"expected_sql"`
+	Ordered     bool                   `yaml:"ordered"` // compare rows in order
+	Expect      Invariants             `yaml:"expect"`
+	Tags        []string               `yaml:"tags"`
+}
+
+// Invariants are checks on a generated query's result
+type Invariants struct {
+	RowCount *int                     `yaml:"row_count"`
+	MinRows  *int                     `yaml:"min_rows"`
+	MaxRows  *int                     `yaml:"max_rows"`
+	Columns  int                      `yaml:"columns"`  // exact column count
+	Contains []map[string]interface{} `yaml:"contains"` // rows that must appear; keys are matched case-insensitively
+	SQLMatch []string                 `yaml:"sql_match"`
+	SQLAvoid []string                 `yaml:"sql_avoid"`
+}
+
+func (i Invariants) empty() bool {
+	return i.RowCount == nil && i.MinRows == nil && i.MaxRows == nil && i.Columns == 0 &&
+		len(i.Contains) == 0 && len(i.SQLMatch) == 0 && len(i.SQLAvoid) == 0
+}
+
+// LoadSuite reads and validates a YAML suite. Setup paths are resolved
+// relative to the suite file.
+func LoadSuite(path string) (*Suite, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite: %w", err)
+	}
+	var suite Suite
+	if err := yaml.Unmarshal(raw, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse suite %s: %w", path, err)
+	}
+
+	if suite.Name == "" {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i, setup := range suite.Datasource.Setup {
+		if !filepath.IsAbs(setup) {
+			suite.Datasource.Setup[i] = filepath.Join(filepath.Dir(path), setup)
+		}
+	}
+	if err := suite.validate(); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	return &suite, nil
+}
+
+func (s *Suite) validate() error {
+	if s.Datasource.ID == "" || s.Datasource.Kind == "" || s.Datasource.DSN == "" {
+		return fmt.Errorf("datasource needs id, kind and dsn")
+	}
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("no cases")
+	}
+	seen := map[string]bool{}
+	for i, c := range s.Cases {
+		if c.ID == "" {
+			return fmt.Errorf("case %d has no id", i+1)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("duplicate case id %q", c.ID)
+		}
+		seen[c.ID] = true
+		if strings.TrimSpace(c.Question) == "" {
+			return fmt.Errorf("case %q has no question", c.ID)
+		}
+		if strings.TrimSpace(c.ExpectedSQL) == "" && c.Expect.empty() {
+			return fmt.Errorf("case %q needs expected_sql or expect", c.ID)
+		}
+	}
+	return nil
+}
+
+// Filter keeps the cases with one of the given IDs or tags; no filter keeps all
+func (s *Suite) Filter(only []string) {
+	if len(only) == 0 {
+		return
+	}
+	want := map[string]bool{}
+	for _, o := range only {
+		want[o] = true
+	}
+	var kept []Case
+	for _, c := range s.Cases {
+		match := want[c.ID]
+		for _, tag := range c.Tags {
+			match = match || want[tag]
+		}
+		if match {
+			kept = append(kept, c)
+		}
+	}
+	s.Cases = kept
+}
@@ -0,0 +1,332 @@
+// Package graphql executes read-only GraphQL queries against a schema of Go
+// resolvers. It covers what screens need to fetch nested data in one round
+// trip: operations, variables, aliases, arguments, fragments and the
+// @include/@skip directives. Mutations, subscriptions and introspection are
+// not supported.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// maxDepth bounds how deeply selections may nest
+const maxDepth = 8
+
+// Object is a GraphQL object type
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field resolves one field of an Object from its parent value. Type is the
+// object type of the result, nil for scalars; a slice result is a list.
+type Field struct {
+	Type    *Object
+	Args    []string
+	Resolve func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error)
+}
+
+// Schema is the root query type
+type Schema struct {
+	Query *Object
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query" binding:"required"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// Response is the result of a request; Data is null when the request could
+// not be executed at all
+type Response struct {
+	Data   *OrderedMap `json:"data"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is an error of the request or of one field, located by its path
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// OrderedMap is a JSON object that keeps its keys in selection order
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *OrderedMap {
+	return &OrderedMap{values: make(map[string]interface{})}
+}
+
+func (m *OrderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// MarshalJSON writes the keys in the order they were selected
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// executor holds the state of one request
+type executor struct {
+	ctx       context.Context
+	doc       *document
+	variables map[string]interface{}
+	errors    []Error
+}
+
+// Execute runs the query operation of req
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: "syntax error: " + err.Error()}}}
+	}
+	if err := doc.validate(); err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	if op.kind != "query" {
+		return &Response{Errors: []Error{{Message: op.kind + " operations are not supported; the API is read-only"}}}
+	}
+
+	e := &executor{ctx: ctx, doc: doc, variables: make(map[string]interface{})}
+	for _, def := range op.variables {
+		if value, ok := req.Variables[def.name]; ok {
+			e.variables[def.name] = value
+		} else if def.hasDefault {
+			e.variables[def.name] = def.defaultValue
+		}
+	}
+
+	data := e.selectObject(s.Query, nil, op.selections, nil, 1)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// operation picks the operation named name, or the only one when name is empty
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) != 1 {
+			return nil, fmt.Errorf("operationName is required when the document has %d operations", len(d.operations))
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func (e *executor) fail(path []interface{}, format string, args ...interface{}) {
+	e.errors = append(e.errors, Error{Message: fmt.Sprintf(format, args...), Path: append([]interface{}(nil), path...)})
+}
+
+// selectObject resolves selections against source, an object of type t
+func (e *executor) selectObject(t *Object, source interface{}, selections []selection, path []interface{}, depth int) *OrderedMap {
+	result := newOrderedMap()
+	if depth > maxDepth {
+		e.fail(path, "query is nested deeper than %d levels", maxDepth)
+		return result
+	}
+	e.collect(t, source, selections, result, path, depth)
+	return result
+}
+
+// collect adds the fields of selections to result, expanding fragments.
+// Each named fragment spread counts as a level of depth, so spreads of
+// spreads are bounded like nested fields.
+func (e *executor) collect(t *Object, source interface{}, selections []selection, result *OrderedMap, path []interface{}, depth int) {
+	for _, sel := range selections {
+		include, err := e.included(sel.directives)
+		if err != nil {
+			e.fail(path, "%v", err)
+			continue
+		}
+		if !include {
+			continue
+		}
+
+		if sel.isFragment {
+			selections, typeName := sel.selections, sel.typeName
+			if sel.spread != "" {
+				frag, ok := e.doc.fragments[sel.spread]
+				if !ok {
+					e.fail(path, "unknown fragment %q", sel.spread)
+					continue
+				}
+				selections, typeName = frag.selections, frag.typeName
+			}
+			if typeName != "" && typeName != t.Name {
+				continue
+			}
+			if sel.spread != "" {
+				if depth+1 > maxDepth {
+					e.fail(path, "query is nested deeper than %d levels", maxDepth)
+					continue
+				}
+				e.collect(t, source, selections, result, path, depth+1)
+				continue
+			}
+			e.collect(t, source, selections, result, path, depth)
+			continue
+		}
+
+		fieldPath := append(append([]interface{}(nil), path...), sel.alias)
+		if sel.name == "__typename" {
+			result.set(sel.alias, t.Name)
+			continue
+		}
+		field, ok := t.Fields[sel.name]
+		if !ok {
+			e.fail(fieldPath, "cannot query field %q on type %s", sel.name, t.Name)
+			continue
+		}
+		result.set(sel.alias, e.resolveField(field, source, sel, fieldPath, depth))
+	}
+}
+
+// included evaluates @include(if:) and @skip(if:)
+func (e *executor) included(directives []directive) (bool, error) {
+	for _, d := range directives {
+		value, err := e.value(d.args["if"])
+		if err != nil {
+			return false, err
+		}
+		condition, ok := value.(bool)
+		switch {
+		case d.name != "include" && d.name != "skip":
+			return false, fmt.Errorf("unknown directive @%s", d.name)
+		case !ok:
+			return false, fmt.Errorf("@%s requires a boolean if argument", d.name)
+		case d.name == "include" && !condition, d.name == "skip" && condition:
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (e *executor) resolveField(field *Field, source interface{}, sel selection, path []interface{}, depth int) interface{} {
+	args := make(map[string]interface{}, len(sel.args))
+	for name, raw := range sel.args {
+		if !contains(field.Args, name) {
+			e.fail(path, "unknown argument %q", name)
+			return nil
+		}
+		value, err := e.value(raw)
+		if err != nil {
+			e.fail(path, "%v", err)
+			return nil
+		}
+		args[name] = value
+	}
+
+	value, err := field.Resolve(e.ctx, source, args)
+	if err != nil {
+		e.fail(path, "%v", err)
+		return nil
+	}
+
+	if field.Type == nil {
+		if len(sel.selections) > 0 {
+			e.fail(path, "field %q is a scalar and has no fields", sel.name)
+			return nil
+		}
+		return value
+	}
+	if len(sel.selections) == 0 {
+		e.fail(path, "field %q of type %s needs a selection of fields", sel.name, field.Type.Name)
+		return nil
+	}
+	return e.complete(field.Type, value, sel.selections, path, depth)
+}
+
+// complete selects fields of an object value or of each item of a list
+func (e *executor) complete(t *Object, value interface{}, selections []selection, path []interface{}, depth int) interface{} {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() || (rv.Kind() == reflect.Pointer && rv.IsNil()) {
+		return nil
+	}
+	if rv.Kind() == reflect.Slice {
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			itemPath := append(append([]interface{}(nil), path...), i)
+			items[i] = e.selectObject(t, rv.Index(i).Interface(), selections, itemPath, depth+1)
+		}
+		return items
+	}
+	return e.selectObject(t, value, selections, path, depth+1)
+}
+
+// value resolves variables inside an argument value
+func (e *executor) value(raw interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case variable:
+		value, ok := e.variables[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+		return value, nil
+	case enum:
+		return string(v), nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			value, err := e.value(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		return list, nil
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for name, item := range v {
+			value, err := e.value(item)
+			if err != nil {
+				return nil, err
+			}
+			object[name] = value
+		}
+		return object, nil
+	}
+	return raw, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testSchema is a node type whose child field nests without end
+func testSchema() *Schema {
+	node := &Object{Name: "Node", Fields: map[string]*Field{
+		"id": {Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+			return 1, nil
+		}},
+	}}
+	node.Fields["child"] = &Field{Type: node, Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+		return struct{}{}, nil
+	}}
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"node": {Type: node, Resolve: func(ctx context.Context, source interface{}, args map[string]interface{}) (interface{}, error) {
+			return struct{}{}, nil
+		}},
+	}}}
+}
+
+func TestExecuteRejectsFragmentCycle(t *testing.T) {
+	resp := testSchema().Execute(context.Background(), Request{
+		Query: `{ node { ...A } } fragment A on Node { id ...B } fragment B on Node { ...A }`,
+	})
+	if resp.Data != nil {
+		t.Fatalf("cyclic query was executed")
+	}
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "spreads itself") {
+		t.Fatalf("got errors %+v, want a fragment cycle error", resp.Errors)
+	}
+}
+
+func TestExecuteCountsSpreadsTowardDepth(t *testing.T) {
+	// A chain of fragments, each spreading the next, nests no fields
+	// but still counts against maxDepth
+	var query strings.Builder
+	query.WriteString("{ node { ...F0 } }")
+	for i := 0; i < maxDepth; i++ {
+		fmt.Fprintf(&query, " fragment F%d on Node { id ...F%d }", i, i+1)
+	}
+	fmt.Fprintf(&query, " fragment F%d on Node { id }", maxDepth)
+
+	resp := testSchema().Execute(context.Background(), Request{Query: query.String()})
+	if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, "nested deeper") {
+		t.Fatalf("got errors %+v, want a depth error", resp.Errors)
+	}
+}
+
+func TestExecuteExpandsFragments(t *testing.T) {
+	resp := testSchema().Execute(context.Background(), Request{
+		Query: `{ node { ...A } } fragment A on Node { id child { ...B } } fragment B on Node { id }`,
+	})
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors %+v", resp.Errors)
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"node":{"id":1,"child":{"id":1}}}`; string(data) != want {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,108 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// StructFields returns a scalar field for each JSON-tagged column of model's
+// struct type, named in lowerCamelCase (scope_md becomes scopeMd). Relations
+// are left for the schema to define; times resolve to RFC 3339 strings.
+func StructFields(model interface{}) map[string]*Field {
+	t := reflect.TypeOf(model)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	fields := make(map[string]*Field)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !isScalar(sf.Type) {
+			continue
+		}
+		index := sf.Index
+		fields[camelCase(name)] = &Field{
+			Resolve: func(_ context.Context, source interface{}, _ map[string]interface{}) (interface{}, error) {
+				v := reflect.Indirect(reflect.ValueOf(source))
+				if !v.IsValid() {
+					return nil, nil
+				}
+				return scalarValue(v.FieldByIndex(index)), nil
+			},
+		}
+	}
+	return fields
+}
+
+func isScalar(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t == timeType
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.Interface:
+		return false
+	}
+	return true
+}
+
+func scalarValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return v.Interface()
+}
+
+// camelCase turns a snake_case JSON name into a GraphQL field name
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// IntArg returns the integer argument name, or def when it is absent or null.
+// Variables decoded from JSON arrive as float64 and are accepted when whole.
+func IntArg(args map[string]interface{}, name string, def int) (int, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return def, nil
+	case int64:
+		return int(v), nil
+	case int:
+		return v, nil
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+// StringArg returns the string argument name and whether it was given
+func StringArg(args map[string]interface{}, name string) (string, bool, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		return v, true, nil
+	}
+	return "", false, fmt.Errorf("argument %q must be a string", name)
+}
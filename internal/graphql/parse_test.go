@@ -0,0 +1,60 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFragmentCycles(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		cycle string // part of the error, "" when the document is valid
+	}{
+		{
+			name:  "self spread",
+			query: `{ report { ...A } } fragment A on Report { id ...A }`,
+			cycle: `fragment "A" spreads itself`,
+		},
+		{
+			name:  "mutual spread",
+			query: `{ report { ...A } } fragment A on Report { ...B } fragment B on Report { ...A }`,
+			cycle: "A -> B -> A",
+		},
+		{
+			name:  "spread inside a nested field",
+			query: `{ report { ...A } } fragment A on Report { versions { ...B } } fragment B on Version { report { ...A } }`,
+			cycle: "A -> B -> A",
+		},
+		{
+			name:  "spread inside an inline fragment",
+			query: `{ report { ...A } } fragment A on Report { ... on Report { ...A } }`,
+			cycle: `fragment "A" spreads itself`,
+		},
+		{
+			name:  "shared fragment",
+			query: `{ report { ...A ...B } } fragment A on Report { ...C } fragment B on Report { ...C } fragment C on Report { id }`,
+		},
+		{
+			name:  "unknown fragment",
+			query: `{ report { ...Missing } }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parse(tt.query)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			err = doc.validate()
+			switch {
+			case tt.cycle == "" && err != nil:
+				t.Fatalf("validate: %v", err)
+			case tt.cycle != "" && err == nil:
+				t.Fatalf("validate accepted a fragment cycle")
+			case tt.cycle != "" && !strings.Contains(err.Error(), tt.cycle):
+				t.Fatalf("validate: got %q, want it to contain %q", err, tt.cycle)
+			}
+		})
+	}
+}
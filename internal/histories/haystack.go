@@ -0,0 +1,131 @@
+package histories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// haystackReader reads point histories with the Haystack hisRead op. Both the
+// Haystack 3 JSON encoding ("n:72.5 °F") and Hayson ({"_kind":"number"}) are
+// understood.
+type haystackReader struct {
+	base   string
+	client *httpClient
+}
+
+type haystackGrid struct {
+	Meta map[string]interface{}   `json:"meta"`
+	Rows []map[string]interface{} `json:"rows"`
+}
+
+// Read calls hisRead for one point id over a UTC date-time range
+func (r *haystackReader) Read(ctx context.Context, point string, start, end time.Time) ([]Sample, error) {
+	id := point
+	if !strings.HasPrefix(id, "@") {
+		id = "@" + id
+	}
+	rng := fmt.Sprintf("%s UTC,%s UTC", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+	query := url.Values{"id": {id}, "range": {rng}}
+
+	body, err := r.client.get(ctx, r.base+"/hisRead?"+query.Encode(), "application/json")
+	if err != nil {
+		return nil, err
+	}
+
+	var grid haystackGrid
+	if err := json.Unmarshal(body, &grid); err != nil {
+		return nil, fmt.Errorf("invalid hisRead response: %w", err)
+	}
+	if _, isErr := grid.Meta["err"]; isErr {
+		return nil, fmt.Errorf("hisRead failed: %s", haystackString(grid.Meta["dis"]))
+	}
+
+	samples := make([]Sample, 0, len(grid.Rows))
+	for _, row := range grid.Rows {
+		ts, err := haystackTime(row["ts"])
+		if err != nil {
+			return nil, err
+		}
+		sample := haystackValue(row["val"])
+		sample.Time = ts
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// haystackTime parses "t:2024-01-02T03:04:05-05:00 New_York" or a Hayson dateTime
+func haystackTime(v interface{}) (time.Time, error) {
+	var raw string
+	switch t := v.(type) {
+	case string:
+		raw = strings.TrimPrefix(t, "t:")
+	case map[string]interface{}:
+		raw, _ = t["val"].(string)
+	}
+	if i := strings.IndexByte(raw, ' '); i >= 0 {
+		raw = raw[:i] // drop the Haystack timezone name; the offset is authoritative
+	}
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid history timestamp %v", v)
+	}
+	return ts.UTC(), nil
+}
+
+// haystackValue converts a grid cell to a sample value
+func haystackValue(v interface{}) Sample {
+	switch val := v.(type) {
+	case float64:
+		return Sample{Value: floatPtr(val), Text: strconv.FormatFloat(val, 'f', -1, 64)}
+	case bool:
+		return boolSample(val)
+	case string:
+		switch {
+		case strings.HasPrefix(val, "n:"):
+			num, unit, _ := strings.Cut(strings.TrimPrefix(val, "n:"), " ")
+			if f, err := strconv.ParseFloat(num, 64); err == nil {
+				return Sample{Value: floatPtr(f), Text: num, Unit: unit}
+			}
+			return Sample{Text: num, Unit: unit}
+		case strings.HasPrefix(val, "s:"):
+			return Sample{Text: strings.TrimPrefix(val, "s:")}
+		default:
+			return Sample{Text: val}
+		}
+	case map[string]interface{}:
+		if val["_kind"] == "number" {
+			unit, _ := val["unit"].(string)
+			if f, ok := val["val"].(float64); ok {
+				return Sample{Value: floatPtr(f), Text: strconv.FormatFloat(f, 'f', -1, 64), Unit: unit}
+			}
+			s := haystackString(val["val"]) // "INF", "-INF" or "NaN"
+			return Sample{Text: s, Unit: unit}
+		}
+		return Sample{Text: haystackString(val["val"])}
+	}
+	return Sample{}
+}
+
+func boolSample(b bool) Sample {
+	if b {
+		return Sample{Value: floatPtr(1), Text: "true"}
+	}
+	return Sample{Value: floatPtr(0), Text: "false"}
+}
+
+func haystackString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return strings.TrimPrefix(s, "s:")
+	case map[string]interface{}:
+		return haystackString(s["val"])
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,91 @@
+package histories
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+)
+
+// Sample is one history record of a point. Numeric, boolean and enum values
+// all keep their raw form in Text; Value is set when the sample is numeric
+// (booleans map to 0/1).
+type Sample struct {
+	Time  time.Time
+	Value *float64
+	Text  string
+	Unit  string
+}
+
+// Reader reads the history of one point between start and end
+type Reader interface {
+	Read(ctx context.Context, point string, start, end time.Time) ([]Sample, error)
+}
+
+// NewReader returns the reader for a configured history source
+func NewReader(cfg config.HistorySourceConfig) (Reader, error) {
+	client := &httpClient{
+		http:     &http.Client{Timeout: 2 * time.Minute},
+		username: cfg.Username,
+		password: cfg.Password,
+		token:    cfg.Token,
+	}
+	base := strings.TrimRight(cfg.URL, "/")
+
+	switch cfg.Kind {
+	case "haystack":
+		return &haystackReader{base: base, client: client}, nil
+	case "niagara":
+		return &obixReader{base: base, client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported history source kind %q", cfg.Kind)
+	}
+}
+
+// httpClient performs authenticated GETs against a history endpoint
+type httpClient struct {
+	http     *http.Client
+	username string
+	password string
+	token    string
+}
+
+func (c *httpClient) get(ctx context.Context, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet := strings.TrimSpace(string(body))
+		if len(snippet) > 200 {
+			snippet = snippet[:200]
+		}
+		return nil, fmt.Errorf("history endpoint returned %d: %s", resp.StatusCode, snippet)
+	}
+	return body, nil
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,89 @@
+package histories
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// obixReader reads Niagara histories through the oBIX historyQuery operation
+type obixReader struct {
+	base   string
+	client *httpClient
+}
+
+// obixElement is a generic oBIX object; records are navigated by name attribute
+type obixElement struct {
+	XMLName  xml.Name
+	Name     string        `xml:"name,attr"`
+	Val      string        `xml:"val,attr"`
+	Unit     string        `xml:"unit,attr"`
+	Display  string        `xml:"display,attr"`
+	Children []obixElement `xml:",any"`
+}
+
+func (e *obixElement) child(name string) *obixElement {
+	for i := range e.Children {
+		if e.Children[i].Name == name {
+			return &e.Children[i]
+		}
+	}
+	return nil
+}
+
+// Read queries one history (e.g. "AHU1_SAT") between start and end
+func (r *obixReader) Read(ctx context.Context, point string, start, end time.Time) ([]Sample, error) {
+	query := url.Values{
+		"start": {start.UTC().Format("2006-01-02T15:04:05.000Z07:00")},
+		"end":   {end.UTC().Format("2006-01-02T15:04:05.000Z07:00")},
+	}
+	endpoint := fmt.Sprintf("%s/%s/~historyQuery?%s", r.base, url.PathEscape(point), query.Encode())
+
+	body, err := r.client.get(ctx, endpoint, "text/xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var root obixElement
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("invalid historyQuery response: %w", err)
+	}
+	if root.XMLName.Local == "err" {
+		return nil, fmt.Errorf("historyQuery failed: %s", root.Display)
+	}
+
+	data := root.child("data")
+	if data == nil {
+		return nil, nil // no records in range
+	}
+
+	samples := make([]Sample, 0, len(data.Children))
+	for _, record := range data.Children {
+		tsElem := record.child("timestamp")
+		valElem := record.child("value")
+		if tsElem == nil || valElem == nil {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, tsElem.Val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid history timestamp %q", tsElem.Val)
+		}
+
+		sample := Sample{Time: ts.UTC(), Text: valElem.Val, Unit: strings.TrimPrefix(valElem.Unit, "obix:units/")}
+		switch valElem.XMLName.Local {
+		case "real", "int":
+			if f, err := strconv.ParseFloat(valElem.Val, 64); err == nil {
+				sample.Value = floatPtr(f)
+			}
+		case "bool":
+			b := boolSample(valElem.Val == "true")
+			sample.Value = b.Value
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
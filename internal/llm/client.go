@@ -19,8 +19,15 @@ type LLMClient interface {
 
 // NewLLMClient creates the appropriate LLM client based on config
 func NewLLMClient(cfg *config.Config) (LLMClient, error) {
+	if isMockRoute(cfg.Models.ChatPrimary) {
+		logger.LogInfo(logger.ServiceAI, "Using mock chat model", map[string]interface{}{
+			"fixtures": cfg.Models.Mock.Fixtures,
+		})
+		return newMockClient(cfg.Models.Mock)
+	}
+
 	// Check if OpenAI is configured and should be used
-	if cfg.Models.ChatPrimary == "openai" && cfg.Models.OpenAI.APIKey != "" {
+	if useOpenAI(cfg, cfg.Models.ChatPrimary) {
 		logger.LogInfo(logger.ServiceAI, "Using OpenAI as primary chat model", map[string]interface{}{
 			"model": cfg.Models.OpenAI.Model,
 		})
@@ -41,28 +48,56 @@ func NewSQLClient(cfg *config.Config) (LLMClient, error) {
 	return NewLLMClient(cfg)
 }
 
+// ProviderName returns "openai", "ollama" or "mock" for a client created by this package
+func ProviderName(client LLMClient) string {
+	switch c := client.(type) {
+	case *pooledClient:
+		return c.provider
+	case *OpenAIClient:
+		return "openai"
+	case *OllamaClient:
+		return "ollama"
+	case *MockClient:
+		return "mock"
+	default:
+		return ""
+	}
+}
+
 // GetModelName returns the appropriate model name based on config and type
 func GetModelName(cfg *config.Config, modelType string) string {
+	if IsMockRoute(cfg, modelType) {
+		return MockModel
+	}
 	switch modelType {
 	case "chat":
-		if cfg.Models.ChatPrimary == "openai" && cfg.Models.OpenAI.APIKey != "" {
+		if useOpenAI(cfg, cfg.Models.ChatPrimary) {
 			return cfg.Models.OpenAI.Model
 		}
 		return cfg.Models.Ollama.Llama3Model
 	case "sql":
-		if cfg.Models.SQLPrimary == "openai" && cfg.Models.OpenAI.APIKey != "" {
+		if useOpenAI(cfg, cfg.Models.SQLPrimary) {
 			return cfg.Models.OpenAI.Model
 		}
 		return cfg.Models.Ollama.SQLCoderModel
 	default:
 		// Default to chat model
-		if cfg.Models.ChatPrimary == "openai" && cfg.Models.OpenAI.APIKey != "" {
+		if useOpenAI(cfg, cfg.Models.ChatPrimary) {
 			return cfg.Models.OpenAI.Model
 		}
 		return cfg.Models.Ollama.Llama3Model
 	}
 }
 
+// useOpenAI reports whether a model route should go to OpenAI. Replaying
+// OpenAI recordings needs no API key.
+func useOpenAI(cfg *config.Config, route string) bool {
+	if IsLocalOnly() || cfg.Privacy.LocalOnly {
+		return false
+	}
+	return route == "openai" && (cfg.Models.OpenAI.APIKey != "" || cfg.Models.Replay.Mode == ReplayReplay)
+}
+
 // CheckModelHealth checks if the specified model is available and healthy
 func CheckModelHealth(cfg *config.Config, modelType string) error {
 	client, err := NewLLMClient(cfg)
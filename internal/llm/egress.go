@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/NubeDev/air/internal/logger"
+)
+
+// ErrLocalOnly is returned when an external provider is used while local-only mode is enabled
+var ErrLocalOnly = errors.New("external model providers are disabled (privacy.local_only is enabled)")
+
+// localOnly is the process-wide egress switch, set once at startup from config
+var localOnly atomic.Bool
+
+// blockedCalls counts external calls refused since startup
+var blockedCalls atomic.Int64
+
+// SetLocalOnly enables or disables local-only mode for all LLM clients
+func SetLocalOnly(enabled bool) {
+	localOnly.Store(enabled)
+	if enabled {
+		logger.LogInfo(logger.ServiceAI, "Local-only mode enabled: external model providers are disabled")
+	}
+}
+
+// IsLocalOnly reports whether local-only mode is enabled
+func IsLocalOnly() bool {
+	return localOnly.Load()
+}
+
+// BlockedExternalCalls returns the number of external calls refused since startup
+func BlockedExternalCalls() int64 {
+	return blockedCalls.Load()
+}
+
+// checkEgress refuses and audits an external call when local-only mode is enabled
+func checkEgress(provider, operation, model string) error {
+	if !localOnly.Load() {
+		return nil
+	}
+
+	total := blockedCalls.Add(1)
+	logger.LogWarn(logger.ServiceAI, "Blocked external model call (local-only mode)", map[string]interface{}{
+		"audit":         "egress_blocked",
+		"provider":      provider,
+		"operation":     operation,
+		"model":         model,
+		"blocked_total": total,
+	})
+
+	return ErrLocalOnly
+}
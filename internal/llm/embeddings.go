@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/ollama/ollama/api"
+)
+
+// Embedder turns texts into embedding vectors
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+	Model() string
+}
+
+// NewEmbedder creates the embeddings client selected by models.embeddings.
+// It returns nil when no embeddings model is configured.
+func NewEmbedder(cfg *config.Config) (Embedder, error) {
+	emb := cfg.Models.Embeddings
+	if emb.Model == "" {
+		return nil, nil
+	}
+
+	if strings.EqualFold(emb.Provider, "ollama") {
+		baseURL, err := url.Parse(cfg.Models.Ollama.Host)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Ollama host URL: %w", err)
+		}
+		return &ollamaEmbedder{client: api.NewClient(baseURL, &http.Client{}), model: emb.Model}, nil
+	}
+
+	if err := checkEgress("openai", "create_embedder", emb.Model); err != nil {
+		return nil, err
+	}
+	if cfg.Models.OpenAI.APIKey == "" {
+		return nil, fmt.Errorf("OpenAI API key is required for embeddings")
+	}
+	return &openAIEmbedder{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		apiKey:  cfg.Models.OpenAI.APIKey,
+		model:   emb.Model,
+		baseURL: "https://api.openai.com/v1",
+	}, nil
+}
+
+type ollamaEmbedder struct {
+	client *api.Client
+	model  string
+}
+
+func (e *ollamaEmbedder) Model() string { return e.model }
+
+// Embed embeds texts with the Ollama /api/embed endpoint
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	resp, err := e.client.Embed(ctx, &api.EmbedRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("ollama embed failed: %w", err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(resp.Embeddings), len(texts))
+	}
+	vectors := make([][]float64, len(resp.Embeddings))
+	for i, v := range resp.Embeddings {
+		vectors[i] = make([]float64, len(v))
+		for j, f := range v {
+			vectors[i][j] = float64(f)
+		}
+	}
+	return vectors, nil
+}
+
+type openAIEmbedder struct {
+	client  *http.Client
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+func (e *openAIEmbedder) Model() string { return e.model }
+
+// Embed embeds texts with the OpenAI embeddings endpoint
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	if err := checkEgress("openai", "embeddings", e.model); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", e.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI embeddings request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenAI embeddings returned status %d", resp.StatusCode)
+	}
+
+	var parsed struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings: %w", err)
+	}
+	vectors := make([][]float64, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("OpenAI embeddings missing result %d", i)
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// MockReply produces the content of a mock model response
+type MockReply func(req ChatRequest) (string, error)
+
+// MockClient is an LLMClient that answers from a reply function instead of
+// a model, so the pipeline can be exercised without one. It records every
+// request it receives.
+type MockClient struct {
+	mu    sync.Mutex
+	reply MockReply
+	calls []ChatRequest
+}
+
+// NewMockClient creates a mock client answering with reply
+func NewMockClient(reply MockReply) *MockClient {
+	return &MockClient{reply: reply}
+}
+
+// SetReply replaces the reply function
+func (c *MockClient) SetReply(reply MockReply) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reply = reply
+}
+
+// Calls returns the requests received so far
+func (c *MockClient) Calls() []ChatRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ChatRequest(nil), c.calls...)
+}
+
+// ChatCompletion answers a chat request with the reply function
+func (c *MockClient) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, req)
+	reply := c.reply
+	c.mu.Unlock()
+
+	content, err := reply(req)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponse{Model: req.Model, Message: Message{Role: "assistant", Content: content}, Done: true}, nil
+}
+
+// GenerateText answers a prompt as a single user message
+func (c *MockClient) GenerateText(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	resp, err := c.ChatCompletion(ctx, ChatRequest{
+		Model:    req.Model,
+		Messages: []Message{{Role: "user", Content: req.Prompt}},
+		Options:  req.Options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &GenerateResponse{Model: req.Model, Response: resp.Message.Content, Done: true}, nil
+}
+
+// Health always succeeds
+func (c *MockClient) Health(ctx context.Context) error {
+	return nil
+}
+
+// ListModels reports a single "mock" model
+func (c *MockClient) ListModels(ctx context.Context) (*ModelsResponse, error) {
+	return &ModelsResponse{Models: []Model{{Name: "mock"}}}, nil
+}
+
+// GetModelInfo describes any model as present
+func (c *MockClient) GetModelInfo(ctx context.Context, modelName string) (*ModelInfo, error) {
+	return &ModelInfo{Name: modelName}, nil
+}
@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// MockModel is the route and model name that selects the mock provider
+const MockModel = "mock"
+
+// mockFixtures is the fixtures file of the mock provider
+type mockFixtures struct {
+	// Responses maps a PromptHash to the reply content for that prompt
+	Responses map[string]string `yaml:"responses"`
+}
+
+var (
+	mockTableRe  = regexp.MustCompile(`(?m)^# Table: (\S+)`)
+	mockColumnRe = regexp.MustCompile(`(?m)^\| (\S+) \| [^|]+ \| (?:Yes|No) \|`)
+	mockTaskRe   = regexp.MustCompile(`-- Task: Query the (\S+) table`)
+)
+
+// mockAnalysis is the built-in verdict for run analysis prompts
+const mockAnalysis = `{"verdict": {"score": 80, "severity": "info", "key_findings": ["mock analysis"], "anomalies": [], "recommendations": []}, "analysis_md": "Mock analysis: the results were not reviewed by a model."}`
+
+// isMockRoute reports whether a model route should go to the mock provider
+func isMockRoute(route string) bool {
+	return strings.EqualFold(route, MockModel)
+}
+
+// IsMockRoute reports whether the chat or sql route in cfg uses the mock
+// provider
+func IsMockRoute(cfg *config.Config, modelType string) bool {
+	if modelType == "sql" {
+		return isMockRoute(cfg.Models.SQLPrimary)
+	}
+	return isMockRoute(cfg.Models.ChatPrimary)
+}
+
+// PromptHash identifies a request for the mock fixtures: the first 16 hex
+// digits of the SHA-256 of its messages, one "role: content" line each
+func PromptHash(req ChatRequest) string {
+	h := sha256.New()
+	for _, m := range req.Messages {
+		fmt.Fprintf(h, "%s: %s\n", m.Role, m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// newMockClient creates the mock provider's client. Requests whose prompt
+// hash has a fixture get that reply; the rest get a deterministic reply
+// derived from the prompt.
+func newMockClient(cfg config.MockConfig) (*MockClient, error) {
+	fixtures := map[string]string{}
+	if cfg.Fixtures != "" {
+		data, err := os.ReadFile(cfg.Fixtures)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mock fixtures: %w", err)
+		}
+		var file mockFixtures
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse mock fixtures %s: %w", cfg.Fixtures, err)
+		}
+		for hash, reply := range file.Responses {
+			fixtures[strings.ToLower(hash)] = reply
+		}
+	}
+
+	return NewMockClient(func(req ChatRequest) (string, error) {
+		hash := PromptHash(req)
+		reply, ok := fixtures[hash]
+		logger.LogInfo(logger.ServiceAI, "Mock model call", map[string]interface{}{
+			"prompt_hash": hash,
+			"fixture":     ok,
+		})
+		if ok {
+			return reply, nil
+		}
+		return mockDefaultReply(req, hash)
+	}), nil
+}
+
+// mockDefaultReply answers IR, SQL and analysis prompts with the simplest
+// valid reply for the schema in the prompt
+func mockDefaultReply(req ChatRequest, hash string) (string, error) {
+	var system, prompt strings.Builder
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			system.WriteString(m.Content)
+		} else {
+			prompt.WriteString(m.Content)
+			prompt.WriteString("\n")
+		}
+	}
+
+	switch {
+	case strings.Contains(system.String(), "Intermediate Representation"):
+		return mockIR(prompt.String(), hash)
+	case strings.Contains(system.String(), "Analyze the SQL execution results"):
+		return mockAnalysis, nil
+	}
+	if match := mockTaskRe.FindStringSubmatch(prompt.String()); match != nil {
+		return fmt.Sprintf("SELECT * FROM %s LIMIT 100", match[1]), nil
+	}
+	return fmt.Sprintf("Mock response (prompt %s).", hash), nil
+}
+
+// mockIR selects the first columns of the first table in the schema notes
+func mockIR(prompt, hash string) (string, error) {
+	loc := mockTableRe.FindStringSubmatchIndex(prompt)
+	if loc == nil {
+		return "", fmt.Errorf("mock model: no table in IR prompt %s; add a fixture for this hash", hash)
+	}
+	table := prompt[loc[2]:loc[3]]
+
+	// Columns of this table only, up to the next table heading
+	notes := prompt[loc[1]:]
+	if next := mockTableRe.FindStringIndex(notes); next != nil {
+		notes = notes[:next[0]]
+	}
+	var columns []string
+	for _, match := range mockColumnRe.FindAllStringSubmatch(notes, 5) {
+		columns = append(columns, match[1])
+	}
+	if len(columns) == 0 {
+		columns = []string{"*"}
+	}
+
+	ir, err := json.Marshal(map[string]interface{}{
+		"dataset": table,
+		"select":  columns,
+		"limit":   100,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(ir), nil
+}
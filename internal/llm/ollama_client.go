@@ -20,12 +20,16 @@ type OllamaClient struct {
 
 // NewOllamaClient creates a new Ollama client
 func NewOllamaClient(cfg config.OllamaConfig) (*OllamaClient, error) {
+	return newOllamaClient(cfg, &http.Client{})
+}
+
+func newOllamaClient(cfg config.OllamaConfig, httpClient *http.Client) (*OllamaClient, error) {
 	baseURL, err := url.Parse(cfg.Host)
 	if err != nil {
 		return nil, fmt.Errorf("invalid Ollama host URL: %w", err)
 	}
 
-	client := api.NewClient(baseURL, &http.Client{})
+	client := api.NewClient(baseURL, httpClient)
 
 	return &OllamaClient{
 		client: client,
@@ -108,6 +112,9 @@ func (c *OllamaClient) ChatCompletion(ctx context.Context, req ChatRequest) (*Ch
 	if req.Options != nil {
 		ollamaReq.Options["temperature"] = req.Options.Temperature
 		ollamaReq.Options["top_p"] = req.Options.TopP
+		if req.Options.Seed != 0 {
+			ollamaReq.Options["seed"] = req.Options.Seed
+		}
 	}
 
 	// Convert messages
@@ -162,6 +169,9 @@ func (c *OllamaClient) GenerateText(ctx context.Context, req GenerateRequest) (*
 	if req.Options != nil {
 		ollamaReq.Options["temperature"] = req.Options.Temperature
 		ollamaReq.Options["top_p"] = req.Options.TopP
+		if req.Options.Seed != 0 {
+			ollamaReq.Options["seed"] = req.Options.Seed
+		}
 	}
 
 	// Make the request
@@ -257,6 +267,113 @@ func (c *OllamaClient) GetModelInfo(ctx context.Context, modelName string) (*Mod
 	return modelInfo, nil
 }
 
+// PullProgress represents a progress update while pulling a model
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// PullModel downloads a model, invoking fn for every progress update
+func (c *OllamaClient) PullModel(ctx context.Context, modelName string, fn func(PullProgress) error) error {
+	logger.LogInfo(logger.ServiceAI, "Pulling model", map[string]interface{}{
+		"model": modelName,
+	})
+
+	req := api.PullRequest{
+		Model: modelName,
+	}
+
+	err := c.client.Pull(ctx, &req, func(resp api.ProgressResponse) error {
+		if fn == nil {
+			return nil
+		}
+		return fn(PullProgress{
+			Status:    resp.Status,
+			Digest:    resp.Digest,
+			Total:     resp.Total,
+			Completed: resp.Completed,
+		})
+	})
+	if err != nil {
+		logger.LogError(logger.ServiceAI, "Failed to pull model", err, map[string]interface{}{
+			"model": modelName,
+		})
+		return fmt.Errorf("failed to pull model %s: %w", modelName, err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Model pulled", map[string]interface{}{
+		"model": modelName,
+	})
+
+	return nil
+}
+
+// DeleteModel removes a model from the Ollama host
+func (c *OllamaClient) DeleteModel(ctx context.Context, modelName string) error {
+	req := api.DeleteRequest{
+		Model: modelName,
+	}
+
+	if err := c.client.Delete(ctx, &req); err != nil {
+		logger.LogError(logger.ServiceAI, "Failed to delete model", err, map[string]interface{}{
+			"model": modelName,
+		})
+		return fmt.Errorf("failed to delete model %s: %w", modelName, err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Model deleted", map[string]interface{}{
+		"model": modelName,
+	})
+
+	return nil
+}
+
+// WarmUpModel loads a model into memory so the first real request does not pay the cold start
+func (c *OllamaClient) WarmUpModel(ctx context.Context, modelName string, keepAlive time.Duration) error {
+	start := time.Now()
+
+	stream := false
+	req := api.GenerateRequest{
+		Model:  modelName,
+		Stream: &stream,
+	}
+	if keepAlive > 0 {
+		req.KeepAlive = &api.Duration{Duration: keepAlive}
+	}
+
+	// An empty prompt makes Ollama load the model without generating anything
+	if err := c.client.Generate(ctx, &req, func(api.GenerateResponse) error { return nil }); err != nil {
+		logger.LogError(logger.ServiceAI, "Failed to warm up model", err, map[string]interface{}{
+			"model": modelName,
+		})
+		return fmt.Errorf("failed to warm up model %s: %w", modelName, err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Model warmed up", map[string]interface{}{
+		"model":    modelName,
+		"duration": time.Since(start).String(),
+	})
+
+	return nil
+}
+
+// ListRunningModels returns the names of models currently loaded in memory
+func (c *OllamaClient) ListRunningModels(ctx context.Context) ([]string, error) {
+	running, err := c.client.ListRunning(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running models: %w", err)
+	}
+
+	names := make([]string, 0, len(running.Models))
+	for _, model := range running.Models {
+		names = append(names, model.Name)
+	}
+
+	return names, nil
+}
+
 // Helper function for min
 func min(a, b int) int {
 	if a < b {
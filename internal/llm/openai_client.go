@@ -21,12 +21,20 @@ type OpenAIClient struct {
 
 // NewOpenAIClient creates a new OpenAI client
 func NewOpenAIClient(cfg config.OpenAIConfig) (*OpenAIClient, error) {
+	return newOpenAIClient(cfg, &http.Client{Timeout: 60 * time.Second})
+}
+
+func newOpenAIClient(cfg config.OpenAIConfig, httpClient *http.Client) (*OpenAIClient, error) {
+	if err := checkEgress("openai", "create_client", cfg.Model); err != nil {
+		return nil, err
+	}
+
 	if cfg.APIKey == "" {
 		return nil, fmt.Errorf("OpenAI API key is required")
 	}
 
 	return &OpenAIClient{
-		client:  &http.Client{Timeout: 60 * time.Second},
+		client:  httpClient,
 		config:  cfg,
 		baseURL: "https://api.openai.com/v1",
 	}, nil
@@ -40,6 +48,7 @@ type OpenAIRequest struct {
 	TopP        float64   `json:"top_p,omitempty"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Stream      bool      `json:"stream,omitempty"`
+	Seed        *int      `json:"seed,omitempty"`
 }
 
 // OpenAIMessage represents an OpenAI message
@@ -71,6 +80,10 @@ type OpenAIResponse struct {
 
 // ChatCompletion performs a chat completion using the specified model
 func (c *OpenAIClient) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := checkEgress("openai", "chat_completion", req.Model); err != nil {
+		return nil, err
+	}
+
 	logger.LogInfo(logger.ServiceAI, "Starting OpenAI chat completion", map[string]interface{}{
 		"model":    req.Model,
 		"messages": len(req.Messages),
@@ -90,6 +103,10 @@ func (c *OpenAIClient) ChatCompletion(ctx context.Context, req ChatRequest) (*Ch
 	if req.Options != nil {
 		openaiReq.Temperature = float64(req.Options.Temperature)
 		openaiReq.TopP = float64(req.Options.TopP)
+		if req.Options.Seed != 0 {
+			seed := req.Options.Seed
+			openaiReq.Seed = &seed
+		}
 	}
 
 	// Marshal request
@@ -201,6 +218,10 @@ func (c *OpenAIClient) GenerateText(ctx context.Context, req GenerateRequest) (*
 
 // Health checks if the OpenAI service is healthy
 func (c *OpenAIClient) Health(ctx context.Context) error {
+	if err := checkEgress("openai", "health", c.config.Model); err != nil {
+		return err
+	}
+
 	// Try to list models as a health check
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models", nil)
 	if err != nil {
@@ -226,6 +247,10 @@ func (c *OpenAIClient) Health(ctx context.Context) error {
 
 // ListModels lists available models
 func (c *OpenAIClient) ListModels(ctx context.Context) (*ModelsResponse, error) {
+	if err := checkEgress("openai", "list_models", c.config.Model); err != nil {
+		return nil, err
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/models", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create list models request: %w", err)
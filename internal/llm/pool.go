@@ -0,0 +1,191 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+)
+
+// defaultMaxInFlight applies when a provider's max_in_flight is not set
+const defaultMaxInFlight = 4
+
+// Pool hands out one client per provider+model. Clients of the same provider
+// share an HTTP transport, so connections are kept alive between calls, and a
+// slot limit, so a burst of requests queues instead of opening unbounded
+// connections.
+type Pool struct {
+	cfg config.ModelsConfig
+
+	mu        sync.Mutex
+	clients   map[string]LLMClient
+	providers map[string]*providerSlots
+}
+
+// providerSlots is the shared transport and in-flight limit of one provider
+type providerSlots struct {
+	transport *http.Transport
+	slots     chan struct{}
+}
+
+// PoolStats reports a provider's in-flight calls against its limit
+type PoolStats struct {
+	InFlight int `json:"in_flight"`
+	Limit    int `json:"limit"`
+	Clients  int `json:"clients"`
+}
+
+// NewPool creates an empty pool; clients are created on first use
+func NewPool(cfg config.ModelsConfig) *Pool {
+	return &Pool{
+		cfg:       cfg,
+		clients:   make(map[string]LLMClient),
+		providers: make(map[string]*providerSlots),
+	}
+}
+
+// Client returns the pooled client for provider ("openai", "ollama" or "mock") and model
+func (p *Pool) Client(provider, model string) (LLMClient, error) {
+	key := provider + "/" + model
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.clients[key]; ok {
+		return client, nil
+	}
+
+	var limit int
+	switch provider {
+	case "openai":
+		limit = p.cfg.OpenAI.MaxInFlight
+	case "ollama":
+		limit = p.cfg.Ollama.MaxInFlight
+	case MockModel:
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", provider)
+	}
+	if limit <= 0 {
+		limit = defaultMaxInFlight
+	}
+
+	shared, ok := p.providers[provider]
+	if !ok {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = limit
+		transport.MaxConnsPerHost = limit
+		transport.IdleConnTimeout = 90 * time.Second
+		shared = &providerSlots{transport: transport, slots: make(chan struct{}, limit)}
+	}
+
+	var (
+		client LLMClient
+		err    error
+	)
+	switch {
+	case provider != MockModel && p.cfg.Replay.Mode == ReplayReplay:
+		// Recordings answer every call, so no provider client is needed
+		client = newReplayClient(p.cfg.Replay, provider, model, nil)
+	case provider == "openai":
+		client, err = newOpenAIClient(p.cfg.OpenAI, &http.Client{Transport: shared.transport, Timeout: 60 * time.Second})
+	case provider == "ollama":
+		client, err = newOllamaClient(p.cfg.Ollama, &http.Client{Transport: shared.transport})
+	case provider == MockModel:
+		client, err = newMockClient(p.cfg.Mock)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if provider != MockModel && p.cfg.Replay.Mode == ReplayRecord {
+		client = newReplayClient(p.cfg.Replay, provider, model, client)
+	}
+
+	p.providers[provider] = shared
+	pooled := &pooledClient{LLMClient: client, provider: provider, slots: shared.slots}
+	p.clients[key] = pooled
+	return pooled, nil
+}
+
+// Route returns the pooled client for the chat or sql model route in cfg
+func (p *Pool) Route(cfg *config.Config, modelType string) (LLMClient, error) {
+	route := cfg.Models.ChatPrimary
+	if modelType == "sql" {
+		route = cfg.Models.SQLPrimary
+	}
+	provider := "ollama"
+	if isMockRoute(route) {
+		provider = MockModel
+	} else if useOpenAI(cfg, route) {
+		provider = "openai"
+	}
+	return p.Client(provider, GetModelName(cfg, modelType))
+}
+
+// Stats returns in-flight counts per provider that has been used
+func (p *Pool) Stats() map[string]PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make(map[string]PoolStats, len(p.providers))
+	for provider, shared := range p.providers {
+		stats[provider] = PoolStats{InFlight: len(shared.slots), Limit: cap(shared.slots)}
+	}
+	for _, client := range p.clients {
+		pooled := client.(*pooledClient)
+		s := stats[pooled.provider]
+		s.Clients++
+		stats[pooled.provider] = s
+	}
+	return stats
+}
+
+// CloseIdle drops idle keep-alive connections of every provider
+func (p *Pool) CloseIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, shared := range p.providers {
+		shared.transport.CloseIdleConnections()
+	}
+}
+
+// pooledClient holds a provider slot for the duration of each model call
+type pooledClient struct {
+	LLMClient
+	provider string
+	slots    chan struct{}
+}
+
+func (c *pooledClient) acquire(ctx context.Context) error {
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for %s slot: %w", c.provider, ctx.Err())
+	}
+}
+
+func (c *pooledClient) release() {
+	<-c.slots
+}
+
+// ChatCompletion waits for a provider slot, then runs the chat call
+func (c *pooledClient) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.LLMClient.ChatCompletion(ctx, req)
+}
+
+// GenerateText waits for a provider slot, then runs the generation call
+func (c *pooledClient) GenerateText(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.release()
+	return c.LLMClient.GenerateText(ctx, req)
+}
@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+)
+
+// Replay modes of models.replay.mode
+const (
+	ReplayRecord = "record"
+	ReplayReplay = "replay"
+)
+
+// recording is one provider response saved by record mode. Hash is the
+// PromptHash of the request, so a recorded Response can also be pasted into
+// the mock provider's fixtures.
+type recording struct {
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model"`
+	Hash       string    `json:"hash"`
+	Messages   []Message `json:"messages"`
+	Response   string    `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// replayClient records the responses of the client it wraps, or in replay
+// mode answers from earlier recordings without a provider at all
+type replayClient struct {
+	next     LLMClient // nil in replay mode
+	mode     string
+	dir      string
+	provider string
+	model    string
+
+	mu sync.Mutex // serializes writes of the same recording
+}
+
+// newReplayClient wraps next for cfg's mode; next is nil in replay mode
+func newReplayClient(cfg config.ReplayConfig, provider, model string, next LLMClient) *replayClient {
+	return &replayClient{next: next, mode: cfg.Mode, dir: cfg.Dir, provider: provider, model: model}
+}
+
+// ChatCompletion replays or records a chat request
+func (c *replayClient) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	content, err := c.exchange(req, func() (string, error) {
+		resp, err := c.next.ChatCompletion(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return resp.Message.Content, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponse{Model: c.requestModel(req.Model), Message: Message{Role: "assistant", Content: content}, Done: true}, nil
+}
+
+// GenerateText replays or records a prompt, keyed as a single user message
+func (c *replayClient) GenerateText(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	chat := ChatRequest{Model: req.Model, Messages: []Message{{Role: "user", Content: req.Prompt}}}
+	content, err := c.exchange(chat, func() (string, error) {
+		resp, err := c.next.GenerateText(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return resp.Response, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &GenerateResponse{Model: c.requestModel(req.Model), Response: content, Done: true}, nil
+}
+
+// Health succeeds in replay mode, which needs no provider
+func (c *replayClient) Health(ctx context.Context) error {
+	if c.next == nil {
+		return nil
+	}
+	return c.next.Health(ctx)
+}
+
+// ListModels lists the models that have recordings in replay mode
+func (c *replayClient) ListModels(ctx context.Context) (*ModelsResponse, error) {
+	if c.next != nil {
+		return c.next.ListModels(ctx)
+	}
+	entries, err := os.ReadDir(filepath.Join(c.dir, c.provider))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list recordings: %w", err)
+	}
+	models := &ModelsResponse{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			models.Models = append(models.Models, Model{Name: entry.Name()})
+		}
+	}
+	return models, nil
+}
+
+// GetModelInfo describes any model as present in replay mode
+func (c *replayClient) GetModelInfo(ctx context.Context, modelName string) (*ModelInfo, error) {
+	if c.next != nil {
+		return c.next.GetModelInfo(ctx, modelName)
+	}
+	return &ModelInfo{Name: modelName}, nil
+}
+
+// exchange answers req from its recording in replay mode, or calls the
+// provider and saves the answer in record mode
+func (c *replayClient) exchange(req ChatRequest, call func() (string, error)) (string, error) {
+	model := c.requestModel(req.Model)
+	hash := PromptHash(req)
+	path := c.recordingPath(model, hash)
+
+	if c.mode == ReplayReplay {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("no recorded %s response for prompt %s of %s; run once with models.replay.mode: record", c.provider, hash, model)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read recording: %w", err)
+		}
+		var rec recording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return "", fmt.Errorf("failed to parse recording %s: %w", path, err)
+		}
+		logger.LogInfo(logger.ServiceAI, "Replayed model call", map[string]interface{}{
+			"provider":    c.provider,
+			"model":       model,
+			"prompt_hash": hash,
+		})
+		return rec.Response, nil
+	}
+
+	content, err := call()
+	if err != nil {
+		return "", err
+	}
+	rec := recording{
+		Provider:   c.provider,
+		Model:      model,
+		Hash:       hash,
+		Messages:   req.Messages,
+		Response:   content,
+		RecordedAt: time.Now().UTC(),
+	}
+	// A failed write loses the recording, not the answer
+	if err := c.save(path, rec); err != nil {
+		logger.LogWarn(logger.ServiceAI, "Failed to record model call", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+	return content, nil
+}
+
+// save writes rec to path, replacing any earlier recording of the prompt
+func (c *replayClient) save(path string, rec recording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// recordingPath is <dir>/<provider>/<model>/<hash>.json, with the model name
+// made safe for a directory name
+func (c *replayClient) recordingPath(model, hash string) string {
+	safe := strings.NewReplacer("/", "_", ":", "_", "\\", "_").Replace(model)
+	return filepath.Join(c.dir, c.provider, safe, hash+".json")
+}
+
+// requestModel is the request's model, or the pooled model when unset
+func (c *replayClient) requestModel(model string) string {
+	if model == "" {
+		return c.model
+	}
+	return model
+}
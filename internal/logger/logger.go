@@ -20,6 +20,8 @@ const (
 	ServiceConfig = "CONF"
 	ServiceFile   = "FILE"
 	ServiceRedis  = "REDI"
+	ServiceMQTT   = "MQTT"
+	ServiceGRPC   = "GRPC"
 )
 
 // Log levels (4 letters for consistency)
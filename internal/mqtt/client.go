@@ -0,0 +1,262 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+)
+
+// MQTT 3.1.1 control packet types used by the publisher
+const (
+	packetConnect    = 0x10
+	packetConnAck    = 0x20
+	packetPublish    = 0x30
+	packetPubAck     = 0x40
+	packetDisconnect = 0xE0
+)
+
+// Client is a minimal MQTT 3.1.1 publisher. It opens one connection per Publish
+// call, which suits the low message rate of report results and avoids keep-alive
+// handling; QoS 2 and subscriptions are not supported.
+type Client struct {
+	config    *config.MQTTConfig
+	mu        sync.Mutex
+	packetID  uint16
+	publishMu sync.Mutex // brokers drop an existing connection that reuses the client ID
+}
+
+// NewClient creates a new MQTT publisher; it returns nil when MQTT is disabled
+func NewClient(cfg *config.MQTTConfig) (*Client, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if _, _, err := brokerAddress(cfg.Broker); err != nil {
+		return nil, err
+	}
+	return &Client{config: cfg}, nil
+}
+
+// Publish sends payload to topic with the configured QoS and retain flag. With
+// QoS 1 it waits for the broker's PUBACK.
+func (c *Client) Publish(ctx context.Context, topic string, payload []byte) error {
+	if topic == "" {
+		return errors.New("topic is required")
+	}
+
+	c.publishMu.Lock()
+	defer c.publishMu.Unlock()
+
+	timeout := c.config.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	r := bufio.NewReader(conn)
+	if err := c.connect(conn, r); err != nil {
+		return err
+	}
+
+	qos := byte(c.config.QoS)
+	id := c.nextPacketID()
+	if _, err := conn.Write(encodePublish(topic, payload, qos, c.config.Retain, id)); err != nil {
+		return fmt.Errorf("failed to publish: %w", err)
+	}
+
+	if qos == 1 {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return fmt.Errorf("failed to read PUBACK: %w", err)
+		}
+		if header&0xF0 != packetPubAck || len(body) < 2 || binary.BigEndian.Uint16(body) != id {
+			return fmt.Errorf("unexpected response to publish (packet 0x%02x)", header)
+		}
+	}
+
+	_, _ = conn.Write([]byte{packetDisconnect, 0})
+	return nil
+}
+
+func (c *Client) nextPacketID() uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.packetID++
+	if c.packetID == 0 {
+		c.packetID = 1
+	}
+	return c.packetID
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	addr, useTLS, err := brokerAddress(c.config.Broker)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	if !useTLS {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	td := tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: host}}
+	return td.DialContext(ctx, "tcp", addr)
+}
+
+// connect performs the CONNECT/CONNACK handshake with a clean session
+func (c *Client) connect(conn net.Conn, r *bufio.Reader) error {
+	var flags byte = 0x02 // clean session
+	if c.config.Username != "" {
+		flags |= 0x80
+	}
+	if c.config.Password != "" {
+		flags |= 0x40
+	}
+
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4, flags, 0, 60) // protocol level 4, keep-alive 60s
+	body = appendString(body, c.config.ClientID)
+	if c.config.Username != "" {
+		body = appendString(body, c.config.Username)
+	}
+	if c.config.Password != "" {
+		body = appendString(body, c.config.Password)
+	}
+
+	if _, err := conn.Write(encodePacket(packetConnect, body)); err != nil {
+		return fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+
+	header, ack, err := readPacket(r)
+	if err != nil {
+		return fmt.Errorf("failed to read CONNACK: %w", err)
+	}
+	if header != packetConnAck || len(ack) != 2 {
+		return fmt.Errorf("unexpected response to CONNECT (packet 0x%02x)", header)
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("broker refused connection: %s", connAckReason(ack[1]))
+	}
+	return nil
+}
+
+// brokerAddress parses tcp://, mqtt://, ssl://, tls:// and mqtts:// broker URLs
+func brokerAddress(broker string) (string, bool, error) {
+	u, err := url.Parse(broker)
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("invalid mqtt broker %q", broker)
+	}
+	useTLS := false
+	defaultPort := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+		defaultPort = "8883"
+	default:
+		return "", false, fmt.Errorf("unsupported mqtt broker scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), defaultPort), useTLS, nil
+	}
+	return u.Host, useTLS, nil
+}
+
+func encodePublish(topic string, payload []byte, qos byte, retain bool, id uint16) []byte {
+	header := byte(packetPublish) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, payload...)
+	return encodePacket(header, body)
+}
+
+// encodePacket prefixes body with the fixed header and variable-length remaining length
+func encodePacket(header byte, body []byte) []byte {
+	out := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7F) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func connAckReason(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	default:
+		return fmt.Sprintf("code %d", code)
+	}
+}
@@ -2,7 +2,9 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/NubeDev/air/internal/config"
@@ -10,10 +12,17 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// Client wraps the Redis client with our configuration
+// ErrDisabled is returned by operations that have no fallback when Redis is
+// disabled or was unreachable at startup
+var ErrDisabled = errors.New("redis client is disabled")
+
+// Client wraps the Redis client with our configuration. A nil *Client is a
+// disabled client: cache writes are skipped, reads miss, Publish and SMembers
+// return ErrDisabled, Subscribe returns nil and locks are local.
 type Client struct {
 	rdb    *redis.Client
 	config *config.RedisConfig
+	prefix string
 }
 
 // NewClient creates a new Redis client
@@ -42,9 +51,15 @@ func NewClient(cfg *config.RedisConfig) (*Client, error) {
 
 	rdb := redis.NewClient(opts)
 
+	prefix := cfg.KeyPrefix
+	if prefix != "" && !strings.HasSuffix(prefix, ":") {
+		prefix += ":"
+	}
+
 	client := &Client{
 		rdb:    rdb,
 		config: cfg,
+		prefix: prefix,
 	}
 
 	// Test connection
@@ -52,12 +67,14 @@ func NewClient(cfg *config.RedisConfig) (*Client, error) {
 	defer cancel()
 
 	if err := client.Ping(ctx); err != nil {
+		rdb.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
 	logger.LogInfo(logger.ServiceRedis, "Redis client connected successfully", map[string]interface{}{
-		"url": cfg.URL,
-		"db":  cfg.DB,
+		"url":        cfg.URL,
+		"db":         cfg.DB,
+		"key_prefix": prefix,
 	})
 
 	return client, nil
@@ -66,7 +83,7 @@ func NewClient(cfg *config.RedisConfig) (*Client, error) {
 // Ping tests the Redis connection
 func (c *Client) Ping(ctx context.Context) error {
 	if c == nil {
-		return fmt.Errorf("Redis client is disabled")
+		return ErrDisabled
 	}
 	return c.rdb.Ping(ctx).Err()
 }
@@ -79,19 +96,33 @@ func (c *Client) Close() error {
 	return c.rdb.Close()
 }
 
-// GetClient returns the underlying Redis client
+// GetClient returns the underlying Redis client. Keys used on it directly
+// must be namespaced with Key.
 func (c *Client) GetClient() *redis.Client {
+	if c == nil {
+		return nil
+	}
 	return c.rdb
 }
 
+// Key namespaces a key or channel name with redis.key_prefix
+func (c *Client) Key(name string) string {
+	return c.prefix + name
+}
+
+// TrimKey strips redis.key_prefix from a key or channel name read back from Redis
+func (c *Client) TrimKey(name string) string {
+	return strings.TrimPrefix(name, c.prefix)
+}
+
 // Publish publishes a message to a channel
 func (c *Client) Publish(ctx context.Context, channel string, message interface{}) error {
 	if c == nil {
-		return fmt.Errorf("Redis client is disabled")
+		return ErrDisabled
 	}
 
 	start := time.Now()
-	err := c.rdb.Publish(ctx, channel, message).Err()
+	err := c.rdb.Publish(ctx, c.Key(channel), message).Err()
 	duration := time.Since(start)
 
 	if err != nil {
@@ -115,17 +146,33 @@ func (c *Client) Subscribe(ctx context.Context, channels ...string) *redis.PubSu
 	if c == nil {
 		return nil
 	}
-	return c.rdb.Subscribe(ctx, channels...)
+	keys := make([]string, len(channels))
+	for i, channel := range channels {
+		keys[i] = c.Key(channel)
+	}
+	return c.rdb.Subscribe(ctx, keys...)
+}
+
+// PSubscribe subscribes to channels matching patterns
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) *redis.PubSub {
+	if c == nil {
+		return nil
+	}
+	keys := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		keys[i] = c.Key(pattern)
+	}
+	return c.rdb.PSubscribe(ctx, keys...)
 }
 
-// Set sets a key-value pair with expiration
+// Set sets a key-value pair with expiration; skipped when Redis is disabled
 func (c *Client) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	if c == nil {
-		return fmt.Errorf("Redis client is disabled")
+		return nil
 	}
 
 	start := time.Now()
-	err := c.rdb.Set(ctx, key, value, expiration).Err()
+	err := c.rdb.Set(ctx, c.Key(key), value, expiration).Err()
 	duration := time.Since(start)
 
 	if err != nil {
@@ -145,14 +192,14 @@ func (c *Client) Set(ctx context.Context, key string, value interface{}, expirat
 	return nil
 }
 
-// Get gets a value by key
+// Get gets a value by key. A missing key, or disabled Redis, reads as "".
 func (c *Client) Get(ctx context.Context, key string) (string, error) {
 	if c == nil {
-		return "", fmt.Errorf("Redis client is disabled")
+		return "", nil
 	}
 
 	start := time.Now()
-	result := c.rdb.Get(ctx, key)
+	result := c.rdb.Get(ctx, c.Key(key))
 	duration := time.Since(start)
 
 	if err := result.Err(); err != nil {
@@ -182,11 +229,15 @@ func (c *Client) Get(ctx context.Context, key string) (string, error) {
 // Del deletes a key
 func (c *Client) Del(ctx context.Context, keys ...string) error {
 	if c == nil {
-		return fmt.Errorf("Redis client is disabled")
+		return nil
 	}
 
 	start := time.Now()
-	err := c.rdb.Del(ctx, keys...).Err()
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.Key(key)
+	}
+	err := c.rdb.Del(ctx, prefixed...).Err()
 	duration := time.Since(start)
 
 	if err != nil {
@@ -208,11 +259,11 @@ func (c *Client) Del(ctx context.Context, keys ...string) error {
 // SAdd adds members to a set
 func (c *Client) SAdd(ctx context.Context, key string, members ...interface{}) error {
 	if c == nil {
-		return fmt.Errorf("Redis client is disabled")
+		return nil
 	}
 
 	start := time.Now()
-	err := c.rdb.SAdd(ctx, key, members...).Err()
+	err := c.rdb.SAdd(ctx, c.Key(key), members...).Err()
 	duration := time.Since(start)
 
 	if err != nil {
@@ -235,11 +286,11 @@ func (c *Client) SAdd(ctx context.Context, key string, members ...interface{}) e
 // SMembers gets all members of a set
 func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
 	if c == nil {
-		return nil, fmt.Errorf("Redis client is disabled")
+		return nil, ErrDisabled
 	}
 
 	start := time.Now()
-	result := c.rdb.SMembers(ctx, key)
+	result := c.rdb.SMembers(ctx, c.Key(key))
 	duration := time.Since(start)
 
 	if err := result.Err(); err != nil {
@@ -262,11 +313,11 @@ func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
 // Expire sets expiration on a key
 func (c *Client) Expire(ctx context.Context, key string, expiration time.Duration) error {
 	if c == nil {
-		return fmt.Errorf("Redis client is disabled")
+		return nil
 	}
 
 	start := time.Now()
-	err := c.rdb.Expire(ctx, key, expiration).Err()
+	err := c.rdb.Expire(ctx, c.Key(key), expiration).Err()
 	duration := time.Since(start)
 
 	if err != nil {
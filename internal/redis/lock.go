@@ -0,0 +1,226 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrLockHeld is returned when another process holds the lock
+	ErrLockHeld = errors.New("lock is held by another process")
+	// ErrLockLost is returned when a lock expired or was taken over before it was released
+	ErrLockLost = errors.New("lock was lost")
+)
+
+// lockKeyPrefix is applied after redis.key_prefix
+const lockKeyPrefix = "lock:"
+
+// releaseScript deletes the lock only while it still holds our value
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// refreshScript extends the lock only while it still holds our value
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// localLocks stand in for Redis locks on a nil (disabled) client, keyed by
+// lock name, so a single instance still runs each named job once at a time
+var localLocks = struct {
+	sync.Mutex
+	held   map[string]string // lock name -> holder's value
+	fences map[string]int64  // lock name -> last fencing token issued
+}{held: make(map[string]string), fences: make(map[string]int64)}
+
+// Lock is a held distributed lock. Token is a fencing token that increases
+// with every acquisition of the same name, so a writer can reject work from a
+// holder whose lock expired while it was paused.
+type Lock struct {
+	client *Client
+	name   string
+	key    string
+	value  string
+	ttl    time.Duration
+	Token  int64
+}
+
+// AcquireLock takes the named lock for ttl with SET NX PX, returning
+// ErrLockHeld when another process has it. On a nil (disabled) client it
+// takes a process-local lock of the same name instead, which does not
+// expire, so single-instance deployments need no special case.
+func (c *Client) AcquireLock(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	if c == nil {
+		return acquireLocalLock(name)
+	}
+
+	// The fencing counter starts at the current time in milliseconds, so
+	// tokens keep increasing even if Redis loses the counter
+	key := c.Key(lockKeyPrefix + name)
+	fence := key + ":fence"
+	if err := c.rdb.SetNX(ctx, fence, time.Now().UnixMilli(), 0).Err(); err != nil {
+		return nil, fmt.Errorf("failed to issue fencing token: %w", err)
+	}
+	token, err := c.rdb.Incr(ctx, fence).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue fencing token: %w", err)
+	}
+
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+	value := fmt.Sprintf("%d:%s", token, hex.EncodeToString(nonce))
+
+	ok, err := c.rdb.SetNX(ctx, key, value, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if !ok {
+		return nil, ErrLockHeld
+	}
+
+	logger.LogDebug(logger.ServiceRedis, "Lock acquired", map[string]interface{}{
+		"lock":  name,
+		"token": token,
+		"ttl":   ttl.String(),
+	})
+
+	return &Lock{client: c, key: key, value: value, ttl: ttl, Token: token}, nil
+}
+
+// acquireLocalLock takes the process-local lock of a name, returning
+// ErrLockHeld while another goroutine holds it
+func acquireLocalLock(name string) (*Lock, error) {
+	localLocks.Lock()
+	defer localLocks.Unlock()
+	if _, held := localLocks.held[name]; held {
+		return nil, ErrLockHeld
+	}
+	if localLocks.fences[name] == 0 {
+		localLocks.fences[name] = time.Now().UnixMilli()
+	}
+	localLocks.fences[name]++
+	token := localLocks.fences[name]
+	value := fmt.Sprintf("%d:local", token)
+	localLocks.held[name] = value
+	return &Lock{name: name, value: value, Token: token}, nil
+}
+
+// holdsLocal reports whether a process-local lock is still held by l
+func (l *Lock) holdsLocal() bool {
+	localLocks.Lock()
+	defer localLocks.Unlock()
+	return localLocks.held[l.name] == l.value
+}
+
+// Refresh extends the lock by its ttl, returning ErrLockLost when it is no
+// longer ours
+func (l *Lock) Refresh(ctx context.Context) error {
+	if l.client == nil {
+		if !l.holdsLocal() {
+			return ErrLockLost
+		}
+		return nil
+	}
+	n, err := refreshScript.Run(ctx, l.client.rdb, []string{l.key}, l.value, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("failed to refresh lock: %w", err)
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Release gives the lock up if it is still ours
+func (l *Lock) Release(ctx context.Context) error {
+	if l.client == nil {
+		localLocks.Lock()
+		defer localLocks.Unlock()
+		if localLocks.held[l.name] != l.value {
+			return ErrLockLost
+		}
+		delete(localLocks.held, l.name)
+		return nil
+	}
+	n, err := releaseScript.Run(ctx, l.client.rdb, []string{l.key}, l.value).Int()
+	if err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// WithLock runs fn while holding the named lock, refreshing it every third of
+// ttl. fn's context is cancelled if the lock is lost, and the error is then
+// ErrLockLost unless fn failed on its own.
+func (c *Client) WithLock(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context, token int64) error) error {
+	lock, err := c.AcquireLock(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if lock.client == nil {
+			return
+		}
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := lock.Refresh(ctx); err != nil && ctx.Err() == nil {
+					logger.LogWarn(logger.ServiceRedis, "Lost lock while running", map[string]interface{}{
+						"lock":  name,
+						"token": lock.Token,
+						"error": err.Error(),
+					})
+					close(lost)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	fnErr := fn(ctx, lock.Token)
+	cancel()
+	<-done
+
+	select {
+	case <-lost:
+		if fnErr == nil || errors.Is(fnErr, context.Canceled) {
+			return ErrLockLost
+		}
+		return fnErr
+	default:
+	}
+
+	releaseCtx, releaseCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer releaseCancel()
+	if err := lock.Release(releaseCtx); err != nil && fnErr == nil {
+		return err
+	}
+	return fnErr
+}
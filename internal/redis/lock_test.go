@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireLockWithoutRedisIsExclusive(t *testing.T) {
+	var c *Client
+	ctx := context.Background()
+
+	var acquired, held atomic.Int32
+	locks := make(chan *Lock, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := c.AcquireLock(ctx, "exclusive", time.Minute)
+			switch {
+			case err == nil:
+				acquired.Add(1)
+				locks <- lock
+			case errors.Is(err, ErrLockHeld):
+				held.Add(1)
+			default:
+				t.Errorf("AcquireLock: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(locks)
+
+	if acquired.Load() != 1 || held.Load() != 19 {
+		t.Fatalf("acquired %d, refused %d; want 1 and 19", acquired.Load(), held.Load())
+	}
+	first := <-locks
+	if err := first.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := first.Release(ctx); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("second Release = %v, want ErrLockLost", err)
+	}
+
+	second, err := c.AcquireLock(ctx, "exclusive", time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock after release: %v", err)
+	}
+	defer second.Release(ctx)
+	if second.Token <= first.Token {
+		t.Fatalf("token %d did not increase past %d", second.Token, first.Token)
+	}
+	other, err := c.AcquireLock(ctx, "other", time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock of another name: %v", err)
+	}
+	other.Release(ctx)
+}
+
+func TestWithLockWithoutRedisRunsOneAtATime(t *testing.T) {
+	var c *Client
+	var running, maxRunning, ran, refused atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.WithLock(context.Background(), "job", time.Minute, func(ctx context.Context, token int64) error {
+				n := running.Add(1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+				ran.Add(1)
+				return nil
+			})
+			if errors.Is(err, ErrLockHeld) {
+				refused.Add(1)
+			} else if err != nil {
+				t.Errorf("WithLock: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning.Load() != 1 {
+		t.Fatalf("%d jobs ran at once, want 1", maxRunning.Load())
+	}
+	if ran.Load()+refused.Load() != 10 || ran.Load() == 0 {
+		t.Fatalf("ran %d, refused %d", ran.Load(), refused.Load())
+	}
+}
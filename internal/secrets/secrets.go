@@ -0,0 +1,51 @@
+// Package secrets resolves secret references kept in config in place of the
+// secrets themselves. A reference is "env:NAME", read from the process
+// environment, or "file:PATH", read from a file such as a mounted Docker or
+// Kubernetes secret. Secrets are read on every Resolve, so rotating one needs
+// no restart.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrInvalidReference is returned for a reference that is neither env: nor
+// file:
+var ErrInvalidReference = errors.New("secret reference must look like env:NAME or file:PATH")
+
+// Check validates a reference without reading the secret
+func Check(ref string) error {
+	scheme, name, ok := strings.Cut(ref, ":")
+	if !ok || strings.TrimSpace(name) == "" || (scheme != "env" && scheme != "file") {
+		return fmt.Errorf("%w, got %q", ErrInvalidReference, ref)
+	}
+	return nil
+}
+
+// Resolve reads the secret a reference names. A file's trailing newline is
+// dropped; an unset or empty variable or file is an error.
+func Resolve(ref string) (string, error) {
+	if err := Check(ref); err != nil {
+		return "", err
+	}
+	scheme, name, _ := strings.Cut(ref, ":")
+
+	var value string
+	switch scheme {
+	case "env":
+		value = os.Getenv(name)
+	case "file":
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+	if value == "" {
+		return "", fmt.Errorf("secret %s is empty", ref)
+	}
+	return value, nil
+}
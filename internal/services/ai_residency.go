@@ -0,0 +1,77 @@
+package services
+
+import (
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+)
+
+// ErrExternalAIBlocked is returned when a no_external_ai datasource's data
+// would have to go to an external model because no local one is configured
+var ErrExternalAIBlocked = classErrorf(ErrSafetyBlocked, "datasource allows only local models and no Ollama model is configured")
+
+// localOnlyDatasource reports whether a datasource's schema, samples and
+// results may only be sent to local models
+func (s *AIService) localOnlyDatasource(datasourceID string) bool {
+	if datasourceID == "" {
+		return false
+	}
+	connector, err := s.registry.GetDatasource(datasourceID)
+	return err == nil && connector.NoExternalAI
+}
+
+// isExternalClient reports whether a model client sends prompts off the host
+func isExternalClient(client llm.LLMClient) bool {
+	return llm.ProviderName(client) == "openai"
+}
+
+// chatClientFor returns the chat client and model for calls carrying a
+// datasource's data: the configured chat route, or the local Ollama chat
+// model when the datasource is no_external_ai and the route is external
+func (s *AIService) chatClientFor(datasourceID, model string) (llm.LLMClient, string, error) {
+	if !isExternalClient(s.llmClient) || !s.localOnlyDatasource(datasourceID) {
+		return s.llmClient, model, nil
+	}
+	local := s.Config.Models.Ollama.Llama3Model
+	if s.Config.Models.Ollama.Host == "" || local == "" {
+		return nil, "", ErrExternalAIBlocked
+	}
+	client, err := s.pool.Client("ollama", local)
+	if err != nil {
+		return nil, "", err
+	}
+	logger.LogDebug(logger.ServiceAI, "Routing chat to a local model (no_external_ai)", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"model":         local,
+	})
+	return client, local, nil
+}
+
+// localSQLGeneratorFor returns generator, or the local SQLCoder model in its
+// place when the datasource is no_external_ai and generator is external
+func (s *AIService) localSQLGeneratorFor(datasourceID string, generator SQLGenerator) (SQLGenerator, error) {
+	if generatorIsLocal(generator) || !s.localOnlyDatasource(datasourceID) {
+		return generator, nil
+	}
+	local := s.Config.Models.Ollama.SQLCoderModel
+	if s.Config.Models.Ollama.Host == "" || local == "" {
+		return nil, ErrExternalAIBlocked
+	}
+	client, err := s.pool.Client("ollama", local)
+	if err != nil {
+		return nil, err
+	}
+	return &llmSQLGenerator{name: "sqlcoder", client: client, model: local, seed: s.Config.Models.Seed}, nil
+}
+
+// generatorIsLocal reports whether a SQL generator keeps prompts on the host.
+// HTTP generators are treated as external.
+func generatorIsLocal(generator SQLGenerator) bool {
+	switch g := generator.(type) {
+	case *deterministicSQLGenerator:
+		return true
+	case *llmSQLGenerator:
+		return !isExternalClient(g.client)
+	default:
+		return false
+	}
+}
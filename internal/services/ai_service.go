@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/NubeDev/air/internal/auth"
 	"github.com/NubeDev/air/internal/config"
 	"github.com/NubeDev/air/internal/datasource"
 	"github.com/NubeDev/air/internal/llm"
@@ -16,42 +17,111 @@ import (
 	"gorm.io/gorm"
 )
 
+// PromptVersion identifies the IR and SQL generation prompt templates. Bump it
+// whenever those prompts change so eval reports and safety checks can be
+// compared across template revisions.
+const PromptVersion = "v1"
+
 // AIService handles AI-related business logic
 type AIService struct {
 	registry          *datasource.Registry
 	db                *gorm.DB
+	pool              *llm.Pool
 	llmClient         llm.LLMClient
 	sqlClient         llm.LLMClient
 	Config            *config.Config
 	datasourceService *DatasourceService
+	sqlGenerator      SQLGenerator            // default backend (models.sql_generator)
+	sqlGenerators     map[string]SQLGenerator // per-datasource overrides
+	webhooks          *WebhookService
+	examples          *ExampleService
+	fileWorkspaces    *fileWorkspaceCache // CSV files loaded for AnswerFileQuestion
+	traceRedactors    []TraceRedactor     // applied to redacted trace exports
 }
 
 // NewAIService creates a new AI service
 func NewAIService(registry *datasource.Registry, db *gorm.DB, cfg *config.Config, datasourceService *DatasourceService) (*AIService, error) {
+	pool := llm.NewPool(cfg.Models)
+
 	// Initialize LLM client for chat
-	llmClient, err := llm.NewLLMClient(cfg)
+	llmClient, err := pool.Route(cfg, "chat")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create LLM client: %w", err)
 	}
 
 	// Initialize SQL client (could be different from chat client)
-	sqlClient, err := llm.NewSQLClient(cfg)
+	sqlClient, err := pool.Route(cfg, "sql")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SQL client: %w", err)
 	}
 
-	return &AIService{
+	s := &AIService{
 		registry:          registry,
 		db:                db,
-		llmClient:         llmClient,
-		sqlClient:         sqlClient,
+		pool:              pool,
 		Config:            cfg,
 		datasourceService: datasourceService,
-	}, nil
+		fileWorkspaces:    newFileWorkspaceCache(),
+		traceRedactors:    []TraceRedactor{redactTracePII},
+	}
+	if err := s.SetLLMClients(llmClient, sqlClient); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetLLMClients replaces the chat and SQL model clients, e.g. with an
+// llm.MockClient, and rebuilds the SQL generation backends on the new SQL client
+func (s *AIService) SetLLMClients(chat, sql llm.LLMClient) error {
+	cfg := s.Config
+	generator, err := NewSQLGenerator(cfg, cfg.Models.SQLGenerator, s.pool, sql, s.generateDatabaseSpecificSQL)
+	if err != nil {
+		return fmt.Errorf("failed to create SQL generator: %w", err)
+	}
+	generators := make(map[string]SQLGenerator)
+	for _, source := range cfg.AnalyticsSources {
+		if source.SQLGenerator.Type == "" {
+			continue
+		}
+		generator, err := NewSQLGenerator(cfg, source.SQLGenerator, s.pool, sql, s.generateDatabaseSpecificSQL)
+		if err != nil {
+			return fmt.Errorf("failed to create SQL generator for datasource %s: %w", source.ID, err)
+		}
+		generators[source.ID] = generator
+	}
+
+	s.llmClient = chat
+	s.sqlClient = sql
+	s.sqlGenerator = generator
+	s.sqlGenerators = generators
+	return nil
+}
+
+// SetWebhooks enables lifecycle event delivery for run analyses
+func (s *AIService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// SetExamples enables few-shot examples in SQL generation prompts
+func (s *AIService) SetExamples(examples *ExampleService) {
+	s.examples = examples
+}
+
+// PoolStats reports in-flight model calls per provider
+func (s *AIService) PoolStats() map[string]llm.PoolStats {
+	return s.pool.Stats()
+}
+
+// sqlGeneratorFor returns the SQL generation backend configured for a datasource
+func (s *AIService) sqlGeneratorFor(datasourceID string) SQLGenerator {
+	if generator, ok := s.sqlGenerators[datasourceID]; ok {
+		return generator
+	}
+	return s.sqlGenerator
 }
 
 // BuildIR builds Intermediate Representation from scope
-func (s *AIService) BuildIR(req store.BuildIRRequest) (map[string]interface{}, error) {
+func (s *AIService) BuildIR(ctx context.Context, req store.BuildIRRequest) (map[string]interface{}, error) {
 	start := time.Now()
 
 	logger.LogInfo(logger.ServiceAI, "Building Intermediate Representation", map[string]interface{}{
@@ -64,7 +134,7 @@ func (s *AIService) BuildIR(req store.BuildIRRequest) (map[string]interface{}, e
 		logger.LogError(logger.ServiceAI, "Failed to load scope version", err, map[string]interface{}{
 			"scope_version_id": req.ScopeVersionID,
 		})
-		return nil, fmt.Errorf("scope version not found")
+		return nil, classErrorf(ErrNotFound, "scope version not found")
 	}
 
 	// Get the datasource to understand the schema
@@ -73,7 +143,7 @@ func (s *AIService) BuildIR(req store.BuildIRRequest) (map[string]interface{}, e
 		logger.LogError(logger.ServiceAI, "Failed to load datasource", err, map[string]interface{}{
 			"datasource_id": req.DatasourceID,
 		})
-		return nil, fmt.Errorf("datasource not found")
+		return nil, classErrorf(ErrNotFound, "datasource not found")
 	}
 
 	// Get schema information for the datasource
@@ -86,57 +156,23 @@ func (s *AIService) BuildIR(req store.BuildIRRequest) (map[string]interface{}, e
 		schemaNotes = []store.SchemaNote{}
 	}
 
-	// Compose chat to convert scope markdown to IR JSON with schema context
-	systemMsg := llm.Message{
-		Role:    "system",
-		Content: "You are an expert data analyst. Convert the user's scope (Markdown) into a compact JSON Intermediate Representation (IR) for analytics. Respond with ONLY valid JSON (no code fences, no commentary).\n\nIMPORTANT: \n- Use ONLY the actual column names from the schema information provided\n- If the goal mentions 'sum sales per customer name', you MUST include:\n  * select: [\"customer_name\", {\"SUM(total_amount)\": \"total_sales\"}]\n  * group_by: [\"customer_name\"]\n  * filters: [{\"field\": \"customer_name\", \"op\": \"=\", \"value\": \"{{customer_name}}\"}]\n- Always include proper aggregation functions (SUM, COUNT, AVG, etc.) when needed\n- Make filters parameterizable using {{param_name}} syntax\n- NEVER leave select array empty - always specify what to select\n\nIR schema: {\n  \"dataset\": string,                  // main table/view or dataset\n  \"select\": [string | object],        // columns or expressions to select (use actual column names)\n  \"filters\": [                        // simple filter list\n    {\n      \"field\": string,\n      \"op\": one of [=,!=,>,>=,<,<=,IN,NOT IN,LIKE,BETWEEN],\n      \"value\": any | [any, any] | \"{{param_name}}\"\n    }\n  ],\n  \"group_by\": [string],               // optional group by columns (use actual column names)\n  \"order_by\": [{\"field\": string, \"dir\": one of [ASC, DESC]}],\n  \"limit\": number                     // optional row limit\n}",
-	}
-
 	// Include schema information in the user message
 	schemaInfo := ""
-	if len(schemaNotes) > 0 {
-		var schemaStrings []string
-		for _, note := range schemaNotes {
-			schemaStrings = append(schemaStrings, note.MD)
-		}
-		schemaInfo = fmt.Sprintf("\n\nAvailable schema information:\n%s", strings.Join(schemaStrings, "\n"))
+	glossary := glossaryFor(s.db, req.DatasourceID)
+	if context := s.schemaContext(req.DatasourceID, schemaNotes, glossary); context != "" {
+		schemaInfo = "\n\n" + context
 	}
 
-	userMsg := llm.Message{
-		Role:    "user",
-		Content: fmt.Sprintf("Scope Markdown:\n\n%s%s\n\nGenerate IR now.", scopeVersion.ScopeMD, schemaInfo),
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	model := llm.GetModelName(s.Config, "chat")
-
-	chatReq := llm.ChatRequest{
-		Model:    model,
-		Messages: []llm.Message{systemMsg, userMsg},
-		Stream:   false,
-		Options: &api.Options{
-			Temperature: 0.2,
-			TopP:        0.9,
-		},
-	}
-
-	resp, err := s.llmClient.ChatCompletion(ctx, chatReq)
+	// Convert the scope markdown to IR JSON with schema context
+	ir, err := s.chatIR(ctx, "build_ir", traceLink{DatasourceID: req.DatasourceID, ScopeVersionID: &scopeVersion.ID},
+		fmt.Sprintf("Scope Markdown:\n\n%s%s\n\nGenerate IR now.", scopeVersion.ScopeMD, schemaInfo))
 	if err != nil {
-		return nil, fmt.Errorf("failed to build IR: %w", err)
+		return nil, err
 	}
 
-	// Sanitize/parse JSON
-	content := strings.TrimSpace(resp.Message.Content)
-	jsonBytes := sanitizeModelJSONOutput(content)
-
-	var ir map[string]interface{}
-	if uErr := json.Unmarshal(jsonBytes, &ir); uErr != nil {
-		logger.LogError(logger.ServiceAI, "Failed to parse IR JSON", uErr, map[string]interface{}{
-			"content_head": content[:min(200, len(content))],
-		})
-		return nil, fmt.Errorf("model did not return valid IR JSON: %w", uErr)
+	// Pin glossary terms to their mapped columns whatever the model picked
+	if resolved := resolveGlossaryIR(ir, glossary); len(resolved) > 0 {
+		ir["glossary"] = resolved
 	}
 
 	// Persist IR back to scope version
@@ -158,25 +194,89 @@ func (s *AIService) BuildIR(req store.BuildIRRequest) (map[string]interface{}, e
 	return ir, nil
 }
 
+// irSystemPrompt has the chat model convert a description of what to query
+// into IR JSON
+const irSystemPrompt = "You are an expert data analyst. Convert the user's scope (Markdown) into a compact JSON Intermediate Representation (IR) for analytics. Respond with ONLY valid JSON (no code fences, no commentary).\n\nIMPORTANT: \n- Use ONLY the actual column names from the schema information provided\n- If the goal mentions 'sum sales per customer name', you MUST include:\n  * select: [\"customer_name\", {\"SUM(total_amount)\": \"total_sales\"}]\n  * group_by: [\"customer_name\"]\n  * filters: [{\"field\": \"customer_name\", \"op\": \"=\", \"value\": \"{{customer_name}}\"}]\n- Always include proper aggregation functions (SUM, COUNT, AVG, etc.) when needed\n- Make filters parameterizable using {{param_name}} syntax\n- NEVER leave select array empty - always specify what to select\n\nIR schema: {\n  \"dataset\": string,                  // main table/view or dataset\n  \"select\": [string | object],        // columns or expressions to select (use actual column names)\n  \"filters\": [                        // simple filter list\n    {\n      \"field\": string,\n      \"op\": one of [=,!=,>,>=,<,<=,IN,NOT IN,LIKE,BETWEEN],\n      \"value\": any | [any, any] | \"{{param_name}}\"\n    }\n  ],\n  \"group_by\": [string],               // optional group by columns (use actual column names)\n  \"order_by\": [{\"field\": string, \"dir\": one of [ASC, DESC]}],\n  \"limit\": number,                    // optional row limit\n  \"joins\": [                         // optional; only when the question spans tables\n    {\"dataset\": string, \"type\": one of [inner,left], \"on\": [{\"left\": \"table.column\", \"right\": \"table.column\"}]}\n  ],\n  \"timeseries\": {                    // optional; only for bucketed time-series questions (e.g. 15-minute kWh rolled up hourly)\n    \"time_field\": string,\n    \"metrics\": [{\"field\": string, \"agg\": one of [sum,avg,min,max,count], \"as\": string}],\n    \"resample\": \"15m\",                // bucket raw rows (m, h, d, w, mo)\n    \"rollup\": {\"interval\": \"1h\", \"agg\": string},  // optional coarser re-aggregation\n    \"gap_fill\": one of [null,zero,locf,interpolate],  // optional; fills empty buckets between {{start_date}} and {{end_date}}\n    \"window\": [{\"func\": one of [avg,sum,min,max,lag,delta], \"field\": metric alias, \"size\": number, \"as\": string}],\n    \"partition_by\": [string]          // series keys, e.g. meter_id\n  }\n}"
+
+// chatIR has the chat model convert userContent, a scope or question with
+// the schema it is asked against, into IR
+func (s *AIService) chatIR(ctx context.Context, operation string, link traceLink, userContent string) (map[string]interface{}, error) {
+	ctx, cancel := s.operationContext(ctx, opIRBuild)
+	defer cancel()
+
+	resp, err := s.tracedChat(ctx, operation, link, llm.ChatRequest{
+		Model: llm.GetModelName(s.Config, "chat"),
+		Messages: []llm.Message{
+			{Role: "system", Content: irSystemPrompt},
+			{Role: "user", Content: userContent},
+		},
+		Stream:  false,
+		Options: s.samplingOptions(0.2, 0.9),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build IR: %w", s.aiCallError(ctx, opIRBuild, err))
+	}
+
+	// Sanitize/parse JSON
+	content := strings.TrimSpace(resp.Message.Content)
+	jsonBytes := sanitizeModelJSONOutput(content)
+
+	var ir map[string]interface{}
+	if uErr := json.Unmarshal(jsonBytes, &ir); uErr != nil {
+		logger.LogError(logger.ServiceAI, "Failed to parse IR JSON", uErr, map[string]interface{}{
+			"content_head": content[:min(200, len(content))],
+		})
+		return nil, fmt.Errorf("model did not return valid IR JSON: %w", uErr)
+	}
+	return ir, nil
+}
+
 // GenerateSQLFromIR generates SQL from IR for a specific datasource
-func (s *AIService) GenerateSQLFromIR(req store.GenerateSQLRequest) (string, map[string]interface{}, error) {
+func (s *AIService) GenerateSQLFromIR(ctx context.Context, req store.GenerateSQLRequest) (string, map[string]interface{}, error) {
 	start := time.Now()
 
-	logger.LogInfo(logger.ServiceAI, "Generating SQL from IR (SQLCoder)", map[string]interface{}{
-		"datasource_id": req.DatasourceID,
-	})
+	generator, err := s.localSQLGeneratorFor(req.DatasourceID, s.sqlGeneratorFor(req.DatasourceID))
+	if err != nil {
+		return "", nil, err
+	}
+
+	ts, err := parseTimeSeriesIR(req.IR)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid timeseries IR: %w", err)
+	}
 
 	// Get datasource (to determine dialect label)
 	connector, err := s.registry.GetDatasource(req.DatasourceID)
 	if err != nil {
-		return "", nil, fmt.Errorf("datasource not found: %w", err)
+		return "", nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
 	}
 
-	// Convert IR to natural language prompt for SQLCoder
-	prompt, err := s.buildSQLCoderPromptFromIR(req.IR, connector.Kind)
+	var caggs []store.ContinuousAggregate
+	if connector.Dialect() == "timescaledb" {
+		caggs = s.continuousAggregatesFor(req.DatasourceID)
+	}
+	if ts != nil {
+		// Bucketing and gap filling are compiled directly; models rarely get them right
+		generator = &deterministicSQLGenerator{compile: func(ir map[string]interface{}, dbKind string) string {
+			return s.generateTimeSeriesSQL(ir, ts, dbKind, caggs)
+		}}
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Generating SQL from IR", map[string]interface{}{
+		"datasource_id": req.DatasourceID,
+		"generator":     generator.Name(),
+	})
+
+	// Convert IR to natural language prompt for model-based generators
+	prompt, err := s.buildSQLCoderPromptFromIR(req.IR, connector.Dialect())
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to build SQLCoder prompt: %w", err)
 	}
+	var examples []store.SQLExample
+	if s.examples != nil {
+		examples = s.examples.SimilarExamples(req.DatasourceID, prompt, maxPromptExamples)
+	}
+	prompt += continuousAggregateHint(caggs)
 
 	// Get schema information for the datasource
 	schema, err := s.getDatasourceSchema(req.DatasourceID)
@@ -184,24 +284,34 @@ func (s *AIService) GenerateSQLFromIR(req store.GenerateSQLRequest) (string, map
 		return "", nil, fmt.Errorf("failed to get datasource schema: %w", err)
 	}
 
-	// Use SQLCoder to generate SQL
-	sql, err := s.GenerateSQL(prompt, schema)
+	ctx, cancel := s.operationContext(ctx, opSQLGenerate)
+	defer cancel()
+
+	trace := &generationTrace{}
+	genStart := time.Now()
+	sql, err := generator.GenerateSQL(ctx, SQLGenerationRequest{
+		DatasourceID: req.DatasourceID,
+		Dialect:      connector.Dialect(),
+		IR:           req.IR,
+		Prompt:       prompt,
+		Schema:       schema,
+		Examples:     examples,
+		Trace:        trace,
+	})
+	s.recordGeneration(ctx, trace, traceLink{DatasourceID: req.DatasourceID}, time.Since(genStart), err)
 	if err != nil {
-		return "", nil, fmt.Errorf("SQLCoder generation failed: %w", err)
+		return "", nil, fmt.Errorf("%s generation failed: %w", generator.Name(), s.aiCallError(ctx, opSQLGenerate, err))
 	}
 
 	if sql == "" {
-		return "", nil, fmt.Errorf("SQLCoder returned empty result")
+		return "", nil, fmt.Errorf("%s returned empty result", generator.Name())
 	}
 
-	// Use the SQLCoder-generated SQL directly
-	// SQLCoder already knows the database type and generates appropriate SQL
-
 	// Safety report (structure only; checks can be expanded later)
 	safetyReport := map[string]interface{}{
 		"read_only": true,
 		"warnings":  []string{},
-		"checks":    map[string]any{"generated_by": "sqlcoder"},
+		"checks":    map[string]any{"generated_by": generator.Name(), "dialect": connector.Dialect(), "examples": len(examples), "prompt_version": PromptVersion},
 	}
 
 	duration := time.Since(start)
@@ -215,6 +325,10 @@ func (s *AIService) GenerateSQLFromIR(req store.GenerateSQLRequest) (string, map
 
 // generateDatabaseSpecificSQL generates SQL optimized for specific database types
 func (s *AIService) generateDatabaseSpecificSQL(ir map[string]interface{}, dbKind string) string {
+	if ts, err := parseTimeSeriesIR(ir); err == nil && ts != nil {
+		return s.generateTimeSeriesSQL(ir, ts, dbKind, nil)
+	}
+
 	switch strings.ToLower(dbKind) {
 	case "sqlite", "sqlite3":
 		return s.generateSQLiteSQL(ir)
@@ -247,6 +361,7 @@ func (s *AIService) generateSQLiteSQL(ir map[string]interface{}) string {
 
 	// Build FROM clause
 	sql.WriteString(fmt.Sprintf(" FROM %s", dataset))
+	sql.WriteString(buildJoinClause(ir))
 
 	// Build WHERE clause with date range filtering
 	whereClause := s.buildWhereClause(filters, "sqlite")
@@ -300,6 +415,7 @@ func (s *AIService) generatePostgreSQLSQL(ir map[string]interface{}) string {
 
 	// Build FROM clause
 	sql.WriteString(fmt.Sprintf(" FROM %s", dataset))
+	sql.WriteString(buildJoinClause(ir))
 
 	// Build WHERE clause
 	whereClause := s.buildWhereClause(filters, "postgres")
@@ -349,6 +465,7 @@ func (s *AIService) generateMySQLSQL(ir map[string]interface{}) string {
 
 	// Build FROM clause
 	sql.WriteString(fmt.Sprintf(" FROM %s", dataset))
+	sql.WriteString(buildJoinClause(ir))
 
 	// Build WHERE clause
 	whereClause := s.buildWhereClause(filters, "mysql")
@@ -493,16 +610,8 @@ func (s *AIService) buildFunctionCall(field, funcName, dbType string) string {
 	case "min":
 		return fmt.Sprintf("MIN(%s)", field)
 	case "date":
-		switch dbType {
-		case "sqlite":
-			return fmt.Sprintf("date(%s)", field)
-		case "postgres":
-			return fmt.Sprintf("date_trunc('day', %s)", field)
-		case "mysql":
-			return fmt.Sprintf("DATE(%s)", field)
-		default:
-			return fmt.Sprintf("date(%s)", field)
-		}
+		// Truncate in the report timezone so days don't shift across midnight UTC
+		return localDateExpr(field, dbType)
 	default:
 		return field
 	}
@@ -651,22 +760,29 @@ func (s *AIService) buildWhereClause(filters []interface{}, dbType string) strin
 	return strings.Join(conditions, " AND ")
 }
 
-// buildDateRangeWhereClause builds a WHERE clause for date range filtering
+// buildDateRangeWhereClause builds a WHERE clause for date range filtering.
+// Both bounds are inclusive calendar dates in the report timezone.
 func (s *AIService) buildDateRangeWhereClause(timestampField string, dbType string) string {
-	switch dbType {
-	case "sqlite":
-		return fmt.Sprintf("date(%s) BETWEEN '{{start_date}}' AND '{{end_date}}'", timestampField)
-	case "postgres":
-		return fmt.Sprintf("date_trunc('day', %s) BETWEEN '{{start_date}}' AND '{{end_date}}'", timestampField)
-	case "mysql":
-		return fmt.Sprintf("DATE(%s) BETWEEN '{{start_date}}' AND '{{end_date}}'", timestampField)
-	default:
-		return fmt.Sprintf("date(%s) BETWEEN '{{start_date}}' AND '{{end_date}}'", timestampField)
+	if dbType == "timescaledb" {
+		return timescaleTimeRangeClause(timestampField)
+	}
+	return fmt.Sprintf("%s BETWEEN %s AND %s",
+		localDateExpr(timestampField, dbType),
+		dateLiteralExpr("start_date", dbType),
+		dateLiteralExpr("end_date", dbType))
+}
+
+// runAnalysisPrompt summarizes a run for the analysis model
+func runAnalysisPrompt(db *gorm.DB, run *store.ReportRun) string {
+	summary := fmt.Sprintf("Run Summary:\nStatus: %s\nRow Count: %d\nParams: %s\nSQL:\n%s\n\n", run.Status, run.RowCount, run.ParamsJSON, run.SQLText)
+	if run.ErrorText != "" {
+		summary += fmt.Sprintf("Error: %s\n", run.ErrorText)
 	}
+	return summary + columnAnnotationsMarkdown(resultColumnAnnotations(db, run.DatasourceID, run.SQLText, run.Results))
 }
 
 // AnalyzeRun analyzes a report run with AI
-func (s *AIService) AnalyzeRun(runID uint, req store.AnalyzeRunRequest) (*store.ReportAnalysis, error) {
+func (s *AIService) AnalyzeRun(ctx context.Context, runID uint, req store.AnalyzeRunRequest) (*store.ReportAnalysis, error) {
 	start := time.Now()
 
 	// Load run
@@ -675,7 +791,7 @@ func (s *AIService) AnalyzeRun(runID uint, req store.AnalyzeRunRequest) (*store.
 		logger.LogError(logger.ServiceAI, "Failed to load report run", err, map[string]interface{}{
 			"run_id": runID,
 		})
-		return nil, fmt.Errorf("run not found")
+		return nil, classErrorf(ErrNotFound, "run not found")
 	}
 
 	// Build prompt requesting structured verdict JSON and markdown analysis
@@ -689,14 +805,9 @@ func (s *AIService) AnalyzeRun(runID uint, req store.AnalyzeRunRequest) (*store.
 		Content: "You are a senior data analyst. Analyze the SQL execution results and produce: (1) a JSON verdict with keys: {score: number 0-100, severity: one of [info,warning,error], key_findings: [string], anomalies: [string], recommendations: [string]}, and (2) a concise Markdown analysis. Respond with ONLY JSON in the shape {\"verdict\": {...}, \"analysis_md\": string}.",
 	}
 
-	summary := fmt.Sprintf("Run Summary:\nStatus: %s\nRow Count: %d\nParams: %s\nSQL:\n%s\n\n", run.Status, run.RowCount, run.ParamsJSON, run.SQLText)
-	if run.ErrorText != "" {
-		summary += fmt.Sprintf("Error: %s\n", run.ErrorText)
-	}
+	userMsg := llm.Message{Role: "user", Content: runAnalysisPrompt(s.db, &run)}
 
-	userMsg := llm.Message{Role: "user", Content: summary}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := s.operationContext(ctx, opAnalyze)
 	defer cancel()
 
 	model := llm.GetModelName(s.Config, "chat")
@@ -705,12 +816,12 @@ func (s *AIService) AnalyzeRun(runID uint, req store.AnalyzeRunRequest) (*store.
 		Model:    model,
 		Messages: []llm.Message{systemMsg, userMsg},
 		Stream:   false,
-		Options:  &api.Options{Temperature: 0.3, TopP: 0.9},
+		Options:  s.samplingOptions(0.3, 0.9),
 	}
 
-	resp, err := s.llmClient.ChatCompletion(ctx, chatReq)
+	resp, err := s.tracedChat(ctx, "analyze_run", traceLink{DatasourceID: run.DatasourceID, RunID: &run.ID}, chatReq)
 	if err != nil {
-		return nil, fmt.Errorf("analysis failed: %w", err)
+		return nil, fmt.Errorf("analysis failed: %w", s.aiCallError(ctx, opAnalyze, err))
 	}
 
 	content := strings.TrimSpace(resp.Message.Content)
@@ -736,12 +847,14 @@ func (s *AIService) AnalyzeRun(runID uint, req store.AnalyzeRunRequest) (*store.
 		RubricVersion: rubricVersion,
 		VerdictJSON:   string(verdictJSON),
 		AnalysisMD:    parsed.AnalysisMD,
+		TraceID:       run.TraceID,
 		CreatedAt:     time.Now(),
 	}
 
 	if err := s.db.Create(analysis).Error; err != nil {
 		logger.LogError(logger.ServiceAI, "Failed to save analysis", err, map[string]interface{}{
-			"run_id": run.ID,
+			"run_id":   run.ID,
+			"trace_id": run.TraceID,
 		})
 		return nil, fmt.Errorf("failed to save analysis: %w", err)
 	}
@@ -749,9 +862,19 @@ func (s *AIService) AnalyzeRun(runID uint, req store.AnalyzeRunRequest) (*store.
 	duration := time.Since(start)
 	logger.LogInfo(logger.ServiceAI, "Run analysis completed", map[string]interface{}{
 		"run_id":   run.ID,
+		"trace_id": run.TraceID,
 		"duration": duration.String(),
 	})
 
+	s.webhooks.Emit(EventAnalysisCompleted, map[string]interface{}{
+		"analysis_id": analysis.ID,
+		"run_id":      run.ID,
+		"trace_id":    run.TraceID,
+		"report_id":   run.ReportID,
+		"model":       model,
+		"verdict":     parsed.Verdict,
+	})
+
 	return analysis, nil
 }
 
@@ -806,8 +929,10 @@ func (s *AIService) GetAITools() ([]map[string]interface{}, error) {
 	}
 
 	provider := "Ollama"
-	if s.Config.Models.ChatPrimary == "openai" {
+	if s.Config.Models.ChatPrimary == "openai" && !llm.IsLocalOnly() {
 		provider = "OpenAI"
+	} else if llm.IsMockRoute(s.Config, "chat") {
+		provider = "mock"
 	}
 
 	return append(tools, map[string]interface{}{
@@ -818,12 +943,21 @@ func (s *AIService) GetAITools() ([]map[string]interface{}, error) {
 	}), nil
 }
 
-// ChatCompletion performs a chat completion using the configured model
-func (s *AIService) ChatCompletion(messages []llm.Message) (*llm.ChatResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+// ChatCompletion performs a chat completion using the caller's preferred chat
+// model, or the configured one
+func (s *AIService) ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error) {
+	ctx, cancel := s.operationContext(ctx, opChat)
 	defer cancel()
 
 	model := llm.GetModelName(s.Config, "chat")
+	client := s.llmClient
+	if preferred := userPreferences(s.db, auth.Username(ctx)).ChatModel; preferred != "" && preferred != model {
+		var err error
+		if client, err = s.clientForModel(preferred); err != nil {
+			return nil, err
+		}
+		model = preferred
+	}
 
 	req := llm.ChatRequest{
 		Model:    model,
@@ -835,12 +969,13 @@ func (s *AIService) ChatCompletion(messages []llm.Message) (*llm.ChatResponse, e
 		},
 	}
 
-	return s.llmClient.ChatCompletion(ctx, req)
+	resp, err := client.ChatCompletion(ctx, req)
+	return resp, s.aiCallError(ctx, opChat, err)
 }
 
 // AiRaw performs raw AI completion without any system prompts or backend interference
-func (s *AIService) AiRaw(messages []llm.Message, modelOverride string) (*llm.ChatResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+func (s *AIService) AiRaw(ctx context.Context, messages []llm.Message, modelOverride string) (*llm.ChatResponse, error) {
+	ctx, cancel := s.operationContext(ctx, opChat)
 	defer cancel()
 
 	// Use model override if provided, otherwise use the configured chat model
@@ -849,23 +984,9 @@ func (s *AIService) AiRaw(messages []llm.Message, modelOverride string) (*llm.Ch
 		model = llm.GetModelName(s.Config, "chat")
 	}
 
-	// Create the appropriate LLM client based on the model
-	var client llm.LLMClient
-	var err error
-
-	// Determine which client to use based on the model name
-	if strings.HasPrefix(model, "gpt-") {
-		// OpenAI model
-		client, err = llm.NewOpenAIClient(s.Config.Models.OpenAI)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create OpenAI client: %w", err)
-		}
-	} else {
-		// Ollama model (llama3:latest, sqlcoder:7b, etc.)
-		client, err = llm.NewOllamaClient(s.Config.Models.Ollama)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create Ollama client: %w", err)
-		}
+	client, err := s.clientForModel(model)
+	if err != nil {
+		return nil, err
 	}
 
 	req := llm.ChatRequest{
@@ -878,40 +999,49 @@ func (s *AIService) AiRaw(messages []llm.Message, modelOverride string) (*llm.Ch
 		},
 	}
 
-	return client.ChatCompletion(ctx, req)
+	resp, err := client.ChatCompletion(ctx, req)
+	return resp, s.aiCallError(ctx, opChat, err)
 }
 
-// GenerateSQL generates SQL using SQLCoder model
-func (s *AIService) GenerateSQL(prompt string, schema string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	model := llm.GetModelName(s.Config, "sql")
-
-	// Create a comprehensive prompt for SQL generation using SQLCoder format
-	fullPrompt := fmt.Sprintf(`-- Database: PostgreSQL
--- Schema:
-%s
--- Task: %s
-
-SELECT`, schema, prompt)
-
-	req := llm.GenerateRequest{
-		Model:  model,
-		Prompt: fullPrompt,
-		Stream: false,
-		Options: &api.Options{
-			Temperature: 0.1, // Lower temperature for more deterministic SQL
-			TopP:        0.9,
-		},
+// clientForModel returns a client of the provider serving model: gpt-* is
+// OpenAI, "mock" is the mock provider, anything else (llama3:latest,
+// sqlcoder:7b, etc.) is Ollama
+func (s *AIService) clientForModel(model string) (llm.LLMClient, error) {
+	provider := "ollama"
+	if strings.HasPrefix(model, "gpt-") {
+		provider = "openai"
+	} else if model == llm.MockModel {
+		provider = llm.MockModel
 	}
-
-	resp, err := s.sqlClient.GenerateText(ctx, req)
+	client, err := s.pool.Client(provider, model)
 	if err != nil {
-		return "", fmt.Errorf("SQL generation failed: %w", err)
+		return nil, fmt.Errorf("failed to create %s client: %w", provider, err)
 	}
+	return client, nil
+}
+
+// GenerateSQL generates SQL from a natural language prompt using the default generator
+func (s *AIService) GenerateSQL(ctx context.Context, prompt string, schema string, dialect string) (string, error) {
+	return s.generateSQL(ctx, nil, prompt, schema, dialect)
+}
+
+// generateSQL generates SQL with the default generator from a prompt and,
+// when one was built, the IR it describes
+func (s *AIService) generateSQL(ctx context.Context, ir map[string]interface{}, prompt string, schema string, dialect string) (string, error) {
+	ctx, cancel := s.operationContext(ctx, opSQLGenerate)
+	defer cancel()
 
-	return resp.Response, nil
+	trace := &generationTrace{}
+	start := time.Now()
+	sql, err := s.sqlGenerator.GenerateSQL(ctx, SQLGenerationRequest{
+		Dialect: dialect,
+		IR:      ir,
+		Prompt:  prompt,
+		Schema:  schema,
+		Trace:   trace,
+	})
+	s.recordGeneration(ctx, trace, traceLink{}, time.Since(start), err)
+	return sql, s.aiCallError(ctx, opSQLGenerate, err)
 }
 
 // buildSQLCoderPromptFromIR converts IR into a natural language prompt for SQLCoder
@@ -926,6 +1056,13 @@ func (s *AIService) buildSQLCoderPromptFromIR(ir map[string]interface{}, datasou
 
 	// Start with the main table
 	promptParts = append(promptParts, fmt.Sprintf("Query the %s table", dataset))
+	joins, err := describeIRJoins(ir)
+	if err != nil {
+		return "", err
+	}
+	if joins != "" {
+		promptParts = append(promptParts, joins)
+	}
 
 	// Add SELECT fields description
 	if selectFields, ok := ir["select"].([]interface{}); ok && len(selectFields) > 0 {
@@ -1024,21 +1161,50 @@ func (s *AIService) buildSQLCoderPromptFromIR(ir map[string]interface{}, datasou
 	switch strings.ToLower(datasourceKind) {
 	case "sqlite", "sqlite3":
 		description += ". Use SQLite syntax."
-	case "postgres", "postgresql", "timescaledb":
+	case "postgres", "postgresql":
 		description += ". Use PostgreSQL syntax."
+	case "timescaledb":
+		description += ". Use PostgreSQL syntax with TimescaleDB: bucket time with time_bucket() and compare the raw time column to constant bounds so chunks can be excluded."
 	case "mysql":
 		description += ". Use MySQL syntax."
+	case "trino":
+		description += ". Use Trino syntax: reference tables outside the session schema as catalog.schema.table and write intervals as INTERVAL '7' DAY."
+	case "bigquery":
+		description += ". Use BigQuery GoogleSQL syntax: quote names with backticks, reference tables outside the default dataset as dataset.table and filter on partitioning columns, since queries are billed by bytes scanned."
 	}
 
 	return description, nil
 }
 
+// schemaContext renders a datasource's schema notes, column annotations,
+// masked PII columns and glossary as prompt context
+func (s *AIService) schemaContext(datasourceID string, schemaNotes []store.SchemaNote, glossary []store.GlossaryTerm) string {
+	var parts []string
+	if notes := promptSchemaNotes(schemaNotes); len(notes) > 0 {
+		var schemaStrings []string
+		for _, note := range notes {
+			schemaStrings = append(schemaStrings, schemaNoteText(note))
+		}
+		parts = append(parts, "Available schema information:\n"+strings.Join(schemaStrings, "\n"))
+	}
+	if annotations := columnAnnotationsMarkdown(annotationsFor(s.db, datasourceID)); annotations != "" {
+		parts = append(parts, annotations)
+	}
+	if pii := piiMarkdown(maskedPIIColumns(s.db, datasourceID)); pii != "" {
+		parts = append(parts, pii)
+	}
+	if terms := glossaryMarkdown(glossary); terms != "" {
+		parts = append(parts, terms)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
 // getDatasourceSchema retrieves schema information for a datasource
 func (s *AIService) getDatasourceSchema(datasourceID string) (string, error) {
 	// Get datasource connector
 	connector, err := s.registry.GetDatasource(datasourceID)
 	if err != nil {
-		return "", fmt.Errorf("datasource not found: %w", err)
+		return "", classErrorf(ErrNotFound, "datasource not found: %w", err)
 	}
 
 	// For now, return a basic schema description
@@ -1046,6 +1212,12 @@ func (s *AIService) getDatasourceSchema(datasourceID string) (string, error) {
 	schema := fmt.Sprintf(`-- Database: %s
 -- Table structure will be provided by datasource learning
 -- This is a placeholder schema that should be replaced with actual schema introspection`, connector.Kind)
+	if annotations := columnAnnotationsMarkdown(annotationsFor(s.db, datasourceID)); annotations != "" {
+		schema += "\n\n" + annotations
+	}
+	if pii := piiMarkdown(maskedPIIColumns(s.db, datasourceID)); pii != "" {
+		schema += "\n\n" + pii
+	}
 
 	return schema, nil
 }
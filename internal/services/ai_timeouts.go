@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Operation types with their own models.timeouts setting
+const (
+	opIRBuild     = "ir_build"
+	opSQLGenerate = "sql_generate"
+	opAnalyze     = "analyze"
+	opChat        = "chat"
+)
+
+// defaultAITimeout applies when an operation's timeout is not configured
+const defaultAITimeout = 60 * time.Second
+
+var (
+	// ErrAITimeout is returned when a model call exceeds its operation timeout
+	ErrAITimeout = errors.New("AI operation timed out")
+	// ErrAICanceled is returned when the caller went away before a model call finished
+	ErrAICanceled = errors.New("AI operation canceled")
+)
+
+// aiTimeout returns the configured timeout for an operation type
+func (s *AIService) aiTimeout(op string) time.Duration {
+	timeouts := s.Config.Models.Timeouts
+	var timeout time.Duration
+	switch op {
+	case opIRBuild:
+		timeout = timeouts.IRBuild
+	case opSQLGenerate:
+		timeout = timeouts.SQLGenerate
+	case opAnalyze:
+		timeout = timeouts.Analyze
+	case opChat:
+		timeout = timeouts.Chat
+	}
+	if timeout <= 0 {
+		return defaultAITimeout
+	}
+	return timeout
+}
+
+// operationContext bounds a model call by its operation timeout while still
+// ending it when parent (usually the HTTP request) is canceled
+func (s *AIService) operationContext(parent context.Context, op string) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, s.aiTimeout(op))
+}
+
+// aiCallError classifies a failed model call by why its context ended, so
+// handlers can tell timeouts and disconnects from provider errors
+func (s *AIService) aiCallError(ctx context.Context, op string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%w: %s exceeded %s", ErrAITimeout, op, s.aiTimeout(op))
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%w: %s", ErrAICanceled, op)
+	}
+	return err
+}
+
+// traceOperationType maps a traced operation to its timeout setting
+func traceOperationType(operation string) string {
+	switch operation {
+	case "build_ir":
+		return opIRBuild
+	case "generate_sql":
+		return opSQLGenerate
+	case "analyze_run":
+		return opAnalyze
+	}
+	return opChat
+}
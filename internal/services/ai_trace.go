@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/ollama/ollama/api"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrAITraceNotFound is returned for unknown trace IDs
+	ErrAITraceNotFound = classErrorf(ErrNotFound, "AI trace not found")
+	// ErrTraceNotReplayable is returned for traces that did not call a model
+	ErrTraceNotReplayable = classErrorf(ErrValidation, "trace cannot be replayed")
+)
+
+// traceLink ties a traced call to the objects it was made for
+type traceLink struct {
+	DatasourceID   string
+	ScopeVersionID *uint
+	RunID          *uint
+}
+
+// traceOptions are the sampling options recorded with a trace
+type traceOptions struct {
+	Temperature float32 `json:"temperature"`
+	TopP        float32 `json:"top_p"`
+	Seed        int     `json:"seed,omitempty"`
+}
+
+// generationTrace captures what a SQLGenerator sent to its backend so
+// GenerateSQL calls are traced like chat calls
+type generationTrace struct {
+	CallType string
+	Provider string
+	Model    string
+	Prompt   string
+	Options  *api.Options
+	Response string
+}
+
+// samplingOptions returns options with models.seed applied
+func (s *AIService) samplingOptions(temperature, topP float32) *api.Options {
+	return &api.Options{Temperature: temperature, TopP: topP, Seed: s.Config.Models.Seed}
+}
+
+// tracedChat runs a chat completion on the chat client and records it. Calls
+// for a no_external_ai datasource go to the local chat model.
+func (s *AIService) tracedChat(ctx context.Context, operation string, link traceLink, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	client, model, err := s.chatClientFor(link.DatasourceID, req.Model)
+	if err != nil {
+		return nil, err
+	}
+	req.Model = model
+
+	start := time.Now()
+	resp, err := client.ChatCompletion(ctx, req)
+
+	messages, _ := json.Marshal(req.Messages)
+	trace := &store.AITrace{
+		Operation:    operation,
+		CallType:     "chat",
+		Provider:     llm.ProviderName(client),
+		Model:        req.Model,
+		MessagesJSON: string(messages),
+		OptionsJSON:  encodeTraceOptions(req.Options),
+		DurationMS:   time.Since(start).Milliseconds(),
+	}
+	if resp != nil {
+		trace.Response = resp.Message.Content
+	}
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	s.recordTrace(ctx, trace, link)
+	return resp, err
+}
+
+// recordGeneration records a SQL generator call; generators that never reach
+// a backend (deterministic) leave the capture empty and are not traced
+func (s *AIService) recordGeneration(ctx context.Context, gen *generationTrace, link traceLink, duration time.Duration, err error) {
+	if gen.CallType == "" {
+		return
+	}
+	trace := &store.AITrace{
+		Operation:   "generate_sql",
+		CallType:    gen.CallType,
+		Provider:    gen.Provider,
+		Model:       gen.Model,
+		Prompt:      gen.Prompt,
+		OptionsJSON: encodeTraceOptions(gen.Options),
+		Response:    gen.Response,
+		DurationMS:  duration.Milliseconds(),
+	}
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	s.recordTrace(ctx, trace, link)
+}
+
+// recordTrace persists a trace attributed to the caller in ctx; failures are
+// logged and never fail the call
+func (s *AIService) recordTrace(ctx context.Context, trace *store.AITrace, link traceLink) {
+	trace.Username = auth.Username(ctx)
+	trace.DatasourceID = link.DatasourceID
+	trace.ScopeVersionID = link.ScopeVersionID
+	trace.RunID = link.RunID
+	trace.CreatedAt = time.Now()
+	if err := s.db.Create(trace).Error; err != nil {
+		logger.LogWarn(logger.ServiceAI, "Failed to record AI trace", map[string]interface{}{
+			"operation": trace.Operation,
+			"error":     err.Error(),
+		})
+	}
+}
+
+func encodeTraceOptions(options *api.Options) string {
+	if options == nil {
+		return ""
+	}
+	raw, _ := json.Marshal(traceOptions{Temperature: options.Temperature, TopP: options.TopP, Seed: options.Seed})
+	return string(raw)
+}
+
+// ListAITraces returns the newest traces, optionally filtered by operation,
+// run or scope version
+func (s *AIService) ListAITraces(operation string, runID, scopeVersionID uint, limit int) ([]store.AITrace, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := s.db.Order("created_at DESC").Limit(limit)
+	if operation != "" {
+		query = query.Where("operation = ?", operation)
+	}
+	if runID != 0 {
+		query = query.Where("run_id = ?", runID)
+	}
+	if scopeVersionID != 0 {
+		query = query.Where("scope_version_id = ?", scopeVersionID)
+	}
+	var traces []store.AITrace
+	if err := query.Find(&traces).Error; err != nil {
+		return nil, fmt.Errorf("failed to list AI traces: %w", err)
+	}
+	return traces, nil
+}
+
+// GetAITrace returns one trace
+func (s *AIService) GetAITrace(id uint) (*store.AITrace, error) {
+	var trace store.AITrace
+	if err := s.db.First(&trace, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAITraceNotFound
+		}
+		return nil, fmt.Errorf("failed to load AI trace: %w", err)
+	}
+	return &trace, nil
+}
+
+// ReplayAITrace re-issues a traced prompt with its recorded options, against
+// the original or a chosen provider/model, and records the result as a new
+// trace linked to the original
+func (s *AIService) ReplayAITrace(ctx context.Context, id uint, req store.ReplayAITraceRequest) (*store.ReplayAITraceResponse, error) {
+	original, err := s.GetAITrace(id)
+	if err != nil {
+		return nil, err
+	}
+	if original.CallType != "chat" && original.CallType != "generate" {
+		return nil, fmt.Errorf("%w: %s calls have no model prompt", ErrTraceNotReplayable, original.CallType)
+	}
+
+	provider := req.Provider
+	if provider == "" {
+		provider = original.Provider
+	}
+	model := req.Model
+	if model == "" {
+		model = original.Model
+	}
+
+	if provider != "openai" && provider != "ollama" {
+		return nil, fmt.Errorf("%w: unknown provider %q", ErrTraceNotReplayable, provider)
+	}
+	if provider == "openai" && s.localOnlyDatasource(original.DatasourceID) {
+		return nil, classErrorf(ErrSafetyBlocked, "datasource %s allows only local models", original.DatasourceID)
+	}
+	client, err := s.pool.Client(provider, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", provider, err)
+	}
+
+	var recorded traceOptions
+	if original.OptionsJSON != "" {
+		if err := json.Unmarshal([]byte(original.OptionsJSON), &recorded); err != nil {
+			return nil, fmt.Errorf("%w: invalid recorded options: %v", ErrTraceNotReplayable, err)
+		}
+	}
+	if req.Seed != nil {
+		recorded.Seed = *req.Seed
+	}
+	options := &api.Options{Temperature: recorded.Temperature, TopP: recorded.TopP, Seed: recorded.Seed}
+
+	replay := &store.AITrace{
+		Operation:    original.Operation,
+		CallType:     original.CallType,
+		Provider:     provider,
+		Model:        model,
+		MessagesJSON: original.MessagesJSON,
+		Prompt:       original.Prompt,
+		OptionsJSON:  encodeTraceOptions(options),
+		ReplayOfID:   &original.ID,
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Replaying AI trace", map[string]interface{}{
+		"trace_id": original.ID,
+		"provider": provider,
+		"model":    model,
+	})
+
+	op := traceOperationType(original.Operation)
+	ctx, cancel := s.operationContext(ctx, op)
+	defer cancel()
+
+	start := time.Now()
+	var callErr error
+	if original.CallType == "chat" {
+		var messages []llm.Message
+		if err := json.Unmarshal([]byte(original.MessagesJSON), &messages); err != nil {
+			return nil, fmt.Errorf("%w: invalid recorded messages: %v", ErrTraceNotReplayable, err)
+		}
+		var resp *llm.ChatResponse
+		if resp, callErr = client.ChatCompletion(ctx, llm.ChatRequest{Model: model, Messages: messages, Options: options}); callErr == nil {
+			replay.Response = resp.Message.Content
+		}
+	} else {
+		var resp *llm.GenerateResponse
+		if resp, callErr = client.GenerateText(ctx, llm.GenerateRequest{Model: model, Prompt: original.Prompt, Options: options}); callErr == nil {
+			replay.Response = resp.Response
+		}
+	}
+	replay.DurationMS = time.Since(start).Milliseconds()
+	if callErr != nil {
+		callErr = s.aiCallError(ctx, op, callErr)
+		replay.Error = callErr.Error()
+	}
+
+	s.recordTrace(ctx, replay, traceLink{
+		DatasourceID:   original.DatasourceID,
+		ScopeVersionID: original.ScopeVersionID,
+		RunID:          original.RunID,
+	})
+	if errors.Is(callErr, ErrAITimeout) || errors.Is(callErr, ErrAICanceled) {
+		return nil, callErr
+	}
+
+	return &store.ReplayAITraceResponse{
+		Trace:           replay,
+		MatchesOriginal: replay.Error == "" && original.Error == "" && replay.Response == original.Response,
+	}, nil
+}
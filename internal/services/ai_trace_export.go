@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// traceExportBatch is how many traces are read per query while exporting
+const traceExportBatch = 200
+
+// TraceRedactor rewrites a trace before a redacted export writes it, e.g. to
+// mask personal data in the prompt and response
+type TraceRedactor func(trace *store.AITrace)
+
+// AddTraceRedactor adds a hook applied, in order, to every trace of a
+// redacted export. Register hooks during setup, before exports run.
+func (s *AIService) AddTraceRedactor(redactor TraceRedactor) {
+	s.traceRedactors = append(s.traceRedactors, redactor)
+}
+
+// ExportAITraces writes the traces matching filter to w as JSON lines, oldest
+// first, and returns how many were written. The filter is checked before
+// anything is written, so a validation error leaves w untouched.
+func (s *AIService) ExportAITraces(ctx context.Context, w io.Writer, filter store.AITraceExportFilter) (int, error) {
+	query := s.db.WithContext(ctx).Model(&store.AITrace{}).Order("id ASC")
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return 0, classErrorf(ErrValidation, "to must be after from")
+	}
+	if filter.User != "" {
+		query = query.Where("username = ?", filter.User)
+	}
+	if filter.Operation != "" {
+		query = query.Where("operation = ?", filter.Operation)
+	}
+	if filter.ReportKey != "" {
+		var report store.Report
+		if err := s.db.Where("key = ?", filter.ReportKey).First(&report).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, classErrorf(ErrNotFound, "report %s not found", filter.ReportKey)
+			}
+			return 0, fmt.Errorf("failed to load report: %w", err)
+		}
+		// A report's traces are those of its runs and of the scope versions
+		// its SQL was generated from
+		query = query.Where("run_id IN (?) OR scope_version_id IN (?)",
+			s.db.Model(&store.ReportRun{}).Select("id").Where("report_id = ?", report.ID),
+			s.db.Model(&store.ReportVersion{}).Select("scope_version_id").Where("report_id = ? AND scope_version_id IS NOT NULL", report.ID))
+	}
+
+	encoder := json.NewEncoder(w)
+	written := 0
+	var batch []store.AITrace
+	err := query.FindInBatches(&batch, traceExportBatch, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if filter.Redact {
+				for _, redactor := range s.traceRedactors {
+					redactor(&batch[i])
+				}
+			}
+			if err := encoder.Encode(&batch[i]); err != nil {
+				return err
+			}
+			written++
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return written, fmt.Errorf("failed to export AI traces: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "AI traces exported", map[string]interface{}{
+		"traces":   written,
+		"user":     filter.User,
+		"report":   filter.ReportKey,
+		"redacted": filter.Redact,
+	})
+	return written, nil
+}
+
+// redactTracePII is the default trace redactor: it masks emails, phone
+// numbers and national IDs in the prompt, messages, response and error
+func redactTracePII(trace *store.AITrace) {
+	for _, field := range []*string{&trace.MessagesJSON, &trace.Prompt, &trace.Response, &trace.Error} {
+		*field = maskPII(*field)
+	}
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrColumnAnnotationNotFound is returned when deleting an unknown annotation
+var ErrColumnAnnotationNotFound = classErrorf(ErrNotFound, "column annotation not found")
+
+// learnedUnits maps trailing column-name tokens to units, e.g. energy_kwh -> kWh
+var learnedUnits = map[string]string{
+	"wh":      "Wh",
+	"kwh":     "kWh",
+	"mwh":     "MWh",
+	"kw":      "kW",
+	"mw":      "MW",
+	"kva":     "kVA",
+	"kvar":    "kvar",
+	"kvarh":   "kvarh",
+	"volts":   "V",
+	"amps":    "A",
+	"hz":      "Hz",
+	"degc":    "°C",
+	"celsius": "°C",
+	"degf":    "°F",
+	"pct":     "%",
+	"percent": "%",
+	"ppm":     "ppm",
+	"pa":      "Pa",
+	"kpa":     "kPa",
+	"lps":     "L/s",
+	"m3":      "m³",
+}
+
+// ListColumnAnnotations returns a datasource's column annotations
+func (s *DatasourceService) ListColumnAnnotations(datasourceID string) ([]store.ColumnAnnotation, error) {
+	var annotations []store.ColumnAnnotation
+	if err := s.db.Where("datasource_id = ?", datasourceID).Order("object, column_name").Find(&annotations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list column annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+// UpsertColumnAnnotations sets user annotations, replacing any existing
+// annotation (learned or not) for the same column
+func (s *DatasourceService) UpsertColumnAnnotations(datasourceID string, reqs []store.ColumnAnnotationRequest) ([]store.ColumnAnnotation, error) {
+	now := time.Now()
+	annotations := make([]store.ColumnAnnotation, 0, len(reqs))
+	for _, req := range reqs {
+		annotations = append(annotations, store.ColumnAnnotation{
+			DatasourceID: datasourceID,
+			Object:       req.Object,
+			ColumnName:   req.Column,
+			Unit:         strings.TrimSpace(req.Unit),
+			DisplayName:  strings.TrimSpace(req.DisplayName),
+			Description:  strings.TrimSpace(req.Description),
+			Source:       "user",
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		})
+	}
+	if len(annotations) == 0 {
+		return annotations, nil
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "datasource_id"}, {Name: "object"}, {Name: "column_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"unit", "display_name", "description", "source", "updated_at"}),
+	}).Create(&annotations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save column annotations: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Column annotations updated", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"count":         len(annotations),
+	})
+	return s.ListColumnAnnotations(datasourceID)
+}
+
+// DeleteColumnAnnotation removes one annotation from a datasource
+func (s *DatasourceService) DeleteColumnAnnotation(datasourceID string, id uint) error {
+	res := s.db.Where("datasource_id = ? AND id = ?", datasourceID, id).Delete(&store.ColumnAnnotation{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete column annotation: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrColumnAnnotationNotFound
+	}
+	return nil
+}
+
+// inferColumnAnnotations guesses units from column names such as "energy_kwh"
+// or "supply_temp_degc"; anything ambiguous is left for the user
+func inferColumnAnnotations(datasourceID, table string, columns []ColumnInfo) []store.ColumnAnnotation {
+	var inferred []store.ColumnAnnotation
+	for _, col := range columns {
+		tokens := strings.Split(strings.ToLower(col.Name), "_")
+		if len(tokens) < 2 {
+			continue
+		}
+		unit := learnedUnits[tokens[len(tokens)-1]]
+		if unit == "" && tokens[len(tokens)-1] == "c" && strings.Contains(col.Name, "temp") {
+			unit = "°C"
+		}
+		if unit == "" {
+			continue
+		}
+		inferred = append(inferred, store.ColumnAnnotation{
+			DatasourceID: datasourceID,
+			Object:       table,
+			ColumnName:   col.Name,
+			Unit:         unit,
+			Source:       "learn",
+		})
+	}
+	return inferred
+}
+
+// saveLearnedAnnotations stores inferred annotations without touching columns
+// a user has already annotated
+func (s *DatasourceService) saveLearnedAnnotations(annotations []store.ColumnAnnotation) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range annotations {
+		annotations[i].CreatedAt = now
+		annotations[i].UpdatedAt = now
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "datasource_id"}, {Name: "object"}, {Name: "column_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"unit", "updated_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: "column_annotations", Name: "source"}, Value: "learn"}}},
+	}).Create(&annotations).Error
+}
+
+// columnAnnotationsMarkdown renders annotations as prompt context
+func columnAnnotationsMarkdown(annotations []store.ColumnAnnotation) string {
+	if len(annotations) == 0 {
+		return ""
+	}
+	var md strings.Builder
+	md.WriteString("Column annotations (interpret raw values in these units and label results with them):\n")
+	for _, a := range annotations {
+		var parts []string
+		if a.Unit != "" {
+			parts = append(parts, "unit: "+a.Unit)
+		}
+		if a.DisplayName != "" {
+			parts = append(parts, fmt.Sprintf("display name: %q", a.DisplayName))
+		}
+		if a.Description != "" {
+			parts = append(parts, a.Description)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		md.WriteString(fmt.Sprintf("- %s.%s: %s\n", a.Object, a.ColumnName, strings.Join(parts, "; ")))
+	}
+	return md.String()
+}
+
+// annotationsFor loads a datasource's annotations for prompt building; lookup
+// failures only cost context, so they are logged and ignored
+func annotationsFor(db *gorm.DB, datasourceID string) []store.ColumnAnnotation {
+	var annotations []store.ColumnAnnotation
+	if err := db.Where("datasource_id = ?", datasourceID).Order("object, column_name").Find(&annotations).Error; err != nil {
+		logger.LogWarn(logger.ServiceDB, "Failed to load column annotations", map[string]interface{}{
+			"datasource_id": datasourceID,
+			"error":         err.Error(),
+		})
+		return nil
+	}
+	return annotations
+}
+
+// resultColumnAnnotations matches result columns of a run to annotations by
+// column name, preferring objects referenced in the SQL when names collide
+func resultColumnAnnotations(db *gorm.DB, datasourceID, sqlText, results string) []store.ColumnAnnotation {
+	set, err := ParseRunResults(results)
+	if err != nil || len(set.Columns) == 0 {
+		return nil
+	}
+	inResults := make(map[string]bool, len(set.Columns))
+	for _, column := range set.Columns {
+		inResults[column.Name] = true
+	}
+
+	byColumn := map[string]store.ColumnAnnotation{}
+	lowerSQL := strings.ToLower(sqlText)
+	for _, a := range annotationsFor(db, datasourceID) {
+		if !inResults[a.ColumnName] {
+			continue
+		}
+		if _, ok := byColumn[a.ColumnName]; ok && !strings.Contains(lowerSQL, strings.ToLower(a.Object)) {
+			continue
+		}
+		byColumn[a.ColumnName] = a
+	}
+
+	columns := make([]store.ColumnAnnotation, 0, len(byColumn))
+	for _, a := range byColumn {
+		columns = append(columns, a)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].ColumnName < columns[j].ColumnName })
+	return columns
+}
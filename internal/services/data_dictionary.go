@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+const (
+	// dataDictionaryMaxTables caps the tables described in one generation
+	dataDictionaryMaxTables = 50
+	// dataDictionarySampleRows is how many rows sample values are taken from
+	dataDictionarySampleRows = 20
+	// dataDictionarySampleValues is how many distinct values are shown per column
+	dataDictionarySampleValues = 3
+	// dataDictionaryPrompt asks for the Markdown layout users edit afterwards
+	dataDictionaryPrompt = `You are a data documentation expert. Write a data dictionary in Markdown for the database tables below, for business users.
+For each table write a "## <table>" heading, one or two sentences on what the table holds, then a Markdown table "| Column | Type | Description |" with a plain-language description of every column. Use the sample values to infer meaning, units and codes.
+End with a "## Relationships" section listing likely joins, one per line, as "table.column → table.column" with a short reason.
+Only describe the tables and columns given. Respond with the Markdown only.`
+)
+
+// ErrDataDictionaryNotFound is returned when a datasource has no data
+// dictionary, or not the version asked for
+var ErrDataDictionaryNotFound = classErrorf(ErrNotFound, "data dictionary not found")
+
+// GenerateDataDictionary has the chat model describe a datasource's learned
+// tables, columns and relationships, using sample values from the live
+// datasource when it is connected, and saves the result as a new version
+func (s *AIService) GenerateDataDictionary(ctx context.Context, datasourceID string, req store.GenerateDataDictionaryRequest) (*store.DataDictionary, error) {
+	connector, err := s.registry.GetDatasource(datasourceID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+
+	notes, err := latestSchemaNotes(s.db, datasourceID, req.Tables)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, classErrorf(ErrValidation, "datasource %s has no learned schema; learn it first", datasourceID)
+	}
+	if len(notes) > dataDictionaryMaxTables {
+		return nil, classErrorf(ErrValidation, "datasource %s has %d learned tables; pass at most %d in tables", datasourceID, len(notes), dataDictionaryMaxTables)
+	}
+
+	pii := maskedPIIColumns(s.db, datasourceID)
+	var content strings.Builder
+	for _, note := range notes {
+		content.WriteString(schemaNoteText(note))
+		if samples := sampleColumnValues(ctx, connector, note.Object, piiKindsByColumn(pii, note.Object)); len(samples) > 0 {
+			content.WriteString("\nSample values:\n")
+			for _, line := range samples {
+				content.WriteString("- " + line + "\n")
+			}
+		}
+		content.WriteString("\n")
+	}
+	if annotations := columnAnnotationsMarkdown(annotationsFor(s.db, datasourceID)); annotations != "" {
+		content.WriteString(annotations + "\n")
+	}
+	if masked := piiMarkdown(pii); masked != "" {
+		content.WriteString(masked + "\n")
+	}
+	if glossary := glossaryMarkdown(glossaryFor(s.db, datasourceID)); glossary != "" {
+		content.WriteString(glossary)
+	}
+
+	ctx, cancel := s.operationContext(ctx, opAnalyze)
+	defer cancel()
+
+	model := llm.GetModelName(s.Config, "chat")
+	resp, err := s.tracedChat(ctx, "data_dictionary", traceLink{DatasourceID: datasourceID}, llm.ChatRequest{
+		Model: model,
+		Messages: []llm.Message{
+			{Role: "system", Content: dataDictionaryPrompt},
+			{Role: "user", Content: fmt.Sprintf("Database: %s (%s)\n\n%s", datasourceID, connector.Kind, content.String())},
+		},
+		Stream:  false,
+		Options: s.samplingOptions(0.2, 0.9),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data dictionary generation failed: %w", s.aiCallError(ctx, opAnalyze, err))
+	}
+
+	// The same fence stripping as JSON answers; models often wrap Markdown too
+	md := string(sanitizeModelJSONOutput(resp.Message.Content))
+	if md == "" {
+		return nil, classErrorf(ErrValidation, "model returned an empty data dictionary")
+	}
+	return s.datasourceService.saveDataDictionary(datasourceID, md, "generated", model)
+}
+
+// GetDataDictionary returns one version of a datasource's data dictionary,
+// or the latest when version is 0
+func (s *DatasourceService) GetDataDictionary(datasourceID string, version int) (*store.DataDictionary, error) {
+	query := s.db.Where("datasource_id = ?", datasourceID)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+
+	var dictionary store.DataDictionary
+	if err := query.Order("version DESC").First(&dictionary).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataDictionaryNotFound
+		}
+		return nil, fmt.Errorf("failed to load data dictionary: %w", err)
+	}
+	return &dictionary, nil
+}
+
+// dataDictionaryList is the sort and filter fields of ListDataDictionaryVersions
+var dataDictionaryList = ListSpec{
+	Table: "data_dictionaries",
+	Sorts: map[string]string{
+		"version":    "version",
+		"created_at": "created_at",
+	},
+	Filters: map[string]string{
+		"source": "source",
+	},
+	DefaultSort: "-version",
+}
+
+// ListDataDictionaryVersions lists a datasource's data dictionary versions,
+// newest first by default
+func (s *DatasourceService) ListDataDictionaryVersions(datasourceID string, opts store.ListOptions) ([]store.DataDictionary, *store.PageInfo, error) {
+	var versions []store.DataDictionary
+	page, err := ListPage(s.db.Where("datasource_id = ?", datasourceID), dataDictionaryList, opts, &versions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list data dictionary versions: %w", err)
+	}
+	return versions, page, nil
+}
+
+// UpdateDataDictionary saves an edited data dictionary as a new user version
+func (s *DatasourceService) UpdateDataDictionary(datasourceID, md string) (*store.DataDictionary, error) {
+	if _, err := s.registry.GetDatasource(datasourceID); err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	md = strings.TrimSpace(md)
+	if md == "" {
+		return nil, classErrorf(ErrValidation, "data dictionary markdown is required")
+	}
+	return s.saveDataDictionary(datasourceID, md, "user", "")
+}
+
+// saveDataDictionary adds the next version of a datasource's data dictionary
+func (s *DatasourceService) saveDataDictionary(datasourceID, md, source, model string) (*store.DataDictionary, error) {
+	dictionary := &store.DataDictionary{
+		DatasourceID: datasourceID,
+		MD:           md,
+		Source:       source,
+		Model:        model,
+		CreatedAt:    time.Now(),
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&store.DataDictionary{}).
+			Where("datasource_id = ?", datasourceID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		dictionary.Version = latest + 1
+		return tx.Create(dictionary).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save data dictionary: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Data dictionary saved", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"version":       dictionary.Version,
+		"source":        source,
+	})
+	return dictionary, nil
+}
+
+// latestSchemaNotes returns the newest note of each learned object, by
+// object name. Learning appends notes, so older runs are skipped. Tables
+// limits the objects, and may name hidden ones; naming one that was never
+// learned is an error.
+func latestSchemaNotes(db *gorm.DB, datasourceID string, tables []string) ([]store.SchemaNote, error) {
+	var notes []store.SchemaNote
+	if err := db.Where("datasource_id = ?", datasourceID).Order("id DESC").Find(&notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve schema notes: %w", err)
+	}
+
+	latest := make(map[string]store.SchemaNote)
+	for _, note := range notes {
+		if _, seen := latest[note.Object]; !seen {
+			latest[note.Object] = note
+		}
+	}
+
+	objects := tables
+	if len(objects) == 0 {
+		for object, note := range latest {
+			if !note.Hidden {
+				objects = append(objects, object)
+			}
+		}
+	}
+	sort.Strings(objects)
+
+	result := make([]store.SchemaNote, 0, len(objects))
+	for _, object := range objects {
+		note, ok := latest[object]
+		if !ok {
+			return nil, classErrorf(ErrValidation, "table %s has not been learned", object)
+		}
+		result = append(result, note)
+	}
+	return result, nil
+}
+
+// sampleColumnValues reads a few rows of a table and lists up to
+// dataDictionarySampleValues distinct values per column as "column: a, b".
+// Columns in pii are listed by kind only, never with values. Samples are
+// optional context, so an unreachable datasource gives none.
+func sampleColumnValues(ctx context.Context, connector *datasource.DatasourceConnector, table string, pii map[string]string) []string {
+	if connector.Connected() != nil {
+		return nil
+	}
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = connector.Quote(part)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	query := fmt.Sprintf("SELECT * FROM %s LIMIT %d", strings.Join(parts, "."), dataDictionarySampleRows)
+	rows, err := connector.DB.QueryContext(ctx, queryComment{User: auth.Username(ctx), Job: "data_dictionary"}.apply(query))
+	if err != nil {
+		logger.LogWarn(logger.ServiceAI, "Failed to sample table for data dictionary", map[string]interface{}{
+			"datasource_id": connector.ID,
+			"table":         table,
+			"error":         err.Error(),
+		})
+		return nil
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil
+	}
+	values := make([][]string, len(columns))
+	for rows.Next() {
+		raw := make([]sql.NullString, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range raw {
+			pointers[i] = &raw[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil
+		}
+		for i, value := range raw {
+			text := strings.TrimSpace(value.String)
+			if !value.Valid || text == "" || len(values[i]) == dataDictionarySampleValues || slices.Contains(values[i], text) {
+				continue
+			}
+			if len(text) > 60 {
+				text = text[:60] + "…"
+			}
+			values[i] = append(values[i], text)
+		}
+	}
+
+	var lines []string
+	for i, column := range columns {
+		if kind, ok := pii[strings.ToLower(column)]; ok {
+			lines = append(lines, fmt.Sprintf("%s: [%s, masked]", column, kind))
+		} else if len(values[i]) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %s", column, strings.Join(values[i], ", ")))
+		}
+	}
+	return lines
+}
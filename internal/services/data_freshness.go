@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// freshnessQueryTimeout bounds the MAX(timestamp) query of one monitor
+const freshnessQueryTimeout = 30 * time.Second
+
+// freshnessTimeLayouts parse timestamps drivers return as text, such as
+// SQLite's, most specific first
+var freshnessTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// ListFreshnessMonitors returns a datasource's freshness monitors
+func (s *DatasourceService) ListFreshnessMonitors(datasourceID string) ([]store.FreshnessMonitor, error) {
+	var monitors []store.FreshnessMonitor
+	if err := s.db.Where("datasource_id = ?", datasourceID).Order("source_table, time_column").Find(&monitors).Error; err != nil {
+		return nil, fmt.Errorf("failed to list freshness monitors: %w", err)
+	}
+	return monitors, nil
+}
+
+// CreateFreshnessMonitor watches a table's newest timestamp and checks it
+// right away so the monitor starts with a status
+func (s *DatasourceService) CreateFreshnessMonitor(datasourceID string, req store.CreateFreshnessMonitorRequest) (*store.FreshnessMonitor, error) {
+	if _, err := s.registry.GetDatasource(datasourceID); err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	maxAge, err := time.ParseDuration(req.MaxAge)
+	if err != nil {
+		return nil, classErrorf(ErrValidation, "invalid max_age: %w", err)
+	}
+	if maxAge <= 0 {
+		return nil, classErrorf(ErrValidation, "max_age must be positive")
+	}
+
+	monitor := &store.FreshnessMonitor{
+		DatasourceID: datasourceID,
+		SourceTable:  strings.TrimSpace(req.Table),
+		TimeColumn:   strings.TrimSpace(req.Column),
+		MaxAge:       maxAge.String(),
+		Status:       "pending",
+	}
+	if err := s.db.Create(monitor).Error; err != nil {
+		return nil, fmt.Errorf("failed to save freshness monitor: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Freshness monitor created", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"table":         monitor.SourceTable,
+		"column":        monitor.TimeColumn,
+		"max_age":       monitor.MaxAge,
+	})
+
+	s.checkFreshness(monitor)
+	return monitor, nil
+}
+
+// DeleteFreshnessMonitor stops watching a table
+func (s *DatasourceService) DeleteFreshnessMonitor(datasourceID string, id uint) error {
+	res := s.db.Where("datasource_id = ? AND id = ?", datasourceID, id).Delete(&store.FreshnessMonitor{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete freshness monitor: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return classErrorf(ErrNotFound, "freshness monitor not found")
+	}
+	return nil
+}
+
+// StartFreshnessMonitor checks every freshness monitor on interval. Checks
+// run under a job lock so only one replica queries the datasources.
+func (s *DatasourceService) StartFreshnessMonitor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Freshness monitor started", map[string]interface{}{
+		"poll_interval": interval.String(),
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			err := withJobLock(context.Background(), s.locks, "freshness", func(context.Context, int64) error {
+				s.checkAllFreshness()
+				return nil
+			})
+			if err != nil && !errors.Is(err, redis.ErrLockHeld) {
+				logger.LogError(logger.ServiceDB, "Freshness check failed", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// checkAllFreshness checks every monitor one at a time
+func (s *DatasourceService) checkAllFreshness() {
+	var monitors []store.FreshnessMonitor
+	if err := s.db.Order("id ASC").Find(&monitors).Error; err != nil {
+		logger.LogError(logger.ServiceDB, "Failed to load freshness monitors", err)
+		return
+	}
+	for i := range monitors {
+		s.checkFreshness(&monitors[i])
+	}
+}
+
+// checkFreshness reads the monitor's newest timestamp and stores the result.
+// When the table turns stale, data.stale is emitted once with the reports
+// reading the table; it is emitted again only after the table was fresh.
+func (s *DatasourceService) checkFreshness(monitor *store.FreshnessMonitor) {
+	now := time.Now()
+	latest, err := s.latestTimestamp(monitor)
+	maxAge, _ := time.ParseDuration(monitor.MaxAge)
+
+	wasStale := monitor.StaleSince != nil
+	monitor.CheckedAt = &now
+	monitor.ErrorText = ""
+	switch {
+	case err != nil:
+		// Staleness is kept as it was, so a flaky datasource doesn't re-alert
+		monitor.Status = "error"
+		monitor.ErrorText = err.Error()
+	case latest == nil || now.Sub(*latest) > maxAge:
+		monitor.Status = "stale"
+		monitor.LatestAt = latest
+		if monitor.StaleSince == nil {
+			monitor.StaleSince = &now
+		}
+	default:
+		monitor.Status = "fresh"
+		monitor.LatestAt = latest
+		monitor.StaleSince = nil
+	}
+	saveErr := s.db.Model(monitor).Select("status", "latest_at", "checked_at", "stale_since", "error_text").Updates(monitor).Error
+	if saveErr != nil {
+		logger.LogError(logger.ServiceDB, "Failed to save freshness check", saveErr, map[string]interface{}{
+			"datasource_id": monitor.DatasourceID,
+			"table":         monitor.SourceTable,
+		})
+		return
+	}
+	if err != nil {
+		logger.LogWarn(logger.ServiceDB, "Freshness check failed", map[string]interface{}{
+			"datasource_id": monitor.DatasourceID,
+			"table":         monitor.SourceTable,
+			"error":         err.Error(),
+		})
+		return
+	}
+	if monitor.Status != "stale" || wasStale {
+		return
+	}
+
+	reports, err := findLineage(s.db, monitor.DatasourceID, monitor.SourceTable, nil, 0)
+	if err != nil {
+		logger.LogError(logger.ServiceDB, "Failed to find reports reading stale table", err, map[string]interface{}{
+			"datasource_id": monitor.DatasourceID,
+			"table":         monitor.SourceTable,
+		})
+	}
+	keys := []string{}
+	for _, report := range reports {
+		keys = appendUnique(keys, report.Key)
+	}
+	sort.Strings(keys)
+
+	logger.LogWarn(logger.ServiceDB, "Table data is stale", map[string]interface{}{
+		"datasource_id": monitor.DatasourceID,
+		"table":         monitor.SourceTable,
+		"latest_at":     monitor.LatestAt,
+		"max_age":       monitor.MaxAge,
+	})
+	s.webhooks.Emit(EventDataStale, map[string]interface{}{
+		"datasource_id": monitor.DatasourceID,
+		"table":         monitor.SourceTable,
+		"column":        monitor.TimeColumn,
+		"max_age":       monitor.MaxAge,
+		"latest_at":     monitor.LatestAt,
+		"reports":       keys,
+	})
+}
+
+// latestTimestamp queries MAX of the monitor's column; nil means the table is empty
+func (s *DatasourceService) latestTimestamp(monitor *store.FreshnessMonitor) (*time.Time, error) {
+	connector, err := s.registry.GetDatasource(monitor.DatasourceID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	if err := connector.Connected(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), freshnessQueryTimeout)
+	defer cancel()
+
+	query := fmt.Sprintf("SELECT MAX(%s) FROM %s", connector.Quote(monitor.TimeColumn), quoteTableName(connector, monitor.SourceTable))
+	var value interface{}
+	if err := connector.DB.QueryRowContext(ctx, queryComment{Job: "freshness"}.apply(query)).Scan(&value); err != nil {
+		return nil, fmt.Errorf("freshness query failed: %w", err)
+	}
+	return parseFreshnessTime(value)
+}
+
+// quoteTableName quotes each part of a possibly schema-qualified table name
+func quoteTableName(connector *datasource.DatasourceConnector, table string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = connector.Quote(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// parseFreshnessTime converts a scanned MAX value to a time. Text without an
+// offset is taken as UTC; integers are Unix seconds.
+func parseFreshnessTime(value interface{}) (*time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case time.Time:
+		return &v, nil
+	case int64:
+		t := time.Unix(v, 0)
+		return &t, nil
+	case []byte:
+		return parseFreshnessTime(string(v))
+	case string:
+		for _, layout := range freshnessTimeLayouts {
+			if t, err := time.ParseInLocation(layout, v, time.UTC); err == nil {
+				return &t, nil
+			}
+		}
+		return nil, fmt.Errorf("column value %q is not a timestamp", v)
+	default:
+		return nil, fmt.Errorf("column value of type %T is not a timestamp", value)
+	}
+}
+
+// dataFreshness reports whether any table a report version reads is stale by
+// its freshness monitor, and the newest row of the stalest such table
+func dataFreshness(db *gorm.DB, reportVersionID uint, datasourceID string) (bool, *time.Time) {
+	var monitors []store.FreshnessMonitor
+	if err := db.Where("datasource_id = ? AND stale_since IS NOT NULL", datasourceID).Find(&monitors).Error; err != nil || len(monitors) == 0 {
+		return false, nil
+	}
+	var tables []string
+	if err := db.Model(&store.LineageEdge{}).Where("report_version_id = ?", reportVersionID).
+		Distinct().Pluck("source_table", &tables).Error; err != nil {
+		return false, nil
+	}
+
+	stale := false
+	var asOf *time.Time
+	for _, monitor := range monitors {
+		table := strings.ToLower(monitor.SourceTable)
+		for _, read := range tables {
+			if read != table && lastSegment(read) != lastSegment(table) {
+				continue
+			}
+			stale = true
+			if monitor.LatestAt != nil && (asOf == nil || monitor.LatestAt.Before(*asOf)) {
+				asOf = monitor.LatestAt
+			}
+			break
+		}
+	}
+	return stale, asOf
+}
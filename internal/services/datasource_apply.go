@@ -0,0 +1,120 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// ApplyDatasources makes the registered datasources match a declared list:
+// missing ones are created, differing ones updated and, with Prune, unlisted
+// ones removed. Each change is made on its own, so one failure does not stop
+// the rest; failures are reported per datasource.
+func (s *DatasourceService) ApplyDatasources(req store.ApplyDatasourcesRequest) (*store.ApplyDatasourcesResponse, error) {
+	declared := make(map[string]bool, len(req.Datasources))
+	defaults := 0
+	for _, ds := range req.Datasources {
+		if declared[ds.ID] {
+			return nil, classErrorf(ErrValidation, "datasource %s is declared twice", ds.ID)
+		}
+		declared[ds.ID] = true
+		if err := validateDatasourceKind(ds.Kind); err != nil {
+			return nil, fmt.Errorf("datasource %s: %w", ds.ID, err)
+		}
+		if ds.IsDefault {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return nil, classErrorf(ErrValidation, "only one datasource may be the default, %d are", defaults)
+	}
+
+	var records []store.Datasource
+	if err := s.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load datasources: %w", err)
+	}
+	existing := make(map[string]store.Datasource, len(records))
+	for _, record := range records {
+		existing[record.ID] = record
+	}
+
+	resp := &store.ApplyDatasourcesResponse{DryRun: req.DryRun, Changes: []store.DatasourceChange{}}
+	record := func(change store.DatasourceChange, err error) {
+		if err != nil {
+			change.Error = err.Error()
+			resp.Failed++
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+
+	for _, ds := range req.Datasources {
+		current, ok := existing[ds.ID]
+		if !ok {
+			change := store.DatasourceChange{ID: ds.ID, Action: "create"}
+			if req.DryRun {
+				record(change, nil)
+				continue
+			}
+			record(change, s.registry.AddDatasource(ds.ID, ds.Kind, ds.DSN, ds.DisplayName, ds.IsDefault))
+			continue
+		}
+
+		fields := changedDatasourceFields(current, ds)
+		if len(fields) == 0 {
+			record(store.DatasourceChange{ID: ds.ID, Action: "unchanged"}, nil)
+			continue
+		}
+		change := store.DatasourceChange{ID: ds.ID, Action: "update", Fields: fields}
+		if req.DryRun {
+			record(change, nil)
+			continue
+		}
+		record(change, s.registry.UpdateDatasource(ds.ID, ds.Kind, ds.DSN, ds.DisplayName, ds.IsDefault))
+	}
+
+	if req.Prune {
+		// Removed last so the default can move to a declared datasource first
+		sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+		for _, current := range records {
+			if declared[current.ID] {
+				continue
+			}
+			change := store.DatasourceChange{ID: current.ID, Action: "delete"}
+			if req.DryRun {
+				record(change, nil)
+				continue
+			}
+			record(change, s.registry.RemoveDatasource(current.ID))
+		}
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Datasources applied", map[string]interface{}{
+		"declared": len(req.Datasources),
+		"changes":  len(resp.Changes),
+		"failed":   resp.Failed,
+		"dry_run":  req.DryRun,
+		"prune":    req.Prune,
+	})
+	return resp, nil
+}
+
+// changedDatasourceFields names the settings a declaration changes. The DSN
+// is compared but never echoed, since it may hold credentials.
+func changedDatasourceFields(current store.Datasource, declared store.CreateDatasourceRequest) []string {
+	var fields []string
+	if current.Kind != declared.Kind {
+		fields = append(fields, "kind")
+	}
+	if current.DSN != declared.DSN {
+		fields = append(fields, "dsn")
+	}
+	if current.DisplayName != declared.DisplayName {
+		fields = append(fields, "display_name")
+	}
+	if current.IsDefault != declared.IsDefault {
+		fields = append(fields, "is_default")
+	}
+	return fields
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+const (
+	queryTestDefaultLimit   = 20
+	queryTestMaxLimit       = 100
+	queryTestDefaultTimeout = 10 * time.Second
+	queryTestMaxTimeout     = 30 * time.Second
+)
+
+// QueryTest runs a read-only statement against a datasource to debug
+// connectivity and permissions. The statement is wrapped so at most limit
+// rows come back, and it is cancelled when the timeout runs out.
+func (s *DatasourceService) QueryTest(ctx context.Context, id, user string, req store.QueryTestRequest) (*store.QueryTestResponse, error) {
+	connector, err := s.registry.GetDatasource(id)
+	if err != nil {
+		return nil, fmt.Errorf("datasource not found: %w", err)
+	}
+	if err := connector.Connected(); err != nil {
+		return nil, err
+	}
+
+	if _, err := ValidateReadOnlySQL(req.SQL); err != nil {
+		return nil, err
+	}
+	if names := extractSQLPlaceholders(req.SQL); len(names) > 0 {
+		return nil, classErrorf(ErrValidation, "query tests take literal SQL; replace the placeholders %s", strings.Join(names, ", "))
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = queryTestDefaultLimit
+	}
+	if limit > queryTestMaxLimit {
+		return nil, classErrorf(ErrValidation, "limit must be at most %d", queryTestMaxLimit)
+	}
+	timeout := queryTestDefaultTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	if timeout > queryTestMaxTimeout {
+		return nil, classErrorf(ErrValidation, "timeout_seconds must be at most %d", int(queryTestMaxTimeout.Seconds()))
+	}
+
+	// One row over the limit tells whether the result was cut short
+	statement := trimStatementEnd(req.SQL)
+	query := fmt.Sprintf("SELECT * FROM (%s) AS query_test LIMIT %d", statement, limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	rows, err := connector.DB.QueryContext(ctx, queryComment{User: user}.apply(query))
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, classErrorf(ErrValidation, "query test timed out after %s", timeout)
+		}
+		return nil, classErrorf(ErrValidation, "query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	resp := &store.QueryTestResponse{
+		DatasourceID: id,
+		SQL:          fmt.Sprintf("SELECT * FROM (%s) AS query_test LIMIT %d", statement, limit),
+		Columns:      columns,
+		Rows:         []map[string]interface{}{},
+	}
+	values := make([]interface{}, len(columns))
+	scanArgs := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(resp.Rows) == limit {
+			resp.Truncated = true
+			break
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		resp.Rows = append(resp.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, classErrorf(ErrValidation, "query test timed out after %s", timeout)
+		}
+		return nil, classErrorf(ErrValidation, "query failed: %w", err)
+	}
+	resp.RowCount = len(resp.Rows)
+	resp.DurationMS = time.Since(start).Milliseconds()
+
+	logger.LogInfo(logger.ServiceDB, "Query test run", map[string]interface{}{
+		"datasource_id": id,
+		"user":          user,
+		"rows":          resp.RowCount,
+		"duration_ms":   resp.DurationMS,
+	})
+	return resp, nil
+}
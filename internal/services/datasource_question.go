@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+)
+
+const (
+	// datasourceQuestionTimeout bounds the query answering a datasource question
+	datasourceQuestionTimeout = 30 * time.Second
+
+	// datasourceAnswerPrompt asks the chat model to phrase a datasource query result
+	datasourceAnswerPrompt = `You are AIR (AI Reporting Intelligence). A SQL query was run against the user's database to answer their question.
+Answer the question from the query result only. Be specific and concise, and quote the numbers. If the result is empty, say so. Values shown as [email], [phone] or [national_id] are masked personal data.`
+)
+
+// AnswerDatasourceQuestion answers a question about a learned datasource with
+// SQL. The SQL generator writes a read-only query from the schema notes, it
+// runs with the same row cap as file questions, masked PII columns are
+// replaced, and the chat model phrases the result.
+func (s *AIService) AnswerDatasourceQuestion(ctx context.Context, datasourceID, question, user string) (*FileAnswer, error) {
+	connector, err := s.registry.GetDatasource(datasourceID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	if err := connector.Connected(); err != nil {
+		return nil, err
+	}
+	generator, err := s.localSQLGeneratorFor(datasourceID, s.sqlGeneratorFor(datasourceID))
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := s.datasourceService.GetSchema(datasourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema: %w", err)
+	}
+	schema := s.schemaContext(datasourceID, notes, glossaryFor(s.db, datasourceID))
+	if schema == "" {
+		return nil, classErrorf(ErrValidation, "datasource %s has not been learned yet", datasourceID)
+	}
+
+	genCtx, cancel := s.operationContext(ctx, opSQLGenerate)
+	defer cancel()
+	trace := &generationTrace{}
+	start := time.Now()
+	query, err := generator.GenerateSQL(genCtx, SQLGenerationRequest{
+		DatasourceID: datasourceID,
+		Dialect:      connector.Dialect(),
+		Prompt:       question,
+		Schema:       schema,
+		Trace:        trace,
+	})
+	s.recordGeneration(genCtx, trace, traceLink{DatasourceID: datasourceID}, time.Since(start), err)
+	if err != nil {
+		return nil, fmt.Errorf("%s generation failed: %w", generator.Name(), s.aiCallError(genCtx, opSQLGenerate, err))
+	}
+	if _, err := ValidateReadOnlySQL(query); err != nil {
+		return nil, err
+	}
+
+	piiKinds, err := piiKindsForSQL(s.db, datasourceID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCtx, cancelQuery := context.WithTimeout(ctx, datasourceQuestionTimeout)
+	defer cancelQuery()
+	answer := &FileAnswer{SQL: query}
+	answer.Columns, answer.Rows, answer.Truncated, err = queryRows(queryCtx, connector.DB, queryComment{User: user}.apply(query), fileQueryRowLimit)
+	if err != nil {
+		return nil, classErrorf(ErrValidation, "generated query failed: %w", err)
+	}
+	maskRowsPII(piiKinds, answer.Columns, answer.Rows)
+
+	if err := s.phraseQueryAnswer(ctx, "answer_datasource_question", datasourceAnswerPrompt, traceLink{DatasourceID: datasourceID}, question, answer); err != nil {
+		return nil, fmt.Errorf("failed to answer datasource question: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Datasource question answered with SQL", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"sql":           query,
+		"rows":          len(answer.Rows),
+		"truncated":     answer.Truncated,
+	})
+	return answer, nil
+}
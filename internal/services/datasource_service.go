@@ -1,18 +1,18 @@
 package services
 
 import (
+	"context"
 	"crypto/md5"
-	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/NubeDev/air/internal/datasource"
 	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
 	"github.com/NubeDev/air/internal/store"
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
-	_ "github.com/mattn/go-sqlite3"
 	"gorm.io/gorm"
 )
 
@@ -20,13 +20,65 @@ import (
 type DatasourceService struct {
 	registry *datasource.Registry
 	db       *gorm.DB
+	webhooks *WebhookService
+	locks    *redis.Client
+
+	healthMu   sync.Mutex
+	lastHealth map[string]bool // datasource ID -> healthy, for unhealthy transitions
 }
 
 // NewDatasourceService creates a new datasource service
 func NewDatasourceService(registry *datasource.Registry, db *gorm.DB) *DatasourceService {
 	return &DatasourceService{
-		registry: registry,
-		db:       db,
+		registry:   registry,
+		db:         db,
+		lastHealth: make(map[string]bool),
+	}
+}
+
+// SetWebhooks enables lifecycle event delivery for schema drift and health changes
+func (s *DatasourceService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// StartHealthMonitor probes every datasource on interval so datasource.unhealthy
+// is emitted without anyone polling the health endpoint
+func (s *DatasourceService) StartHealthMonitor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for id, result := range s.registry.HealthCheck() {
+				var err error
+				if result != "healthy" {
+					err = fmt.Errorf("%s", strings.TrimPrefix(result, "unhealthy: "))
+				}
+				s.recordHealth(id, err)
+			}
+		}
+	}()
+}
+
+// recordHealth emits datasource.unhealthy when a datasource stops responding.
+// Repeated failures are reported once until the datasource recovers.
+func (s *DatasourceService) recordHealth(id string, healthErr error) {
+	s.healthMu.Lock()
+	wasHealthy, seen := s.lastHealth[id]
+	s.lastHealth[id] = healthErr == nil
+	s.healthMu.Unlock()
+
+	if healthErr != nil && (!seen || wasHealthy) {
+		logger.LogWarn(logger.ServiceDB, "Datasource became unhealthy", map[string]interface{}{
+			"id":    id,
+			"error": healthErr.Error(),
+		})
+		s.webhooks.Emit(EventDatasourceUnhealthy, map[string]interface{}{
+			"datasource_id": id,
+			"error":         healthErr.Error(),
+		})
 	}
 }
 
@@ -45,9 +97,17 @@ func (s *DatasourceService) ListDatasources() ([]store.DatasourceResponse, error
 			Kind:         connector.Kind,
 			DisplayName:  connector.DisplayName,
 			IsDefault:    connector.IsDefault,
+			NeedsReview:  connector.NeedsReview,
+			Sandbox:      connector.Sandbox,
+			NoExternalAI: connector.NoExternalAI,
+			AllowWrites:  connector.AllowWrites,
 			HealthStatus: connector.HealthStatus,
 			LastHealth:   connector.LastHealth,
 		}
+		datasources[i].Circuit, datasources[i].Failures, datasources[i].RetryAt = connector.Circuit()
+		for _, credential := range connector.Credentials {
+			datasources[i].Credentials = append(datasources[i].Credentials, credential.Name)
+		}
 		if connector.Error != nil {
 			datasources[i].Error = connector.Error.Error()
 		}
@@ -73,6 +133,9 @@ func (s *DatasourceService) CreateDatasource(req store.CreateDatasourceRequest)
 		"is_default":   req.IsDefault,
 	})
 
+	if err := validateDatasourceKind(req.Kind); err != nil {
+		return err
+	}
 	err := s.registry.AddDatasource(req.ID, req.Kind, req.DSN, req.DisplayName, req.IsDefault)
 
 	duration := time.Since(start)
@@ -92,6 +155,19 @@ func (s *DatasourceService) CreateDatasource(req store.CreateDatasourceRequest)
 	return nil
 }
 
+// validateDatasourceKind checks that a datasource created through the API is
+// of a registered kind. SQLite sources are local files, so they are only
+// configured in config.yaml.
+func validateDatasourceKind(kind string) error {
+	if strings.EqualFold(kind, "sqlite") {
+		return classErrorf(ErrValidation, "sqlite datasources are configured in config.yaml")
+	}
+	if _, err := datasource.Lookup(kind); err != nil {
+		return classErrorf(ErrValidation, "%v (registered: %s)", err, strings.Join(datasource.Kinds(), ", "))
+	}
+	return nil
+}
+
 // GetDatasourceHealth checks the health of a specific datasource
 func (s *DatasourceService) GetDatasourceHealth(id string) (store.HealthCheckResponse, error) {
 	connector, err := s.registry.GetDatasource(id)
@@ -99,15 +175,21 @@ func (s *DatasourceService) GetDatasourceHealth(id string) (store.HealthCheckRes
 		return store.HealthCheckResponse{}, fmt.Errorf("datasource not found: %w", err)
 	}
 
-	if err := connector.TestConnection(); err != nil {
+	start := time.Now()
+	err = connector.Probe()
+	latency := time.Since(start).Milliseconds()
+	s.recordHealth(id, err)
+	if err != nil {
 		return store.HealthCheckResponse{
-			Status: "unhealthy",
-			Error:  err.Error(),
+			Status:    "unhealthy",
+			LatencyMS: latency,
+			Error:     err.Error(),
 		}, nil
 	}
 
 	return store.HealthCheckResponse{
-		Status: "healthy",
+		Status:    "healthy",
+		LatencyMS: latency,
 	}, nil
 }
 
@@ -116,187 +198,303 @@ func (s *DatasourceService) DeleteDatasource(id string) error {
 	return s.registry.RemoveDatasource(id)
 }
 
-// LearnDatasource learns schema from a datasource
+// SetLocks keeps API replicas from learning the same datasource at once
+func (s *DatasourceService) SetLocks(locks *redis.Client) {
+	s.locks = locks
+}
+
+// LearnDatasource learns schema from a datasource. A learn of the same
+// datasource already running on any replica is reported as a conflict.
 func (s *DatasourceService) LearnDatasource(req store.LearnDatasourceRequest) error {
+	return withJobLock(context.Background(), s.locks, "learn:"+req.DatasourceID, func(context.Context, int64) error {
+		return s.learnDatasource(req)
+	})
+}
+
+func (s *DatasourceService) learnDatasource(req store.LearnDatasourceRequest) error {
 	// Get datasource connector
 	connector, err := s.registry.GetDatasource(req.DatasourceID)
 	if err != nil {
 		return fmt.Errorf("datasource not found: %w", err)
 	}
 
-	// Get DSN from database
-	var datasource store.Datasource
-	if err := s.db.Where("id = ?", req.DatasourceID).First(&datasource).Error; err != nil {
-		return fmt.Errorf("failed to get datasource DSN: %w", err)
+	// Introspect tables and views
+	tables, err := connector.Introspect(context.Background(), req.Schemas)
+	if err != nil {
+		return fmt.Errorf("failed to introspect schema: %w", err)
 	}
+	schemaNotes, annotations := s.schemaNotes(req.DatasourceID, tables)
 
-	// Map connector kind to driver name
-	driverName := connector.Kind
-	if connector.Kind == "sqlite" {
-		driverName = "sqlite3"
+	if err := s.detectSchemaDrift(req.DatasourceID, schemaNotes, len(req.Schemas) == 0); err != nil {
+		logger.LogWarn(logger.ServiceDB, "Schema drift check failed", map[string]interface{}{
+			"datasource_id": req.DatasourceID,
+			"error":         err.Error(),
+		})
 	}
 
-	// Connect to the datasource
-	db, err := sql.Open(driverName, datasource.DSN)
-	if err != nil {
-		return fmt.Errorf("failed to connect to datasource: %w", err)
+	// Store schema notes in database, keeping what users curated
+	carryCuration(s.db, req.DatasourceID, schemaNotes)
+	for _, note := range schemaNotes {
+		if err := s.db.Create(&note).Error; err != nil {
+			// Log error but continue with other notes
+			logger.LogError(logger.ServiceDB, "Failed to store schema note", err)
+		}
 	}
-	defer db.Close()
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("failed to ping datasource: %w", err)
+	if err := s.saveLearnedAnnotations(annotations); err != nil {
+		logger.LogWarn(logger.ServiceDB, "Failed to store learned column annotations", map[string]interface{}{
+			"datasource_id": req.DatasourceID,
+			"error":         err.Error(),
+		})
 	}
 
-	// Introspect tables and views
-	schemaNotes, err := s.introspectSchema(db, req.DatasourceID, connector.Kind, req.Schemas)
-	if err != nil {
-		return fmt.Errorf("failed to introspect schema: %w", err)
+	if err := s.savePIIColumns(detectPII(context.Background(), connector, req.DatasourceID, tables)); err != nil {
+		logger.LogWarn(logger.ServiceDB, "Failed to store PII column tags", map[string]interface{}{
+			"datasource_id": req.DatasourceID,
+			"error":         err.Error(),
+		})
 	}
 
-	// Store schema notes in database
-	for _, note := range schemaNotes {
-		if err := s.db.Create(&note).Error; err != nil {
-			// Log error but continue with other notes
-			logger.LogError(logger.ServiceDB, "Failed to store schema note", err)
+	if connector.Dialect() == "timescaledb" {
+		if err := s.discoverContinuousAggregates(connector.DB, req.DatasourceID); err != nil {
+			logger.LogWarn(logger.ServiceDB, "Continuous aggregate discovery failed", map[string]interface{}{
+				"datasource_id": req.DatasourceID,
+				"error":         err.Error(),
+			})
 		}
 	}
 
 	return nil
 }
 
-// GetSchema returns schema information for a datasource
-func (s *DatasourceService) GetSchema(datasourceID string) ([]store.SchemaNote, error) {
-	var schemaNotes []store.SchemaNote
-	if err := s.db.Where("datasource_id = ?", datasourceID).Find(&schemaNotes).Error; err != nil {
-		return nil, fmt.Errorf("failed to retrieve schema notes: %w", err)
+// detectSchemaDrift compares freshly introspected notes with the last learned
+// schema and emits schema.drift.detected for added, removed or changed objects,
+// naming the reports that read what was removed or changed.
+// Removals are only reported for full learns since a schema filter hides objects.
+func (s *DatasourceService) detectSchemaDrift(datasourceID string, notes []store.SchemaNote, fullLearn bool) error {
+	var previous []store.SchemaNote
+	if err := s.db.Where("datasource_id = ?", datasourceID).Order("id ASC").Find(&previous).Error; err != nil {
+		return err
+	}
+	if len(previous) == 0 {
+		return nil // first learn; nothing to drift from
 	}
-	return schemaNotes, nil
-}
 
-// introspectSchema introspects database schema and returns schema notes
-func (s *DatasourceService) introspectSchema(db *sql.DB, datasourceID, dbKind string, schemas []string) ([]store.SchemaNote, error) {
-	var schemaNotes []store.SchemaNote
+	before := schemaObjectHashes(previous)
+	after := schemaObjectHashes(notes)
+
+	var added, removed, changed []string
+	for object, hash := range after {
+		prev, ok := before[object]
+		switch {
+		case !ok:
+			added = append(added, object)
+		case prev != hash:
+			changed = append(changed, object)
+		}
+	}
+	if fullLearn {
+		for object := range before {
+			if _, ok := after[object]; !ok {
+				removed = append(removed, object)
+			}
+		}
+	}
+	if len(added)+len(removed)+len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
 
-	// Get list of tables and views
-	tables, err := s.getTablesAndViews(db, dbKind, schemas)
+	impacted, dropped, err := s.driftImpact(datasourceID, previous, notes, removed, changed)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get tables and views: %w", err)
+		logger.LogError(logger.ServiceDB, "Failed to find reports impacted by schema drift", err, map[string]interface{}{
+			"datasource_id": datasourceID,
+		})
+	}
+	s.flagStaleReports(datasourceID, impacted, dropped)
+
+	logger.LogInfo(logger.ServiceDB, "Schema drift detected", map[string]interface{}{
+		"datasource_id":    datasourceID,
+		"added":            len(added),
+		"removed":          len(removed),
+		"changed":          len(changed),
+		"impacted_reports": len(impacted),
+	})
+	if len(impacted) > 0 {
+		keys := make([]string, len(impacted))
+		for i, report := range impacted {
+			keys[i] = report.Key
+		}
+		logger.LogWarn(logger.ServiceDB, "Schema drift affects reports", map[string]interface{}{
+			"datasource_id": datasourceID,
+			"reports":       keys,
+		})
 	}
+	s.webhooks.Emit(EventSchemaDriftDetected, map[string]interface{}{
+		"datasource_id":    datasourceID,
+		"added":            added,
+		"removed":          removed,
+		"changed":          changed,
+		"impacted_reports": impacted,
+	})
+	return nil
+}
 
-	// Introspect each table/view
-	for _, table := range tables {
-		columns, err := s.getTableColumns(db, dbKind, table)
+// driftImpact finds the report versions reading a removed table, or a column
+// that a changed table dropped or retyped; their sources list just the
+// affected columns. It also returns what was dropped, keyed by table, with a
+// nil column set for a removed table.
+func (s *DatasourceService) driftImpact(datasourceID string, previous, notes []store.SchemaNote, removed, changed []string) ([]store.LineageReport, map[string]map[string]bool, error) {
+	impacted := make(map[uint]*store.LineageReport)
+	collect := func(table string, columns []string) error {
+		reports, err := findLineage(s.db, datasourceID, table, columns, 0)
 		if err != nil {
-			logger.LogError(logger.ServiceDB, "Failed to get columns for table", err)
-			continue
+			return err
 		}
-
-		// Generate markdown description
-		md := s.generateTableMarkdown(table, columns)
-		mdHash := fmt.Sprintf("%x", md5.Sum([]byte(md)))
-
-		// Create schema note
-		note := store.SchemaNote{
-			DatasourceID: datasourceID,
-			Object:       table,
-			Chunk:        0,
-			MD:           md,
-			MDHash:       mdHash,
-			CreatedAt:    time.Now(),
+		for _, report := range reports {
+			if existing, ok := impacted[report.ReportVersionID]; ok {
+				existing.Sources = append(existing.Sources, report.Sources...)
+				continue
+			}
+			report := report
+			impacted[report.ReportVersionID] = &report
 		}
+		return nil
+	}
 
-		schemaNotes = append(schemaNotes, note)
+	dropped := make(map[string]map[string]bool)
+	for _, table := range removed {
+		dropped[strings.ToLower(table)] = nil
+		if err := collect(table, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	before, after := notesColumns(previous), notesColumns(notes)
+	for _, table := range changed {
+		table = strings.ToLower(table)
+		var columns []string
+		for column, typ := range before[table] {
+			newType, ok := after[table][column]
+			if !ok {
+				if dropped[table] == nil {
+					dropped[table] = make(map[string]bool)
+				}
+				dropped[table][column] = true
+			}
+			if !ok || newType != typ {
+				columns = append(columns, column)
+			}
+		}
+		if len(columns) == 0 {
+			continue // only added columns or changed defaults
+		}
+		sort.Strings(columns)
+		if err := collect(table, columns); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return schemaNotes, nil
+	reports := make([]store.LineageReport, 0, len(impacted))
+	for _, report := range impacted {
+		reports = append(reports, *report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Key != reports[j].Key {
+			return reports[i].Key < reports[j].Key
+		}
+		return reports[i].Version < reports[j].Version
+	})
+	return reports, dropped, nil
 }
 
-// getTablesAndViews returns list of tables and views in the database
-func (s *DatasourceService) getTablesAndViews(db *sql.DB, dbKind string, schemas []string) ([]string, error) {
-	var query string
-	var args []interface{}
-
-	switch strings.ToLower(dbKind) {
-	case "sqlite", "sqlite3":
-		query = "SELECT name FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'"
-	case "postgres", "postgresql", "timescaledb":
-		if len(schemas) == 0 {
-			schemas = []string{"public"}
+// schemaObjectHashes reduces notes to one hash signature per object, keeping the
+// most recent note for each chunk
+func schemaObjectHashes(notes []store.SchemaNote) map[string]string {
+	chunks := make(map[string]map[int]string)
+	for _, note := range notes {
+		if chunks[note.Object] == nil {
+			chunks[note.Object] = make(map[int]string)
 		}
-		placeholders := strings.Repeat("?,", len(schemas))
-		placeholders = placeholders[:len(placeholders)-1]
-		query = fmt.Sprintf("SELECT tablename FROM pg_tables WHERE schemaname IN (%s) UNION SELECT viewname FROM pg_views WHERE schemaname IN (%s)", placeholders, placeholders)
-		for _, schema := range schemas {
-			args = append(args, schema, schema)
-		}
-	case "mysql":
-		query = "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type IN ('BASE TABLE', 'VIEW')"
-	default:
-		return nil, fmt.Errorf("unsupported database kind: %s", dbKind)
+		chunks[note.Object][note.Chunk] = note.MDHash
 	}
 
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query tables: %w", err)
+	hashes := make(map[string]string, len(chunks))
+	for object, byChunk := range chunks {
+		keys := make([]int, 0, len(byChunk))
+		for chunk := range byChunk {
+			keys = append(keys, chunk)
+		}
+		sort.Ints(keys)
+		parts := make([]string, len(keys))
+		for i, chunk := range keys {
+			parts[i] = byChunk[chunk]
+		}
+		hashes[object] = strings.Join(parts, ",")
 	}
-	defer rows.Close()
+	return hashes
+}
 
-	var tables []string
-	for rows.Next() {
-		var table string
-		if err := rows.Scan(&table); err != nil {
-			return nil, fmt.Errorf("failed to scan table name: %w", err)
-		}
-		tables = append(tables, table)
+// GetSchema returns schema information for a datasource
+func (s *DatasourceService) GetSchema(datasourceID string) ([]store.SchemaNote, error) {
+	var schemaNotes []store.SchemaNote
+	if err := s.db.Where("datasource_id = ?", datasourceID).Find(&schemaNotes).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve schema notes: %w", err)
 	}
+	return schemaNotes, nil
+}
 
-	return tables, nil
+// schemaNoteList is the sort and filter fields of ListSchemaNotes
+var schemaNoteList = ListSpec{
+	Table: "schema_notes",
+	Sorts: map[string]string{
+		"object":     "object",
+		"created_at": "created_at",
+	},
+	Filters: map[string]string{
+		"object": "object",
+	},
+	DefaultSort: "object",
 }
 
-// getTableColumns returns column information for a table
-func (s *DatasourceService) getTableColumns(db *sql.DB, dbKind, tableName string) ([]ColumnInfo, error) {
-	var query string
-	var args []interface{}
-
-	switch strings.ToLower(dbKind) {
-	case "sqlite", "sqlite3":
-		query = "PRAGMA table_info(?)"
-		args = []interface{}{tableName}
-	case "postgres", "postgresql", "timescaledb":
-		query = `
-			SELECT column_name, data_type, is_nullable, column_default
-			FROM information_schema.columns 
-			WHERE table_name = $1 
-			ORDER BY ordinal_position`
-		args = []interface{}{tableName}
-	case "mysql":
-		query = `
-			SELECT column_name, data_type, is_nullable, column_default
-			FROM information_schema.columns 
-			WHERE table_name = ? AND table_schema = DATABASE()
-			ORDER BY ordinal_position`
-		args = []interface{}{tableName}
-	default:
-		return nil, fmt.Errorf("unsupported database kind: %s", dbKind)
-	}
-
-	rows, err := db.Query(query, args...)
+// ListSchemaNotes returns one page of a datasource's schema notes, ordered by
+// object by default
+func (s *DatasourceService) ListSchemaNotes(datasourceID string, opts store.ListOptions) ([]store.SchemaNote, *store.PageInfo, error) {
+	var notes []store.SchemaNote
+	page, err := ListPage(s.db.Where("datasource_id = ?", datasourceID), schemaNoteList, opts, &notes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query columns: %w", err)
+		return nil, nil, fmt.Errorf("failed to retrieve schema notes: %w", err)
 	}
-	defer rows.Close()
+	return notes, page, nil
+}
+
+// schemaNotes builds a schema note and inferred column annotations for each
+// introspected table or view
+func (s *DatasourceService) schemaNotes(datasourceID string, tables []datasource.Table) ([]store.SchemaNote, []store.ColumnAnnotation) {
+	var schemaNotes []store.SchemaNote
+	var annotations []store.ColumnAnnotation
+
+	for _, table := range tables {
+		// Generate markdown description
+		md := s.generateTableMarkdown(table.Name, table.Columns)
+		mdHash := fmt.Sprintf("%x", md5.Sum([]byte(md)))
 
-	var columns []ColumnInfo
-	for rows.Next() {
-		var col ColumnInfo
-		if err := rows.Scan(&col.Name, &col.Type, &col.Nullable, &col.Default); err != nil {
-			return nil, fmt.Errorf("failed to scan column: %w", err)
+		// Create schema note
+		note := store.SchemaNote{
+			DatasourceID: datasourceID,
+			Object:       table.Name,
+			Chunk:        0,
+			MD:           md,
+			MDHash:       mdHash,
+			CreatedAt:    time.Now(),
 		}
-		columns = append(columns, col)
+
+		schemaNotes = append(schemaNotes, note)
+		annotations = append(annotations, inferColumnAnnotations(datasourceID, table.Name, table.Columns)...)
 	}
 
-	return columns, nil
+	return schemaNotes, annotations
 }
 
 // generateTableMarkdown generates markdown description of a table
@@ -328,9 +526,4 @@ func (s *DatasourceService) generateTableMarkdown(tableName string, columns []Co
 }
 
 // ColumnInfo represents column information
-type ColumnInfo struct {
-	Name     string
-	Type     string
-	Nullable string
-	Default  string
-}
+type ColumnInfo = datasource.Column
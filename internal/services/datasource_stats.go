@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// GetDatasourceStats gathers a datasource's table, view and size figures,
+// what AIR has learned and run against it, and its connection pool state.
+// A datasource that cannot be reached still gets the figures AIR holds, with
+// the reason in Error.
+func (s *DatasourceService) GetDatasourceStats(ctx context.Context, id string) (*store.DatasourceStatsResponse, error) {
+	connector, err := s.registry.GetDatasource(id)
+	if err != nil {
+		return nil, fmt.Errorf("datasource not found: %w", err)
+	}
+
+	stats := &store.DatasourceStatsResponse{
+		DatasourceID: id,
+		Kind:         connector.Kind,
+		HealthStatus: connector.HealthStatus,
+		LastHealth:   connector.LastHealth,
+	}
+
+	var learned int64
+	if err := s.db.Model(&store.SchemaNote{}).Where("datasource_id = ?", id).
+		Distinct("object").Count(&learned).Error; err != nil {
+		return nil, fmt.Errorf("failed to count learned objects: %w", err)
+	}
+	stats.LearnedObjects = int(learned)
+
+	// Learning appends notes, so the newest one dates the last learn
+	var note store.SchemaNote
+	err = s.db.Where("datasource_id = ?", id).Order("id DESC").First(&note).Error
+	if err == nil {
+		stats.LastLearnAt = &note.CreatedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load last learn: %w", err)
+	}
+
+	var run store.ReportRun
+	err = s.db.Select("finished_at").
+		Where("datasource_id = ? AND status = ? AND finished_at IS NOT NULL", id, "completed").
+		Order("finished_at DESC").First(&run).Error
+	if err == nil {
+		stats.LastSuccessfulRun = run.FinishedAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load last successful run: %w", err)
+	}
+
+	if err := connector.Connected(); err != nil {
+		stats.Error = err.Error()
+		return stats, nil
+	}
+
+	pool := connector.DB.Stats()
+	stats.Pool = &store.DatasourcePoolStats{
+		MaxOpen:      pool.MaxOpenConnections,
+		Open:         pool.OpenConnections,
+		InUse:        pool.InUse,
+		Idle:         pool.Idle,
+		WaitCount:    pool.WaitCount,
+		WaitDuration: pool.WaitDuration.String(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := liveDatasourceStats(ctx, connector, stats); err != nil {
+		logger.LogWarn(logger.ServiceDB, "Failed to read datasource stats", map[string]interface{}{
+			"datasource_id": id,
+			"error":         err.Error(),
+		})
+		stats.Error = err.Error()
+	}
+	return stats, nil
+}
+
+// liveDatasourceStats counts a datasource's tables and views and reads its
+// size, where the database reports one. System schemas are not counted.
+func liveDatasourceStats(ctx context.Context, connector *datasource.DatasourceConnector, stats *store.DatasourceStatsResponse) error {
+	var countQuery, sizeQuery string
+	switch connector.Dialect() {
+	case "sqlite", "sqlite3":
+		countQuery = `SELECT
+			COALESCE(SUM(type = 'table'), 0),
+			COALESCE(SUM(type = 'view'), 0)
+			FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%'`
+		sizeQuery = "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	case "postgres", "postgresql", "timescaledb":
+		countQuery = `SELECT
+			(SELECT COUNT(*) FROM pg_tables WHERE schemaname NOT IN ('pg_catalog', 'information_schema') AND schemaname NOT LIKE '\_timescaledb%'),
+			(SELECT COUNT(*) FROM pg_views WHERE schemaname NOT IN ('pg_catalog', 'information_schema') AND schemaname NOT LIKE '\_timescaledb%')`
+		sizeQuery = "SELECT pg_database_size(current_database())"
+	case "mysql":
+		countQuery = `SELECT
+			COALESCE(SUM(table_type = 'BASE TABLE'), 0),
+			COALESCE(SUM(table_type = 'VIEW'), 0)
+			FROM information_schema.tables WHERE table_schema = DATABASE()`
+		sizeQuery = "SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables WHERE table_schema = DATABASE()"
+	case "trino":
+		// Catalogs report no storage size, so only the session schema is counted
+		countQuery = `SELECT
+			count_if(table_type = 'BASE TABLE'),
+			count_if(table_type = 'VIEW')
+			FROM information_schema.tables WHERE table_schema = current_schema`
+	default:
+		return fmt.Errorf("unsupported database kind: %s", connector.Kind)
+	}
+
+	var tables, views int
+	comment := queryComment{User: auth.Username(ctx), Job: "datasource_stats"}
+	if err := connector.DB.QueryRowContext(ctx, comment.apply(countQuery)).Scan(&tables, &views); err != nil {
+		return fmt.Errorf("failed to count tables and views: %w", err)
+	}
+	stats.Tables = &tables
+	stats.Views = &views
+	if sizeQuery == "" {
+		return nil
+	}
+
+	var size sql.NullInt64
+	if err := connector.DB.QueryRowContext(ctx, comment.apply(sizeQuery)).Scan(&size); err != nil {
+		return fmt.Errorf("failed to read database size: %w", err)
+	}
+	if size.Valid {
+		stats.SizeBytes = &size.Int64
+	}
+	return nil
+}
@@ -0,0 +1,431 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+const (
+	// DemoDatasourceID is the datasource the demo data is registered as
+	DemoDatasourceID = "demo"
+	demoDSN          = "demo_analytics.db"
+	demoSeed         = 42 // fixed so every seed produces the same figures
+)
+
+// demoSchema creates the demo tables. The sales tables match the eval
+// fixture (testdata/eval/sales.sql), so the suite's questions apply here too.
+var demoSchema = []string{
+	`DROP TABLE IF EXISTS orders`,
+	`DROP TABLE IF EXISTS customers`,
+	`DROP TABLE IF EXISTS energy_readings`,
+	`DROP TABLE IF EXISTS energy_sites`,
+	`CREATE TABLE customers (
+		customer_id   VARCHAR(20) PRIMARY KEY,
+		customer_name VARCHAR(100) NOT NULL,
+		region        VARCHAR(50) NOT NULL,
+		segment       VARCHAR(20) NOT NULL
+	)`,
+	`CREATE TABLE orders (
+		order_id         INTEGER PRIMARY KEY,
+		order_date       DATE NOT NULL,
+		customer_id      VARCHAR(20) NOT NULL REFERENCES customers (customer_id),
+		product_category VARCHAR(50) NOT NULL,
+		quantity         INTEGER NOT NULL,
+		total_amount     NUMERIC(12, 2) NOT NULL,
+		order_status     VARCHAR(20) NOT NULL
+	)`,
+	`CREATE TABLE energy_sites (
+		site_id       VARCHAR(20) PRIMARY KEY,
+		site_name     VARCHAR(100) NOT NULL,
+		city          VARCHAR(50) NOT NULL,
+		building_type VARCHAR(20) NOT NULL,
+		floor_area_m2 REAL NOT NULL
+	)`,
+	`CREATE TABLE energy_readings (
+		reading_time   TIMESTAMP NOT NULL,
+		site_id        VARCHAR(20) NOT NULL REFERENCES energy_sites (site_id),
+		kwh            REAL NOT NULL,
+		demand_kw      REAL NOT NULL,
+		outdoor_temp_c REAL NOT NULL,
+		PRIMARY KEY (site_id, reading_time)
+	)`,
+}
+
+// demoGlossary maps business terms to the demo columns
+var demoGlossary = []store.GlossaryTermRequest{
+	{Term: "revenue", Synonyms: []string{"sales", "turnover"}, Object: "orders", Expression: "total_amount", Description: "Order value including tax"},
+	{Term: "consumption", Synonyms: []string{"energy use", "usage"}, Object: "energy_readings", Expression: "kwh", Description: "Energy used in the hour, in kWh"},
+	{Term: "peak demand", Object: "energy_readings", Expression: "MAX(demand_kw)", Description: "Highest hourly demand, in kW"},
+}
+
+// demoReport is a pre-built report with the scope it answers
+type demoReport struct {
+	key     string
+	title   string
+	scopeMD string
+	sql     string
+}
+
+var demoReports = []demoReport{
+	{
+		key:   "demo_monthly_revenue",
+		title: "Monthly revenue by region",
+		scopeMD: `# Monthly revenue by region
+
+Revenue and order count per region for each of the last 12 months.
+
+- Source: orders joined to customers
+- Excludes cancelled orders
+- Grouped by month and region`,
+		sql: `SELECT strftime('%Y-%m', o.order_date) AS month, c.region,
+       SUM(o.total_amount) AS revenue, COUNT(*) AS orders
+FROM orders o
+JOIN customers c ON c.customer_id = o.customer_id
+WHERE o.order_status <> 'cancelled' AND o.order_date >= date('now', '-12 months')
+GROUP BY month, c.region
+ORDER BY month, c.region`,
+	},
+	{
+		key:   "demo_top_customers",
+		title: "Top customers by revenue",
+		scopeMD: `# Top customers by revenue
+
+The ten customers with the most delivered revenue since a start date.
+
+- Parameter: start_date (YYYY-MM-DD)
+- Only delivered orders count`,
+		sql: `SELECT c.customer_name, c.region, c.segment, SUM(o.total_amount) AS revenue
+FROM orders o
+JOIN customers c ON c.customer_id = o.customer_id
+WHERE o.order_status = 'delivered' AND o.order_date >= '{{start_date}}'
+GROUP BY c.customer_id, c.customer_name, c.region, c.segment
+ORDER BY revenue DESC
+LIMIT 10`,
+	},
+	{
+		key:   "demo_daily_energy",
+		title: "Daily energy use per site",
+		scopeMD: `# Daily energy use per site
+
+Energy consumption, peak demand and mean outdoor temperature per site per day over the last 30 days.
+
+- Source: hourly energy_readings joined to energy_sites`,
+		sql: `SELECT date(r.reading_time) AS day, s.site_name,
+       SUM(r.kwh) AS kwh, MAX(r.demand_kw) AS peak_kw, AVG(r.outdoor_temp_c) AS avg_temp_c
+FROM energy_readings r
+JOIN energy_sites s ON s.site_id = r.site_id
+WHERE r.reading_time >= datetime('now', '-30 days')
+GROUP BY day, s.site_name
+ORDER BY day, s.site_name`,
+	},
+}
+
+// DemoService creates a demo analytics source for onboarding, demos and
+// trying out eval questions
+type DemoService struct {
+	db          *gorm.DB
+	datasources *DatasourceService
+	reports     *ReportsService
+}
+
+// NewDemoService creates a new demo service
+func NewDemoService(db *gorm.DB, datasources *DatasourceService, reports *ReportsService) *DemoService {
+	return &DemoService{db: db, datasources: datasources, reports: reports}
+}
+
+// SeedDemo writes the demo SQLite database, registers and learns it as the
+// demo datasource, and adds its glossary, scopes and reports. Seeding again
+// is a conflict unless Reset is set, which rebuilds the data and adds new
+// scope and report versions.
+func (s *DemoService) SeedDemo(req store.SeedDemoRequest) (*store.SeedDemoResponse, error) {
+	start := time.Now()
+
+	var existing store.Datasource
+	err := s.db.Where("id = ?", DemoDatasourceID).First(&existing).Error
+	exists := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to check demo datasource: %w", err)
+	}
+	if exists {
+		if existing.Kind != "sqlite" || existing.DSN != demoDSN {
+			return nil, classErrorf(ErrConflict, "datasource %s exists and is not the demo database", DemoDatasourceID)
+		}
+		if !req.Reset {
+			return nil, classErrorf(ErrConflict, "demo datasource already seeded; seed with reset to rebuild it")
+		}
+	}
+
+	rows, err := writeDemoDatabase(demoDSN, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		// Reconnect so the datasource sees the rebuilt tables
+		err = s.datasources.registry.UpdateDatasource(DemoDatasourceID, "sqlite", demoDSN, existing.DisplayName, existing.IsDefault)
+	} else {
+		err = s.datasources.registry.AddDatasource(DemoDatasourceID, "sqlite", demoDSN, "Demo Analytics", false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to register demo datasource: %w", err)
+	}
+	if err := s.datasources.LearnDatasource(store.LearnDatasourceRequest{DatasourceID: DemoDatasourceID}); err != nil {
+		return nil, fmt.Errorf("failed to learn demo datasource: %w", err)
+	}
+	if _, err := s.datasources.UpsertGlossary(DemoDatasourceID, demoGlossary); err != nil {
+		return nil, fmt.Errorf("failed to load demo glossary: %w", err)
+	}
+
+	resp := &store.SeedDemoResponse{DatasourceID: DemoDatasourceID, DSN: demoDSN, Rows: rows}
+	for _, demo := range demoReports {
+		scopeID, err := s.seedDemoReport(demo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create demo report %s: %w", demo.key, err)
+		}
+		resp.Scopes = append(resp.Scopes, scopeID)
+		resp.Reports = append(resp.Reports, demo.key)
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Demo datasource seeded", map[string]interface{}{
+		"datasource_id": DemoDatasourceID,
+		"rows":          rows,
+		"reports":       len(resp.Reports),
+		"reset":         req.Reset,
+		"duration":      time.Since(start).String(),
+	})
+	return resp, nil
+}
+
+// seedDemoReport adds a scope version for the report's scope, creating the
+// scope on first seed, and a report version linked to it
+func (s *DemoService) seedDemoReport(demo demoReport) (uint, error) {
+	var scope store.Scope
+	err := s.db.Where("name = ?", demo.title).First(&scope).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		created, createErr := s.reports.CreateScope(store.CreateScopeRequest{Name: demo.title})
+		if createErr != nil {
+			return 0, createErr
+		}
+		scope, err = *created, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find scope: %w", err)
+	}
+
+	scopeVersion, err := s.reports.CreateScopeVersion(scope.ID, store.CreateScopeVersionRequest{ScopeMD: demo.scopeMD})
+	if err != nil {
+		return 0, err
+	}
+	created, err := s.reports.CreateSQLReport(store.CreateSQLReportRequest{
+		Key:          demo.key,
+		Title:        demo.title,
+		Owner:        "demo",
+		DatasourceID: DemoDatasourceID,
+		SQL:          demo.sql,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := s.db.Model(created.Version).Update("scope_version_id", scopeVersion.ID).Error; err != nil {
+		return 0, fmt.Errorf("failed to link report to scope: %w", err)
+	}
+	return scope.ID, nil
+}
+
+// writeDemoDatabase (re)creates the demo tables at dsn and fills them with
+// generated data ending the day before now, so relative date filters always
+// find rows. It returns the rows loaded per table.
+func writeDemoDatabase(dsn string, now time.Time) (map[string]int, error) {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open demo database: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start demo seed: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range demoSchema {
+		if _, err := tx.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("failed to create demo tables: %w", err)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(demoSeed))
+	today := now.Truncate(24 * time.Hour)
+	rows := make(map[string]int)
+	// In dependency order, and always the same order so the data is too
+	for _, step := range []struct {
+		table string
+		seed  func(*sql.Tx, *rand.Rand, time.Time) (int, error)
+	}{
+		{"customers", seedDemoCustomers},
+		{"orders", seedDemoOrders},
+		{"energy_sites", seedDemoSites},
+		{"energy_readings", seedDemoReadings},
+	} {
+		n, err := step.seed(tx, rng, today)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seed %s: %w", step.table, err)
+		}
+		rows[step.table] = n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit demo seed: %w", err)
+	}
+	return rows, nil
+}
+
+var (
+	demoFirstNames = []string{"Alice", "Bob", "Carol", "David", "Eva", "Farid", "Grace", "Hiro", "Ines", "Jonas", "Kemi", "Liam", "Maya", "Noah", "Olga", "Priya", "Quinn", "Rosa", "Sam", "Tara"}
+	demoLastNames  = []string{"Johnson", "Wilson", "Brown", "Lee", "Martinez", "Khan", "Tanaka", "Silva", "Novak", "Okafor"}
+	demoRegions    = []string{"North America", "Europe", "Asia", "South America", "Oceania"}
+	demoSegments   = []string{"premium", "standard", "standard", "budget"}
+	demoCategories = []struct {
+		name     string
+		minPrice float64
+		maxPrice float64
+	}{
+		{"Electronics", 150, 1800},
+		{"Clothing", 20, 250},
+		{"Home & Garden", 30, 400},
+		{"Sports", 25, 600},
+		{"Books", 8, 60},
+	}
+)
+
+const demoCustomers = 40
+
+func seedDemoCustomers(tx *sql.Tx, rng *rand.Rand, _ time.Time) (int, error) {
+	stmt, err := tx.Prepare(`INSERT INTO customers (customer_id, customer_name, region, segment) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for i := 1; i <= demoCustomers; i++ {
+		name := demoFirstNames[rng.Intn(len(demoFirstNames))] + " " + demoLastNames[rng.Intn(len(demoLastNames))]
+		if _, err := stmt.Exec(fmt.Sprintf("CUST-%03d", i), name,
+			demoRegions[rng.Intn(len(demoRegions))], demoSegments[rng.Intn(len(demoSegments))]); err != nil {
+			return 0, err
+		}
+	}
+	return demoCustomers, nil
+}
+
+// seedDemoOrders adds a year of daily orders, busier at weekends and
+// towards the end of the year
+func seedDemoOrders(tx *sql.Tx, rng *rand.Rand, today time.Time) (int, error) {
+	stmt, err := tx.Prepare(`INSERT INTO orders (order_id, order_date, customer_id, product_category, quantity, total_amount, order_status) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	id := 0
+	for day := today.AddDate(-1, 0, 0); day.Before(today); day = day.AddDate(0, 0, 1) {
+		count := 2 + rng.Intn(5)
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			count += 2
+		}
+		if day.Month() >= time.November {
+			count += 3
+		}
+		for i := 0; i < count; i++ {
+			id++
+			category := demoCategories[rng.Intn(len(demoCategories))]
+			quantity := 1 + rng.Intn(4)
+			price := category.minPrice + rng.Float64()*(category.maxPrice-category.minPrice)
+			amount := math.Round(price*float64(quantity)*100) / 100
+
+			status := "delivered"
+			switch roll := rng.Intn(100); {
+			case today.Sub(day) < 5*24*time.Hour:
+				status = "shipped"
+			case roll < 5:
+				status = "cancelled"
+			case roll < 12:
+				status = "returned"
+			}
+
+			if _, err := stmt.Exec(id, day.Format("2006-01-02"), fmt.Sprintf("CUST-%03d", 1+rng.Intn(demoCustomers)),
+				category.name, quantity, amount, status); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return id, nil
+}
+
+var demoSites = []struct {
+	id, name, city, buildingType string
+	area                         float64
+	baseKW                       float64 // load outside working hours
+	meanTemp                     float64
+}{
+	{"SITE-01", "Harbour Office", "Sydney", "office", 4200, 35, 19},
+	{"SITE-02", "Northgate Mall", "Melbourne", "retail", 12500, 120, 15},
+	{"SITE-03", "Riverside Plant", "Brisbane", "industrial", 8800, 260, 22},
+	{"SITE-04", "City Library", "Adelaide", "public", 3100, 18, 17},
+	{"SITE-05", "Westfield Depot", "Perth", "warehouse", 6400, 45, 20},
+}
+
+const demoReadingDays = 90
+
+func seedDemoSites(tx *sql.Tx, _ *rand.Rand, _ time.Time) (int, error) {
+	for _, site := range demoSites {
+		if _, err := tx.Exec(`INSERT INTO energy_sites (site_id, site_name, city, building_type, floor_area_m2) VALUES (?, ?, ?, ?, ?)`,
+			site.id, site.name, site.city, site.buildingType, site.area); err != nil {
+			return 0, err
+		}
+	}
+	return len(demoSites), nil
+}
+
+// seedDemoReadings adds hourly readings for each site: a base load, a
+// working-hours peak on weekdays and extra cooling on hot afternoons
+func seedDemoReadings(tx *sql.Tx, rng *rand.Rand, today time.Time) (int, error) {
+	stmt, err := tx.Prepare(`INSERT INTO energy_readings (reading_time, site_id, kwh, demand_kw, outdoor_temp_c) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	count := 0
+	for hour := today.AddDate(0, 0, -demoReadingDays); hour.Before(today); hour = hour.Add(time.Hour) {
+		for _, site := range demoSites {
+			temp := site.meanTemp + 6*math.Sin(2*math.Pi*float64(hour.Hour()-9)/24) + rng.NormFloat64()*1.5
+
+			demand := site.baseKW
+			weekday := hour.Weekday() != time.Saturday && hour.Weekday() != time.Sunday
+			if hour.Hour() >= 8 && hour.Hour() < 18 && (weekday || site.buildingType == "retail") {
+				demand += site.baseKW * 1.8
+			}
+			if temp > 24 {
+				demand += site.area * 0.002 * (temp - 24)
+			}
+			// An hour at a mean demand uses that many kWh; the recorded demand is the hour's peak
+			kwh := demand * (1 + rng.NormFloat64()*0.05)
+			peak := kwh * (1 + rng.Float64()*0.15)
+
+			if _, err := stmt.Exec(hour.Format("2006-01-02 15:04:05"), site.id,
+				math.Round(kwh*100)/100, math.Round(peak*100)/100, math.Round(temp*10)/10); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
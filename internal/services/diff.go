@@ -0,0 +1,200 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/NubeDev/air/internal/store"
+)
+
+// diffContextLines is the number of unchanged lines shown around each hunk
+const diffContextLines = 3
+
+// unifiedDiff returns a unified diff of two texts, or "" when they are equal
+func unifiedDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	a := splitLines(from)
+	b := splitLines(to)
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Group operations into hunks separated by more than 2*context unchanged lines
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		start := max(0, i-diffContextLines)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContextLines {
+				end = min(len(ops), end+diffContextLines)
+				break
+			}
+			end = run
+		}
+
+		hunk := ops[start:end]
+		aStart, bStart := hunk[0].aLine, hunk[0].bLine
+		aCount, bCount := 0, 0
+		for _, op := range hunk {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart+1, aCount, bStart+1, bCount)
+		for _, op := range hunk {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.text)
+		}
+
+		i = end
+	}
+
+	return out.String()
+}
+
+// diffOp is a single line in an edit script: ' ' unchanged, '-' removed, '+' added
+type diffOp struct {
+	kind  byte
+	text  string
+	aLine int
+	bLine int
+}
+
+// diffLines computes a line edit script from the longest common subsequence
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffIR compares two IR JSON documents and lists changes by JSON path
+func diffIR(fromJSON, toJSON string) ([]store.IRChange, error) {
+	var from, to interface{}
+	if fromJSON != "" {
+		if err := json.Unmarshal([]byte(fromJSON), &from); err != nil {
+			return nil, fmt.Errorf("invalid IR JSON in base version: %w", err)
+		}
+	}
+	if toJSON != "" {
+		if err := json.Unmarshal([]byte(toJSON), &to); err != nil {
+			return nil, fmt.Errorf("invalid IR JSON in target version: %w", err)
+		}
+	}
+
+	changes := []store.IRChange{}
+	collectIRChanges("$", from, to, &changes)
+	return changes, nil
+}
+
+func collectIRChanges(path string, from, to interface{}, changes *[]store.IRChange) {
+	if reflect.DeepEqual(from, to) {
+		return
+	}
+	if from == nil {
+		*changes = append(*changes, store.IRChange{Path: path, Op: "added", To: to})
+		return
+	}
+	if to == nil {
+		*changes = append(*changes, store.IRChange{Path: path, Op: "removed", From: from})
+		return
+	}
+
+	switch f := from.(type) {
+	case map[string]interface{}:
+		t, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range f {
+			keys[k] = true
+		}
+		for k := range t {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			collectIRChanges(path+"."+k, f[k], t[k], changes)
+		}
+		return
+	case []interface{}:
+		t, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(f), len(t)); i++ {
+			var fi, ti interface{}
+			if i < len(f) {
+				fi = f[i]
+			}
+			if i < len(t) {
+				ti = t[i]
+			}
+			collectIRChanges(fmt.Sprintf("%s[%d]", path, i), fi, ti, changes)
+		}
+		return
+	}
+
+	*changes = append(*changes, store.IRChange{Path: path, Op: "changed", From: from, To: to})
+}
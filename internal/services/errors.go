@@ -0,0 +1,45 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error classes. Service errors match one of these with errors.Is so the API
+// can map them to an error code and status without parsing messages.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrValidation    = errors.New("validation failed")
+	ErrConflict      = errors.New("conflict")
+	ErrSafetyBlocked = errors.New("blocked by safety checks")
+)
+
+// classError keeps the message of err while also matching class
+type classError struct {
+	class error
+	err   error
+}
+
+func (e *classError) Error() string   { return e.err.Error() }
+func (e *classError) Unwrap() []error { return []error{e.class, e.err} }
+
+// classErrorf formats an error like fmt.Errorf that also matches class
+func classErrorf(class error, format string, args ...interface{}) error {
+	return &classError{class: class, err: fmt.Errorf(format, args...)}
+}
+
+// VersionConflictError is returned when a new version is based on a version
+// that is no longer the latest. It matches ErrConflict; Latest holds the
+// current version so the caller can merge and retry.
+type VersionConflictError struct {
+	Resource string
+	Expected int
+	Current  int
+	Latest   interface{}
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s was modified: based on version %d, latest is %d", e.Resource, e.Expected, e.Current)
+}
+
+func (e *VersionConflictError) Is(target error) bool { return target == ErrConflict }
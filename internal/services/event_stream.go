@@ -0,0 +1,139 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventReportRunStarted is published to stream subscribers when a run starts
+// executing. It is not a webhook event: the completed or failed event follows.
+const EventReportRunStarted = "report.run.started"
+
+const (
+	eventStreamHistory = 256 // events kept for Last-Event-ID replay
+	eventStreamBuffer  = 64  // per-subscriber queue before events are dropped
+)
+
+// StreamEvent is one event fanned out to stream subscribers
+type StreamEvent struct {
+	ID    uint64                 `json:"id"`
+	Event string                 `json:"event"`
+	Data  map[string]interface{} `json:"data"`
+	At    time.Time              `json:"at"`
+}
+
+// EventFilter selects the events a subscriber receives. Types match exact
+// names or a prefix ending in ".*"; an empty filter matches everything.
+type EventFilter struct {
+	Types    []string
+	ReportID uint
+}
+
+// Match reports whether e passes the filter
+func (f EventFilter) Match(e StreamEvent) bool {
+	if f.ReportID != 0 {
+		id, ok := e.Data["report_id"].(uint)
+		if !ok || id != f.ReportID {
+			return false
+		}
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == "*" || t == e.Event {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "*"); ok && strings.HasPrefix(e.Event, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type streamSubscriber struct {
+	filter EventFilter
+	ch     chan StreamEvent
+}
+
+// EventStream fans lifecycle events out to long-lived HTTP subscribers, such
+// as Server-Sent Events clients that cannot hold a WebSocket open. It keeps a
+// short history so a reconnecting client can resume from its last event.
+type EventStream struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []StreamEvent
+	subs    map[*streamSubscriber]struct{}
+}
+
+// NewEventStream creates an empty event stream
+func NewEventStream() *EventStream {
+	return &EventStream{subs: make(map[*streamSubscriber]struct{})}
+}
+
+// HandleEvent is registered as a lifecycle event listener
+func (s *EventStream) HandleEvent(event string, data map[string]interface{}) {
+	s.Publish(event, data)
+}
+
+// Publish sends an event to every matching subscriber. A subscriber whose
+// queue is full misses the event rather than blocking the publisher. It is
+// safe to call on a nil stream.
+func (s *EventStream) Publish(event string, data map[string]interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	e := StreamEvent{ID: s.nextID, Event: event, Data: data, At: time.Now().UTC()}
+	s.history = append(s.history, e)
+	if len(s.history) > eventStreamHistory {
+		s.history = s.history[len(s.history)-eventStreamHistory:]
+	}
+
+	for sub := range s.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of events matching filter, starting with any
+// kept events published after lastID (0 for none), and a function that ends
+// the subscription.
+func (s *EventStream) Subscribe(filter EventFilter, lastID uint64) (<-chan StreamEvent, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var replay []StreamEvent
+	if lastID > 0 {
+		for _, e := range s.history {
+			if e.ID > lastID && filter.Match(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	sub := &streamSubscriber{filter: filter, ch: make(chan StreamEvent, eventStreamBuffer+len(replay))}
+	for _, e := range replay {
+		sub.ch <- e
+	}
+	s.subs[sub] = struct{}{}
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, sub)
+			s.mu.Unlock()
+		})
+	}
+}
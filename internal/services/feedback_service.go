@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrFeedbackRunNotFound is returned when feedback targets an unknown run
+	ErrFeedbackRunNotFound = classErrorf(ErrNotFound, "run not found")
+	// ErrInvalidFeedback is returned for feedback that cannot be linked or validated
+	ErrInvalidFeedback = classErrorf(ErrValidation, "invalid feedback")
+)
+
+// FeedbackService records user ratings of generated SQL and analyses
+type FeedbackService struct {
+	db       *gorm.DB
+	examples *ExampleService
+}
+
+// NewFeedbackService creates a new feedback service
+func NewFeedbackService(db *gorm.DB) *FeedbackService {
+	return &FeedbackService{db: db}
+}
+
+// SetExamples seeds the SQL example library from accepted and corrected SQL
+func (s *FeedbackService) SetExamples(examples *ExampleService) {
+	s.examples = examples
+}
+
+// CreateFeedback stores feedback for a run, linking it to the report version,
+// scope version and analysis rubric that produced the rated output
+func (s *FeedbackService) CreateFeedback(runID uint, req store.CreateRunFeedbackRequest) (*store.RunFeedback, error) {
+	var run store.ReportRun
+	if err := s.db.First(&run, runID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFeedbackRunNotFound
+		}
+		return nil, fmt.Errorf("failed to load run: %w", err)
+	}
+
+	feedback := &store.RunFeedback{
+		RunID:           run.ID,
+		Target:          req.Target,
+		Rating:          req.Rating,
+		Comment:         strings.TrimSpace(req.Comment),
+		User:            req.User,
+		ReportVersionID: run.ReportVersionID,
+		CreatedAt:       time.Now(),
+	}
+
+	var version store.ReportVersion
+	if err := s.db.Preload("Report").First(&version, run.ReportVersionID).Error; err == nil {
+		feedback.ScopeVersionID = version.ScopeVersionID
+	}
+
+	switch req.Target {
+	case "sql":
+		if corrected := strings.TrimSpace(req.CorrectedSQL); corrected != "" {
+			if _, err := ValidateReadOnlySQL(corrected); err != nil {
+				return nil, fmt.Errorf("%w: corrected SQL: %v", ErrInvalidFeedback, err)
+			}
+			feedback.CorrectedSQL = corrected
+		}
+	case "analysis":
+		if req.CorrectedSQL != "" {
+			return nil, fmt.Errorf("%w: corrected_sql only applies to sql feedback", ErrInvalidFeedback)
+		}
+		var analysis store.ReportAnalysis
+		query := s.db.Where("run_id = ?", run.ID)
+		if req.AnalysisID != nil {
+			query = query.Where("id = ?", *req.AnalysisID)
+		}
+		if err := query.Order("created_at DESC").First(&analysis).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: run has no matching analysis", ErrInvalidFeedback)
+			}
+			return nil, fmt.Errorf("failed to load analysis: %w", err)
+		}
+		feedback.AnalysisID = &analysis.ID
+		feedback.RubricVersion = analysis.RubricVersion
+		feedback.ModelUsed = analysis.ModelUsed
+	}
+
+	if err := s.db.Create(feedback).Error; err != nil {
+		return nil, fmt.Errorf("failed to save feedback: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Run feedback recorded", map[string]interface{}{
+		"run_id": run.ID,
+		"target": feedback.Target,
+		"rating": feedback.Rating,
+	})
+
+	if feedback.Target == "sql" {
+		s.seedExample(&run, &version, feedback)
+	}
+	return feedback, nil
+}
+
+// seedExample turns corrected SQL, or the report's SQL template for a thumbs-up,
+// into a few-shot example. The template is used rather than the executed SQL so
+// examples keep their {{param}} placeholders instead of one run's values.
+func (s *FeedbackService) seedExample(run *store.ReportRun, version *store.ReportVersion, feedback *store.RunFeedback) {
+	if s.examples == nil {
+		return
+	}
+	sqlText, source := feedback.CorrectedSQL, "correction"
+	if sqlText == "" {
+		if feedback.Rating < 1 {
+			return
+		}
+		sqlText, source = extractSQLFromDef(version.DefJSON), "accepted"
+	}
+
+	question := version.Report.Title
+	if version.ScopeVersionID != nil {
+		var scope store.ScopeVersion
+		if err := s.db.First(&scope, *version.ScopeVersionID).Error; err == nil && strings.TrimSpace(scope.ScopeMD) != "" {
+			question = scope.ScopeMD
+		}
+	}
+	if question == "" || sqlText == "" {
+		return
+	}
+
+	if _, err := s.examples.AddExample(run.DatasourceID, question, sqlText, source, &run.ID); err != nil {
+		logger.LogWarn(logger.ServiceAI, "Failed to seed SQL example from feedback", map[string]interface{}{
+			"run_id": run.ID,
+			"error":  err.Error(),
+		})
+	}
+}
+
+// ListRunFeedback returns all feedback recorded for a run
+func (s *FeedbackService) ListRunFeedback(runID uint) ([]store.RunFeedback, error) {
+	var feedback []store.RunFeedback
+	if err := s.db.Where("run_id = ?", runID).Order("created_at").Find(&feedback).Error; err != nil {
+		return nil, fmt.Errorf("failed to list feedback: %w", err)
+	}
+	return feedback, nil
+}
+
+// ExportPairs returns feedback as prompt/response pairs, optionally filtered
+// by target ("sql" or "analysis") and rating. SQL prompts are the scope and IR
+// the SQL was generated from; analysis prompts are the run summary the model saw.
+func (s *FeedbackService) ExportPairs(target string, rating int) ([]store.FeedbackPair, error) {
+	query := s.db.Order("created_at")
+	if target != "" {
+		query = query.Where("target = ?", target)
+	}
+	if rating != 0 {
+		query = query.Where("rating = ?", rating)
+	}
+	var feedback []store.RunFeedback
+	if err := query.Find(&feedback).Error; err != nil {
+		return nil, fmt.Errorf("failed to load feedback: %w", err)
+	}
+
+	runs := map[uint]*store.ReportRun{}
+	pairs := make([]store.FeedbackPair, 0, len(feedback))
+	for _, f := range feedback {
+		run, ok := runs[f.RunID]
+		if !ok {
+			run = &store.ReportRun{}
+			if err := s.db.Preload("Report").First(run, f.RunID).Error; err != nil {
+				run = nil // run deleted; its feedback can't be paired
+			}
+			runs[f.RunID] = run
+		}
+		if run == nil {
+			continue
+		}
+
+		pair := store.FeedbackPair{
+			FeedbackID:      f.ID,
+			RunID:           f.RunID,
+			Target:          f.Target,
+			Rating:          f.Rating,
+			Corrected:       f.CorrectedSQL,
+			Comment:         f.Comment,
+			ReportVersionID: f.ReportVersionID,
+			ScopeVersionID:  f.ScopeVersionID,
+			RubricVersion:   f.RubricVersion,
+			ModelUsed:       f.ModelUsed,
+			CreatedAt:       f.CreatedAt,
+		}
+		switch f.Target {
+		case "sql":
+			pair.Prompt = s.sqlPrompt(run, f.ScopeVersionID)
+			pair.Response = run.SQLText
+		case "analysis":
+			var analysis store.ReportAnalysis
+			if f.AnalysisID == nil || s.db.First(&analysis, *f.AnalysisID).Error != nil {
+				continue
+			}
+			pair.Prompt = runAnalysisPrompt(s.db, run)
+			pair.Response = analysis.AnalysisMD
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// sqlPrompt reconstructs what SQL generation worked from: the scope markdown
+// and its IR, or just the report title for hand-written SQL
+func (s *FeedbackService) sqlPrompt(run *store.ReportRun, scopeVersionID *uint) string {
+	if scopeVersionID != nil {
+		var scope store.ScopeVersion
+		if err := s.db.First(&scope, *scopeVersionID).Error; err == nil {
+			prompt := scope.ScopeMD
+			if scope.IRJSON != "" {
+				prompt += "\n\nIR:\n" + scope.IRJSON
+			}
+			return prompt
+		}
+	}
+	return "Report: " + run.Report.Title
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+)
+
+const (
+	// fileAnalysisMaxItems caps the insights and suggestions kept from a response
+	fileAnalysisMaxItems = 10
+	// fileAnalysisPrompt asks for the only shape parseFileAnalysis accepts
+	fileAnalysisPrompt = `You are a data analysis expert. Analyze the provided file content for the user's query. Be specific and actionable.
+Respond with ONLY a JSON object of exactly this shape, with no Markdown and no other text:
+{"summary": string, "insights": [string], "suggestions": [string]}
+summary is a short Markdown analysis. insights are key findings and suggestions are actions, one sentence each, at most 10 of each.`
+)
+
+// FileAnalysisResult is the structured answer to a file analysis prompt
+type FileAnalysisResult struct {
+	Summary     string   `json:"summary"`
+	Insights    []string `json:"insights"`
+	Suggestions []string `json:"suggestions"`
+	Model       string   `json:"model"`
+}
+
+// AnalyzeFileContent asks the chat model to analyze the head of an uploaded
+// file and parses its strict JSON answer into summary, insights and suggestions
+func (s *AIService) AnalyzeFileContent(ctx context.Context, filename, content, query string) (*FileAnalysisResult, error) {
+	ctx, cancel := s.operationContext(ctx, opAnalyze)
+	defer cancel()
+
+	model := llm.GetModelName(s.Config, "chat")
+	req := llm.ChatRequest{
+		Model: model,
+		Messages: []llm.Message{
+			{Role: "system", Content: fileAnalysisPrompt},
+			{Role: "user", Content: fmt.Sprintf("File: %s\n\nContent:\n\n%s\n\nUser query: %s", filename, content, query)},
+		},
+		Stream:  false,
+		Options: s.samplingOptions(0.3, 0.9),
+	}
+
+	resp, err := s.tracedChat(ctx, "analyze_file", traceLink{}, req)
+	if err != nil {
+		return nil, fmt.Errorf("file analysis failed: %w", s.aiCallError(ctx, opAnalyze, err))
+	}
+
+	result, err := parseFileAnalysis(resp.Message.Content)
+	if err != nil {
+		raw := strings.TrimSpace(resp.Message.Content)
+		logger.LogError(logger.ServiceAI, "Failed to parse file analysis JSON", err, map[string]interface{}{
+			"content_head": raw[:min(200, len(raw))],
+		})
+		return nil, err
+	}
+	result.Model = model
+	return result, nil
+}
+
+// parseFileAnalysis checks a model answer against the file analysis shape:
+// an object with a non-empty summary and string arrays of insights and
+// suggestions, nothing else. Blank items are dropped and long lists cut.
+func parseFileAnalysis(content string) (*FileAnalysisResult, error) {
+	decoder := json.NewDecoder(bytes.NewReader(sanitizeModelJSONOutput(content)))
+	decoder.DisallowUnknownFields()
+
+	var parsed struct {
+		Summary     *string   `json:"summary"`
+		Insights    *[]string `json:"insights"`
+		Suggestions *[]string `json:"suggestions"`
+	}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, classErrorf(ErrValidation, "model did not return valid file analysis JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, classErrorf(ErrValidation, "file analysis JSON is followed by other text")
+	}
+	switch {
+	case parsed.Summary == nil || strings.TrimSpace(*parsed.Summary) == "":
+		return nil, classErrorf(ErrValidation, "file analysis JSON is missing summary")
+	case parsed.Insights == nil:
+		return nil, classErrorf(ErrValidation, "file analysis JSON is missing insights")
+	case parsed.Suggestions == nil:
+		return nil, classErrorf(ErrValidation, "file analysis JSON is missing suggestions")
+	}
+
+	return &FileAnalysisResult{
+		Summary:     strings.TrimSpace(*parsed.Summary),
+		Insights:    cleanAnalysisItems(*parsed.Insights),
+		Suggestions: cleanAnalysisItems(*parsed.Suggestions),
+	}, nil
+}
+
+// cleanAnalysisItems trims items, drops blank ones and keeps at most
+// fileAnalysisMaxItems
+func cleanAnalysisItems(items []string) []string {
+	cleaned := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			cleaned = append(cleaned, item)
+		}
+		if len(cleaned) == fileAnalysisMaxItems {
+			break
+		}
+	}
+	return cleaned
+}
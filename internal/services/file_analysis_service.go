@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// ErrFileAnalysisNotFound is returned for an unknown file analysis
+var ErrFileAnalysisNotFound = classErrorf(ErrNotFound, "file analysis not found")
+
+// FileAnalysisService stores the file analyses requested over the WebSocket
+type FileAnalysisService struct {
+	db *gorm.DB
+}
+
+// NewFileAnalysisService creates a new file analysis service
+func NewFileAnalysisService(db *gorm.DB) *FileAnalysisService {
+	return &FileAnalysisService{db: db}
+}
+
+// RecordFileAnalysis saves a completed analysis. It satisfies the WebSocket
+// hub's recorder so analyses outlive the connection that asked for them.
+func (s *FileAnalysisService) RecordFileAnalysis(ctx context.Context, userID, fileID, query, model, analysis string, insights, suggestions []string) (uint, error) {
+	insightsJSON, err := json.Marshal(nonNilStrings(insights))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode insights: %w", err)
+	}
+	suggestionsJSON, err := json.Marshal(nonNilStrings(suggestions))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode suggestions: %w", err)
+	}
+
+	record := &store.FileAnalysis{
+		UserID:          userID,
+		FileID:          fileID,
+		Query:           query,
+		Model:           model,
+		Analysis:        analysis,
+		InsightsJSON:    string(insightsJSON),
+		SuggestionsJSON: string(suggestionsJSON),
+		CreatedAt:       time.Now(),
+	}
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return 0, fmt.Errorf("failed to save file analysis: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "File analysis saved", map[string]interface{}{
+		"analysis_id": record.ID,
+		"file_id":     fileID,
+		"user_id":     userID,
+		"model":       model,
+	})
+	return record.ID, nil
+}
+
+// GetFileAnalysis returns one saved analysis
+func (s *FileAnalysisService) GetFileAnalysis(id uint) (*store.FileAnalysis, error) {
+	var record store.FileAnalysis
+	if err := s.db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFileAnalysisNotFound
+		}
+		return nil, fmt.Errorf("failed to load file analysis: %w", err)
+	}
+	decodeFileAnalysis(&record)
+	return &record, nil
+}
+
+// fileAnalysisList is the sort and filter fields of ListFileAnalyses
+var fileAnalysisList = ListSpec{
+	Table: "file_analyses",
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"file_id":    "file_id",
+	},
+	Filters: map[string]string{
+		"file_id": "file_id",
+		"user_id": "user_id",
+		"model":   "model",
+	},
+	DefaultSort: "-created_at",
+}
+
+// ListFileAnalyses lists saved analyses a page at a time, newest first by default
+func (s *FileAnalysisService) ListFileAnalyses(opts store.ListOptions) ([]store.FileAnalysis, *store.PageInfo, error) {
+	var records []store.FileAnalysis
+	page, err := ListPage(s.db, fileAnalysisList, opts, &records)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range records {
+		decodeFileAnalysis(&records[i])
+	}
+	return records, page, nil
+}
+
+// decodeFileAnalysis fills the insight and suggestion lists from their columns
+func decodeFileAnalysis(record *store.FileAnalysis) {
+	record.Insights, record.Suggestions = []string{}, []string{}
+	if record.InsightsJSON != "" {
+		json.Unmarshal([]byte(record.InsightsJSON), &record.Insights)
+	}
+	if record.SuggestionsJSON != "" {
+		json.Unmarshal([]byte(record.SuggestionsJSON), &record.Suggestions)
+	}
+}
+
+// nonNilStrings keeps empty lists encoding as [] rather than null
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
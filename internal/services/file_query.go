@@ -0,0 +1,593 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+)
+
+const (
+	// fileTableMaxRows caps how much of a file is loaded
+	fileTableMaxRows = 500000
+	// fileTableTypeSample is how many rows column types are inferred from
+	fileTableTypeSample = 200
+	// fileTableSampleRows are shown to the SQL model with the schema
+	fileTableSampleRows = 3
+	// fileWorkspaceCacheSize is how many loaded workspaces are kept open
+	fileWorkspaceCacheSize = 4
+	// fileJoinHintLimit caps the join keys suggested to the SQL model
+	fileJoinHintLimit = 10
+	// fileQueryRowLimit caps the result rows read back and shown to the model
+	fileQueryRowLimit = 200
+	// fileAnswerPrompt asks the chat model to phrase a query result
+	fileAnswerPrompt = `You are AIR (AI Reporting Intelligence). A SQL query was run against the user's dataset to answer their question.
+Answer the question from the query result only. Be specific and concise, and quote the numbers. If the result is empty, say so.`
+)
+
+// uploadPrefixRe matches the upload timestamp prepended to file IDs
+var uploadPrefixRe = regexp.MustCompile(`^\d{8}_\d{6}_`)
+
+// FileAnswer is the answer to a question about tabular files, with the
+// query that produced it
+type FileAnswer struct {
+	Answer    string            `json:"answer"`
+	SQL       string            `json:"sql"`
+	Tables    map[string]string `json:"tables"` // file name to the table it was queried as
+	Columns   []string          `json:"columns"`
+	Rows      [][]interface{}   `json:"rows"`
+	Truncated bool              `json:"truncated"`
+	Model     string            `json:"model"`
+}
+
+// IsTabularFile reports whether a file is loaded as a table for questions
+// rather than read by the model as text
+func IsTabularFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// AnswerFileQuestion answers a question about CSV or TSV files with SQL. Each
+// file becomes a table of one in-memory SQLite workspace, the question is
+// turned into IR against their schemas, the SQL generator writes a query
+// from the IR, joining the files when the question spans them, and the chat
+// model phrases the result. The answer covers every row instead of the head
+// the model could read.
+func (s *AIService) AnswerFileQuestion(ctx context.Context, paths []string, question string) (*FileAnswer, error) {
+	if len(paths) == 0 {
+		return nil, classErrorf(ErrValidation, "no files to query")
+	}
+	workspace, err := s.fileWorkspaces.load(paths)
+	if err != nil {
+		return nil, err
+	}
+	defer s.fileWorkspaces.release(workspace)
+
+	schema := workspace.schema()
+	ir, err := s.chatIR(ctx, "build_file_ir", traceLink{}, fmt.Sprintf("Scope Markdown:\n\n## Question\n\n%s\n\nFilter on the literal values in the question; there are no {{param}} placeholders.\n\n%s\nGenerate IR now.", question, schema))
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.buildSQLCoderPromptFromIR(ir, "sqlite")
+	if err != nil {
+		return nil, classErrorf(ErrValidation, "could not turn the question into a query: %w", err)
+	}
+	query, err := s.generateSQL(ctx, ir, prompt, schema, "sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate file query: %w", err)
+	}
+	if _, err := ValidateReadOnlySQL(query); err != nil {
+		return nil, err
+	}
+
+	answer := &FileAnswer{SQL: query, Tables: workspace.tableNames()}
+	answer.Columns, answer.Rows, answer.Truncated, err = workspace.query(ctx, query)
+	if err != nil {
+		return nil, classErrorf(ErrValidation, "generated file query failed: %w", err)
+	}
+
+	if err := s.phraseQueryAnswer(ctx, "answer_file_question", fileAnswerPrompt, traceLink{}, question, answer); err != nil {
+		return nil, fmt.Errorf("failed to answer file question: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceAI, "File question answered with SQL", map[string]interface{}{
+		"files":     len(paths),
+		"sql":       query,
+		"rows":      len(answer.Rows),
+		"truncated": answer.Truncated,
+	})
+	return answer, nil
+}
+
+// phraseQueryAnswer has the chat model answer question from the query result
+// in answer, with systemPrompt describing where the data came from
+func (s *AIService) phraseQueryAnswer(ctx context.Context, operation, systemPrompt string, link traceLink, question string, answer *FileAnswer) error {
+	ctx, cancel := s.operationContext(ctx, opChat)
+	defer cancel()
+
+	result, _ := json.Marshal(map[string]interface{}{
+		"columns":   answer.Columns,
+		"rows":      answer.Rows,
+		"truncated": answer.Truncated,
+	})
+	answer.Model = llm.GetModelName(s.Config, "chat")
+	resp, err := s.tracedChat(ctx, operation, link, llm.ChatRequest{
+		Model: answer.Model,
+		Messages: []llm.Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: fmt.Sprintf("Question: %s\n\nSQL:\n%s\n\nResult:\n%s", question, answer.SQL, result)},
+		},
+		Stream:  false,
+		Options: s.samplingOptions(0.2, 0.9),
+	})
+	if err != nil {
+		return s.aiCallError(ctx, opChat, err)
+	}
+	answer.Answer = strings.TrimSpace(resp.Message.Content)
+	return nil
+}
+
+// fileTable is one file loaded into a workspace
+type fileTable struct {
+	path       string
+	name       string
+	modTime    time.Time
+	columns    []string
+	types      []string
+	sampleRows [][]string
+	rowCount   int
+	truncated  bool
+}
+
+// has reports whether the table has a column
+func (t *fileTable) has(column string) bool {
+	for _, c := range t.columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// fileWorkspace is a set of files loaded as tables of one in-memory SQLite
+// database, so a query can join them. refs and evicted are guarded by the
+// cache's mutex: a workspace dropped from the cache while in use is closed
+// by its last release.
+type fileWorkspace struct {
+	db       *sql.DB
+	tables   []*fileTable
+	lastUsed time.Time
+	refs     int
+	evicted  bool
+}
+
+// schema describes the workspace tables, and the columns they likely join
+// on, for the SQL generator
+func (w *fileWorkspace) schema() string {
+	var b strings.Builder
+	for _, t := range w.tables {
+		defs := make([]string, len(t.columns))
+		for i, column := range t.columns {
+			defs[i] = fmt.Sprintf("  %q %s", column, t.types[i])
+		}
+		fmt.Fprintf(&b, "CREATE TABLE %s (\n%s\n);\n", t.name, strings.Join(defs, ",\n"))
+		fmt.Fprintf(&b, "-- From %s: %d rows", filepath.Base(t.path), t.rowCount)
+		if t.truncated {
+			fmt.Fprintf(&b, " (file truncated to the first %d)", fileTableMaxRows)
+		}
+		b.WriteString("\n")
+		if len(t.sampleRows) > 0 {
+			b.WriteString("-- Sample rows:\n")
+			for _, row := range t.sampleRows {
+				fmt.Fprintf(&b, "-- %s\n", strings.Join(row, " | "))
+			}
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("-- Dates are stored as TEXT; use strftime() to group by year or month\n")
+	if hints := joinKeyHints(w.tables); len(hints) > 0 {
+		b.WriteString("-- Likely join keys:\n")
+		for _, hint := range hints {
+			fmt.Fprintf(&b, "-- %s\n", hint)
+		}
+	}
+	return b.String()
+}
+
+// tableNames maps each file name to its table
+func (w *fileWorkspace) tableNames() map[string]string {
+	names := make(map[string]string, len(w.tables))
+	for _, t := range w.tables {
+		names[filepath.Base(t.path)] = t.name
+	}
+	return names
+}
+
+// query runs a read-only query and reads back at most fileQueryRowLimit rows
+func (w *fileWorkspace) query(ctx context.Context, query string) ([]string, [][]interface{}, bool, error) {
+	return queryRows(ctx, w.db, query, fileQueryRowLimit)
+}
+
+// queryRows runs a query and reads back at most limit rows, reporting whether
+// more were left
+func queryRows(ctx context.Context, db *sql.DB, query string, limit int) ([]string, [][]interface{}, bool, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	result := [][]interface{}{}
+	for rows.Next() {
+		if len(result) == limit {
+			return columns, result, true, nil
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, false, err
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result = append(result, values)
+	}
+	return columns, result, false, rows.Err()
+}
+
+// fileWorkspaceCache keeps recently queried workspaces loaded, reloading one
+// when any of its files changes on disk
+type fileWorkspaceCache struct {
+	mu         sync.Mutex
+	workspaces map[string]*fileWorkspace
+}
+
+// newFileWorkspaceCache creates an empty cache
+func newFileWorkspaceCache() *fileWorkspaceCache {
+	return &fileWorkspaceCache{workspaces: make(map[string]*fileWorkspace)}
+}
+
+// load returns the workspace for a set of files, loading it on first use.
+// The same files in any order share a workspace. The caller must release it.
+func (c *fileWorkspaceCache) load(paths []string) (*fileWorkspace, error) {
+	sorted := append([]string(nil), paths...)
+	sort.Strings(sorted)
+	modTimes := make(map[string]time.Time, len(sorted))
+	for _, path := range sorted {
+		info, err := os.Stat(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, classErrorf(ErrNotFound, "file not found: %s", filepath.Base(path))
+			}
+			return nil, fmt.Errorf("failed to stat file: %w", err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+	key := strings.Join(sorted, "\n")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if workspace, ok := c.workspaces[key]; ok {
+		fresh := true
+		for _, t := range workspace.tables {
+			fresh = fresh && t.modTime.Equal(modTimes[t.path])
+		}
+		if fresh {
+			workspace.lastUsed = time.Now()
+			workspace.refs++
+			return workspace, nil
+		}
+		c.evict(key)
+	}
+
+	workspace, err := loadFileWorkspace(sorted, modTimes)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.workspaces) >= fileWorkspaceCacheSize {
+		var oldest string
+		for key, cached := range c.workspaces {
+			if oldest == "" || cached.lastUsed.Before(c.workspaces[oldest].lastUsed) {
+				oldest = key
+			}
+		}
+		c.evict(oldest)
+	}
+	workspace.refs++
+	c.workspaces[key] = workspace
+	return workspace, nil
+}
+
+// evict drops a workspace from the cache, closing it unless a query still
+// holds it. Callers hold c.mu.
+func (c *fileWorkspaceCache) evict(key string) {
+	workspace := c.workspaces[key]
+	delete(c.workspaces, key)
+	workspace.evicted = true
+	if workspace.refs == 0 {
+		workspace.db.Close()
+	}
+}
+
+// release gives back a workspace from load, closing it when it was evicted
+// while in use
+func (c *fileWorkspaceCache) release(workspace *fileWorkspace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	workspace.refs--
+	if workspace.evicted && workspace.refs == 0 {
+		workspace.db.Close()
+	}
+}
+
+// loadFileWorkspace reads each file into a table of a new in-memory database,
+// naming the tables after the files
+func loadFileWorkspace(paths []string, modTimes map[string]time.Time) (*fileWorkspace, error) {
+	// Each connection to :memory: is a separate database, so keep one
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	workspace := &fileWorkspace{db: db, lastUsed: time.Now()}
+	used := make(map[string]int)
+	for _, path := range paths {
+		table, records, err := readFileTable(path)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		table.name = uniqueIdentifier(fileTableName(path), used)
+		table.modTime = modTimes[path]
+		if err := table.insert(db, records); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to load %s: %w", filepath.Base(path), err)
+		}
+		workspace.tables = append(workspace.tables, table)
+
+		logger.LogInfo(logger.ServiceAI, "File loaded for SQL questions", map[string]interface{}{
+			"file":      filepath.Base(path),
+			"table":     table.name,
+			"columns":   len(table.columns),
+			"rows":      table.rowCount,
+			"truncated": table.truncated,
+		})
+	}
+	return workspace, nil
+}
+
+// readFileTable parses a CSV or TSV file. Column types are inferred from the
+// first rows; values that do not fit are kept as text, which SQLite allows.
+func readFileTable(path string) (*fileTable, [][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		reader.Comma = '\t'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, classErrorf(ErrValidation, "failed to read header of %s: %w", filepath.Base(path), err)
+	}
+	table := &fileTable{path: path, columns: fileColumnNames(header)}
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, classErrorf(ErrValidation, "failed to parse %s: %w", filepath.Base(path), err)
+		}
+		if len(records) == fileTableMaxRows {
+			table.truncated = true
+			break
+		}
+		records = append(records, record)
+	}
+	table.rowCount = len(records)
+	table.types = make([]string, len(table.columns))
+	for i := range table.columns {
+		table.types[i] = inferFileColumnType(records[:min(len(records), fileTableTypeSample)], i)
+	}
+	table.sampleRows = records[:min(len(records), fileTableSampleRows)]
+	return table, records, nil
+}
+
+// insert creates the table and loads the records in one transaction
+func (t *fileTable) insert(db *sql.DB, records [][]string) error {
+	defs := make([]string, len(t.columns))
+	marks := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		defs[i] = fmt.Sprintf("%q %s", column, t.types[i])
+		marks[i] = "?"
+	}
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE %q (%s)", t.name, strings.Join(defs, ", "))); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %q VALUES (%s)", t.name, strings.Join(marks, ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(t.columns))
+	for _, record := range records {
+		for i := range args {
+			args[i] = nil
+			if i < len(record) {
+				if value := strings.TrimSpace(record[i]); value != "" {
+					args[i] = value
+				}
+			}
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// joinKeyHints suggests join conditions between tables: key-like columns
+// with the same name, and <table>_id columns that point at a table's id
+func joinKeyHints(tables []*fileTable) []string {
+	var hints []string
+	for i, a := range tables {
+		for j, b := range tables {
+			if i == j {
+				continue
+			}
+			for _, column := range a.columns {
+				switch {
+				case i < j && isKeyColumn(column) && b.has(column):
+					hints = append(hints, fmt.Sprintf("%s.%s = %s.%s", a.name, column, b.name, column))
+				case b.has("id") && (column == b.name+"_id" || column == strings.TrimSuffix(b.name, "s")+"_id"):
+					hints = append(hints, fmt.Sprintf("%s.%s = %s.id", a.name, column, b.name))
+				}
+				if len(hints) == fileJoinHintLimit {
+					return hints
+				}
+			}
+		}
+	}
+	return hints
+}
+
+// isKeyColumn reports whether a column name looks like an identifier or code
+func isKeyColumn(column string) bool {
+	if column == "id" || column == "code" || column == "key" {
+		return true
+	}
+	for _, suffix := range []string{"_id", "_code", "_key", "_no", "_number"} {
+		if strings.HasSuffix(column, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileTableName names a file's table after the file, without the upload
+// timestamp and extension: 20240101_120000_site-list.csv is site_list
+func fileTableName(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name := fileIdentifier(uploadPrefixRe.ReplaceAllString(base, ""))
+	switch {
+	case name == "":
+		return "data"
+	case name[0] >= '0' && name[0] <= '9':
+		return "t_" + name
+	}
+	return name
+}
+
+// fileColumnNames turns a header row into unique, lower-case identifiers
+func fileColumnNames(header []string) []string {
+	names := make([]string, len(header))
+	used := make(map[string]int)
+	for i, raw := range header {
+		name := fileIdentifier(raw)
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		} else if name[0] >= '0' && name[0] <= '9' {
+			name = "col_" + name
+		}
+		names[i] = uniqueIdentifier(name, used)
+	}
+	return names
+}
+
+// fileIdentifier lower-cases a name and keeps letters, digits and
+// underscores, turning common separators into underscores
+func fileIdentifier(raw string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(raw)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		case r == ' ', r == '-', r == '.', r == '/':
+			b.WriteByte('_')
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// uniqueIdentifier suffixes repeated names with _2, _3 and so on
+func uniqueIdentifier(name string, used map[string]int) string {
+	if used[name]++; used[name] > 1 {
+		return fmt.Sprintf("%s_%d", name, used[name])
+	}
+	return name
+}
+
+// inferFileColumnType picks INTEGER, REAL or TEXT for a column from sample rows
+func inferFileColumnType(rows [][]string, column int) string {
+	kind := ""
+	for _, row := range rows {
+		if column >= len(row) {
+			continue
+		}
+		value := strings.TrimSpace(row[column])
+		if value == "" {
+			continue
+		}
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			if kind == "" {
+				kind = "INTEGER"
+			}
+			continue
+		}
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			kind = "REAL"
+			continue
+		}
+		return "TEXT"
+	}
+	if kind == "" {
+		return "TEXT"
+	}
+	return kind
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrGlossaryTermNotFound is returned when deleting an unknown term
+	ErrGlossaryTermNotFound = classErrorf(ErrNotFound, "glossary term not found")
+	// ErrInvalidGlossaryTerm is returned for terms without a target or with
+	// synonyms that already belong to another term
+	ErrInvalidGlossaryTerm = classErrorf(ErrValidation, "invalid glossary term")
+)
+
+// ListGlossary returns a datasource's business glossary
+func (s *DatasourceService) ListGlossary(datasourceID string) ([]store.GlossaryTerm, error) {
+	var terms []store.GlossaryTerm
+	if err := s.db.Where("datasource_id = ?", datasourceID).Order("term").Find(&terms).Error; err != nil {
+		return nil, fmt.Errorf("failed to list glossary: %w", err)
+	}
+	return terms, nil
+}
+
+// UpsertGlossary adds or replaces glossary terms. Terms and synonyms are
+// case-insensitive and must resolve to exactly one term per datasource.
+func (s *DatasourceService) UpsertGlossary(datasourceID string, reqs []store.GlossaryTermRequest) ([]store.GlossaryTerm, error) {
+	existing, err := s.ListGlossary(datasourceID)
+	if err != nil {
+		return nil, err
+	}
+	owner := map[string]string{} // term or synonym -> term
+	for _, t := range existing {
+		for _, name := range glossaryNames(t) {
+			owner[name] = t.Term
+		}
+	}
+
+	now := time.Now()
+	terms := make([]store.GlossaryTerm, 0, len(reqs))
+	for _, req := range reqs {
+		term := normalizeGlossaryName(req.Term)
+		if term == "" {
+			return nil, fmt.Errorf("%w: term is required", ErrInvalidGlossaryTerm)
+		}
+		if strings.TrimSpace(req.Object) == "" && strings.TrimSpace(req.Expression) == "" {
+			return nil, fmt.Errorf("%w: %q needs an object or expression", ErrInvalidGlossaryTerm, term)
+		}
+
+		var synonyms []string
+		for _, name := range append([]string{term}, req.Synonyms...) {
+			name = normalizeGlossaryName(name)
+			if name == "" {
+				continue
+			}
+			if other, ok := owner[name]; ok && other != term {
+				return nil, fmt.Errorf("%w: %q already refers to %q", ErrInvalidGlossaryTerm, name, other)
+			}
+			owner[name] = term
+			if name != term {
+				synonyms = append(synonyms, name)
+			}
+		}
+
+		terms = append(terms, store.GlossaryTerm{
+			DatasourceID: datasourceID,
+			Term:         term,
+			Synonyms:     strings.Join(synonyms, ","),
+			Object:       strings.TrimSpace(req.Object),
+			Expression:   strings.TrimSpace(req.Expression),
+			Description:  strings.TrimSpace(req.Description),
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		})
+	}
+	if len(terms) == 0 {
+		return existing, nil
+	}
+
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "datasource_id"}, {Name: "term"}},
+		DoUpdates: clause.AssignmentColumns([]string{"synonyms", "object", "expression", "description", "updated_at"}),
+	}).Create(&terms).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save glossary: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Glossary updated", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"count":         len(terms),
+	})
+	return s.ListGlossary(datasourceID)
+}
+
+// DeleteGlossaryTerm removes one term from a datasource's glossary
+func (s *DatasourceService) DeleteGlossaryTerm(datasourceID string, id uint) error {
+	res := s.db.Where("datasource_id = ? AND id = ?", datasourceID, id).Delete(&store.GlossaryTerm{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete glossary term: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrGlossaryTermNotFound
+	}
+	return nil
+}
+
+func normalizeGlossaryName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// glossaryNames returns a term and its synonyms
+func glossaryNames(t store.GlossaryTerm) []string {
+	names := []string{t.Term}
+	if t.Synonyms != "" {
+		names = append(names, strings.Split(t.Synonyms, ",")...)
+	}
+	return names
+}
+
+// glossaryFor loads a datasource's glossary for IR building
+func glossaryFor(db *gorm.DB, datasourceID string) []store.GlossaryTerm {
+	var terms []store.GlossaryTerm
+	if err := db.Where("datasource_id = ?", datasourceID).Order("term").Find(&terms).Error; err != nil {
+		logger.LogWarn(logger.ServiceAI, "Failed to load glossary", map[string]interface{}{
+			"datasource_id": datasourceID,
+			"error":         err.Error(),
+		})
+		return nil
+	}
+	return terms
+}
+
+// glossaryMarkdown renders the glossary as binding prompt context
+func glossaryMarkdown(terms []store.GlossaryTerm) string {
+	if len(terms) == 0 {
+		return ""
+	}
+	var md strings.Builder
+	md.WriteString("Business glossary (when the scope uses a term or synonym, use exactly the mapped table or expression):\n")
+	for _, t := range terms {
+		var target []string
+		if t.Object != "" {
+			target = append(target, "table "+t.Object)
+		}
+		if t.Expression != "" {
+			target = append(target, "expression "+t.Expression)
+		}
+		line := fmt.Sprintf("- %s", t.Term)
+		if t.Synonyms != "" {
+			line += fmt.Sprintf(" (also: %s)", strings.ReplaceAll(t.Synonyms, ",", ", "))
+		}
+		line += " -> " + strings.Join(target, ", ")
+		if t.Description != "" {
+			line += ": " + t.Description
+		}
+		md.WriteString(line + "\n")
+	}
+	return md.String()
+}
+
+// resolveGlossaryIR replaces IR field names that are glossary terms with their
+// mapped expressions, so a term resolves the same way regardless of what the
+// model chose. It returns the terms that were resolved.
+func resolveGlossaryIR(ir map[string]interface{}, terms []store.GlossaryTerm) map[string]string {
+	index := map[string]store.GlossaryTerm{}
+	for _, t := range terms {
+		for _, name := range glossaryNames(t) {
+			index[name] = t
+		}
+	}
+	resolved := map[string]string{}
+
+	// lookup maps a field to its expression; plainOnly limits results to
+	// column names for places that reject expressions (time-series fields)
+	lookup := func(field string, plainOnly bool) (string, bool) {
+		t, ok := index[normalizeGlossaryName(field)]
+		if !ok || t.Expression == "" || (plainOnly && !tsIdentRe.MatchString(t.Expression)) {
+			return "", false
+		}
+		resolved[t.Term] = t.Expression
+		return t.Expression, true
+	}
+
+	if dataset, ok := ir["dataset"].(string); ok {
+		if t, found := index[normalizeGlossaryName(dataset)]; found && t.Object != "" {
+			ir["dataset"] = t.Object
+			resolved[t.Term] = t.Object
+		}
+	}
+
+	if fields, ok := ir["select"].([]interface{}); ok {
+		for i, item := range fields {
+			switch v := item.(type) {
+			case string:
+				if expr, ok := lookup(v, false); ok {
+					fields[i] = map[string]interface{}{"field": expr, "as": strings.ReplaceAll(normalizeGlossaryName(v), " ", "_")}
+				}
+			case map[string]interface{}:
+				for key, val := range v {
+					field, isString := val.(string)
+					if !isString || (key != "field" && !isAggregateKey(key)) {
+						continue
+					}
+					expr, ok := lookup(field, false)
+					if !ok {
+						continue
+					}
+					if key == "field" {
+						v[key] = expr
+						continue
+					}
+					// {"sum": "revenue"} aliases by field name; keep the term as the alias
+					fields[i] = map[string]interface{}{"field": expr, "func": key, "as": key + "_" + strings.ReplaceAll(normalizeGlossaryName(field), " ", "_")}
+					break
+				}
+			}
+		}
+	}
+
+	for _, key := range []string{"filters", "order_by"} {
+		items, _ := ir[key].([]interface{})
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				if field, _ := m["field"].(string); field != "" {
+					if expr, ok := lookup(field, false); ok {
+						m["field"] = expr
+					}
+				}
+			}
+		}
+	}
+
+	if groupBy, ok := ir["group_by"].([]interface{}); ok {
+		_, seriesKeys := ir["timeseries"] // group_by doubles as the series keys
+		for i, item := range groupBy {
+			if field, ok := item.(string); ok {
+				if expr, ok := lookup(field, seriesKeys); ok {
+					groupBy[i] = expr
+				}
+			}
+		}
+	}
+
+	if ts, ok := ir["timeseries"].(map[string]interface{}); ok {
+		if field, _ := ts["time_field"].(string); field != "" {
+			if expr, ok := lookup(field, true); ok {
+				ts["time_field"] = expr
+			}
+		}
+		metrics, _ := ts["metrics"].([]interface{})
+		for _, item := range metrics {
+			if m, ok := item.(map[string]interface{}); ok {
+				if field, _ := m["field"].(string); field != "" {
+					if expr, ok := lookup(field, true); ok {
+						m["field"] = expr
+					}
+				}
+			}
+		}
+		partitions, _ := ts["partition_by"].([]interface{})
+		for i, item := range partitions {
+			if field, ok := item.(string); ok {
+				if expr, ok := lookup(field, true); ok {
+					partitions[i] = expr
+				}
+			}
+		}
+	}
+
+	return resolved
+}
+
+func isAggregateKey(key string) bool {
+	switch key {
+	case "sum", "avg", "count", "max", "min":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NubeDev/air/internal/graphql"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// GraphQLService answers read-only GraphQL queries over reports, versions,
+// runs, scopes, schema notes and analyses, so a screen can fetch the nested
+// data it needs in one round trip
+type GraphQLService struct {
+	db     *gorm.DB
+	schema *graphql.Schema
+}
+
+// NewGraphQLService creates a GraphQL service over the control plane database
+func NewGraphQLService(db *gorm.DB) *GraphQLService {
+	s := &GraphQLService{db: db}
+	s.schema = s.buildSchema()
+	return s
+}
+
+// Execute runs a query; field errors are reported in the response
+func (s *GraphQLService) Execute(ctx context.Context, req graphql.Request) *graphql.Response {
+	return s.schema.Execute(ctx, req)
+}
+
+// source returns the model a resolver was called on, whether the parent
+// resolved to a value or a pointer
+func source[T any](parent interface{}) *T {
+	switch v := parent.(type) {
+	case T:
+		return &v
+	case *T:
+		return v
+	}
+	return nil
+}
+
+// limitArg reads the limit argument of list fields
+func limitArg(args map[string]interface{}) (int, error) {
+	limit, err := graphql.IntArg(args, "limit", defaultPageLimit)
+	return PageLimit(limit), err
+}
+
+// first loads the row matching query, resolving to null when there is none
+func first[T any](db *gorm.DB, query interface{}, args ...interface{}) (interface{}, error) {
+	var row T
+	if err := db.Where(query, args...).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &row, nil
+}
+
+// find loads up to the limit argument of rows, filtered by the string
+// arguments named in filters (argument name to column)
+func find[T any](db *gorm.DB, args map[string]interface{}, order string, filters map[string]string, query interface{}, values ...interface{}) (interface{}, error) {
+	limit, err := limitArg(args)
+	if err != nil {
+		return nil, err
+	}
+	q := db.Order(order).Limit(limit)
+	if query != nil {
+		q = q.Where(query, values...)
+	}
+	for name, column := range filters {
+		value, ok, err := graphql.StringArg(args, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			q = q.Where(column+" = ?", value)
+		}
+	}
+	var rows []T
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (s *GraphQLService) buildSchema() *graphql.Schema {
+	report := &graphql.Object{Name: "Report", Fields: graphql.StructFields(store.Report{})}
+	reportVersion := &graphql.Object{Name: "ReportVersion", Fields: graphql.StructFields(store.ReportVersion{})}
+	run := &graphql.Object{Name: "ReportRun", Fields: graphql.StructFields(store.ReportRun{})}
+	analysis := &graphql.Object{Name: "ReportAnalysis", Fields: graphql.StructFields(store.ReportAnalysis{})}
+	scope := &graphql.Object{Name: "Scope", Fields: graphql.StructFields(store.Scope{})}
+	scopeVersion := &graphql.Object{Name: "ScopeVersion", Fields: graphql.StructFields(store.ScopeVersion{})}
+	schemaNote := &graphql.Object{Name: "SchemaNote", Fields: graphql.StructFields(store.SchemaNote{})}
+
+	db := func(ctx context.Context) *gorm.DB { return s.db.WithContext(ctx) }
+
+	report.Fields["versions"] = &graphql.Field{
+		Type: reportVersion,
+		Args: []string{"limit", "status"},
+		Resolve: func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error) {
+			r := source[store.Report](parent)
+			return find[store.ReportVersion](db(ctx), args, "version DESC", map[string]string{"status": "status"}, "report_id = ?", r.ID)
+		},
+	}
+	report.Fields["latestVersion"] = &graphql.Field{
+		Type: reportVersion,
+		Resolve: func(ctx context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			r := source[store.Report](parent)
+			var version store.ReportVersion
+			err := db(ctx).Scopes(approvedVersions).Where("report_id = ?", r.ID).Order("version DESC").Limit(1).Find(&version).Error
+			if err != nil || version.ID == 0 {
+				return nil, err
+			}
+			return &version, nil
+		},
+	}
+	report.Fields["runs"] = &graphql.Field{
+		Type: run,
+		Args: []string{"limit", "status", "datasourceId"},
+		Resolve: func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error) {
+			r := source[store.Report](parent)
+			return find[store.ReportRun](db(ctx), args, "started_at DESC", map[string]string{"status": "status", "datasourceId": "datasource_id"}, "report_id = ?", r.ID)
+		},
+	}
+
+	reportVersion.Fields["report"] = &graphql.Field{
+		Type: report,
+		Resolve: func(ctx context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return first[store.Report](db(ctx), "id = ?", source[store.ReportVersion](parent).ReportID)
+		},
+	}
+	reportVersion.Fields["scopeVersion"] = &graphql.Field{
+		Type: scopeVersion,
+		Resolve: func(ctx context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			v := source[store.ReportVersion](parent)
+			if v.ScopeVersionID == nil {
+				return nil, nil
+			}
+			return first[store.ScopeVersion](db(ctx), "id = ?", *v.ScopeVersionID)
+		},
+	}
+	reportVersion.Fields["runs"] = &graphql.Field{
+		Type: run,
+		Args: []string{"limit", "status"},
+		Resolve: func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error) {
+			v := source[store.ReportVersion](parent)
+			return find[store.ReportRun](db(ctx), args, "started_at DESC", map[string]string{"status": "status"}, "report_version_id = ?", v.ID)
+		},
+	}
+
+	run.Fields["report"] = &graphql.Field{
+		Type: report,
+		Resolve: func(ctx context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return first[store.Report](db(ctx), "id = ?", source[store.ReportRun](parent).ReportID)
+		},
+	}
+	run.Fields["reportVersion"] = &graphql.Field{
+		Type: reportVersion,
+		Resolve: func(ctx context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return first[store.ReportVersion](db(ctx), "id = ?", source[store.ReportRun](parent).ReportVersionID)
+		},
+	}
+	run.Fields["analyses"] = &graphql.Field{
+		Type: analysis,
+		Args: []string{"limit"},
+		Resolve: func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error) {
+			r := source[store.ReportRun](parent)
+			return find[store.ReportAnalysis](db(ctx), args, "created_at DESC", nil, "run_id = ?", r.ID)
+		},
+	}
+
+	analysis.Fields["run"] = &graphql.Field{
+		Type: run,
+		Resolve: func(ctx context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return first[store.ReportRun](db(ctx), "id = ?", source[store.ReportAnalysis](parent).RunID)
+		},
+	}
+
+	scope.Fields["versions"] = &graphql.Field{
+		Type: scopeVersion,
+		Args: []string{"limit"},
+		Resolve: func(ctx context.Context, parent interface{}, args map[string]interface{}) (interface{}, error) {
+			sc := source[store.Scope](parent)
+			return find[store.ScopeVersion](db(ctx), args, "version DESC", nil, "scope_id = ?", sc.ID)
+		},
+	}
+	scopeVersion.Fields["scope"] = &graphql.Field{
+		Type: scope,
+		Resolve: func(ctx context.Context, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+			return first[store.Scope](db(ctx), "id = ?", source[store.ScopeVersion](parent).ScopeID)
+		},
+	}
+
+	byID := func(args map[string]interface{}) (int, error) {
+		id, err := graphql.IntArg(args, "id", 0)
+		if err == nil && id <= 0 {
+			err = fmt.Errorf("argument \"id\" is required")
+		}
+		return id, err
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"reports": {
+			Type: report,
+			Args: []string{"limit", "folder", "owner"},
+			Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				return find[store.Report](db(ctx), args, "created_at DESC", map[string]string{"folder": "folder", "owner": "owner"}, nil)
+			},
+		},
+		"report": {
+			Type: report,
+			Args: []string{"id", "key"},
+			Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				if key, ok, err := graphql.StringArg(args, "key"); err != nil || ok {
+					if err != nil {
+						return nil, err
+					}
+					return first[store.Report](db(ctx), "key = ?", key)
+				}
+				id, err := byID(args)
+				if err != nil {
+					return nil, fmt.Errorf("report needs an id or key argument")
+				}
+				return first[store.Report](db(ctx), "id = ?", id)
+			},
+		},
+		"run": {
+			Type: run,
+			Args: []string{"id"},
+			Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				id, err := byID(args)
+				if err != nil {
+					return nil, err
+				}
+				return first[store.ReportRun](db(ctx), "id = ?", id)
+			},
+		},
+		"analysis": {
+			Type: analysis,
+			Args: []string{"id"},
+			Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				id, err := byID(args)
+				if err != nil {
+					return nil, err
+				}
+				return first[store.ReportAnalysis](db(ctx), "id = ?", id)
+			},
+		},
+		"scopes": {
+			Type: scope,
+			Args: []string{"limit", "status"},
+			Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				return find[store.Scope](db(ctx), args, "created_at DESC", map[string]string{"status": "status"}, nil)
+			},
+		},
+		"scope": {
+			Type: scope,
+			Args: []string{"id"},
+			Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				id, err := byID(args)
+				if err != nil {
+					return nil, err
+				}
+				return first[store.Scope](db(ctx), "id = ?", id)
+			},
+		},
+		"schemaNotes": {
+			Type: schemaNote,
+			Args: []string{"datasourceId", "object", "limit"},
+			Resolve: func(ctx context.Context, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				datasourceID, ok, err := graphql.StringArg(args, "datasourceId")
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					return nil, fmt.Errorf("argument \"datasourceId\" is required")
+				}
+				return find[store.SchemaNote](db(ctx), args, "object, chunk", map[string]string{"object": "object"}, "datasource_id = ?", datasourceID)
+			},
+		},
+	}}
+
+	return &graphql.Schema{Query: query}
+}
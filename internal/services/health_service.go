@@ -25,6 +25,7 @@ func (s *HealthService) GetHealthStatus() store.HealthResponse {
 	return store.HealthResponse{
 		Status:      "healthy",
 		AuthEnabled: s.config.Server.Auth.Enabled,
+		LocalOnly:   s.config.Privacy.LocalOnly,
 		Datasources: len(s.registry.ListDatasources()),
 	}
 }
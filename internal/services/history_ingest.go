@@ -0,0 +1,457 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/histories"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrHistorySourceNotFound is returned for names missing from ingestion.histories
+	ErrHistorySourceNotFound = classErrorf(ErrNotFound, "history source not found")
+	// ErrHistorySourceBusy is returned when a source is already being ingested
+	ErrHistorySourceBusy = classErrorf(ErrConflict, "history source ingestion already running")
+)
+
+// historyPointTimeout bounds reading and writing one point's history
+const historyPointTimeout = 5 * time.Minute
+
+// historySource is a configured history endpoint with its reader
+type historySource struct {
+	config config.HistorySourceConfig
+	reader histories.Reader
+}
+
+// HistoryIngestService copies Niagara/Haystack point histories into an analytics
+// source on a schedule and describes the target table in schema notes
+type HistoryIngestService struct {
+	db           *gorm.DB
+	registry     *datasource.Registry
+	pollInterval time.Duration
+	sources      []*historySource
+
+	mu      sync.Mutex
+	running map[string]bool
+	lastRun map[string]time.Time
+
+	locks *redis.Client
+}
+
+// NewHistoryIngestService creates a new history ingestion service
+func NewHistoryIngestService(registry *datasource.Registry, db *gorm.DB, cfg *config.Config) (*HistoryIngestService, error) {
+	s := &HistoryIngestService{
+		db:           db,
+		registry:     registry,
+		pollInterval: cfg.Ingestion.PollInterval,
+		running:      make(map[string]bool),
+		lastRun:      make(map[string]time.Time),
+	}
+
+	for _, sc := range cfg.Ingestion.Histories {
+		if sc.Table == "" {
+			sc.Table = "histories"
+		}
+		if sc.Interval <= 0 {
+			sc.Interval = 15 * time.Minute
+		}
+		if sc.Backfill <= 0 {
+			sc.Backfill = 7 * 24 * time.Hour
+		}
+		reader, err := histories.NewReader(sc)
+		if err != nil {
+			return nil, fmt.Errorf("history source %s: %w", sc.Name, err)
+		}
+		s.sources = append(s.sources, &historySource{config: sc, reader: reader})
+	}
+	return s, nil
+}
+
+// SetLocks makes each source ingest on one API replica at a time
+func (s *HistoryIngestService) SetLocks(locks *redis.Client) {
+	s.locks = locks
+}
+
+// Start ingests every source whose interval has elapsed, checking each poll interval
+func (s *HistoryIngestService) Start() {
+	if len(s.sources) == 0 {
+		return
+	}
+
+	logger.LogInfo(logger.ServiceDB, "History ingestion started", map[string]interface{}{
+		"sources":       len(s.sources),
+		"poll_interval": s.pollInterval.String(),
+	})
+
+	go func() {
+		ticker := time.NewTicker(s.pollInterval)
+		defer ticker.Stop()
+		for {
+			s.runDue()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *HistoryIngestService) runDue() {
+	for _, src := range s.sources {
+		s.mu.Lock()
+		last, ran := s.lastRun[src.config.Name]
+		s.mu.Unlock()
+		if ran && time.Since(last) < src.config.Interval {
+			continue
+		}
+
+		result, err := s.ingest(src)
+		if err != nil {
+			if !errors.Is(err, ErrHistorySourceBusy) {
+				logger.LogWarn(logger.ServiceDB, "History ingestion failed", map[string]interface{}{
+					"source": src.config.Name,
+					"error":  err.Error(),
+				})
+			}
+			continue
+		}
+		if result.Failed > 0 {
+			logger.LogWarn(logger.ServiceDB, "History ingestion finished with failed points", map[string]interface{}{
+				"source": src.config.Name,
+				"failed": result.Failed,
+				"errors": result.Errors,
+			})
+		}
+	}
+}
+
+// RunSource ingests one source immediately
+func (s *HistoryIngestService) RunSource(name string) (*store.HistoryIngestResult, error) {
+	for _, src := range s.sources {
+		if src.config.Name == name {
+			return s.ingest(src)
+		}
+	}
+	return nil, ErrHistorySourceNotFound
+}
+
+// ListSources returns the configured sources with their per-point cursors
+func (s *HistoryIngestService) ListSources() ([]store.HistorySourceStatus, error) {
+	statuses := make([]store.HistorySourceStatus, 0, len(s.sources))
+	for _, src := range s.sources {
+		var cursors []store.HistoryCursor
+		if err := s.db.Where("source = ?", src.config.Name).Order("point_id ASC").Find(&cursors).Error; err != nil {
+			return nil, fmt.Errorf("failed to load history cursors: %w", err)
+		}
+
+		status := store.HistorySourceStatus{
+			Name:         src.config.Name,
+			Kind:         src.config.Kind,
+			URL:          src.config.URL,
+			DatasourceID: src.config.DatasourceID,
+			Table:        src.config.Table,
+			Interval:     src.config.Interval.String(),
+			Points:       cursors,
+		}
+		s.mu.Lock()
+		if last, ok := s.lastRun[src.config.Name]; ok {
+			status.LastRunAt = &last
+		}
+		status.Running = s.running[src.config.Name]
+		s.mu.Unlock()
+
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// ingest reads every point of a source and appends new samples to the target table.
+// A failing point is recorded on its cursor and does not stop the others.
+func (s *HistoryIngestService) ingest(src *historySource) (*store.HistoryIngestResult, error) {
+	name := src.config.Name
+	s.mu.Lock()
+	if s.running[name] {
+		s.mu.Unlock()
+		return nil, ErrHistorySourceBusy
+	}
+	s.running[name] = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		s.mu.Lock()
+		s.running[name] = false
+		s.lastRun[name] = start
+		s.mu.Unlock()
+	}()
+
+	var result *store.HistoryIngestResult
+	err := withJobLock(context.Background(), s.locks, "history:"+name, func(ctx context.Context, _ int64) error {
+		var err error
+		result, err = s.ingestLocked(ctx, src, start)
+		return err
+	})
+	if errors.Is(err, redis.ErrLockHeld) {
+		return nil, ErrHistorySourceBusy
+	}
+	return result, err
+}
+
+// ingestLocked does the ingestion while the source's job lock is held, and
+// stops between points if the lock is lost
+func (s *HistoryIngestService) ingestLocked(ctx context.Context, src *historySource, start time.Time) (*store.HistoryIngestResult, error) {
+	name := src.config.Name
+	target, err := s.registry.GetDatasource(src.config.DatasourceID)
+	if err != nil {
+		return nil, fmt.Errorf("target datasource not found: %w", err)
+	}
+	if err := target.Connected(); err != nil {
+		return nil, err
+	}
+	if err := ensureHistoryTable(target, src.config.Table); err != nil {
+		return nil, fmt.Errorf("failed to create history table: %w", err)
+	}
+
+	result := &store.HistoryIngestResult{Source: name, Points: len(src.config.Points), StartedAt: start}
+	for _, point := range src.config.Points {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		rows, err := s.ingestPoint(src, target, point, start)
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", point, err))
+			continue
+		}
+		result.Rows += rows
+	}
+	result.DurationMS = time.Since(start).Milliseconds()
+
+	if err := s.registerSchemaNote(target, src.config.Table); err != nil {
+		logger.LogWarn(logger.ServiceDB, "Failed to register history schema note", map[string]interface{}{
+			"source": name,
+			"error":  err.Error(),
+		})
+	}
+
+	logger.LogInfo(logger.ServiceDB, "History ingestion finished", map[string]interface{}{
+		"source":   name,
+		"points":   result.Points,
+		"rows":     result.Rows,
+		"failed":   result.Failed,
+		"duration": time.Since(start).String(),
+	})
+	return result, nil
+}
+
+// ingestPoint reads a point from its cursor (or the backfill window) up to now and
+// writes samples newer than the cursor
+func (s *HistoryIngestService) ingestPoint(src *historySource, target *datasource.DatasourceConnector, point string, now time.Time) (int, error) {
+	cursor := store.HistoryCursor{Source: src.config.Name, PointID: point}
+	if err := s.db.Where(&cursor).FirstOrCreate(&cursor).Error; err != nil {
+		return 0, fmt.Errorf("failed to load cursor: %w", err)
+	}
+
+	from := now.Add(-src.config.Backfill)
+	if cursor.LastTS != nil {
+		from = *cursor.LastTS
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), historyPointTimeout)
+	defer cancel()
+
+	samples, err := src.reader.Read(ctx, point, from, now)
+	if err == nil {
+		if cursor.LastTS != nil {
+			samples = samplesAfter(samples, *cursor.LastTS) // ranges are inclusive of the cursor sample
+		}
+		err = insertHistorySamples(ctx, target, src.config.Table, src.config.Name, point, samples)
+	}
+
+	updates := map[string]interface{}{"last_run_at": now}
+	if err != nil {
+		updates["status"] = "failed"
+		updates["error_text"] = err.Error()
+	} else {
+		updates["status"] = "ok"
+		updates["error_text"] = ""
+		if len(samples) > 0 {
+			first, last := samples[0].Time, samples[0].Time
+			for _, sample := range samples {
+				if sample.Time.Before(first) {
+					first = sample.Time
+				}
+				if sample.Time.After(last) {
+					last = sample.Time
+				}
+				if sample.Unit != "" {
+					updates["unit"] = sample.Unit
+				}
+			}
+			if cursor.FirstTS == nil {
+				updates["first_ts"] = first
+			}
+			updates["last_ts"] = last
+			updates["row_count"] = cursor.RowCount + int64(len(samples))
+		}
+	}
+	if uerr := s.db.Model(&cursor).Updates(updates).Error; uerr != nil && err == nil {
+		err = fmt.Errorf("failed to update cursor: %w", uerr)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(samples), nil
+}
+
+func samplesAfter(samples []histories.Sample, after time.Time) []histories.Sample {
+	out := samples[:0]
+	for _, sample := range samples {
+		if sample.Time.After(after) {
+			out = append(out, sample)
+		}
+	}
+	return out
+}
+
+// ensureHistoryTable creates the long-format history table and its point/time index
+func ensureHistoryTable(target *datasource.DatasourceConnector, table string) error {
+	kind := strings.ToLower(target.Kind)
+	quoted := datasource.QuoteIdent(kind, table)
+	index := datasource.QuoteIdent(kind, table+"_point_ts")
+
+	columns := fmt.Sprintf("source VARCHAR(255) NOT NULL, point_id VARCHAR(255) NOT NULL, ts %s NOT NULL, value %s, value_text VARCHAR(255), unit VARCHAR(64)",
+		snapshotColumnType(kind, time.Time{}), snapshotColumnType(kind, float64(0)))
+
+	if kind == "mysql" {
+		_, err := target.DB.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, INDEX %s (point_id, ts))", quoted, columns, index))
+		return err
+	}
+	if _, err := target.DB.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoted, columns)); err != nil {
+		return err
+	}
+	_, err := target.DB.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (point_id, ts)", index, quoted))
+	return err
+}
+
+// insertHistorySamples appends samples for one point in a single transaction
+func insertHistorySamples(ctx context.Context, target *datasource.DatasourceConnector, table, source, point string, samples []histories.Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	kind := strings.ToLower(target.Kind)
+
+	placeholders := "?, ?, ?, ?, ?, ?"
+	if kind == "postgres" || kind == "timescaledb" {
+		placeholders = "$1, $2, $3, $4, $5, $6"
+	}
+
+	tx, err := target.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (source, point_id, ts, value, value_text, unit) VALUES (%s)",
+		datasource.QuoteIdent(kind, table), placeholders))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, sample := range samples {
+		var value interface{}
+		if sample.Value != nil {
+			value = *sample.Value
+		}
+		if _, err := insert.ExecContext(ctx, source, point, sample.Time.UTC(), value, sample.Text, sample.Unit); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// registerSchemaNote describes a history table and the points it holds so the
+// table is usable for SQL generation without a separate learn. The note is only
+// rewritten when its content changes.
+func (s *HistoryIngestService) registerSchemaNote(target *datasource.DatasourceConnector, table string) error {
+	var sourceNames []string
+	for _, src := range s.sources {
+		if src.config.DatasourceID == target.ID && src.config.Table == table {
+			sourceNames = append(sourceNames, src.config.Name)
+		}
+	}
+	var cursors []store.HistoryCursor
+	if err := s.db.Where("source IN ?", sourceNames).Order("source ASC, point_id ASC").Find(&cursors).Error; err != nil {
+		return err
+	}
+
+	md := historyTableMarkdown(table, cursors)
+	hash := fmt.Sprintf("%x", md5.Sum([]byte(md)))
+
+	var existing []store.SchemaNote
+	if err := s.db.Where("datasource_id = ? AND object = ?", target.ID, table).Find(&existing).Error; err != nil {
+		return err
+	}
+	if len(existing) == 1 && existing[0].MDHash == hash {
+		return nil
+	}
+
+	notes := []store.SchemaNote{{
+		DatasourceID: target.ID,
+		Object:       table,
+		Chunk:        0,
+		MD:           md,
+		MDHash:       hash,
+		CreatedAt:    time.Now(),
+	}}
+	carryCuration(s.db, target.ID, notes)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("datasource_id = ? AND object = ?", target.ID, table).Delete(&store.SchemaNote{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&notes[0]).Error
+	})
+}
+
+// historyTableMarkdown renders the schema note for a history table. Sample times
+// are left out so the note stays stable between runs.
+func historyTableMarkdown(table string, cursors []store.HistoryCursor) string {
+	var md strings.Builder
+
+	md.WriteString(fmt.Sprintf("# Table: %s\n\n", table))
+	md.WriteString("Building telemetry histories ingested from Niagara/Haystack. One row per point sample; ")
+	md.WriteString("filter by point_id and aggregate value over ts for trends.\n\n")
+
+	md.WriteString("| Column | Type | Description |\n")
+	md.WriteString("|--------|------|-------------|\n")
+	md.WriteString("| source | text | Ingestion source name |\n")
+	md.WriteString("| point_id | text | Haystack point id or Niagara history name |\n")
+	md.WriteString("| ts | timestamp | Sample time (UTC) |\n")
+	md.WriteString("| value | float | Numeric value; booleans are 0/1, NULL for enums and strings |\n")
+	md.WriteString("| value_text | text | Raw value as reported by the source |\n")
+	md.WriteString("| unit | text | Engineering unit |\n\n")
+
+	sort.SliceStable(cursors, func(i, j int) bool { return cursors[i].PointID < cursors[j].PointID })
+	md.WriteString(fmt.Sprintf("**Points:** %d\n\n", len(cursors)))
+	md.WriteString("| point_id | source | unit |\n")
+	md.WriteString("|----------|--------|------|\n")
+	for _, c := range cursors {
+		unit := c.Unit
+		if unit == "" {
+			unit = "-"
+		}
+		md.WriteString(fmt.Sprintf("| %s | %s | %s |\n", c.PointID, c.Source, unit))
+	}
+
+	return md.String()
+}
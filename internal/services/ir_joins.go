@@ -0,0 +1,110 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// joinFieldRe matches the column references a join condition may use,
+// "column" or "table.column"
+var joinFieldRe = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)?$`)
+
+// irJoin is one entry of an IR's optional "joins" list
+type irJoin struct {
+	Dataset string
+	Type    string // "INNER" or "LEFT"
+	On      [][2]string
+}
+
+// parseIRJoins reads ir["joins"]:
+//
+//	[{"dataset": "sites", "type": "left", "on": [{"left": "readings.site_id", "right": "sites.id"}]}]
+//
+// Joins are only used when a question spans tables, such as several files
+// loaded into one workspace. Entries with unsafe names are rejected rather
+// than dropped so a half-applied join never changes the result silently.
+func parseIRJoins(ir map[string]interface{}) ([]irJoin, error) {
+	raw, ok := ir["joins"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, nil
+	}
+
+	joins := make([]irJoin, 0, len(raw))
+	for i, entry := range raw {
+		spec, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, classErrorf(ErrValidation, "joins[%d] must be an object", i)
+		}
+		join := irJoin{Type: "INNER"}
+		join.Dataset, _ = spec["dataset"].(string)
+		if !joinFieldRe.MatchString(join.Dataset) {
+			return nil, classErrorf(ErrValidation, "joins[%d].dataset is not a table name: %q", i, join.Dataset)
+		}
+		if kind, _ := spec["type"].(string); kind != "" {
+			switch strings.ToUpper(kind) {
+			case "INNER", "LEFT":
+				join.Type = strings.ToUpper(kind)
+			default:
+				return nil, classErrorf(ErrValidation, "joins[%d].type must be inner or left", i)
+			}
+		}
+
+		conditions, _ := spec["on"].([]interface{})
+		for j, condition := range conditions {
+			pair, _ := condition.(map[string]interface{})
+			left, _ := pair["left"].(string)
+			right, _ := pair["right"].(string)
+			if !joinFieldRe.MatchString(left) || !joinFieldRe.MatchString(right) {
+				return nil, classErrorf(ErrValidation, "joins[%d].on[%d] needs left and right column references", i, j)
+			}
+			join.On = append(join.On, [2]string{left, right})
+		}
+		if len(join.On) == 0 {
+			return nil, classErrorf(ErrValidation, "joins[%d] has no on conditions", i)
+		}
+		joins = append(joins, join)
+	}
+	return joins, nil
+}
+
+// buildJoinClause renders the IR joins to follow "FROM dataset". An invalid
+// joins list renders nothing here; buildSQLCoderPromptFromIR reports it.
+func buildJoinClause(ir map[string]interface{}) string {
+	joins, err := parseIRJoins(ir)
+	if err != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, join := range joins {
+		conditions := make([]string, len(join.On))
+		for i, on := range join.On {
+			conditions[i] = fmt.Sprintf("%s = %s", on[0], on[1])
+		}
+		fmt.Fprintf(&b, " %s JOIN %s ON %s", join.Type, join.Dataset, strings.Join(conditions, " AND "))
+	}
+	return b.String()
+}
+
+// describeIRJoins phrases the IR joins for the SQL model's prompt
+func describeIRJoins(ir map[string]interface{}) (string, error) {
+	joins, err := parseIRJoins(ir)
+	if err != nil || len(joins) == 0 {
+		return "", err
+	}
+
+	parts := make([]string, len(joins))
+	for i, join := range joins {
+		conditions := make([]string, len(join.On))
+		for j, on := range join.On {
+			conditions[j] = fmt.Sprintf("%s = %s", on[0], on[1])
+		}
+		kind := "joined with"
+		if join.Type == "LEFT" {
+			kind = "left joined with"
+		}
+		parts[i] = fmt.Sprintf("%s %s on %s", kind, join.Dataset, strings.Join(conditions, " and "))
+	}
+	return strings.Join(parts, ", "), nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/NubeDev/air/internal/redis"
+)
+
+// jobLockTTL is how long a job lock outlives a stalled holder. Running jobs
+// refresh it, so it does not bound how long a job may take.
+const jobLockTTL = time.Minute
+
+// errStaleFence is returned when a write is refused because a newer lock
+// holder has already written
+var errStaleFence = classErrorf(ErrConflict, "a newer run has already written results")
+
+// withJobLock runs fn under a distributed lock so scheduled and long-running
+// jobs execute once across API replicas. With Redis disabled the lock is
+// local to this process. A lock held elsewhere is reported as a conflict.
+func withJobLock(ctx context.Context, locks *redis.Client, name string, fn func(ctx context.Context, token int64) error) error {
+	err := locks.WithLock(ctx, name, jobLockTTL, fn)
+	if errors.Is(err, redis.ErrLockHeld) {
+		return classErrorf(ErrConflict, "%s is already running: %w", name, err)
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// RegisterLakeTable registers an Iceberg or Delta table directory with a
+// Trino datasource, so a table found by file discovery can be queried and
+// learned there. The catalog, the session catalog by default, must be an
+// Iceberg or Delta Lake catalog with table registration enabled.
+func (s *DatasourceService) RegisterLakeTable(ctx context.Context, id string, req store.RegisterLakeTableRequest) (*store.RegisterLakeTableResponse, error) {
+	connector, err := s.registry.GetDatasource(id)
+	if err != nil {
+		return nil, fmt.Errorf("datasource not found: %w", err)
+	}
+	if connector.Dialect() != "trino" {
+		return nil, classErrorf(ErrValidation, "lake tables are registered with trino datasources, %s is %s", id, connector.Kind)
+	}
+	if err := connector.Connected(); err != nil {
+		return nil, err
+	}
+
+	catalog := req.Catalog
+	if catalog == "" {
+		var current sql.NullString
+		if err := connector.DB.QueryRowContext(ctx, "SELECT current_catalog").Scan(&current); err != nil {
+			return nil, fmt.Errorf("failed to read session catalog: %w", err)
+		}
+		if !current.Valid {
+			return nil, classErrorf(ErrValidation, "datasource %s has no session catalog; set catalog", id)
+		}
+		catalog = current.String
+	}
+
+	literal := func(v string) string { return "'" + strings.ReplaceAll(v, "'", "''") + "'" }
+	call := fmt.Sprintf("CALL %s.system.register_table(schema_name => %s, table_name => %s, table_location => %s)",
+		datasource.QuoteIdent("trino", catalog), literal(req.Schema), literal(req.Table), literal(req.Location))
+	if _, err := connector.DB.ExecContext(ctx, call); err != nil {
+		return nil, classErrorf(ErrValidation, "failed to register table: %w", err)
+	}
+
+	table := catalog + "." + req.Schema + "." + req.Table
+	logger.LogInfo(logger.ServiceDB, "Registered lake table", map[string]interface{}{
+		"datasource_id": id,
+		"table":         table,
+		"location":      req.Location,
+	})
+	return &store.RegisterLakeTableResponse{DatasourceID: id, Table: table}, nil
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// Lineage is read from report SQL with a tokenizer rather than a full parser:
+// table references and their aliases come from FROM/JOIN, qualified column
+// references resolve through the aliases, and unqualified ones resolve to the
+// only table in the query or, with several, to the tables whose learned
+// schema has the column.
+var (
+	lineageQuotedRe   = regexp.MustCompile("`([^`]*)`|\"([^\"]*)\"")
+	lineageFromArgRe  = regexp.MustCompile(`(?i)\b(EXTRACT|SUBSTRING|TRIM|POSITION|OVERLAY)\s*\(([^()]*?)\bFROM\b`)
+	lineageDistinctRe = regexp.MustCompile(`(?i)\bIS\s+(?:NOT\s+)?DISTINCT\s+FROM\b`)
+	lineageCTERe      = regexp.MustCompile(`(?i)(?:\bWITH(?:\s+RECURSIVE)?|,)\s*([A-Za-z_]\w*)\s*(?:\([^()]*\)\s*)?AS\s*(?:NOT\s+)?(?:MATERIALIZED\s*)?\(`)
+	lineageTableRe    = regexp.MustCompile(`(?i)\b(FROM|JOIN)\s+([A-Za-z_][\w.]*)`)
+	lineageAliasRe    = regexp.MustCompile(`(?i)^\s+(?:AS\s+)?([A-Za-z_]\w*)`)
+	lineageListRe     = regexp.MustCompile(`^\s*,\s*([A-Za-z_][\w.]*)`)
+	lineageTokenRe    = regexp.MustCompile(`[A-Za-z_][\w$]*(?:\.(?:[A-Za-z_][\w$]*|\*))*|\d+(?:\.\d*)?(?:[eE][-+]?\d+)?|::|[(),*]`)
+	mdColumnRowRe     = regexp.MustCompile(`^\|\s*([^|]+?)\s*\|\s*([^|]*?)\s*\|`)
+)
+
+// lineageKeywords are words that are never read as column names
+var lineageKeywords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		SELECT FROM WHERE AND OR NOT IN IS NULL AS ON JOIN LEFT RIGHT INNER OUTER
+		FULL CROSS NATURAL GROUP BY ORDER HAVING LIMIT OFFSET FETCH FIRST NEXT ROWS
+		ROW ONLY UNION ALL INTERSECT EXCEPT DISTINCT CASE WHEN THEN ELSE END ASC DESC
+		NULLS LAST BETWEEN LIKE ILIKE SIMILAR EXISTS WITH RECURSIVE OVER PARTITION
+		WINDOW USING TRUE FALSE INTERVAL DATE TIME TIMESTAMP ZONE AT LATERAL VALUES
+		ANY SOME CURRENT_DATE CURRENT_TIME CURRENT_TIMESTAMP LOCALTIME LOCALTIMESTAMP
+		CURRENT_USER TOP PRECEDING FOLLOWING UNBOUNDED CURRENT RANGE FILTER WITHIN
+		ESCAPE COLLATE TABLESAMPLE MATERIALIZED BOTH LEADING TRAILING FOR NULLIF
+		YEAR QUARTER MONTH WEEK DAY HOUR MINUTE SECOND EPOCH DOW DOY`) {
+		lineageKeywords[word] = true
+	}
+}
+
+// lineageRef is a source column read by a query
+type lineageRef struct {
+	Table  string
+	Column string
+}
+
+// schemaColumns holds the learned columns of a datasource's tables, keyed by
+// lower-cased table and column name, with each column's type
+type schemaColumns map[string]map[string]string
+
+// lookup returns the columns of table, matching a schema-qualified name
+// against its last segment when the full name was not learned
+func (c schemaColumns) lookup(table string) (map[string]string, bool) {
+	if cols, ok := c[table]; ok {
+		return cols, true
+	}
+	cols, ok := c[lastSegment(table)]
+	return cols, ok
+}
+
+// extractLineage lists the source columns sqlText reads, sorted. A column of
+// "*" means every column of the table.
+func extractLineage(sqlText string, learned schemaColumns) []lineageRef {
+	text := stripSQLLiterals(sqlText)
+	text = placeholderRe.ReplaceAllString(text, " NULL ")
+	text = lineageQuotedRe.ReplaceAllString(text, "$1$2")
+	text = lineageFromArgRe.ReplaceAllString(text, "${1}(${2},")
+	text = lineageDistinctRe.ReplaceAllString(text, " = ")
+
+	ctes := make(map[string]bool)
+	for _, match := range lineageCTERe.FindAllStringSubmatch(text, -1) {
+		ctes[strings.ToLower(match[1])] = true
+	}
+
+	// aliases maps every name a table can be referred to by to the table; CTE
+	// names map to "" since their columns are covered by the CTE's own query
+	var tables []string
+	aliases := make(map[string]string)
+	addTable := func(name, rest string) int {
+		name = strings.ToLower(name)
+		source := name
+		if ctes[name] {
+			source = ""
+		} else {
+			tables = appendUnique(tables, name)
+		}
+		aliases[name] = source
+		if _, taken := aliases[lastSegment(name)]; !taken {
+			aliases[lastSegment(name)] = source
+		}
+		if m := lineageAliasRe.FindStringSubmatch(rest); m != nil && !lineageKeywords[strings.ToUpper(m[1])] {
+			aliases[strings.ToLower(m[1])] = source
+			return len(m[0])
+		}
+		return 0
+	}
+	for _, loc := range lineageTableRe.FindAllStringSubmatchIndex(text, -1) {
+		name, rest := text[loc[4]:loc[5]], text[loc[5]:]
+		if strings.HasPrefix(strings.TrimSpace(rest), "(") {
+			continue // table function
+		}
+		rest = rest[addTable(name, rest):]
+		if !strings.EqualFold(text[loc[2]:loc[3]], "FROM") {
+			continue
+		}
+		for m := lineageListRe.FindStringSubmatch(rest); m != nil; m = lineageListRe.FindStringSubmatch(rest) {
+			rest = rest[len(m[0]):]
+			if strings.HasPrefix(strings.TrimSpace(rest), "(") {
+				break
+			}
+			rest = rest[addTable(m[1], rest):]
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	tokens := lineageTokenRe.FindAllString(text, -1)
+	outputAliases := make(map[string]bool)
+	for i := 1; i < len(tokens); i++ {
+		if strings.EqualFold(tokens[i-1], "AS") {
+			outputAliases[strings.ToLower(tokens[i])] = true
+		}
+	}
+
+	seen := make(map[lineageRef]bool)
+	var refs []lineageRef
+	add := func(table, column string) {
+		ref := lineageRef{Table: table, Column: column}
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	for i, token := range tokens {
+		prev, next := "", ""
+		if i > 0 {
+			prev = strings.ToUpper(tokens[i-1])
+		}
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+
+		switch {
+		case token == "*":
+			if prev == "SELECT" || prev == "," || prev == "DISTINCT" || prev == "ALL" {
+				for _, table := range tables {
+					add(table, "*")
+				}
+			}
+			continue
+		case !isIdentStart(token[0]), next == "(", prev == "AS", prev == "::":
+			continue
+		}
+
+		lower := strings.ToLower(token)
+		if dot := strings.LastIndex(lower, "."); dot >= 0 {
+			qualifier, column := lower[:dot], lower[dot+1:]
+			table, ok := aliases[qualifier]
+			if !ok {
+				table, ok = aliases[lastSegment(qualifier)]
+			}
+			if ok && table != "" {
+				add(table, column)
+			}
+			continue
+		}
+
+		if lineageKeywords[strings.ToUpper(token)] || outputAliases[lower] || ctes[lower] {
+			continue
+		}
+		if _, isTable := aliases[lower]; isTable {
+			continue
+		}
+		if len(tables) == 1 {
+			cols, known := learned.lookup(tables[0])
+			if _, has := cols[lower]; has || !known {
+				add(tables[0], lower)
+			}
+			continue
+		}
+		for _, table := range tables {
+			cols, _ := learned.lookup(table)
+			if _, has := cols[lower]; has {
+				add(table, lower)
+			}
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Table != refs[j].Table {
+			return refs[i].Table < refs[j].Table
+		}
+		return refs[i].Column < refs[j].Column
+	})
+	return refs
+}
+
+// isIdentStart reports whether c can start an unquoted identifier
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// lastSegment returns the table part of a schema-qualified name
+func lastSegment(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// noteColumns reads the column rows of a schema note's markdown table
+func noteColumns(md string) map[string]string {
+	columns := make(map[string]string)
+	for _, line := range strings.Split(md, "\n") {
+		m := mdColumnRowRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil || m[1] == "Column" || strings.HasPrefix(m[1], "-") {
+			continue
+		}
+		columns[strings.ToLower(m[1])] = m[2]
+	}
+	return columns
+}
+
+// notesColumns reads the columns of each object from notes, keeping the most
+// recent note for each chunk
+func notesColumns(notes []store.SchemaNote) schemaColumns {
+	latest := make(map[string]map[int]string)
+	for _, note := range notes {
+		object := strings.ToLower(note.Object)
+		if latest[object] == nil {
+			latest[object] = make(map[int]string)
+		}
+		latest[object][note.Chunk] = note.MD
+	}
+
+	learned := make(schemaColumns, len(latest))
+	for object, chunks := range latest {
+		cols := make(map[string]string)
+		for _, md := range chunks {
+			for name, typ := range noteColumns(md) {
+				cols[name] = typ
+			}
+		}
+		learned[object] = cols
+	}
+	return learned
+}
+
+// learnedColumns loads the learned columns of a datasource's tables
+func learnedColumns(db *gorm.DB, datasourceID string) (schemaColumns, error) {
+	if datasourceID == "" {
+		return schemaColumns{}, nil
+	}
+	var notes []store.SchemaNote
+	if err := db.Select("object", "chunk", "md").Where("datasource_id = ?", datasourceID).
+		Order("id ASC").Find(&notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to load schema notes: %w", err)
+	}
+	return notesColumns(notes), nil
+}
+
+// recordLineage stores the lineage edges of a report version's SQL
+func recordLineage(db *gorm.DB, version *store.ReportVersion) error {
+	sqlText := extractSQLFromDef(version.DefJSON)
+	if strings.TrimSpace(sqlText) == "" {
+		return nil
+	}
+	datasourceID := ""
+	if version.DatasourceID != nil {
+		datasourceID = *version.DatasourceID
+	}
+	learned, err := learnedColumns(db, datasourceID)
+	if err != nil {
+		return err
+	}
+
+	refs := extractLineage(sqlText, learned)
+	if len(refs) == 0 {
+		return nil
+	}
+	edges := make([]store.LineageEdge, len(refs))
+	for i, ref := range refs {
+		edges[i] = store.LineageEdge{
+			ReportID:        version.ReportID,
+			ReportVersionID: version.ID,
+			DatasourceID:    datasourceID,
+			SourceTable:     ref.Table,
+			SourceColumn:    ref.Column,
+			CreatedAt:       time.Now(),
+		}
+	}
+	if err := db.Create(&edges).Error; err != nil {
+		return fmt.Errorf("failed to store lineage: %w", err)
+	}
+	return nil
+}
+
+// liveReportVersions selects the IDs of the versions that still run: each
+// report's latest approved version and any older version a snapshot is pinned to
+func liveReportVersions(db *gorm.DB) *gorm.DB {
+	return db.Model(&store.ReportVersion{}).Select("id").Where("id IN (?) OR id IN (?)",
+		db.Model(&store.ReportVersion{}).Scopes(approvedVersions).Select("MAX(id)").Group("report_id"),
+		db.Model(&store.ReportMaterialization{}).Select("report_version_id"))
+}
+
+// BackfillLineage records lineage for live report versions that have none,
+// such as versions created before lineage was tracked
+func (s *ReportsService) BackfillLineage() {
+	var versions []store.ReportVersion
+	err := s.db.Where("id IN (?)", liveReportVersions(s.db)).
+		Where("id NOT IN (?)", s.db.Model(&store.LineageEdge{}).Select("report_version_id")).
+		Find(&versions).Error
+	if err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to find report versions without lineage", err)
+		return
+	}
+
+	recorded := 0
+	for i := range versions {
+		if err := recordLineage(s.db, &versions[i]); err != nil {
+			logger.LogError(logger.ServiceREST, "Failed to backfill report lineage", err, map[string]interface{}{
+				"report_id": versions[i].ReportID,
+				"version":   versions[i].Version,
+			})
+			continue
+		}
+		recorded++
+	}
+	if recorded > 0 {
+		logger.LogInfo(logger.ServiceREST, "Backfilled report lineage", map[string]interface{}{
+			"versions": recorded,
+		})
+	}
+}
+
+// Lineage answers which reports read a table or column, or which columns a
+// report reads. Only live versions are considered.
+func (s *ReportsService) Lineage(q store.LineageQuery) (*store.LineageResponse, error) {
+	if q.Table == "" && q.Report == "" {
+		return nil, classErrorf(ErrValidation, "table or report is required")
+	}
+	if q.Column != "" && q.Table == "" {
+		return nil, classErrorf(ErrValidation, "column requires table")
+	}
+
+	var reportID uint
+	if q.Report != "" {
+		var report store.Report
+		if err := s.db.Where("key = ?", q.Report).First(&report).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, classErrorf(ErrNotFound, "report not found")
+			}
+			return nil, fmt.Errorf("failed to find report: %w", err)
+		}
+		reportID = report.ID
+	}
+
+	var columns []string
+	if q.Column != "" {
+		columns = []string{q.Column}
+	}
+	reports, err := findLineage(s.db, q.DatasourceID, q.Table, columns, reportID)
+	if err != nil {
+		return nil, err
+	}
+	return &store.LineageResponse{Reports: reports}, nil
+}
+
+// findLineage lists the live report versions reading table, or any of
+// columns of it when given, on datasourceID. Portable reports match every
+// datasource, and reading "*" matches every column. Empty filters match all.
+func findLineage(db *gorm.DB, datasourceID, table string, columns []string, reportID uint) ([]store.LineageReport, error) {
+	query := db.Model(&store.LineageEdge{}).
+		Where("report_version_id IN (?)", liveReportVersions(db)).
+		Where("report_id IN (?)", db.Model(&store.Report{}).Select("id").Where("archived = ?", false))
+	if datasourceID != "" {
+		query = query.Where("datasource_id = ? OR datasource_id = ''", datasourceID)
+	}
+	if table != "" {
+		table = strings.ToLower(table)
+		query = query.Where("source_table IN ? OR source_table LIKE ?",
+			[]string{table, lastSegment(table)}, "%."+lastSegment(table))
+	}
+	if len(columns) > 0 {
+		wanted := []string{"*"}
+		for _, column := range columns {
+			wanted = append(wanted, strings.ToLower(column))
+		}
+		query = query.Where("source_column IN ?", wanted)
+	}
+	if reportID != 0 {
+		query = query.Where("report_id = ?", reportID)
+	}
+
+	var edges []store.LineageEdge
+	if err := query.Order("source_table ASC, source_column ASC").Find(&edges).Error; err != nil {
+		return nil, fmt.Errorf("failed to query lineage: %w", err)
+	}
+	if len(edges) == 0 {
+		return []store.LineageReport{}, nil
+	}
+
+	byVersion := make(map[uint][]store.LineageSource)
+	var versionIDs []uint
+	for _, edge := range edges {
+		if _, ok := byVersion[edge.ReportVersionID]; !ok {
+			versionIDs = append(versionIDs, edge.ReportVersionID)
+		}
+		byVersion[edge.ReportVersionID] = append(byVersion[edge.ReportVersionID],
+			store.LineageSource{Table: edge.SourceTable, Column: edge.SourceColumn})
+	}
+
+	var versions []store.ReportVersion
+	if err := db.Preload("Report").Where("id IN ?", versionIDs).Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load report versions: %w", err)
+	}
+	reports := make([]store.LineageReport, 0, len(versions))
+	for _, version := range versions {
+		lineage := store.LineageReport{
+			ReportID:        version.ReportID,
+			ReportVersionID: version.ID,
+			Key:             version.Report.Key,
+			Title:           version.Report.Title,
+			Version:         version.Version,
+			Sources:         byVersion[version.ID],
+		}
+		if version.DatasourceID != nil {
+			lineage.DatasourceID = *version.DatasourceID
+		}
+		reports = append(reports, lineage)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Key != reports[j].Key {
+			return reports[i].Key < reports[j].Key
+		}
+		return reports[i].Version < reports[j].Version
+	})
+	return reports, nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// ModelService handles Ollama model management (pull, delete, warm-up)
+type ModelService struct {
+	config *config.Config
+	client *llm.OllamaClient
+}
+
+// NewModelService creates a new model service
+func NewModelService(cfg *config.Config) (*ModelService, error) {
+	client, err := llm.NewOllamaClient(cfg.Models.Ollama)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Ollama client: %w", err)
+	}
+
+	return &ModelService{
+		config: cfg,
+		client: client,
+	}, nil
+}
+
+// ListModels lists installed models, marking which are configured and loaded in memory
+func (s *ModelService) ListModels(ctx context.Context) ([]map[string]interface{}, error) {
+	installed, err := s.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]bool)
+	if running, err := s.client.ListRunningModels(ctx); err == nil {
+		for _, name := range running {
+			loaded[normalizeModelName(name)] = true
+		}
+	}
+
+	configured := make(map[string]bool)
+	for _, name := range s.ConfiguredModels() {
+		configured[normalizeModelName(name)] = true
+	}
+
+	models := make([]map[string]interface{}, 0, len(installed.Models))
+	for _, model := range installed.Models {
+		name := normalizeModelName(model.Name)
+		models = append(models, map[string]interface{}{
+			"name":        model.Name,
+			"size":        model.Size,
+			"modified_at": model.ModifiedAt,
+			"configured":  configured[name],
+			"loaded":      loaded[name],
+		})
+	}
+
+	return models, nil
+}
+
+// PullModel pulls a model from the Ollama registry, reporting progress through fn
+func (s *ModelService) PullModel(ctx context.Context, name string, fn func(llm.PullProgress) error) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("model name is required")
+	}
+	return s.client.PullModel(ctx, name, fn)
+}
+
+// DeleteModel removes a model; configured models are refused unless force is set
+func (s *ModelService) DeleteModel(ctx context.Context, name string, force bool) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("model name is required")
+	}
+
+	if !force {
+		for _, configured := range s.ConfiguredModels() {
+			if normalizeModelName(configured) == normalizeModelName(name) {
+				return fmt.Errorf("model %s is in use by the current configuration", name)
+			}
+		}
+	}
+
+	return s.client.DeleteModel(ctx, name)
+}
+
+// WarmUp loads the given models into memory, defaulting to the configured chat/SQL models
+func (s *ModelService) WarmUp(ctx context.Context, models []string) map[string]string {
+	if len(models) == 0 {
+		models = s.ConfiguredModels()
+	}
+
+	results := make(map[string]string, len(models))
+	for _, model := range models {
+		if err := s.client.WarmUpModel(ctx, model, s.config.Models.Ollama.KeepAlive); err != nil {
+			results[model] = err.Error()
+			continue
+		}
+		results[model] = "ready"
+	}
+
+	return results
+}
+
+// RunStartupTasks verifies configured models and then warms them, in the background
+func (s *ModelService) RunStartupTasks() {
+	go func() {
+		s.VerifyModels(context.Background())
+
+		if s.config.Models.Ollama.WarmUp {
+			logger.LogInfo(logger.ServiceAI, "Warming up configured models", map[string]interface{}{
+				"models": s.ConfiguredModels(),
+			})
+			s.WarmUp(context.Background(), nil)
+		}
+	}()
+}
+
+// VerifyModels checks that every configured model exists with its provider,
+// logging an actionable error for each one that does not
+func (s *ModelService) VerifyModels(ctx context.Context) []store.ModelCheckResponse {
+	var checks []store.ModelCheckResponse
+
+	checks = append(checks, s.verifyRoute(ctx, "chat", s.config.Models.ChatPrimary))
+	checks = append(checks, s.verifyRoute(ctx, "sql", s.config.Models.SQLPrimary))
+
+	embeddings := s.config.Models.Embeddings
+	if embeddings.Model != "" {
+		if strings.EqualFold(embeddings.Provider, "ollama") {
+			checks = append(checks, s.verifyOllamaModel(ctx, "embeddings", embeddings.Model))
+		} else {
+			checks = append(checks, s.verifyOpenAIModel(ctx, "embeddings", embeddings.Model))
+		}
+	}
+
+	for _, check := range checks {
+		fields := map[string]interface{}{
+			"role":     check.Role,
+			"provider": check.Provider,
+			"model":    check.Model,
+			"status":   check.Status,
+		}
+		switch check.Status {
+		case "ok", "pulled":
+			logger.LogInfo(logger.ServiceAI, "Model verified", fields)
+		default:
+			fields["hint"] = check.Hint
+			logger.LogError(logger.ServiceAI, "Model verification failed", fmt.Errorf("%s", check.Error), fields)
+		}
+	}
+
+	return checks
+}
+
+// verifyRoute checks the model a chat/SQL route resolves to
+func (s *ModelService) verifyRoute(ctx context.Context, role, route string) store.ModelCheckResponse {
+	name := llm.GetModelName(s.config, role)
+	if llm.IsMockRoute(s.config, role) {
+		return store.ModelCheckResponse{Role: role, Provider: llm.MockModel, Model: name, Status: "ok"}
+	}
+	if s.config.Models.Replay.Mode == llm.ReplayReplay {
+		return store.ModelCheckResponse{Role: role, Provider: "replay", Model: name, Status: "ok"}
+	}
+	if strings.EqualFold(route, "openai") && name == s.config.Models.OpenAI.Model {
+		return s.verifyOpenAIModel(ctx, role, name)
+	}
+	return s.verifyOllamaModel(ctx, role, name)
+}
+
+// verifyOllamaModel checks an Ollama model is installed, pulling it when auto_pull is enabled
+func (s *ModelService) verifyOllamaModel(ctx context.Context, role, name string) store.ModelCheckResponse {
+	check := store.ModelCheckResponse{Role: role, Provider: "ollama", Model: name}
+
+	installed, err := s.client.ListModels(ctx)
+	if err != nil {
+		check.Status = "error"
+		check.Error = err.Error()
+		check.Hint = fmt.Sprintf("Ensure Ollama is running at %s (models.ollama.host)", s.config.Models.Ollama.Host)
+		return check
+	}
+
+	for _, model := range installed.Models {
+		if normalizeModelName(model.Name) == normalizeModelName(name) {
+			check.Status = "ok"
+			return check
+		}
+	}
+
+	if !s.config.Models.Ollama.AutoPull {
+		check.Status = "missing"
+		check.Error = fmt.Sprintf("model %s is not installed on the Ollama host", name)
+		check.Hint = fmt.Sprintf("Run `ollama pull %s` or set models.ollama.auto_pull: true", name)
+		return check
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Pulling missing model", map[string]interface{}{
+		"role":  role,
+		"model": name,
+	})
+	if err := s.client.PullModel(ctx, name, nil); err != nil {
+		check.Status = "error"
+		check.Error = err.Error()
+		check.Hint = fmt.Sprintf("Check the model name or pull it manually with `ollama pull %s`", name)
+		return check
+	}
+
+	check.Status = "pulled"
+	return check
+}
+
+// verifyOpenAIModel checks a model is available to the configured OpenAI key
+func (s *ModelService) verifyOpenAIModel(ctx context.Context, role, name string) store.ModelCheckResponse {
+	check := store.ModelCheckResponse{Role: role, Provider: "openai", Model: name}
+
+	if s.config.Models.OpenAI.APIKey == "" {
+		check.Status = "error"
+		check.Error = "no OpenAI API key configured"
+		check.Hint = "Set models.openai.api_key or switch the provider to Ollama"
+		return check
+	}
+
+	client, err := llm.NewOpenAIClient(s.config.Models.OpenAI)
+	if err != nil {
+		check.Status = "error"
+		check.Error = err.Error()
+		return check
+	}
+
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		check.Status = "error"
+		check.Error = err.Error()
+		check.Hint = "Check models.openai.api_key and network access to the OpenAI API"
+		return check
+	}
+
+	for _, model := range models.Models {
+		if model.Name == name {
+			check.Status = "ok"
+			return check
+		}
+	}
+
+	check.Status = "missing"
+	check.Error = fmt.Sprintf("model %s is not available to this OpenAI account", name)
+	check.Hint = "Check models.openai.model (or models.embeddings.model) for typos"
+	return check
+}
+
+// ConfiguredModels returns the Ollama models used for chat and SQL generation
+func (s *ModelService) ConfiguredModels() []string {
+	var models []string
+	// Replayed routes never reach a model
+	if s.config.Models.Replay.Mode == llm.ReplayReplay {
+		return models
+	}
+	openAIModel := s.config.Models.OpenAI.Model
+	for _, route := range []string{"chat", "sql"} {
+		name := llm.GetModelName(s.config, route)
+		// Routes served by OpenAI resolve to the OpenAI model name; mock
+		// routes have nothing to pull or warm
+		if name == "" || name == openAIModel || name == llm.MockModel {
+			continue
+		}
+		if len(models) > 0 && models[0] == name {
+			continue
+		}
+		models = append(models, name)
+	}
+	return models
+}
+
+// normalizeModelName adds the implicit ":latest" tag so "llama3" matches "llama3:latest"
+func normalizeModelName(name string) string {
+	if !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/mqtt"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// MQTTTopicData is the data available to MQTT topic templates
+type MQTTTopicData struct {
+	Event     string
+	ReportID  uint
+	ReportKey string
+	Folder    string
+}
+
+// mqttTopicReplacer strips MQTT wildcards and separators out of template values
+var mqttTopicReplacer = strings.NewReplacer("+", "_", "#", "_", "/", "_")
+
+// MQTTPublisher publishes report results and alerts to an MQTT broker so on-site
+// controllers and dashboards can consume them without polling the API
+type MQTTPublisher struct {
+	db         *gorm.DB
+	reports    *ReportsService
+	client     *mqtt.Client
+	config     config.MQTTConfig
+	topic      *template.Template
+	alertTopic *template.Template
+}
+
+// NewMQTTPublisher creates a new MQTT publisher; it returns nil when MQTT is disabled
+func NewMQTTPublisher(db *gorm.DB, reports *ReportsService, cfg *config.Config) (*MQTTPublisher, error) {
+	client, err := mqtt.NewClient(&cfg.MQTT)
+	if err != nil || client == nil {
+		return nil, err
+	}
+
+	topic, err := template.New("topic").Parse(cfg.MQTT.TopicTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt.topic_template: %w", err)
+	}
+	alertTopic, err := template.New("alert_topic").Parse(cfg.MQTT.AlertTopicTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt.alert_topic_template: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceMQTT, "MQTT publishing enabled", map[string]interface{}{
+		"broker":         cfg.MQTT.Broker,
+		"qos":            cfg.MQTT.QoS,
+		"payload_format": cfg.MQTT.PayloadFormat,
+	})
+
+	return &MQTTPublisher{
+		db:         db,
+		reports:    reports,
+		client:     client,
+		config:     cfg.MQTT,
+		topic:      topic,
+		alertTopic: alertTopic,
+	}, nil
+}
+
+// HandleEvent is registered as a lifecycle event listener. Completed runs and
+// snapshot refreshes publish results; failed runs and warning/error analyses
+// publish alerts.
+func (p *MQTTPublisher) HandleEvent(event string, data map[string]interface{}) {
+	switch event {
+	case EventReportRunCompleted, EventSnapshotRefreshed, EventReportRunFailed, EventAnalysisCompleted:
+	default:
+		return
+	}
+	go func() {
+		if err := p.publishEvent(event, data); err != nil {
+			logger.LogWarn(logger.ServiceMQTT, "MQTT publish failed", map[string]interface{}{
+				"event":     event,
+				"report_id": toUint(data["report_id"]),
+				"error":     err.Error(),
+			})
+		}
+	}()
+}
+
+func (p *MQTTPublisher) publishEvent(event string, data map[string]interface{}) error {
+	var report store.Report
+	if err := p.db.First(&report, toUint(data["report_id"])).Error; err != nil {
+		return classErrorf(ErrNotFound, "report not found")
+	}
+
+	switch event {
+	case EventReportRunCompleted:
+		var run store.ReportRun
+		if err := p.db.First(&run, toUint(data["run_id"])).Error; err != nil {
+			return classErrorf(ErrNotFound, "run not found")
+		}
+		set, err := ParseRunResults(run.Results)
+		if err != nil {
+			return err
+		}
+		return p.publishResults(event, &report, run.ID, "run", resultRecords(set), run.StartedAt)
+
+	case EventSnapshotRefreshed:
+		snapshot, err := p.reports.GetSnapshotData(report.ID)
+		if err != nil {
+			return err
+		}
+		set := &store.ResultSet{Columns: snapshot.Columns, Rows: snapshot.Rows}
+		return p.publishResults(event, &report, 0, "snapshot", resultRecords(set), snapshot.RefreshedAt)
+
+	case EventReportRunFailed:
+		return p.publishAlert(event, &report, map[string]interface{}{
+			"severity": "error",
+			"run_id":   toUint(data["run_id"]),
+			"message":  data["error"],
+		})
+
+	case EventAnalysisCompleted:
+		var analysis store.ReportAnalysis
+		if err := p.db.First(&analysis, toUint(data["analysis_id"])).Error; err != nil {
+			return classErrorf(ErrNotFound, "analysis not found")
+		}
+		severity, findings := analysisFindings(analysis.VerdictJSON)
+		if severity != "warning" && severity != "error" {
+			return nil
+		}
+		return p.publishAlert(event, &report, map[string]interface{}{
+			"severity":    severity,
+			"run_id":      analysis.RunID,
+			"analysis_id": analysis.ID,
+			"findings":    findings,
+		})
+	}
+	return nil
+}
+
+// publishResults publishes rows in the configured payload format
+func (p *MQTTPublisher) publishResults(event string, report *store.Report, runID uint, source string, rows []map[string]interface{}, at time.Time) error {
+	var payload interface{}
+	switch p.config.PayloadFormat {
+	case "values":
+		values := map[string]interface{}{}
+		if len(rows) > 0 {
+			for k, v := range rows[len(rows)-1] {
+				values[k] = v
+			}
+		}
+		values["timestamp"] = at.UTC().Format(time.RFC3339)
+		payload = values
+	default:
+		envelope := map[string]interface{}{
+			"report_id":  report.ID,
+			"report_key": report.Key,
+			"title":      report.Title,
+			"source":     source,
+			"row_count":  len(rows),
+			"timestamp":  at.UTC().Format(time.RFC3339),
+		}
+		if runID != 0 {
+			envelope["run_id"] = runID
+		}
+		if p.config.PayloadFormat == "json" {
+			if p.config.MaxRows > 0 && len(rows) > p.config.MaxRows {
+				rows = rows[:p.config.MaxRows]
+				envelope["truncated"] = true
+			}
+			envelope["rows"] = rows
+		}
+		payload = envelope
+	}
+	return p.publish(p.topic, event, report, payload)
+}
+
+// publishAlert publishes an alert envelope to the alert topic
+func (p *MQTTPublisher) publishAlert(event string, report *store.Report, alert map[string]interface{}) error {
+	alert["event"] = event
+	alert["report_id"] = report.ID
+	alert["report_key"] = report.Key
+	alert["title"] = report.Title
+	alert["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+	return p.publish(p.alertTopic, event, report, alert)
+}
+
+func (p *MQTTPublisher) publish(tmpl *template.Template, event string, report *store.Report, payload interface{}) error {
+	var topic bytes.Buffer
+	err := tmpl.Execute(&topic, MQTTTopicData{
+		Event:     event,
+		ReportID:  report.ID,
+		ReportKey: mqttTopicReplacer.Replace(report.Key),
+		Folder:    mqttTopicReplacer.Replace(report.Folder),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render topic: %w", err)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	if err := p.client.Publish(context.Background(), topic.String(), body); err != nil {
+		return err
+	}
+
+	logger.LogInfo(logger.ServiceMQTT, "Published to MQTT", map[string]interface{}{
+		"topic":     topic.String(),
+		"event":     event,
+		"report_id": report.ID,
+		"bytes":     len(body),
+	})
+	return nil
+}
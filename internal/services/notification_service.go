@@ -0,0 +1,373 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// defaultNotificationEvents are used when a target doesn't list its events
+var defaultNotificationEvents = []string{EventReportRunCompleted, EventReportRunFailed, EventReportStale}
+
+// Default message templates. Slack renders mrkdwn, Teams renders Markdown.
+const (
+	defaultSlackTemplate = `{{if eq .Status "failed"}}:x:{{else if eq .Status "stale"}}:warning:{{else}}:white_check_mark:{{end}} *{{.ReportTitle}}* ({{.ReportKey}}) {{.Status}}{{if .Reason}}
+{{.Reason}}{{if .Owner}} · owner {{.Owner}}{{end}}{{else}}
+Rows: {{.RowCount}}{{if .DurationMS}} · {{.DurationMS}} ms{{end}}{{end}}{{if .Error}}
+Error: {{.Error}}{{end}}{{if .Findings}}
+Key findings:{{range .Findings}}
+• {{.}}{{end}}{{end}}{{if .Link}}
+<{{.Link}}|View report>{{end}}`
+
+	defaultTeamsTemplate = `**{{.ReportTitle}}** ({{.ReportKey}}) {{.Status}}
+{{if .Reason}}
+{{.Reason}}{{if .Owner}} · owner {{.Owner}}{{end}}{{else}}
+Rows: {{.RowCount}}{{if .DurationMS}} · {{.DurationMS}} ms{{end}}{{end}}{{if .Error}}
+
+Error: {{.Error}}{{end}}{{if .Findings}}
+
+Key findings:
+{{range .Findings}}
+- {{.}}{{end}}{{end}}{{if .Link}}
+
+[View report]({{.Link}}){{end}}`
+)
+
+// NotificationMessage is the data available to notification templates
+type NotificationMessage struct {
+	Event       string
+	Status      string
+	ReportID    uint
+	ReportKey   string
+	ReportTitle string
+	RunID       uint
+	RowCount    int
+	DurationMS  int64
+	Error       string
+	Severity    string
+	Findings    []string
+	Reason      string // why a report version went stale
+	Owner       string
+	Link        string
+}
+
+// notifierTarget is a resolved Slack/Teams destination, global or per report
+type notifierTarget struct {
+	name       string
+	kind       string
+	webhookURL string
+	events     []string
+	template   string
+}
+
+// NotificationService posts run summaries to Slack and Teams incoming webhooks
+type NotificationService struct {
+	db           *gorm.DB
+	config       config.NotificationsConfig
+	client       *http.Client
+	allowPrivate bool // webhooks.allow_private_targets
+	targets      []notifierTarget
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(db *gorm.DB, cfg *config.Config) *NotificationService {
+	s := &NotificationService{
+		db:           db,
+		config:       cfg.Notifications,
+		client:       outboundHTTPClient(10*time.Second, cfg.Webhooks.AllowPrivateTargets),
+		allowPrivate: cfg.Webhooks.AllowPrivateTargets,
+	}
+	for _, t := range cfg.Notifications.Targets {
+		s.targets = append(s.targets, notifierTarget{
+			name:       t.Name,
+			kind:       t.Type,
+			webhookURL: t.WebhookURL,
+			events:     t.Events,
+			template:   t.Template,
+		})
+	}
+	return s
+}
+
+// HandleEvent is registered as a lifecycle event listener; posting happens in
+// the background so the emitting request is never delayed
+func (s *NotificationService) HandleEvent(event string, data map[string]interface{}) {
+	switch event {
+	case EventReportRunCompleted, EventReportRunFailed, EventAnalysisCompleted, EventReportStale:
+	default:
+		return
+	}
+	go s.notify(event, data)
+}
+
+func (s *NotificationService) notify(event string, data map[string]interface{}) {
+	msg, err := s.buildMessage(event, data)
+	if err != nil {
+		logger.LogWarn(logger.ServiceREST, "Failed to build notification", map[string]interface{}{
+			"event": event,
+			"error": err.Error(),
+		})
+		return
+	}
+
+	targets := append([]notifierTarget{}, s.targets...)
+	var perReport []store.ReportNotification
+	if err := s.db.Where("report_id = ?", msg.ReportID).Find(&perReport).Error; err == nil {
+		for _, n := range perReport {
+			targets = append(targets, notifierTarget{
+				name:       fmt.Sprintf("report-%d-%d", n.ReportID, n.ID),
+				kind:       n.Type,
+				webhookURL: n.WebhookURL,
+				events:     splitEvents(n.Events),
+				template:   n.Template,
+			})
+		}
+	}
+
+	for _, target := range targets {
+		if !targetWants(target, event) {
+			continue
+		}
+		if err := s.post(target, msg); err != nil {
+			logger.LogWarn(logger.ServiceREST, "Notification failed", map[string]interface{}{
+				"target":    target.name,
+				"type":      target.kind,
+				"report_id": msg.ReportID,
+				"error":     err.Error(),
+			})
+		}
+	}
+}
+
+// buildMessage gathers report, run and analysis details for an event
+func (s *NotificationService) buildMessage(event string, data map[string]interface{}) (*NotificationMessage, error) {
+	msg := &NotificationMessage{Event: event}
+
+	var run store.ReportRun
+	switch event {
+	case EventReportStale:
+		run.ReportID = toUint(data["report_id"])
+		msg.Status = "stale"
+		msg.Reason, _ = data["reason"].(string)
+	case EventAnalysisCompleted:
+		var analysis store.ReportAnalysis
+		if err := s.db.First(&analysis, toUint(data["analysis_id"])).Error; err != nil {
+			return nil, classErrorf(ErrNotFound, "analysis not found")
+		}
+		if err := s.db.First(&run, analysis.RunID).Error; err != nil {
+			return nil, classErrorf(ErrNotFound, "run not found")
+		}
+		msg.Status = "analyzed"
+		msg.Severity, msg.Findings = analysisFindings(analysis.VerdictJSON)
+	default:
+		if err := s.db.First(&run, toUint(data["run_id"])).Error; err != nil {
+			return nil, classErrorf(ErrNotFound, "run not found")
+		}
+		msg.Status = run.Status
+		msg.DurationMS = toInt64(data["duration_ms"])
+	}
+
+	var report store.Report
+	if err := s.db.First(&report, run.ReportID).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found")
+	}
+
+	msg.ReportID = report.ID
+	msg.ReportKey = report.Key
+	msg.ReportTitle = report.Title
+	msg.Owner = report.Owner
+	msg.RunID = run.ID
+	msg.RowCount = run.RowCount
+	msg.Error = run.ErrorText
+	if s.config.BaseURL != "" {
+		msg.Link = fmt.Sprintf("%s/v1/reports/%d/data", strings.TrimRight(s.config.BaseURL, "/"), report.ID)
+	}
+	return msg, nil
+}
+
+// post renders the target template and sends it in the target's payload format
+func (s *NotificationService) post(target notifierTarget, msg *NotificationMessage) error {
+	text, err := renderNotification(target.kind, target.template, msg)
+	if err != nil {
+		return err
+	}
+
+	var payload interface{}
+	switch target.kind {
+	case "teams":
+		payload = map[string]interface{}{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  fmt.Sprintf("%s %s", msg.ReportTitle, msg.Status),
+			"text":     text,
+		}
+	default:
+		payload = map[string]interface{}{"text": text}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(target.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s webhook returned %d", target.kind, resp.StatusCode)
+	}
+	return nil
+}
+
+// renderNotification executes a custom template, or the default for the target type
+func renderNotification(kind, tmpl string, msg *NotificationMessage) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultSlackTemplate
+		if kind == "teams" {
+			tmpl = defaultTeamsTemplate
+		}
+	}
+	t, err := template.New("notification").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, msg); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return out.String(), nil
+}
+
+// ListReportNotifications returns the notifiers configured for a report
+func (s *NotificationService) ListReportNotifications(reportID uint) ([]store.ReportNotification, error) {
+	var notifications []store.ReportNotification
+	if err := s.db.Where("report_id = ?", reportID).Order("id ASC").Find(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// CreateReportNotification adds a Slack or Teams notifier to a report
+func (s *NotificationService) CreateReportNotification(reportID uint, req store.CreateReportNotificationRequest) (*store.ReportNotification, error) {
+	if req.Type != "slack" && req.Type != "teams" {
+		return nil, fmt.Errorf("type must be one of: slack, teams")
+	}
+	if err := checkOutboundURL(req.WebhookURL, s.allowPrivate); err != nil {
+		return nil, fmt.Errorf("webhook_url: %w", err)
+	}
+
+	events := req.Events
+	if len(events) == 0 {
+		events = defaultNotificationEvents
+	}
+	for _, event := range events {
+		if event != EventReportRunCompleted && event != EventReportRunFailed && event != EventAnalysisCompleted && event != EventReportStale {
+			return nil, fmt.Errorf("unsupported notification event %q", event)
+		}
+	}
+
+	if req.Template != "" {
+		if _, err := renderNotification(req.Type, req.Template, &NotificationMessage{}); err != nil {
+			return nil, err
+		}
+	}
+
+	var report store.Report
+	if err := s.db.First(&report, reportID).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found")
+	}
+
+	notification := &store.ReportNotification{
+		ReportID:   reportID,
+		Type:       req.Type,
+		WebhookURL: req.WebhookURL,
+		Events:     strings.Join(events, ","),
+		Template:   req.Template,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.db.Create(notification).Error; err != nil {
+		return nil, fmt.Errorf("failed to create notification: %w", err)
+	}
+	return notification, nil
+}
+
+// DeleteReportNotification removes a report notifier
+func (s *NotificationService) DeleteReportNotification(reportID, notificationID uint) error {
+	result := s.db.Where("report_id = ?", reportID).Delete(&store.ReportNotification{}, notificationID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete notification: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return classErrorf(ErrNotFound, "notification not found")
+	}
+	return nil
+}
+
+func targetWants(target notifierTarget, event string) bool {
+	events := target.events
+	if len(events) == 0 {
+		events = defaultNotificationEvents
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func splitEvents(events string) []string {
+	var out []string
+	for _, e := range strings.Split(events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// analysisFindings pulls severity and key_findings out of a stored verdict
+func analysisFindings(verdictJSON string) (string, []string) {
+	var verdict struct {
+		Severity    string   `json:"severity"`
+		KeyFindings []string `json:"key_findings"`
+	}
+	if err := json.Unmarshal([]byte(verdictJSON), &verdict); err != nil {
+		return "", nil
+	}
+	return verdict.Severity, verdict.KeyFindings
+}
+
+func toUint(v interface{}) uint {
+	switch n := v.(type) {
+	case uint:
+		return n
+	case int:
+		return uint(n)
+	case float64:
+		return uint(n)
+	}
+	return 0
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
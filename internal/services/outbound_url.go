@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Webhook subscriptions and notifiers post to URLs users register, so unless
+// webhooks.allow_private_targets is set they may not reach loopback, private,
+// link-local (e.g. cloud metadata) or unspecified addresses. URLs are checked
+// when registered, and every connection is checked again when dialled, so a
+// name that later resolves to a private address is refused too.
+
+// errPrivateTarget is returned for URLs resolving to a refused address
+var errPrivateTarget = errors.New("target resolves to a private, loopback or link-local address; set webhooks.allow_private_targets to allow it")
+
+// blockedOutboundIP reports whether an address is refused as a target
+func blockedOutboundIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// checkOutboundURL validates an absolute http(s) URL and, unless private
+// targets are allowed, refuses one whose host resolves to a blocked address
+func checkOutboundURL(raw string, allowPrivate bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if blockedOutboundIP(addr.IP) {
+			return errPrivateTarget
+		}
+	}
+	return nil
+}
+
+// outboundHTTPClient returns a client for posting to registered URLs that,
+// unless private targets are allowed, refuses to connect to blocked addresses
+func outboundHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedOutboundIP(ip) {
+				return errPrivateTarget
+			}
+			return nil
+		}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil // a proxy would connect on the client's behalf, past the check
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 500
+)
+
+// ListSpec maps the field names a list endpoint accepts in sort and filters to
+// the columns of Table
+type ListSpec struct {
+	Table       string
+	Sorts       map[string]string
+	Filters     map[string]string
+	DefaultSort string
+}
+
+// pageCursor is the position after the last item of a page. It records the
+// sort it was made for so it is not reused under another order.
+type pageCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+}
+
+// EncodeCursor returns an opaque cursor for the item identified by key
+func EncodeCursor(sort, key string) string {
+	raw, _ := json.Marshal(pageCursor{Sort: sort, Key: key})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor returns the item key of a cursor made by EncodeCursor for sort
+func DecodeCursor(cursor, sort string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", classErrorf(ErrValidation, "invalid cursor")
+	}
+	var decoded pageCursor
+	if err := json.Unmarshal(raw, &decoded); err != nil || decoded.Key == "" {
+		return "", classErrorf(ErrValidation, "invalid cursor")
+	}
+	if decoded.Sort != sort {
+		return "", classErrorf(ErrValidation, "cursor was issued for sort %q", decoded.Sort)
+	}
+	return decoded.Key, nil
+}
+
+// PageLimit clamps a requested page size
+func PageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		return maxPageLimit
+	}
+	return limit
+}
+
+// keysetCursor is the position after the last item of a ListPage page: the
+// item's ID and its value in the sort column, so the next page starts after
+// it even if the item was since deleted or changed
+type keysetCursor struct {
+	ID    uint64  `json:"id"`
+	Kind  string  `json:"t"`           // how Value is typed: "s", "i", "f", "time" or "null"
+	Value *string `json:"v,omitempty"` // nil for "null"
+}
+
+// encodeKeysetCursor returns a cursor for the item with ID id and sort column
+// value as scanned from the database
+func encodeKeysetCursor(sort string, id uint64, value interface{}) string {
+	cursor := keysetCursor{ID: id}
+	var text string
+	switch v := value.(type) {
+	case nil:
+		cursor.Kind = "null"
+	case time.Time:
+		cursor.Kind, text = "time", v.Format(time.RFC3339Nano)
+	case int64:
+		cursor.Kind, text = "i", strconv.FormatInt(v, 10)
+	case float64:
+		cursor.Kind, text = "f", strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		cursor.Kind, text = "s", string(v)
+	default:
+		cursor.Kind, text = "s", fmt.Sprint(v)
+	}
+	if cursor.Kind != "null" {
+		cursor.Value = &text
+	}
+	raw, _ := json.Marshal(cursor)
+	return EncodeCursor(sort, string(raw))
+}
+
+// decodeKeysetCursor returns the item ID and sort value of a cursor made by
+// encodeKeysetCursor for sort; value is nil when the item sorted as NULL
+func decodeKeysetCursor(cursor, sort string) (uint64, interface{}, error) {
+	raw, err := DecodeCursor(cursor, sort)
+	if err != nil {
+		return 0, nil, err
+	}
+	var decoded keysetCursor
+	if err := json.Unmarshal([]byte(raw), &decoded); err != nil || decoded.ID == 0 {
+		return 0, nil, classErrorf(ErrValidation, "invalid cursor")
+	}
+	if decoded.Kind == "null" {
+		return decoded.ID, nil, nil
+	}
+	if decoded.Value == nil {
+		return 0, nil, classErrorf(ErrValidation, "invalid cursor")
+	}
+	text := *decoded.Value
+	var value interface{}
+	switch decoded.Kind {
+	case "s":
+		value = text
+	case "i":
+		value, err = strconv.ParseInt(text, 10, 64)
+	case "f":
+		value, err = strconv.ParseFloat(text, 64)
+	case "time":
+		value, err = time.Parse(time.RFC3339Nano, text)
+	default:
+		err = fmt.Errorf("unknown cursor value type %q", decoded.Kind)
+	}
+	if err != nil {
+		return 0, nil, classErrorf(ErrValidation, "invalid cursor")
+	}
+	return decoded.ID, value, nil
+}
+
+// ListPage loads one page of query, scoped to spec.Table, into dest, a pointer to a slice of models
+// with an ID field. Pages are keyed on the sort column with ID as tie-breaker,
+// so inserts between requests do not shift or repeat items. NULLs in the sort
+// column come last in either direction.
+func ListPage(query *gorm.DB, spec ListSpec, opts store.ListOptions, dest interface{}) (*store.PageInfo, error) {
+	query = query.Table(spec.Table)
+	sort := opts.Sort
+	if sort == "" {
+		sort = spec.DefaultSort
+	}
+	field := strings.TrimPrefix(sort, "-")
+	column, ok := spec.Sorts[field]
+	if !ok {
+		return nil, classErrorf(ErrValidation, "cannot sort by %q", field)
+	}
+	descending := strings.HasPrefix(sort, "-")
+
+	for name, value := range opts.Filters {
+		filterColumn, ok := spec.Filters[name]
+		if !ok {
+			return nil, classErrorf(ErrValidation, "cannot filter by %q", name)
+		}
+		query = query.Where(filterColumn+" = ?", value)
+	}
+
+	page := &store.PageInfo{}
+	if opts.IncludeTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Select("id").Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	direction, compare := "ASC", ">"
+	if descending {
+		direction, compare = "DESC", "<"
+	}
+	if opts.Cursor != "" {
+		key, value, err := decodeKeysetCursor(opts.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			query = query.Where(column+" IS NULL AND id "+compare+" ?", key)
+		} else {
+			query = query.Where(
+				"("+column+" "+compare+" ?) OR ("+column+" = ? AND id "+compare+" ?) OR ("+column+" IS NULL)",
+				value, value, key,
+			)
+		}
+	}
+
+	limit := PageLimit(opts.Limit)
+	order := query.Order(column + " IS NULL ASC").Order(column + " " + direction).Order("id " + direction)
+	if err := order.Limit(limit + 1).Find(dest).Error; err != nil {
+		return nil, err
+	}
+
+	items := reflect.ValueOf(dest).Elem()
+	if items.Len() > limit {
+		items.Set(items.Slice(0, limit))
+		last := items.Index(limit - 1).FieldByName("ID").Uint()
+		var value interface{}
+		row := query.Session(&gorm.Session{NewDB: true}).Table(spec.Table).Select(column).Where("id = ?", last).Row()
+		if err := row.Scan(&value); err != nil {
+			return nil, err
+		}
+		page.NextCursor = encodeKeysetCursor(sort, last, value)
+	}
+	return page, nil
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// paramEnumQueryTimeout bounds the DISTINCT query filling one param enum
+const paramEnumQueryTimeout = 30 * time.Second
+
+// Params of type string marked "x-air-enum": "distinct" are offered the
+// distinct values of their x-air-column as an enum, so forms show a dropdown
+// of real values. Values come from a per-column cache refreshed on a schedule.
+const (
+	paramEnumKey      = "x-air-enum"
+	paramEnumDistinct = "distinct"
+)
+
+// fillParamEnums sets the enum of the opted-in params of schema from the
+// cached values of datasourceID. Params without a column of their own take
+// the one inferred from sqlText. A column seen for the first time is queried
+// right away; columns with more values than param_enums.max_values, masked
+// PII columns and failed queries get no enum, leaving a free-text field.
+func (s *ReportsService) fillParamEnums(datasourceID, sqlText string, schema map[string]interface{}) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	if datasourceID == "" || len(properties) == 0 {
+		return
+	}
+
+	var columns map[string]string
+	for name, raw := range properties {
+		property, ok := raw.(map[string]interface{})
+		if !ok || property[paramEnumKey] != paramEnumDistinct {
+			continue
+		}
+		if typ, _ := property["type"].(string); typ != "" && typ != "string" {
+			continue
+		}
+		if _, declared := property["enum"]; declared {
+			continue
+		}
+
+		if columns == nil {
+			learned, err := learnedColumns(s.db, datasourceID)
+			if err != nil {
+				return
+			}
+			columns = paramColumns(schema, sqlText, learned)
+		}
+		table, column, ok := splitParamColumn(columns[name])
+		if !ok {
+			continue
+		}
+
+		values, err := s.paramEnumValues(datasourceID, table, column)
+		if err != nil {
+			logger.LogWarn(logger.ServiceREST, "Failed to load param enum", map[string]interface{}{
+				"datasource_id": datasourceID,
+				"param":         name,
+				"column":        columns[name],
+				"error":         err.Error(),
+			})
+			continue
+		}
+		if values != nil {
+			property["enum"] = values
+		}
+	}
+}
+
+// paramColumns maps each param of schema to the table.column it is compared
+// with: its own x-air-column, else the one inferred from sqlText
+func paramColumns(schema map[string]interface{}, sqlText string, learned schemaColumns) map[string]string {
+	columns := make(map[string]string)
+	inferred, _ := inferParamsSchema(sqlText, learned)["properties"].(map[string]interface{})
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name, raw := range properties {
+		for _, source := range []interface{}{raw, inferred[name]} {
+			property, _ := source.(map[string]interface{})
+			if column, _ := property[paramColumnKey].(string); column != "" {
+				columns[name] = column
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// splitParamColumn splits a possibly schema-qualified table.column
+func splitParamColumn(column string) (string, string, bool) {
+	dot := strings.LastIndex(column, ".")
+	if dot <= 0 || dot == len(column)-1 {
+		return "", "", false
+	}
+	return column[:dot], column[dot+1:], true
+}
+
+// paramEnumValues returns the cached values of a column, creating and
+// filling the cache on first use. nil means no enum is offered.
+func (s *ReportsService) paramEnumValues(datasourceID, table, column string) ([]string, error) {
+	cache := store.ParamEnumCache{DatasourceID: datasourceID, SourceTable: table, SourceColumn: column}
+	err := s.db.Where(&cache).First(&cache).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&cache).Error; err != nil {
+			return nil, fmt.Errorf("failed to save param enum cache: %w", err)
+		}
+		if cache.ID == 0 {
+			// Another request created it first
+			return s.paramEnumValues(datasourceID, table, column)
+		}
+		s.refreshParamEnum(&cache)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load param enum cache: %w", err)
+	}
+
+	if cache.Truncated || cache.ValuesJSON == "" {
+		return nil, nil
+	}
+	var values []string
+	if err := json.Unmarshal([]byte(cache.ValuesJSON), &values); err != nil {
+		return nil, fmt.Errorf("failed to decode param enum cache: %w", err)
+	}
+	return values, nil
+}
+
+// StartParamEnumRefresh queries every cached param enum again on interval.
+// Refreshes run under a job lock so only one replica queries the datasources.
+func (s *ReportsService) StartParamEnumRefresh(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Param enum refresh started", map[string]interface{}{
+		"refresh_interval": interval.String(),
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			<-ticker.C
+			err := withJobLock(context.Background(), s.locks, "param_enums", func(context.Context, int64) error {
+				s.refreshAllParamEnums()
+				return nil
+			})
+			if err != nil && !errors.Is(err, redis.ErrLockHeld) {
+				logger.LogError(logger.ServiceREST, "Param enum refresh failed", err)
+			}
+		}
+	}()
+}
+
+// refreshAllParamEnums refreshes every cached column one at a time
+func (s *ReportsService) refreshAllParamEnums() {
+	var caches []store.ParamEnumCache
+	if err := s.db.Order("id ASC").Find(&caches).Error; err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to load param enum caches", err)
+		return
+	}
+	for i := range caches {
+		s.refreshParamEnum(&caches[i])
+	}
+}
+
+// refreshParamEnum queries a column's distinct values and stores them. A
+// failed query keeps the values it had, so a flaky datasource doesn't empty
+// the dropdowns; a masked PII column loses them.
+func (s *ReportsService) refreshParamEnum(cache *store.ParamEnumCache) {
+	now := time.Now()
+	values, truncated, err := s.distinctValues(cache)
+	cache.RefreshedAt = &now
+	cache.ErrorText = ""
+	switch {
+	case errors.Is(err, ErrValidation):
+		// The column turned out to hold masked personal data
+		cache.ErrorText = err.Error()
+		cache.ValuesJSON = ""
+	case err != nil:
+		cache.ErrorText = err.Error()
+	case truncated:
+		cache.ValuesJSON = ""
+		cache.Truncated = true
+	default:
+		encoded, _ := json.Marshal(values)
+		cache.ValuesJSON = string(encoded)
+		cache.Truncated = false
+	}
+
+	err = s.db.Model(cache).Select("values_json", "truncated", "refreshed_at", "error_text").Updates(cache).Error
+	if err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to save param enum cache", err, map[string]interface{}{
+			"datasource_id": cache.DatasourceID,
+			"table":         cache.SourceTable,
+			"column":        cache.SourceColumn,
+		})
+	}
+}
+
+// distinctValues runs SELECT DISTINCT on the cache's column, reading one
+// value past param_enums.max_values to tell whether the column has more
+func (s *ReportsService) distinctValues(cache *store.ParamEnumCache) ([]string, bool, error) {
+	if _, masked := piiKindsByColumn(maskedPIIColumns(s.db, cache.DatasourceID), cache.SourceTable)[strings.ToLower(cache.SourceColumn)]; masked {
+		return nil, false, classErrorf(ErrValidation, "%s.%s holds masked personal data", cache.SourceTable, cache.SourceColumn)
+	}
+	connector, err := s.registry.GetDatasource(cache.DatasourceID)
+	if err != nil {
+		return nil, false, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	if err := connector.Connected(); err != nil {
+		return nil, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), paramEnumQueryTimeout)
+	defer cancel()
+
+	column := connector.Quote(cache.SourceColumn)
+	query := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %s IS NOT NULL ORDER BY %s LIMIT %d",
+		column, quoteTableName(connector, cache.SourceTable), column, column, s.enums.MaxValues+1)
+	rows, err := connector.DB.QueryContext(ctx, queryComment{User: "scheduler"}.apply(query))
+	if err != nil {
+		return nil, false, fmt.Errorf("param enum query failed: %w", err)
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value sql.NullString
+		if err := rows.Scan(&value); err != nil {
+			return nil, false, err
+		}
+		if len(values) == s.enums.MaxValues {
+			return nil, true, nil
+		}
+		values = append(values, value.String)
+	}
+	return values, false, rows.Err()
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// paramOptionsTimeout bounds the lookups narrowing one request's options
+const paramOptionsTimeout = 30 * time.Second
+
+// A param whose options depend on others names them in x-air-depends-on
+// (sites filtered by the selected region). Its options are looked up with
+// the parents' values bound as query args, never spliced into SQL: by
+// default the distinct values of its column in rows whose parent columns
+// match, or with the x-air-options-sql template when the tables differ.
+const (
+	paramDependsOnKey  = "x-air-depends-on"
+	paramOptionsSQLKey = "x-air-options-sql"
+)
+
+// ParamOptions are the enum values of dependent params narrowed by the
+// values of their parents
+type ParamOptions struct {
+	ReportID     uint                `json:"report_id"`
+	DatasourceID string              `json:"datasource_id"`
+	Options      map[string][]string `json:"options"`
+	Truncated    []string            `json:"truncated,omitempty"` // more values than param_enums.max_values; left free text
+	Pending      []string            `json:"pending,omitempty"`   // a parent has no value yet
+}
+
+// NarrowParamOptions looks up the options of every dependent param of a
+// report's latest version whose parents all have a value in req.Params
+func (s *ReportsService) NarrowParamOptions(reportID uint, req store.ParamOptionsRequest) (*ParamOptions, error) {
+	report, err := s.GetReportByID(reportID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found: %w", err)
+	}
+	reportVersion, schema, err := s.latestParamsSchema(reportID)
+	if err != nil {
+		return nil, err
+	}
+	if reportVersion == nil {
+		return nil, classErrorf(ErrNotFound, "report %d has no versions", reportID)
+	}
+	datasourceID := req.DatasourceID
+	if datasourceID == "" && reportVersion.DatasourceID != nil {
+		datasourceID = *reportVersion.DatasourceID
+	}
+	if datasourceID == "" {
+		return nil, classErrorf(ErrValidation, "no datasource specified")
+	}
+	connector, err := s.registry.GetDatasource(datasourceID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	if err := connector.Connected(); err != nil {
+		return nil, err
+	}
+	learned, err := learnedColumns(s.db, datasourceID)
+	if err != nil {
+		return nil, err
+	}
+	columns := paramColumns(schema, extractSQLFromDef(reportVersion.DefJSON), learned)
+	masked := maskedPIIColumns(s.db, datasourceID)
+	comment := queryComment{Report: report.Key, Version: reportVersion.Version, User: req.User}
+
+	ctx, cancel := context.WithTimeout(context.Background(), paramOptionsTimeout)
+	defer cancel()
+
+	result := &ParamOptions{ReportID: reportID, DatasourceID: datasourceID, Options: make(map[string][]string)}
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, _ := properties[name].(map[string]interface{})
+		parents := paramDependsOn(property)
+		if len(parents) == 0 {
+			continue
+		}
+		if !hasParamValues(req.Params, parents) {
+			result.Pending = append(result.Pending, name)
+			continue
+		}
+
+		query, args, err := paramOptionsLookup(connector, comment, name, property, parents, columns, learned, masked, req.Params)
+		if err != nil {
+			return nil, err
+		}
+		template, _ := property[paramOptionsSQLKey].(string)
+		piiKinds, err := piiResultKinds(template, masked, learned)
+		if err != nil {
+			return nil, err
+		}
+		values, truncated, err := s.lookupParamOptions(ctx, connector, query, args, piiKinds)
+		if err != nil {
+			return nil, classErrorf(ErrValidation, "options lookup for %s failed: %w", name, err)
+		}
+		if truncated {
+			result.Truncated = append(result.Truncated, name)
+			continue
+		}
+		result.Options[name] = values
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Param options narrowed", map[string]interface{}{
+		"report_id":     reportID,
+		"datasource_id": datasourceID,
+		"params":        len(result.Options),
+		"pending":       len(result.Pending),
+	})
+	return result, nil
+}
+
+// checkParamDependencies validates the dependencies declared in a params
+// schema: parents must be other params, without cycles, and an options
+// template must be read-only and use only its parents' placeholders
+func checkParamDependencies(schema map[string]interface{}) error {
+	properties, _ := schema["properties"].(map[string]interface{})
+	graph := make(map[string][]string)
+	for name, raw := range properties {
+		property, _ := raw.(map[string]interface{})
+		parents := paramDependsOn(property)
+		if parents == nil && property[paramDependsOnKey] != nil {
+			return classErrorf(ErrValidation, "%s of %s must be a param name or a list of them", paramDependsOnKey, name)
+		}
+		for _, parent := range parents {
+			if parent == name {
+				return classErrorf(ErrValidation, "param %s cannot depend on itself", name)
+			}
+			if _, ok := properties[parent]; !ok {
+				return classErrorf(ErrValidation, "param %s depends on unknown param %s", name, parent)
+			}
+		}
+		graph[name] = parents
+
+		template, _ := property[paramOptionsSQLKey].(string)
+		if template == "" {
+			continue
+		}
+		if len(parents) == 0 {
+			return classErrorf(ErrValidation, "%s of %s needs %s", paramOptionsSQLKey, name, paramDependsOnKey)
+		}
+		if _, err := ValidateReadOnlySQL(template); err != nil {
+			return classErrorf(ErrValidation, "%s of %s: %w", paramOptionsSQLKey, name, err)
+		}
+		for _, placeholder := range extractSQLPlaceholders(template) {
+			if !slices.Contains(parents, placeholder) {
+				return classErrorf(ErrValidation, "%s of %s uses {{%s}}, which it does not depend on", paramOptionsSQLKey, name, placeholder)
+			}
+		}
+	}
+
+	// Depth-first search for a param reached again while it is being visited
+	state := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return classErrorf(ErrValidation, "param dependencies form a cycle through %s", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, parent := range graph[name] {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		return nil
+	}
+	for name := range graph {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paramDependsOn returns the params a property depends on; x-air-depends-on
+// is one name or a list. nil means none, or a malformed value.
+func paramDependsOn(property map[string]interface{}) []string {
+	switch value := property[paramDependsOnKey].(type) {
+	case string:
+		if value = strings.TrimSpace(value); value != "" {
+			return []string{value}
+		}
+	case []interface{}:
+		parents := make([]string, 0, len(value))
+		for _, item := range value {
+			name, ok := item.(string)
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil
+			}
+			parents = append(parents, strings.TrimSpace(name))
+		}
+		return parents
+	}
+	return nil
+}
+
+// hasParamValues reports whether every named param has a non-empty value
+func hasParamValues(params map[string]interface{}, names []string) bool {
+	for _, name := range names {
+		values := paramArgs(params[name])
+		if len(values) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// paramArgs returns a param value as query args; a list, for multi-select
+// parents, is several args
+func paramArgs(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil
+		}
+		return []interface{}{v}
+	case []interface{}:
+		args := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			args = append(args, paramArgs(item)...)
+		}
+		return args
+	default:
+		return []interface{}{v}
+	}
+}
+
+// paramOptionsLookup builds the query listing a dependent param's options.
+// Parent values become bind args; identifiers come from the params schema
+// and are quoted.
+func paramOptionsLookup(connector *datasource.DatasourceConnector, comment queryComment, name string, property map[string]interface{}, parents []string, columns map[string]string, learned schemaColumns, masked []store.PIIColumn, params map[string]interface{}) (string, []interface{}, error) {
+	var args []interface{}
+	markers := func(values []interface{}) string {
+		list := make([]string, len(values))
+		for i := range values {
+			args = append(args, values[i])
+			list[i] = bindMarker(connector.Dialect(), len(args))
+		}
+		return strings.Join(list, ", ")
+	}
+
+	if template, _ := property[paramOptionsSQLKey].(string); template != "" {
+		query := placeholderRe.ReplaceAllStringFunc(template, func(match string) string {
+			return markers(paramArgs(params[placeholderRe.FindStringSubmatch(match)[1]]))
+		})
+		return comment.apply(query), args, nil
+	}
+
+	table, column, ok := splitParamColumn(columns[name])
+	if !ok {
+		return "", nil, classErrorf(ErrValidation, "param %s has no %s to list options from; declare one or %s", name, paramColumnKey, paramOptionsSQLKey)
+	}
+	if _, isMasked := piiKindsByColumn(masked, table)[strings.ToLower(column)]; isMasked {
+		return "", nil, classErrorf(ErrValidation, "%s.%s holds masked personal data", table, column)
+	}
+	tableColumns, _ := learned.lookup(strings.ToLower(table))
+
+	conditions := make([]string, 0, len(parents))
+	for _, parent := range parents {
+		parentColumn := ""
+		if parentTable, col, ok := splitParamColumn(columns[parent]); ok && strings.EqualFold(lastSegment(parentTable), lastSegment(table)) {
+			parentColumn = col
+		} else {
+			// The parent is compared in another table; match a same-named column of this one
+			for _, candidate := range []string{col, parent} {
+				if _, ok := tableColumns[strings.ToLower(candidate)]; ok && candidate != "" {
+					parentColumn = candidate
+					break
+				}
+			}
+		}
+		if parentColumn == "" {
+			return "", nil, classErrorf(ErrValidation, "cannot narrow %s by %s: %s has no matching column; declare %s", name, parent, table, paramOptionsSQLKey)
+		}
+		conditions = append(conditions, fmt.Sprintf("%s IN (%s)", connector.Quote(parentColumn), markers(paramArgs(params[parent]))))
+	}
+
+	quoted := connector.Quote(column)
+	query := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %s IS NOT NULL AND %s ORDER BY %s",
+		quoted, quoteTableName(connector, table), quoted, strings.Join(conditions, " AND "), quoted)
+	return comment.apply(query), args, nil
+}
+
+// lookupParamOptions runs an options lookup and returns its first column,
+// reading one value past param_enums.max_values to tell whether there are
+// more. A first column named in piiKinds is refused.
+func (s *ReportsService) lookupParamOptions(ctx context.Context, connector *datasource.DatasourceConnector, query string, args []interface{}, piiKinds map[string]string) ([]string, bool, error) {
+	rows, err := connector.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, false, err
+	}
+	if kind := piiKinds[strings.ToLower(columns[0])]; kind != "" {
+		return nil, false, fmt.Errorf("%s holds masked personal data (%s)", columns[0], kind)
+	}
+	values := []string{}
+	raw := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range raw {
+		pointers[i] = &raw[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, false, err
+		}
+		if !raw[0].Valid {
+			continue
+		}
+		if len(values) == s.enums.MaxValues {
+			return nil, true, nil
+		}
+		values = append(values, raw[0].String)
+	}
+	return values, false, rows.Err()
+}
+
+// bindMarker is the n-th (1-based) bind parameter of a dialect
+func bindMarker(dialect string, n int) string {
+	switch dialect {
+	case "postgres", "timescaledb":
+		return fmt.Sprintf("$%d", n)
+	case "sqlserver":
+		return fmt.Sprintf("@p%d", n)
+	}
+	return "?"
+}
@@ -0,0 +1,540 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// piiSampleRows is how many rows of a table learning scans for personal data
+const piiSampleRows = 50
+
+// ErrPIIColumnNotFound is returned for unknown PII column tags
+var ErrPIIColumnNotFound = classErrorf(ErrNotFound, "PII column not found")
+
+// piiPatterns match personal data in text. National IDs are tried before
+// phone numbers, which would otherwise claim them.
+var piiPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{"national_id", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{"phone", regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{3}\)|\b\d{3})[ .\-]?\d{3}[ .\-]\d{4}\b`)},
+}
+
+// piiNamePhrases are column-name fragments that span tokens
+var piiNamePhrases = map[string]string{
+	"e_mail":          "email",
+	"national_id":     "national_id",
+	"social_security": "national_id",
+	"tax_id":          "national_id",
+}
+
+// piiNameTokens are column-name tokens that suggest personal data
+var piiNameTokens = map[string]string{
+	"email":        "email",
+	"emailaddress": "email",
+	"phone":        "phone",
+	"phonenumber":  "phone",
+	"mobile":       "phone",
+	"telephone":    "phone",
+	"fax":          "phone",
+	"msisdn":       "phone",
+	"ssn":          "national_id",
+	"nino":         "national_id",
+	"passport":     "national_id",
+	"taxid":        "national_id",
+}
+
+// maskPII replaces every PII match in text with [<kind>]
+func maskPII(text string) string {
+	for _, p := range piiPatterns {
+		text = p.pattern.ReplaceAllString(text, "["+p.name+"]")
+	}
+	return text
+}
+
+// piiKindForName returns the kind of personal data a column name suggests
+func piiKindForName(name string) string {
+	lower := strings.ToLower(name)
+	for phrase, kind := range piiNamePhrases {
+		if strings.Contains(lower, phrase) {
+			return kind
+		}
+	}
+	for _, token := range strings.FieldsFunc(lower, func(r rune) bool { return r == '_' || r == '-' || r == ' ' }) {
+		if kind := piiNameTokens[token]; kind != "" {
+			return kind
+		}
+	}
+	return ""
+}
+
+// piiKindForValues returns the kind of personal data most sampled values
+// consist of entirely, or "" when no kind covers more than half of them
+func piiKindForValues(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	for _, p := range piiPatterns {
+		matches := 0
+		for _, value := range values {
+			if p.pattern.FindString(value) == value {
+				matches++
+			}
+		}
+		if matches*2 > len(values) {
+			return p.name
+		}
+	}
+	return ""
+}
+
+// isTextColumn reports whether a column type can hold personal data as text
+func isTextColumn(columnType string) bool {
+	t := strings.ToLower(columnType)
+	if t == "" {
+		return true
+	}
+	for _, kind := range []string{"char", "text", "string", "clob", "varchar"} {
+		if strings.Contains(t, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectPII tags the columns of learned tables that hold personal data:
+// first by name, then by sampling text columns of connected datasources.
+// Sampling failures only cost detection, so they are logged and skipped.
+func detectPII(ctx context.Context, connector *datasource.DatasourceConnector, datasourceID string, tables []datasource.Table) []store.PIIColumn {
+	var tagged []store.PIIColumn
+	for _, table := range tables {
+		var sampled []string
+		for _, column := range table.Columns {
+			if kind := piiKindForName(column.Name); kind != "" {
+				tagged = append(tagged, store.PIIColumn{DatasourceID: datasourceID, Object: table.Name, ColumnName: column.Name, Kind: kind, DetectedBy: "name"})
+			} else if isTextColumn(column.Type) {
+				sampled = append(sampled, column.Name)
+			}
+		}
+		if len(sampled) == 0 || connector.Connected() != nil {
+			continue
+		}
+		values, err := samplePIIValues(ctx, connector, table.Name, sampled)
+		if err != nil {
+			logger.LogWarn(logger.ServiceDB, "Failed to sample table for PII", map[string]interface{}{
+				"datasource_id": datasourceID,
+				"table":         table.Name,
+				"error":         err.Error(),
+			})
+			continue
+		}
+		for i, column := range sampled {
+			if kind := piiKindForValues(values[i]); kind != "" {
+				tagged = append(tagged, store.PIIColumn{DatasourceID: datasourceID, Object: table.Name, ColumnName: column, Kind: kind, DetectedBy: "values"})
+			}
+		}
+	}
+	return tagged
+}
+
+// samplePIIValues reads the non-empty values of columns from the first
+// piiSampleRows rows of a table, one slice per column
+func samplePIIValues(ctx context.Context, connector *datasource.DatasourceConnector, table string, columns []string) ([][]string, error) {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = connector.Quote(column)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	query := fmt.Sprintf("SELECT %s FROM %s LIMIT %d", strings.Join(quoted, ", "), quoteTableName(connector, table), piiSampleRows)
+	rows, err := connector.DB.QueryContext(ctx, queryComment{User: auth.Username(ctx), Job: "pii_scan"}.apply(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make([][]string, len(columns))
+	raw := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range raw {
+		pointers[i] = &raw[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		for i, value := range raw {
+			if text := strings.TrimSpace(value.String); value.Valid && text != "" {
+				values[i] = append(values[i], text)
+			}
+		}
+	}
+	return values, rows.Err()
+}
+
+// savePIIColumns stores detected tags; columns already tagged keep whether an
+// admin allowed them
+func (s *DatasourceService) savePIIColumns(columns []store.PIIColumn) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range columns {
+		columns[i].CreatedAt = now
+		columns[i].UpdatedAt = now
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "datasource_id"}, {Name: "object"}, {Name: "column_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"kind", "detected_by", "updated_at"}),
+	}).Create(&columns).Error
+}
+
+// ListPIIColumns returns a datasource's PII-tagged columns
+func (s *DatasourceService) ListPIIColumns(datasourceID string) ([]store.PIIColumn, error) {
+	var columns []store.PIIColumn
+	if err := s.db.Where("datasource_id = ?", datasourceID).Order("object, column_name").Find(&columns).Error; err != nil {
+		return nil, fmt.Errorf("failed to list PII columns: %w", err)
+	}
+	return columns, nil
+}
+
+// SetPIIColumnAllowed lets an admin allow a tagged column into prompts and
+// results, or mask it again
+func (s *DatasourceService) SetPIIColumnAllowed(datasourceID string, id uint, allowed bool, user string) (*store.PIIColumn, error) {
+	var column store.PIIColumn
+	if err := s.db.Where("datasource_id = ? AND id = ?", datasourceID, id).First(&column).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPIIColumnNotFound
+		}
+		return nil, fmt.Errorf("failed to load PII column: %w", err)
+	}
+
+	column.Allowed = allowed
+	column.AllowedBy = ""
+	column.AllowedAt = nil
+	if allowed {
+		now := time.Now()
+		column.AllowedBy = user
+		column.AllowedAt = &now
+	}
+	if err := s.db.Model(&column).Select("allowed", "allowed_by", "allowed_at").Updates(&column).Error; err != nil {
+		return nil, fmt.Errorf("failed to update PII column: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceDB, "PII column policy changed", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"table":         column.Object,
+		"column":        column.ColumnName,
+		"allowed":       allowed,
+		"user":          user,
+	})
+	return &column, nil
+}
+
+// DeletePIIColumn removes a tag, e.g. a false positive. Learning tags the
+// column again if it still looks like personal data.
+func (s *DatasourceService) DeletePIIColumn(datasourceID string, id uint) error {
+	res := s.db.Where("datasource_id = ? AND id = ?", datasourceID, id).Delete(&store.PIIColumn{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete PII column: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrPIIColumnNotFound
+	}
+	return nil
+}
+
+// maskedPIIColumns loads a datasource's tagged columns that are not allowed;
+// lookup failures are logged and mask nothing
+func maskedPIIColumns(db *gorm.DB, datasourceID string) []store.PIIColumn {
+	var columns []store.PIIColumn
+	if err := db.Where("datasource_id = ? AND allowed = ?", datasourceID, false).Order("object, column_name").Find(&columns).Error; err != nil {
+		logger.LogWarn(logger.ServiceDB, "Failed to load PII columns", map[string]interface{}{
+			"datasource_id": datasourceID,
+			"error":         err.Error(),
+		})
+		return nil
+	}
+	return columns
+}
+
+// piiMarkdown renders masked columns as prompt context
+func piiMarkdown(columns []store.PIIColumn) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	var md strings.Builder
+	md.WriteString("Columns holding personal data (do not select, group by or filter on them; their values are masked):\n")
+	for _, c := range columns {
+		md.WriteString(fmt.Sprintf("- %s.%s (%s)\n", c.Object, c.ColumnName, c.Kind))
+	}
+	return md.String()
+}
+
+// piiKindsByColumn maps the lower-cased masked columns of one table to their kind
+func piiKindsByColumn(columns []store.PIIColumn, table string) map[string]string {
+	kinds := make(map[string]string)
+	for _, c := range columns {
+		if strings.EqualFold(c.Object, table) {
+			kinds[strings.ToLower(c.ColumnName)] = c.Kind
+		}
+	}
+	return kinds
+}
+
+// piiKindsForSQL maps the lower-cased names of the result columns of
+// sqlText that return values of a datasource's masked columns to their kind.
+// See piiResultKinds.
+func piiKindsForSQL(db *gorm.DB, datasourceID, sqlText string) (map[string]string, error) {
+	columns := maskedPIIColumns(db, datasourceID)
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	learned, err := learnedColumns(db, datasourceID)
+	if err != nil {
+		logger.LogWarn(logger.ServiceDB, "Failed to load learned columns for PII masking", map[string]interface{}{
+			"datasource_id": datasourceID,
+			"error":         err.Error(),
+		})
+		learned = schemaColumns{}
+	}
+	return piiResultKinds(sqlText, columns, learned)
+}
+
+// piiResultKinds maps the lower-cased names of the result columns of sqlText
+// that return values of masked columns to their kind. The masked columns the
+// SQL reads come from its lineage, so only tables it reads count. A select
+// item, at any level of the query, that refers to a masked column or to an
+// item derived from one is masked by its output name, so aliases and
+// expressions such as lower(email) AS contact are masked too. COUNT(...) is
+// not, since it returns no values. An item without an output name to mask it
+// by is refused.
+func piiResultKinds(sqlText string, columns []store.PIIColumn, learned schemaColumns) (map[string]string, error) {
+	kinds := make(map[string]string)
+	for _, ref := range extractLineage(sqlText, learned) {
+		for _, c := range columns {
+			if !strings.EqualFold(c.Object, ref.Table) && !strings.EqualFold(lastSegment(c.Object), lastSegment(ref.Table)) {
+				continue
+			}
+			if ref.Column == "*" || strings.EqualFold(c.ColumnName, ref.Column) {
+				kinds[strings.ToLower(c.ColumnName)] = c.Kind
+			}
+		}
+	}
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+
+	items := selectItems(sqlText)
+	for changed := true; changed; {
+		changed = false
+		for _, item := range items {
+			kind, ok := item.readsAny(kinds)
+			if !ok {
+				continue
+			}
+			if item.name == "" {
+				return nil, classErrorf(ErrValidation, "the query returns masked personal data (%s) in an expression without a column alias; alias it so it can be masked", kind)
+			}
+			if _, seen := kinds[item.name]; !seen {
+				kinds[item.name] = kind
+				changed = true
+			}
+		}
+	}
+	return kinds, nil
+}
+
+var piiTokenRe = regexp.MustCompile(`[A-Za-z_][\w$]*(?:\.(?:[A-Za-z_][\w$]*|\*))*|\d+(?:\.\d*)?|::|\S`)
+
+// selectListEnd are keywords ending a select list at its own level
+var selectListEnd = map[string]bool{
+	"FROM": true, "WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true,
+	"OFFSET": true, "FETCH": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "WINDOW": true, "INTO": true,
+}
+
+// selectItem is one item of a select list: its tokens, including those of
+// any subquery inside it, and its lower-cased output name, "" when it has
+// none to go by
+type selectItem struct {
+	tokens []string
+	alias  int // index of the alias token, -1 without one
+	name   string
+}
+
+// readsAny returns the kind of the first name in kinds the item refers to,
+// outside COUNT(...) and its own alias
+func (item selectItem) readsAny(kinds map[string]string) (string, bool) {
+	countDepth, depth := 0, 0
+	for i, token := range item.tokens {
+		switch token {
+		case "(":
+			depth++
+			continue
+		case ")":
+			if depth--; depth < countDepth {
+				countDepth = 0
+			}
+			continue
+		}
+		if i == item.alias || !isIdentStart(token[0]) {
+			continue
+		}
+		if i+1 < len(item.tokens) && item.tokens[i+1] == "(" {
+			if countDepth == 0 && strings.EqualFold(token, "COUNT") {
+				countDepth = depth + 1
+			}
+			continue
+		}
+		if countDepth > 0 {
+			continue
+		}
+		if kind, ok := kinds[lastSegment(strings.ToLower(token))]; ok {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// selectItems lists the items of every select list in sqlText, subqueries
+// and CTEs included
+func selectItems(sqlText string) []selectItem {
+	text := placeholderRe.ReplaceAllString(stripSQLLiterals(sqlText), " NULL ")
+	text = lineageQuotedRe.ReplaceAllString(text, "$1$2")
+	tokens := piiTokenRe.FindAllString(text, -1)
+
+	type selectList struct {
+		depth   int
+		current []string
+	}
+	var (
+		items []selectItem
+		open  []*selectList
+		depth int
+	)
+	finish := func(list *selectList) {
+		if len(list.current) > 0 {
+			items = append(items, newSelectItem(list.current))
+		}
+		list.current = nil
+	}
+	for _, token := range tokens {
+		upper := strings.ToUpper(token)
+		var top *selectList
+		if len(open) > 0 && open[len(open)-1].depth == depth {
+			top = open[len(open)-1]
+		}
+		switch {
+		case token == ")":
+			depth--
+			for len(open) > 0 && open[len(open)-1].depth > depth {
+				finish(open[len(open)-1])
+				open = open[:len(open)-1]
+			}
+		case top != nil && token == ",":
+			finish(top)
+			continue
+		case top != nil && selectListEnd[upper]:
+			finish(top)
+			open = open[:len(open)-1]
+		}
+		for _, list := range open {
+			list.current = append(list.current, token)
+		}
+		switch {
+		case token == "(":
+			depth++
+		case upper == "SELECT":
+			open = append(open, &selectList{depth: depth})
+		}
+	}
+	for _, list := range open {
+		finish(list)
+	}
+	return items
+}
+
+// newSelectItem names a select item by its alias, or by the column it is
+// when it is a bare column reference
+func newSelectItem(tokens []string) selectItem {
+	for len(tokens) > 1 && (strings.EqualFold(tokens[0], "DISTINCT") || strings.EqualFold(tokens[0], "ALL")) {
+		tokens = tokens[1:]
+	}
+	item := selectItem{tokens: tokens, alias: -1}
+	last := len(tokens) - 1
+	bare := func(token string) bool {
+		return isIdentStart(token[0]) && !strings.Contains(token, ".") && !lineageKeywords[strings.ToUpper(token)]
+	}
+	switch {
+	case last == 0 && isIdentStart(tokens[0][0]) && !strings.HasSuffix(tokens[0], "*"):
+		item.name = lastSegment(strings.ToLower(tokens[0]))
+	case last >= 1 && strings.EqualFold(tokens[last-1], "AS") && isIdentStart(tokens[last][0]):
+		item.alias, item.name = last, strings.ToLower(tokens[last])
+	case last >= 1 && bare(tokens[last]) && (tokens[last-1] == ")" || tokens[last-1] == "'" || strings.EqualFold(tokens[last-1], "END") || isIdentStart(tokens[last-1][0]) && !lineageKeywords[strings.ToUpper(tokens[last-1])]):
+		item.alias, item.name = last, strings.ToLower(tokens[last])
+	}
+	return item
+}
+
+// maskRowsPII replaces the values of columns named in kinds with [<kind>] and
+// returns the sorted names of the masked columns
+func maskRowsPII(kinds map[string]string, columns []string, rows [][]interface{}) []string {
+	var masked []string
+	for i, column := range columns {
+		kind, ok := kinds[strings.ToLower(column)]
+		if !ok {
+			continue
+		}
+		masked = append(masked, column)
+		for _, row := range rows {
+			if i < len(row) && row[i] != nil {
+				row[i] = "[" + kind + "]"
+			}
+		}
+	}
+	sort.Strings(masked)
+	return masked
+}
+
+// maskResultPII replaces the values of the result columns in kinds, from
+// piiKindsForSQL, with [<kind>]. It returns the results and the masked column
+// names; results that do not decode are returned unchanged.
+func maskResultPII(kinds map[string]string, results string) (string, []string) {
+	if len(kinds) == 0 {
+		return results, nil
+	}
+
+	// Numbers are kept as written, so large integers survive re-encoding
+	var set store.ResultSet
+	decoder := json.NewDecoder(strings.NewReader(results))
+	decoder.UseNumber()
+	if err := decoder.Decode(&set); err != nil {
+		return results, nil
+	}
+	masked := maskRowsPII(kinds, columnNames(set.Columns), set.Rows)
+	if len(masked) == 0 {
+		return results, nil
+	}
+	encoded, err := json.Marshal(set)
+	if err != nil {
+		return results, nil
+	}
+	return string(encoded), masked
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/NubeDev/air/internal/store"
+)
+
+// postAggregateKey names the post-aggregation step in a report definition.
+// It is applied in Go to the merged rows of a fan-out run, so cross-site
+// totals need no ETL:
+//
+//	"post_aggregate": {
+//	  "group_by":   ["month"],
+//	  "aggregates": [{"func": "sum", "column": "kwh", "as": "total_kwh"},
+//	                 {"func": "sum", "column": "kwh", "source": "site_a", "as": "site_a_kwh"}],
+//	  "computed":   [{"as": "site_a_share", "expr": "site_a_kwh / total_kwh * 100"}]
+//	}
+//
+// Rows are grouped by group_by (datasource_id among them keeps sources
+// apart); an aggregate with a source only reads that datasource's rows, which
+// computed columns combine with + - * / and parentheses.
+const postAggregateKey = "post_aggregate"
+
+// postAggregateFuncs are the aggregate functions a post-aggregation may use
+var postAggregateFuncs = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+
+// PostAggregate is the post-aggregation step of a report definition
+type PostAggregate struct {
+	GroupBy    []string              `json:"group_by,omitempty"`
+	Aggregates []PostAggregateColumn `json:"aggregates,omitempty"`
+	Computed   []PostComputedColumn  `json:"computed,omitempty"`
+}
+
+// PostAggregateColumn aggregates a column of every group, or with Source only
+// the rows of that datasource. count also takes "*" to count rows.
+type PostAggregateColumn struct {
+	Func   string `json:"func"`
+	Column string `json:"column"`
+	Source string `json:"source,omitempty"`
+	As     string `json:"as"`
+}
+
+// PostComputedColumn is arithmetic over the group-by columns, aggregates and
+// earlier computed columns of a group
+type PostComputedColumn struct {
+	As   string `json:"as"`
+	Expr string `json:"expr"`
+
+	expr *postExpr
+}
+
+// postAggregateFromDef reads and validates the post-aggregation step of a
+// report definition; nil when it has none
+func postAggregateFromDef(defJSON string) (*PostAggregate, error) {
+	var def map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(defJSON), &def); err != nil {
+		return nil, nil
+	}
+	raw, ok := def[postAggregateKey]
+	if !ok || string(raw) == "null" {
+		return nil, nil
+	}
+	return parsePostAggregate(raw)
+}
+
+// parsePostAggregate decodes a post-aggregation step and checks that its
+// functions are known, its output names unique and its expressions refer to
+// columns defined before them
+func parsePostAggregate(raw []byte) (*PostAggregate, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	var post PostAggregate
+	if err := decoder.Decode(&post); err != nil {
+		return nil, classErrorf(ErrValidation, "invalid %s: %v", postAggregateKey, err)
+	}
+	if len(post.Aggregates) == 0 && len(post.Computed) == 0 {
+		return nil, classErrorf(ErrValidation, "%s needs at least one aggregate or computed column", postAggregateKey)
+	}
+
+	names := make(map[string]bool)
+	define := func(name string) error {
+		if strings.TrimSpace(name) == "" {
+			return classErrorf(ErrValidation, "%s has a column without a name", postAggregateKey)
+		}
+		if names[name] {
+			return classErrorf(ErrValidation, "%s defines %s twice", postAggregateKey, name)
+		}
+		names[name] = true
+		return nil
+	}
+	for _, column := range post.GroupBy {
+		if err := define(column); err != nil {
+			return nil, err
+		}
+	}
+	for i := range post.Aggregates {
+		aggregate := &post.Aggregates[i]
+		aggregate.Func = strings.ToLower(strings.TrimSpace(aggregate.Func))
+		if !postAggregateFuncs[aggregate.Func] {
+			return nil, classErrorf(ErrValidation, "%s: unknown function %q for %s; use sum, avg, min, max or count", postAggregateKey, aggregate.Func, aggregate.As)
+		}
+		if aggregate.Column == "" || (aggregate.Column == "*" && aggregate.Func != "count") {
+			return nil, classErrorf(ErrValidation, "%s: %s needs a column", postAggregateKey, aggregate.As)
+		}
+		if err := define(aggregate.As); err != nil {
+			return nil, err
+		}
+	}
+	for i := range post.Computed {
+		computed := &post.Computed[i]
+		expr, err := parsePostExpr(computed.Expr)
+		if err != nil {
+			return nil, classErrorf(ErrValidation, "%s: expression of %s: %v", postAggregateKey, computed.As, err)
+		}
+		for _, name := range expr.names(nil) {
+			if !names[name] {
+				return nil, classErrorf(ErrValidation, "%s: expression of %s uses %s, which is not a group-by, aggregate or earlier computed column", postAggregateKey, computed.As, name)
+			}
+		}
+		if err := define(computed.As); err != nil {
+			return nil, err
+		}
+		computed.expr = expr
+	}
+	return &post, nil
+}
+
+// postGroup accumulates one group's aggregates
+type postGroup struct {
+	key    []interface{}
+	counts []int
+	sums   []float64
+	mins   []float64
+	maxs   []float64
+}
+
+// apply groups the rows of set, whose first column is the datasource ID, and
+// returns one row per group in order of first appearance
+func (post *PostAggregate) apply(set *store.ResultSet) (*store.ResultSet, error) {
+	positions := make(map[string]int, len(set.Columns))
+	for i, column := range set.Columns {
+		if _, ok := positions[column.Name]; !ok {
+			positions[column.Name] = i
+		}
+	}
+	position := func(name string) (int, error) {
+		i, ok := positions[name]
+		if !ok {
+			return 0, classErrorf(ErrValidation, "%s: the results have no column %s", postAggregateKey, name)
+		}
+		return i, nil
+	}
+
+	out := &store.ResultSet{Rows: [][]interface{}{}}
+	keyColumns := make([]int, len(post.GroupBy))
+	for i, name := range post.GroupBy {
+		p, err := position(name)
+		if err != nil {
+			return nil, err
+		}
+		keyColumns[i] = p
+		out.Columns = append(out.Columns, set.Columns[p])
+	}
+	valueColumns := make([]int, len(post.Aggregates))
+	for i, aggregate := range post.Aggregates {
+		valueColumns[i] = -1
+		if aggregate.Column != "*" {
+			p, err := position(aggregate.Column)
+			if err != nil {
+				return nil, err
+			}
+			valueColumns[i] = p
+		}
+		columnType := "NUMERIC"
+		if aggregate.Func == "count" {
+			columnType = "INTEGER"
+		}
+		out.Columns = append(out.Columns, store.ResultColumn{Name: aggregate.As, Type: columnType})
+	}
+	for _, computed := range post.Computed {
+		out.Columns = append(out.Columns, store.ResultColumn{Name: computed.As, Type: "NUMERIC"})
+	}
+
+	var groups []*postGroup
+	byKey := make(map[string]*postGroup)
+	for _, row := range set.Rows {
+		key := make([]interface{}, len(keyColumns))
+		for i, p := range keyColumns {
+			if p < len(row) {
+				key[i] = row[p]
+			}
+		}
+		encoded, _ := json.Marshal(key)
+		group, ok := byKey[string(encoded)]
+		if !ok {
+			n := len(post.Aggregates)
+			group = &postGroup{key: key, counts: make([]int, n), sums: make([]float64, n), mins: make([]float64, n), maxs: make([]float64, n)}
+			for i := range group.mins {
+				group.mins[i], group.maxs[i] = math.Inf(1), math.Inf(-1)
+			}
+			byKey[string(encoded)] = group
+			groups = append(groups, group)
+		}
+
+		for i, aggregate := range post.Aggregates {
+			if aggregate.Source != "" && (len(row) == 0 || row[0] != aggregate.Source) {
+				continue
+			}
+			p := valueColumns[i]
+			if p < 0 {
+				group.counts[i]++
+				continue
+			}
+			if p >= len(row) || row[p] == nil {
+				continue
+			}
+			if aggregate.Func == "count" {
+				group.counts[i]++
+				continue
+			}
+			v, ok := summaryNumber(row[p], isNumericColumnType(set.Columns[p].Type))
+			if !ok {
+				return nil, classErrorf(ErrValidation, "%s: %s of %s: %v is not a number", postAggregateKey, aggregate.Func, aggregate.Column, row[p])
+			}
+			group.counts[i]++
+			group.sums[i] += v
+			group.mins[i] = math.Min(group.mins[i], v)
+			group.maxs[i] = math.Max(group.maxs[i], v)
+		}
+	}
+
+	for _, group := range groups {
+		row := append([]interface{}{}, group.key...)
+		values := make(map[string]float64)
+		for i, name := range post.GroupBy {
+			if v, ok := summaryNumber(group.key[i], isNumericColumnType(set.Columns[keyColumns[i]].Type)); ok {
+				values[name] = v
+			}
+		}
+		for i, aggregate := range post.Aggregates {
+			var value interface{}
+			switch {
+			case aggregate.Func == "count":
+				value = group.counts[i]
+			case group.counts[i] == 0:
+				// Nothing to aggregate: null, not zero
+			case aggregate.Func == "sum":
+				value = group.sums[i]
+			case aggregate.Func == "avg":
+				value = group.sums[i] / float64(group.counts[i])
+			case aggregate.Func == "min":
+				value = group.mins[i]
+			case aggregate.Func == "max":
+				value = group.maxs[i]
+			}
+			switch v := value.(type) {
+			case int:
+				values[aggregate.As] = float64(v)
+			case float64:
+				values[aggregate.As] = v
+			}
+			row = append(row, value)
+		}
+		for _, computed := range post.Computed {
+			var value interface{}
+			if v, ok := computed.expr.eval(values); ok {
+				values[computed.As] = v
+				value = v
+			}
+			row = append(row, value)
+		}
+		out.Rows = append(out.Rows, row)
+	}
+	return out, nil
+}
+
+// postExpr is a node of a computed column's expression: a number, a column
+// name, or an operator applied to one (unary minus) or two operands
+type postExpr struct {
+	op          byte
+	number      float64
+	name        string
+	left, right *postExpr
+}
+
+// names lists the columns an expression reads
+func (e *postExpr) names(into []string) []string {
+	if e == nil {
+		return into
+	}
+	if e.op == 0 && e.name != "" {
+		into = append(into, e.name)
+	}
+	return e.right.names(e.left.names(into))
+}
+
+// eval computes an expression; false when a column it reads is null or it
+// divides by zero
+func (e *postExpr) eval(values map[string]float64) (float64, bool) {
+	if e.op == 0 {
+		if e.name == "" {
+			return e.number, true
+		}
+		v, ok := values[e.name]
+		return v, ok
+	}
+	left, ok := e.left.eval(values)
+	if !ok {
+		return 0, false
+	}
+	if e.right == nil {
+		return -left, true
+	}
+	right, ok := e.right.eval(values)
+	if !ok {
+		return 0, false
+	}
+	switch e.op {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	case '/':
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+	return 0, false
+}
+
+// parsePostExpr parses arithmetic over numbers and column names, quoted with
+// double quotes when they aren't plain identifiers
+func parsePostExpr(text string) (*postExpr, error) {
+	p := &postExprParser{text: text}
+	expr, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.text) {
+		return nil, fmt.Errorf("unexpected %q at %d", p.text[p.pos:], p.pos)
+	}
+	return expr, nil
+}
+
+type postExprParser struct {
+	text string
+	pos  int
+}
+
+func (p *postExprParser) skipSpace() {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+// sum parses terms joined by + and -
+func (p *postExprParser) sum() (*postExpr, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.pos < len(p.text) && (p.text[p.pos] == '+' || p.text[p.pos] == '-'); p.skipSpace() {
+		op := p.text[p.pos]
+		p.pos++
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = &postExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// product parses operands joined by * and /
+func (p *postExprParser) product() (*postExpr, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.skipSpace(); p.pos < len(p.text) && (p.text[p.pos] == '*' || p.text[p.pos] == '/'); p.skipSpace() {
+		op := p.text[p.pos]
+		p.pos++
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &postExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// operand parses a number, a column name, a negated operand or a
+// parenthesized expression
+func (p *postExprParser) operand() (*postExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	start := p.pos
+	switch c := p.text[p.pos]; {
+	case c == '(':
+		p.pos++
+		expr, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.skipSpace(); p.pos >= len(p.text) || p.text[p.pos] != ')' {
+			return nil, fmt.Errorf("missing ) for ( at %d", start)
+		}
+		p.pos++
+		return expr, nil
+	case c == '-':
+		p.pos++
+		operand, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return &postExpr{op: '-', left: operand}, nil
+	case c == '"':
+		end := strings.IndexByte(p.text[p.pos+1:], '"')
+		if end <= 0 {
+			return nil, fmt.Errorf("unterminated or empty quoted name at %d", start)
+		}
+		p.pos += end + 2
+		return &postExpr{name: p.text[start+1 : p.pos-1]}, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		for p.pos < len(p.text) && (p.text[p.pos] == '.' || (p.text[p.pos] >= '0' && p.text[p.pos] <= '9')) {
+			p.pos++
+		}
+		number, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.text[start:p.pos])
+		}
+		return &postExpr{number: number}, nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.text) && (p.text[p.pos] == '_' || unicode.IsLetter(rune(p.text[p.pos])) || unicode.IsDigit(rune(p.text[p.pos]))) {
+			p.pos++
+		}
+		return &postExpr{name: p.text[start:p.pos]}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", p.text[p.pos], p.pos)
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// relevanceSort orders report lists for the caller: favorites, then the
+	// reports they used most recently, then the rest by last update
+	relevanceSort = "relevance"
+
+	// defaultRecentReports is how many reports /v1/reports/recent returns by default
+	defaultRecentReports = 20
+)
+
+// accessKey is the conflict target of report access upserts
+var accessKey = []clause.Column{{Name: "username"}, {Name: "report_id"}}
+
+// TrackReportAccess records that a user opened or ran a report. Tracking is
+// best effort: anonymous callers are skipped and failures are only logged.
+func (s *ReportsService) TrackReportAccess(user string, reportID uint) {
+	if user == "" {
+		return
+	}
+	now := time.Now()
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: accessKey,
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"access_count":     gorm.Expr("access_count + 1"),
+			"last_accessed_at": now,
+		}),
+	}).Create(&store.ReportAccess{Username: user, ReportID: reportID, AccessCount: 1, LastAccessedAt: &now}).Error
+	if err != nil {
+		logger.LogWarn(logger.ServiceREST, "Failed to track report access", map[string]interface{}{
+			"user":      user,
+			"report_id": reportID,
+			"error":     err.Error(),
+		})
+	}
+}
+
+// SetReportFavorite marks or unmarks a report as one of the user's favorites
+func (s *ReportsService) SetReportFavorite(reportID uint, user string, favorite bool) (*store.Report, error) {
+	if user == "" {
+		return nil, classErrorf(ErrValidation, "favorites need an authenticated user")
+	}
+	report, err := s.GetReportByID(reportID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found")
+	}
+
+	var favoritedAt *time.Time
+	if favorite {
+		now := time.Now()
+		favoritedAt = &now
+	}
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   accessKey,
+		DoUpdates: clause.Assignments(map[string]interface{}{"favorite": favorite, "favorited_at": favoritedAt}),
+	}).Create(&store.ReportAccess{Username: user, ReportID: reportID, Favorite: favorite, FavoritedAt: favoritedAt}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save favorite: %w", err)
+	}
+
+	reports := []store.Report{*report}
+	if err := s.annotateReportAccess(user, reports); err != nil {
+		return nil, err
+	}
+	return &reports[0], nil
+}
+
+// RecentReports returns the reports a user opened or ran, most recent first.
+// With favorites set it returns the user's favorites instead.
+func (s *ReportsService) RecentReports(user string, favorites bool, limit int) ([]store.Report, error) {
+	if limit <= 0 {
+		limit = defaultRecentReports
+	}
+	query := s.db.Where("username = ?", user)
+	if favorites {
+		query = query.Where("favorite = ?", true)
+	} else {
+		query = query.Where("last_accessed_at IS NOT NULL")
+	}
+	var accesses []store.ReportAccess
+	if err := query.Order("last_accessed_at DESC").Limit(PageLimit(limit)).Find(&accesses).Error; err != nil {
+		return nil, fmt.Errorf("failed to list recent reports: %w", err)
+	}
+
+	ids := make([]uint, len(accesses))
+	for i, access := range accesses {
+		ids[i] = access.ReportID
+	}
+	var found []store.Report
+	if err := s.db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to load recent reports: %w", err)
+	}
+	byID := make(map[uint]store.Report, len(found))
+	for _, report := range found {
+		byID[report.ID] = report
+	}
+
+	reports := make([]store.Report, 0, len(accesses))
+	for _, access := range accesses {
+		report, ok := byID[access.ReportID]
+		if !ok {
+			continue
+		}
+		report.Favorite = access.Favorite
+		report.LastAccessedAt = access.LastAccessedAt
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// listReportsByRelevance pages reports in relevance order. The order moves as
+// the caller uses reports, so its cursors are offsets rather than keys.
+func (s *ReportsService) listReportsByRelevance(opts store.ListOptions, user string) ([]store.Report, *store.PageInfo, error) {
+	query := s.db.Model(&store.Report{})
+	for name, value := range opts.Filters {
+		column, ok := reportList.Filters[name]
+		if !ok {
+			return nil, nil, classErrorf(ErrValidation, "cannot filter by %q", name)
+		}
+		query = query.Where("reports."+column+" = ?", value)
+	}
+
+	page := &store.PageInfo{}
+	if opts.IncludeTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, nil, err
+		}
+		page.Total = &total
+	}
+
+	offset := 0
+	if opts.Cursor != "" {
+		raw, err := DecodeCursor(opts.Cursor, relevanceSort)
+		if err != nil {
+			return nil, nil, err
+		}
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 {
+			return nil, nil, classErrorf(ErrValidation, "invalid cursor")
+		}
+	}
+
+	limit := PageLimit(opts.Limit)
+	var reports []store.Report
+	err := query.Select("reports.*").
+		Joins("LEFT JOIN report_accesses ON report_accesses.report_id = reports.id AND report_accesses.username = ?", user).
+		Order("CASE WHEN report_accesses.favorite THEN 0 ELSE 1 END, report_accesses.last_accessed_at IS NULL, " +
+			"report_accesses.last_accessed_at DESC, reports.updated_at DESC, reports.id DESC").
+		Offset(offset).Limit(limit + 1).Find(&reports).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(reports) > limit {
+		reports = reports[:limit]
+		page.NextCursor = EncodeCursor(relevanceSort, strconv.Itoa(offset+limit))
+	}
+	return reports, page, nil
+}
+
+// annotateReportAccess sets Favorite and LastAccessedAt on reports for user
+func (s *ReportsService) annotateReportAccess(user string, reports []store.Report) error {
+	if user == "" || len(reports) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(reports))
+	for i, report := range reports {
+		ids[i] = report.ID
+	}
+	var accesses []store.ReportAccess
+	if err := s.db.Where("username = ? AND report_id IN ?", user, ids).Find(&accesses).Error; err != nil {
+		return fmt.Errorf("failed to load report access: %w", err)
+	}
+	byReport := make(map[uint]store.ReportAccess, len(accesses))
+	for _, access := range accesses {
+		byReport[access.ReportID] = access
+	}
+	for i := range reports {
+		access := byReport[reports[i].ID]
+		reports[i].Favorite = access.Favorite
+		reports[i].LastAccessedAt = access.LastAccessedAt
+	}
+	return nil
+}
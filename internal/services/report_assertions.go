@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// assertionOps are the comparisons a row_count assertion can make
+var assertionOps = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"=":  func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// preparedAssertion is an assertion whose reference query has the run's
+// params substituted
+type preparedAssertion struct {
+	store.ReportAssertion
+	referenceSQL string
+}
+
+// CreateReportAssertion attaches an assertion to a report
+func (s *ReportsService) CreateReportAssertion(reportID uint, req store.CreateReportAssertionRequest) (*store.ReportAssertion, error) {
+	var report store.Report
+	if err := s.db.First(&report, reportID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, classErrorf(ErrNotFound, "report not found")
+		}
+		return nil, fmt.Errorf("failed to find report: %w", err)
+	}
+
+	assertion := &store.ReportAssertion{
+		ReportID:     reportID,
+		Name:         strings.TrimSpace(req.Name),
+		Kind:         req.Kind,
+		ColumnName:   req.Column,
+		Op:           req.Op,
+		ReferenceSQL: strings.TrimSpace(req.ReferenceSQL),
+		Tolerance:    req.Tolerance,
+		Critical:     req.Critical,
+	}
+	switch req.Kind {
+	case "row_count":
+		if _, ok := assertionOps[req.Op]; !ok {
+			return nil, classErrorf(ErrValidation, "row_count assertions need op >, >=, <, <=, = or !=")
+		}
+		if req.Value == nil {
+			return nil, classErrorf(ErrValidation, "row_count assertions need a value")
+		}
+		assertion.Value = *req.Value
+	case "not_null":
+		if req.Column == "" {
+			return nil, classErrorf(ErrValidation, "not_null assertions need a column")
+		}
+	case "reference":
+		if req.Column == "" || assertion.ReferenceSQL == "" {
+			return nil, classErrorf(ErrValidation, "reference assertions need a column and reference_sql")
+		}
+		if req.Tolerance < 0 {
+			return nil, classErrorf(ErrValidation, "tolerance must not be negative")
+		}
+		if _, err := ValidateReadOnlySQL(assertion.ReferenceSQL); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, classErrorf(ErrValidation, "kind must be row_count, not_null or reference")
+	}
+
+	if err := s.db.Create(assertion).Error; err != nil {
+		return nil, fmt.Errorf("failed to create assertion: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report assertion created", map[string]interface{}{
+		"report_id": reportID,
+		"name":      assertion.Name,
+		"kind":      assertion.Kind,
+		"critical":  assertion.Critical,
+	})
+	return assertion, nil
+}
+
+// ListReportAssertions lists a report's assertions with their latest results
+func (s *ReportsService) ListReportAssertions(reportID uint) ([]store.ReportAssertion, error) {
+	var assertions []store.ReportAssertion
+	if err := s.db.Where("report_id = ?", reportID).Order("id ASC").Find(&assertions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list assertions: %w", err)
+	}
+	for i := range assertions {
+		var last store.ReportAssertionResult
+		err := s.db.Where("assertion_id = ?", assertions[i].ID).Order("id DESC").First(&last).Error
+		if err == nil {
+			assertions[i].LastResult = &last
+		} else if err != gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("failed to load assertion results: %w", err)
+		}
+	}
+	return assertions, nil
+}
+
+// DeleteReportAssertion removes an assertion and its results
+func (s *ReportsService) DeleteReportAssertion(reportID, assertionID uint) error {
+	result := s.db.Where("id = ? AND report_id = ?", assertionID, reportID).Delete(&store.ReportAssertion{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete assertion: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return classErrorf(ErrNotFound, "assertion not found")
+	}
+	return s.db.Where("assertion_id = ?", assertionID).Delete(&store.ReportAssertionResult{}).Error
+}
+
+// ListRunAssertionResults lists the assertion results recorded for a run
+func (s *ReportsService) ListRunAssertionResults(runID uint) ([]store.ReportAssertionResult, error) {
+	var results []store.ReportAssertionResult
+	if err := s.db.Where("run_id = ?", runID).Order("id ASC").Find(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to list assertion results: %w", err)
+	}
+	return results, nil
+}
+
+// prepareAssertions loads a report's assertions and substitutes the run's
+// params into their reference queries
+func (s *ReportsService) prepareAssertions(report *store.Report, connector *datasource.DatasourceConnector, params map[string]interface{}, user string, now time.Time) ([]preparedAssertion, error) {
+	var assertions []store.ReportAssertion
+	if err := s.db.Where("report_id = ?", report.ID).Order("id ASC").Find(&assertions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load assertions: %w", err)
+	}
+	prepared := make([]preparedAssertion, len(assertions))
+	for i, a := range assertions {
+		prepared[i].ReportAssertion = a
+		if a.Kind != "reference" {
+			continue
+		}
+		sqlText, _, err := prepareReportSQL(report, connector, a.ReferenceSQL, params, user, now)
+		if err != nil {
+			return nil, fmt.Errorf("assertion %s: %w", a.Name, err)
+		}
+		prepared[i].referenceSQL = sqlText
+	}
+	return prepared, nil
+}
+
+// evaluateAssertions checks a result against the prepared assertions and
+// records a result for each. runID is nil for snapshot refreshes. Reference
+// queries run on the connector the result came from.
+func (s *ReportsService) evaluateAssertions(report *store.Report, assertions []preparedAssertion, connector *datasource.DatasourceConnector, runID *uint, columns []string, rows [][]interface{}, rowCount int) []store.ReportAssertionResult {
+	results := make([]store.ReportAssertionResult, 0, len(assertions))
+	for _, a := range assertions {
+		passed, detail := s.checkAssertion(report, a, connector, columns, rows, rowCount)
+		result := store.ReportAssertionResult{
+			AssertionID: a.ID,
+			ReportID:    report.ID,
+			RunID:       runID,
+			Name:        a.Name,
+			Critical:    a.Critical,
+			Passed:      passed,
+			Detail:      detail,
+		}
+		if err := s.db.Create(&result).Error; err != nil {
+			logger.LogError(logger.ServiceREST, "Failed to record assertion result", err, map[string]interface{}{
+				"report_id": report.ID,
+				"assertion": a.Name,
+			})
+		}
+		if !passed {
+			logger.LogWarn(logger.ServiceREST, "Report assertion failed", map[string]interface{}{
+				"report_id": report.ID,
+				"assertion": a.Name,
+				"critical":  a.Critical,
+				"detail":    detail,
+			})
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkAssertion reports whether a result passes one assertion, with what was
+// measured
+func (s *ReportsService) checkAssertion(report *store.Report, a preparedAssertion, connector *datasource.DatasourceConnector, columns []string, rows [][]interface{}, rowCount int) (bool, string) {
+	if a.Kind == "row_count" {
+		detail := fmt.Sprintf("%d rows, expected %s %s", rowCount, a.Op, formatNumber(a.Value))
+		return assertionOps[a.Op](float64(rowCount), a.Value), detail
+	}
+
+	column := -1
+	for i, name := range columns {
+		if strings.EqualFold(name, a.ColumnName) {
+			column = i
+			break
+		}
+	}
+	if column < 0 {
+		return false, fmt.Sprintf("results have no column %s", a.ColumnName)
+	}
+
+	switch a.Kind {
+	case "not_null":
+		nulls := 0
+		for _, row := range rows {
+			if row[column] == nil {
+				nulls++
+			}
+		}
+		return nulls == 0, fmt.Sprintf("%d of %d rows have a NULL %s", nulls, len(rows), a.ColumnName)
+	case "reference":
+		var total float64
+		for _, row := range rows {
+			if row[column] == nil {
+				continue
+			}
+			f, ok := numberValue(row[column])
+			if !ok {
+				return false, fmt.Sprintf("column %s value %v is not a number", a.ColumnName, row[column])
+			}
+			total += f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.runTimeout(false))
+		defer cancel()
+		tagged := queryComment{Report: report.Key, User: "assertion"}.apply(a.referenceSQL)
+		_, refRows, err := queryTypedRows(ctx, connector.DB, tagged)
+		if err != nil {
+			return false, fmt.Sprintf("reference query failed: %v", err)
+		}
+		if len(refRows) == 0 || len(refRows[0]) == 0 {
+			return false, "reference query returned no rows"
+		}
+		reference, ok := numberValue(refRows[0][0])
+		if !ok {
+			return false, fmt.Sprintf("reference value %v is not a number", refRows[0][0])
+		}
+		diff := math.Abs(total - reference)
+		allowed := a.Tolerance * math.Abs(reference)
+		detail := fmt.Sprintf("%s totals %s, reference %s, tolerance %s", a.ColumnName,
+			formatNumber(total), formatNumber(reference), formatNumber(a.Tolerance))
+		return diff <= allowed+1e-9, detail
+	}
+	return false, fmt.Sprintf("unknown assertion kind %s", a.Kind)
+}
+
+// failedCritical names the critical assertions that failed
+func failedCritical(results []store.ReportAssertionResult) []string {
+	var names []string
+	for _, r := range results {
+		if r.Critical && !r.Passed {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// formatNumber prints a measured value without float noise
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1e6)/1e6, 'f', -1, 64)
+}
+
+// numberValue reads a result value as a number. Decimals may come back as
+// strings or bytes depending on the driver.
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case []byte:
+		return numberValue(string(n))
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
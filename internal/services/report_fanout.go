@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// maxFanOutSources caps the datasources one fan-out run may target
+const maxFanOutSources = 50
+
+// fanOutSourceColumn leads every merged row with the datasource it came from
+const fanOutSourceColumn = "datasource_id"
+
+// FanOutRun is a portable report run against several datasources, with the
+// outcome of each and their rows merged into one result set
+type FanOutRun struct {
+	ReportID  uint                 `json:"report_id"`
+	ReportKey string               `json:"report_key"`
+	TraceID   string               `json:"trace_id"`
+	Status    string               `json:"status"` // "completed", "partial" or "failed"
+	Sources   []FanOutSource       `json:"sources"`
+	Columns   []store.ResultColumn `json:"columns"`
+	Rows      [][]interface{}      `json:"rows"`
+	RowCount  int                  `json:"row_count"`
+
+	// Aggregate is the merged rows after the definition's post_aggregate step
+	Aggregate      *store.ResultSet `json:"aggregate,omitempty"`
+	AggregateError string           `json:"aggregate_error,omitempty"`
+}
+
+// FanOutSource is the outcome of a fan-out run on one datasource
+type FanOutSource struct {
+	DatasourceID string `json:"datasource_id"`
+	Status       string `json:"status"` // "completed" or "failed"
+	RunID        *uint  `json:"run_id,omitempty"`
+	RowCount     int    `json:"row_count"`
+	Truncated    bool   `json:"truncated,omitempty"`
+	ErrorText    string `json:"error_text,omitempty"`
+	DurationMs   int64  `json:"duration_ms"`
+
+	results *store.ResultSet
+}
+
+// RunReportFanOut runs a portable report (one whose latest version names no
+// datasource) against every requested datasource, safety.fan_out_concurrency
+// at a time. Each datasource gets a run of its own; a failing one doesn't
+// stop the others. Rows are merged keyed by datasource, their columns the
+// union of every source's in order of first appearance. A post_aggregate
+// step in the definition is then applied to the completed sources' rows.
+func (s *ReportsService) RunReportFanOut(reportID uint, req store.FanOutRunRequest) (*FanOutRun, error) {
+	start := time.Now()
+	datasourceIDs, err := fanOutDatasources(req.DatasourceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.GetReportByID(reportID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found: %w", err)
+	}
+	var reportVersion store.ReportVersion
+	err = s.db.Scopes(approvedVersions).Where("report_id = ?", report.ID).Order("version DESC").First(&reportVersion).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, classErrorf(ErrNotFound, "report %d has no approved versions", reportID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find report version: %w", err)
+	}
+	if reportVersion.DatasourceID != nil && *reportVersion.DatasourceID != "" {
+		return nil, classErrorf(ErrValidation, "report %s is bound to datasource %s; only portable reports can run on several", report.Key, *reportVersion.DatasourceID)
+	}
+
+	fanOut := &FanOutRun{
+		ReportID:  report.ID,
+		ReportKey: report.Key,
+		TraceID:   runTraceID(req.TraceID),
+		Sources:   make([]FanOutSource, len(datasourceIDs)),
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, s.safety.FanOutConcurrency)
+	for i, datasourceID := range datasourceIDs {
+		wg.Add(1)
+		go func(source *FanOutSource, datasourceID string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			*source = s.runFanOutSource(report.Key, datasourceID, fanOut.TraceID, req)
+		}(&fanOut.Sources[i], datasourceID)
+	}
+	wg.Wait()
+
+	succeeded, failed := 0, 0
+	for _, source := range fanOut.Sources {
+		if source.Status == "completed" {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	fanOut.Status = "completed"
+	if failed > 0 && succeeded > 0 {
+		fanOut.Status = "partial"
+	} else if failed > 0 {
+		fanOut.Status = "failed"
+	}
+	fanOut.Columns, fanOut.Rows = mergeFanOutResults(fanOut.Sources)
+	fanOut.RowCount = len(fanOut.Rows)
+	if succeeded > 0 {
+		post, err := postAggregateFromDef(reportVersion.DefJSON)
+		if err == nil && post != nil {
+			fanOut.Aggregate, err = post.apply(&store.ResultSet{Columns: fanOut.Columns, Rows: fanOut.Rows})
+		}
+		if err != nil {
+			fanOut.AggregateError = err.Error()
+		}
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Fan-out run finished", map[string]interface{}{
+		"trace_id":    fanOut.TraceID,
+		"report_id":   report.ID,
+		"datasources": len(datasourceIDs),
+		"status":      fanOut.Status,
+		"succeeded":   succeeded,
+		"failed":      failed,
+		"rows":        fanOut.RowCount,
+		"duration":    time.Since(start).String(),
+	})
+	return fanOut, nil
+}
+
+// fanOutDatasources trims and de-duplicates the requested datasources,
+// keeping their order
+func fanOutDatasources(requested []string) ([]string, error) {
+	seen := make(map[string]bool, len(requested))
+	datasourceIDs := make([]string, 0, len(requested))
+	for _, id := range requested {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		datasourceIDs = append(datasourceIDs, id)
+	}
+	if len(datasourceIDs) == 0 {
+		return nil, classErrorf(ErrValidation, "datasource_ids must name at least one datasource")
+	}
+	if len(datasourceIDs) > maxFanOutSources {
+		return nil, classErrorf(ErrValidation, "a fan-out run targets at most %d datasources, got %d", maxFanOutSources, len(datasourceIDs))
+	}
+	return datasourceIDs, nil
+}
+
+// runFanOutSource runs the report on one datasource and waits for it
+func (s *ReportsService) runFanOutSource(reportKey, datasourceID, traceID string, req store.FanOutRunRequest) (source FanOutSource) {
+	started := time.Now()
+	source = FanOutSource{DatasourceID: datasourceID, Status: "failed"}
+	defer func() { source.DurationMs = time.Since(started).Milliseconds() }()
+
+	run, err := s.RunReport(reportKey, store.RunReportRequest{
+		Params:       req.Params,
+		DatasourceID: &datasourceID,
+		User:         req.User,
+		TraceID:      traceID,
+	})
+	if err != nil {
+		source.ErrorText = err.Error()
+		return source
+	}
+	source.RunID = &run.ID
+	if run.Status == "failed" {
+		source.ErrorText = run.ErrorText
+		return source
+	}
+
+	results, err := ParseRunResults(run.Results)
+	if err != nil {
+		source.ErrorText = err.Error()
+		return source
+	}
+	source.Status = "completed"
+	source.RowCount = run.RowCount
+	source.Truncated = run.Truncated
+	source.results = results
+	return source
+}
+
+// mergeFanOutResults stacks the rows of the completed sources, each led by
+// its datasource ID. A source missing a column has nil in it.
+func mergeFanOutResults(sources []FanOutSource) ([]store.ResultColumn, [][]interface{}) {
+	columns := []store.ResultColumn{{Name: fanOutSourceColumn}}
+	positions := make(map[string]int)
+	for _, source := range sources {
+		if source.results == nil {
+			continue
+		}
+		for _, column := range source.results.Columns {
+			if _, ok := positions[column.Name]; !ok {
+				positions[column.Name] = len(columns)
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	rows := [][]interface{}{}
+	for _, source := range sources {
+		if source.results == nil {
+			continue
+		}
+		for _, row := range source.results.Rows {
+			merged := make([]interface{}, len(columns))
+			merged[0] = source.DatasourceID
+			for i, column := range source.results.Columns {
+				if i < len(row) {
+					merged[positions[column.Name]] = row[i]
+				}
+			}
+			rows = append(rows, merged)
+		}
+	}
+	return columns, rows
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Param types are inferred from how a placeholder is used: the column it is
+// compared with (its learned type), a cast around it, or its place in LIMIT
+// and OFFSET. Placeholders used nowhere typed fall back to their name.
+const (
+	paramPattern  = `\{\{\s*(\w+)\s*\}\}`
+	columnPattern = `((?:[A-Za-z_][\w$]*\.)*[A-Za-z_][\w$]*)`
+	compareOp     = `\s*(?:=|<>|!=|<=|>=|<|>|\s(?:NOT\s+)?I?LIKE\s)\s*`
+)
+
+var (
+	quotedParamRe  = regexp.MustCompile(`'\s*` + paramPattern + `\s*'`)
+	paramCompareRe = regexp.MustCompile(`(?i)` + columnPattern + compareOp + paramPattern)
+	compareParamRe = regexp.MustCompile(`(?i)` + paramPattern + compareOp + columnPattern)
+	paramInRe      = regexp.MustCompile(`(?i)` + columnPattern + `\s+(?:NOT\s+)?IN\s*\(\s*` + paramPattern + `\s*\)`)
+	paramBetweenRe = regexp.MustCompile(`(?i)` + columnPattern + `\s+(?:NOT\s+)?BETWEEN\s+(\S+)\s+AND\s+(\S+)`)
+	paramLimitRe   = regexp.MustCompile(`(?i)\b(?:LIMIT|OFFSET|TOP|FIRST|NEXT)\s*\(?\s*` + paramPattern)
+	paramCastRe    = regexp.MustCompile(`(?i)\bCAST\s*\(\s*` + paramPattern + `\s+AS\s+(\w+)`)
+	paramColonRe   = regexp.MustCompile(`(?i)` + paramPattern + `\s*::\s*(\w+)`)
+	paramDateFnRe  = regexp.MustCompile(`(?i)\b(DATE|DATETIME|TIMESTAMP)\s*\(\s*` + paramPattern)
+)
+
+// paramColumnKey is the schema extension naming the table.column a param is
+// compared with, when it resolved to a learned column
+const paramColumnKey = "x-air-column"
+
+// paramType is the JSON Schema type and format of a param
+type paramType struct {
+	Type   string
+	Format string
+}
+
+// paramUse is what one usage of a placeholder says about its param
+type paramUse struct {
+	column string // compared column, possibly qualified
+	typ    paramType
+}
+
+// inferParamsSchema builds the JSON Schema of the caller params of sqlText.
+// Every placeholder outside comments, except the built-in variables, is a
+// required property. start_date and end_date are always dates, since runs
+// normalize them to one.
+func inferParamsSchema(sqlText string, learned schemaColumns) map[string]interface{} {
+	text := blockCommentRe.ReplaceAllString(sqlText, " ")
+	text = lineCommentRe.ReplaceAllString(text, " ")
+	text = placeholderRe.ReplaceAllString(text, "{{$1}}")
+	text = quotedParamRe.ReplaceAllString(text, "{{$1}}")
+
+	uses := make(map[string][]paramUse)
+	limits := make(map[string]bool)
+	for _, m := range paramCastRe.FindAllStringSubmatch(text, -1) {
+		uses[m[1]] = append(uses[m[1]], paramUse{typ: columnParamType(m[2])})
+	}
+	for _, m := range paramColonRe.FindAllStringSubmatch(text, -1) {
+		uses[m[1]] = append(uses[m[1]], paramUse{typ: columnParamType(m[2])})
+	}
+	for _, m := range paramDateFnRe.FindAllStringSubmatch(text, -1) {
+		uses[m[2]] = append(uses[m[2]], paramUse{typ: columnParamType(m[1])})
+	}
+	for _, m := range paramLimitRe.FindAllStringSubmatch(text, -1) {
+		uses[m[1]] = append(uses[m[1]], paramUse{typ: paramType{Type: "integer"}})
+		limits[m[1]] = true
+	}
+	for _, m := range paramCompareRe.FindAllStringSubmatch(text, -1) {
+		uses[m[2]] = append(uses[m[2]], paramUse{column: m[1]})
+	}
+	for _, m := range compareParamRe.FindAllStringSubmatch(text, -1) {
+		uses[m[1]] = append(uses[m[1]], paramUse{column: m[2]})
+	}
+	for _, m := range paramInRe.FindAllStringSubmatch(text, -1) {
+		uses[m[2]] = append(uses[m[2]], paramUse{column: m[1]})
+	}
+	for _, m := range paramBetweenRe.FindAllStringSubmatch(text, -1) {
+		for _, bound := range m[2:] {
+			if p := placeholderRe.FindStringSubmatch(bound); p != nil {
+				uses[p[1]] = append(uses[p[1]], paramUse{column: m[1]})
+			}
+		}
+	}
+
+	refs := extractLineage(sqlText, learned)
+	properties := make(map[string]interface{})
+	required := []string{}
+	for _, name := range extractSQLPlaceholders(text) {
+		if isBuiltinTemplateVar(name) {
+			continue
+		}
+		property := map[string]interface{}{"title": paramTitle(name)}
+		typ := paramType{}
+		for _, use := range uses[name] {
+			if use.column != "" {
+				table, column, columnType := resolveParamColumn(use.column, refs, learned)
+				if table != "" {
+					if _, ok := property[paramColumnKey]; !ok {
+						property[paramColumnKey] = table + "." + column
+					}
+				}
+				use.typ = columnParamType(columnType)
+			}
+			if typ.Type == "" {
+				typ = use.typ
+			}
+		}
+		if typ.Type == "" || isDateParamName(name) {
+			typ = namedParamType(name)
+		}
+		property["type"] = typ.Type
+		if typ.Format != "" {
+			property["format"] = typ.Format
+		}
+		if limits[name] {
+			property["minimum"] = 0
+		}
+		properties[name] = property
+		required = append(required, name)
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// resolveParamColumn finds the table and learned type of a compared column
+// among the columns the query reads
+func resolveParamColumn(ref string, refs []lineageRef, learned schemaColumns) (string, string, string) {
+	ref = strings.ToLower(ref)
+	column, qualifier := lastSegment(ref), ""
+	if dot := strings.LastIndex(ref, "."); dot >= 0 {
+		qualifier = ref[:dot]
+	}
+
+	var table string
+	for _, r := range refs {
+		if r.Column != column {
+			continue
+		}
+		if table == "" || r.Table == qualifier || lastSegment(r.Table) == lastSegment(qualifier) {
+			table = r.Table
+		}
+	}
+	if table == "" {
+		return "", column, ""
+	}
+	cols, _ := learned.lookup(table)
+	return table, column, cols[column]
+}
+
+// columnParamType maps a database type name to a JSON Schema type; unknown
+// types give none
+func columnParamType(dbType string) paramType {
+	t := strings.ToLower(dbType)
+	switch {
+	case t == "":
+		return paramType{}
+	case strings.Contains(t, "bool"):
+		return paramType{Type: "boolean"}
+	case strings.Contains(t, "timestamp"), strings.Contains(t, "datetime"):
+		return paramType{Type: "string", Format: "date-time"}
+	case strings.Contains(t, "date"):
+		return paramType{Type: "string", Format: "date"}
+	case strings.Contains(t, "interval"), strings.Contains(t, "point"):
+		return paramType{Type: "string"}
+	case strings.Contains(t, "int"), strings.Contains(t, "serial"):
+		return paramType{Type: "integer"}
+	case strings.Contains(t, "numeric"), strings.Contains(t, "decimal"), strings.Contains(t, "float"),
+		strings.Contains(t, "double"), strings.Contains(t, "real"), strings.Contains(t, "money"),
+		strings.Contains(t, "number"):
+		return paramType{Type: "number"}
+	}
+	return paramType{Type: "string"}
+}
+
+// isDateParamName reports whether runs normalize a param to a calendar date
+func isDateParamName(name string) bool {
+	for _, dateName := range dateParamNames {
+		if name == dateName {
+			return true
+		}
+	}
+	return false
+}
+
+// namedParamType guesses the type of a param no usage typed from its name
+func namedParamType(name string) paramType {
+	lower := strings.ToLower(name)
+	switch {
+	case lower == "date", strings.HasSuffix(lower, "_date"):
+		return paramType{Type: "string", Format: "date"}
+	case lower == "limit", lower == "offset":
+		return paramType{Type: "integer"}
+	}
+	return paramType{Type: "string"}
+}
+
+// paramTitle turns a snake_case param name into a form label
+func paramTitle(name string) string {
+	words := strings.Fields(strings.ReplaceAll(name, "_", " "))
+	for i, word := range words {
+		if strings.EqualFold(word, "id") {
+			words[i] = "ID"
+			continue
+		}
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// attachParamsSchema stores the inferred params_schema in a report
+// definition that declares none, and adds the placeholders a declared schema
+// is missing. Definitions without SQL are returned as they are.
+func attachParamsSchema(db *gorm.DB, defJSON string, datasourceID *string) (string, error) {
+	var def map[string]interface{}
+	if err := json.Unmarshal([]byte(defJSON), &def); err != nil {
+		return defJSON, nil
+	}
+	sqlText, _ := def["sql"].(string)
+	if strings.TrimSpace(sqlText) == "" {
+		return defJSON, nil
+	}
+	if _, err := postAggregateFromDef(defJSON); err != nil {
+		return "", err
+	}
+	if _, err := runAsFromDef(defJSON); err != nil {
+		return "", err
+	}
+
+	id := ""
+	if datasourceID != nil {
+		id = *datasourceID
+	}
+	learned, err := learnedColumns(db, id)
+	if err != nil {
+		return "", err
+	}
+	inferred := inferParamsSchema(sqlText, learned)
+
+	declared, ok := def["params_schema"].(map[string]interface{})
+	if !ok {
+		def["params_schema"] = inferred
+	} else if err := checkParamDependencies(declared); err != nil {
+		return "", err
+	} else if !mergeParamsSchema(declared, inferred) {
+		return defJSON, nil
+	}
+	out, err := json.Marshal(def)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode report definition: %w", err)
+	}
+	return string(out), nil
+}
+
+// mergeParamsSchema adds the inferred properties declared lacks, as required
+// params, and reports whether it added any
+func mergeParamsSchema(declared, inferred map[string]interface{}) bool {
+	properties, _ := declared["properties"].(map[string]interface{})
+	if properties == nil {
+		properties = make(map[string]interface{})
+	}
+	required, _ := declared["required"].([]interface{})
+
+	added := false
+	for _, name := range inferred["required"].([]string) {
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		properties[name] = inferred["properties"].(map[string]interface{})[name]
+		required = append(required, name)
+		added = true
+	}
+	if added {
+		declared["properties"] = properties
+		declared["required"] = required
+	}
+	return added
+}
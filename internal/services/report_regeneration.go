@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NubeDev/air/internal/auth"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// approvedVersions leaves out proposed and rejected versions, which runs,
+// snapshots and exports never use
+func approvedVersions(db *gorm.DB) *gorm.DB {
+	return db.Where("status NOT IN ?", []string{"proposed", "rejected"})
+}
+
+// RegenerateReport regenerates the SQL of a report's latest stale version from
+// its scope's IR against the datasource's current learned schema, and saves it
+// as a proposed version that nothing runs until it is approved
+func (s *AIService) RegenerateReport(ctx context.Context, reportID uint, req store.RegenerateReportRequest) (*store.RegenerateReportResponse, error) {
+	var report store.Report
+	if err := s.db.First(&report, reportID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, classErrorf(ErrNotFound, "report not found")
+		}
+		return nil, fmt.Errorf("failed to find report: %w", err)
+	}
+
+	var stale store.ReportVersion
+	if err := s.db.Scopes(approvedVersions).Where("report_id = ? AND stale_at IS NOT NULL", reportID).
+		Order("version DESC").First(&stale).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, classErrorf(ErrConflict, "report has no stale version to regenerate")
+		}
+		return nil, fmt.Errorf("failed to find report version: %w", err)
+	}
+	var pending int64
+	if err := s.db.Model(&store.ReportVersion{}).Where("proposed_from = ? AND status = ?", stale.ID, "proposed").
+		Count(&pending).Error; err != nil {
+		return nil, fmt.Errorf("failed to check proposals: %w", err)
+	}
+	if pending > 0 {
+		return nil, classErrorf(ErrConflict, "version %d already has a proposal; approve or reject it first", stale.Version)
+	}
+
+	if stale.ScopeVersionID == nil {
+		return nil, classErrorf(ErrValidation, "version %d was written as SQL and has no IR to regenerate from", stale.Version)
+	}
+	var scopeVersion store.ScopeVersion
+	if err := s.db.First(&scopeVersion, *stale.ScopeVersionID).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "scope version not found")
+	}
+	var ir map[string]interface{}
+	if err := json.Unmarshal([]byte(scopeVersion.IRJSON), &ir); err != nil || len(ir) == 0 {
+		return nil, classErrorf(ErrValidation, "scope version %d has no IR; build it first", scopeVersion.Version)
+	}
+
+	datasourceID := req.DatasourceID
+	if stale.DatasourceID != nil && *stale.DatasourceID != "" {
+		datasourceID = *stale.DatasourceID
+	}
+	if datasourceID == "" {
+		return nil, classErrorf(ErrValidation, "datasource_id is required to regenerate a portable report")
+	}
+
+	logger.LogInfo(logger.ServiceAI, "Regenerating stale report", map[string]interface{}{
+		"report_id":     reportID,
+		"version":       stale.Version,
+		"datasource_id": datasourceID,
+	})
+
+	newSQL, _, err := s.GenerateSQLFromIR(ctx, store.GenerateSQLRequest{IR: ir, DatasourceID: datasourceID})
+	if err != nil {
+		return nil, err
+	}
+	safetyReport, err := ValidateReadOnlySQL(newSQL)
+	if err != nil {
+		return nil, err
+	}
+	defJSON, err := replaceDefSQL(stale.DefJSON, newSQL)
+	if err != nil {
+		return nil, err
+	}
+	if defJSON, err = attachParamsSchema(s.db, defJSON, stale.DatasourceID); err != nil {
+		return nil, err
+	}
+
+	checksum := sha256.Sum256([]byte(defJSON))
+	proposal := &store.ReportVersion{
+		ReportID:       reportID,
+		ScopeVersionID: stale.ScopeVersionID,
+		DatasourceID:   stale.DatasourceID,
+		DefJSON:        defJSON,
+		Checksum:       hex.EncodeToString(checksum[:]),
+		Status:         "proposed",
+		ProposedFrom:   &stale.ID,
+		CreatedBy:      auth.Username(ctx),
+		CreatedAt:      time.Now(),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var maxVersion int
+		if err := tx.Model(&store.ReportVersion{}).Where("report_id = ?", reportID).
+			Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+			return fmt.Errorf("failed to get max version: %w", err)
+		}
+		proposal.Version = maxVersion + 1
+		if err := tx.Create(proposal).Error; err != nil {
+			return fmt.Errorf("failed to create report version: %w", err)
+		}
+		return recordLineage(tx, proposal)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	oldSQL := extractSQLFromDef(stale.DefJSON)
+	logger.LogInfo(logger.ServiceAI, "Report regeneration proposed", map[string]interface{}{
+		"report_id":    reportID,
+		"from_version": stale.Version,
+		"version":      proposal.Version,
+	})
+	return &store.RegenerateReportResponse{
+		ReportID:     reportID,
+		FromVersion:  stale.Version,
+		Proposal:     proposal,
+		OldSQL:       oldSQL,
+		NewSQL:       newSQL,
+		SQLDiff:      unifiedDiff(fmt.Sprintf("v%d/query.sql", stale.Version), fmt.Sprintf("v%d/query.sql", proposal.Version), oldSQL, newSQL),
+		SafetyReport: safetyReport,
+	}, nil
+}
+
+// replaceDefSQL sets the sql of a report definition, keeping its other fields
+func replaceDefSQL(defJSON, sqlText string) (string, error) {
+	def := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(defJSON), &def); err != nil {
+		// Some definitions are stored as a JSON-encoded string of the object
+		var raw string
+		if json.Unmarshal([]byte(defJSON), &raw) != nil || json.Unmarshal([]byte(raw), &def) != nil {
+			return "", classErrorf(ErrValidation, "report definition is not a JSON object")
+		}
+	}
+	def["sql"] = sqlText
+	out, err := json.Marshal(def)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode report definition: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// checkApproved refuses a report version that no reviewer has approved when
+// the datasource it runs against requires approval
+func checkApproved(version *store.ReportVersion, connector *datasource.DatasourceConnector) error {
+	if !connector.NeedsReview || version.ReviewedAt != nil {
+		return nil
+	}
+	return classErrorf(ErrSafetyBlocked, "report version %d has not been approved to run against datasource %s", version.Version, connector.ID)
+}
+
+// ListPendingReviews lists the versions waiting for a reviewer: regenerated
+// versions still proposed, and unreviewed versions that runs or snapshots
+// would use against a datasource that requires approval
+func (s *ReportsService) ListPendingReviews() (*store.PendingReviewsResponse, error) {
+	var gated []string
+	for _, connector := range s.registry.ListDatasources() {
+		if connector.NeedsReview {
+			gated = append(gated, connector.ID)
+		}
+	}
+
+	pending := s.db.Where("status = ?", "proposed")
+	if len(gated) > 0 {
+		pending = pending.Or(s.db.Where("reviewed_at IS NULL AND status <> ?", "rejected").
+			Where("datasource_id IN ? AND id IN (?)", gated, liveReportVersions(s.db)))
+	}
+	var versions []store.ReportVersion
+	if err := s.db.Preload("Report").Where(pending).Order("created_at ASC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list pending reviews: %w", err)
+	}
+	return &store.PendingReviewsResponse{Versions: versions}, nil
+}
+
+// ApproveReportVersion records a reviewer's approval of a version's SQL. A
+// proposed version also becomes active once the schema it reads has been
+// learned, and snapshots pinned to the stale version it replaces move to it.
+// Snapshots held back for want of approval refresh on the next tick. The
+// user who wrote or regenerated the SQL cannot approve it.
+func (s *ReportsService) ApproveReportVersion(reportID uint, versionNumber int, req store.ReviewReportVersionRequest) (*store.ReportVersion, error) {
+	version, err := s.reviewableVersion(reportID, versionNumber, req)
+	if err != nil {
+		return nil, err
+	}
+	if version.CreatedBy != "" && version.CreatedBy == req.Reviewer {
+		return nil, classErrorf(ErrValidation, "version %d was written by %s, who cannot approve it; another reviewer must", version.Version, req.Reviewer)
+	}
+	proposed := version.Status == "proposed"
+	if proposed {
+		missing, err := s.missingSources(version)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			return nil, classErrorf(ErrValidation, "version %d reads %s, which the learned schema does not have", version.Version, strings.Join(missing, ", "))
+		}
+	}
+
+	updates := map[string]interface{}{
+		"reviewed_by":    req.Reviewer,
+		"reviewed_at":    time.Now(),
+		"review_comment": req.Comment,
+	}
+	if proposed {
+		updates["status"] = "active"
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(version).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to approve report version: %w", err)
+		}
+		if proposed && version.ProposedFrom != nil {
+			if err := tx.Model(&store.ReportMaterialization{}).
+				Where("report_id = ? AND report_version_id = ?", reportID, *version.ProposedFrom).
+				Update("report_version_id", version.ID).Error; err != nil {
+				return fmt.Errorf("failed to move snapshot: %w", err)
+			}
+		}
+		refresh := tx.Model(&store.ReportMaterialization{}).Where("report_version_id = ?", version.ID)
+		if !proposed {
+			refresh = refresh.Where("status = ?", "blocked")
+		}
+		return refresh.Updates(map[string]interface{}{"status": "pending", "error_text": "", "next_refresh_at": time.Now()}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report version approved", map[string]interface{}{
+		"report_id": reportID,
+		"version":   version.Version,
+		"reviewer":  req.Reviewer,
+	})
+	return version, nil
+}
+
+// RejectReportVersion records a reviewer's rejection of a version's SQL. The
+// version is kept for reference but is never run; runs fall back to the
+// report's previous version.
+func (s *ReportsService) RejectReportVersion(reportID uint, versionNumber int, req store.ReviewReportVersionRequest) (*store.ReportVersion, error) {
+	version, err := s.reviewableVersion(reportID, versionNumber, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(version).Updates(map[string]interface{}{
+		"status":         "rejected",
+		"reviewed_by":    req.Reviewer,
+		"reviewed_at":    time.Now(),
+		"review_comment": req.Comment,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to reject report version: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report version rejected", map[string]interface{}{
+		"report_id": reportID,
+		"version":   version.Version,
+		"reviewer":  req.Reviewer,
+	})
+	return version, nil
+}
+
+// reviewableVersion loads a report version that has not been reviewed yet
+func (s *ReportsService) reviewableVersion(reportID uint, versionNumber int, req store.ReviewReportVersionRequest) (*store.ReportVersion, error) {
+	if strings.TrimSpace(req.Reviewer) == "" {
+		return nil, classErrorf(ErrValidation, "reviewer is required")
+	}
+	var version store.ReportVersion
+	if err := s.db.Where("report_id = ? AND version = ?", reportID, versionNumber).First(&version).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, classErrorf(ErrNotFound, "report version not found")
+		}
+		return nil, fmt.Errorf("failed to find report version: %w", err)
+	}
+	if version.ReviewedAt != nil {
+		return nil, classErrorf(ErrConflict, "version %d was already reviewed by %s", version.Version, version.ReviewedBy)
+	}
+	if version.Status == "rejected" {
+		return nil, classErrorf(ErrConflict, "version %d was rejected", version.Version)
+	}
+	return &version, nil
+}
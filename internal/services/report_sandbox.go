@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// checkRunnable refuses to run a report version against a datasource whose
+// policy it does not meet: approval by a reviewer, and a passed validate run
+// on the datasource's sandbox
+func (s *ReportsService) checkRunnable(version *store.ReportVersion, connector *datasource.DatasourceConnector) error {
+	if err := checkApproved(version, connector); err != nil {
+		return err
+	}
+	if connector.Sandbox == "" {
+		return nil
+	}
+	var last store.ReportValidation
+	err := s.db.Where("report_version_id = ? AND datasource_id = ?", version.ID, connector.ID).
+		Order("id DESC").First(&last).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("failed to load validations: %w", err)
+	}
+	if err == gorm.ErrRecordNotFound || last.Status != "passed" {
+		return classErrorf(ErrSafetyBlocked, "report version %d has not passed a validate run on %s, the sandbox of datasource %s", version.Version, connector.Sandbox, connector.ID)
+	}
+	return nil
+}
+
+// ValidateReport runs a report version on the sandbox of the production
+// datasource it targets and checks the result's shape. The validation is
+// recorded whether it passes or fails; only the latest one for the version
+// and datasource counts.
+func (s *ReportsService) ValidateReport(reportID uint, req store.ValidateReportRequest) (*store.ReportValidation, error) {
+	var report store.Report
+	if err := s.db.First(&report, reportID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, classErrorf(ErrNotFound, "report not found")
+		}
+		return nil, fmt.Errorf("failed to find report: %w", err)
+	}
+
+	// Any version can be validated, so proposals can be tried before approval
+	query := s.db.Where("report_id = ?", reportID)
+	if req.Version > 0 {
+		query = query.Where("version = ?", req.Version)
+	} else {
+		query = query.Scopes(approvedVersions).Order("version DESC")
+	}
+	var version store.ReportVersion
+	if err := query.First(&version).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "report version not found")
+	}
+
+	datasourceID := version.DatasourceID
+	if req.DatasourceID != nil {
+		datasourceID = req.DatasourceID
+	}
+	if datasourceID == nil || *datasourceID == "" {
+		return nil, classErrorf(ErrValidation, "no datasource specified")
+	}
+	production, err := s.registry.GetDatasource(*datasourceID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	if production.Sandbox == "" || production.Sandbox == production.ID {
+		return nil, classErrorf(ErrValidation, "datasource %s has no sandbox", production.ID)
+	}
+	sandbox, err := s.registry.GetDatasource(production.Sandbox)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "sandbox datasource not found: %w", err)
+	}
+	if err := sandbox.Connected(); err != nil {
+		return nil, err
+	}
+
+	sqlText := extractSQLFromDef(version.DefJSON)
+	if sqlText == "" {
+		return nil, fmt.Errorf("report version def_json does not contain sql")
+	}
+	sqlPrepared, _, err := prepareReportSQL(&report, sandbox, sqlText, req.Params, req.User, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	// The sandbox needs a credential of the same name as the production datasource
+	sandboxDB, _, err := runAsDB(sandbox, version.DefJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	expect := req.Expect
+	if len(expect.Columns) == 0 {
+		if expect.Columns, err = s.lastRunColumns(reportID, production.ID); err != nil {
+			return nil, err
+		}
+	}
+	expectJSON, _ := json.Marshal(expect)
+
+	validation := &store.ReportValidation{
+		ReportID:        reportID,
+		ReportVersionID: version.ID,
+		DatasourceID:    production.ID,
+		SandboxID:       sandbox.ID,
+		SQLText:         sqlPrepared,
+		ExpectJSON:      string(expectJSON),
+		User:            req.User,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.runTimeout(false))
+	defer cancel()
+	results, rowCount, _, execErr := executeAndGetResults(ctx, sandboxDB, queryComment{
+		Run:     runTraceID(""),
+		Report:  report.Key,
+		Version: version.Version,
+		User:    req.User,
+	}.apply(sqlPrepared), s.resultLimits(sandbox))
+	var problems []string
+	if execErr != nil {
+		problems = []string{execErr.Error()}
+	} else {
+		set, err := ParseRunResults(results)
+		if err != nil {
+			return nil, err
+		}
+		columns := columnNames(set.Columns)
+		columnsJSON, _ := json.Marshal(columns)
+		validation.ColumnsJSON = string(columnsJSON)
+		validation.RowCount = rowCount
+		problems = shapeProblems(columns, rowCount, expect)
+	}
+	validation.Status = "passed"
+	if len(problems) > 0 {
+		validation.Status = "failed"
+		validation.Problems = strings.Join(problems, "; ")
+	}
+	if err := s.db.Create(validation).Error; err != nil {
+		return nil, fmt.Errorf("failed to save validation: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report validated on sandbox", map[string]interface{}{
+		"report_id":     reportID,
+		"version":       version.Version,
+		"datasource_id": production.ID,
+		"sandbox_id":    sandbox.ID,
+		"status":        validation.Status,
+		"problems":      validation.Problems,
+	})
+	return validation, nil
+}
+
+// ListReportValidations lists a report's validate runs, newest first
+func (s *ReportsService) ListReportValidations(reportID uint) ([]store.ReportValidation, error) {
+	var validations []store.ReportValidation
+	if err := s.db.Where("report_id = ?", reportID).Order("id DESC").Find(&validations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list validations: %w", err)
+	}
+	return validations, nil
+}
+
+// lastRunColumns returns the result columns of the report's last completed
+// run on a datasource, or nil when it has none
+func (s *ReportsService) lastRunColumns(reportID uint, datasourceID string) ([]string, error) {
+	var run store.ReportRun
+	err := s.db.Where("report_id = ? AND datasource_id = ? AND status = ?", reportID, datasourceID, "completed").
+		Order("id DESC").First(&run).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find last run: %w", err)
+	}
+	set, err := ParseRunResults(run.Results)
+	if err != nil {
+		return nil, nil // nothing usable to compare with
+	}
+	return columnNames(set.Columns), nil
+}
+
+// shapeProblems compares a result's columns and row count with an expectation
+func shapeProblems(columns []string, rowCount int, expect store.ShapeExpectation) []string {
+	var problems []string
+	if len(columns) == 0 {
+		problems = append(problems, "the query returned no columns")
+	}
+	if len(expect.Columns) > 0 {
+		got := make(map[string]bool, len(columns))
+		for _, c := range columns {
+			got[strings.ToLower(c)] = true
+		}
+		want := make(map[string]bool, len(expect.Columns))
+		for _, c := range expect.Columns {
+			want[strings.ToLower(c)] = true
+			if !got[strings.ToLower(c)] {
+				problems = append(problems, fmt.Sprintf("missing column %s", c))
+			}
+		}
+		for _, c := range columns {
+			if !want[strings.ToLower(c)] {
+				problems = append(problems, fmt.Sprintf("unexpected column %s", c))
+			}
+		}
+	}
+	if expect.MinRows != nil && rowCount < *expect.MinRows {
+		problems = append(problems, fmt.Sprintf("%d rows, expected at least %d", rowCount, *expect.MinRows))
+	}
+	if expect.MaxRows != nil && rowCount > *expect.MaxRows {
+		problems = append(problems, fmt.Sprintf("%d rows, expected at most %d", rowCount, *expect.MaxRows))
+	}
+	return problems
+}
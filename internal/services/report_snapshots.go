@@ -0,0 +1,518 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// minSnapshotInterval keeps materialized reports from hammering their source
+const minSnapshotInterval = time.Minute
+
+// snapshotQueryTimeout bounds a single snapshot refresh query
+const snapshotQueryTimeout = 5 * time.Minute
+
+// MaterializeReport marks a report version as materialized. The scheduler
+// refreshes its snapshot table every refresh interval.
+func (s *ReportsService) MaterializeReport(reportID uint, req store.MaterializeReportRequest) (*store.ReportMaterialization, error) {
+	if !s.snapshots.Enabled {
+		return nil, fmt.Errorf("snapshots are disabled; set snapshots.enabled and snapshots.datasource_id")
+	}
+
+	interval, err := time.ParseDuration(req.RefreshInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh_interval: %w", err)
+	}
+	if interval < minSnapshotInterval {
+		return nil, fmt.Errorf("refresh_interval must be at least %s", minSnapshotInterval)
+	}
+
+	var report store.Report
+	if err := s.db.First(&report, reportID).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found")
+	}
+
+	query := s.db.Where("report_id = ?", reportID)
+	if req.Version > 0 {
+		query = query.Where("version = ?", req.Version)
+	} else {
+		query = query.Scopes(approvedVersions).Order("version DESC")
+	}
+	var version store.ReportVersion
+	if err := query.First(&version).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "report version not found")
+	}
+	if version.DatasourceID == nil || *version.DatasourceID == "" {
+		return nil, fmt.Errorf("report version %d has no datasource to materialize from", version.Version)
+	}
+	if extractSQLFromDef(version.DefJSON) == "" {
+		return nil, fmt.Errorf("report version %d does not contain sql", version.Version)
+	}
+	if source, err := s.registry.GetDatasource(*version.DatasourceID); err == nil {
+		if err := s.checkRunnable(&version, source); err != nil {
+			return nil, err
+		}
+	}
+
+	paramsJSON, err := json.Marshal(req.Params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode params: %w", err)
+	}
+
+	var m store.ReportMaterialization
+	err = s.db.Where("report_id = ?", reportID).First(&m).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to load materialization: %w", err)
+	}
+
+	m.ReportID = reportID
+	m.ReportVersionID = version.ID
+	m.SnapshotDatasourceID = s.snapshots.DatasourceID
+	m.SnapshotTable = fmt.Sprintf("%sreport_%d", s.snapshots.TablePrefix, reportID)
+	m.ParamsJSON = string(paramsJSON)
+	m.RefreshInterval = interval.String()
+	m.Status = "pending"
+	m.ErrorText = ""
+	m.NextRefreshAt = time.Now() // refresh on the next scheduler tick
+
+	if err := s.db.Save(&m).Error; err != nil {
+		return nil, fmt.Errorf("failed to save materialization: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report materialized", map[string]interface{}{
+		"report_id":        reportID,
+		"version":          version.Version,
+		"snapshot_table":   m.SnapshotTable,
+		"refresh_interval": m.RefreshInterval,
+	})
+
+	return &m, nil
+}
+
+// GetMaterialization returns the materialization settings and state for a report
+func (s *ReportsService) GetMaterialization(reportID uint) (*store.ReportMaterialization, error) {
+	var m store.ReportMaterialization
+	if err := s.db.Where("report_id = ?", reportID).First(&m).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("report is not materialized")
+		}
+		return nil, fmt.Errorf("failed to load materialization: %w", err)
+	}
+	return &m, nil
+}
+
+// DematerializeReport stops refreshing a report and drops its snapshot table
+func (s *ReportsService) DematerializeReport(reportID uint) error {
+	m, err := s.GetMaterialization(reportID)
+	if err != nil {
+		return err
+	}
+
+	if connector, err := s.registry.GetDatasource(m.SnapshotDatasourceID); err == nil && connector.DB != nil {
+		if _, err := connector.DB.Exec("DROP TABLE IF EXISTS " + connector.Quote(m.SnapshotTable)); err != nil {
+			logger.LogWarn(logger.ServiceREST, "Failed to drop snapshot table", map[string]interface{}{
+				"report_id":      reportID,
+				"snapshot_table": m.SnapshotTable,
+				"error":          err.Error(),
+			})
+		}
+	}
+
+	return s.db.Delete(m).Error
+}
+
+// StartSnapshotScheduler refreshes due snapshots in the background
+func (s *ReportsService) StartSnapshotScheduler() {
+	if !s.snapshots.Enabled {
+		return
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Snapshot scheduler started", map[string]interface{}{
+		"datasource_id": s.snapshots.DatasourceID,
+		"poll_interval": s.snapshots.PollInterval.String(),
+	})
+
+	go func() {
+		ticker := time.NewTicker(s.snapshots.PollInterval)
+		defer ticker.Stop()
+		for {
+			s.refreshDueSnapshots()
+			<-ticker.C
+		}
+	}()
+}
+
+// refreshDueSnapshots refreshes every materialization whose next refresh has passed.
+// Refreshes run one at a time so a slow source is never hit concurrently.
+func (s *ReportsService) refreshDueSnapshots() {
+	var due []store.ReportMaterialization
+	if err := s.db.Where("next_refresh_at <= ?", time.Now()).Order("next_refresh_at ASC").Find(&due).Error; err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to load due snapshots", err)
+		return
+	}
+
+	for _, m := range due {
+		if _, err := s.refreshSnapshot(m.ReportID, true); err != nil {
+			if errors.Is(err, redis.ErrLockHeld) {
+				continue // another replica has it
+			}
+			logger.LogWarn(logger.ServiceREST, "Snapshot refresh failed", map[string]interface{}{
+				"report_id": m.ReportID,
+				"error":     err.Error(),
+			})
+		}
+	}
+}
+
+// RefreshSnapshot executes a materialized report against its source and replaces
+// the snapshot table with the results
+func (s *ReportsService) RefreshSnapshot(reportID uint) (*store.ReportMaterialization, error) {
+	return s.refreshSnapshot(reportID, false)
+}
+
+// refreshSnapshot refreshes under the report's job lock so only one replica
+// writes the snapshot table at a time. With onlyDue, a snapshot another
+// replica refreshed since it was found due is left alone.
+func (s *ReportsService) refreshSnapshot(reportID uint, onlyDue bool) (*store.ReportMaterialization, error) {
+	var m *store.ReportMaterialization
+	err := withJobLock(context.Background(), s.locks, fmt.Sprintf("snapshot:%d", reportID), func(_ context.Context, token int64) error {
+		var err error
+		m, err = s.GetMaterialization(reportID)
+		if err != nil {
+			return err
+		}
+		if onlyDue && m.NextRefreshAt.After(time.Now()) {
+			return nil
+		}
+		if onlyDue {
+			if blocked, err := s.blockSnapshotRefresh(m); blocked || err != nil {
+				return err
+			}
+		}
+		m, err = s.refreshSnapshotLocked(m, token)
+		return err
+	})
+	return m, err
+}
+
+// refreshSnapshotLocked does the refresh; token fences the final update so a
+// holder whose lock expired mid-refresh cannot overwrite a newer result
+func (s *ReportsService) refreshSnapshotLocked(m *store.ReportMaterialization, token int64) (*store.ReportMaterialization, error) {
+	reportID := m.ReportID
+	interval, err := time.ParseDuration(m.RefreshInterval)
+	if err != nil {
+		interval = time.Hour
+	}
+
+	start := time.Now()
+	s.db.Model(m).Update("status", "refreshing")
+
+	rowCount, refreshErr := s.refreshSnapshotTable(m, start)
+
+	updates := map[string]interface{}{
+		"next_refresh_at": start.Add(interval),
+		"fence_token":     token,
+	}
+	if refreshErr != nil {
+		updates["status"] = "failed"
+		updates["error_text"] = refreshErr.Error()
+	} else {
+		updates["status"] = "fresh"
+		updates["error_text"] = ""
+		updates["row_count"] = rowCount
+		updates["refreshed_at"] = time.Now()
+	}
+	result := s.db.Model(m).Where("fence_token <= ?", token).Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update materialization: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errStaleFence
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Snapshot refreshed", map[string]interface{}{
+		"report_id": reportID,
+		"status":    updates["status"],
+		"rows":      rowCount,
+		"duration":  time.Since(start).String(),
+	})
+
+	if refreshErr == nil {
+		s.webhooks.Emit(EventSnapshotRefreshed, map[string]interface{}{
+			"report_id":         reportID,
+			"report_version_id": m.ReportVersionID,
+			"snapshot_table":    m.SnapshotTable,
+			"row_count":         rowCount,
+		})
+	}
+
+	if err := s.db.First(m, m.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload materialization: %w", err)
+	}
+	if refreshErr != nil {
+		return m, refreshErr
+	}
+	return m, nil
+}
+
+func (s *ReportsService) refreshSnapshotTable(m *store.ReportMaterialization, now time.Time) (int, error) {
+	var report store.Report
+	if err := s.db.First(&report, m.ReportID).Error; err != nil {
+		return 0, classErrorf(ErrNotFound, "report not found")
+	}
+	var version store.ReportVersion
+	if err := s.db.First(&version, m.ReportVersionID).Error; err != nil {
+		return 0, classErrorf(ErrNotFound, "report version not found")
+	}
+	if version.DatasourceID == nil {
+		return 0, fmt.Errorf("report version has no datasource")
+	}
+
+	source, err := s.registry.GetDatasource(*version.DatasourceID)
+	if err != nil {
+		return 0, classErrorf(ErrNotFound, "source datasource not found: %w", err)
+	}
+	if err := s.checkRunnable(&version, source); err != nil {
+		return 0, err
+	}
+	target, err := s.registry.GetDatasource(m.SnapshotDatasourceID)
+	if err != nil {
+		return 0, classErrorf(ErrNotFound, "snapshot datasource not found: %w", err)
+	}
+	if err := source.Connected(); err != nil {
+		return 0, err
+	}
+	if err := target.Connected(); err != nil {
+		return 0, err
+	}
+
+	var params map[string]interface{}
+	if m.ParamsJSON != "" {
+		if err := json.Unmarshal([]byte(m.ParamsJSON), &params); err != nil {
+			return 0, fmt.Errorf("invalid stored params: %w", err)
+		}
+	}
+
+	sqlPrepared, _, err := prepareReportSQL(&report, source, extractSQLFromDef(version.DefJSON), params, "scheduler", now)
+	if err != nil {
+		return 0, err
+	}
+	assertions, err := s.prepareAssertions(&report, source, params, "scheduler", now)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.checkRowEstimate(source, sqlPrepared, queryComment{Report: report.Key, Version: version.Version, User: "scheduler", Job: "row_estimate"}); err != nil {
+		return 0, err
+	}
+	sourceDB, _, err := runAsDB(source, version.DefJSON)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotQueryTimeout)
+	defer cancel()
+
+	tagged := queryComment{Report: report.Key, Version: version.Version, User: "scheduler"}.apply(sqlPrepared)
+	cols, rows, err := queryTypedRows(ctx, sourceDB, tagged)
+	if err != nil {
+		return 0, fmt.Errorf("report query failed: %w", err)
+	}
+	// A critical assertion failure keeps the previous snapshot in place
+	results := s.evaluateAssertions(&report, assertions, source, nil, columnNames(cols), rows, len(rows))
+	if failed := failedCritical(results); len(failed) > 0 {
+		return 0, fmt.Errorf("critical assertions failed: %s", strings.Join(failed, ", "))
+	}
+	if err := writeSnapshotTable(ctx, target, m.SnapshotTable, columnNames(cols), rows); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return len(rows), nil
+}
+
+// GetSnapshotData serves a materialized report from its snapshot table. It returns
+// nil when the report is not materialized or has never been refreshed.
+func (s *ReportsService) GetSnapshotData(reportID uint) (*store.SnapshotData, error) {
+	var m store.ReportMaterialization
+	if err := s.db.Where("report_id = ?", reportID).First(&m).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load materialization: %w", err)
+	}
+	if m.RefreshedAt == nil {
+		return nil, nil
+	}
+
+	target, err := s.registry.GetDatasource(m.SnapshotDatasourceID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "snapshot datasource not found: %w", err)
+	}
+	if err := target.Connected(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.runTimeout(false))
+	defer cancel()
+
+	cols, rows, err := queryTypedRows(ctx, target.DB, "SELECT * FROM "+target.Quote(m.SnapshotTable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	if rows == nil {
+		rows = [][]interface{}{}
+	}
+
+	now := time.Now()
+	return &store.SnapshotData{
+		ReportID:        reportID,
+		ReportVersionID: m.ReportVersionID,
+		Source:          "snapshot",
+		Table:           m.SnapshotTable,
+		Status:          m.Status,
+		RowCount:        len(rows),
+		Columns:         cols,
+		Rows:            rows,
+		RefreshedAt:     *m.RefreshedAt,
+		AgeSeconds:      int64(now.Sub(*m.RefreshedAt).Seconds()),
+		Stale:           m.Status == "failed" || now.After(m.NextRefreshAt.Add(s.snapshots.PollInterval)),
+	}, nil
+}
+
+// queryTypedRows runs a query and returns its columns with driver-typed values
+func queryTypedRows(ctx context.Context, db *sql.DB, query string) ([]store.ResultColumn, [][]interface{}, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	cols, err := resultColumns(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var out [][]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		scanArgs := make([]interface{}, len(cols))
+		for i := range values {
+			scanArgs[i] = &values[i]
+		}
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, nil, err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		out = append(out, values)
+	}
+	return cols, out, rows.Err()
+}
+
+// writeSnapshotTable replaces table with rows by loading a staging table and
+// renaming it into place. Postgres and SQLite swap atomically; MySQL commits DDL
+// implicitly so readers may briefly see the table missing.
+func writeSnapshotTable(ctx context.Context, target *datasource.DatasourceConnector, table string, cols []string, rows [][]interface{}) error {
+	kind := strings.ToLower(target.Kind)
+	staging := table + "__staging"
+
+	colDefs := make([]string, len(cols))
+	quotedCols := make([]string, len(cols))
+	placeholders := make([]string, len(cols))
+	for i, col := range cols {
+		var sample interface{}
+		for _, row := range rows {
+			if row[i] != nil {
+				sample = row[i]
+				break
+			}
+		}
+		quotedCols[i] = datasource.QuoteIdent(kind, col)
+		colDefs[i] = quotedCols[i] + " " + snapshotColumnType(kind, sample)
+		placeholders[i] = "?"
+		if kind == "postgres" || kind == "timescaledb" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+	}
+
+	tx, err := target.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	quotedStaging := datasource.QuoteIdent(kind, staging)
+	statements := []string{
+		"DROP TABLE IF EXISTS " + quotedStaging,
+		fmt.Sprintf("CREATE TABLE %s (%s)", quotedStaging, strings.Join(colDefs, ", ")),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	insert, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quotedStaging, strings.Join(quotedCols, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, row := range rows {
+		if _, err := insert.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+
+	quotedTable := datasource.QuoteIdent(kind, table)
+	rename := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quotedStaging, quotedTable)
+	if kind == "mysql" {
+		rename = fmt.Sprintf("RENAME TABLE %s TO %s", quotedStaging, quotedTable)
+	}
+	for _, stmt := range []string{"DROP TABLE IF EXISTS " + quotedTable, rename} {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// snapshotColumnType picks a column type from a sample value; unknown and all-NULL
+// columns fall back to text
+func snapshotColumnType(kind string, sample interface{}) string {
+	switch sample.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "BIGINT"
+	case float32, float64:
+		switch kind {
+		case "postgres", "timescaledb":
+			return "DOUBLE PRECISION"
+		case "mysql":
+			return "DOUBLE"
+		default:
+			return "REAL"
+		}
+	case bool:
+		return "BOOLEAN"
+	case time.Time:
+		if kind == "mysql" {
+			return "DATETIME"
+		}
+		return "TIMESTAMP"
+	default:
+		return "TEXT"
+	}
+}
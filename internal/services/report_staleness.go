@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// flagStaleReports marks the impacted report versions whose SQL reads a
+// dropped table or column as stale and emits report.stale for each newly
+// stale version. Retyped columns and SELECT * do not make a version stale:
+// the SQL still runs.
+func (s *DatasourceService) flagStaleReports(datasourceID string, impacted []store.LineageReport, dropped map[string]map[string]bool) {
+	for _, report := range impacted {
+		broken := droppedSources(report.Sources, dropped)
+		if len(broken) == 0 {
+			continue
+		}
+
+		reason := fmt.Sprintf("schema drift on datasource %s removed %s", datasourceID, strings.Join(broken, ", "))
+		now := time.Now()
+		result := s.db.Model(&store.ReportVersion{}).
+			Where("id = ? AND stale_at IS NULL", report.ReportVersionID).
+			Updates(map[string]interface{}{"stale_at": now, "stale_reason": reason})
+		if result.Error != nil {
+			logger.LogError(logger.ServiceDB, "Failed to mark report version stale", result.Error, map[string]interface{}{
+				"report_id": report.ReportID,
+				"version":   report.Version,
+			})
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue // already stale
+		}
+
+		var owner string
+		s.db.Model(&store.Report{}).Where("id = ?", report.ReportID).Pluck("owner", &owner)
+		logger.LogWarn(logger.ServiceDB, "Report version marked stale", map[string]interface{}{
+			"report_id": report.ReportID,
+			"version":   report.Version,
+			"reason":    reason,
+		})
+		s.webhooks.Emit(EventReportStale, map[string]interface{}{
+			"report_id":         report.ReportID,
+			"report_key":        report.Key,
+			"report_version_id": report.ReportVersionID,
+			"version":           report.Version,
+			"owner":             owner,
+			"datasource_id":     datasourceID,
+			"columns":           broken,
+			"reason":            reason,
+		})
+	}
+}
+
+// droppedSources lists, as table.column, the sources that were dropped.
+// Sources are matched to dropped tables by name with or without schema.
+func droppedSources(sources []store.LineageSource, dropped map[string]map[string]bool) []string {
+	var broken []string
+	for _, source := range sources {
+		columns, ok := dropped[source.Table]
+		if !ok {
+			columns, ok = dropped[lastSegment(source.Table)]
+		}
+		switch {
+		case !ok:
+		case columns == nil:
+			broken = appendUnique(broken, source.Table)
+		case source.Column != "*" && columns[source.Column]:
+			broken = appendUnique(broken, source.Table+"."+source.Column)
+		}
+	}
+	sort.Strings(broken)
+	return broken
+}
+
+// RevalidateReport clears staleness from a report's stale versions once every
+// table and column their SQL reads is in the learned schema again, and lets
+// blocked snapshot refreshes run on the next scheduler tick. Lineage is
+// recorded afresh against the current schema. Portable versions have no
+// schema to check and are cleared as they are.
+func (s *ReportsService) RevalidateReport(reportID uint) (*store.RevalidateReportResponse, error) {
+	var report store.Report
+	if err := s.db.First(&report, reportID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, classErrorf(ErrNotFound, "report not found")
+		}
+		return nil, fmt.Errorf("failed to find report: %w", err)
+	}
+
+	var versions []store.ReportVersion
+	if err := s.db.Where("report_id = ? AND stale_at IS NOT NULL", reportID).Order("version ASC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load report versions: %w", err)
+	}
+
+	var problems []string
+	for _, version := range versions {
+		missing, err := s.missingSources(&version)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("version %d reads %s", version.Version, strings.Join(missing, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, classErrorf(ErrValidation, "report still reads dropped schema: %s", strings.Join(problems, "; "))
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range versions {
+			if err := tx.Model(&versions[i]).Updates(map[string]interface{}{"stale_at": nil, "stale_reason": ""}).Error; err != nil {
+				return fmt.Errorf("failed to clear staleness: %w", err)
+			}
+			if err := tx.Where("report_version_id = ?", versions[i].ID).Delete(&store.LineageEdge{}).Error; err != nil {
+				return fmt.Errorf("failed to clear lineage: %w", err)
+			}
+			if err := recordLineage(tx, &versions[i]); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&store.ReportMaterialization{}).
+			Where("report_id = ? AND status = ?", reportID, "blocked").
+			Updates(map[string]interface{}{"status": "pending", "error_text": "", "next_refresh_at": time.Now()}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report revalidated", map[string]interface{}{
+		"report_id": reportID,
+		"versions":  len(versions),
+	})
+	return &store.RevalidateReportResponse{ReportID: reportID, Versions: versions}, nil
+}
+
+// missingSources lists the tables and columns in a version's recorded lineage
+// that are not in its datasource's learned schema
+func (s *ReportsService) missingSources(version *store.ReportVersion) ([]string, error) {
+	if version.DatasourceID == nil || *version.DatasourceID == "" {
+		return nil, nil
+	}
+	learned, err := learnedColumns(s.db, *version.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+	var edges []store.LineageEdge
+	if err := s.db.Where("report_version_id = ?", version.ID).Find(&edges).Error; err != nil {
+		return nil, fmt.Errorf("failed to load lineage: %w", err)
+	}
+
+	var missing []string
+	for _, edge := range edges {
+		columns, ok := learned.lookup(edge.SourceTable)
+		switch {
+		case !ok:
+			missing = appendUnique(missing, edge.SourceTable)
+		case edge.SourceColumn == "*":
+		default:
+			if _, ok := columns[edge.SourceColumn]; !ok {
+				missing = appendUnique(missing, edge.SourceTable+"."+edge.SourceColumn)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// blockSnapshotRefresh holds back a scheduled refresh whose report version is
+// stale, or is not yet approved or validated as its datasource requires: the
+// materialization is marked blocked and checked again after its interval. It
+// reports whether the refresh was blocked.
+func (s *ReportsService) blockSnapshotRefresh(m *store.ReportMaterialization) (bool, error) {
+	var version store.ReportVersion
+	if err := s.db.First(&version, m.ReportVersionID).Error; err != nil {
+		return false, fmt.Errorf("failed to load report version: %w", err)
+	}
+	var errorText string
+	if version.StaleAt != nil {
+		errorText = fmt.Sprintf("report version %d is stale until revalidated: %s", version.Version, version.StaleReason)
+	} else if version.DatasourceID != nil {
+		if source, err := s.registry.GetDatasource(*version.DatasourceID); err == nil {
+			if err := s.checkRunnable(&version, source); err != nil {
+				errorText = err.Error()
+			}
+		}
+	}
+	if errorText == "" {
+		return false, nil
+	}
+
+	interval, err := time.ParseDuration(m.RefreshInterval)
+	if err != nil {
+		interval = time.Hour
+	}
+	if err := s.db.Model(m).Updates(map[string]interface{}{
+		"status":          "blocked",
+		"error_text":      errorText,
+		"next_refresh_at": time.Now().Add(interval),
+	}).Error; err != nil {
+		return true, fmt.Errorf("failed to update materialization: %w", err)
+	}
+
+	logger.LogWarn(logger.ServiceREST, "Scheduled snapshot refresh blocked", map[string]interface{}{
+		"report_id": m.ReportID,
+		"version":   version.Version,
+		"reason":    errorText,
+	})
+	return true, nil
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// selectReports resolves a selector to the matching non-archived reports
+func (s *ReportsService) selectReports(sel store.ReportSelector) ([]store.Report, error) {
+	query := s.db.Where("archived = ?", false)
+
+	switch {
+	case len(sel.Keys) > 0:
+		query = query.Where("key IN ?", sel.Keys)
+	case sel.Folder != "":
+		query = query.Where("folder = ?", sel.Folder)
+	case sel.Tag != "":
+		query = query.Where("tags LIKE ?", "%"+sel.Tag+"%")
+	default:
+		return nil, fmt.Errorf("one of keys, folder or tag is required")
+	}
+
+	var reports []store.Report
+	if err := query.Order("key ASC").Find(&reports).Error; err != nil {
+		return nil, fmt.Errorf("failed to select reports: %w", err)
+	}
+
+	// LIKE is only a pre-filter; match tags exactly
+	if sel.Tag != "" && len(sel.Keys) == 0 && sel.Folder == "" {
+		filtered := reports[:0]
+		for _, report := range reports {
+			if hasTag(report.Tags, sel.Tag) {
+				filtered = append(filtered, report)
+			}
+		}
+		reports = filtered
+	}
+
+	if len(sel.Keys) > 0 && len(reports) != len(sel.Keys) {
+		found := make(map[string]bool, len(reports))
+		for _, report := range reports {
+			found[report.Key] = true
+		}
+		var missing []string
+		for _, key := range sel.Keys {
+			if !found[key] {
+				missing = append(missing, key)
+			}
+		}
+		return nil, classErrorf(ErrNotFound, "reports not found or archived: %s", strings.Join(missing, ", "))
+	}
+
+	return reports, nil
+}
+
+func hasTag(tags, tag string) bool {
+	for _, t := range strings.Split(tags, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartBatchRun creates a batch and runs the selected reports in the background
+func (s *ReportsService) StartBatchRun(req store.BatchRunReportsRequest) (*store.ReportBatch, error) {
+	reports, err := s.selectReports(req.ReportSelector)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no reports matched the selector")
+	}
+
+	paramsJSON, _ := json.Marshal(req.Params)
+	batch := &store.ReportBatch{
+		ParamsJSON:   string(paramsJSON),
+		DatasourceID: req.DatasourceID,
+		Status:       "running",
+		Total:        len(reports),
+		CreatedAt:    time.Now(),
+	}
+	for _, report := range reports {
+		batch.Items = append(batch.Items, store.ReportBatchItem{
+			ReportID:  report.ID,
+			ReportKey: report.Key,
+			Status:    "pending",
+		})
+	}
+
+	if err := s.db.Create(batch).Error; err != nil {
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Batch run started", map[string]interface{}{
+		"batch_id": batch.ID,
+		"reports":  batch.Total,
+	})
+
+	go s.runBatch(batch.ID, req)
+
+	return batch, nil
+}
+
+// runBatch executes each batch item in order, recording per-report status
+func (s *ReportsService) runBatch(batchID uint, req store.BatchRunReportsRequest) {
+	var items []store.ReportBatchItem
+	if err := s.db.Where("batch_id = ?", batchID).Order("id ASC").Find(&items).Error; err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to load batch items", err, map[string]interface{}{
+			"batch_id": batchID,
+		})
+		return
+	}
+
+	succeeded, failed := 0, 0
+	for _, item := range items {
+		started := time.Now()
+		s.db.Model(&item).Updates(map[string]interface{}{"status": "running", "started_at": started})
+
+		updates := map[string]interface{}{}
+		run, err := s.RunReport(item.ReportKey, store.RunReportRequest{
+			Params:       req.Params,
+			DatasourceID: req.DatasourceID,
+			User:         req.User,
+		})
+		switch {
+		case err != nil:
+			updates["status"] = "failed"
+			updates["error_text"] = err.Error()
+		case run.Status == "failed":
+			updates["status"] = "failed"
+			updates["error_text"] = run.ErrorText
+			updates["run_id"] = run.ID
+		default:
+			updates["status"] = "completed"
+			updates["run_id"] = run.ID
+			updates["row_count"] = run.RowCount
+		}
+		updates["finished_at"] = time.Now()
+
+		if updates["status"] == "completed" {
+			succeeded++
+		} else {
+			failed++
+		}
+		s.db.Model(&item).Updates(updates)
+	}
+
+	status := "completed"
+	if failed > 0 && succeeded > 0 {
+		status = "partial"
+	} else if failed > 0 {
+		status = "failed"
+	}
+
+	finished := time.Now()
+	s.db.Model(&store.ReportBatch{}).Where("id = ?", batchID).Updates(map[string]interface{}{
+		"status":      status,
+		"succeeded":   succeeded,
+		"failed":      failed,
+		"finished_at": finished,
+	})
+
+	logger.LogInfo(logger.ServiceREST, "Batch run finished", map[string]interface{}{
+		"batch_id":  batchID,
+		"status":    status,
+		"succeeded": succeeded,
+		"failed":    failed,
+	})
+}
+
+// GetBatch returns a batch with its per-report statuses
+func (s *ReportsService) GetBatch(batchID uint) (*store.ReportBatch, error) {
+	var batch store.ReportBatch
+	if err := s.db.Preload("Items").First(&batch, batchID).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "batch not found")
+	}
+	return &batch, nil
+}
+
+// ArchiveReports archives every selected report and returns the archived keys
+func (s *ReportsService) ArchiveReports(sel store.ReportSelector) ([]string, error) {
+	reports, err := s.selectReports(sel)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(reports))
+	ids := make([]uint, 0, len(reports))
+	for _, report := range reports {
+		keys = append(keys, report.Key)
+		ids = append(ids, report.ID)
+	}
+
+	if len(ids) > 0 {
+		if err := s.db.Model(&store.Report{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"archived":   true,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return nil, fmt.Errorf("failed to archive reports: %w", err)
+		}
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Reports archived", map[string]interface{}{
+		"count": len(keys),
+	})
+
+	return keys, nil
+}
+
+// ExportReports bundles the selected reports with their latest (or all) versions
+func (s *ReportsService) ExportReports(req store.BatchExportReportsRequest) (*store.ReportBundle, error) {
+	reports, err := s.selectReports(req.ReportSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &store.ReportBundle{
+		ExportedAt: time.Now(),
+		Selector:   req.ReportSelector,
+		Reports:    make([]store.ReportBundleEntry, 0, len(reports)),
+	}
+
+	for _, report := range reports {
+		query := s.db.Where("report_id = ?", report.ID).Order("version DESC")
+		if !req.IncludeAllVersions {
+			query = query.Scopes(approvedVersions).Limit(1)
+		}
+
+		var versions []store.ReportVersion
+		if err := query.Find(&versions).Error; err != nil {
+			return nil, fmt.Errorf("failed to load versions for report %s: %w", report.Key, err)
+		}
+
+		bundle.Reports = append(bundle.Reports, store.ReportBundleEntry{
+			Report:   report,
+			Versions: versions,
+		})
+	}
+
+	return bundle, nil
+}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// maxPackageFileSize caps each file read from a .airpkg archive
+const maxPackageFileSize = 10 << 20
+
+var tableRefRe = regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+([A-Za-z_][\w.]*)`)
+
+// ExportPackage builds a .airpkg archive (zip) for the selected reports.
+//
+// Layout:
+//
+//	manifest.json        AirPackageManifest
+//	reports/<key>.json   AirPackageReport, one per report
+func (s *ReportsService) ExportPackage(req store.ExportPackageRequest) ([]byte, error) {
+	reports, err := s.selectReports(req.ReportSelector)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("no reports matched the selector")
+	}
+
+	manifest := store.AirPackageManifest{
+		Format:             store.AirPackageFormat,
+		FormatVersion:      store.AirPackageFormatVersion,
+		Name:               req.Name,
+		Description:        req.Description,
+		CreatedAt:          time.Now(),
+		SchemaFingerprints: make(map[string]string),
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, report := range reports {
+		pkgReport, err := s.packageReport(report, manifest.SchemaFingerprints)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipJSON(zw, path.Join("reports", packageFileName(report.Key)+".json"), pkgReport); err != nil {
+			return nil, err
+		}
+		manifest.Reports = append(manifest.Reports, report.Key)
+	}
+
+	if err := writeZipJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize package: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report package exported", map[string]interface{}{
+		"package": req.Name,
+		"reports": len(manifest.Reports),
+		"bytes":   buf.Len(),
+	})
+
+	return buf.Bytes(), nil
+}
+
+// packageReport converts a report and all its versions to package form,
+// recording schema fingerprints for every table the SQL references
+func (s *ReportsService) packageReport(report store.Report, fingerprints map[string]string) (*store.AirPackageReport, error) {
+	var versions []store.ReportVersion
+	if err := s.db.Where("report_id = ?", report.ID).Order("version ASC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load versions for report %s: %w", report.Key, err)
+	}
+
+	pkgReport := &store.AirPackageReport{
+		Key:    report.Key,
+		Title:  report.Title,
+		Owner:  report.Owner,
+		Folder: report.Folder,
+		Tags:   report.Tags,
+	}
+
+	for _, version := range versions {
+		pkgVersion := store.AirPackageReportVersion{
+			Version: version.Version,
+			DefJSON: version.DefJSON,
+		}
+
+		var def map[string]interface{}
+		if err := json.Unmarshal([]byte(version.DefJSON), &def); err == nil {
+			pkgVersion.ParamsSchema, _ = def["params_schema"].(map[string]interface{})
+			if chart, ok := def["chart_spec"].(map[string]interface{}); ok {
+				pkgVersion.ChartSpec = chart
+			} else {
+				pkgVersion.ChartSpec, _ = def["chart"].(map[string]interface{})
+			}
+		}
+
+		pkgVersion.RequiredTables = referencedTables(extractSQLFromDef(version.DefJSON))
+
+		if version.DatasourceID != nil {
+			if connector, err := s.registry.GetDatasource(*version.DatasourceID); err == nil {
+				pkgVersion.DatasourceKind = connector.Kind
+			}
+			for table, fingerprint := range s.schemaFingerprints(*version.DatasourceID, pkgVersion.RequiredTables) {
+				fingerprints[table] = fingerprint
+			}
+		}
+
+		if version.ScopeVersionID != nil {
+			var scopeVersion store.ScopeVersion
+			if err := s.db.Preload("Scope").First(&scopeVersion, *version.ScopeVersionID).Error; err == nil {
+				pkgVersion.Scope = &store.AirPackageScope{
+					Name:    scopeVersion.Scope.Name,
+					Version: scopeVersion.Version,
+					ScopeMD: scopeVersion.ScopeMD,
+					IRJSON:  scopeVersion.IRJSON,
+				}
+			}
+		}
+
+		pkgReport.Versions = append(pkgReport.Versions, pkgVersion)
+	}
+
+	return pkgReport, nil
+}
+
+// InstallPackage imports a .airpkg archive, binding its reports to datasourceID.
+// Existing report keys are skipped unless overwrite is set, in which case the
+// packaged versions are appended as new versions.
+func (s *ReportsService) InstallPackage(data []byte, datasourceID string, overwrite bool) (*store.PackageInstallResult, error) {
+	if _, err := s.registry.GetDatasource(datasourceID); err != nil {
+		return nil, fmt.Errorf("datasource not found: %w", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid package archive: %w", err)
+	}
+
+	var manifest store.AirPackageManifest
+	var reports []store.AirPackageReport
+	for _, file := range zr.File {
+		switch {
+		case file.Name == "manifest.json":
+			if err := readZipJSON(file, &manifest); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(file.Name, "reports/") && strings.HasSuffix(file.Name, ".json"):
+			var report store.AirPackageReport
+			if err := readZipJSON(file, &report); err != nil {
+				return nil, err
+			}
+			reports = append(reports, report)
+		}
+	}
+
+	if manifest.Format != store.AirPackageFormat {
+		return nil, fmt.Errorf("not an airpkg archive (missing or invalid manifest.json)")
+	}
+	if manifest.FormatVersion > store.AirPackageFormatVersion {
+		return nil, fmt.Errorf("package format version %d is newer than supported version %d", manifest.FormatVersion, store.AirPackageFormatVersion)
+	}
+
+	result := &store.PackageInstallResult{
+		Package:   manifest.Name,
+		Installed: []string{},
+		Warnings:  s.checkSchemaFingerprints(datasourceID, manifest.SchemaFingerprints),
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, pkgReport := range reports {
+			installed, err := installPackageReport(tx, pkgReport, datasourceID, overwrite)
+			if err != nil {
+				return fmt.Errorf("failed to install report %s: %w", pkgReport.Key, err)
+			}
+			if installed {
+				result.Installed = append(result.Installed, pkgReport.Key)
+			} else {
+				result.Skipped = append(result.Skipped, pkgReport.Key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report package installed", map[string]interface{}{
+		"package":       manifest.Name,
+		"datasource_id": datasourceID,
+		"installed":     len(result.Installed),
+		"skipped":       len(result.Skipped),
+		"warnings":      len(result.Warnings),
+	})
+
+	return result, nil
+}
+
+// installPackageReport creates (or extends) one report and its scopes within tx
+func installPackageReport(tx *gorm.DB, pkgReport store.AirPackageReport, datasourceID string, overwrite bool) (bool, error) {
+	var report store.Report
+	err := tx.Where("key = ?", pkgReport.Key).First(&report).Error
+	switch {
+	case err == nil && !overwrite:
+		return false, nil
+	case err == gorm.ErrRecordNotFound:
+		report = store.Report{
+			Key:       pkgReport.Key,
+			Title:     pkgReport.Title,
+			Owner:     pkgReport.Owner,
+			Folder:    pkgReport.Folder,
+			Tags:      pkgReport.Tags,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := tx.Create(&report).Error; err != nil {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	}
+
+	var maxVersion int
+	if err := tx.Model(&store.ReportVersion{}).
+		Where("report_id = ?", report.ID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&maxVersion).Error; err != nil {
+		return false, err
+	}
+
+	// Packaged scope versions are added to the local scope of the same name,
+	// created on first install, so reinstalling does not duplicate scopes
+	scopes := make(map[string]*store.Scope)
+	for _, pkgVersion := range pkgReport.Versions {
+		var scopeVersionID *uint
+		if pkgVersion.Scope != nil {
+			scope, ok := scopes[pkgVersion.Scope.Name]
+			if !ok {
+				var err error
+				if scope, err = packageScope(tx, pkgVersion.Scope.Name); err != nil {
+					return false, err
+				}
+				scopes[pkgVersion.Scope.Name] = scope
+			}
+
+			var scopeMax int
+			if err := tx.Model(&store.ScopeVersion{}).
+				Where("scope_id = ?", scope.ID).
+				Select("COALESCE(MAX(version), 0)").
+				Scan(&scopeMax).Error; err != nil {
+				return false, err
+			}
+
+			scopeVersion := &store.ScopeVersion{
+				ScopeID:   scope.ID,
+				Version:   scopeMax + 1,
+				ScopeMD:   pkgVersion.Scope.ScopeMD,
+				IRJSON:    pkgVersion.Scope.IRJSON,
+				CreatedAt: time.Now(),
+			}
+			if err := tx.Create(scopeVersion).Error; err != nil {
+				return false, err
+			}
+			scopeVersionID = &scopeVersion.ID
+		}
+
+		maxVersion++
+		dsID := datasourceID
+		defJSON, err := attachParamsSchema(tx, pkgVersion.DefJSON, &dsID)
+		if err != nil {
+			return false, err
+		}
+		checksum := sha256.Sum256([]byte(defJSON))
+		version := &store.ReportVersion{
+			ReportID:       report.ID,
+			Version:        maxVersion,
+			ScopeVersionID: scopeVersionID,
+			DatasourceID:   &dsID,
+			DefJSON:        defJSON,
+			Checksum:       hex.EncodeToString(checksum[:]),
+			Status:         "active",
+			CreatedAt:      time.Now(),
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return false, err
+		}
+		if err := recordLineage(tx, version); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// packageScope returns the oldest scope named name, creating it when there
+// is none
+func packageScope(tx *gorm.DB, name string) (*store.Scope, error) {
+	var scope store.Scope
+	err := tx.Where("name = ?", name).Order("id").First(&scope).Error
+	if err == gorm.ErrRecordNotFound {
+		scope = store.Scope{
+			Name:      name,
+			Status:    "draft",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		err = tx.Create(&scope).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &scope, nil
+}
+
+// schemaFingerprints hashes the learned schema notes of each table
+func (s *ReportsService) schemaFingerprints(datasourceID string, tables []string) map[string]string {
+	fingerprints := make(map[string]string)
+	if len(tables) == 0 {
+		return fingerprints
+	}
+
+	var notes []store.SchemaNote
+	if err := s.db.Where("datasource_id = ? AND object IN ?", datasourceID, tables).
+		Order("object ASC, chunk ASC").Find(&notes).Error; err != nil {
+		return fingerprints
+	}
+
+	hashes := make(map[string][]string)
+	for _, note := range notes {
+		hashes[note.Object] = append(hashes[note.Object], note.MDHash)
+	}
+	for table, parts := range hashes {
+		sum := sha256.Sum256([]byte(strings.Join(parts, ",")))
+		fingerprints[table] = hex.EncodeToString(sum[:])
+	}
+	return fingerprints
+}
+
+// checkSchemaFingerprints compares packaged fingerprints with the target datasource
+func (s *ReportsService) checkSchemaFingerprints(datasourceID string, expected map[string]string) []string {
+	warnings := []string{}
+	if len(expected) == 0 {
+		return warnings
+	}
+
+	tables := make([]string, 0, len(expected))
+	for table := range expected {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	actual := s.schemaFingerprints(datasourceID, tables)
+	for _, table := range tables {
+		fingerprint, ok := actual[table]
+		switch {
+		case !ok:
+			warnings = append(warnings, fmt.Sprintf("table %s has not been learned on datasource %s", table, datasourceID))
+		case fingerprint != expected[table]:
+			warnings = append(warnings, fmt.Sprintf("schema for table %s differs from the packaged schema", table))
+		}
+	}
+	return warnings
+}
+
+// referencedTables lists the distinct tables named after FROM/JOIN in a query
+func referencedTables(sqlText string) []string {
+	tables := []string{}
+	seen := make(map[string]bool)
+	for _, match := range tableRefRe.FindAllStringSubmatch(stripSQLLiterals(sqlText), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			tables = append(tables, match[1])
+		}
+	}
+	return tables
+}
+
+// packageFileName makes a report key safe to use as an archive file name
+func packageFileName(key string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(key)
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to package: %w", name, err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func readZipJSON(file *zip.File, v interface{}) error {
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxPackageFileSize))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid JSON in %s: %w", file.Name, err)
+	}
+	return nil
+}
@@ -1,34 +1,79 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/NubeDev/air/internal/config"
 	"github.com/NubeDev/air/internal/datasource"
 	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
 	"github.com/NubeDev/air/internal/store"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
 // ReportsService handles report-related business logic
 type ReportsService struct {
-	registry *datasource.Registry
-	db       *gorm.DB
+	registry  *datasource.Registry
+	db        *gorm.DB
+	safety    config.SafetyConfig
+	snapshots config.SnapshotsConfig
+	enums     config.ParamEnumsConfig
+	cost      config.WarehouseCostConfig
+	webhooks  *WebhookService
+	events    *EventStream
+	locks     *redis.Client
+
+	// inflight coalesces identical runs onto one database execution
+	inflight singleflight.Group
+
+	// runs tracks runs executing on their own goroutine; stopRuns cancels
+	// runCtx, which they execute under, at shutdown
+	runs     sync.WaitGroup
+	runCtx   context.Context
+	stopRuns context.CancelFunc
 }
 
 // NewReportsService creates a new reports service
-func NewReportsService(registry *datasource.Registry, db *gorm.DB) *ReportsService {
+func NewReportsService(registry *datasource.Registry, db *gorm.DB, cfg *config.Config) *ReportsService {
+	runCtx, stopRuns := context.WithCancel(context.Background())
 	return &ReportsService{
-		registry: registry,
-		db:       db,
+		registry:  registry,
+		db:        db,
+		safety:    cfg.Safety,
+		snapshots: cfg.Snapshots,
+		enums:     cfg.ParamEnums,
+		cost:      cfg.WarehouseCost,
+		runCtx:    runCtx,
+		stopRuns:  stopRuns,
 	}
 }
 
+// SetWebhooks enables lifecycle event delivery for report runs
+func (s *ReportsService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// SetLocks shares scheduled snapshot refreshes across API replicas
+func (s *ReportsService) SetLocks(locks *redis.Client) {
+	s.locks = locks
+}
+
+// SetEvents enables run progress events for stream subscribers
+func (s *ReportsService) SetEvents(events *EventStream) {
+	s.events = events
+}
+
 // CreateScope creates a new scope
 func (s *ReportsService) CreateScope(req store.CreateScopeRequest) (*store.Scope, error) {
 	start := time.Now()
@@ -74,7 +119,7 @@ func (s *ReportsService) GetScope(id uint) (*store.Scope, error) {
 			logger.LogWarn(logger.ServiceREST, "Scope not found", map[string]interface{}{
 				"scope_id": id,
 			})
-			return nil, fmt.Errorf("scope not found")
+			return nil, classErrorf(ErrNotFound, "scope not found")
 		}
 		logger.LogError(logger.ServiceREST, "Failed to retrieve scope", err, map[string]interface{}{
 			"scope_id": id,
@@ -103,34 +148,39 @@ func (s *ReportsService) CreateScopeVersion(scopeID uint, req store.CreateScopeV
 	var scope store.Scope
 	if err := s.db.First(&scope, scopeID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("scope not found")
+			return nil, classErrorf(ErrNotFound, "scope not found")
 		}
 		return nil, fmt.Errorf("failed to find scope: %w", err)
 	}
 
-	// Get next version number
-	var maxVersion int
-	if err := s.db.Model(&store.ScopeVersion{}).
-		Where("scope_id = ?", scopeID).
-		Select("COALESCE(MAX(version), 0)").
-		Scan(&maxVersion).Error; err != nil {
-		return nil, fmt.Errorf("failed to get max version: %w", err)
-	}
-
-	// Create scope version
+	// Read the latest version and insert the next one in one transaction so a
+	// stale base version cannot slip in between the check and the insert
 	scopeVersion := &store.ScopeVersion{
 		ScopeID:   scopeID,
-		Version:   maxVersion + 1,
 		ScopeMD:   req.ScopeMD,
 		CreatedAt: time.Now(),
 	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var latest store.ScopeVersion
+		if err := tx.Where("scope_id = ?", scopeID).Order("version DESC").Limit(1).Find(&latest).Error; err != nil {
+			return fmt.Errorf("failed to get max version: %w", err)
+		}
+		if req.BaseVersion != nil && *req.BaseVersion != latest.Version {
+			return &VersionConflictError{Resource: "scope", Expected: *req.BaseVersion, Current: latest.Version, Latest: latest}
+		}
 
-	if err := s.db.Create(scopeVersion).Error; err != nil {
+		scopeVersion.Version = latest.Version + 1
+		if err := tx.Create(scopeVersion).Error; err != nil {
+			return fmt.Errorf("failed to create scope version: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
 		logger.LogError(logger.ServiceREST, "Failed to create scope version", err, map[string]interface{}{
 			"scope_id": scopeID,
 			"version":  scopeVersion.Version,
 		})
-		return nil, fmt.Errorf("failed to create scope version: %w", err)
+		return nil, err
 	}
 
 	duration := time.Since(start)
@@ -164,11 +214,18 @@ func (s *ReportsService) CreateReport(req store.CreateReportRequest) (*store.Rep
 		return nil, fmt.Errorf("failed to check existing report: %w", err)
 	}
 
+	if _, err := loadReportLocation(req.Timezone); err != nil {
+		return nil, err
+	}
+
 	// Create report
 	report := &store.Report{
 		Key:       req.Key,
 		Title:     req.Title,
 		Owner:     req.Owner,
+		Folder:    req.Folder,
+		Tags:      req.Tags,
+		Timezone:  req.Timezone,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -204,7 +261,7 @@ func (s *ReportsService) GetReport(key string) (*store.Report, error) {
 			logger.LogWarn(logger.ServiceREST, "Report not found", map[string]interface{}{
 				"key": key,
 			})
-			return nil, fmt.Errorf("report not found")
+			return nil, classErrorf(ErrNotFound, "report not found")
 		}
 		logger.LogError(logger.ServiceREST, "Failed to retrieve report", err, map[string]interface{}{
 			"key": key,
@@ -235,7 +292,7 @@ func (s *ReportsService) CreateReportVersion(reportKey string, req store.CreateR
 	var report store.Report
 	if err := s.db.Where("key = ?", reportKey).First(&report).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("report not found")
+			return nil, classErrorf(ErrNotFound, "report not found")
 		}
 		return nil, fmt.Errorf("failed to find report: %w", err)
 	}
@@ -244,36 +301,49 @@ func (s *ReportsService) CreateReportVersion(reportKey string, req store.CreateR
 	var scopeVersion store.ScopeVersion
 	if err := s.db.First(&scopeVersion, req.ScopeVersionID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("scope version not found")
+			return nil, classErrorf(ErrNotFound, "scope version not found")
 		}
 		return nil, fmt.Errorf("failed to find scope version: %w", err)
 	}
 
-	// Get next version number
-	var maxVersion int
-	if err := s.db.Model(&store.ReportVersion{}).
-		Where("report_id = ?", report.ID).
-		Select("COALESCE(MAX(version), 0)").
-		Scan(&maxVersion).Error; err != nil {
-		return nil, fmt.Errorf("failed to get max version: %w", err)
+	// The version stores the params form of its SQL, so forms match the query
+	defJSON, err := attachParamsSchema(s.db, req.DefJSON, req.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRunAs(defJSON, req.DatasourceID); err != nil {
+		return nil, err
 	}
 
-	// Create report version
 	reportVersion := &store.ReportVersion{
 		ReportID:       report.ID,
-		ScopeVersionID: req.ScopeVersionID,
+		ScopeVersionID: &req.ScopeVersionID,
 		DatasourceID:   req.DatasourceID,
-		Version:        maxVersion + 1,
-		DefJSON:        req.DefJSON,
+		DefJSON:        defJSON,
+		CreatedBy:      req.User,
 		CreatedAt:      time.Now(),
 	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var latest store.ReportVersion
+		if err := tx.Where("report_id = ?", report.ID).Order("version DESC").Limit(1).Find(&latest).Error; err != nil {
+			return fmt.Errorf("failed to get max version: %w", err)
+		}
+		if req.BaseVersion != nil && *req.BaseVersion != latest.Version {
+			return &VersionConflictError{Resource: "report", Expected: *req.BaseVersion, Current: latest.Version, Latest: latest}
+		}
 
-	if err := s.db.Create(reportVersion).Error; err != nil {
+		reportVersion.Version = latest.Version + 1
+		if err := tx.Create(reportVersion).Error; err != nil {
+			return fmt.Errorf("failed to create report version: %w", err)
+		}
+		return recordLineage(tx, reportVersion)
+	})
+	if err != nil {
 		logger.LogError(logger.ServiceREST, "Failed to create report version", err, map[string]interface{}{
 			"report_id": report.ID,
 			"version":   reportVersion.Version,
 		})
-		return nil, fmt.Errorf("failed to create report version: %w", err)
+		return nil, err
 	}
 
 	duration := time.Since(start)
@@ -289,8 +359,10 @@ func (s *ReportsService) CreateReportVersion(reportKey string, req store.CreateR
 // RunReport executes a report with parameters
 func (s *ReportsService) RunReport(reportKey string, req store.RunReportRequest) (*store.ReportRun, error) {
 	start := time.Now()
+	traceID := runTraceID(req.TraceID)
 
 	logger.LogInfo(logger.ServiceREST, "Running report", map[string]interface{}{
+		"trace_id":      traceID,
 		"report_key":    reportKey,
 		"datasource_id": req.DatasourceID,
 	})
@@ -299,38 +371,54 @@ func (s *ReportsService) RunReport(reportKey string, req store.RunReportRequest)
 	var report store.Report
 	if err := s.db.Where("key = ?", reportKey).First(&report).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("report not found")
+			return nil, classErrorf(ErrNotFound, "report not found")
 		}
 		return nil, fmt.Errorf("failed to find report: %w", err)
 	}
+	s.TrackReportAccess(req.User, report.ID)
 
 	// Get latest report version
 	var reportVersion store.ReportVersion
-	if err := s.db.Where("report_id = ?", report.ID).Order("version DESC").First(&reportVersion).Error; err != nil {
+	if err := s.db.Scopes(approvedVersions).Where("report_id = ?", report.ID).Order("version DESC").First(&reportVersion).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("no report version found")
 		}
 		return nil, fmt.Errorf("failed to find report version: %w", err)
 	}
 
-	// Determine datasource
-	datasourceID := reportVersion.DatasourceID
-	if req.DatasourceID != nil {
-		datasourceID = req.DatasourceID
+	// The caller's preferences fill in what neither the request nor the report sets
+	prefs := userPreferences(s.db, req.User)
+	if report.Timezone == "" {
+		report.Timezone = prefs.Timezone
 	}
-	if datasourceID == nil || *datasourceID == "" {
-		return nil, fmt.Errorf("no datasource specified")
+
+	// Determine datasource
+	datasourceID, err := runDatasource(&reportVersion, req.DatasourceID, prefs)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get datasource connector
 	connector, err := s.registry.GetDatasource(*datasourceID)
 	if err != nil {
-		return nil, fmt.Errorf("datasource not found: %w", err)
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	if err := s.checkRunnable(&reportVersion, connector); err != nil {
+		return nil, err
+	}
+	if err := connector.Connected(); err != nil {
+		return nil, err
+	}
+	// Sign in as the version's run_as credential now, so a bad one fails the request
+	_, runAs, err := runAsDB(connector, reportVersion.DefJSON)
+	if err != nil {
+		return nil, err
 	}
 
 	// Extract SQL from def_json (expects a JSON with {"sql": "..."})
 	sqlText := extractSQLFromDef(reportVersion.DefJSON)
 	logger.LogInfo(logger.ServiceREST, "Extracted SQL from report version", map[string]interface{}{
+		"trace_id":   traceID,
 		"report_id":  report.ID,
 		"version_id": reportVersion.ID,
 		"has_sql":    sqlText != "",
@@ -339,67 +427,121 @@ func (s *ReportsService) RunReport(reportKey string, req store.RunReportRequest)
 		return nil, fmt.Errorf("report version def_json does not contain sql")
 	}
 
-	// Replace simple placeholders {{param}} with provided params (dev only)
-	sqlPrepared := replacePlaceholders(sqlText, req.Params)
+	sqlPrepared, params, err := prepareReportSQL(&report, connector, sqlText, req.Params, req.User, start)
+	if err != nil {
+		return nil, err
+	}
+	assertions, err := s.prepareAssertions(&report, connector, req.Params, req.User, start)
+	if err != nil {
+		return nil, err
+	}
 
-	// Execute SQL and get results
-	results, rowCount, execErr := executeAndGetResults(connector.DB, sqlPrepared)
-	if execErr != nil {
-		logger.LogError(logger.ServiceREST, "Report SQL execution failed", execErr, map[string]interface{}{
-			"report_id":  report.ID,
-			"version_id": reportVersion.ID,
-			"sql":        sqlPrepared,
-		})
-	} else {
-		logger.LogInfo(logger.ServiceREST, "Report SQL executed", map[string]interface{}{
-			"rows": rowCount,
-			"sql":  sqlPrepared,
-		})
+	// Pre-check the result size so runaway queries are refused or flagged
+	estimate, err := s.checkRowEstimate(connector, sqlPrepared, queryComment{
+		Run:     traceID,
+		Report:  report.Key,
+		Version: reportVersion.Version,
+		User:    req.User,
+		Job:     "row_estimate",
+	})
+	if err != nil {
+		return nil, err
 	}
-	status := "completed"
-	var errText string
-	if execErr != nil {
-		status = "failed"
-		errText = execErr.Error()
+	// Warehouses billed by scan are dry-run against the cost budgets
+	costEstimate, err := s.checkCostBudget(connector, sqlPrepared)
+	if err != nil {
+		return nil, err
 	}
 
-	finished := time.Now()
+	// The run is recorded before it executes so a run that outlives the sync
+	// threshold already has an ID for the caller to poll
 	reportRun := &store.ReportRun{
 		ReportID:        report.ID,
 		ReportVersionID: reportVersion.ID,
 		DatasourceID:    *datasourceID,
-		ParamsJSON:      fmt.Sprintf(`{"params": %v}`, req.Params),
+		ParamsJSON:      fmt.Sprintf(`{"params": %v}`, params),
 		SQLText:         sqlPrepared,
-		RowCount:        rowCount,
-		Results:         results,
 		StartedAt:       start,
-		FinishedAt:      &finished,
-		Status:          status,
-		ErrorText:       errText,
+		Status:          "running",
+		TraceID:         traceID,
+		RunAs:           runAs,
 	}
-
+	if estimate != nil {
+		reportRun.EstimatedRows = &estimate.Rows
+		reportRun.Warnings = estimate.Guidance
+	}
+	if costEstimate != nil {
+		reportRun.EstimatedBytes = &costEstimate.Bytes
+	}
+	// Flag the numbers as old when a table the report reads is past its freshness max age
+	reportRun.DataStale, reportRun.DataAsOf = dataFreshness(s.db, reportVersion.ID, *datasourceID)
 	if err := s.db.Create(reportRun).Error; err != nil {
 		logger.LogError(logger.ServiceREST, "Failed to create report run", err, map[string]interface{}{
+			"trace_id":  traceID,
 			"report_id": report.ID,
 		})
 		return nil, fmt.Errorf("failed to create report run: %w", err)
 	}
 
+	s.events.Publish(EventReportRunStarted, map[string]interface{}{
+		"trace_id":      traceID,
+		"report_id":     report.ID,
+		"report_key":    report.Key,
+		"run_id":        reportRun.ID,
+		"version":       reportVersion.Version,
+		"datasource_id": *datasourceID,
+	})
+
+	pending := *reportRun
+	threshold := s.safety.SyncRunThreshold
+	async := req.AllowAsync && threshold > 0
+	limits := s.resultLimits(connector).capRows(prefs.DefaultRowLimit)
+	done := s.startRun(reportRun, s.runTimeout(async), func(ctx context.Context) error {
+		return s.executeRun(ctx, reportRun, &report, reportVersion.Version, connector, req.User, assertions, limits)
+	})
+
+	var runErr error
+	if async {
+		select {
+		case runErr = <-done:
+		case <-time.After(threshold):
+			logger.LogInfo(logger.ServiceREST, "Report run continues in the background", map[string]interface{}{
+				"trace_id":  traceID,
+				"report_id": report.ID,
+				"run_id":    pending.ID,
+				"threshold": threshold.String(),
+			})
+			return &pending, nil
+		}
+	} else {
+		runErr = <-done
+	}
+	if runErr != nil {
+		return nil, runErr
+	}
+	status := reportRun.Status
+	rowCount := reportRun.RowCount
+	results := reportRun.Results
+
 	// Manually populate the relationships
 	populatedReportRun := *reportRun
+	populatedReportRun.Columns = resultColumnAnnotations(s.db, *datasourceID, sqlPrepared, results)
 
 	// Load Report
 	logger.LogInfo(logger.ServiceREST, "Loading report", map[string]interface{}{
+		"trace_id":  traceID,
 		"report_id": report.ID,
 		"run_id":    reportRun.ID,
 	})
 	if err := s.db.First(&populatedReportRun.Report, report.ID).Error; err != nil {
 		logger.LogWarn(logger.ServiceREST, "Failed to load report", map[string]interface{}{
+			"trace_id":  traceID,
 			"report_id": report.ID,
 			"error":     err.Error(),
 		})
 	} else {
 		logger.LogInfo(logger.ServiceREST, "Successfully loaded report", map[string]interface{}{
+			"trace_id":  traceID,
 			"report_id": populatedReportRun.Report.ID,
 			"key":       populatedReportRun.Report.Key,
 			"title":     populatedReportRun.Report.Title,
@@ -409,11 +551,13 @@ func (s *ReportsService) RunReport(reportKey string, req store.RunReportRequest)
 	// Load ReportVersion
 	if err := s.db.First(&populatedReportRun.ReportVersion, reportVersion.ID).Error; err != nil {
 		logger.LogWarn(logger.ServiceREST, "Failed to load report version", map[string]interface{}{
+			"trace_id":   traceID,
 			"version_id": reportVersion.ID,
 			"error":      err.Error(),
 		})
 	} else {
 		logger.LogInfo(logger.ServiceREST, "Successfully loaded report version", map[string]interface{}{
+			"trace_id":   traceID,
 			"version_id": populatedReportRun.ReportVersion.ID,
 			"version":    populatedReportRun.ReportVersion.Version,
 		})
@@ -422,6 +566,7 @@ func (s *ReportsService) RunReport(reportKey string, req store.RunReportRequest)
 	// Load Datasource - check if it exists in database first
 	if err := s.db.Where("id = ?", *datasourceID).First(&populatedReportRun.Datasource).Error; err != nil {
 		logger.LogWarn(logger.ServiceREST, "Failed to load datasource from database", map[string]interface{}{
+			"trace_id":      traceID,
 			"datasource_id": *datasourceID,
 			"error":         err.Error(),
 		})
@@ -437,12 +582,14 @@ func (s *ReportsService) RunReport(reportKey string, req store.RunReportRequest)
 				UpdatedAt:   time.Now(),
 			}
 			logger.LogInfo(logger.ServiceREST, "Loaded datasource from registry", map[string]interface{}{
+				"trace_id":      traceID,
 				"datasource_id": *datasourceID,
 				"kind":          connector.Kind,
 			})
 		}
 	} else {
 		logger.LogInfo(logger.ServiceREST, "Successfully loaded datasource from database", map[string]interface{}{
+			"trace_id":      traceID,
 			"datasource_id": populatedReportRun.Datasource.ID,
 			"kind":          populatedReportRun.Datasource.Kind,
 		})
@@ -450,6 +597,7 @@ func (s *ReportsService) RunReport(reportKey string, req store.RunReportRequest)
 
 	duration := time.Since(start)
 	logger.LogInfo(logger.ServiceREST, "Report run finished", map[string]interface{}{
+		"trace_id":  traceID,
 		"run_id":    populatedReportRun.ID,
 		"report_id": populatedReportRun.ReportID,
 		"status":    status,
@@ -460,6 +608,139 @@ func (s *ReportsService) RunReport(reportKey string, req store.RunReportRequest)
 	return &populatedReportRun, nil
 }
 
+// executeRun executes a recorded run's SQL under ctx, checks the report's assertions,
+// stores its outcome and emits the completed or failed webhook. It finishes
+// runs the caller stopped waiting for too, so the webhook is how those
+// callers learn the outcome.
+func (s *ReportsService) executeRun(ctx context.Context, run *store.ReportRun, report *store.Report, version int, connector *datasource.DatasourceConnector, user string, assertions []preparedAssertion, limits resultLimits) error {
+	// Warehouses that take job labels attribute the query to the report and run
+	ctx = datasource.WithQueryLabels(ctx, map[string]string{
+		"air_report": report.Key,
+		"air_run":    run.TraceID,
+	})
+	// Personal data the SQL returns in a way that cannot be masked is refused
+	// before the query runs
+	piiKinds, execErr := piiKindsForSQL(s.db, run.DatasourceID, run.SQLText)
+	execution, shared := &runExecution{TraceID: run.TraceID}, false
+	if execErr == nil {
+		execution, shared, execErr = s.executeCoalesced(ctx, run, queryComment{
+			Run:     run.TraceID,
+			Report:  report.Key,
+			Version: version,
+			User:    user,
+		}.apply(run.SQLText), connector, limits)
+	}
+	results, rowCount, truncation := execution.Results, execution.RowCount, execution.Truncation
+	if execErr != nil {
+		logger.LogError(logger.ServiceREST, "Report SQL execution failed", execErr, map[string]interface{}{
+			"trace_id":   run.TraceID,
+			"report_id":  run.ReportID,
+			"version_id": run.ReportVersionID,
+			"sql":        run.SQLText,
+		})
+	} else {
+		logger.LogInfo(logger.ServiceREST, "Report SQL executed", map[string]interface{}{
+			"trace_id": run.TraceID,
+			"rows":     rowCount,
+			"sql":      run.SQLText,
+		})
+	}
+
+	finished := time.Now()
+	run.Status = "completed"
+	if execErr != nil {
+		run.Status = "failed"
+		run.ErrorText = execErr.Error()
+	}
+	if execErr == nil {
+		var masked []string
+		if results, masked = maskResultPII(piiKinds, results); len(masked) > 0 {
+			run.Warnings = strings.TrimSpace(run.Warnings + "\nPersonal data masked in columns: " + strings.Join(masked, ", "))
+		}
+	}
+	run.RowCount = rowCount
+	run.Results = results
+	run.FinishedAt = &finished
+	billed := execution.Billed
+	if shared {
+		// The run that executed the query is billed for it
+		run.CoalescedWith = execution.TraceID
+		billed = 0
+	}
+	if connector.BillsByScan() {
+		cost := s.scanCost(billed)
+		run.BytesBilled, run.Cost = &billed, &cost
+	}
+	if truncation != nil {
+		run.Truncated = true
+		run.TruncatedBy = truncation.By
+		run.ResultLimit = truncation.Limit
+		run.Warnings = strings.TrimSpace(run.Warnings + "\n" + truncation.Guidance)
+		logger.LogWarn(logger.ServiceREST, "Report results truncated", map[string]interface{}{
+			"trace_id":  run.TraceID,
+			"report_id": run.ReportID,
+			"by":        truncation.By,
+			"limit":     truncation.Limit,
+			"rows":      rowCount,
+		})
+	}
+
+	// Failed assertions are recorded and flagged; only snapshot refreshes fail on them
+	if execErr == nil && len(assertions) > 0 {
+		if set, err := ParseRunResults(results); err == nil {
+			var failed []string
+			for _, r := range s.evaluateAssertions(report, assertions, connector, &run.ID, columnNames(set.Columns), set.Rows, rowCount) {
+				if !r.Passed {
+					failed = append(failed, r.Name)
+				}
+			}
+			if len(failed) > 0 {
+				run.Warnings = strings.TrimSpace(run.Warnings + "\nFailed assertions: " + strings.Join(failed, ", "))
+			}
+		}
+	}
+
+	if err := s.db.Model(&store.ReportRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+		"status":         run.Status,
+		"error_text":     run.ErrorText,
+		"row_count":      run.RowCount,
+		"results":        run.Results,
+		"finished_at":    run.FinishedAt,
+		"truncated":      run.Truncated,
+		"truncated_by":   run.TruncatedBy,
+		"result_limit":   run.ResultLimit,
+		"warnings":       run.Warnings,
+		"bytes_billed":   run.BytesBilled,
+		"cost":           run.Cost,
+		"coalesced_with": run.CoalescedWith,
+	}).Error; err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to update report run", err, map[string]interface{}{
+			"trace_id": run.TraceID,
+			"run_id":   run.ID,
+		})
+		return fmt.Errorf("failed to update report run: %w", err)
+	}
+
+	event := EventReportRunCompleted
+	if run.Status == "failed" {
+		event = EventReportRunFailed
+	}
+	s.webhooks.Emit(event, map[string]interface{}{
+		"trace_id":      run.TraceID,
+		"report_id":     run.ReportID,
+		"report_key":    report.Key,
+		"run_id":        run.ID,
+		"version":       version,
+		"datasource_id": run.DatasourceID,
+		"status":        run.Status,
+		"row_count":     rowCount,
+		"truncated":     run.Truncated,
+		"error":         run.ErrorText,
+		"duration_ms":   finished.Sub(run.StartedAt).Milliseconds(),
+	})
+	return nil
+}
+
 // GetLatestReportRun retrieves the most recent report run for a given report ID
 func (s *ReportsService) GetLatestReportRun(reportID uint) (*store.ReportRun, error) {
 	var reportRun store.ReportRun
@@ -469,6 +750,7 @@ func (s *ReportsService) GetLatestReportRun(reportID uint) (*store.ReportRun, er
 		}
 		return nil, fmt.Errorf("failed to retrieve report run: %w", err)
 	}
+	reportRun.Columns = resultColumnAnnotations(s.db, reportRun.DatasourceID, reportRun.SQLText, reportRun.Results)
 
 	// Load relationships
 	if err := s.db.Preload("Report").Preload("ReportVersion").Preload("Datasource").First(&reportRun, reportRun.ID).Error; err != nil {
@@ -484,6 +766,165 @@ func (s *ReportsService) GetLatestReportRun(reportID uint) (*store.ReportRun, er
 	return &reportRun, nil
 }
 
+// GetReportRun retrieves a run by ID, including one still running
+func (s *ReportsService) GetReportRun(runID uint) (*store.ReportRun, error) {
+	var reportRun store.ReportRun
+	if err := s.db.Preload("Report").Preload("ReportVersion").Preload("Datasource").First(&reportRun, runID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, classErrorf(ErrNotFound, "report run not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve report run: %w", err)
+	}
+	reportRun.Columns = resultColumnAnnotations(s.db, reportRun.DatasourceID, reportRun.SQLText, reportRun.Results)
+	return &reportRun, nil
+}
+
+// runFields are the ReportRun columns a RunView can select
+var runFields = map[string]bool{
+	"id": true, "report_id": true, "report_version_id": true, "datasource_id": true,
+	"params_json": true, "sql_text": true, "row_count": true, "results": true,
+	"started_at": true, "finished_at": true, "status": true, "error_text": true,
+	"estimated_rows": true, "estimated_bytes": true, "bytes_billed": true, "cost": true,
+	"warnings": true, "trace_id": true, "coalesced_with": true, "run_as": true,
+	"truncated": true, "truncated_by": true, "result_limit": true,
+	"data_stale": true, "data_as_of": true, "results_purged_at": true,
+}
+
+// runIncludes are the relations and computed fields a RunView can include
+var runIncludes = map[string]bool{
+	"report":         true,
+	"report_version": true,
+	"datasource":     true,
+	"columns":        true,
+}
+
+// ValidateRunView rejects fields and includes a ReportRun does not have
+func ValidateRunView(view store.RunView) error {
+	for _, field := range view.Fields {
+		if !runFields[field] {
+			return classErrorf(ErrValidation, "unknown run field %q", field)
+		}
+	}
+	for _, include := range view.Include {
+		if !runIncludes[include] {
+			return classErrorf(ErrValidation, "unknown run include %q", include)
+		}
+	}
+	return nil
+}
+
+// runList is the sort and filter fields of ListReportRuns
+var runList = ListSpec{
+	Table: "report_runs",
+	Sorts: map[string]string{
+		"started_at": "started_at",
+		"row_count":  "row_count",
+	},
+	Filters: map[string]string{
+		"status":        "status",
+		"datasource_id": "datasource_id",
+		"trace_id":      "trace_id",
+	},
+	DefaultSort: "-started_at",
+}
+
+// ListReportRuns returns one page of a report's runs, newest first by default.
+// Only the columns in view.Fields are read, so summaries that leave out
+// results never load the result blob, and relations are only loaded when
+// included.
+func (s *ReportsService) ListReportRuns(reportID uint, opts store.ListOptions, view store.RunView) ([]store.ReportRun, *store.PageInfo, error) {
+	if err := ValidateRunView(view); err != nil {
+		return nil, nil, err
+	}
+	report, err := s.GetReportByID(reportID)
+	if err != nil {
+		return nil, nil, err
+	}
+	include := make(map[string]bool, len(view.Include))
+	for _, name := range view.Include {
+		include[name] = true
+	}
+
+	query := s.db.Where("report_id = ?", reportID)
+	if len(view.Fields) > 0 {
+		// Keys the relations are loaded by, and what column annotations are read from
+		columns := append([]string{"id", "report_id", "report_version_id", "datasource_id"}, view.Fields...)
+		if include["columns"] {
+			columns = append(columns, "sql_text", "results")
+		}
+		query = query.Select(columns)
+	}
+
+	var runs []store.ReportRun
+	page, err := ListPage(query, runList, opts, &runs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list report runs: %w", err)
+	}
+
+	// Load each distinct version and datasource once for the whole page
+	versions := make(map[uint]store.ReportVersion)
+	datasources := make(map[string]store.Datasource)
+	for i := range runs {
+		run := &runs[i]
+		if include["report"] {
+			run.Report = *report
+		}
+		if include["report_version"] {
+			if _, ok := versions[run.ReportVersionID]; !ok {
+				var version store.ReportVersion
+				s.db.First(&version, run.ReportVersionID)
+				versions[run.ReportVersionID] = version
+			}
+			run.ReportVersion = versions[run.ReportVersionID]
+		}
+		if include["datasource"] {
+			if _, ok := datasources[run.DatasourceID]; !ok {
+				var ds store.Datasource
+				s.db.Where("id = ?", run.DatasourceID).First(&ds)
+				datasources[run.DatasourceID] = ds
+			}
+			run.Datasource = datasources[run.DatasourceID]
+		}
+		if include["columns"] {
+			run.Columns = resultColumnAnnotations(s.db, run.DatasourceID, run.SQLText, run.Results)
+		}
+	}
+	return runs, page, nil
+}
+
+// prepareReportSQL substitutes caller params and built-in variables into report SQL
+// and returns the final SQL with the effective params
+func prepareReportSQL(report *store.Report, connector *datasource.DatasourceConnector, sqlText string, callerParams map[string]interface{}, user string, now time.Time) (string, map[string]interface{}, error) {
+	// Built-in variables ({{today}}, {{current_user}}, ...) override caller params
+	builtins, err := resolveTemplateVars(sqlText, connector, report.Timezone, user, now)
+	if err != nil {
+		return "", nil, err
+	}
+	loc, err := loadReportLocation(report.Timezone)
+	if err != nil {
+		return "", nil, err
+	}
+	params := normalizeDateParams(callerParams, loc)
+	if params == nil {
+		params = make(map[string]interface{}, len(builtins))
+	}
+	for k, v := range builtins {
+		params[k] = v
+	}
+
+	// Identifier variables first, so they are not quoted as strings
+	sqlText = replaceIdentifierVars(sqlText, builtins, connector)
+	// Replace simple placeholders {{param}} with provided params (dev only)
+	return replacePlaceholders(sqlText, params), params, nil
+}
+
+// PrepareAdHocSQL substitutes params and built-in variables into SQL that does
+// not belong to a report, such as eval cases. Dates resolve in UTC.
+func PrepareAdHocSQL(connector *datasource.DatasourceConnector, sqlText string, params map[string]interface{}, now time.Time) (string, error) {
+	prepared, _, err := prepareReportSQL(&store.Report{}, connector, sqlText, params, "", now)
+	return prepared, err
+}
+
 func extractSQLFromDef(defJSON string) string {
 	// Case 1: defJSON contains a JSON object
 	var obj map[string]interface{}
@@ -584,59 +1025,123 @@ func executeAndCountRows(db *sql.DB, query string) (int, error) {
 	return count, nil
 }
 
-// executeAndGetResults executes a query and returns both results and row count
-func executeAndGetResults(db *sql.DB, query string) (string, int, error) {
+// resultLimits bounds the results a run stores; zero means no limit
+type resultLimits struct {
+	Rows  int
+	Bytes int
+}
+
+// resultLimits resolves a datasource's limits, falling back to the safety settings
+func (s *ReportsService) resultLimits(connector *datasource.DatasourceConnector) resultLimits {
+	limits := resultLimits{Rows: connector.MaxRows, Bytes: connector.MaxBytes}
+	if limits.Rows <= 0 {
+		limits.Rows = s.safety.MaxRowLimit
+	}
+	if limits.Bytes <= 0 {
+		limits.Bytes = s.safety.MaxResultBytes
+	}
+	return limits
+}
+
+// capRows lowers the row limit to rows, such as a user's default row limit;
+// zero or a higher value leaves it unchanged
+func (l resultLimits) capRows(rows int) resultLimits {
+	if rows > 0 && (l.Rows <= 0 || rows < l.Rows) {
+		l.Rows = rows
+	}
+	return l
+}
+
+// resultTruncation records which limit cut a run's results short
+type resultTruncation struct {
+	By       string // "rows" or "bytes"
+	Limit    int
+	Guidance string
+}
+
+// executeAndGetResults executes a query under ctx, which bounds it, and
+// returns the results as store.ResultSet JSON with their row count. Reading
+// stops at the first row that would pass a limit, and the truncation says
+// which one.
+func executeAndGetResults(ctx context.Context, db *sql.DB, query string, limits resultLimits) (string, int, *resultTruncation, error) {
 	if db == nil {
-		return "", 0, fmt.Errorf("nil db connection")
+		return "", 0, nil, fmt.Errorf("nil db connection")
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
 	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
-		return "", 0, err
+		return "", 0, nil, err
 	}
 	defer rows.Close()
 
-	cols, err := rows.Columns()
+	columns, err := resultColumns(rows)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	header, err := json.Marshal(columns)
 	if err != nil {
-		return "", 0, err
+		return "", 0, nil, fmt.Errorf("failed to marshal columns: %w", err)
 	}
 
-	var results []map[string]interface{}
-	values := make([]interface{}, len(cols))
-	scanArgs := make([]interface{}, len(cols))
+	values := make([]interface{}, len(columns))
+	scanArgs := make([]interface{}, len(columns))
 	for i := range values {
 		scanArgs[i] = &values[i]
 	}
 
+	// Rows are encoded as they are read so the byte limit applies to what is stored
+	var buf bytes.Buffer
+	buf.WriteString(`{"columns":`)
+	buf.Write(header)
+	buf.WriteString(`,"rows":[`)
+	count := 0
+	var truncation *resultTruncation
 	for rows.Next() {
+		if limits.Rows > 0 && count == limits.Rows {
+			truncation = &resultTruncation{
+				By:       "rows",
+				Limit:    limits.Rows,
+				Guidance: fmt.Sprintf("Results truncated to the first %d rows (max_row_limit). Narrow the date range or other filters, aggregate with GROUP BY, or add a LIMIT.", limits.Rows),
+			}
+			break
+		}
 		if err := rows.Scan(scanArgs...); err != nil {
-			return "", 0, err
+			return "", 0, nil, err
 		}
 
-		row := make(map[string]interface{})
-		for i, col := range cols {
-			val := values[i]
+		row := make([]interface{}, len(values))
+		for i, val := range values {
 			if b, ok := val.([]byte); ok {
-				row[col] = string(b)
+				row[i] = string(b)
 			} else {
-				row[col] = val
+				row[i] = val
 			}
 		}
-		results = append(results, row)
+		encoded, err := json.Marshal(row)
+		if err != nil {
+			return "", 0, nil, fmt.Errorf("failed to marshal results: %w", err)
+		}
+		// Two bytes for the separator or the closing brackets
+		if limits.Bytes > 0 && buf.Len()+len(encoded)+2 > limits.Bytes {
+			truncation = &resultTruncation{
+				By:       "bytes",
+				Limit:    limits.Bytes,
+				Guidance: fmt.Sprintf("Results truncated after %d rows at %d bytes (max_result_bytes). Select fewer or narrower columns, aggregate with GROUP BY, or narrow the filters.", count, limits.Bytes),
+			}
+			break
+		}
+		if count > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(encoded)
+		count++
 	}
 
 	if err := rows.Err(); err != nil {
-		return "", 0, err
-	}
-
-	// Convert results to JSON
-	resultsJSON, err := json.Marshal(results)
-	if err != nil {
-		return "", 0, fmt.Errorf("failed to marshal results: %w", err)
+		return "", 0, nil, err
 	}
 
-	return string(resultsJSON), len(results), nil
+	buf.WriteString("]}")
+	return buf.String(), count, truncation, nil
 }
 
 // ExportReport exports a report in various formats
@@ -650,14 +1155,14 @@ func (s *ReportsService) ExportReport(reportKey string, format string) ([]byte,
 	var report store.Report
 	if err := s.db.Where("key = ?", reportKey).First(&report).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("report not found")
+			return nil, classErrorf(ErrNotFound, "report not found")
 		}
 		return nil, fmt.Errorf("failed to find report: %w", err)
 	}
 
 	// Get latest report version
 	var reportVersion store.ReportVersion
-	if err := s.db.Where("report_id = ?", report.ID).
+	if err := s.db.Scopes(approvedVersions).Where("report_id = ?", report.ID).
 		Order("version DESC").
 		First(&reportVersion).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -694,13 +1199,41 @@ func (s *ReportsService) ExportReport(reportKey string, format string) ([]byte,
 	}
 }
 
-// ListReports returns all reports
-func (s *ReportsService) ListReports() ([]store.Report, error) {
+// reportList is the sort and filter fields of ListReports
+var reportList = ListSpec{
+	Table: "reports",
+	Sorts: map[string]string{
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+		"title":      "title",
+		"key":        "key",
+	},
+	Filters: map[string]string{
+		"folder": "folder",
+		"owner":  "owner",
+	},
+	DefaultSort: "-created_at",
+}
+
+// ListReports returns one page of reports, newest first by default. Sorted
+// by relevance, the user's favorites and recently used reports come first;
+// either way each report says whether it is one of the user's favorites.
+func (s *ReportsService) ListReports(opts store.ListOptions, user string) ([]store.Report, *store.PageInfo, error) {
 	var reports []store.Report
-	if err := s.db.Order("created_at DESC").Find(&reports).Error; err != nil {
-		return nil, err
+	var page *store.PageInfo
+	var err error
+	if opts.Sort == relevanceSort {
+		reports, page, err = s.listReportsByRelevance(opts, user)
+	} else {
+		page, err = ListPage(s.db, reportList, opts, &reports)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.annotateReportAccess(user, reports); err != nil {
+		return nil, nil, err
 	}
-	return reports, nil
+	return reports, page, nil
 }
 
 // GetReportByID retrieves a report by numeric ID
@@ -714,6 +1247,31 @@ func (s *ReportsService) GetReportByID(id uint) (*store.Report, error) {
 
 // DeleteReportByID deletes a report by ID
 func (s *ReportsService) DeleteReportByID(id uint) error {
+	if err := s.db.Where("report_id = ?", id).Delete(&store.ReportAccess{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("report_id = ?", id).Delete(&store.ReportSummaryTemplate{}).Error; err != nil {
+		return err
+	}
+	shares := s.db.Model(&store.ShareLink{}).Select("id").Where("report_id = ?", id)
+	if err := s.db.Where("share_link_id IN (?)", shares).Delete(&store.ShareAccess{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("report_id = ?", id).Delete(&store.ShareLink{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("report_id = ?", id).Delete(&store.LineageEdge{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("report_id = ?", id).Delete(&store.ReportValidation{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("report_id = ?", id).Delete(&store.ReportAssertionResult{}).Error; err != nil {
+		return err
+	}
+	if err := s.db.Where("report_id = ?", id).Delete(&store.ReportAssertion{}).Error; err != nil {
+		return err
+	}
 	return s.db.Delete(&store.Report{}, id).Error
 }
 
@@ -725,3 +1283,260 @@ func (s *ReportsService) RunReportByID(id uint, req store.RunReportRequest) (*st
 	}
 	return s.RunReport(report.Key, req)
 }
+
+// runDatasource picks the datasource of a run: the request's, else the
+// version's, else the caller's default
+func runDatasource(reportVersion *store.ReportVersion, requested *string, prefs store.UserPreference) (*string, error) {
+	datasourceID := reportVersion.DatasourceID
+	if requested != nil {
+		datasourceID = requested
+	}
+	if (datasourceID == nil || *datasourceID == "") && prefs.DefaultDatasource != "" {
+		datasourceID = &prefs.DefaultDatasource
+	}
+	if datasourceID == nil || *datasourceID == "" {
+		return nil, classErrorf(ErrValidation, "no datasource specified")
+	}
+	return datasourceID, nil
+}
+
+// CreateSQLReport registers hand-written SQL as a report version, bypassing scopes and IR.
+// An existing report with the same key gets a new version.
+func (s *ReportsService) CreateSQLReport(req store.CreateSQLReportRequest) (*store.CreateSQLReportResponse, error) {
+	start := time.Now()
+
+	logger.LogInfo(logger.ServiceREST, "Creating SQL report", map[string]interface{}{
+		"key":           req.Key,
+		"datasource_id": req.DatasourceID,
+	})
+
+	if _, err := s.registry.GetDatasource(req.DatasourceID); err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+
+	safetyReport, err := ValidateReadOnlySQL(req.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("SQL failed safety validation: %w", err)
+	}
+
+	if _, err := loadReportLocation(req.Timezone); err != nil {
+		return nil, err
+	}
+
+	// Without a declared params_schema the form is inferred from the SQL
+	learned, err := learnedColumns(s.db, req.DatasourceID)
+	if err != nil {
+		return nil, err
+	}
+	inferred := inferParamsSchema(req.SQL, learned)
+	paramsSchema := req.ParamsSchema
+	if paramsSchema == nil {
+		paramsSchema = inferred
+	} else if err := checkParamDependencies(paramsSchema); err != nil {
+		return nil, err
+	}
+	properties, _ := paramsSchema["properties"].(map[string]interface{})
+	for _, name := range inferred["required"].([]string) {
+		if _, ok := properties[name]; !ok {
+			return nil, fmt.Errorf("placeholder {{%s}} is not declared in params_schema", name)
+		}
+	}
+
+	defJSON, err := json.Marshal(map[string]interface{}{
+		"sql":           req.SQL,
+		"params_schema": paramsSchema,
+		"source":        "manual",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report definition: %w", err)
+	}
+
+	var report store.Report
+	var version store.ReportVersion
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("key = ?", req.Key).First(&report).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				return fmt.Errorf("failed to check existing report: %w", err)
+			}
+			report = store.Report{
+				Key:       req.Key,
+				Title:     req.Title,
+				Owner:     req.Owner,
+				Timezone:  req.Timezone,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			}
+			if err := tx.Create(&report).Error; err != nil {
+				return fmt.Errorf("failed to create report: %w", err)
+			}
+		}
+
+		var maxVersion int
+		if err := tx.Model(&store.ReportVersion{}).
+			Where("report_id = ?", report.ID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&maxVersion).Error; err != nil {
+			return fmt.Errorf("failed to get max version: %w", err)
+		}
+
+		datasourceID := req.DatasourceID
+		checksum := sha256.Sum256(defJSON)
+		version = store.ReportVersion{
+			ReportID:     report.ID,
+			Version:      maxVersion + 1,
+			DatasourceID: &datasourceID,
+			DefJSON:      string(defJSON),
+			Checksum:     hex.EncodeToString(checksum[:]),
+			Status:       "active",
+			CreatedBy:    req.User,
+			CreatedAt:    time.Now(),
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return fmt.Errorf("failed to create report version: %w", err)
+		}
+		return recordLineage(tx, &version)
+	})
+	if err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to create SQL report", err, map[string]interface{}{
+			"key": req.Key,
+		})
+		return nil, err
+	}
+
+	logger.LogInfo(logger.ServiceREST, "SQL report created successfully", map[string]interface{}{
+		"report_id": report.ID,
+		"version":   version.Version,
+		"duration":  time.Since(start).String(),
+	})
+
+	return &store.CreateSQLReportResponse{
+		Report:       &report,
+		Version:      &version,
+		SafetyReport: safetyReport,
+	}, nil
+}
+
+// GetReportParamsSchema returns the params_schema stored on the latest report
+// version. Versions saved before schemas were stored get one inferred from
+// their SQL; a report without versions has none. Params marked
+// "x-air-enum": "distinct" get the enum of datasourceID, else of the
+// version's datasource.
+func (s *ReportsService) GetReportParamsSchema(reportID uint, datasourceID string) (map[string]interface{}, error) {
+	reportVersion, schema, err := s.latestParamsSchema(reportID)
+	if err != nil || reportVersion == nil {
+		return nil, err
+	}
+	if datasourceID == "" && reportVersion.DatasourceID != nil {
+		datasourceID = *reportVersion.DatasourceID
+	}
+	s.fillParamEnums(datasourceID, extractSQLFromDef(reportVersion.DefJSON), schema)
+	return schema, nil
+}
+
+// latestParamsSchema returns the latest approved version of a report with
+// its params schema, stored or inferred; nil when the report has no versions
+func (s *ReportsService) latestParamsSchema(reportID uint) (*store.ReportVersion, map[string]interface{}, error) {
+	var reportVersion store.ReportVersion
+	if err := s.db.Scopes(approvedVersions).Where("report_id = ?", reportID).Order("version DESC").First(&reportVersion).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to find report version: %w", err)
+	}
+
+	var def map[string]interface{}
+	if err := json.Unmarshal([]byte(reportVersion.DefJSON), &def); err == nil {
+		if schema, ok := def["params_schema"].(map[string]interface{}); ok {
+			return &reportVersion, schema, nil
+		}
+	}
+	datasourceID := ""
+	if reportVersion.DatasourceID != nil {
+		datasourceID = *reportVersion.DatasourceID
+	}
+	learned, err := learnedColumns(s.db, datasourceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &reportVersion, inferParamsSchema(extractSQLFromDef(reportVersion.DefJSON), learned), nil
+}
+
+// ListScopeVersions returns all versions of a scope, newest first
+func (s *ReportsService) ListScopeVersions(scopeID uint) ([]store.ScopeVersion, error) {
+	if _, err := s.GetScope(scopeID); err != nil {
+		return nil, err
+	}
+
+	var versions []store.ScopeVersion
+	if err := s.db.Where("scope_id = ?", scopeID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list scope versions: %w", err)
+	}
+	return versions, nil
+}
+
+// LatestScopeVersion returns the newest version number of a scope, 0 when it
+// has none. It is the value If-Match is compared against.
+func (s *ReportsService) LatestScopeVersion(scopeID uint) (int, error) {
+	var version int
+	if err := s.db.Model(&store.ScopeVersion{}).
+		Where("scope_id = ?", scopeID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to get latest scope version: %w", err)
+	}
+	return version, nil
+}
+
+// LatestReportVersion returns the newest version number of a report, 0 when
+// it has none
+func (s *ReportsService) LatestReportVersion(reportID uint) (int, error) {
+	var version int
+	if err := s.db.Model(&store.ReportVersion{}).
+		Where("report_id = ?", reportID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to get latest report version: %w", err)
+	}
+	return version, nil
+}
+
+// DiffScopeVersions compares two versions of a scope by version number
+func (s *ReportsService) DiffScopeVersions(scopeID uint, fromVersion, toVersion int) (*store.ScopeVersionDiffResponse, error) {
+	var from, to store.ScopeVersion
+	if err := s.db.Where("scope_id = ? AND version = ?", scopeID, fromVersion).First(&from).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "scope version %d not found", fromVersion)
+	}
+	if err := s.db.Where("scope_id = ? AND version = ?", scopeID, toVersion).First(&to).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "scope version %d not found", toVersion)
+	}
+
+	irChanges, err := diffIR(from.IRJSON, to.IRJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	fromLabel := fmt.Sprintf("v%d", fromVersion)
+	toLabel := fmt.Sprintf("v%d", toVersion)
+
+	return &store.ScopeVersionDiffResponse{
+		ScopeID:     scopeID,
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		ScopeMDDiff: unifiedDiff(fromLabel+"/scope.md", toLabel+"/scope.md", from.ScopeMD, to.ScopeMD),
+		IRJSONDiff:  unifiedDiff(fromLabel+"/ir.json", toLabel+"/ir.json", prettyJSON(from.IRJSON), prettyJSON(to.IRJSON)),
+		IRChanges:   irChanges,
+	}, nil
+}
+
+// prettyJSON indents a JSON document so line diffs are readable; invalid input is returned as-is
+func prettyJSON(raw string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return raw
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return raw
+	}
+	return string(out)
+}
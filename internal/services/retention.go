@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// retentionBatch bounds the IDs in one IN clause, under SQLite's variable limit
+const retentionBatch = 500
+
+// RetentionService removes old run results, runs, AI traces and orphaned
+// uploads according to the retention config
+type RetentionService struct {
+	db     *gorm.DB
+	policy config.RetentionConfig
+	locks  *redis.Client
+
+	mu   sync.Mutex
+	last *store.RetentionReport
+}
+
+// NewRetentionService creates a new retention service
+func NewRetentionService(db *gorm.DB, cfg *config.Config) *RetentionService {
+	return &RetentionService{db: db, policy: cfg.Retention}
+}
+
+// SetLocks runs scheduled cleanups once across API replicas
+func (s *RetentionService) SetLocks(locks *redis.Client) {
+	s.locks = locks
+}
+
+// Policy returns the configured retention policy
+func (s *RetentionService) Policy() config.RetentionConfig {
+	return s.policy
+}
+
+// LastReport returns the outcome of the last cleanup, or nil before the first
+func (s *RetentionService) LastReport() *store.RetentionReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Start runs the cleanup worker in the background every retention interval
+func (s *RetentionService) Start() {
+	if s.policy.Interval <= 0 {
+		return
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Retention worker started", map[string]interface{}{
+		"interval": s.policy.Interval.String(),
+	})
+
+	go func() {
+		ticker := time.NewTicker(s.policy.Interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.Run(false); err != nil && !errors.Is(err, redis.ErrLockHeld) {
+				logger.LogError(logger.ServiceDB, "Retention cleanup failed", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Run applies the retention policy once. A dry run only counts what would be
+// removed. A failing step is recorded in the report and the others still run.
+func (s *RetentionService) Run(dryRun bool) (*store.RetentionReport, error) {
+	report := &store.RetentionReport{DryRun: dryRun, UploadsDeleted: []string{}, StartedAt: time.Now()}
+	err := withJobLock(context.Background(), s.locks, "retention", func(context.Context, int64) error {
+		steps := []struct {
+			name string
+			run  func(*store.RetentionReport, bool) error
+		}{
+			{"runs", s.pruneRuns},
+			{"results", s.purgeResults},
+			{"traces", s.pruneTraces},
+			{"uploads", s.pruneUploads},
+		}
+		for _, step := range steps {
+			if err := step.run(report, dryRun); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", step.name, err))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+
+	logger.LogInfo(logger.ServiceDB, "Retention cleanup finished", map[string]interface{}{
+		"dry_run":         dryRun,
+		"runs_deleted":    report.RunsDeleted,
+		"results_purged":  report.ResultsPurged,
+		"traces_deleted":  report.TracesDeleted,
+		"uploads_deleted": len(report.UploadsDeleted),
+		"errors":          len(report.Errors),
+	})
+
+	if !dryRun {
+		s.mu.Lock()
+		s.last = report
+		s.mu.Unlock()
+	}
+	return report, nil
+}
+
+// pruneRuns deletes each report's runs beyond the newest keep_runs_per_report,
+// with their samples, analyses and assertion results. Running runs and runs
+// with feedback are kept: feedback is the SQL example and tuning set.
+func (s *RetentionService) pruneRuns(report *store.RetentionReport, dryRun bool) error {
+	keep := s.policy.KeepRunsPerReport
+	if keep <= 0 {
+		return nil
+	}
+
+	var ids []uint
+	err := s.db.Model(&store.ReportRun{}).
+		Where("status <> ?", "running").
+		Where("id NOT IN (?)", s.db.Model(&store.RunFeedback{}).Select("run_id")).
+		Where("(SELECT COUNT(*) FROM report_runs newer WHERE newer.report_id = report_runs.report_id AND newer.id > report_runs.id) >= ?", keep).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return fmt.Errorf("failed to find runs to prune: %w", err)
+	}
+	report.RunsDeleted = int64(len(ids))
+	if dryRun || len(ids) == 0 {
+		return nil
+	}
+
+	for start := 0; start < len(ids); start += retentionBatch {
+		batch := ids[start:min(start+retentionBatch, len(ids))]
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&store.ReportSample{}, &store.ReportAnalysis{}, &store.ReportAssertionResult{}} {
+				if err := tx.Where("run_id IN ?", batch).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Where("id IN ?", batch).Delete(&store.ReportRun{}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete runs: %w", err)
+		}
+	}
+	return nil
+}
+
+// purgeResults empties the results and samples of runs finished before
+// results_max_age, keeping the run record and its analyses
+func (s *RetentionService) purgeResults(report *store.RetentionReport, dryRun bool) error {
+	if s.policy.ResultsMaxAge <= 0 {
+		return nil
+	}
+	cutoff := time.Now().Add(-s.policy.ResultsMaxAge)
+	query := func() *gorm.DB {
+		return s.db.Model(&store.ReportRun{}).Where("finished_at < ? AND results_purged_at IS NULL", cutoff)
+	}
+
+	if dryRun {
+		return query().Count(&report.ResultsPurged).Error
+	}
+
+	var ids []uint
+	if err := query().Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("failed to find runs to purge: %w", err)
+	}
+	now := time.Now()
+	for start := 0; start < len(ids); start += retentionBatch {
+		batch := ids[start:min(start+retentionBatch, len(ids))]
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("run_id IN ?", batch).Delete(&store.ReportSample{}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&store.ReportRun{}).Where("id IN ?", batch).
+				Updates(map[string]interface{}{"results": "", "results_purged_at": now}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to purge results: %w", err)
+		}
+		report.ResultsPurged += int64(len(batch))
+	}
+	return nil
+}
+
+// pruneTraces deletes AI traces older than traces_max_age
+func (s *RetentionService) pruneTraces(report *store.RetentionReport, dryRun bool) error {
+	if s.policy.TracesMaxAge <= 0 {
+		return nil
+	}
+	query := s.db.Where("created_at < ?", time.Now().Add(-s.policy.TracesMaxAge))
+	if dryRun {
+		return query.Model(&store.AITrace{}).Count(&report.TracesDeleted).Error
+	}
+	result := query.Delete(&store.AITrace{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete traces: %w", result.Error)
+	}
+	report.TracesDeleted = result.RowsAffected
+	return nil
+}
+
+// pruneUploads deletes uploaded files older than orphan_upload_age that no
+// session, session dataset, file analysis or generated report refers to
+func (s *RetentionService) pruneUploads(report *store.RetentionReport, dryRun bool) error {
+	if !s.policy.OrphanUploads {
+		return nil
+	}
+	entries, err := os.ReadDir(uploadsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read uploads: %w", err)
+	}
+
+	used, err := s.usedUploads()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-s.policy.OrphanUploadAge)
+	for _, entry := range entries {
+		if entry.IsDir() || used[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(filepath.Join(uploadsDir, entry.Name())); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("uploads: %v", err))
+				continue
+			}
+		}
+		report.UploadsDeleted = append(report.UploadsDeleted, entry.Name())
+		report.UploadBytes += info.Size()
+	}
+	sort.Strings(report.UploadsDeleted)
+	return nil
+}
+
+// usedUploads returns the file names of uploads still referenced
+func (s *RetentionService) usedUploads() (map[string]bool, error) {
+	used := make(map[string]bool)
+	sources := []struct {
+		model  interface{}
+		column string
+	}{
+		{&store.Session{}, "file_path"},
+		{&store.SessionDataset{}, "file_id"},
+		{&store.FileAnalysis{}, "file_id"},
+		{&store.GeneratedReport{}, "file_path"},
+	}
+	for _, source := range sources {
+		var names []string
+		if err := s.db.Model(source.model).Distinct().Pluck(source.column, &names).Error; err != nil {
+			return nil, fmt.Errorf("failed to load upload references: %w", err)
+		}
+		for _, name := range names {
+			if name != "" {
+				used[filepath.Base(name)] = true
+			}
+		}
+	}
+	return used, nil
+}
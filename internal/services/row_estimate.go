@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// ErrRowEstimateExceeded is returned when safety.row_estimate is "deny" and a
+// report is expected to return more than safety.max_row_limit rows
+var ErrRowEstimateExceeded = classErrorf(ErrSafetyBlocked, "estimated row count exceeds max_row_limit")
+
+// rowEstimateTimeout bounds the pre-check so it stays cheaper than the query itself
+const rowEstimateTimeout = 10 * time.Second
+
+// checkRowEstimate estimates the rows sqlText will return and applies the configured
+// policy. The estimate queries carry comment. Estimator failures are logged
+// and never block the run.
+func (s *ReportsService) checkRowEstimate(connector *datasource.DatasourceConnector, sqlText string, comment queryComment) (*store.RowEstimate, error) {
+	mode := s.safety.RowEstimate
+	limit := s.resultLimits(connector).Rows
+	if mode == "" || mode == "off" || limit <= 0 || connector.DB == nil {
+		return nil, nil
+	}
+	if connector.BillsByScan() {
+		// Without a planner estimate the bounded count would be billed like
+		// the query itself; the dry run in checkCostBudget guards these
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rowEstimateTimeout)
+	defer cancel()
+
+	rows, method, err := estimateRowCount(ctx, connector, sqlText, limit, comment)
+	if err != nil {
+		logger.LogWarn(logger.ServiceREST, "Row estimate failed; running query without pre-check", map[string]interface{}{
+			"datasource_id": connector.ID,
+			"error":         err.Error(),
+		})
+		return nil, nil
+	}
+
+	estimate := &store.RowEstimate{
+		Rows:   rows,
+		Method: method,
+		Limit:  limit,
+	}
+	if rows <= int64(limit) {
+		return estimate, nil
+	}
+
+	estimate.Exceeded = true
+	estimate.Guidance = fmt.Sprintf("Estimated ~%d rows exceeds max_row_limit (%d). Narrow the date range or other filters, aggregate with GROUP BY, or add a LIMIT.", rows, limit)
+
+	logger.LogWarn(logger.ServiceREST, "Row estimate exceeds max_row_limit", map[string]interface{}{
+		"datasource_id": connector.ID,
+		"estimate":      rows,
+		"method":        method,
+		"limit":         limit,
+		"mode":          mode,
+	})
+
+	if mode == "deny" {
+		return estimate, fmt.Errorf("%w: %s", ErrRowEstimateExceeded, estimate.Guidance)
+	}
+	return estimate, nil
+}
+
+// estimateRowCount returns an estimated result size and the method used. The
+// connector's planner estimate is used when it has one; otherwise a subquery
+// capped at limit+1 rows is counted. Both are tagged with comment.
+func estimateRowCount(ctx context.Context, connector *datasource.DatasourceConnector, sqlText string, limit int, comment queryComment) (int64, string, error) {
+	query := trimStatementEnd(sqlText)
+
+	rows, err := connector.Explain(ctx, comment.apply(query))
+	if err == nil {
+		return rows, "explain", nil
+	}
+	if !errors.Is(err, datasource.ErrNoEstimate) {
+		return 0, "", err
+	}
+
+	bounded := comment.apply(fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM (%s) AS estimate_q LIMIT %d) AS estimate_c", query, limit+1))
+	if err := connector.DB.QueryRowContext(ctx, bounded).Scan(&rows); err != nil {
+		return 0, "", err
+	}
+	return rows, "bounded_count", nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/NubeDev/air/internal/datasource"
+)
+
+// runAsKey names the datasource credential a report definition runs as
+const runAsKey = "run_as"
+
+// runAsFromDef returns the credential a report definition's SQL runs as, or
+// "" for the datasource's own sign-in
+func runAsFromDef(defJSON string) (string, error) {
+	var def map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(defJSON), &def); err != nil {
+		return "", nil
+	}
+	raw, ok := def[runAsKey]
+	if !ok || string(raw) == "null" {
+		return "", nil
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil || strings.TrimSpace(name) == "" {
+		return "", classErrorf(ErrValidation, "%s must name a credential of the datasource", runAsKey)
+	}
+	return name, nil
+}
+
+// runAsDB returns the pool a report definition's SQL executes on: the
+// connector's own, or the one signed in as the definition's run_as
+// credential
+func runAsDB(connector *datasource.DatasourceConnector, defJSON string) (*sql.DB, string, error) {
+	name, err := runAsFromDef(defJSON)
+	if err != nil {
+		return nil, "", err
+	}
+	db, err := connector.As(name)
+	if errors.Is(err, datasource.ErrCredentialNotFound) {
+		return nil, "", classErrorf(ErrValidation, "%s: %w", runAsKey, err)
+	}
+	return db, name, err
+}
+
+// checkRunAs refuses a definition naming a credential its bound datasource
+// does not configure. Portable definitions are checked when they run.
+func (s *ReportsService) checkRunAs(defJSON string, datasourceID *string) error {
+	name, err := runAsFromDef(defJSON)
+	if err != nil || name == "" || datasourceID == nil || *datasourceID == "" {
+		return err
+	}
+	connector, err := s.registry.GetDatasource(*datasourceID)
+	if err != nil {
+		return nil
+	}
+	for _, credential := range connector.Credentials {
+		if credential.Name == name {
+			return nil
+		}
+	}
+	return classErrorf(ErrValidation, "%s: datasource %s has no credential %q", runAsKey, *datasourceID, name)
+}
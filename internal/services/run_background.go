@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// Fallbacks when safety.run_timeout or safety.async_run_timeout is not
+// configured
+const (
+	defaultRunTimeout      = 60 * time.Second
+	defaultAsyncRunTimeout = 30 * time.Minute
+)
+
+// errRunInterrupted is recorded on runs the server stopped before they
+// finished
+const errRunInterrupted = "run interrupted: the server stopped before it finished"
+
+// runTimeout returns how long a run's query may take: safety.run_timeout
+// while the caller waits, or safety.async_run_timeout when the run may
+// finish in the background
+func (s *ReportsService) runTimeout(async bool) time.Duration {
+	timeout, fallback := s.safety.RunTimeout, defaultRunTimeout
+	if async {
+		timeout, fallback = s.safety.AsyncRunTimeout, defaultAsyncRunTimeout
+	}
+	if timeout <= 0 {
+		return fallback
+	}
+	return timeout
+}
+
+// startRun executes a recorded run on a goroutine Shutdown waits for,
+// bounded by timeout and cancelled by Shutdown. The returned channel gets
+// execute's error. A panic fails the run rather than leaving it running.
+func (s *ReportsService) startRun(run *store.ReportRun, timeout time.Duration, execute func(ctx context.Context) error) <-chan error {
+	done := make(chan error, 1)
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("report run panicked: %v", r)
+				logger.LogError(logger.ServiceREST, "Report run panicked", err, map[string]interface{}{
+					"trace_id": run.TraceID,
+					"run_id":   run.ID,
+				})
+				s.failRuns(err.Error(), "id = ?", run.ID)
+				done <- err
+			}
+		}()
+		ctx, cancel := context.WithTimeout(s.runCtx, timeout)
+		defer cancel()
+		done <- execute(ctx)
+	}()
+	return done
+}
+
+// Shutdown waits for runs executing in the background until ctx is done,
+// then cancels the rest so they are recorded as failed rather than left
+// running
+func (s *ReportsService) Shutdown(ctx context.Context) {
+	finished := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		logger.LogWarn(logger.ServiceREST, "Cancelling report runs still executing at shutdown")
+		s.stopRuns()
+		<-finished
+	}
+	s.stopRuns()
+}
+
+// FailOrphanedRuns marks runs left running by a stopped process as failed,
+// so callers polling them get an outcome. Instances sharing the store
+// through Redis may still be executing recent runs, so only runs older than
+// the async run timeout are failed there.
+func (s *ReportsService) FailOrphanedRuns() {
+	cutoff := time.Now()
+	if s.locks != nil {
+		cutoff = cutoff.Add(-s.runTimeout(true))
+	}
+	if failed := s.failRuns(errRunInterrupted, "started_at < ?", cutoff); failed > 0 {
+		logger.LogInfo(logger.ServiceREST, "Marked orphaned report runs failed", map[string]interface{}{
+			"runs": failed,
+		})
+	}
+}
+
+// failRuns records the runs matching a condition that are still running as
+// failed and returns how many it updated
+func (s *ReportsService) failRuns(reason string, query string, args ...interface{}) int64 {
+	result := s.db.Model(&store.ReportRun{}).Where("status = ?", "running").Where(query, args...).Updates(map[string]interface{}{
+		"status":      "failed",
+		"error_text":  reason,
+		"finished_at": time.Now(),
+	})
+	if result.Error != nil {
+		logger.LogError(logger.ServiceREST, "Failed to mark report runs failed", result.Error)
+		return 0
+	}
+	return result.RowsAffected
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// runExecution is the outcome of one database execution of a report run's
+// SQL, shared by every identical run that was in flight alongside it
+type runExecution struct {
+	Results    string
+	RowCount   int
+	Truncation *resultTruncation
+	Billed     int64  // bytes the warehouse billed, on warehouses billed by scan
+	TraceID    string // trace ID of the run that executed the query
+}
+
+// runCoalesceKey identifies runs that would execute the same query with the
+// same result limits: the report version, datasource and credential,
+// resolved params and limits. Built-in variables such as {{current_user}}
+// are part of the params, so runs only coalesce when their SQL is identical.
+func runCoalesceKey(run *store.ReportRun, limits resultLimits) string {
+	return fmt.Sprintf("%d/%d/%s/%s/%s/%d/%d", run.ReportID, run.ReportVersionID, run.DatasourceID, run.RunAs, run.ParamsJSON, limits.Rows, limits.Bytes)
+}
+
+// executeCoalesced executes a run's SQL, or, when an identical run is already
+// executing (e.g. dashboards auto-refreshing the same report), waits for that
+// execution and shares its result instead of querying the database again.
+// shared is true when the result came from another run's execution, whose
+// ctx the query then ran with.
+func (s *ReportsService) executeCoalesced(ctx context.Context, run *store.ReportRun, query string, connector *datasource.DatasourceConnector, limits resultLimits) (execution *runExecution, shared bool, err error) {
+	executed := false
+	value, err, _ := s.inflight.Do(runCoalesceKey(run, limits), func() (interface{}, error) {
+		executed = true
+		execution := &runExecution{TraceID: run.TraceID}
+		db, err := connector.As(run.RunAs)
+		if err != nil {
+			return execution, err
+		}
+		billedCtx := datasource.WithBilledBytes(ctx, &execution.Billed)
+		execution.Results, execution.RowCount, execution.Truncation, err = executeAndGetResults(billedCtx, db, query, limits)
+		return execution, err
+	})
+	if executed {
+		return value.(*runExecution), false, err
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Report run shared an identical in-flight execution", map[string]interface{}{
+		"trace_id":       run.TraceID,
+		"report_id":      run.ReportID,
+		"coalesced_with": value.(*runExecution).TraceID,
+	})
+	return value.(*runExecution), true, err
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/NubeDev/air/internal/store"
+)
+
+// resultColumns describes the columns of rows in select order
+func resultColumns(rows *sql.Rows) ([]store.ResultColumn, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]store.ResultColumn, len(types))
+	for i, t := range types {
+		columns[i] = store.ResultColumn{Name: t.Name(), Type: t.DatabaseTypeName()}
+	}
+	return columns, nil
+}
+
+// columnNames returns the names of columns in order
+func columnNames(columns []store.ResultColumn) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// ParseRunResults decodes a run's stored results. Runs from before results
+// kept their columns hold an array of row objects instead; their columns come
+// back sorted by name and without types.
+func ParseRunResults(results string) (*store.ResultSet, error) {
+	set := &store.ResultSet{Columns: []store.ResultColumn{}, Rows: [][]interface{}{}}
+	trimmed := strings.TrimSpace(results)
+	if trimmed == "" || trimmed == "null" {
+		return set, nil
+	}
+
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), set); err != nil {
+			return nil, fmt.Errorf("failed to decode results: %w", err)
+		}
+		if set.Rows == nil {
+			set.Rows = [][]interface{}{}
+		}
+		return set, nil
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode results: %w", err)
+	}
+	if len(records) == 0 {
+		return set, nil
+	}
+	names := make([]string, 0, len(records[0]))
+	for name := range records[0] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		set.Columns = append(set.Columns, store.ResultColumn{Name: name})
+	}
+	for _, record := range records {
+		row := make([]interface{}, len(names))
+		for i, name := range names {
+			row[i] = record[name]
+		}
+		set.Rows = append(set.Rows, row)
+	}
+	return set, nil
+}
+
+// resultRecords returns one object per row keyed by column name, for
+// payloads that address values by name
+func resultRecords(set *store.ResultSet) []map[string]interface{} {
+	records := make([]map[string]interface{}, 0, len(set.Rows))
+	for _, row := range set.Rows {
+		record := make(map[string]interface{}, len(set.Columns))
+		for i, column := range set.Columns {
+			if i < len(row) {
+				record[column.Name] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Run summary formats
+const (
+	SummaryMarkdown = "markdown"
+	SummaryHTML     = "html"
+)
+
+// summaryMaxAggregates caps the numeric columns a summary lists
+const summaryMaxAggregates = 10
+
+// Default summary templates. HTML templates are html/template, so values are
+// escaped; the analysis Markdown is shown as preformatted text.
+const (
+	defaultSummaryMarkdown = `# {{.ReportTitle}}
+
+{{.ReportKey}} v{{.Version}} · run {{.RunID}} · {{.Status}}{{if .FinishedAt}} · {{.FinishedAt.Format "2006-01-02 15:04 MST"}}{{end}}
+
+Rows: {{.RowCount}}{{if .Truncated}} (truncated){{end}}{{if .DurationMS}} · {{.DurationMS}} ms{{end}}{{if .DataStale}} · the data may be stale{{end}}
+{{if .Error}}
+**Error:** {{.Error}}
+{{end}}{{if .Aggregates}}
+## Key figures
+
+| Column | Count | Sum | Min | Max | Average |
+|---|---:|---:|---:|---:|---:|
+{{range .Aggregates}}| {{cell .Column}} | {{.Count}} | {{num .Sum}} | {{num .Min}} | {{num .Max}} | {{num .Avg}} |
+{{end}}{{end}}{{if or .Analysis .Findings}}
+## Analysis
+{{if .Severity}}
+Severity: **{{.Severity}}**
+{{end}}{{if .Findings}}
+{{range .Findings}}- {{.}}
+{{end}}{{end}}{{if .Analysis}}
+{{.Analysis}}
+{{end}}{{end}}{{if .ChartSpec}}
+## Chart
+
+` + "```json" + `
+{{.ChartSpec}}
+` + "```" + `
+{{end}}`
+
+	defaultSummaryHTML = `<h1>{{.ReportTitle}}</h1>
+<p>{{.ReportKey}} v{{.Version}} · run {{.RunID}} · {{.Status}}{{if .FinishedAt}} · {{.FinishedAt.Format "2006-01-02 15:04 MST"}}{{end}}</p>
+<p>Rows: {{.RowCount}}{{if .Truncated}} (truncated){{end}}{{if .DurationMS}} · {{.DurationMS}} ms{{end}}{{if .DataStale}} · the data may be stale{{end}}</p>
+{{if .Error}}<p><strong>Error:</strong> {{.Error}}</p>
+{{end}}{{if .Aggregates}}<h2>Key figures</h2>
+<table>
+<tr><th>Column</th><th>Count</th><th>Sum</th><th>Min</th><th>Max</th><th>Average</th></tr>
+{{range .Aggregates}}<tr><td>{{.Column}}</td><td>{{.Count}}</td><td>{{num .Sum}}</td><td>{{num .Min}}</td><td>{{num .Max}}</td><td>{{num .Avg}}</td></tr>
+{{end}}</table>
+{{end}}{{if or .Analysis .Findings}}<h2>Analysis</h2>
+{{if .Severity}}<p>Severity: <strong>{{.Severity}}</strong></p>
+{{end}}{{if .Findings}}<ul>
+{{range .Findings}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .Analysis}}<pre style="white-space: pre-wrap">{{.Analysis}}</pre>
+{{end}}{{end}}{{if .ChartSpec}}<h2>Chart</h2>
+<pre>{{.ChartSpec}}</pre>
+{{end}}`
+)
+
+// RunSummary is the data run summary templates are rendered with
+type RunSummary struct {
+	ReportID     uint
+	ReportKey    string
+	ReportTitle  string
+	Version      int
+	RunID        uint
+	DatasourceID string
+	Status       string
+	StartedAt    time.Time
+	FinishedAt   *time.Time
+	DurationMS   int64
+	RowCount     int
+	Truncated    bool
+	DataStale    bool
+	Error        string
+	Aggregates   []SummaryAggregate
+	ChartSpec    string // the version's chart spec as indented JSON
+	Analysis     string // Markdown of the run's latest AI analysis
+	Severity     string
+	Findings     []string
+}
+
+// SummaryAggregate is the totals of one numeric result column
+type SummaryAggregate struct {
+	Column string
+	Count  int // non-null values
+	Sum    float64
+	Min    float64
+	Max    float64
+	Avg    float64
+}
+
+// summaryFuncs are the functions summary templates can call: num formats a
+// number, cell escapes a Markdown table cell
+var summaryFuncs = map[string]interface{}{
+	"num": func(v float64) string {
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatFloat(v, 'f', 0, 64)
+		}
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	},
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+	},
+}
+
+// RenderRunSummary renders a digest of a run as Markdown or HTML: its key
+// aggregates, the version's chart spec and the latest AI analysis, with the
+// report's template for the format when it has one
+func (s *ReportsService) RenderRunSummary(runID uint, format string) (string, error) {
+	if err := checkSummaryFormat(format); err != nil {
+		return "", err
+	}
+	summary, err := s.runSummary(runID)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl := ""
+	var custom store.ReportSummaryTemplate
+	err = s.db.Where("report_id = ? AND format = ?", summary.ReportID, format).First(&custom).Error
+	switch {
+	case err == nil:
+		tmpl = custom.Template
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return "", fmt.Errorf("failed to load summary template: %w", err)
+	}
+	return renderSummary(format, tmpl, summary)
+}
+
+// ListSummaryTemplates returns a report's summary template overrides
+func (s *ReportsService) ListSummaryTemplates(reportID uint) ([]store.ReportSummaryTemplate, error) {
+	if _, err := s.GetReportByID(reportID); err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found: %w", err)
+	}
+	var templates []store.ReportSummaryTemplate
+	if err := s.db.Where("report_id = ?", reportID).Order("format").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to list summary templates: %w", err)
+	}
+	return templates, nil
+}
+
+// SetSummaryTemplate overrides a report's summary template for a format. The
+// template is rendered against sample data first, so a broken one is refused.
+func (s *ReportsService) SetSummaryTemplate(reportID uint, format, tmpl, user string) (*store.ReportSummaryTemplate, error) {
+	if err := checkSummaryFormat(format); err != nil {
+		return nil, err
+	}
+	if _, err := s.GetReportByID(reportID); err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found: %w", err)
+	}
+	if _, err := renderSummary(format, tmpl, sampleRunSummary()); err != nil {
+		return nil, classErrorf(ErrValidation, "%w", err)
+	}
+
+	custom := &store.ReportSummaryTemplate{
+		ReportID:  reportID,
+		Format:    format,
+		Template:  tmpl,
+		UpdatedBy: user,
+		UpdatedAt: time.Now(),
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "report_id"}, {Name: "format"}},
+		DoUpdates: clause.AssignmentColumns([]string{"template", "updated_by", "updated_at"}),
+	}).Create(custom).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save summary template: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Summary template set", map[string]interface{}{
+		"report_id": reportID,
+		"format":    format,
+		"user":      user,
+	})
+	return custom, nil
+}
+
+// DeleteSummaryTemplate restores the default summary template for a format
+func (s *ReportsService) DeleteSummaryTemplate(reportID uint, format string) error {
+	res := s.db.Where("report_id = ? AND format = ?", reportID, format).Delete(&store.ReportSummaryTemplate{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete summary template: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return classErrorf(ErrNotFound, "report %d has no %s summary template", reportID, format)
+	}
+	return nil
+}
+
+// runSummary gathers what a run's summary shows
+func (s *ReportsService) runSummary(runID uint) (*RunSummary, error) {
+	var run store.ReportRun
+	if err := s.db.Preload("Report").Preload("ReportVersion").First(&run, runID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, classErrorf(ErrNotFound, "report run not found")
+		}
+		return nil, fmt.Errorf("failed to retrieve report run: %w", err)
+	}
+
+	summary := &RunSummary{
+		ReportID:     run.ReportID,
+		ReportKey:    run.Report.Key,
+		ReportTitle:  run.Report.Title,
+		Version:      run.ReportVersion.Version,
+		RunID:        run.ID,
+		DatasourceID: run.DatasourceID,
+		Status:       run.Status,
+		StartedAt:    run.StartedAt,
+		FinishedAt:   run.FinishedAt,
+		RowCount:     run.RowCount,
+		Truncated:    run.Truncated,
+		DataStale:    run.DataStale,
+		Error:        run.ErrorText,
+	}
+	if run.FinishedAt != nil {
+		summary.DurationMS = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	}
+	if set, err := ParseRunResults(run.Results); err == nil {
+		summary.Aggregates = summaryAggregates(set)
+	}
+
+	var def map[string]interface{}
+	if err := json.Unmarshal([]byte(run.ReportVersion.DefJSON), &def); err == nil {
+		chart, ok := def["chart_spec"].(map[string]interface{})
+		if !ok {
+			chart, ok = def["chart"].(map[string]interface{})
+		}
+		if ok {
+			encoded, _ := json.MarshalIndent(chart, "", "  ")
+			summary.ChartSpec = string(encoded)
+		}
+	}
+
+	var analysis store.ReportAnalysis
+	err := s.db.Where("run_id = ?", run.ID).Order("created_at DESC").First(&analysis).Error
+	switch {
+	case err == nil:
+		summary.Analysis = strings.TrimSpace(analysis.AnalysisMD)
+		summary.Severity, summary.Findings = analysisFindings(analysis.VerdictJSON)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, fmt.Errorf("failed to load run analysis: %w", err)
+	}
+	return summary, nil
+}
+
+// summaryAggregates totals the numeric columns of a result set. A column is
+// numeric when every non-null value is a number, or numeric text in a column
+// the driver typed as a number.
+func summaryAggregates(set *store.ResultSet) []SummaryAggregate {
+	var aggregates []SummaryAggregate
+	for i, column := range set.Columns {
+		agg := SummaryAggregate{Column: column.Name, Min: math.Inf(1), Max: math.Inf(-1)}
+		numericType := isNumericColumnType(column.Type)
+		numeric := true
+		for _, row := range set.Rows {
+			if i >= len(row) || row[i] == nil {
+				continue
+			}
+			v, ok := summaryNumber(row[i], numericType)
+			if !ok {
+				numeric = false
+				break
+			}
+			agg.Count++
+			agg.Sum += v
+			agg.Min = math.Min(agg.Min, v)
+			agg.Max = math.Max(agg.Max, v)
+		}
+		if !numeric || agg.Count == 0 {
+			continue
+		}
+		agg.Avg = agg.Sum / float64(agg.Count)
+		aggregates = append(aggregates, agg)
+		if len(aggregates) == summaryMaxAggregates {
+			break
+		}
+	}
+	return aggregates
+}
+
+// summaryNumber reads a result value as a number
+func summaryNumber(value interface{}, numericType bool) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		if !numericType {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// isNumericColumnType reports whether a driver column type holds numbers
+func isNumericColumnType(dbType string) bool {
+	upper := strings.ToUpper(dbType)
+	for _, marker := range []string{"INT", "NUM", "DEC", "FLOAT", "DOUBLE", "REAL", "MONEY"} {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderSummary executes tmpl, or the format's default, with summary
+func renderSummary(format, tmpl string, summary *RunSummary) (string, error) {
+	var out bytes.Buffer
+	if format == SummaryHTML {
+		if tmpl == "" {
+			tmpl = defaultSummaryHTML
+		}
+		t, err := htmltemplate.New("summary").Funcs(summaryFuncs).Parse(tmpl)
+		if err != nil {
+			return "", fmt.Errorf("invalid template: %w", err)
+		}
+		if err := t.Execute(&out, summary); err != nil {
+			return "", fmt.Errorf("failed to render template: %w", err)
+		}
+		return out.String(), nil
+	}
+
+	if tmpl == "" {
+		tmpl = defaultSummaryMarkdown
+	}
+	t, err := template.New("summary").Funcs(summaryFuncs).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	if err := t.Execute(&out, summary); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return out.String(), nil
+}
+
+// checkSummaryFormat rejects unknown summary formats
+func checkSummaryFormat(format string) error {
+	if format != SummaryMarkdown && format != SummaryHTML {
+		return classErrorf(ErrValidation, "unknown summary format %q: use %s or %s", format, SummaryMarkdown, SummaryHTML)
+	}
+	return nil
+}
+
+// sampleRunSummary is the data a new template is test-rendered with
+func sampleRunSummary() *RunSummary {
+	now := time.Now()
+	return &RunSummary{
+		ReportID:    1,
+		ReportKey:   "sample",
+		ReportTitle: "Sample report",
+		Version:     1,
+		RunID:       1,
+		Status:      "completed",
+		StartedAt:   now.Add(-time.Second),
+		FinishedAt:  &now,
+		DurationMS:  1000,
+		RowCount:    2,
+		Aggregates:  []SummaryAggregate{{Column: "revenue", Count: 2, Sum: 30, Min: 10, Max: 20, Avg: 15}},
+		ChartSpec:   `{"type": "bar"}`,
+		Analysis:    "Revenue grew.",
+		Severity:    "info",
+		Findings:    []string{"Revenue grew"},
+	}
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// traceIDRe is what a caller-supplied trace ID may look like. It ends up in
+// a SQL comment, so anything that could close the comment is refused.
+var traceIDRe = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// runTraceID returns the caller's trace ID when it is safe to embed in SQL,
+// or a new random one
+func runTraceID(requested string) string {
+	if traceIDRe.MatchString(requested) {
+		return requested
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
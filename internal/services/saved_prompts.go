@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// promptSuggestionLimit caps the prompts offered for one autocomplete prefix
+const promptSuggestionLimit = 20
+
+// ErrSavedPromptNotFound is returned for an unknown prompt, or one of another
+// user that is not shared
+var ErrSavedPromptNotFound = classErrorf(ErrNotFound, "saved prompt not found")
+
+// PromptService keeps the saved prompts library and runs prompts against a
+// session's files or a datasource
+type PromptService struct {
+	db       *gorm.DB
+	ai       *AIService
+	sessions *SessionService
+}
+
+// PromptRun is the answer to a saved prompt and what it was asked about
+type PromptRun struct {
+	PromptID     uint     `json:"prompt_id"`
+	Question     string   `json:"question"` // the prompt with its params filled in
+	SessionID    uint     `json:"session_id,omitempty"`
+	DatasourceID string   `json:"datasource_id,omitempty"`
+	Files        []string `json:"files,omitempty"`
+	*FileAnswer
+}
+
+// NewPromptService creates a new prompt service
+func NewPromptService(db *gorm.DB, ai *AIService, sessions *SessionService) *PromptService {
+	return &PromptService{db: db, ai: ai, sessions: sessions}
+}
+
+// ListPrompts returns the caller's prompts and the shared ones, the caller's
+// first. A prefix narrows them to names starting with it, for autocomplete.
+func (s *PromptService) ListPrompts(user, prefix string) ([]store.SavedPrompt, error) {
+	query := s.db.Where("owner = ? OR shared = ?", user, true)
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(prefix))
+		query = query.Where(`LOWER(name) LIKE ? ESCAPE '\'`, escaped+"%").Limit(promptSuggestionLimit)
+	}
+
+	var prompts []store.SavedPrompt
+	if err := query.Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "owner = ? DESC, name", Vars: []interface{}{user}}}).Find(&prompts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list saved prompts: %w", err)
+	}
+	for i := range prompts {
+		prompts[i].Params = extractSQLPlaceholders(prompts[i].Text)
+	}
+	return prompts, nil
+}
+
+// GetPrompt returns a prompt the caller owns or that is shared
+func (s *PromptService) GetPrompt(id uint, user string) (*store.SavedPrompt, error) {
+	var prompt store.SavedPrompt
+	err := s.db.Where("id = ? AND (owner = ? OR shared = ?)", id, user, true).First(&prompt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSavedPromptNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved prompt: %w", err)
+	}
+	prompt.Params = extractSQLPlaceholders(prompt.Text)
+	return &prompt, nil
+}
+
+// CreatePrompt saves a prompt to the caller's library
+func (s *PromptService) CreatePrompt(user string, req store.CreateSavedPromptRequest) (*store.SavedPrompt, error) {
+	prompt := &store.SavedPrompt{
+		Owner:       user,
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		Text:        strings.TrimSpace(req.Text),
+		Shared:      req.Shared,
+	}
+	if err := s.checkPrompt(prompt); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(prompt).Error; err != nil {
+		return nil, fmt.Errorf("failed to save prompt: %w", err)
+	}
+	prompt.Params = extractSQLPlaceholders(prompt.Text)
+
+	logger.LogInfo(logger.ServiceREST, "Saved prompt created", map[string]interface{}{
+		"prompt_id": prompt.ID,
+		"name":      prompt.Name,
+		"owner":     user,
+		"shared":    prompt.Shared,
+	})
+	return prompt, nil
+}
+
+// UpdatePrompt changes a prompt the caller owns
+func (s *PromptService) UpdatePrompt(id uint, user string, req store.UpdateSavedPromptRequest) (*store.SavedPrompt, error) {
+	prompt, err := s.ownedPrompt(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		prompt.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		prompt.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Text != nil {
+		prompt.Text = strings.TrimSpace(*req.Text)
+	}
+	if req.Shared != nil {
+		prompt.Shared = *req.Shared
+	}
+	if err := s.checkPrompt(prompt); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(prompt).Select("name", "description", "text", "shared").Updates(prompt).Error; err != nil {
+		return nil, fmt.Errorf("failed to update prompt: %w", err)
+	}
+	prompt.Params = extractSQLPlaceholders(prompt.Text)
+	return prompt, nil
+}
+
+// DeletePrompt removes a prompt the caller owns
+func (s *PromptService) DeletePrompt(id uint, user string) error {
+	prompt, err := s.ownedPrompt(id, user)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(prompt).Error; err != nil {
+		return fmt.Errorf("failed to delete prompt: %w", err)
+	}
+	return nil
+}
+
+// RunPrompt fills in a prompt's params and asks it about the request's files
+// or session, else its datasource, else the caller's default datasource
+func (s *PromptService) RunPrompt(ctx context.Context, id uint, user string, req store.RunSavedPromptRequest) (*PromptRun, error) {
+	prompt, err := s.GetPrompt(id, user)
+	if err != nil {
+		return nil, err
+	}
+	question, err := fillPrompt(prompt.Text, req.Params)
+	if err != nil {
+		return nil, err
+	}
+	run := &PromptRun{PromptID: prompt.ID, Question: question, SessionID: req.SessionID}
+
+	files := req.FileIDs
+	if len(files) == 0 && req.SessionID != 0 {
+		loaded, err := s.sessions.Datasets(req.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		for _, fileID := range loaded {
+			if IsTabularFile(fileID) {
+				files = append(files, fileID)
+			}
+		}
+		if len(files) == 0 {
+			return nil, classErrorf(ErrValidation, "session %d has no CSV or TSV files loaded", req.SessionID)
+		}
+	}
+
+	if len(files) > 0 {
+		paths := make([]string, len(files))
+		for i, fileID := range files {
+			paths[i] = filepath.Join(uploadsDir, fileID)
+		}
+		run.Files = files
+		run.FileAnswer, err = s.ai.AnswerFileQuestion(ctx, paths, question)
+	} else {
+		run.DatasourceID = req.DatasourceID
+		if run.DatasourceID == "" {
+			run.DatasourceID = userPreferences(s.db, user).DefaultDatasource
+		}
+		if run.DatasourceID == "" {
+			return nil, classErrorf(ErrValidation, "give a session_id or datasource_id, or set a default datasource in your preferences")
+		}
+		run.FileAnswer, err = s.ai.AnswerDatasourceQuestion(ctx, run.DatasourceID, question, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Saved prompt run", map[string]interface{}{
+		"prompt_id":     prompt.ID,
+		"user":          user,
+		"session_id":    req.SessionID,
+		"datasource_id": run.DatasourceID,
+		"files":         len(run.Files),
+	})
+	return run, nil
+}
+
+// checkPrompt validates a prompt before it is saved; names are unique per owner
+func (s *PromptService) checkPrompt(prompt *store.SavedPrompt) error {
+	if prompt.Name == "" || prompt.Text == "" {
+		return classErrorf(ErrValidation, "name and text are required")
+	}
+	var count int64
+	err := s.db.Model(&store.SavedPrompt{}).
+		Where("owner = ? AND name = ? AND id <> ?", prompt.Owner, prompt.Name, prompt.ID).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check prompt name: %w", err)
+	}
+	if count > 0 {
+		return classErrorf(ErrConflict, "you already have a prompt named %q", prompt.Name)
+	}
+	return nil
+}
+
+// ownedPrompt loads a prompt for a change by its owner
+func (s *PromptService) ownedPrompt(id uint, user string) (*store.SavedPrompt, error) {
+	prompt, err := s.GetPrompt(id, user)
+	if err != nil {
+		return nil, err
+	}
+	if prompt.Owner != user {
+		return nil, classErrorf(ErrValidation, "only %s can change this shared prompt", prompt.Owner)
+	}
+	return prompt, nil
+}
+
+// fillPrompt replaces a prompt's {{placeholders}} with params; every
+// placeholder needs a value
+func fillPrompt(text string, params map[string]string) (string, error) {
+	var missing []string
+	for _, name := range extractSQLPlaceholders(text) {
+		if strings.TrimSpace(params[name]) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", classErrorf(ErrValidation, "missing params: %s", strings.Join(missing, ", "))
+	}
+	return placeholderRe.ReplaceAllStringFunc(text, func(match string) string {
+		return strings.TrimSpace(params[placeholderRe.FindStringSubmatch(match)[1]])
+	}), nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// ErrSchemaNoteNotFound is returned for an unknown schema note
+var ErrSchemaNoteNotFound = classErrorf(ErrNotFound, "schema note not found")
+
+// CurateSchemaNote edits, annotates, pins or hides a schema note and records
+// the change with its author. Curation belongs to the note's object, so it is
+// kept when the datasource is learned again.
+func (s *DatasourceService) CurateSchemaNote(datasourceID string, noteID uint, author string, req store.CurateSchemaNoteRequest) (*store.SchemaNote, error) {
+	var note store.SchemaNote
+	if err := s.db.Where("datasource_id = ?", datasourceID).First(&note, noteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSchemaNoteNotFound
+		}
+		return nil, fmt.Errorf("failed to load schema note: %w", err)
+	}
+
+	var changes []string
+	if req.MD != nil && strings.TrimSpace(*req.MD) != note.CuratedMD {
+		note.CuratedMD = strings.TrimSpace(*req.MD)
+		changes = append(changes, "md")
+	}
+	if req.Annotation != nil && strings.TrimSpace(*req.Annotation) != note.Annotation {
+		note.Annotation = strings.TrimSpace(*req.Annotation)
+		changes = append(changes, "annotation")
+	}
+	if req.Pinned != nil && *req.Pinned != note.Pinned {
+		note.Pinned = *req.Pinned
+		changes = append(changes, "pinned")
+	}
+	if req.Hidden != nil && *req.Hidden != note.Hidden {
+		note.Hidden = *req.Hidden
+		changes = append(changes, "hidden")
+	}
+	if len(changes) == 0 {
+		return &note, nil
+	}
+
+	now := time.Now()
+	note.CuratedBy = author
+	note.CuratedAt = &now
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&note).Select("curated_md", "annotation", "pinned", "hidden", "curated_by", "curated_at").Updates(&note).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&store.SchemaNoteRevision{
+			NoteID:       note.ID,
+			DatasourceID: note.DatasourceID,
+			Object:       note.Object,
+			Changes:      strings.Join(changes, ","),
+			Author:       author,
+			CuratedMD:    note.CuratedMD,
+			Annotation:   note.Annotation,
+			Pinned:       note.Pinned,
+			Hidden:       note.Hidden,
+			CreatedAt:    now,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to curate schema note: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Schema note curated", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"object":        note.Object,
+		"changes":       changes,
+		"author":        author,
+	})
+	return &note, nil
+}
+
+// SchemaNoteHistory lists the curation changes of a note's object, newest
+// first, including those made to its notes from earlier learns
+func (s *DatasourceService) SchemaNoteHistory(datasourceID string, noteID uint) ([]store.SchemaNoteRevision, error) {
+	var note store.SchemaNote
+	if err := s.db.Where("datasource_id = ?", datasourceID).First(&note, noteID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSchemaNoteNotFound
+		}
+		return nil, fmt.Errorf("failed to load schema note: %w", err)
+	}
+
+	var revisions []store.SchemaNoteRevision
+	if err := s.db.Where("datasource_id = ? AND object = ?", datasourceID, note.Object).
+		Order("id DESC").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load schema note history: %w", err)
+	}
+	return revisions, nil
+}
+
+// carryCuration copies each object's latest curation onto freshly learned
+// notes, so relearning never undoes a user's edits
+func carryCuration(db *gorm.DB, datasourceID string, notes []store.SchemaNote) {
+	var curated []store.SchemaNote
+	if err := db.Where("datasource_id = ? AND curated_at IS NOT NULL", datasourceID).Order("id ASC").Find(&curated).Error; err != nil {
+		logger.LogWarn(logger.ServiceDB, "Failed to load schema note curation", map[string]interface{}{
+			"datasource_id": datasourceID,
+			"error":         err.Error(),
+		})
+		return
+	}
+
+	latest := make(map[string]store.SchemaNote)
+	for _, note := range curated {
+		latest[note.Object] = note
+	}
+	for i := range notes {
+		if prev, ok := latest[notes[i].Object]; ok {
+			notes[i].CuratedMD = prev.CuratedMD
+			notes[i].Annotation = prev.Annotation
+			notes[i].Pinned = prev.Pinned
+			notes[i].Hidden = prev.Hidden
+			notes[i].CuratedBy = prev.CuratedBy
+			notes[i].CuratedAt = prev.CuratedAt
+		}
+	}
+}
+
+// promptSchemaNotes picks the notes to show a model: the newest note of each
+// object, without hidden ones, pinned first, then curated, then learned
+func promptSchemaNotes(notes []store.SchemaNote) []store.SchemaNote {
+	latest := make(map[string]store.SchemaNote)
+	for _, note := range notes {
+		if prev, ok := latest[note.Object]; !ok || note.ID > prev.ID {
+			latest[note.Object] = note
+		}
+	}
+
+	result := make([]store.SchemaNote, 0, len(latest))
+	for _, note := range latest {
+		if !note.Hidden {
+			result = append(result, note)
+		}
+	}
+	rank := func(note store.SchemaNote) int {
+		switch {
+		case note.Pinned:
+			return 0
+		case note.CuratedAt != nil:
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if ri, rj := rank(result[i]), rank(result[j]); ri != rj {
+			return ri < rj
+		}
+		return result[i].Object < result[j].Object
+	})
+	return result
+}
+
+// schemaNoteText is a note as a model sees it: the curated text when there
+// is one, and the annotation after it
+func schemaNoteText(note store.SchemaNote) string {
+	text := note.MD
+	if note.CuratedMD != "" {
+		text = note.CuratedMD
+	}
+	if note.Annotation != "" {
+		text += "\n\nNote: " + note.Annotation
+	}
+	return text
+}
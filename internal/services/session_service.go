@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// uploadsDir is where uploaded files are stored and datasets are loaded from
+const uploadsDir = "uploads"
+
+// ErrSessionNotFound is returned for an unknown session
+var ErrSessionNotFound = classErrorf(ErrNotFound, "session not found")
+
+// SessionService manages the state kept on a session record across
+// connections, such as the dataset chat questions are asked about
+type SessionService struct {
+	db *gorm.DB
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(db *gorm.DB) *SessionService {
+	return &SessionService{db: db}
+}
+
+// ActiveDataset returns the uploaded file a session is working with, or ""
+// when none is selected
+func (s *SessionService) ActiveDataset(sessionID uint) (string, error) {
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return session.ActiveDataset, nil
+}
+
+// SetActiveDataset selects an uploaded file as the session's dataset and
+// loads it into the session's workspace. An empty file ID clears the
+// selection and leaves the workspace as it is.
+func (s *SessionService) SetActiveDataset(sessionID uint, fileID string) (*store.Session, error) {
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileID != "" {
+		if err := checkUploadedFile(fileID); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(session).Update("active_dataset", fileID).Error; err != nil {
+			return err
+		}
+		if fileID == "" {
+			return nil
+		}
+		return addSessionDataset(tx, sessionID, fileID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Session dataset selected", map[string]interface{}{
+		"session_id": sessionID,
+		"file_id":    fileID,
+	})
+	return session, nil
+}
+
+// Datasets lists the files loaded into a session's workspace in the order
+// they were added
+func (s *SessionService) Datasets(sessionID uint) ([]string, error) {
+	if _, err := s.getSession(sessionID); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err := s.db.Model(&store.SessionDataset{}).
+		Where("session_id = ?", sessionID).
+		Order("created_at, id").
+		Pluck("file_id", &files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session datasets: %w", err)
+	}
+	return nonNilStrings(files), nil
+}
+
+// AddDataset loads another uploaded file into a session's workspace without
+// changing the active dataset. Adding a file twice is a no-op.
+func (s *SessionService) AddDataset(sessionID uint, fileID string) ([]string, error) {
+	if _, err := s.getSession(sessionID); err != nil {
+		return nil, err
+	}
+	if err := checkUploadedFile(fileID); err != nil {
+		return nil, err
+	}
+	if err := addSessionDataset(s.db, sessionID, fileID); err != nil {
+		return nil, fmt.Errorf("failed to add session dataset: %w", err)
+	}
+	return s.Datasets(sessionID)
+}
+
+// RemoveDataset unloads a file from a session's workspace, clearing the
+// active dataset when it was that file
+func (s *SessionService) RemoveDataset(sessionID uint, fileID string) ([]string, error) {
+	session, err := s.getSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("session_id = ? AND file_id = ?", sessionID, fileID).Delete(&store.SessionDataset{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return classErrorf(ErrNotFound, "file is not loaded in the session: %s", fileID)
+		}
+		if session.ActiveDataset == fileID {
+			return tx.Model(session).Update("active_dataset", "").Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Datasets(sessionID)
+}
+
+// getSession loads a session by ID
+func (s *SessionService) getSession(sessionID uint) (*store.Session, error) {
+	var session store.Session
+	if err := s.db.First(&session, sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	return &session, nil
+}
+
+// addSessionDataset records a workspace file unless it is already there
+func addSessionDataset(tx *gorm.DB, sessionID uint, fileID string) error {
+	var count int64
+	if err := tx.Model(&store.SessionDataset{}).Where("session_id = ? AND file_id = ?", sessionID, fileID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return tx.Create(&store.SessionDataset{SessionID: sessionID, FileID: fileID, CreatedAt: time.Now()}).Error
+}
+
+// checkUploadedFile rejects file IDs that are not a file in the uploads directory
+func checkUploadedFile(fileID string) error {
+	if fileID == "" || fileID != filepath.Base(fileID) || fileID == "." || fileID == ".." {
+		return classErrorf(ErrValidation, "invalid file ID: %s", fileID)
+	}
+	if _, err := os.Stat(filepath.Join(uploadsDir, fileID)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return classErrorf(ErrNotFound, "file not found: %s", fileID)
+		}
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	return nil
+}
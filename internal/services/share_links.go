@@ -0,0 +1,455 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// Access log page sizes
+const (
+	defaultShareAccesses = 100
+	maxShareAccesses     = 1000
+)
+
+// ErrShareNotFound is returned for a share token that is malformed, badly
+// signed, expired or revoked; callers are not told which
+var ErrShareNotFound = classErrorf(ErrNotFound, "share link not found")
+
+// Share access outcomes
+const (
+	shareOK      = "ok"
+	shareExpired = "expired"
+	shareRevoked = "revoked"
+	shareError   = "error"
+)
+
+// ShareService issues share links, which give read-only access to a run, or
+// to a live report with frozen params, without an account. Tokens are signed
+// and carry their expiry, so forged and expired ones are refused before the
+// database is read; revocation is checked against the stored link.
+type ShareService struct {
+	db      *gorm.DB
+	reports *ReportsService
+	config  config.SharingConfig
+	secret  []byte
+}
+
+// ShareRequest describes a request made with a share token, for the access log
+type ShareRequest struct {
+	Endpoint   string
+	RemoteAddr string
+	UserAgent  string
+}
+
+// CreatedShareLink is a new share link with its token, which is only
+// returned once
+type CreatedShareLink struct {
+	*store.ShareLink
+	Token string `json:"token"`
+	Path  string `json:"path"` // public path of the link, relative to the API root
+}
+
+// SharedLink is what a share token shows about itself
+type SharedLink struct {
+	ReportKey   string                 `json:"report_key"`
+	ReportTitle string                 `json:"report_title"`
+	RunID       *uint                  `json:"run_id,omitempty"`
+	Live        bool                   `json:"live"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+	ExpiresAt   time.Time              `json:"expires_at"`
+}
+
+// SharedData is the results a share token reads. The SQL is not shared.
+type SharedData struct {
+	ReportKey   string               `json:"report_key"`
+	ReportTitle string               `json:"report_title"`
+	RunID       uint                 `json:"run_id"`
+	Status      string               `json:"status"`
+	RowCount    int                  `json:"row_count"`
+	Truncated   bool                 `json:"truncated"`
+	Columns     []store.ResultColumn `json:"columns"`
+	Rows        [][]interface{}      `json:"rows"`
+	ExecutedAt  time.Time            `json:"executed_at"`
+	CompletedAt *time.Time           `json:"completed_at"`
+	ExpiresAt   time.Time            `json:"expires_at"`
+}
+
+// shareClaims is the signed payload of a share token
+type shareClaims struct {
+	ID  uint  `json:"id"`
+	Exp int64 `json:"exp"`
+}
+
+// NewShareService creates a new share service. Tokens are signed with
+// sharing.secret, else the JWT secret; without either a random key is used
+// and links stop working when the server restarts.
+func NewShareService(db *gorm.DB, reports *ReportsService, cfg *config.Config) *ShareService {
+	secret := []byte(cfg.Sharing.Secret)
+	if len(secret) == 0 {
+		secret = []byte(cfg.Server.Auth.JWTSecret)
+	}
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("failed to generate share link secret: %v", err))
+		}
+		logger.LogWarn(logger.ServiceREST, "No sharing.secret or jwt_secret set; share links end when the server restarts", nil)
+	}
+	return &ShareService{db: db, reports: reports, config: cfg.Sharing, secret: secret}
+}
+
+// CreateShareLink shares a run of a report, or with no run the live report
+// run with req.Params
+func (s *ShareService) CreateShareLink(reportID uint, user string, req store.CreateShareLinkRequest) (*CreatedShareLink, error) {
+	if _, err := s.reports.GetReportByID(reportID); err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found: %w", err)
+	}
+
+	ttl := s.config.DefaultTTL
+	if req.ExpiresIn != "" {
+		parsed, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil {
+			return nil, classErrorf(ErrValidation, "invalid expires_in: %w", err)
+		}
+		ttl = parsed
+	}
+	if ttl <= 0 {
+		return nil, classErrorf(ErrValidation, "expires_in must be positive")
+	}
+	if s.config.MaxTTL > 0 && ttl > s.config.MaxTTL {
+		return nil, classErrorf(ErrValidation, "expires_in may be at most %s", s.config.MaxTTL)
+	}
+
+	link := &store.ShareLink{
+		ReportID:  reportID,
+		CreatedBy: user,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	}
+	if req.RunID != nil {
+		if len(req.Params) > 0 || req.DatasourceID != "" {
+			return nil, classErrorf(ErrValidation, "params and datasource_id are for live links; a run keeps its own")
+		}
+		var run store.ReportRun
+		if err := s.db.Where("id = ? AND report_id = ?", *req.RunID, reportID).First(&run).Error; err != nil {
+			return nil, classErrorf(ErrNotFound, "run %d of report %d not found", *req.RunID, reportID)
+		}
+		if run.Status != "completed" {
+			return nil, classErrorf(ErrValidation, "only completed runs can be shared; run %d is %s", run.ID, run.Status)
+		}
+		link.RunID = &run.ID
+	} else {
+		params, err := json.Marshal(req.Params)
+		if err != nil {
+			return nil, classErrorf(ErrValidation, "invalid params: %w", err)
+		}
+		link.ParamsJSON = string(params)
+		link.Params = req.Params
+		link.DatasourceID = req.DatasourceID
+	}
+
+	if err := s.db.Create(link).Error; err != nil {
+		return nil, fmt.Errorf("failed to save share link: %w", err)
+	}
+	token := s.sign(shareClaims{ID: link.ID, Exp: link.ExpiresAt.Unix()})
+
+	logger.LogInfo(logger.ServiceREST, "Share link created", map[string]interface{}{
+		"share_id":   link.ID,
+		"report_id":  reportID,
+		"run_id":     link.RunID,
+		"user":       user,
+		"expires_at": link.ExpiresAt,
+	})
+	return &CreatedShareLink{ShareLink: link, Token: token, Path: "/v1/shared/" + token}, nil
+}
+
+// ListShareLinks returns a report's share links, newest first
+func (s *ShareService) ListShareLinks(reportID uint) ([]store.ShareLink, error) {
+	var links []store.ShareLink
+	if err := s.db.Where("report_id = ?", reportID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to list share links: %w", err)
+	}
+	for i := range links {
+		links[i].Params = decodeShareParams(links[i].ParamsJSON)
+	}
+	return links, nil
+}
+
+// RevokeShareLink ends a share link before it expires
+func (s *ShareService) RevokeShareLink(reportID, id uint, user string) (*store.ShareLink, error) {
+	link, err := s.reportLink(reportID, id)
+	if err != nil {
+		return nil, err
+	}
+	if link.RevokedAt != nil {
+		return link, nil
+	}
+	now := time.Now()
+	link.RevokedAt = &now
+	link.RevokedBy = user
+	if err := s.db.Model(link).Select("revoked_at", "revoked_by").Updates(link).Error; err != nil {
+		return nil, fmt.Errorf("failed to revoke share link: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Share link revoked", map[string]interface{}{
+		"share_id":  link.ID,
+		"report_id": reportID,
+		"user":      user,
+	})
+	return link, nil
+}
+
+// ListShareAccesses returns the newest entries of a share link's access log
+func (s *ShareService) ListShareAccesses(reportID, id uint, limit int) ([]store.ShareAccess, error) {
+	if _, err := s.reportLink(reportID, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultShareAccesses
+	}
+	limit = min(limit, maxShareAccesses)
+	var accesses []store.ShareAccess
+	if err := s.db.Where("share_link_id = ?", id).Order("accessed_at DESC").Limit(limit).Find(&accesses).Error; err != nil {
+		return nil, fmt.Errorf("failed to list share accesses: %w", err)
+	}
+	return accesses, nil
+}
+
+// SharedLink describes what a share token gives access to
+func (s *ShareService) SharedLink(token string, req ShareRequest) (*SharedLink, error) {
+	link, report, err := s.open(token, req)
+	if err != nil {
+		return nil, err
+	}
+	s.logAccess(link, req, shareOK)
+	return &SharedLink{
+		ReportKey:   report.Key,
+		ReportTitle: report.Title,
+		RunID:       link.RunID,
+		Live:        link.RunID == nil,
+		Params:      decodeShareParams(link.ParamsJSON),
+		ExpiresAt:   link.ExpiresAt,
+	}, nil
+}
+
+// SharedData returns the results a share token gives access to
+func (s *ShareService) SharedData(token string, req ShareRequest) (*SharedData, error) {
+	link, report, err := s.open(token, req)
+	if err != nil {
+		return nil, err
+	}
+	run, err := s.sharedRun(link, report)
+	if err != nil {
+		s.logAccess(link, req, shareError)
+		return nil, err
+	}
+	results, err := ParseRunResults(run.Results)
+	if err != nil {
+		s.logAccess(link, req, shareError)
+		return nil, err
+	}
+	s.logAccess(link, req, shareOK)
+
+	return &SharedData{
+		ReportKey:   report.Key,
+		ReportTitle: report.Title,
+		RunID:       run.ID,
+		Status:      run.Status,
+		RowCount:    run.RowCount,
+		Truncated:   run.Truncated,
+		Columns:     results.Columns,
+		Rows:        results.Rows,
+		ExecutedAt:  run.StartedAt,
+		CompletedAt: run.FinishedAt,
+		ExpiresAt:   link.ExpiresAt,
+	}, nil
+}
+
+// SharedSummary renders the run summary a share token gives access to
+func (s *ShareService) SharedSummary(token, format string, req ShareRequest) (string, error) {
+	if err := checkSummaryFormat(format); err != nil {
+		return "", err
+	}
+	link, report, err := s.open(token, req)
+	if err != nil {
+		return "", err
+	}
+	run, err := s.sharedRun(link, report)
+	if err != nil {
+		s.logAccess(link, req, shareError)
+		return "", err
+	}
+	summary, err := s.reports.RenderRunSummary(run.ID, format)
+	if err != nil {
+		s.logAccess(link, req, shareError)
+		return "", err
+	}
+	s.logAccess(link, req, shareOK)
+	return summary, nil
+}
+
+// open verifies a share token and loads its link and report. Tokens naming a
+// link are logged even when refused, so revoked links show their attempts.
+func (s *ShareService) open(token string, req ShareRequest) (*store.ShareLink, *store.Report, error) {
+	claims, ok := s.verify(token)
+	if !ok {
+		return nil, nil, ErrShareNotFound
+	}
+	var link store.ShareLink
+	if err := s.db.First(&link, claims.ID).Error; err != nil {
+		return nil, nil, ErrShareNotFound
+	}
+	switch {
+	case link.RevokedAt != nil:
+		s.logAccess(&link, req, shareRevoked)
+		return nil, nil, ErrShareNotFound
+	case time.Now().After(link.ExpiresAt) || time.Now().Unix() > claims.Exp:
+		s.logAccess(&link, req, shareExpired)
+		return nil, nil, ErrShareNotFound
+	}
+	report, err := s.reports.GetReportByID(link.ReportID)
+	if err != nil {
+		return nil, nil, ErrShareNotFound
+	}
+	return &link, report, nil
+}
+
+// sharedRun returns the run a link shows: its own run, or for a live link
+// its last run while that is younger than sharing.live_refresh, else a new
+// run with the frozen params
+func (s *ShareService) sharedRun(link *store.ShareLink, report *store.Report) (*store.ReportRun, error) {
+	runID := link.RunID
+	if runID == nil && link.LastRunID != nil {
+		var last store.ReportRun
+		err := s.db.First(&last, *link.LastRunID).Error
+		if err == nil && last.Status == "completed" && last.FinishedAt != nil && time.Since(*last.FinishedAt) < s.config.LiveRefresh {
+			runID = &last.ID
+		}
+	}
+	if runID != nil {
+		var run store.ReportRun
+		if err := s.db.First(&run, *runID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, classErrorf(ErrNotFound, "the shared run no longer exists")
+			}
+			return nil, fmt.Errorf("failed to load shared run: %w", err)
+		}
+		return &run, nil
+	}
+
+	req := store.RunReportRequest{
+		Params: decodeShareParams(link.ParamsJSON),
+		User:   fmt.Sprintf("share:%d", link.ID),
+	}
+	if req.Params == nil {
+		req.Params = map[string]interface{}{}
+	}
+	if link.DatasourceID != "" {
+		req.DatasourceID = &link.DatasourceID
+	}
+	run, err := s.reports.RunReport(report.Key, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(link).Update("last_run_id", run.ID).Error; err != nil {
+		logger.LogWarn(logger.ServiceREST, "Failed to record share link run", map[string]interface{}{
+			"share_id": link.ID,
+			"run_id":   run.ID,
+			"error":    err.Error(),
+		})
+	}
+	return run, nil
+}
+
+// logAccess records a request made with a share link. Failures are logged,
+// never returned, so a full log table doesn't block reads.
+func (s *ShareService) logAccess(link *store.ShareLink, req ShareRequest, outcome string) {
+	now := time.Now()
+	access := &store.ShareAccess{
+		ShareLinkID: link.ID,
+		Endpoint:    req.Endpoint,
+		Outcome:     outcome,
+		RemoteAddr:  req.RemoteAddr,
+		UserAgent:   req.UserAgent,
+		AccessedAt:  now,
+	}
+	err := s.db.Create(access).Error
+	if err == nil && outcome == shareOK {
+		err = s.db.Model(link).Updates(map[string]interface{}{
+			"access_count":     gorm.Expr("access_count + 1"),
+			"last_accessed_at": now,
+		}).Error
+	}
+	if err != nil {
+		logger.LogWarn(logger.ServiceREST, "Failed to log share access", map[string]interface{}{
+			"share_id": link.ID,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// reportLink loads a share link of a report
+func (s *ShareService) reportLink(reportID, id uint) (*store.ShareLink, error) {
+	var link store.ShareLink
+	err := s.db.Where("id = ? AND report_id = ?", id, reportID).First(&link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load share link: %w", err)
+	}
+	link.Params = decodeShareParams(link.ParamsJSON)
+	return &link, nil
+}
+
+// sign encodes claims as <payload>.<signature>, both base64url
+func (s *ShareService) sign(claims shareClaims) string {
+	payload, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded))
+}
+
+// verify checks a token's signature and returns its claims
+func (s *ShareService) verify(token string) (shareClaims, bool) {
+	var claims shareClaims
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.mac(encoded)) {
+		return claims, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(payload, &claims) != nil || claims.ID == 0 {
+		return claims, false
+	}
+	return claims, true
+}
+
+// mac is the HMAC-SHA256 of a token payload
+func (s *ShareService) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// decodeShareParams reads a live link's frozen params
+func decodeShareParams(paramsJSON string) map[string]interface{} {
+	var params map[string]interface{}
+	if paramsJSON != "" {
+		_ = json.Unmarshal([]byte(paramsJSON), &params)
+	}
+	return params
+}
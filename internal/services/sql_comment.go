@@ -0,0 +1,42 @@
+package services
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// queryComment is the metadata prepended to a statement AIR executes, so
+// database audit logs, pg_stat_activity and pg_stat_statements can attribute
+// load to a report, its version, the user and the run. Empty fields are left
+// out.
+type queryComment struct {
+	Run     string // run trace ID
+	Report  string // report key
+	Version int
+	User    string
+	Job     string // what AIR issued the query for when it is not a report, e.g. "pii_scan"
+}
+
+// apply returns sqlText prefixed with the comment, e.g.
+// /* air_run:3f2a air_report:sales air_version:2 air_user:alice */ SELECT ...
+// Values are URL-escaped, so no value can end the comment early.
+func (c queryComment) apply(sqlText string) string {
+	var fields []string
+	add := func(key, value string) {
+		if value != "" {
+			fields = append(fields, "air_"+key+":"+url.QueryEscape(value))
+		}
+	}
+	add("run", c.Run)
+	add("report", c.Report)
+	if c.Version > 0 {
+		add("version", strconv.Itoa(c.Version))
+	}
+	add("user", c.User)
+	add("job", c.Job)
+	if len(fields) == 0 {
+		return sqlText
+	}
+	return "/* " + strings.Join(fields, " ") + " */ " + sqlText
+}
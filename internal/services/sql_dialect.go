@@ -0,0 +1,309 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SQL dialect handling for model-generated SQL. SQLCoder tends to answer in
+// PostgreSQL regardless of the prompt, so output is cleaned up and the most
+// common cross-dialect differences are rewritten for the target datasource.
+
+var (
+	codeFenceRe     = regexp.MustCompile("(?s)```(?:sql|SQL)?\\s*(.*?)```")
+	sqlStartRe      = regexp.MustCompile(`(?i)^\s*(SELECT|WITH)\b`)
+	proseLabelRe    = regexp.MustCompile(`(?i)^[ \t]*(explanation|note|notes)[ \t]*:`)
+	proseTokenRe    = regexp.MustCompile(`[A-Za-z_][\w$.]*|\S`)
+	limitRe         = regexp.MustCompile(`(?i)\s+LIMIT\s+(\d+)\s*$`)
+	topRe           = regexp.MustCompile(`(?i)^(\s*SELECT\s+(?:DISTINCT\s+)?)TOP\s*\(?\s*(\d+)\s*\)?\s+`)
+	selectHeadRe    = regexp.MustCompile(`(?i)^(\s*SELECT\s+(?:DISTINCT\s+)?)`)
+	dateTruncRe     = regexp.MustCompile(`(?i)DATE_TRUNC\(\s*'(\w+)'\s*,\s*([^()]+?)\s*\)`)
+	nowIntervalRe   = regexp.MustCompile(`(?i)(NOW\(\)|CURRENT_TIMESTAMP)\s*([-+])\s*INTERVAL\s*'(\d+)\s*(\w+?)s?'`)
+	pgIntervalRe    = regexp.MustCompile(`(?i)INTERVAL\s*'(\d+)\s*(\w+?)s?'`)
+	mysqlIntervalRe = regexp.MustCompile(`(?i)INTERVAL\s+(\d+)\s+(\w+)\b`)
+	nowRe           = regexp.MustCompile(`(?i)\bNOW\(\)|\bGETDATE\(\)`)
+	pgCastRe        = regexp.MustCompile(`([\w."]+|'[^']*')::(\w+(?:\s*\(\s*\d+(?:\s*,\s*\d+)?\s*\))?)`)
+	ilikeRe         = regexp.MustCompile(`(?i)([\w."]+)\s+ILIKE\s+('(?:[^']|'')*')`)
+	getDateRe       = regexp.MustCompile(`(?i)\bGETDATE\(\)`)
+	currentTsRe     = regexp.MustCompile(`(?i)\bCURRENT_TIMESTAMP\b`)
+)
+
+// sqlDialectName returns the prompt label for a datasource kind
+func sqlDialectName(kind string) string {
+	switch strings.ToLower(kind) {
+	case "postgres", "postgresql":
+		return "PostgreSQL"
+	case "timescaledb":
+		return "PostgreSQL (TimescaleDB)"
+	case "mysql":
+		return "MySQL"
+	case "sqlite", "sqlite3":
+		return "SQLite"
+	case "sqlserver", "mssql":
+		return "SQL Server"
+	case "trino":
+		return "Trino"
+	case "bigquery":
+		return "BigQuery (GoogleSQL)"
+	default:
+		return "PostgreSQL"
+	}
+}
+
+// cleanGeneratedSQL extracts a single SQL statement from raw model output.
+// When the prompt ends with "SELECT" (continuesSelect), completions that
+// continue from it get the keyword restored. Output that is not a SELECT or
+// WITH statement, such as a refusal, is an error rather than being passed on
+// as SQL.
+func cleanGeneratedSQL(raw string, continuesSelect bool) (string, error) {
+	sql := strings.TrimSpace(raw)
+
+	// Prefer the contents of a fenced block when the model wrapped its answer
+	if match := codeFenceRe.FindStringSubmatch(sql); match != nil {
+		sql = strings.TrimSpace(match[1])
+	}
+	sql = strings.Trim(sql, "`")
+
+	// Drop prose the model appended after the statement
+	sql = strings.TrimSpace(sql[:generatedStatementEnd(sql)])
+	if sql == "" {
+		return "", nil
+	}
+
+	if !sqlStartRe.MatchString(sql) {
+		if !continuesSelect {
+			return "", fmt.Errorf("generated output is not a SELECT statement: %s", truncateForError(sql))
+		}
+		sql = "SELECT " + sql
+	}
+	if looksLikeProse(sql) {
+		return "", fmt.Errorf("generated output is not SQL: %s", truncateForError(sql))
+	}
+	return sql, nil
+}
+
+// generatedStatementEnd returns where the first statement in model output
+// ends: at a semicolon, a line starting "Explanation:" or "Note:", or a blank
+// line followed by prose, outside parentheses, quoted strings, identifiers
+// and comments
+func generatedStatementEnd(sql string) int {
+	lineStart, depth := true, 0
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		if lineStart {
+			if proseLabelRe.MatchString(sql[i:]) {
+				return i
+			}
+			if rest := strings.TrimLeft(sql[i:], " \t\r"); strings.HasPrefix(rest, "\n") && depth == 0 && proseFollows(sql[:i], rest) {
+				return i
+			}
+			lineStart = false
+		}
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			if nl := strings.IndexByte(sql[i:], '\n'); nl >= 0 {
+				i += nl
+			} else {
+				i = len(sql)
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			if close := strings.Index(sql[i+2:], "*/"); close >= 0 {
+				i += close + 4
+			} else {
+				i = len(sql)
+			}
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(sql) {
+				if sql[j] == c {
+					if j+1 < len(sql) && sql[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			i = min(j+1, len(sql))
+		case c == ';':
+			return i
+		case c == '(' || c == ')':
+			if c == '(' {
+				depth++
+			} else {
+				depth--
+			}
+			i++
+		case c == '\n':
+			i++
+			lineStart = true
+		default:
+			i++
+		}
+	}
+	return len(sql)
+}
+
+// proseFollows reports whether the text after a blank line starts a sentence
+// rather than continuing the statement before it
+func proseFollows(before, after string) bool {
+	if strings.HasSuffix(strings.TrimSpace(before), ",") {
+		return false
+	}
+	token := proseTokenRe.FindString(after)
+	return token != "" && isIdentStart(token[0]) && !lineageKeywords[strings.ToUpper(token)]
+}
+
+// looksLikeProse reports whether a statement reads as a sentence: three or
+// more bare words in a row that are not SQL keywords, as in "SELECT I cannot
+// answer that". SQL puts at most a name and its alias side by side.
+func looksLikeProse(sql string) bool {
+	run := 0
+	for _, token := range proseTokenRe.FindAllString(stripSQLLiterals(sql), -1) {
+		if !isIdentStart(token[0]) || lineageKeywords[strings.ToUpper(token)] {
+			run = 0
+			continue
+		}
+		if run++; run >= 3 {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateForError shortens model output quoted in an error
+func truncateForError(text string) string {
+	const limit = 120
+	if len(text) <= limit {
+		return text
+	}
+	return text[:limit] + "..."
+}
+
+// transpileSQL rewrites common PostgreSQL-isms (and TOP/LIMIT) for the target dialect
+func transpileSQL(sql, kind string) string {
+	switch strings.ToLower(kind) {
+	case "mysql":
+		sql = topToLimit(sql)
+		sql = dateTruncRe.ReplaceAllStringFunc(sql, func(m string) string {
+			parts := dateTruncRe.FindStringSubmatch(m)
+			if format, ok := mysqlTruncFormats[strings.ToLower(parts[1])]; ok {
+				return fmt.Sprintf("DATE_FORMAT(%s, '%s')", parts[2], format)
+			}
+			return m
+		})
+		sql = pgIntervalRe.ReplaceAllStringFunc(sql, func(m string) string {
+			parts := pgIntervalRe.FindStringSubmatch(m)
+			return fmt.Sprintf("INTERVAL %s %s", parts[1], strings.ToUpper(parts[2]))
+		})
+		sql = nowRe.ReplaceAllString(sql, "NOW()")
+	case "sqlite", "sqlite3":
+		sql = topToLimit(sql)
+		sql = dateTruncRe.ReplaceAllStringFunc(sql, func(m string) string {
+			parts := dateTruncRe.FindStringSubmatch(m)
+			if format, ok := sqliteTruncFormats[strings.ToLower(parts[1])]; ok {
+				return fmt.Sprintf("strftime('%s', %s)", format, parts[2])
+			}
+			return m
+		})
+		sql = nowIntervalRe.ReplaceAllString(sql, "datetime('now', '${2}$3 $4')")
+		sql = nowRe.ReplaceAllString(sql, "datetime('now')")
+		sql = currentTsRe.ReplaceAllString(sql, "datetime('now')")
+	case "trino":
+		sql = topToLimit(sql)
+		sql = pgCastRe.ReplaceAllString(sql, "CAST($1 AS $2)")
+		sql = ilikeRe.ReplaceAllString(sql, "lower($1) LIKE lower($2)")
+		sql = pgIntervalRe.ReplaceAllStringFunc(sql, func(m string) string {
+			parts := pgIntervalRe.FindStringSubmatch(m)
+			return trinoInterval(parts[1], parts[2])
+		})
+		sql = mysqlIntervalRe.ReplaceAllStringFunc(sql, func(m string) string {
+			parts := mysqlIntervalRe.FindStringSubmatch(m)
+			return trinoInterval(parts[1], parts[2])
+		})
+		sql = getDateRe.ReplaceAllString(sql, "now()")
+	case "bigquery":
+		sql = topToLimit(sql)
+		sql = pgCastRe.ReplaceAllString(sql, "CAST($1 AS $2)")
+		sql = ilikeRe.ReplaceAllString(sql, "lower($1) LIKE lower($2)")
+		sql = nowRe.ReplaceAllString(sql, "CURRENT_TIMESTAMP()")
+	case "sqlserver", "mssql":
+		sql = limitToTop(sql)
+		sql = nowRe.ReplaceAllString(sql, "GETDATE()")
+	case "timescaledb":
+		sql = transpileSQL(sql, "postgres")
+		// time_bucket plans better than date_trunc on hypertables
+		sql = dateTruncRe.ReplaceAllStringFunc(sql, func(m string) string {
+			parts := dateTruncRe.FindStringSubmatch(m)
+			switch unit := strings.ToLower(parts[1]); unit {
+			case "minute", "hour", "day", "week":
+				return fmt.Sprintf("time_bucket('1 %s', %s)", unit, parts[2])
+			}
+			return m
+		})
+	default:
+		// PostgreSQL
+		sql = topToLimit(sql)
+		sql = mysqlIntervalRe.ReplaceAllStringFunc(sql, func(m string) string {
+			parts := mysqlIntervalRe.FindStringSubmatch(m)
+			return fmt.Sprintf("INTERVAL '%s %s'", parts[1], strings.ToLower(parts[2]))
+		})
+		sql = getDateRe.ReplaceAllString(sql, "NOW()")
+	}
+
+	return sql
+}
+
+// trinoInterval writes an interval literal the way Trino requires, with the
+// unit outside the quotes. Trino has no week unit, so weeks become days.
+func trinoInterval(n, unit string) string {
+	unit = strings.TrimSuffix(strings.ToUpper(unit), "S")
+	if unit == "WEEK" {
+		weeks, _ := strconv.Atoi(n)
+		return fmt.Sprintf("INTERVAL '%d' DAY", weeks*7)
+	}
+	return fmt.Sprintf("INTERVAL '%s' %s", n, unit)
+}
+
+// topToLimit turns "SELECT TOP n ..." into "SELECT ... LIMIT n"
+func topToLimit(sql string) string {
+	match := topRe.FindStringSubmatch(sql)
+	if match == nil {
+		return sql
+	}
+	sql = topRe.ReplaceAllString(sql, "$1")
+	if limitRe.MatchString(sql) {
+		return sql
+	}
+	return fmt.Sprintf("%s LIMIT %s", strings.TrimSpace(sql), match[2])
+}
+
+// limitToTop turns a trailing "LIMIT n" into "SELECT TOP n ..."
+func limitToTop(sql string) string {
+	match := limitRe.FindStringSubmatch(sql)
+	if match == nil || topRe.MatchString(sql) {
+		return sql
+	}
+	sql = limitRe.ReplaceAllString(sql, "")
+	return selectHeadRe.ReplaceAllString(sql, fmt.Sprintf("${1}TOP %s ", match[1]))
+}
+
+// mysqlTruncFormats maps DATE_TRUNC units to DATE_FORMAT patterns
+var mysqlTruncFormats = map[string]string{
+	"year":   "%Y-01-01",
+	"month":  "%Y-%m-01",
+	"day":    "%Y-%m-%d",
+	"hour":   "%Y-%m-%d %H:00:00",
+	"minute": "%Y-%m-%d %H:%i:00",
+}
+
+// sqliteTruncFormats maps DATE_TRUNC units to strftime patterns
+var sqliteTruncFormats = map[string]string{
+	"year":   "%Y-01-01",
+	"month":  "%Y-%m-01",
+	"day":    "%Y-%m-%d",
+	"hour":   "%Y-%m-%d %H:00:00",
+	"minute": "%Y-%m-%d %H:%M:00",
+}
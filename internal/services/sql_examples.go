@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// maxPromptExamples caps how many few-shot examples go into a SQL prompt
+const maxPromptExamples = 3
+
+var (
+	// ErrSQLExampleNotFound is returned when deleting an unknown example
+	ErrSQLExampleNotFound = classErrorf(ErrNotFound, "SQL example not found")
+	// ErrInvalidSQLExample is returned for examples whose SQL is not read-only
+	ErrInvalidSQLExample = classErrorf(ErrValidation, "invalid SQL example")
+)
+
+// ExampleService keeps per-datasource question -> SQL examples and retrieves the
+// ones most similar to a new question. Similarity uses embeddings when
+// models.embeddings is configured and falls back to word overlap otherwise.
+// Questions of no_external_ai datasources are never sent to an external
+// embeddings provider.
+type ExampleService struct {
+	db       *gorm.DB
+	embedder llm.Embedder
+	external bool            // embedder is an external provider
+	localAI  map[string]bool // no_external_ai datasources
+}
+
+// NewExampleService creates a new example service
+func NewExampleService(db *gorm.DB, cfg *config.Config) *ExampleService {
+	embedder, err := llm.NewEmbedder(cfg)
+	if err != nil {
+		logger.LogWarn(logger.ServiceAI, "Embeddings unavailable; SQL examples use word overlap", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	localAI := make(map[string]bool)
+	for _, source := range cfg.AnalyticsSources {
+		if source.NoExternalAI {
+			localAI[source.ID] = true
+		}
+	}
+	external := !strings.EqualFold(cfg.Models.Embeddings.Provider, "ollama")
+	return &ExampleService{db: db, embedder: embedder, external: external, localAI: localAI}
+}
+
+// AddExample stores a verified example; an identical question/SQL pair already
+// stored for the datasource is returned unchanged
+func (s *ExampleService) AddExample(datasourceID, question, sqlText, source string, runID *uint) (*store.SQLExample, error) {
+	question = strings.TrimSpace(question)
+	sqlText = strings.TrimSpace(sqlText)
+	if _, err := ValidateReadOnlySQL(sqlText); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSQLExample, err)
+	}
+
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(strings.ToLower(question)+"\x00"+sqlText)))
+	var existing store.SQLExample
+	if res := s.db.Where("datasource_id = ? AND checksum = ?", datasourceID, checksum).Limit(1).Find(&existing); res.Error == nil && res.RowsAffected > 0 {
+		return &existing, nil
+	}
+
+	example := &store.SQLExample{
+		DatasourceID: datasourceID,
+		Checksum:     checksum,
+		Question:     question,
+		SQL:          sqlText,
+		Source:       source,
+		RunID:        runID,
+		CreatedAt:    time.Now(),
+	}
+	if vectors := s.embed(datasourceID, []string{question}); vectors != nil {
+		raw, _ := json.Marshal(vectors[0])
+		example.EmbeddingJSON = string(raw)
+		example.EmbeddingModel = s.embedder.Model()
+	}
+
+	if err := s.db.Create(example).Error; err != nil {
+		return nil, fmt.Errorf("failed to save SQL example: %w", err)
+	}
+	logger.LogInfo(logger.ServiceAI, "SQL example added", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"source":        source,
+		"embedded":      example.EmbeddingModel != "",
+	})
+	return example, nil
+}
+
+// ListExamples returns a datasource's examples, newest first
+func (s *ExampleService) ListExamples(datasourceID string) ([]store.SQLExample, error) {
+	var examples []store.SQLExample
+	if err := s.db.Where("datasource_id = ?", datasourceID).Order("created_at DESC").Find(&examples).Error; err != nil {
+		return nil, fmt.Errorf("failed to list SQL examples: %w", err)
+	}
+	return examples, nil
+}
+
+// DeleteExample removes one example from a datasource's library
+func (s *ExampleService) DeleteExample(datasourceID string, id uint) error {
+	res := s.db.Where("datasource_id = ? AND id = ?", datasourceID, id).Delete(&store.SQLExample{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete SQL example: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrSQLExampleNotFound
+	}
+	return nil
+}
+
+// SimilarExamples returns up to limit examples most similar to question
+func (s *ExampleService) SimilarExamples(datasourceID, question string, limit int) []store.SQLExample {
+	examples, err := s.ListExamples(datasourceID)
+	if err != nil || len(examples) == 0 {
+		return nil
+	}
+
+	var queryVec []float64
+	if vectors := s.embed(datasourceID, []string{question}); vectors != nil {
+		queryVec = vectors[0]
+	}
+	queryWords := wordSet(question)
+
+	type scored struct {
+		example store.SQLExample
+		score   float64
+	}
+	var ranked []scored
+	for _, ex := range examples {
+		score := -1.0
+		if queryVec != nil && ex.EmbeddingModel == s.embedder.Model() {
+			var vec []float64
+			if json.Unmarshal([]byte(ex.EmbeddingJSON), &vec) == nil {
+				score = cosineSimilarity(queryVec, vec)
+			}
+		}
+		if score < 0 {
+			score = jaccard(queryWords, wordSet(ex.Question))
+		}
+		if score > 0 {
+			ranked = append(ranked, scored{ex, score})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+
+	var result []store.SQLExample
+	for i := 0; i < len(ranked) && i < limit; i++ {
+		result = append(result, ranked[i].example)
+	}
+	return result
+}
+
+// embed returns nil when embeddings are unavailable, fail or would send a
+// no_external_ai datasource's text to an external provider
+func (s *ExampleService) embed(datasourceID string, texts []string) [][]float64 {
+	if s.embedder == nil || (s.external && s.localAI[datasourceID]) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		logger.LogWarn(logger.ServiceAI, "Embedding failed; using word overlap", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	return vectors
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return -1
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return -1
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// wordSet lower-cases text into its set of words, ignoring very short ones
+func wordSet(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		if len(w) > 2 {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range a {
+		if b[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/llm"
+	"github.com/NubeDev/air/internal/store"
+	"github.com/ollama/ollama/api"
+)
+
+// SQLGenerator turns a query description into SQL for a target dialect
+type SQLGenerator interface {
+	// Name identifies the backend in safety reports and logs
+	Name() string
+	GenerateSQL(ctx context.Context, req SQLGenerationRequest) (string, error)
+}
+
+// SQLGenerationRequest carries everything a backend may need to produce SQL
+type SQLGenerationRequest struct {
+	DatasourceID string                 `json:"datasource_id,omitempty"`
+	Dialect      string                 `json:"dialect"`
+	IR           map[string]interface{} `json:"ir,omitempty"`
+	Prompt       string                 `json:"prompt"`
+	Schema       string                 `json:"schema"`
+	Examples     []store.SQLExample     `json:"examples,omitempty"` // verified similar question -> SQL pairs
+	Trace        *generationTrace       `json:"-"`                  // filled by backends that call a model or service
+}
+
+// NewSQLGenerator creates the backend described by cfg. An empty type keeps the
+// models.sql_primary routing through the shared SQL client; model backends
+// take their clients from pool.
+func NewSQLGenerator(cfg *config.Config, genCfg config.SQLGeneratorConfig, pool *llm.Pool, sqlClient llm.LLMClient, compile func(map[string]interface{}, string) string) (SQLGenerator, error) {
+	switch strings.ToLower(genCfg.Type) {
+	case "":
+		return &llmSQLGenerator{
+			name:   cfg.Models.SQLPrimary,
+			client: sqlClient,
+			model:  llm.GetModelName(cfg, "sql"),
+			seed:   cfg.Models.Seed,
+		}, nil
+	case "sqlcoder":
+		client, err := pool.Client("ollama", cfg.Models.Ollama.SQLCoderModel)
+		if err != nil {
+			return nil, err
+		}
+		return &llmSQLGenerator{
+			name:   "sqlcoder",
+			client: client,
+			model:  cfg.Models.Ollama.SQLCoderModel,
+			seed:   cfg.Models.Seed,
+		}, nil
+	case "openai":
+		client, err := pool.Client("openai", cfg.Models.OpenAI.Model)
+		if err != nil {
+			return nil, err
+		}
+		return &llmSQLGenerator{
+			name:   "openai",
+			client: client,
+			model:  cfg.Models.OpenAI.Model,
+			seed:   cfg.Models.Seed,
+		}, nil
+	case "deterministic":
+		return &deterministicSQLGenerator{compile: compile}, nil
+	case "http":
+		timeout := genCfg.Timeout
+		if timeout == 0 {
+			timeout = 60 * time.Second
+		}
+		return &httpSQLGenerator{
+			url:    genCfg.URL,
+			client: &http.Client{Timeout: timeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown SQL generator type: %s", genCfg.Type)
+	}
+}
+
+// llmSQLGenerator prompts a completion model in SQLCoder format
+type llmSQLGenerator struct {
+	name   string
+	client llm.LLMClient
+	model  string
+	seed   int
+}
+
+func (g *llmSQLGenerator) Name() string {
+	return g.name
+}
+
+func (g *llmSQLGenerator) GenerateSQL(ctx context.Context, req SQLGenerationRequest) (string, error) {
+	var examples strings.Builder
+	for _, ex := range req.Examples {
+		examples.WriteString(fmt.Sprintf("-- Example question: %s\n%s;\n\n", strings.ReplaceAll(ex.Question, "\n", " "), strings.TrimSuffix(ex.SQL, ";")))
+	}
+	prompt := fmt.Sprintf(`-- Database: %s
+-- Schema:
+%s
+%s-- Task: %s
+
+SELECT`, sqlDialectName(req.Dialect), req.Schema, examples.String(), req.Prompt)
+
+	options := &api.Options{
+		Temperature: 0.1, // Lower temperature for more deterministic SQL
+		TopP:        0.9,
+		Seed:        g.seed,
+	}
+	if req.Trace != nil {
+		*req.Trace = generationTrace{CallType: "generate", Provider: llm.ProviderName(g.client), Model: g.model, Prompt: prompt, Options: options}
+	}
+
+	resp, err := g.client.GenerateText(ctx, llm.GenerateRequest{
+		Model:   g.model,
+		Prompt:  prompt,
+		Stream:  false,
+		Options: options,
+	})
+	if err != nil {
+		return "", fmt.Errorf("SQL generation failed: %w", err)
+	}
+	if req.Trace != nil {
+		req.Trace.Response = resp.Response
+	}
+
+	sql, err := cleanGeneratedSQL(resp.Response, true)
+	if sql == "" || err != nil {
+		return "", err
+	}
+
+	return transpileSQL(sql, req.Dialect), nil
+}
+
+// deterministicSQLGenerator compiles IR directly without a model
+type deterministicSQLGenerator struct {
+	compile func(ir map[string]interface{}, dialect string) string
+}
+
+func (g *deterministicSQLGenerator) Name() string {
+	return "deterministic"
+}
+
+func (g *deterministicSQLGenerator) GenerateSQL(ctx context.Context, req SQLGenerationRequest) (string, error) {
+	if len(req.IR) == 0 {
+		return "", fmt.Errorf("deterministic SQL generation requires an IR")
+	}
+	return g.compile(req.IR, req.Dialect), nil
+}
+
+// httpSQLGenerator delegates to an external service ("bring your own" backend).
+// The service receives the SQLGenerationRequest as JSON and answers {"sql": "..."}.
+type httpSQLGenerator struct {
+	url    string
+	client *http.Client
+}
+
+func (g *httpSQLGenerator) Name() string {
+	return "http"
+}
+
+func (g *httpSQLGenerator) GenerateSQL(ctx context.Context, req SQLGenerationRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode generator request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create generator request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if req.Trace != nil {
+		*req.Trace = generationTrace{CallType: "http", Provider: "http", Model: g.url, Prompt: string(body)}
+	}
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("SQL generator request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		SQL   string `json:"sql"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode generator response: %w", err)
+	}
+	if req.Trace != nil {
+		req.Trace.Response = result.SQL
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("SQL generator returned status %d: %s", resp.StatusCode, result.Error)
+	}
+
+	return cleanGeneratedSQL(result.SQL, false)
+}
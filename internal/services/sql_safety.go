@@ -0,0 +1,119 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	lineCommentRe   = regexp.MustCompile(`--[^\n]*`)
+	blockCommentRe  = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	stringLiteralRe = regexp.MustCompile(`'(?:[^']|'')*'`)
+	placeholderRe   = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+	writeKeywordRe  = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE|DROP|ALTER|CREATE|TRUNCATE|RENAME|GRANT|REVOKE|CALL|EXEC|EXECUTE|COPY|ATTACH|DETACH|PRAGMA|VACUUM|LOCK|SET)\b`)
+	selectStarRe    = regexp.MustCompile(`(?i)\bSELECT\s+(DISTINCT\s+)?\*`)
+	limitClauseRe   = regexp.MustCompile(`(?i)\b(LIMIT\s+\d+|TOP\s*\(?\s*\d+|FETCH\s+FIRST)\b`)
+)
+
+// ValidateReadOnlySQL checks that sqlText is a single read-only statement and
+// returns a safety report in the same shape as generated SQL reports.
+func ValidateReadOnlySQL(sqlText string) (map[string]interface{}, error) {
+	stripped := stripSQLLiterals(sqlText)
+	trimmed := strings.TrimSpace(stripped)
+	trimmed = strings.TrimSuffix(trimmed, ";")
+
+	if trimmed == "" {
+		return nil, classErrorf(ErrSafetyBlocked, "SQL is empty")
+	}
+	if strings.Contains(trimmed, ";") {
+		return nil, classErrorf(ErrSafetyBlocked, "only a single SQL statement is allowed")
+	}
+	if !sqlStartRe.MatchString(trimmed) {
+		return nil, classErrorf(ErrSafetyBlocked, "SQL must start with SELECT or WITH")
+	}
+	if match := writeKeywordRe.FindString(trimmed); match != "" {
+		return nil, classErrorf(ErrSafetyBlocked, "SQL contains a disallowed keyword: %s", strings.ToUpper(match))
+	}
+
+	warnings := []string{}
+	if selectStarRe.MatchString(trimmed) {
+		warnings = append(warnings, "SELECT * returns every column; list the columns you need")
+	}
+	if !limitClauseRe.MatchString(trimmed) {
+		warnings = append(warnings, "no row limit; the default row limit will apply")
+	}
+
+	return map[string]interface{}{
+		"read_only": true,
+		"warnings":  warnings,
+		"checks": map[string]any{
+			"single_statement": true,
+			"placeholders":     extractSQLPlaceholders(sqlText),
+		},
+	}, nil
+}
+
+// stripSQLLiterals removes comments and string literals so keyword checks only see SQL
+func stripSQLLiterals(sqlText string) string {
+	out := blockCommentRe.ReplaceAllString(sqlText, " ")
+	out = lineCommentRe.ReplaceAllString(out, " ")
+	return stringLiteralRe.ReplaceAllString(out, "''")
+}
+
+// extractSQLPlaceholders returns the distinct {{param}} names in order of appearance
+func extractSQLPlaceholders(sqlText string) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, match := range placeholderRe.FindAllStringSubmatch(sqlText, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// trimStatementEnd drops the whitespace, semicolons and comments that end a
+// statement, so it can be wrapped in a subquery: a trailing -- comment would
+// otherwise swallow the closing parenthesis. Quoted strings and identifiers
+// are skipped whole.
+func trimStatementEnd(sqlText string) string {
+	end := 0
+	for i := 0; i < len(sqlText); {
+		c := sqlText[i]
+		switch {
+		case strings.HasPrefix(sqlText[i:], "--"):
+			if nl := strings.IndexByte(sqlText[i:], '\n'); nl >= 0 {
+				i += nl + 1
+			} else {
+				i = len(sqlText)
+			}
+		case strings.HasPrefix(sqlText[i:], "/*"):
+			if close := strings.Index(sqlText[i+2:], "*/"); close >= 0 {
+				i += close + 4
+			} else {
+				i = len(sqlText)
+			}
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for j < len(sqlText) {
+				if sqlText[j] == c {
+					if j+1 < len(sqlText) && sqlText[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			i = min(j+1, len(sqlText))
+			end = i
+		case c == ';' || c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			i++
+			end = i
+		}
+	}
+	return sqlText[:end]
+}
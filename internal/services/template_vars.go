@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+)
+
+// Built-in template variables resolved server-side when a report runs. They take
+// precedence over caller params so values like {{current_user}} cannot be spoofed.
+const (
+	tvToday            = "today"
+	tvYesterday        = "yesterday"
+	tvTomorrow         = "tomorrow"
+	tvNow              = "now"
+	tvStartOfWeek      = "start_of_week"
+	tvStartOfMonth     = "start_of_month"
+	tvEndOfMonth       = "end_of_month"
+	tvStartOfLastMonth = "start_of_last_month"
+	tvEndOfLastMonth   = "end_of_last_month"
+	tvStartOfYear      = "start_of_year"
+	tvCurrentUser      = "current_user"
+	tvDatasourceID     = "datasource_id"
+	tvDatasourceSchema = "datasource_schema"
+	tvTimezone         = "timezone"
+	tvSourceTimezone   = "source_timezone"
+	tvTZOffset         = "tz_offset"
+)
+
+var builtinTemplateVars = map[string]bool{
+	tvToday: true, tvYesterday: true, tvTomorrow: true, tvNow: true,
+	tvStartOfWeek: true, tvStartOfMonth: true, tvEndOfMonth: true,
+	tvStartOfLastMonth: true, tvEndOfLastMonth: true, tvStartOfYear: true,
+	tvCurrentUser: true, tvDatasourceID: true, tvDatasourceSchema: true, tvTimezone: true,
+	tvSourceTimezone: true, tvTZOffset: true,
+}
+
+// identifierTemplateVars are built-in variables naming a database object, so
+// {{name}} is substituted as a quoted identifier rather than a string
+// literal, as in FROM {{datasource_schema}}.orders. '{{name}}' still gives
+// the literal.
+var identifierTemplateVars = map[string]bool{
+	tvDatasourceSchema: true,
+}
+
+// isBuiltinTemplateVar reports whether a placeholder is resolved by the server
+func isBuiltinTemplateVar(name string) bool {
+	return builtinTemplateVars[name]
+}
+
+// loadReportLocation resolves a report timezone, defaulting to UTC
+func loadReportLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", tz, err)
+	}
+	return loc, nil
+}
+
+// resolveTemplateVars returns values for the built-in variables used in sqlText.
+// Dates are computed in the report's timezone and formatted as YYYY-MM-DD.
+func resolveTemplateVars(sqlText string, connector *datasource.DatasourceConnector, tz, user string, now time.Time) (map[string]interface{}, error) {
+	used := make(map[string]bool)
+	for _, name := range extractSQLPlaceholders(sqlText) {
+		if isBuiltinTemplateVar(name) {
+			used[name] = true
+		}
+	}
+	if len(used) == 0 {
+		return nil, nil
+	}
+
+	loc, err := loadReportLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	sourceLoc, err := loadReportLocation(connector.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("datasource %s: %w", connector.ID, err)
+	}
+
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	startOfMonth := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	weekday := (int(today.Weekday()) + 6) % 7 // Monday = 0
+
+	const dateFormat = "2006-01-02"
+	values := map[string]interface{}{
+		tvToday:            today.Format(dateFormat),
+		tvYesterday:        today.AddDate(0, 0, -1).Format(dateFormat),
+		tvTomorrow:         today.AddDate(0, 0, 1).Format(dateFormat),
+		tvNow:              local.Format("2006-01-02 15:04:05"),
+		tvStartOfWeek:      today.AddDate(0, 0, -weekday).Format(dateFormat),
+		tvStartOfMonth:     startOfMonth.Format(dateFormat),
+		tvEndOfMonth:       startOfMonth.AddDate(0, 1, -1).Format(dateFormat),
+		tvStartOfLastMonth: startOfMonth.AddDate(0, -1, 0).Format(dateFormat),
+		tvEndOfLastMonth:   startOfMonth.AddDate(0, 0, -1).Format(dateFormat),
+		tvStartOfYear:      time.Date(local.Year(), 1, 1, 0, 0, 0, 0, loc).Format(dateFormat),
+		tvCurrentUser:      user,
+		tvDatasourceID:     connector.ID,
+		tvTimezone:         loc.String(),
+		tvSourceTimezone:   sourceLoc.String(),
+		tvTZOffset:         sqliteOffsetModifier(sourceLoc, loc, now),
+	}
+
+	if used[tvDatasourceSchema] {
+		schema, err := currentSchema(connector, queryComment{User: user, Job: "datasource_schema"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve datasource_schema: %w", err)
+		}
+		values[tvDatasourceSchema] = schema
+	}
+
+	resolved := make(map[string]interface{}, len(used))
+	for name := range used {
+		resolved[name] = values[name]
+	}
+	return resolved, nil
+}
+
+// replaceIdentifierVars substitutes the identifier variables in values that
+// appear unquoted in sqlText with the datasource's quoted identifier
+func replaceIdentifierVars(sqlText string, values map[string]interface{}, connector *datasource.DatasourceConnector) string {
+	for name := range identifierTemplateVars {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		placeholder := "{{" + name + "}}"
+		literal := "'" + placeholder + "'"
+		parts := strings.Split(sqlText, literal)
+		for i, part := range parts {
+			parts[i] = strings.ReplaceAll(part, placeholder, connector.Quote(fmt.Sprintf("%v", value)))
+		}
+		sqlText = strings.Join(parts, literal)
+	}
+	return sqlText
+}
+
+// currentSchema returns the default schema (or database) of a datasource connection
+func currentSchema(connector *datasource.DatasourceConnector, comment queryComment) (string, error) {
+	var query string
+	switch connector.Dialect() {
+	case "postgres", "postgresql", "timescaledb":
+		query = "SELECT current_schema()"
+	case "mysql":
+		query = "SELECT DATABASE()"
+	case "trino":
+		query = "SELECT current_schema"
+	case "sqlite", "sqlite3":
+		return "main", nil
+	default:
+		return "", fmt.Errorf("unsupported datasource kind: %s", connector.Kind)
+	}
+
+	if err := connector.Connected(); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var schema string
+	if err := connector.DB.QueryRowContext(ctx, comment.apply(query)).Scan(&schema); err != nil {
+		return "", err
+	}
+	return schema, nil
+}
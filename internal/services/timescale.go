@@ -0,0 +1,330 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+var (
+	caggAliasRe      = regexp.MustCompile(`(?is)^(.*?)\s+AS\s+"?([A-Za-z_][A-Za-z0-9_]*)"?$`)
+	caggTimeBucketRe = regexp.MustCompile(`(?is)^time_bucket\(\s*'([^']+)'(?:::interval)?\s*,\s*([^,()]+?)\s*\)$`)
+	caggAggregateRe  = regexp.MustCompile(`(?is)^(sum|min|max|count|avg)\(\s*([^()]*?)\s*\)$`)
+	pgDurationRe     = regexp.MustCompile(`^(\d+):(\d+):(\d+)$`)
+)
+
+// maxCaggBucketSeconds keeps continuous aggregate buckets aligned with local time.
+// Buckets are cut in the source timezone, so anything coarser than an hour would
+// straddle report-timezone boundaries.
+const maxCaggBucketSeconds = 3600
+
+// discoverContinuousAggregates records the continuous aggregates of a TimescaleDB
+// source, replacing what an earlier learn found
+func (s *DatasourceService) discoverContinuousAggregates(db *sql.DB, datasourceID string) error {
+	rows, err := db.Query(`SELECT view_schema, view_name, hypertable_name, view_definition FROM timescaledb_information.continuous_aggregates`)
+	if err != nil {
+		return fmt.Errorf("failed to list continuous aggregates: %w", err)
+	}
+	defer rows.Close()
+
+	var found []store.ContinuousAggregate
+	for rows.Next() {
+		var schema, view, hypertable, definition string
+		if err := rows.Scan(&schema, &view, &hypertable, &definition); err != nil {
+			return fmt.Errorf("failed to scan continuous aggregate: %w", err)
+		}
+		cagg, ok := parseContinuousAggregate(definition)
+		if !ok {
+			logger.LogWarn(logger.ServiceDB, "Skipping continuous aggregate without a recognizable time_bucket", map[string]interface{}{
+				"datasource_id": datasourceID,
+				"view":          schema + "." + view,
+			})
+			continue
+		}
+		cagg.DatasourceID = datasourceID
+		cagg.ViewName = schema + "." + view
+		cagg.HypertableName = hypertable
+		cagg.DiscoveredAt = time.Now()
+		found = append(found, *cagg)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("datasource_id = ?", datasourceID).Delete(&store.ContinuousAggregate{}).Error; err != nil {
+			return err
+		}
+		for i := range found {
+			if err := tx.Create(&found[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store continuous aggregates: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceDB, "Continuous aggregates discovered", map[string]interface{}{
+		"datasource_id": datasourceID,
+		"count":         len(found),
+	})
+	return nil
+}
+
+// parseContinuousAggregate reads the bucket, group columns and aggregates from a
+// continuous aggregate's view definition
+func parseContinuousAggregate(definition string) (*store.ContinuousAggregate, bool) {
+	items := selectListItems(definition)
+	if len(items) == 0 {
+		return nil, false
+	}
+
+	cagg := &store.ContinuousAggregate{}
+	aggregates := map[string]string{}
+	var groups []string
+	for _, item := range items {
+		expr, alias := item, ""
+		if match := caggAliasRe.FindStringSubmatch(item); match != nil {
+			expr, alias = strings.TrimSpace(match[1]), match[2]
+		}
+
+		if match := caggTimeBucketRe.FindStringSubmatch(expr); match != nil {
+			seconds, ok := pgIntervalSeconds(match[1])
+			if !ok {
+				return nil, false
+			}
+			cagg.TimeColumn = unqualifyColumn(match[2])
+			cagg.BucketColumn = alias
+			if alias == "" {
+				cagg.BucketColumn = "time_bucket"
+			}
+			cagg.BucketSeconds = seconds
+			continue
+		}
+		if match := caggAggregateRe.FindStringSubmatch(expr); match != nil && alias != "" {
+			aggregates[fmt.Sprintf("%s(%s)", strings.ToLower(match[1]), unqualifyColumn(match[2]))] = alias
+			continue
+		}
+		if !strings.ContainsAny(expr, "()") {
+			column := alias
+			if column == "" {
+				column = unqualifyColumn(expr)
+			}
+			groups = append(groups, column)
+		}
+	}
+	if cagg.BucketColumn == "" || cagg.BucketSeconds <= 0 {
+		return nil, false
+	}
+
+	aggJSON, _ := json.Marshal(aggregates)
+	cagg.AggregatesJSON = string(aggJSON)
+	cagg.GroupColumns = strings.Join(groups, ",")
+	return cagg, true
+}
+
+// selectListItems splits the top-level select list of a query at commas
+func selectListItems(query string) []string {
+	lower := strings.ToLower(query)
+	start := strings.Index(lower, "select")
+	if start < 0 {
+		return nil
+	}
+
+	var items []string
+	var current strings.Builder
+	depth, inQuote := 0, false
+	for i := start + len("select"); i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && c == ',':
+			items = append(items, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		case depth == 0 && strings.HasPrefix(lower[i:], "from") && i > 0 && isSQLSpace(query[i-1]) && i+4 < len(query) && isSQLSpace(query[i+4]):
+			items = append(items, strings.TrimSpace(current.String()))
+			return items
+		}
+		current.WriteByte(c)
+	}
+	return nil
+}
+
+func isSQLSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\t' || c == '\r'
+}
+
+// unqualifyColumn turns `readings."time"` into `time`
+func unqualifyColumn(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if i := strings.LastIndexByte(expr, '.'); i >= 0 {
+		expr = expr[i+1:]
+	}
+	return strings.Trim(expr, `"`)
+}
+
+// pgIntervalSeconds converts Postgres interval output such as "01:00:00",
+// "00:15:00" or "1 day" to seconds. Month-based intervals are not fixed-width.
+func pgIntervalSeconds(interval string) (int64, bool) {
+	fields := strings.Fields(strings.ToLower(interval))
+	var total int64
+	for i := 0; i < len(fields); i++ {
+		if match := pgDurationRe.FindStringSubmatch(fields[i]); match != nil {
+			h, _ := strconv.ParseInt(match[1], 10, 64)
+			m, _ := strconv.ParseInt(match[2], 10, 64)
+			sec, _ := strconv.ParseInt(match[3], 10, 64)
+			total += h*3600 + m*60 + sec
+			continue
+		}
+		n, err := strconv.ParseInt(fields[i], 10, 64)
+		if err != nil || i+1 >= len(fields) {
+			return 0, false
+		}
+		i++
+		switch strings.TrimSuffix(fields[i], "s") {
+		case "sec", "second":
+			total += n
+		case "min", "minute":
+			total += n * 60
+		case "hour":
+			total += n * 3600
+		case "day":
+			total += n * 86400
+		case "week":
+			total += n * 7 * 86400
+		default:
+			return 0, false
+		}
+	}
+	return total, total > 0
+}
+
+// continuousAggregatesFor returns the continuous aggregates learned for a datasource
+func (s *AIService) continuousAggregatesFor(datasourceID string) []store.ContinuousAggregate {
+	var caggs []store.ContinuousAggregate
+	if err := s.db.Where("datasource_id = ?", datasourceID).Order("bucket_seconds DESC").Find(&caggs).Error; err != nil {
+		logger.LogWarn(logger.ServiceAI, "Failed to load continuous aggregates", map[string]interface{}{
+			"datasource_id": datasourceID,
+			"error":         err.Error(),
+		})
+		return nil
+	}
+	return caggs
+}
+
+// continuousAggregateHint describes continuous aggregates for model prompts
+func continuousAggregateHint(caggs []store.ContinuousAggregate) string {
+	if len(caggs) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, c := range caggs {
+		var aggregates map[string]string
+		_ = json.Unmarshal([]byte(c.AggregatesJSON), &aggregates)
+		var cols []string
+		for expr, alias := range aggregates {
+			cols = append(cols, fmt.Sprintf("%s = %s", alias, expr))
+		}
+		sort.Strings(cols)
+		lines = append(lines, fmt.Sprintf("%s (%s buckets of %s.%s in column %s; group columns: %s; aggregates: %s)",
+			c.ViewName, time.Duration(c.BucketSeconds)*time.Second, c.HypertableName, c.TimeColumn, c.BucketColumn,
+			c.GroupColumns, strings.Join(cols, ", ")))
+	}
+	return " Continuous aggregates are available and are much cheaper than the raw hypertable when their buckets fit the question: " +
+		strings.Join(lines, "; ") + "."
+}
+
+// timescaleTimeRangeClause filters the raw time column against constant bounds so
+// TimescaleDB can exclude chunks; the report-timezone dates are converted to the
+// source timezone instead of converting every row
+func timescaleTimeRangeClause(field string) string {
+	start := "((CAST('{{start_date}}' AS TIMESTAMP) AT TIME ZONE '{{timezone}}') AT TIME ZONE '{{source_timezone}}')"
+	end := "(((CAST('{{end_date}}' AS TIMESTAMP) + INTERVAL '1 day') AT TIME ZONE '{{timezone}}') AT TIME ZONE '{{source_timezone}}')"
+	return fmt.Sprintf("%s >= %s AND %s < %s", field, start, field, end)
+}
+
+// caggMatch is a continuous aggregate that can serve the first stage of a
+// time-series query, with each metric rewritten over the view's columns
+type caggMatch struct {
+	view         string
+	bucketColumn string
+	metricExprs  map[string]string // metric alias -> aggregate over the view
+}
+
+// matchContinuousAggregate finds a continuous aggregate over dataset whose buckets
+// evenly divide iv and that carries every metric, key and filter column
+func matchContinuousAggregate(ts *timeSeriesIR, iv tsInterval, dataset string, filters []interface{}, caggs []store.ContinuousAggregate) *caggMatch {
+	stageSeconds := int64(86400) // days and months only need the bucket to divide a day
+	switch iv.unit {
+	case "minute", "hour":
+		stageSeconds = int64(iv.seconds())
+	case "week":
+		stageSeconds = 7 * 86400
+	}
+
+	for _, c := range caggs {
+		if !strings.EqualFold(c.HypertableName, unqualifyColumn(dataset)) || c.TimeColumn != unqualifyColumn(ts.timeField) {
+			continue
+		}
+		if c.BucketSeconds > maxCaggBucketSeconds || stageSeconds%c.BucketSeconds != 0 {
+			continue
+		}
+
+		groups := map[string]bool{}
+		for _, g := range strings.Split(c.GroupColumns, ",") {
+			groups[g] = true
+		}
+		covered := true
+		for _, key := range ts.keys {
+			covered = covered && groups[tsColumnName(key)]
+		}
+		for _, f := range filters {
+			fm, _ := f.(map[string]interface{})
+			field, _ := fm["field"].(string)
+			covered = covered && groups[unqualifyColumn(field)]
+		}
+		if !covered {
+			continue
+		}
+
+		var aggregates map[string]string
+		if err := json.Unmarshal([]byte(c.AggregatesJSON), &aggregates); err != nil {
+			continue
+		}
+		exprs := map[string]string{}
+		for _, m := range ts.metrics {
+			column, ok := aggregates[fmt.Sprintf("%s(%s)", m.agg, unqualifyColumn(m.field))]
+			switch {
+			case !ok || m.agg == "avg":
+				// Averages of bucket averages are skewed by uneven bucket counts
+				covered = false
+			case m.agg == "count":
+				exprs[m.alias] = fmt.Sprintf("SUM(%s)", column)
+			default:
+				exprs[m.alias] = fmt.Sprintf("%s(%s)", strings.ToUpper(m.agg), column)
+			}
+		}
+		if covered {
+			return &caggMatch{view: c.ViewName, bucketColumn: c.BucketColumn, metricExprs: exprs}
+		}
+	}
+	return nil
+}
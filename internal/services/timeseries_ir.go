@@ -0,0 +1,635 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/NubeDev/air/internal/store"
+)
+
+// The optional IR "timeseries" block describes bucketed time-series queries:
+//
+//	"timeseries": {
+//	  "time_field": "ts",
+//	  "metrics": [{"field": "kwh", "agg": "sum", "as": "kwh"}],
+//	  "resample": "15m",                              // bucket raw rows
+//	  "rollup": {"interval": "1h", "agg": "sum"},     // re-aggregate buckets
+//	  "gap_fill": "locf",                             // null | zero | locf | interpolate
+//	  "window": [{"func": "avg", "field": "kwh", "size": 24, "as": "kwh_24h"}],
+//	  "partition_by": ["meter_id"]                    // defaults to group_by
+//	}
+//
+// It compiles to a CTE pipeline: resampled -> rolled_up -> filled -> windows.
+// TimescaleDB uses time_bucket/time_bucket_gapfill, other engines truncate
+// with date_trunc or its dialect equivalent and generate the bucket series.
+
+// tsIdentRe restricts time-series fields and aliases to plain (optionally qualified) identifiers
+var tsIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// tsInterval is a bucket width such as 15 minutes or 1 month
+type tsInterval struct {
+	n    int
+	unit string // minute, hour, day, week, month
+}
+
+type tsMetric struct {
+	field     string
+	agg       string
+	alias     string
+	rollupAgg string
+}
+
+type tsWindow struct {
+	fn    string // avg, sum, min, max, lag, delta
+	field string
+	size  int
+	alias string
+}
+
+// timeSeriesIR is the parsed "timeseries" block
+type timeSeriesIR struct {
+	timeField string
+	resample  *tsInterval
+	rollup    *tsInterval
+	metrics   []tsMetric
+	gapFill   string
+	windows   []tsWindow
+	keys      []string
+}
+
+// tsUnitAliases maps accepted interval spellings to units
+var tsUnitAliases = map[string]string{
+	"m": "minute", "min": "minute", "mins": "minute", "minute": "minute", "minutes": "minute",
+	"h": "hour", "hr": "hour", "hrs": "hour", "hour": "hour", "hours": "hour",
+	"d": "day", "day": "day", "days": "day",
+	"w": "week", "week": "week", "weeks": "week",
+	"mo": "month", "month": "month", "months": "month",
+}
+
+var tsIntervalRe = regexp.MustCompile(`^(\d*)\s*([a-z]+)$`)
+
+// parseTSInterval accepts "15m", "1h", "1 day", "week" or "1mo"
+func parseTSInterval(v interface{}) (*tsInterval, error) {
+	raw, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("interval must be a string like \"15m\" or \"1h\"")
+	}
+	match := tsIntervalRe.FindStringSubmatch(strings.ToLower(strings.TrimSpace(raw)))
+	if match == nil || tsUnitAliases[match[2]] == "" {
+		return nil, fmt.Errorf("invalid interval %q", raw)
+	}
+
+	iv := &tsInterval{n: 1, unit: tsUnitAliases[match[2]]}
+	if match[1] != "" {
+		iv.n, _ = strconv.Atoi(match[1])
+	}
+	switch {
+	case iv.n < 1:
+		return nil, fmt.Errorf("invalid interval %q", raw)
+	case iv.unit == "minute" && 60%iv.n != 0:
+		return nil, fmt.Errorf("interval %q: minutes must divide an hour", raw)
+	case iv.unit == "hour" && 24%iv.n != 0:
+		return nil, fmt.Errorf("interval %q: hours must divide a day", raw)
+	case (iv.unit == "day" || iv.unit == "week" || iv.unit == "month") && iv.n != 1:
+		return nil, fmt.Errorf("interval %q: only single days, weeks and months are supported", raw)
+	}
+	return iv, nil
+}
+
+// postgresInterval renders the interval as a Postgres interval literal body
+func (iv tsInterval) postgresInterval() string {
+	unit := iv.unit
+	if iv.n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", iv.n, unit)
+}
+
+// seconds returns the width of minute and hour intervals
+func (iv tsInterval) seconds() int {
+	if iv.unit == "hour" {
+		return iv.n * 3600
+	}
+	return iv.n * 60
+}
+
+// parseTimeSeriesIR reads the IR "timeseries" block; it returns nil when absent
+func parseTimeSeriesIR(ir map[string]interface{}) (*timeSeriesIR, error) {
+	raw, ok := ir["timeseries"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	block, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("timeseries must be an object")
+	}
+
+	ts := &timeSeriesIR{}
+	ts.timeField, _ = block["time_field"].(string)
+	if !tsIdentRe.MatchString(ts.timeField) {
+		return nil, fmt.Errorf("timeseries.time_field must be a column name")
+	}
+
+	var err error
+	if v, ok := block["resample"]; ok {
+		if m, isMap := v.(map[string]interface{}); isMap {
+			v = m["interval"]
+		}
+		if ts.resample, err = parseTSInterval(v); err != nil {
+			return nil, fmt.Errorf("timeseries.resample: %w", err)
+		}
+	}
+
+	rollupAgg := ""
+	if v, ok := block["rollup"]; ok {
+		if m, isMap := v.(map[string]interface{}); isMap {
+			v = m["interval"]
+			rollupAgg, _ = m["agg"].(string)
+			rollupAgg = strings.ToLower(rollupAgg)
+		}
+		if ts.rollup, err = parseTSInterval(v); err != nil {
+			return nil, fmt.Errorf("timeseries.rollup: %w", err)
+		}
+		if rollupAgg != "" && !isTSAggregate(rollupAgg) {
+			return nil, fmt.Errorf("timeseries.rollup.agg must be one of: sum, avg, min, max, count")
+		}
+	}
+	if ts.resample == nil && ts.rollup == nil {
+		return nil, fmt.Errorf("timeseries needs resample or rollup")
+	}
+
+	metrics, _ := block["metrics"].([]interface{})
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("timeseries.metrics must list at least one metric")
+	}
+	seen := map[string]bool{"bucket": true}
+	for i, item := range metrics {
+		m, _ := item.(map[string]interface{})
+		field, _ := m["field"].(string)
+		agg, _ := m["agg"].(string)
+		alias, _ := m["as"].(string)
+		agg = strings.ToLower(agg)
+		if agg == "" {
+			agg = "avg"
+		}
+		if !isTSAggregate(agg) {
+			return nil, fmt.Errorf("timeseries.metrics[%d].agg must be one of: sum, avg, min, max, count", i)
+		}
+		if !tsIdentRe.MatchString(field) && !(field == "*" && agg == "count") {
+			return nil, fmt.Errorf("timeseries.metrics[%d].field must be a column name", i)
+		}
+		if alias == "" {
+			alias = agg + "_" + strings.ReplaceAll(field, ".", "_")
+			if field == "*" {
+				alias = "row_count"
+			}
+		}
+		if !tsIdentRe.MatchString(alias) || strings.Contains(alias, ".") || seen[alias] {
+			return nil, fmt.Errorf("timeseries.metrics[%d].as must be a unique column alias", i)
+		}
+		seen[alias] = true
+
+		metric := tsMetric{field: field, agg: agg, alias: alias, rollupAgg: rollupAgg}
+		if metric.rollupAgg == "" {
+			metric.rollupAgg = agg
+			if agg == "count" {
+				metric.rollupAgg = "sum" // counts of buckets add up
+			}
+		}
+		ts.metrics = append(ts.metrics, metric)
+	}
+
+	if v, ok := block["gap_fill"]; ok && v != nil {
+		if m, isMap := v.(map[string]interface{}); isMap {
+			v = m["method"]
+		}
+		method, _ := v.(string)
+		switch strings.ToLower(method) {
+		case "null", "zero", "locf", "interpolate":
+			ts.gapFill = strings.ToLower(method)
+		default:
+			return nil, fmt.Errorf("timeseries.gap_fill must be one of: null, zero, locf, interpolate")
+		}
+	}
+
+	windows, _ := block["window"].([]interface{})
+	for i, item := range windows {
+		w, _ := item.(map[string]interface{})
+		fn, _ := w["func"].(string)
+		field, _ := w["field"].(string)
+		alias, _ := w["as"].(string)
+		size := 1
+		if n, ok := w["size"].(float64); ok {
+			size = int(n)
+		}
+		fn = strings.ToLower(fn)
+		switch fn {
+		case "avg", "sum", "min", "max", "lag", "delta":
+		default:
+			return nil, fmt.Errorf("timeseries.window[%d].func must be one of: avg, sum, min, max, lag, delta", i)
+		}
+		if !isMetricAlias(ts.metrics, field) {
+			return nil, fmt.Errorf("timeseries.window[%d].field must be a metric alias", i)
+		}
+		if size < 1 {
+			return nil, fmt.Errorf("timeseries.window[%d].size must be at least 1", i)
+		}
+		if alias == "" {
+			alias = fmt.Sprintf("%s_%s_%d", field, fn, size)
+		}
+		if !tsIdentRe.MatchString(alias) || strings.Contains(alias, ".") || seen[alias] {
+			return nil, fmt.Errorf("timeseries.window[%d].as must be a unique column alias", i)
+		}
+		seen[alias] = true
+		ts.windows = append(ts.windows, tsWindow{fn: fn, field: field, size: size, alias: alias})
+	}
+
+	keys, ok := block["partition_by"].([]interface{})
+	if !ok {
+		keys, _ = ir["group_by"].([]interface{})
+	}
+	for _, k := range keys {
+		key, isString := k.(string)
+		if !isString || key == ts.timeField {
+			continue
+		}
+		if !tsIdentRe.MatchString(key) {
+			return nil, fmt.Errorf("timeseries.partition_by entries must be column names")
+		}
+		ts.keys = append(ts.keys, key)
+	}
+
+	return ts, nil
+}
+
+func isMetricAlias(metrics []tsMetric, alias string) bool {
+	for _, m := range metrics {
+		if m.alias == alias {
+			return true
+		}
+	}
+	return false
+}
+
+func isTSAggregate(agg string) bool {
+	switch agg {
+	case "sum", "avg", "min", "max", "count":
+		return true
+	}
+	return false
+}
+
+// generateTimeSeriesSQL compiles an IR with a timeseries block for dbKind
+// On TimescaleDB the first stage reads from a matching continuous aggregate in
+// caggs when one exists.
+func (s *AIService) generateTimeSeriesSQL(ir map[string]interface{}, ts *timeSeriesIR, dbKind string, caggs []store.ContinuousAggregate) string {
+	kind := strings.ToLower(dbKind)
+	dbType := kind
+	timescale := kind == "timescaledb"
+	switch kind {
+	case "postgresql", "timescaledb":
+		dbType = "postgres"
+	case "sqlite3":
+		dbType = "sqlite"
+	}
+
+	dataset, _ := ir["dataset"].(string)
+	filters, _ := ir["filters"].([]interface{})
+	orderBy, _ := ir["order_by"].([]interface{})
+	limit, _ := ir["limit"].(float64)
+
+	rangeType := dbType
+	if timescale {
+		rangeType = kind
+	}
+	var conditions []string
+	if where := s.buildWhereClause(filters, dbType); where != "" {
+		conditions = append(conditions, where)
+	}
+
+	// Aggregation stages: resample from the table, then roll buckets up
+	type stage struct {
+		interval    *tsInterval
+		from        string
+		timeExpr    string
+		rangeField  string
+		metricExprs map[string]string
+		rollup      bool
+	}
+	first := stage{interval: ts.resample, from: dataset, timeExpr: localTimestampExpr(ts.timeField, dbType), rangeField: ts.timeField}
+	if first.interval == nil {
+		first.interval = ts.rollup
+	}
+	if timescale {
+		if match := matchContinuousAggregate(ts, *first.interval, dataset, filters, caggs); match != nil {
+			first.from = match.view
+			first.timeExpr = localTimestampExpr(match.bucketColumn, dbType)
+			first.rangeField = match.bucketColumn
+			first.metricExprs = match.metricExprs
+		}
+	}
+	stages := []stage{first}
+	if ts.resample != nil && ts.rollup != nil {
+		stages = append(stages, stage{interval: ts.rollup, from: "resampled", timeExpr: "resampled.bucket", rollup: true})
+	}
+
+	keyCols := make([]string, len(ts.keys))
+	for i, key := range ts.keys {
+		keyCols[i] = tsColumnName(key)
+	}
+
+	var ctes []string
+	last := ""
+	for i, st := range stages {
+		name := "resampled"
+		if st.rollup {
+			name = "rolled_up"
+		}
+		final := i == len(stages)-1
+		timescaleFill := final && timescale && ts.gapFill != ""
+
+		bucket := tsBucketExpr(st.timeExpr, *st.interval, dbType, timescale)
+		if timescaleFill {
+			start, end := tsRangeExprs(dbType)
+			bucket = fmt.Sprintf("time_bucket_gapfill('%s', %s, %s, %s)", st.interval.postgresInterval(), st.timeExpr, start, end)
+		}
+
+		keys := ts.keys
+		if st.rollup || st.metricExprs != nil {
+			keys = keyCols
+		}
+		cols := []string{bucket + " AS bucket"}
+		cols = append(cols, keys...)
+		for _, m := range ts.metrics {
+			expr := fmt.Sprintf("%s(%s)", strings.ToUpper(m.agg), m.field)
+			if st.rollup {
+				expr = fmt.Sprintf("%s(%s)", strings.ToUpper(m.rollupAgg), m.alias)
+			} else if st.metricExprs != nil {
+				expr = st.metricExprs[m.alias]
+			}
+			if timescaleFill {
+				expr = timescaleGapFillExpr(expr, ts.gapFill)
+			}
+			cols = append(cols, expr+" AS "+m.alias)
+		}
+
+		var sql strings.Builder
+		sql.WriteString(fmt.Sprintf("%s AS (SELECT %s FROM %s", name, strings.Join(cols, ", "), st.from))
+		if !st.rollup {
+			where := append([]string{s.buildDateRangeWhereClause(st.rangeField, rangeType)}, conditions...)
+			sql.WriteString(" WHERE " + strings.Join(where, " AND "))
+		}
+		groupBy := append([]string{bucket}, keys...)
+		sql.WriteString(" GROUP BY " + strings.Join(groupBy, ", ") + ")")
+		ctes = append(ctes, sql.String())
+		last = name
+	}
+
+	recursive := false
+	if ts.gapFill != "" && !timescale {
+		fillCTEs, fillLast := tsGapFillCTEs(ts, *stages[len(stages)-1].interval, last, dbType)
+		ctes = append(ctes, fillCTEs...)
+		last = fillLast
+		recursive = dbType != "postgres"
+	}
+
+	// Final projection with rolling windows
+	cols := []string{"bucket"}
+	cols = append(cols, keyCols...)
+	for _, m := range ts.metrics {
+		cols = append(cols, m.alias)
+	}
+	over := "ORDER BY bucket"
+	if len(keyCols) > 0 {
+		over = "PARTITION BY " + strings.Join(keyCols, ", ") + " " + over
+	}
+	for _, w := range ts.windows {
+		var expr string
+		switch w.fn {
+		case "lag":
+			expr = fmt.Sprintf("LAG(%s, %d) OVER (%s)", w.field, w.size, over)
+		case "delta":
+			expr = fmt.Sprintf("%s - LAG(%s, %d) OVER (%s)", w.field, w.field, w.size, over)
+		default:
+			expr = fmt.Sprintf("%s(%s) OVER (%s ROWS BETWEEN %d PRECEDING AND CURRENT ROW)", strings.ToUpper(w.fn), w.field, over, w.size-1)
+		}
+		cols = append(cols, expr+" AS "+w.alias)
+	}
+
+	var sql strings.Builder
+	sql.WriteString("WITH ")
+	if recursive {
+		sql.WriteString("RECURSIVE ")
+	}
+	sql.WriteString(strings.Join(ctes, ", "))
+	sql.WriteString(" SELECT ")
+	if recursive && dbType == "mysql" {
+		// The bucket series is a recursive CTE; MySQL stops at 1000 levels by default
+		sql.WriteString("/*+ SET_VAR(cte_max_recursion_depth = 1000000) */ ")
+	}
+	sql.WriteString(strings.Join(cols, ", "))
+	sql.WriteString(" FROM " + last)
+
+	if len(orderBy) > 0 {
+		sql.WriteString(" ORDER BY " + s.buildOrderByClause(orderBy, dbType))
+	} else {
+		sql.WriteString(" ORDER BY " + strings.Join(append([]string{"bucket"}, keyCols...), ", "))
+	}
+	if limit > 0 {
+		sql.WriteString(fmt.Sprintf(" LIMIT %d", int(limit)))
+	}
+	return sql.String()
+}
+
+// tsBucketExpr truncates expr to the start of its bucket
+func tsBucketExpr(expr string, iv tsInterval, dbType string, timescale bool) string {
+	if timescale {
+		return fmt.Sprintf("time_bucket('%s', %s)", iv.postgresInterval(), expr)
+	}
+
+	switch dbType {
+	case "postgres":
+		switch {
+		case iv.n == 1:
+			return fmt.Sprintf("date_trunc('%s', %s)", iv.unit, expr)
+		case iv.unit == "minute":
+			return fmt.Sprintf("(date_trunc('hour', %s) + FLOOR(EXTRACT(MINUTE FROM %s) / %d) * INTERVAL '%s')", expr, expr, iv.n, iv.postgresInterval())
+		default:
+			return fmt.Sprintf("(date_trunc('day', %s) + FLOOR(EXTRACT(HOUR FROM %s) / %d) * INTERVAL '%s')", expr, expr, iv.n, iv.postgresInterval())
+		}
+	case "mysql":
+		switch iv.unit {
+		case "minute":
+			return fmt.Sprintf("(TIMESTAMP(DATE_FORMAT(%s, '%s')) + INTERVAL FLOOR(MINUTE(%s) / %d) * %d MINUTE)", expr, mysqlTruncFormats["hour"], expr, iv.n, iv.n)
+		case "hour":
+			return fmt.Sprintf("(TIMESTAMP(DATE(%s)) + INTERVAL FLOOR(HOUR(%s) / %d) * %d HOUR)", expr, expr, iv.n, iv.n)
+		case "day":
+			return fmt.Sprintf("TIMESTAMP(DATE(%s))", expr)
+		case "week":
+			return fmt.Sprintf("TIMESTAMP(DATE(%s) - INTERVAL WEEKDAY(%s) DAY)", expr, expr)
+		default:
+			return fmt.Sprintf("TIMESTAMP(DATE_FORMAT(%s, '%s'))", expr, mysqlTruncFormats["month"])
+		}
+	default:
+		switch iv.unit {
+		case "minute", "hour":
+			return fmt.Sprintf("datetime((CAST(strftime('%%s', %s) AS INTEGER) / %d) * %d, 'unixepoch')", expr, iv.seconds(), iv.seconds())
+		case "day":
+			return fmt.Sprintf("datetime(date(%s))", expr)
+		case "week":
+			return fmt.Sprintf("datetime(date(%s, '-6 days', 'weekday 1'))", expr) // Monday on or before
+		default:
+			return fmt.Sprintf("datetime(date(%s, 'start of month'))", expr)
+		}
+	}
+}
+
+// tsRangeExprs returns the gap-fill range: start_date through the end of end_date
+func tsRangeExprs(dbType string) (string, string) {
+	switch dbType {
+	case "postgres":
+		return "CAST('{{start_date}}' AS TIMESTAMP)", "(CAST('{{end_date}}' AS TIMESTAMP) + INTERVAL '1 day')"
+	case "mysql":
+		return "TIMESTAMP('{{start_date}}')", "(TIMESTAMP('{{end_date}}') + INTERVAL 1 DAY)"
+	default:
+		return "datetime('{{start_date}}')", "datetime('{{end_date}}', '+1 day')"
+	}
+}
+
+// tsStepExpr advances a bucket by one interval
+func tsStepExpr(expr string, iv tsInterval, dbType string) string {
+	if dbType == "mysql" {
+		return fmt.Sprintf("%s + INTERVAL %d %s", expr, iv.n, strings.ToUpper(iv.unit))
+	}
+	n, unit := iv.n, iv.unit
+	if unit == "week" {
+		n, unit = 7*n, "day"
+	}
+	return fmt.Sprintf("datetime(%s, '+%d %ss')", expr, n, unit)
+}
+
+// timescaleGapFillExpr wraps an aggregate for time_bucket_gapfill
+func timescaleGapFillExpr(expr, method string) string {
+	switch method {
+	case "zero":
+		return fmt.Sprintf("COALESCE(%s, 0)", expr)
+	case "locf":
+		return fmt.Sprintf("locf(%s)", expr)
+	case "interpolate":
+		return fmt.Sprintf("interpolate(CAST(%s AS DOUBLE PRECISION))", expr)
+	default:
+		return expr
+	}
+}
+
+// tsGapFillCTEs builds the bucket series, joins it to the aggregated rows and
+// fills missing values. Interpolation is linear in bucket position, which equals
+// time for a regular series.
+func tsGapFillCTEs(ts *timeSeriesIR, iv tsInterval, from, dbType string) ([]string, string) {
+	start, end := tsRangeExprs(dbType)
+	first := tsBucketExpr(start, iv, dbType, false)
+
+	var ctes []string
+	if dbType == "postgres" {
+		ctes = append(ctes, fmt.Sprintf("buckets AS (SELECT bucket FROM generate_series(%s, %s, INTERVAL '%s') AS series(bucket) WHERE bucket < %s)",
+			first, end, iv.postgresInterval(), end))
+	} else {
+		next := tsStepExpr("bucket", iv, dbType)
+		ctes = append(ctes, fmt.Sprintf("buckets (bucket) AS (SELECT %s UNION ALL SELECT %s FROM buckets WHERE %s < %s)",
+			first, next, next, end))
+	}
+
+	grid := "buckets b"
+	join := "a.bucket = b.bucket"
+	keyCols := make([]string, len(ts.keys))
+	for i, key := range ts.keys {
+		col := tsColumnName(key)
+		keyCols[i] = col
+		join += fmt.Sprintf(" AND a.%s = k.%s", col, col)
+	}
+	if len(ts.keys) > 0 {
+		ctes = append(ctes, fmt.Sprintf("series_keys AS (SELECT DISTINCT %s FROM %s)", strings.Join(keyCols, ", "), from))
+		grid += " CROSS JOIN series_keys k"
+	}
+
+	cols := []string{"b.bucket"}
+	for _, col := range keyCols {
+		cols = append(cols, "k."+col)
+	}
+	for _, m := range ts.metrics {
+		expr := "a." + m.alias
+		if ts.gapFill == "zero" {
+			expr = fmt.Sprintf("COALESCE(a.%s, 0)", m.alias)
+		}
+		cols = append(cols, fmt.Sprintf("%s AS %s", expr, m.alias))
+	}
+	ctes = append(ctes, fmt.Sprintf("gridded AS (SELECT %s FROM %s LEFT JOIN %s a ON %s)", strings.Join(cols, ", "), grid, from, join))
+
+	if ts.gapFill == "null" || ts.gapFill == "zero" {
+		return ctes, "gridded"
+	}
+
+	partition := "ORDER BY bucket"
+	if len(keyCols) > 0 {
+		partition = "PARTITION BY " + strings.Join(keyCols, ", ") + " ORDER BY bucket"
+	}
+	groupPartition := func(group string) string {
+		return "PARTITION BY " + strings.Join(append(append([]string{}, keyCols...), group), ", ")
+	}
+
+	// Each run of missing values shares a group with the last (and, descending,
+	// the next) non-null value
+	inner := append([]string{"bucket"}, keyCols...)
+	if ts.gapFill == "interpolate" {
+		inner = append(inner, fmt.Sprintf("ROW_NUMBER() OVER (%s) AS series_pos", partition))
+	}
+	for _, m := range ts.metrics {
+		inner = append(inner, m.alias, fmt.Sprintf("COUNT(%s) OVER (%s) AS %s__prev_grp", m.alias, partition, m.alias))
+		if ts.gapFill == "interpolate" {
+			inner = append(inner, fmt.Sprintf("COUNT(%s) OVER (%s DESC) AS %s__next_grp", m.alias, partition, m.alias))
+		}
+	}
+	grouped := fmt.Sprintf("SELECT %s FROM gridded", strings.Join(inner, ", "))
+
+	if ts.gapFill == "locf" {
+		outer := append([]string{"bucket"}, keyCols...)
+		for _, m := range ts.metrics {
+			outer = append(outer, fmt.Sprintf("MAX(%s) OVER (%s) AS %s", m.alias, groupPartition(m.alias+"__prev_grp"), m.alias))
+		}
+		ctes = append(ctes, fmt.Sprintf("filled AS (SELECT %s FROM (%s) g)", strings.Join(outer, ", "), grouped))
+		return ctes, "filled"
+	}
+
+	neighbours := append([]string{"bucket", "series_pos"}, keyCols...)
+	for _, m := range ts.metrics {
+		prev, next := groupPartition(m.alias+"__prev_grp"), groupPartition(m.alias+"__next_grp")
+		neighbours = append(neighbours,
+			m.alias,
+			fmt.Sprintf("MAX(%s) OVER (%s) AS %s__prev", m.alias, prev, m.alias),
+			fmt.Sprintf("MAX(CASE WHEN %s IS NOT NULL THEN series_pos END) OVER (%s) AS %s__prev_pos", m.alias, prev, m.alias),
+			fmt.Sprintf("MAX(%s) OVER (%s) AS %s__next", m.alias, next, m.alias),
+			fmt.Sprintf("MIN(CASE WHEN %s IS NOT NULL THEN series_pos END) OVER (%s) AS %s__next_pos", m.alias, next, m.alias),
+		)
+	}
+	outer := append([]string{"bucket"}, keyCols...)
+	for _, m := range ts.metrics {
+		a := m.alias
+		outer = append(outer, fmt.Sprintf(
+			"CASE WHEN %s IS NOT NULL THEN %s WHEN %s__prev_pos IS NULL OR %s__next_pos IS NULL THEN NULL "+
+				"ELSE %s__prev + (%s__next - %s__prev) * 1.0 * (series_pos - %s__prev_pos) / (%s__next_pos - %s__prev_pos) END AS %s",
+			a, a, a, a, a, a, a, a, a, a, a))
+	}
+	ctes = append(ctes, fmt.Sprintf("filled AS (SELECT %s FROM (SELECT %s FROM (%s) g) n)",
+		strings.Join(outer, ", "), strings.Join(neighbours, ", "), grouped))
+	return ctes, "filled"
+}
+
+// tsColumnName is the output column name of a possibly qualified key
+func tsColumnName(key string) string {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		return key[i+1:]
+	}
+	return key
+}
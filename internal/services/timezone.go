@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// Generated SQL converts stored timestamps into the report's timezone through the
+// {{source_timezone}}, {{timezone}} and {{tz_offset}} built-ins, so day boundaries
+// follow the report rather than the database session (usually UTC).
+
+// localTimestampExpr converts a naive timestamp column stored in the datasource
+// timezone into the report timezone
+func localTimestampExpr(field, dbType string) string {
+	switch dbType {
+	case "postgres":
+		return fmt.Sprintf("((%s AT TIME ZONE '{{source_timezone}}') AT TIME ZONE '{{timezone}}')", field)
+	case "mysql":
+		// Named zones need the MySQL time zone tables (mysql_tzinfo_to_sql)
+		return fmt.Sprintf("CONVERT_TZ(%s, '{{source_timezone}}', '{{timezone}}')", field)
+	default:
+		// SQLite has no zone database; shift by the offset in effect at run time
+		return fmt.Sprintf("datetime(%s, '{{tz_offset}}')", field)
+	}
+}
+
+// localDateExpr truncates a stored timestamp to the calendar day in the report timezone
+func localDateExpr(field, dbType string) string {
+	switch dbType {
+	case "postgres":
+		return fmt.Sprintf("CAST(%s AS DATE)", localTimestampExpr(field, dbType))
+	case "mysql":
+		return fmt.Sprintf("DATE(%s)", localTimestampExpr(field, dbType))
+	default:
+		return fmt.Sprintf("date(%s)", localTimestampExpr(field, dbType))
+	}
+}
+
+// dateLiteralExpr casts a quoted placeholder to a date in the given dialect
+func dateLiteralExpr(param, dbType string) string {
+	switch dbType {
+	case "postgres", "mysql":
+		return fmt.Sprintf("CAST('{{%s}}' AS DATE)", param)
+	default:
+		return fmt.Sprintf("date('{{%s}}')", param)
+	}
+}
+
+// sqliteOffsetModifier returns the SQLite datetime modifier that shifts a time in
+// the source zone to the report zone at the given instant, e.g. "+120 minutes"
+func sqliteOffsetModifier(source, report *time.Location, at time.Time) string {
+	_, srcOffset := at.In(source).Zone()
+	_, dstOffset := at.In(report).Zone()
+	return fmt.Sprintf("%+d minutes", (dstOffset-srcOffset)/60)
+}
+
+// dateParamNames are the params normalized to calendar dates in the report timezone
+var dateParamNames = []string{"start_date", "end_date"}
+
+// dateParamLayouts are accepted for date params, most specific first
+var dateParamLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// normalizeDateParams rewrites date params as YYYY-MM-DD in loc. Values carrying an
+// offset (e.g. 2024-03-01T23:30:00Z) are converted first; plain dates are taken as
+// already local. Unparseable values are left for the database to reject.
+func normalizeDateParams(params map[string]interface{}, loc *time.Location) map[string]interface{} {
+	if len(params) == 0 {
+		return params
+	}
+
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+
+	for _, name := range dateParamNames {
+		value, ok := out[name]
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case time.Time:
+			out[name] = v.In(loc).Format("2006-01-02")
+		case string:
+			for _, layout := range dateParamLayouts {
+				t, err := time.ParseInLocation(layout, v, loc)
+				if err == nil {
+					out[name] = t.In(loc).Format("2006-01-02")
+					break
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PreferenceService stores per-user defaults in the control plane
+type PreferenceService struct {
+	registry *datasource.Registry
+	db       *gorm.DB
+}
+
+// NewPreferenceService creates a new preference service
+func NewPreferenceService(registry *datasource.Registry, db *gorm.DB) *PreferenceService {
+	return &PreferenceService{registry: registry, db: db}
+}
+
+// GetPreferences returns a user's preferences; a user who never saved any
+// gets empty ones
+func (s *PreferenceService) GetPreferences(username string) (*store.UserPreference, error) {
+	if username == "" {
+		return nil, classErrorf(ErrValidation, "preferences need an authenticated user")
+	}
+	var prefs store.UserPreference
+	err := s.db.Where("username = ?", username).First(&prefs).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &store.UserPreference{Username: username}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load preferences: %w", err)
+	}
+	return &prefs, nil
+}
+
+// UpdatePreferences applies the fields set in req to a user's preferences
+func (s *PreferenceService) UpdatePreferences(username string, req store.UpdateUserPreferencesRequest) (*store.UserPreference, error) {
+	prefs, err := s.GetPreferences(username)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DefaultDatasource != nil {
+		id := strings.TrimSpace(*req.DefaultDatasource)
+		if id != "" {
+			if _, err := s.registry.GetDatasource(id); err != nil {
+				return nil, classErrorf(ErrValidation, "unknown default_datasource %q", id)
+			}
+		}
+		prefs.DefaultDatasource = id
+	}
+	if req.ChatModel != nil {
+		prefs.ChatModel = strings.TrimSpace(*req.ChatModel)
+	}
+	if req.Timezone != nil {
+		tz := strings.TrimSpace(*req.Timezone)
+		if _, err := loadReportLocation(tz); err != nil {
+			return nil, classErrorf(ErrValidation, "%w", err)
+		}
+		prefs.Timezone = tz
+	}
+	if req.DefaultRowLimit != nil {
+		prefs.DefaultRowLimit = *req.DefaultRowLimit
+	}
+	if req.UILayout != nil {
+		prefs.UILayout = nil
+		if layout := bytes.TrimSpace(*req.UILayout); !bytes.Equal(layout, []byte("null")) {
+			prefs.UILayout = layout
+		}
+	}
+	prefs.UpdatedAt = time.Now()
+
+	if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(prefs).Error; err != nil {
+		return nil, fmt.Errorf("failed to save preferences: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "User preferences updated", map[string]interface{}{
+		"user":               username,
+		"default_datasource": prefs.DefaultDatasource,
+		"chat_model":         prefs.ChatModel,
+		"timezone":           prefs.Timezone,
+		"default_row_limit":  prefs.DefaultRowLimit,
+	})
+	return prefs, nil
+}
+
+// userPreferences loads a user's preferences for services applying them.
+// Anonymous callers and lookup failures get none; failures are logged.
+func userPreferences(db *gorm.DB, username string) store.UserPreference {
+	var prefs store.UserPreference
+	if username == "" {
+		return prefs
+	}
+	err := db.Where("username = ?", username).First(&prefs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.LogWarn(logger.ServiceREST, "Failed to load user preferences", map[string]interface{}{
+			"user":  username,
+			"error": err.Error(),
+		})
+	}
+	return prefs
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/NubeDev/air/internal/datasource"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// ErrCostBudgetExceeded is returned when a run's dry-run scan estimate passes
+// warehouse_cost.max_run_bytes or what is left of max_daily_bytes
+var ErrCostBudgetExceeded = classErrorf(ErrSafetyBlocked, "estimated scan exceeds the warehouse cost budget")
+
+// costEstimateTimeout bounds the dry run before a report run
+const costEstimateTimeout = 30 * time.Second
+
+// bytesPerTiB is the unit warehouse_cost.price_per_tib prices
+const bytesPerTiB = 1 << 40
+
+// checkCostBudget dry-runs sqlText on a warehouse billed by bytes scanned and
+// refuses it when the estimate passes the per-run budget or the datasource's
+// remaining daily budget. Other datasources are not checked. A failed dry run
+// refuses the run, since the query would fail too.
+func (s *ReportsService) checkCostBudget(connector *datasource.DatasourceConnector, sqlText string) (*store.CostEstimate, error) {
+	if !connector.BillsByScan() {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), costEstimateTimeout)
+	defer cancel()
+
+	bytes, err := connector.EstimateScan(ctx, trimStatementEnd(sqlText))
+	if err != nil {
+		return nil, classErrorf(ErrValidation, "dry run failed: %w", err)
+	}
+	estimate := &store.CostEstimate{
+		DatasourceID:  connector.ID,
+		Bytes:         bytes,
+		Cost:          s.scanCost(bytes),
+		MaxRunBytes:   s.cost.MaxRunBytes,
+		MaxDailyBytes: s.cost.MaxDailyBytes,
+	}
+	if s.cost.MaxDailyBytes > 0 {
+		if estimate.DailyBytes, err = s.dailyScanBytes(connector.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case s.cost.MaxRunBytes > 0 && bytes > s.cost.MaxRunBytes:
+		estimate.Guidance = fmt.Sprintf("The query would scan %s, more than warehouse_cost.max_run_bytes (%s). Select fewer columns or filter on the partitioning column.",
+			formatBytes(bytes), formatBytes(s.cost.MaxRunBytes))
+	case s.cost.MaxDailyBytes > 0 && estimate.DailyBytes+bytes > s.cost.MaxDailyBytes:
+		estimate.Guidance = fmt.Sprintf("The query would scan %s, but %s of %s's daily budget of %s is used. Try again tomorrow (UTC) or narrow the query.",
+			formatBytes(bytes), formatBytes(estimate.DailyBytes), connector.ID, formatBytes(s.cost.MaxDailyBytes))
+	default:
+		return estimate, nil
+	}
+
+	estimate.Exceeded = true
+	logger.LogWarn(logger.ServiceREST, "Scan estimate exceeds warehouse cost budget", map[string]interface{}{
+		"datasource_id":   connector.ID,
+		"estimated_bytes": bytes,
+		"daily_bytes":     estimate.DailyBytes,
+		"max_run_bytes":   s.cost.MaxRunBytes,
+		"max_daily_bytes": s.cost.MaxDailyBytes,
+	})
+	return estimate, fmt.Errorf("%w: %s", ErrCostBudgetExceeded, estimate.Guidance)
+}
+
+// EstimateReportCost dry-runs a report with params, as RunReport would run
+// it, and returns its scan estimate against the budgets without running it
+func (s *ReportsService) EstimateReportCost(reportID uint, req store.RunReportRequest) (*store.CostEstimate, error) {
+	report, err := s.GetReportByID(reportID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "report not found: %w", err)
+	}
+	var reportVersion store.ReportVersion
+	if err := s.db.Scopes(approvedVersions).Where("report_id = ?", report.ID).Order("version DESC").First(&reportVersion).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "report %d has no approved versions", reportID)
+	}
+	prefs := userPreferences(s.db, req.User)
+	if report.Timezone == "" {
+		report.Timezone = prefs.Timezone
+	}
+	datasourceID, err := runDatasource(&reportVersion, req.DatasourceID, prefs)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := s.registry.GetDatasource(*datasourceID)
+	if err != nil {
+		return nil, classErrorf(ErrNotFound, "datasource not found: %w", err)
+	}
+	if !connector.BillsByScan() {
+		return nil, classErrorf(ErrValidation, "datasource %s is not billed by bytes scanned", *datasourceID)
+	}
+	if err := connector.Connected(); err != nil {
+		return nil, err
+	}
+	sqlText := extractSQLFromDef(reportVersion.DefJSON)
+	if sqlText == "" {
+		return nil, fmt.Errorf("report version def_json does not contain sql")
+	}
+	sqlPrepared, _, err := prepareReportSQL(report, connector, sqlText, req.Params, req.User, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	estimate, err := s.checkCostBudget(connector, sqlPrepared)
+	if estimate != nil {
+		// Exceeding a budget is the answer here, not a failure
+		return estimate, nil
+	}
+	return nil, err
+}
+
+// dailyScanBytes sums the bytes a datasource's report runs scanned since UTC
+// midnight; running runs count with their estimate
+func (s *ReportsService) dailyScanBytes(datasourceID string) (int64, error) {
+	var total int64
+	midnight := time.Now().UTC().Truncate(24 * time.Hour)
+	err := s.db.Model(&store.ReportRun{}).
+		Where("datasource_id = ? AND started_at >= ?", datasourceID, midnight).
+		Where("bytes_billed IS NOT NULL OR status = ?", "running").
+		Select("COALESCE(SUM(COALESCE(bytes_billed, estimated_bytes, 0)), 0)").
+		Scan(&total).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum today's scanned bytes: %w", err)
+	}
+	return total, nil
+}
+
+// scanCost prices bytes at warehouse_cost.price_per_tib
+func (s *ReportsService) scanCost(bytes int64) float64 {
+	return float64(bytes) / bytesPerTiB * s.cost.PricePerTiB
+}
+
+// formatBytes renders a byte count in binary units, e.g. 1.5 GiB
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit && exp < 5; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
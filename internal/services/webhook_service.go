@@ -0,0 +1,418 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/store"
+	"gorm.io/gorm"
+)
+
+// Lifecycle events delivered to webhook subscriptions
+const (
+	EventReportRunCompleted  = "report.run.completed"
+	EventReportRunFailed     = "report.run.failed"
+	EventSchemaDriftDetected = "schema.drift.detected"
+	EventDatasourceUnhealthy = "datasource.unhealthy"
+	EventAnalysisCompleted   = "analysis.completed"
+	EventSnapshotRefreshed   = "report.snapshot.refreshed"
+	EventReportStale         = "report.stale"
+	EventDataStale           = "data.stale"
+	eventWebhookPing         = "webhook.ping"
+)
+
+const (
+	webhookRetryPollInterval  = 15 * time.Second
+	webhookSignatureHeader    = "X-Air-Signature"
+	webhookTimestampHeader    = "X-Air-Timestamp"
+	webhookEventHeader        = "X-Air-Event"
+	webhookDeliveryHeader     = "X-Air-Delivery"
+	webhookMaxResponseLogSize = 1024
+)
+
+// WebhookEvents lists the event types a subscription may select
+var WebhookEvents = []string{
+	EventReportRunCompleted,
+	EventReportRunFailed,
+	EventSchemaDriftDetected,
+	EventDatasourceUnhealthy,
+	EventAnalysisCompleted,
+	EventSnapshotRefreshed,
+	EventReportStale,
+	EventDataStale,
+}
+
+// WebhookService persists subscriptions and delivers signed events with retries
+type WebhookService struct {
+	db        *gorm.DB
+	config    config.WebhooksConfig
+	client    *http.Client
+	pending   chan uint
+	listeners []func(event string, data map[string]interface{})
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(db *gorm.DB, cfg *config.Config) *WebhookService {
+	return &WebhookService{
+		db:      db,
+		config:  cfg.Webhooks,
+		client:  outboundHTTPClient(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivateTargets),
+		pending: make(chan uint, 256),
+	}
+}
+
+// Start runs the delivery worker. Deliveries left pending by a restart are
+// picked up by the retry poll.
+func (s *WebhookService) Start() {
+	if s == nil || !s.config.Enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(webhookRetryPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case id := <-s.pending:
+				s.attemptDelivery(id)
+			case <-ticker.C:
+				s.retryDueDeliveries()
+			}
+		}
+	}()
+}
+
+// Subscribe registers an in-process listener called for every emitted event,
+// regardless of whether outbound webhooks are enabled. Listeners must not block.
+func (s *WebhookService) Subscribe(fn func(event string, data map[string]interface{})) {
+	s.listeners = append(s.listeners, fn)
+}
+
+// Emit queues event for every active subscription that selected it. It is safe to
+// call on a nil service so emitters don't need to check whether webhooks are wired.
+func (s *WebhookService) Emit(event string, data map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	for _, fn := range s.listeners {
+		fn(event, data)
+	}
+	if !s.config.Enabled {
+		return
+	}
+
+	var subs []store.WebhookSubscription
+	if err := s.db.Where("active = ?", true).Find(&subs).Error; err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to load webhook subscriptions", err)
+		return
+	}
+
+	eventID := newEventID()
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":         eventID,
+		"event":      event,
+		"created_at": time.Now().UTC(),
+		"data":       data,
+	})
+	if err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to encode webhook payload", err, map[string]interface{}{
+			"event": event,
+		})
+		return
+	}
+
+	for _, sub := range subs {
+		if !subscribedTo(sub.Events, event) {
+			continue
+		}
+		delivery := store.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        eventID,
+			Event:          event,
+			PayloadJSON:    string(payload),
+			Status:         "pending",
+			NextAttemptAt:  time.Now(),
+			CreatedAt:      time.Now(),
+		}
+		if err := s.db.Create(&delivery).Error; err != nil {
+			logger.LogError(logger.ServiceREST, "Failed to record webhook delivery", err, map[string]interface{}{
+				"subscription_id": sub.ID,
+				"event":           event,
+			})
+			continue
+		}
+		s.enqueue(delivery.ID)
+	}
+}
+
+// enqueue hands a delivery to the worker without blocking the emitter; a full
+// queue falls back to the retry poll
+func (s *WebhookService) enqueue(id uint) {
+	select {
+	case s.pending <- id:
+	default:
+	}
+}
+
+func (s *WebhookService) retryDueDeliveries() {
+	var due []store.WebhookDelivery
+	if err := s.db.Where("status = ? AND next_attempt_at <= ?", "pending", time.Now()).
+		Order("next_attempt_at ASC").Limit(100).Find(&due).Error; err != nil {
+		logger.LogError(logger.ServiceREST, "Failed to load pending webhook deliveries", err)
+		return
+	}
+	for _, d := range due {
+		s.attemptDelivery(d.ID)
+	}
+}
+
+// attemptDelivery sends one delivery and schedules a retry with exponential backoff
+// on failure until max_attempts is reached
+func (s *WebhookService) attemptDelivery(id uint) {
+	var d store.WebhookDelivery
+	if err := s.db.First(&d, id).Error; err != nil || d.Status != "pending" {
+		return
+	}
+	var sub store.WebhookSubscription
+	if err := s.db.First(&sub, d.SubscriptionID).Error; err != nil {
+		s.db.Model(&d).Updates(map[string]interface{}{"status": "failed", "error_text": "subscription deleted"})
+		return
+	}
+
+	code, sendErr := s.send(sub, d)
+	attempts := d.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":      attempts,
+		"response_code": code,
+	}
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		updates["status"] = "succeeded"
+		updates["error_text"] = ""
+		updates["delivered_at"] = &now
+	case attempts >= s.config.MaxAttempts:
+		updates["status"] = "failed"
+		updates["error_text"] = sendErr.Error()
+	default:
+		updates["error_text"] = sendErr.Error()
+		updates["next_attempt_at"] = time.Now().Add(s.config.RetryDelay * time.Duration(1<<(attempts-1)))
+	}
+	s.db.Model(&d).Updates(updates)
+
+	if sendErr != nil {
+		logger.LogWarn(logger.ServiceREST, "Webhook delivery failed", map[string]interface{}{
+			"delivery_id": d.ID,
+			"event":       d.Event,
+			"attempt":     attempts,
+			"error":       sendErr.Error(),
+		})
+	}
+}
+
+// send posts the payload signed as HMAC-SHA256(secret, "<timestamp>.<body>")
+func (s *WebhookService) send(sub store.WebhookSubscription, d store.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body := []byte(d.PayloadJSON)
+
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, d.Event)
+	req.Header.Set(webhookDeliveryHeader, d.EventID)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(sub.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseLogSize))
+		return resp.StatusCode, fmt.Errorf("endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return resp.StatusCode, nil
+}
+
+// signWebhook returns the hex HMAC receivers recompute to verify a delivery
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateSubscription registers an endpoint; the secret is only returned here
+func (s *WebhookService) CreateSubscription(req store.CreateWebhookRequest) (*store.CreateWebhookResponse, error) {
+	if err := checkOutboundURL(req.URL, s.config.AllowPrivateTargets); err != nil {
+		return nil, err
+	}
+
+	for _, event := range req.Events {
+		if event != "*" && !isWebhookEvent(event) {
+			return nil, fmt.Errorf("unknown event %q; valid events: %s", event, strings.Join(WebhookEvents, ", "))
+		}
+	}
+	if len(req.Events) == 0 {
+		return nil, fmt.Errorf("at least one event is required")
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = newEventID() + newEventID()
+	}
+
+	sub := &store.WebhookSubscription{
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      strings.Join(req.Events, ","),
+		Description: req.Description,
+		Active:      true,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.db.Create(sub).Error; err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	logger.LogInfo(logger.ServiceREST, "Webhook subscription created", map[string]interface{}{
+		"id":     sub.ID,
+		"events": sub.Events,
+	})
+
+	return &store.CreateWebhookResponse{WebhookSubscription: sub, Secret: secret}, nil
+}
+
+// ListSubscriptions returns all webhook subscriptions
+func (s *WebhookService) ListSubscriptions() ([]store.WebhookSubscription, error) {
+	var subs []store.WebhookSubscription
+	if err := s.db.Order("id ASC").Find(&subs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return subs, nil
+}
+
+// DeleteSubscription removes a subscription and its delivery log
+func (s *WebhookService) DeleteSubscription(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&store.WebhookSubscription{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete webhook: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return classErrorf(ErrNotFound, "webhook not found")
+		}
+		return tx.Where("subscription_id = ?", id).Delete(&store.WebhookDelivery{}).Error
+	})
+}
+
+// ListDeliveries returns the most recent deliveries for a subscription, optionally
+// filtered by status
+func (s *WebhookService) ListDeliveries(subscriptionID uint, status string, limit int) ([]store.WebhookDelivery, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := s.db.Where("subscription_id = ?", subscriptionID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []store.WebhookDelivery
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver resets a delivery so the worker sends it again
+func (s *WebhookService) Redeliver(deliveryID uint) error {
+	result := s.db.Model(&store.WebhookDelivery{}).Where("id = ?", deliveryID).Updates(map[string]interface{}{
+		"status":          "pending",
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to reset delivery: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return classErrorf(ErrNotFound, "delivery not found")
+	}
+	s.enqueue(deliveryID)
+	return nil
+}
+
+// Ping sends a test event to a single subscription
+func (s *WebhookService) Ping(subscriptionID uint) (*store.WebhookDelivery, error) {
+	var sub store.WebhookSubscription
+	if err := s.db.First(&sub, subscriptionID).Error; err != nil {
+		return nil, classErrorf(ErrNotFound, "webhook not found")
+	}
+
+	eventID := newEventID()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":         eventID,
+		"event":      eventWebhookPing,
+		"created_at": time.Now().UTC(),
+		"data":       map[string]interface{}{"subscription_id": sub.ID},
+	})
+	delivery := &store.WebhookDelivery{
+		SubscriptionID: sub.ID,
+		EventID:        eventID,
+		Event:          eventWebhookPing,
+		PayloadJSON:    string(payload),
+		Status:         "pending",
+		NextAttemptAt:  time.Now(),
+		CreatedAt:      time.Now(),
+	}
+	if err := s.db.Create(delivery).Error; err != nil {
+		return nil, fmt.Errorf("failed to record delivery: %w", err)
+	}
+
+	// Deliver synchronously so the caller sees the endpoint's response
+	s.attemptDelivery(delivery.ID)
+	if err := s.db.First(delivery, delivery.ID).Error; err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func subscribedTo(events, event string) bool {
+	for _, e := range strings.Split(events, ",") {
+		if e = strings.TrimSpace(e); e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -49,19 +50,126 @@ type SchemaNote struct {
 	MDHash       string    `gorm:"not null" json:"md_hash"` // hash for deduplication
 	CreatedAt    time.Time `json:"created_at"`
 
+	// Curation; carried to the object's next note when it is relearned
+	CuratedMD  string     `gorm:"type:text" json:"curated_md,omitempty"` // replaces MD in prompts
+	Annotation string     `gorm:"type:text" json:"annotation,omitempty"` // appended to the note in prompts
+	Pinned     bool       `json:"pinned"`                                // listed first in prompts
+	Hidden     bool       `json:"hidden"`                                // left out of prompts
+	CuratedBy  string     `json:"curated_by,omitempty"`
+	CuratedAt  *time.Time `json:"curated_at,omitempty"`
+
 	// Relationships
 	Datasource Datasource `gorm:"foreignKey:DatasourceID" json:"datasource,omitempty"`
 }
 
+// SchemaNoteRevision records one curation change to an object's schema note,
+// with the curation as it stood afterwards
+type SchemaNoteRevision struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	NoteID       uint      `gorm:"index;not null" json:"note_id"`
+	DatasourceID string    `gorm:"index:idx_schema_note_revision_object;not null" json:"datasource_id"`
+	Object       string    `gorm:"index:idx_schema_note_revision_object;not null" json:"object"`
+	Changes      string    `gorm:"not null" json:"changes"` // comma-separated: md, annotation, pinned, hidden
+	Author       string    `json:"author,omitempty"`
+	CuratedMD    string    `gorm:"type:text" json:"curated_md,omitempty"`
+	Annotation   string    `gorm:"type:text" json:"annotation,omitempty"`
+	Pinned       bool      `json:"pinned"`
+	Hidden       bool      `json:"hidden"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ContinuousAggregate is a TimescaleDB continuous aggregate discovered at learn
+// time. Time-series queries over its hypertable can read the pre-bucketed view.
+type ContinuousAggregate struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	DatasourceID   string    `gorm:"index;not null" json:"datasource_id"`
+	ViewName       string    `gorm:"not null" json:"view_name"`       // schema-qualified view
+	HypertableName string    `gorm:"not null" json:"hypertable_name"` // unqualified source table
+	TimeColumn     string    `gorm:"not null" json:"time_column"`     // hypertable column that is bucketed
+	BucketColumn   string    `gorm:"not null" json:"bucket_column"`   // view column holding the bucket start
+	BucketSeconds  int64     `json:"bucket_seconds"`
+	GroupColumns   string    `json:"group_columns"`                    // comma-separated
+	AggregatesJSON string    `gorm:"type:text" json:"aggregates_json"` // {"sum(kwh)": "kwh_sum"}
+	DiscoveredAt   time.Time `json:"discovered_at"`
+}
+
+// ColumnAnnotation carries units and descriptive metadata for a column so
+// prompts and report outputs can interpret raw values (e.g. milli-degrees).
+// Source is "user" for manual annotations or "learn" for inferred ones; learn
+// never overwrites a user annotation.
+type ColumnAnnotation struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DatasourceID string    `gorm:"uniqueIndex:idx_column_annotation;not null" json:"datasource_id"`
+	Object       string    `gorm:"uniqueIndex:idx_column_annotation;not null" json:"object"`
+	ColumnName   string    `gorm:"uniqueIndex:idx_column_annotation;not null" json:"column"`
+	Unit         string    `json:"unit,omitempty"` // e.g. "kWh", "°C", "m°C"
+	DisplayName  string    `json:"display_name,omitempty"`
+	Description  string    `gorm:"type:text" json:"description,omitempty"`
+	Source       string    `gorm:"not null;default:'user'" json:"source"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// PIIColumn tags a column that learning found to hold personal data, by its
+// name or its sampled values. Its values are kept out of prompts and masked in
+// run results unless an admin allows the column.
+type PIIColumn struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	DatasourceID string     `gorm:"uniqueIndex:idx_pii_column;not null" json:"datasource_id"`
+	Object       string     `gorm:"uniqueIndex:idx_pii_column;not null" json:"object"`
+	ColumnName   string     `gorm:"uniqueIndex:idx_pii_column;not null" json:"column"`
+	Kind         string     `gorm:"not null" json:"kind"`        // email | phone | national_id
+	DetectedBy   string     `gorm:"not null" json:"detected_by"` // name | values
+	Allowed      bool       `gorm:"default:false" json:"allowed"`
+	AllowedBy    string     `json:"allowed_by,omitempty"`
+	AllowedAt    *time.Time `json:"allowed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// DataDictionary is one version of a datasource's human-readable data
+// dictionary in Markdown. Generating one or editing it adds a version, so
+// earlier text is never lost.
+type DataDictionary struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DatasourceID string    `gorm:"uniqueIndex:idx_data_dictionary_version;not null" json:"datasource_id"`
+	Version      int       `gorm:"uniqueIndex:idx_data_dictionary_version;not null" json:"version"`
+	MD           string    `gorm:"type:text" json:"md"`
+	Source       string    `gorm:"not null;default:'generated'" json:"source"` // "generated" or "user"
+	Model        string    `json:"model,omitempty"`                            // model that generated it
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// GlossaryTerm maps a business term ("revenue", "site") to the table, column or
+// expression it means for one datasource. Synonyms resolve to the same target.
+type GlossaryTerm struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	DatasourceID string    `gorm:"uniqueIndex:idx_glossary_term;not null" json:"datasource_id"`
+	Term         string    `gorm:"uniqueIndex:idx_glossary_term;not null" json:"term"` // stored lower-case
+	Synonyms     string    `json:"synonyms,omitempty"`                                 // comma-separated
+	Object       string    `json:"object,omitempty"`                                   // table or view
+	Expression   string    `gorm:"type:text" json:"expression,omitempty"`              // column or SQL expression
+	Description  string    `gorm:"type:text" json:"description,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
 // Report represents a saved report definition
 type Report struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Key       string    `gorm:"uniqueIndex;not null" json:"key"`
 	Title     string    `gorm:"not null" json:"title"`
 	Owner     string    `json:"owner"`
+	Folder    string    `gorm:"index" json:"folder,omitempty"`
+	Tags      string    `json:"tags,omitempty"`     // comma-separated
+	Timezone  string    `json:"timezone,omitempty"` // IANA zone for built-in date variables; UTC when empty
 	Archived  bool      `gorm:"default:false" json:"archived"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Set for the calling user in report lists
+	Favorite       bool       `gorm:"-" json:"favorite,omitempty"`
+	LastAccessedAt *time.Time `gorm:"-" json:"last_accessed_at,omitempty"`
 }
 
 // ReportVersion represents a versioned report definition
@@ -69,17 +177,113 @@ type ReportVersion struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
 	ReportID       uint      `gorm:"not null" json:"report_id"`
 	Version        int       `gorm:"not null" json:"version"`
-	ScopeVersionID uint      `gorm:"not null" json:"scope_version_id"`
-	DatasourceID   *string   `json:"datasource_id"` // null for portable reports
+	ScopeVersionID *uint     `json:"scope_version_id"` // null for hand-written SQL reports
+	DatasourceID   *string   `json:"datasource_id"`    // null for portable reports
 	DefJSON        string    `gorm:"type:text" json:"def_json"`
 	Checksum       string    `gorm:"not null" json:"checksum"`
-	Status         string    `gorm:"default:'draft'" json:"status"` // "draft", "active", "archived"
+	Status         string    `gorm:"default:'draft'" json:"status"` // "draft", "active", "archived"; regenerated versions are "proposed", then "active" or "rejected"
+	CreatedBy      string    `json:"created_by,omitempty"`          // user who wrote or regenerated the SQL; they cannot approve it
 	CreatedAt      time.Time `json:"created_at"`
 
+	// ProposedFrom is the stale version a regenerated, proposed version replaces
+	ProposedFrom *uint `json:"proposed_from,omitempty"`
+
+	// Set when schema drift drops a table or column the SQL reads; scheduled
+	// snapshot refreshes are blocked until the version is revalidated
+	StaleAt     *time.Time `json:"stale_at,omitempty"`
+	StaleReason string     `gorm:"type:text" json:"stale_reason,omitempty"`
+
+	// Set when a reviewer approves or rejects the SQL; datasources with
+	// require_approval run only approved versions
+	ReviewedBy    string     `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	ReviewComment string     `gorm:"type:text" json:"review_comment,omitempty"`
+
 	// Relationships
-	Report       Report       `gorm:"foreignKey:ReportID" json:"report,omitempty"`
-	ScopeVersion ScopeVersion `gorm:"foreignKey:ScopeVersionID" json:"scope_version,omitempty"`
-	Datasource   *Datasource  `gorm:"foreignKey:DatasourceID" json:"datasource,omitempty"`
+	Report       Report        `gorm:"foreignKey:ReportID" json:"report,omitempty"`
+	ScopeVersion *ScopeVersion `gorm:"foreignKey:ScopeVersionID" json:"scope_version,omitempty"`
+	Datasource   *Datasource   `gorm:"foreignKey:DatasourceID" json:"datasource,omitempty"`
+}
+
+// LineageEdge links a report version to a source column its SQL reads.
+// Column is "*" when the SQL selects every column of the table.
+type LineageEdge struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ReportID        uint      `gorm:"not null;index" json:"report_id"`
+	ReportVersionID uint      `gorm:"not null;index" json:"report_version_id"`
+	DatasourceID    string    `gorm:"index" json:"datasource_id"` // empty for portable reports
+	SourceTable     string    `gorm:"not null;index" json:"table"`
+	SourceColumn    string    `gorm:"not null" json:"column"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ReportValidation is a validate run of a report version on the sandbox of a
+// production datasource, with its result shape checked against expectations
+type ReportValidation struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ReportID        uint      `gorm:"not null;index" json:"report_id"`
+	ReportVersionID uint      `gorm:"not null;index" json:"report_version_id"`
+	DatasourceID    string    `gorm:"not null" json:"datasource_id"` // production datasource the version is validated for
+	SandboxID       string    `gorm:"not null" json:"sandbox_id"`
+	Status          string    `gorm:"not null" json:"status"` // "passed", "failed"
+	SQLText         string    `gorm:"type:text" json:"sql_text"`
+	ColumnsJSON     string    `gorm:"type:text" json:"columns_json"` // result column names
+	ExpectJSON      string    `gorm:"type:text" json:"expect_json"`  // the shape expectation checked
+	RowCount        int       `json:"row_count"`
+	Problems        string    `gorm:"type:text" json:"problems,omitempty"` // why the validation failed, "; "-separated
+	User            string    `json:"user,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// FreshnessMonitor watches how old the newest row of a table is. The
+// scheduler reads MAX(TimeColumn) and marks the table stale once that is
+// older than MaxAge; runs of reports reading a stale table are flagged.
+type FreshnessMonitor struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	DatasourceID string     `gorm:"not null;index" json:"datasource_id"`
+	SourceTable  string     `gorm:"not null" json:"table"`
+	TimeColumn   string     `gorm:"not null" json:"column"`
+	MaxAge       string     `gorm:"not null" json:"max_age"`         // Go duration, e.g. "2h"
+	Status       string     `gorm:"default:'pending'" json:"status"` // "pending", "fresh", "stale", "error"
+	LatestAt     *time.Time `json:"latest_at,omitempty"`             // MAX(TimeColumn) at the last check
+	CheckedAt    *time.Time `json:"checked_at,omitempty"`
+	StaleSince   *time.Time `json:"stale_since,omitempty"` // first check that found the table stale
+	ErrorText    string     `gorm:"type:text" json:"error_text,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ReportAssertion is a data test a report's results must pass. It is checked
+// after every run and snapshot refresh.
+type ReportAssertion struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ReportID     uint      `gorm:"not null;index" json:"report_id"`
+	Name         string    `gorm:"not null" json:"name"`
+	Kind         string    `gorm:"not null" json:"kind"`                     // "row_count", "not_null", "reference"
+	ColumnName   string    `json:"column,omitempty"`                         // not_null, reference: the result column checked
+	Op           string    `json:"op,omitempty"`                             // row_count: ">", ">=", "<", "<=", "=" or "!="
+	Value        float64   `json:"value"`                                    // row_count: the count compared with
+	ReferenceSQL string    `gorm:"type:text" json:"reference_sql,omitempty"` // reference: its first value is compared with the column's total
+	Tolerance    float64   `json:"tolerance,omitempty"`                      // reference: allowed relative difference, e.g. 0.01 for 1%
+	Critical     bool      `json:"critical"`                                 // a failure fails snapshot refreshes
+	CreatedAt    time.Time `json:"created_at"`
+
+	// LastResult is the assertion's most recent result; resolved on read
+	LastResult *ReportAssertionResult `gorm:"-" json:"last_result,omitempty"`
+}
+
+// ReportAssertionResult records whether an assertion held for a run or a
+// snapshot refresh
+type ReportAssertionResult struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	AssertionID uint      `gorm:"not null;index" json:"assertion_id"`
+	ReportID    uint      `gorm:"not null;index" json:"report_id"`
+	RunID       *uint     `gorm:"index" json:"run_id,omitempty"` // null for snapshot refreshes
+	Name        string    `json:"name"`
+	Critical    bool      `json:"critical"`
+	Passed      bool      `json:"passed"`
+	Detail      string    `gorm:"type:text" json:"detail"` // what was measured, or why it could not be
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 // ReportRun represents an execution of a report
@@ -91,11 +295,28 @@ type ReportRun struct {
 	ParamsJSON      string     `gorm:"type:text" json:"params_json"`
 	SQLText         string     `gorm:"type:text" json:"sql_text"`
 	RowCount        int        `json:"row_count"`
-	Results         string     `gorm:"type:text" json:"results"` // JSON array of query results
+	Results         string     `gorm:"type:text" json:"results"` // ResultSet JSON; older runs hold an array of row objects
 	StartedAt       time.Time  `json:"started_at"`
 	FinishedAt      *time.Time `json:"finished_at"`
 	Status          string     `gorm:"default:'running'" json:"status"` // "running", "completed", "failed"
 	ErrorText       string     `gorm:"type:text" json:"error_text"`
+	EstimatedRows   *int64     `json:"estimated_rows,omitempty"`  // pre-execution estimate, when safety.row_estimate is enabled
+	EstimatedBytes  *int64     `json:"estimated_bytes,omitempty"` // dry-run scan estimate, on warehouses billed by bytes scanned
+	BytesBilled     *int64     `json:"bytes_billed,omitempty"`    // bytes the warehouse billed the run
+	Cost            *float64   `json:"cost,omitempty"`            // BytesBilled at warehouse_cost.price_per_tib
+	Warnings        string     `gorm:"type:text" json:"warnings,omitempty"`
+	TraceID         string     `gorm:"index" json:"trace_id"`       // correlation ID, also sent to the database as /* air_run:<id> */
+	CoalescedWith   string     `json:"coalesced_with,omitempty"`    // trace ID of the identical in-flight run whose execution this run shared
+	RunAs           string     `json:"run_as,omitempty"`            // datasource credential the SQL ran as; the datasource's own when empty
+	Truncated       bool       `json:"truncated"`                   // results stop at ResultLimit instead of holding every row
+	TruncatedBy     string     `json:"truncated_by,omitempty"`      // "rows" or "bytes"
+	ResultLimit     int        `json:"result_limit,omitempty"`      // the row or byte limit that was applied
+	DataStale       bool       `json:"data_stale"`                  // a table the report reads is past its freshness max age
+	DataAsOf        *time.Time `json:"data_as_of,omitempty"`        // newest row of the stalest table read, when DataStale
+	ResultsPurgedAt *time.Time `json:"results_purged_at,omitempty"` // results and samples removed by retention; metadata kept
+
+	// Columns lists annotations for result columns; resolved on read, not stored
+	Columns []ColumnAnnotation `gorm:"-" json:"columns,omitempty"`
 
 	// Relationships
 	Report        Report        `gorm:"foreignKey:ReportID" json:"report,omitempty"`
@@ -103,6 +324,117 @@ type ReportRun struct {
 	Datasource    Datasource    `gorm:"foreignKey:DatasourceID" json:"datasource,omitempty"`
 }
 
+// RunView selects the parts of a ReportRun a response carries. Fields names
+// top-level columns (all when empty); Include names the relations report,
+// report_version and datasource and the computed columns field.
+type RunView struct {
+	Fields  []string
+	Include []string
+}
+
+// ReportMaterialization marks a report version for periodic execution into a
+// snapshot table so reads don't hit the production datasource
+type ReportMaterialization struct {
+	ID                   uint       `gorm:"primaryKey" json:"id"`
+	ReportID             uint       `gorm:"uniqueIndex;not null" json:"report_id"`
+	ReportVersionID      uint       `gorm:"not null" json:"report_version_id"`
+	SnapshotDatasourceID string     `gorm:"not null" json:"snapshot_datasource_id"`
+	SnapshotTable        string     `gorm:"not null" json:"snapshot_table"`
+	ParamsJSON           string     `gorm:"type:text" json:"params_json"`
+	RefreshInterval      string     `gorm:"not null" json:"refresh_interval"` // Go duration, e.g. "15m"
+	Status               string     `gorm:"default:'pending'" json:"status"`  // "pending", "refreshing", "fresh", "failed", "blocked"
+	ErrorText            string     `gorm:"type:text" json:"error_text,omitempty"`
+	RowCount             int        `json:"row_count"`
+	RefreshedAt          *time.Time `json:"refreshed_at"`
+	NextRefreshAt        time.Time  `gorm:"index" json:"next_refresh_at"`
+	FenceToken           int64      `gorm:"default:0" json:"-"` // lock token of the last refresh that wrote
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// WebhookSubscription represents an external endpoint receiving lifecycle events
+type WebhookSubscription struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	URL         string    `gorm:"not null" json:"url"`
+	Secret      string    `gorm:"not null" json:"-"`      // HMAC-SHA256 signing key
+	Events      string    `gorm:"not null" json:"events"` // comma-separated event types, "*" for all
+	Description string    `json:"description,omitempty"`
+	Active      bool      `gorm:"default:true" json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery records one event sent to one subscription, including retries
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint       `gorm:"index;not null" json:"subscription_id"`
+	EventID        string     `gorm:"index;not null" json:"event_id"`
+	Event          string     `gorm:"index;not null" json:"event"`
+	PayloadJSON    string     `gorm:"type:text" json:"payload_json"`
+	Status         string     `gorm:"index;default:'pending'" json:"status"` // "pending", "succeeded", "failed"
+	Attempts       int        `json:"attempts"`
+	ResponseCode   int        `json:"response_code,omitempty"`
+	ErrorText      string     `gorm:"type:text" json:"error_text,omitempty"`
+	NextAttemptAt  time.Time  `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ReportNotification posts run summaries for one report to a Slack or Teams channel
+type ReportNotification struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ReportID   uint      `gorm:"index;not null" json:"report_id"`
+	Type       string    `gorm:"not null" json:"type"`        // "slack" or "teams"
+	WebhookURL string    `gorm:"not null" json:"webhook_url"` // incoming webhook URL
+	Events     string    `gorm:"not null" json:"events"`      // comma-separated event types
+	Template   string    `gorm:"type:text" json:"template,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// HistoryCursor tracks how far one point of a history source has been ingested
+type HistoryCursor struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Source    string     `gorm:"uniqueIndex:idx_history_cursor;not null" json:"source"`
+	PointID   string     `gorm:"uniqueIndex:idx_history_cursor;not null" json:"point_id"`
+	Unit      string     `json:"unit,omitempty"`
+	FirstTS   *time.Time `json:"first_ts,omitempty"`
+	LastTS    *time.Time `json:"last_ts,omitempty"` // newest sample written; the next read starts after it
+	RowCount  int64      `json:"row_count"`
+	Status    string     `gorm:"default:'pending'" json:"status"` // "pending", "ok", "failed"
+	ErrorText string     `gorm:"type:text" json:"error_text,omitempty"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+}
+
+// ReportBatch represents one batch run of several reports with shared params
+type ReportBatch struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	ParamsJSON   string     `gorm:"type:text" json:"params_json"`
+	DatasourceID *string    `json:"datasource_id,omitempty"`
+	Status       string     `gorm:"default:'running'" json:"status"` // "running", "completed", "partial", "failed"
+	Total        int        `json:"total"`
+	Succeeded    int        `json:"succeeded"`
+	Failed       int        `json:"failed"`
+	CreatedAt    time.Time  `json:"created_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+
+	// Relationships
+	Items []ReportBatchItem `gorm:"foreignKey:BatchID" json:"items,omitempty"`
+}
+
+// ReportBatchItem tracks a single report within a batch run
+type ReportBatchItem struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	BatchID    uint       `gorm:"not null;index" json:"batch_id"`
+	ReportID   uint       `gorm:"not null" json:"report_id"`
+	ReportKey  string     `gorm:"not null" json:"report_key"`
+	RunID      *uint      `json:"run_id"`
+	Status     string     `gorm:"default:'pending'" json:"status"` // "pending", "running", "completed", "failed"
+	RowCount   int        `json:"row_count"`
+	ErrorText  string     `gorm:"type:text" json:"error_text,omitempty"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
 // ReportSample represents sample rows from a report run
 type ReportSample struct {
 	RunID   uint   `gorm:"primaryKey" json:"run_id"`
@@ -121,12 +453,88 @@ type ReportAnalysis struct {
 	RubricVersion string    `gorm:"not null" json:"rubric_version"`
 	VerdictJSON   string    `gorm:"type:text" json:"verdict_json"`
 	AnalysisMD    string    `gorm:"type:text" json:"analysis_md"`
+	TraceID       string    `gorm:"index" json:"trace_id,omitempty"` // the analyzed run's trace ID
 	CreatedAt     time.Time `json:"created_at"`
 
 	// Relationships
 	Run ReportRun `gorm:"foreignKey:RunID" json:"run,omitempty"`
 }
 
+// FileAnalysis is an AI analysis of an uploaded file requested over the
+// WebSocket, kept so it can be revisited without running the model again
+type FileAnalysis struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UserID          string    `gorm:"index" json:"user_id"`
+	FileID          string    `gorm:"index;not null" json:"file_id"`
+	Query           string    `gorm:"type:text" json:"query"`
+	Model           string    `json:"model"`
+	Analysis        string    `gorm:"type:text" json:"analysis"`
+	InsightsJSON    string    `gorm:"type:text" json:"-"`
+	SuggestionsJSON string    `gorm:"type:text" json:"-"`
+	Insights        []string  `gorm:"-" json:"insights"`
+	Suggestions     []string  `gorm:"-" json:"suggestions"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// RunFeedback is a user's rating of a run's generated SQL or of an analysis,
+// tied to the report/scope version and rubric that produced it so feedback
+// pairs can be exported for prompt tuning
+type RunFeedback struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	RunID           uint      `gorm:"index;not null" json:"run_id"`
+	Target          string    `gorm:"not null" json:"target"` // "sql" or "analysis"
+	AnalysisID      *uint     `json:"analysis_id,omitempty"`
+	Rating          int       `gorm:"not null" json:"rating"` // 1 thumbs up, -1 thumbs down
+	CorrectedSQL    string    `gorm:"type:text" json:"corrected_sql,omitempty"`
+	Comment         string    `gorm:"type:text" json:"comment,omitempty"`
+	User            string    `json:"user,omitempty"`
+	ReportVersionID uint      `json:"report_version_id"`
+	ScopeVersionID  *uint     `json:"scope_version_id,omitempty"`
+	RubricVersion   string    `json:"rubric_version,omitempty"`
+	ModelUsed       string    `json:"model_used,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// SQLExample is a verified question -> SQL pair for one datasource, used as a
+// few-shot example when generating SQL. Source is "accepted" (thumbs-up run),
+// "correction" (user-corrected SQL) or "manual".
+type SQLExample struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	DatasourceID   string    `gorm:"uniqueIndex:idx_sql_example;not null" json:"datasource_id"`
+	Checksum       string    `gorm:"uniqueIndex:idx_sql_example;not null" json:"-"` // question+SQL dedupe key
+	Question       string    `gorm:"type:text;not null" json:"question"`
+	SQL            string    `gorm:"type:text;not null" json:"sql"`
+	Source         string    `gorm:"not null" json:"source"`
+	RunID          *uint     `json:"run_id,omitempty"`
+	EmbeddingModel string    `json:"embedding_model,omitempty"`
+	EmbeddingJSON  string    `gorm:"type:text" json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AITrace records one model call made while building IR, generating SQL or
+// analyzing a run: the exact prompt, model and sampling options sent and the
+// raw response, so nondeterministic failures can be inspected and replayed.
+// Chat calls store Messages; completion calls store Prompt.
+type AITrace struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Operation      string    `gorm:"index;not null" json:"operation"` // build_ir | generate_sql | analyze_run
+	CallType       string    `gorm:"not null" json:"call_type"`       // chat | generate | http
+	Provider       string    `json:"provider"`                        // ollama | openai | http
+	Model          string    `json:"model"`
+	MessagesJSON   string    `gorm:"type:text" json:"messages_json,omitempty"`
+	Prompt         string    `gorm:"type:text" json:"prompt,omitempty"`
+	OptionsJSON    string    `gorm:"type:text" json:"options_json,omitempty"`
+	Response       string    `gorm:"type:text" json:"response"`
+	Error          string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMS     int64     `json:"duration_ms"`
+	DatasourceID   string    `gorm:"index" json:"datasource_id,omitempty"`
+	ScopeVersionID *uint     `gorm:"index" json:"scope_version_id,omitempty"`
+	RunID          *uint     `gorm:"index" json:"run_id,omitempty"`
+	ReplayOfID     *uint     `gorm:"index" json:"replay_of_id,omitempty"`
+	Username       string    `gorm:"index" json:"username,omitempty"` // authenticated caller; empty for background work
+	CreatedAt      time.Time `json:"created_at"`
+}
+
 // Session represents a file-based AI learning session
 type Session struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
@@ -135,10 +543,20 @@ type Session struct {
 	Status         string    `gorm:"default:'active'" json:"status"` // "active", "completed", "archived"
 	DatasourceType string    `gorm:"default:'file'" json:"datasource_type"`
 	Options        string    `gorm:"type:text" json:"options"` // JSON string
+	ActiveDataset  string    `json:"active_dataset"`           // uploaded file chat questions are answered from
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+// SessionDataset is an uploaded file loaded into a session's workspace.
+// Questions about the session can span every loaded file.
+type SessionDataset struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	SessionID uint      `gorm:"not null;uniqueIndex:idx_session_dataset" json:"session_id"`
+	FileID    string    `gorm:"not null;uniqueIndex:idx_session_dataset" json:"file_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // GeneratedReport represents a reusable API generated from a successful analysis
 type GeneratedReport struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
@@ -172,6 +590,101 @@ type ReportExecution struct {
 	Report GeneratedReport `gorm:"foreignKey:ReportID" json:"report,omitempty"`
 }
 
+// UserPreference holds one user's defaults. Chat, report runs and the CLI
+// fall back to them when a request does not say otherwise.
+type UserPreference struct {
+	Username          string          `gorm:"primaryKey" json:"username"`
+	DefaultDatasource string          `json:"default_datasource,omitempty"`         // used when neither the request nor the report names one
+	ChatModel         string          `json:"chat_model,omitempty"`                 // model chat completions use instead of the configured one
+	Timezone          string          `json:"timezone,omitempty"`                   // IANA zone for reports that set none
+	DefaultRowLimit   int             `json:"default_row_limit,omitempty"`          // caps stored run rows below the datasource limit
+	UILayout          json.RawMessage `gorm:"type:text" json:"ui_layout,omitempty"` // opaque JSON kept for the UI
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+// SavedPrompt is a reusable chat question such as "monthly energy anomaly
+// check on {{site}}". Its {{placeholders}} are filled in when it runs.
+// Shared prompts are listed for every user; only the owner changes them.
+type SavedPrompt struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Owner       string    `gorm:"uniqueIndex:idx_saved_prompt_name;not null" json:"owner"`
+	Name        string    `gorm:"uniqueIndex:idx_saved_prompt_name;not null" json:"name"`
+	Description string    `json:"description,omitempty"`
+	Text        string    `gorm:"type:text;not null" json:"text"`
+	Params      []string  `gorm:"-" json:"params"` // placeholder names in Text
+	Shared      bool      `gorm:"default:false" json:"shared"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ReportAccess is how one user uses a report: whether it is a favorite and
+// when it was last opened or run. It orders /v1/reports/recent and the
+// relevance sort of report lists.
+type ReportAccess struct {
+	Username       string     `gorm:"primaryKey" json:"username"`
+	ReportID       uint       `gorm:"primaryKey;autoIncrement:false;index" json:"report_id"`
+	Favorite       bool       `gorm:"default:false" json:"favorite"`
+	FavoritedAt    *time.Time `json:"favorited_at,omitempty"`
+	AccessCount    int        `gorm:"default:0" json:"access_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+}
+
+// ParamEnumCache holds the distinct values of a column that report params
+// marked "x-air-enum": "distinct" offer as their enum. Values are refreshed
+// on a schedule rather than queried per form.
+type ParamEnumCache struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	DatasourceID string     `gorm:"uniqueIndex:idx_param_enum;not null" json:"datasource_id"`
+	SourceTable  string     `gorm:"uniqueIndex:idx_param_enum;not null" json:"table"`
+	SourceColumn string     `gorm:"uniqueIndex:idx_param_enum;not null" json:"column"`
+	ValuesJSON   string     `gorm:"type:text" json:"-"`
+	Truncated    bool       `json:"truncated"` // more distinct values than param_enums.max_values; no enum is offered
+	RefreshedAt  *time.Time `json:"refreshed_at,omitempty"`
+	ErrorText    string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ReportSummaryTemplate overrides the template a report's run summaries are
+// rendered with, per format
+type ReportSummaryTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ReportID  uint      `gorm:"uniqueIndex:idx_summary_template;not null" json:"report_id"`
+	Format    string    `gorm:"uniqueIndex:idx_summary_template;not null" json:"format"` // "markdown" or "html"
+	Template  string    `gorm:"type:text;not null" json:"template"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ShareLink grants read-only access to a report's results without an account,
+// through a signed, expiring token
+type ShareLink struct {
+	ID             uint                   `gorm:"primaryKey" json:"id"`
+	ReportID       uint                   `gorm:"index;not null" json:"report_id"`
+	RunID          *uint                  `json:"run_id,omitempty"` // the shared run; nil shares the live report
+	ParamsJSON     string                 `gorm:"type:text" json:"-"`
+	Params         map[string]interface{} `gorm:"-" json:"params,omitempty"` // frozen params of a live link
+	DatasourceID   string                 `json:"datasource_id,omitempty"`
+	LastRunID      *uint                  `json:"last_run_id,omitempty"` // a live link's newest run
+	CreatedBy      string                 `json:"created_by"`
+	ExpiresAt      time.Time              `json:"expires_at"`
+	RevokedAt      *time.Time             `json:"revoked_at,omitempty"`
+	RevokedBy      string                 `json:"revoked_by,omitempty"`
+	AccessCount    int                    `json:"access_count"`
+	LastAccessedAt *time.Time             `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// ShareAccess logs one request made with a share link
+type ShareAccess struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ShareLinkID uint      `gorm:"index;not null" json:"share_link_id"`
+	Endpoint    string    `json:"endpoint"` // "link", "data" or "summary"
+	Outcome     string    `json:"outcome"`  // "ok", "expired", "revoked" or "error"
+	RemoteAddr  string    `json:"remote_addr"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	AccessedAt  time.Time `gorm:"index" json:"accessed_at"`
+}
+
 // ============================================================================
 // API Request/Response Models
 // ============================================================================
@@ -180,18 +693,27 @@ type ReportExecution struct {
 type HealthResponse struct {
 	Status      string `json:"status"`
 	AuthEnabled bool   `json:"auth_enabled"`
+	LocalOnly   bool   `json:"local_only"`
 	Datasources int    `json:"datasources"`
 }
 
 // DatasourceResponse represents a datasource in API responses
 type DatasourceResponse struct {
-	ID           string    `json:"id"`
-	Kind         string    `json:"kind"`
-	DisplayName  string    `json:"display_name"`
-	IsDefault    bool      `json:"is_default"`
-	HealthStatus string    `json:"health_status"`
-	LastHealth   time.Time `json:"last_health"`
-	Error        string    `json:"error,omitempty"`
+	ID           string     `json:"id"`
+	Kind         string     `json:"kind"`
+	DisplayName  string     `json:"display_name"`
+	IsDefault    bool       `json:"is_default"`
+	NeedsReview  bool       `json:"require_approval"`      // report versions need a reviewer's approval to run here
+	Sandbox      string     `json:"sandbox,omitempty"`     // staging copy that validate runs use before runs here
+	NoExternalAI bool       `json:"no_external_ai"`        // schema, samples and results go only to local models
+	AllowWrites  bool       `json:"allow_writes"`          // opened read-write; otherwise read-only and refused if its credentials can write
+	Credentials  []string   `json:"credentials,omitempty"` // credentials report versions can run as (run_as)
+	HealthStatus string     `json:"health_status"`
+	LastHealth   time.Time  `json:"last_health"`
+	Circuit      string     `json:"circuit"`            // "closed", "open" or "half_open"
+	Failures     int        `json:"failures"`           // consecutive failed connection checks
+	RetryAt      *time.Time `json:"retry_at,omitempty"` // while open, when the next checkout may reconnect
+	Error        string     `json:"error,omitempty"`
 }
 
 // DatasourcesResponse represents the list datasources response
@@ -201,16 +723,211 @@ type DatasourcesResponse struct {
 
 // HealthCheckResponse represents a datasource health check response
 type HealthCheckResponse struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"` // time the connection check took
+	Error     string `json:"error,omitempty"`
+}
+
+// DatasourceStatsResponse summarises a datasource for a health page. The
+// table, view and size figures come from the live datasource and are left
+// out when it cannot be reached; the rest come from AIR's own records.
+type DatasourceStatsResponse struct {
+	DatasourceID      string               `json:"datasource_id"`
+	Kind              string               `json:"kind"`
+	HealthStatus      string               `json:"health_status"`
+	LastHealth        time.Time            `json:"last_health"`
+	Tables            *int                 `json:"tables,omitempty"`
+	Views             *int                 `json:"views,omitempty"`
+	SizeBytes         *int64               `json:"size_bytes,omitempty"`
+	LearnedObjects    int                  `json:"learned_objects"`
+	LastLearnAt       *time.Time           `json:"last_learn_at"`
+	LastSuccessfulRun *time.Time           `json:"last_successful_run_at"`
+	Pool              *DatasourcePoolStats `json:"pool,omitempty"`
+	Error             string               `json:"error,omitempty"`
+}
+
+// QueryTestRequest is a read-only statement to try against a datasource
+type QueryTestRequest struct {
+	SQL            string `json:"sql" binding:"required"`
+	Limit          int    `json:"limit,omitempty"`           // rows returned; defaults to 20, at most 100
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // defaults to 10, at most 30
+}
+
+// RegisterLakeTableRequest is an Iceberg or Delta table directory, as found
+// by file discovery, to register with a Trino datasource
+type RegisterLakeTableRequest struct {
+	Location string `json:"location" binding:"required"` // table directory, e.g. s3://lake/sales/orders
+	Catalog  string `json:"catalog,omitempty"`           // defaults to the datasource's session catalog
+	Schema   string `json:"schema" binding:"required"`
+	Table    string `json:"table" binding:"required"`
+}
+
+// RegisterLakeTableResponse names a registered lake table
+type RegisterLakeTableResponse struct {
+	DatasourceID string `json:"datasource_id"`
+	Table        string `json:"table"` // catalog.schema.table
+}
+
+// LineageQuery filters lineage lookups. Table finds the reports reading a
+// table, narrowed to one column with Column; Report lists what a report reads.
+type LineageQuery struct {
+	DatasourceID string
+	Table        string
+	Column       string
+	Report       string
+}
+
+// LineageReport is a report version and the source columns it reads
+type LineageReport struct {
+	ReportID        uint            `json:"report_id"`
+	ReportVersionID uint            `json:"report_version_id"`
+	Key             string          `json:"key"`
+	Title           string          `json:"title"`
+	Version         int             `json:"version"`
+	DatasourceID    string          `json:"datasource_id,omitempty"`
+	Sources         []LineageSource `json:"sources"`
+}
+
+// LineageSource is one table column read by a report
+type LineageSource struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+}
+
+// LineageResponse lists the reports matching a lineage query
+type LineageResponse struct {
+	Reports []LineageReport `json:"reports"`
+}
+
+// RegenerateReportRequest regenerates a stale report's SQL from its IR.
+// DatasourceID is required for portable reports.
+type RegenerateReportRequest struct {
+	DatasourceID string `json:"datasource_id,omitempty"`
+}
+
+// RegenerateReportResponse is a proposed version with its SQL beside the
+// stale version's
+type RegenerateReportResponse struct {
+	ReportID     uint                   `json:"report_id"`
+	FromVersion  int                    `json:"from_version"`
+	Proposal     *ReportVersion         `json:"proposal"`
+	OldSQL       string                 `json:"old_sql"`
+	NewSQL       string                 `json:"new_sql"`
+	SQLDiff      string                 `json:"sql_diff"` // unified diff, old to new
+	SafetyReport map[string]interface{} `json:"safety_report"`
+}
+
+// RevalidateReportResponse lists the report versions cleared of staleness
+type RevalidateReportResponse struct {
+	ReportID uint            `json:"report_id"`
+	Versions []ReportVersion `json:"versions"`
+}
+
+// ReviewReportVersionRequest approves or rejects a report version. Reviewer
+// is the signed-in user; the field is only read when auth is disabled.
+type ReviewReportVersionRequest struct {
+	Reviewer string `json:"reviewer"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// PendingReviewsResponse lists the report versions waiting for a reviewer
+type PendingReviewsResponse struct {
+	Versions []ReportVersion `json:"versions"`
+}
+
+// QueryTestResponse is the outcome of a query test
+type QueryTestResponse struct {
+	DatasourceID string                   `json:"datasource_id"`
+	SQL          string                   `json:"sql"` // the statement run, with the limit applied
+	Columns      []string                 `json:"columns"`
+	Rows         []map[string]interface{} `json:"rows"`
+	RowCount     int                      `json:"row_count"`
+	Truncated    bool                     `json:"truncated"` // more rows matched than the limit
+	DurationMS   int64                    `json:"duration_ms"`
+}
+
+// DatasourcePoolStats is the connection pool state of a datasource
+type DatasourcePoolStats struct {
+	MaxOpen      int    `json:"max_open"`
+	Open         int    `json:"open"`
+	InUse        int    `json:"in_use"`
+	Idle         int    `json:"idle"`
+	WaitCount    int64  `json:"wait_count"`
+	WaitDuration string `json:"wait_duration"`
+}
+
+// ModelCheckResponse represents the startup verification result for one configured model
+type ModelCheckResponse struct {
+	Role     string `json:"role"` // chat, sql, embeddings
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Status   string `json:"status"` // ok, missing, pulled, error
+	Error    string `json:"error,omitempty"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// IRChange represents one structural difference between two IR documents
+type IRChange struct {
+	Path string      `json:"path"` // JSON path, e.g. $.filters[0].value
+	Op   string      `json:"op"`   // added, removed, changed
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// ScopeVersionDiffResponse represents the diff between two scope versions
+type ScopeVersionDiffResponse struct {
+	ScopeID     uint       `json:"scope_id"`
+	FromVersion int        `json:"from_version"`
+	ToVersion   int        `json:"to_version"`
+	ScopeMDDiff string     `json:"scope_md_diff"` // unified diff
+	IRJSONDiff  string     `json:"ir_json_diff"`  // unified diff of pretty-printed IR
+	IRChanges   []IRChange `json:"ir_changes"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
+	Code    string `json:"code,omitempty"` // one of the ErrCode values
 	Error   string `json:"error"`
 	Details string `json:"details,omitempty"`
 }
 
+// Error codes carried in ErrorResponse.Code. Each maps to one HTTP status.
+const (
+	ErrCodeNotFound              = "NOT_FOUND"              // 404
+	ErrCodeValidation            = "VALIDATION"             // 400
+	ErrCodeConflict              = "CONFLICT"               // 409
+	ErrCodeSafetyBlocked         = "SAFETY_BLOCKED"         // 422
+	ErrCodeDatasourceUnavailable = "DATASOURCE_UNAVAILABLE" // 503
+	ErrCodeLLMTimeout            = "LLM_TIMEOUT"            // 504
+	ErrCodeCanceled              = "CANCELED"               // 499, client closed the request
+	ErrCodeInternal              = "INTERNAL"               // 500
+)
+
+// VersionConflictResponse is the 409 body when If-Match names a version that
+// is no longer the latest. Current is the latest scope or report version.
+type VersionConflictResponse struct {
+	ErrorResponse
+	CurrentVersion int         `json:"current_version"`
+	Current        interface{} `json:"current"`
+}
+
+// ListOptions are the cursor, sort and filter options of a list endpoint. Sort
+// names a field, prefixed with "-" for descending; Filters match fields exactly.
+type ListOptions struct {
+	Cursor       string
+	Limit        int
+	Sort         string
+	Filters      map[string]string
+	IncludeTotal bool
+}
+
+// PageInfo describes a page of list results. NextCursor is empty on the last
+// page; Total is only counted when asked for.
+type PageInfo struct {
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
 // SuccessResponse represents a success response
 type SuccessResponse struct {
 	Message string `json:"message"`
@@ -223,12 +940,50 @@ type SuccessResponse struct {
 // CreateDatasourceRequest represents the request to create a new datasource
 type CreateDatasourceRequest struct {
 	ID          string `json:"id" binding:"required"`
-	Kind        string `json:"kind" binding:"required,oneof=postgres timescaledb mysql"`
+	Kind        string `json:"kind" binding:"required"`
 	DSN         string `json:"dsn" binding:"required"`
 	DisplayName string `json:"display_name" binding:"required"`
 	IsDefault   bool   `json:"is_default"`
 }
 
+// ApplyDatasourcesRequest declares the datasources there should be. Listed
+// ones are created or updated to match; with Prune, unlisted ones are removed.
+// DryRun reports the changes without making them.
+type ApplyDatasourcesRequest struct {
+	Datasources []CreateDatasourceRequest `json:"datasources" binding:"dive"`
+	Prune       bool                      `json:"prune"`
+	DryRun      bool                      `json:"dry_run"`
+}
+
+// DatasourceChange is what an apply did, or would do, to one datasource
+type DatasourceChange struct {
+	ID     string   `json:"id"`
+	Action string   `json:"action"`           // "create", "update", "delete" or "unchanged"
+	Fields []string `json:"fields,omitempty"` // changed fields of an update
+	Error  string   `json:"error,omitempty"`
+}
+
+// ApplyDatasourcesResponse lists the changes of an apply in the order made
+type ApplyDatasourcesResponse struct {
+	DryRun  bool               `json:"dry_run"`
+	Changes []DatasourceChange `json:"changes"`
+	Failed  int                `json:"failed"`
+}
+
+// SeedDemoRequest represents the request to create the demo analytics source
+type SeedDemoRequest struct {
+	Reset bool `json:"reset"` // rebuild the demo database and add new scope and report versions
+}
+
+// SeedDemoResponse describes the seeded demo datasource
+type SeedDemoResponse struct {
+	DatasourceID string         `json:"datasource_id"`
+	DSN          string         `json:"dsn"`
+	Rows         map[string]int `json:"rows"` // rows loaded per table
+	Scopes       []uint         `json:"scopes"`
+	Reports      []string       `json:"reports"` // report keys
+}
+
 // LearnDatasourceRequest represents the request to learn from a datasource
 type LearnDatasourceRequest struct {
 	DatasourceID string   `json:"datasource_id" binding:"required"`
@@ -243,6 +998,10 @@ type CreateScopeRequest struct {
 // CreateScopeVersionRequest represents the request to create a new scope version
 type CreateScopeVersionRequest struct {
 	ScopeMD string `json:"scope_md" binding:"required"`
+	// BaseVersion is the version the edit started from; when set, the request
+	// fails with a conflict if another version was created since. The API
+	// fills it from If-Match.
+	BaseVersion *int `json:"base_version,omitempty"`
 }
 
 // BuildIRRequest represents the request to build IR from scope
@@ -257,6 +1016,61 @@ type GetSchemaResponse struct {
 	SchemaNotes  []string `json:"schema_notes"`
 }
 
+// ColumnAnnotationRequest sets the unit and metadata of one column
+type ColumnAnnotationRequest struct {
+	Object      string `json:"object" binding:"required"`
+	Column      string `json:"column" binding:"required"`
+	Unit        string `json:"unit"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+}
+
+// UpsertColumnAnnotationsRequest sets annotations for several columns at once
+type UpsertColumnAnnotationsRequest struct {
+	Annotations []ColumnAnnotationRequest `json:"annotations" binding:"required,dive"`
+}
+
+// CurateSchemaNoteRequest changes the curation of a schema note. Omitted
+// fields are left alone; an empty md goes back to the learned text.
+type CurateSchemaNoteRequest struct {
+	MD         *string `json:"md,omitempty"`
+	Annotation *string `json:"annotation,omitempty"`
+	Pinned     *bool   `json:"pinned,omitempty"`
+	Hidden     *bool   `json:"hidden,omitempty"`
+}
+
+// GenerateDataDictionaryRequest limits a generated data dictionary to some
+// tables; empty covers every learned table
+type GenerateDataDictionaryRequest struct {
+	Tables []string `json:"tables,omitempty"`
+}
+
+// UpdateDataDictionaryRequest saves an edited data dictionary as a new version
+type UpdateDataDictionaryRequest struct {
+	MD string `json:"md" binding:"required"`
+}
+
+// CreateFreshnessMonitorRequest watches a table's newest timestamp
+type CreateFreshnessMonitorRequest struct {
+	Table  string `json:"table" binding:"required"`
+	Column string `json:"column" binding:"required"`  // timestamp column whose MAX is the table's age
+	MaxAge string `json:"max_age" binding:"required"` // Go duration, e.g. "2h"
+}
+
+// GlossaryTermRequest defines one business term; Object or Expression is required
+type GlossaryTermRequest struct {
+	Term        string   `json:"term" binding:"required"`
+	Synonyms    []string `json:"synonyms"`
+	Object      string   `json:"object"`
+	Expression  string   `json:"expression"`
+	Description string   `json:"description"`
+}
+
+// UpsertGlossaryRequest sets several glossary terms at once
+type UpsertGlossaryRequest struct {
+	Terms []GlossaryTermRequest `json:"terms" binding:"required,dive"`
+}
+
 // GenerateSQLRequest represents the request to generate SQL
 type GenerateSQLRequest struct {
 	IR           map[string]interface{} `json:"ir" binding:"required"`
@@ -265,9 +1079,12 @@ type GenerateSQLRequest struct {
 
 // CreateReportRequest represents the request to create a new report
 type CreateReportRequest struct {
-	Key   string `json:"key" binding:"required"`
-	Title string `json:"title" binding:"required"`
-	Owner string `json:"owner,omitempty"`
+	Key      string `json:"key" binding:"required"`
+	Title    string `json:"title" binding:"required"`
+	Owner    string `json:"owner,omitempty"`
+	Folder   string `json:"folder,omitempty"`
+	Tags     string `json:"tags,omitempty"` // comma-separated
+	Timezone string `json:"timezone,omitempty"`
 }
 
 // CreateReportVersionRequest represents the request to create a new report version
@@ -275,12 +1092,319 @@ type CreateReportVersionRequest struct {
 	ScopeVersionID uint    `json:"scope_version_id" binding:"required"`
 	DatasourceID   *string `json:"datasource_id,omitempty"`
 	DefJSON        string  `json:"def_json" binding:"required"`
+	BaseVersion    *int    `json:"base_version,omitempty"` // see CreateScopeVersionRequest
+	User           string  `json:"-"`                      // set from the authenticated caller
+}
+
+// CreateSQLReportRequest represents the request to register hand-written SQL as a report
+type CreateSQLReportRequest struct {
+	Key          string                 `json:"key" binding:"required"`
+	Title        string                 `json:"title" binding:"required"`
+	Owner        string                 `json:"owner,omitempty"`
+	Timezone     string                 `json:"timezone,omitempty"`
+	DatasourceID string                 `json:"datasource_id" binding:"required"`
+	SQL          string                 `json:"sql" binding:"required"`
+	ParamsSchema map[string]interface{} `json:"params_schema,omitempty"` // JSON Schema; derived from placeholders when omitted
+	User         string                 `json:"-"`                       // set from the authenticated caller
+}
+
+// CreateSQLReportResponse represents the result of registering a SQL report
+type CreateSQLReportResponse struct {
+	Report       *Report                `json:"report"`
+	Version      *ReportVersion         `json:"version"`
+	SafetyReport map[string]interface{} `json:"safety_report"`
 }
 
 // RunReportRequest represents the request to run a report
 type RunReportRequest struct {
 	Params       map[string]interface{} `json:"params" binding:"required"`
 	DatasourceID *string                `json:"datasource_id,omitempty"`
+	User         string                 `json:"-"` // set from the authenticated caller for {{current_user}}
+	TraceID      string                 `json:"-"` // caller's correlation ID, e.g. X-Request-ID; generated when empty or unsafe
+	AllowAsync   bool                   `json:"-"` // return a still-running run once safety.sync_run_threshold passes
+}
+
+// FanOutRunRequest represents the request to run a portable report against
+// several datasources at once
+type FanOutRunRequest struct {
+	Params        map[string]interface{} `json:"params"`
+	DatasourceIDs []string               `json:"datasource_ids" binding:"required,min=1"`
+	User          string                 `json:"-"`
+	TraceID       string                 `json:"-"`
+}
+
+// ParamOptionsRequest asks for the options of dependent params given the
+// params chosen so far
+type ParamOptionsRequest struct {
+	Params       map[string]interface{} `json:"params"`
+	DatasourceID string                 `json:"datasource_id,omitempty"`
+	User         string                 `json:"-"` // set from the authenticated caller
+}
+
+// CreateShareLinkRequest shares a run, or the live report with frozen params
+type CreateShareLinkRequest struct {
+	RunID        *uint                  `json:"run_id,omitempty"`
+	Params       map[string]interface{} `json:"params,omitempty"`        // live links only
+	DatasourceID string                 `json:"datasource_id,omitempty"` // live links only
+	ExpiresIn    string                 `json:"expires_in,omitempty"`    // Go duration, e.g. "72h"; sharing.default_ttl when empty
+}
+
+// SetSummaryTemplateRequest sets a report's run summary template for a format
+type SetSummaryTemplateRequest struct {
+	Template string `json:"template" binding:"required"`
+}
+
+// CreateReportAssertionRequest attaches an assertion to a report
+type CreateReportAssertionRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	Kind         string   `json:"kind" binding:"required"`
+	Column       string   `json:"column,omitempty"`
+	Op           string   `json:"op,omitempty"`
+	Value        *float64 `json:"value,omitempty"`
+	ReferenceSQL string   `json:"reference_sql,omitempty"`
+	Tolerance    float64  `json:"tolerance,omitempty"`
+	Critical     bool     `json:"critical"`
+}
+
+// ValidateReportRequest runs a report version on a datasource's sandbox.
+// Version defaults to the latest; DatasourceID names the production
+// datasource for portable reports. Without expected columns, the columns of
+// the report's last completed run on the datasource are expected.
+type ValidateReportRequest struct {
+	Version      int                    `json:"version,omitempty"`
+	DatasourceID *string                `json:"datasource_id,omitempty"`
+	Params       map[string]interface{} `json:"params,omitempty"`
+	Expect       ShapeExpectation       `json:"expect"`
+	User         string                 `json:"-"`
+}
+
+// ShapeExpectation is the result shape a validate run must have. Column
+// names are matched case-insensitively and in any order.
+type ShapeExpectation struct {
+	Columns []string `json:"columns,omitempty"`
+	MinRows *int     `json:"min_rows,omitempty"`
+	MaxRows *int     `json:"max_rows,omitempty"`
+}
+
+// MaterializeReportRequest represents the request to materialize a report version
+type MaterializeReportRequest struct {
+	Version         int                    `json:"version,omitempty"` // defaults to the latest version
+	RefreshInterval string                 `json:"refresh_interval" binding:"required"`
+	Params          map[string]interface{} `json:"params,omitempty"`
+}
+
+// ResultColumn is a result column's name and database type
+type ResultColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // as reported by the driver, e.g. "INTEGER" or "NUMERIC"; empty when unknown
+}
+
+// ResultSet is query results in select order. Every row has one value per
+// column, so a NULL is an explicit null rather than a missing key.
+type ResultSet struct {
+	Columns []ResultColumn  `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// SnapshotData represents report rows served from a snapshot table
+type SnapshotData struct {
+	ReportID        uint            `json:"report_id"`
+	ReportVersionID uint            `json:"report_version_id"`
+	Source          string          `json:"source"` // always "snapshot"
+	Table           string          `json:"snapshot_table"`
+	Status          string          `json:"status"`
+	RowCount        int             `json:"row_count"`
+	Columns         []ResultColumn  `json:"columns"`
+	Rows            [][]interface{} `json:"rows"`
+	RefreshedAt     time.Time       `json:"refreshed_at"`
+	AgeSeconds      int64           `json:"age_seconds"`
+	Stale           bool            `json:"stale"` // the scheduled refresh is overdue or failed
+}
+
+// CreateWebhookRequest represents the request to subscribe an endpoint to events
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	Secret      string   `json:"secret,omitempty"` // generated when omitted
+	Description string   `json:"description,omitempty"`
+}
+
+// CreateWebhookResponse returns the subscription with its signing secret (shown once)
+type CreateWebhookResponse struct {
+	*WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// CreateReportNotificationRequest represents the request to add a report notifier
+type CreateReportNotificationRequest struct {
+	Type       string   `json:"type" binding:"required"`
+	WebhookURL string   `json:"webhook_url" binding:"required"`
+	Events     []string `json:"events,omitempty"` // defaults to run completed/failed and report stale
+	Template   string   `json:"template,omitempty"`
+}
+
+// HistorySourceStatus describes a configured history source and its point cursors
+type HistorySourceStatus struct {
+	Name         string          `json:"name"`
+	Kind         string          `json:"kind"`
+	URL          string          `json:"url"`
+	DatasourceID string          `json:"datasource_id"`
+	Table        string          `json:"table"`
+	Interval     string          `json:"interval"`
+	LastRunAt    *time.Time      `json:"last_run_at,omitempty"`
+	Running      bool            `json:"running"`
+	Points       []HistoryCursor `json:"points"`
+}
+
+// HistoryIngestResult summarizes one ingestion run of a history source
+type HistoryIngestResult struct {
+	Source     string    `json:"source"`
+	Points     int       `json:"points"`
+	Rows       int       `json:"rows"`
+	Failed     int       `json:"failed"`
+	Errors     []string  `json:"errors,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// RetentionReport summarizes one cleanup pass. With DryRun nothing was
+// removed and the counts are what a real pass would remove.
+type RetentionReport struct {
+	DryRun         bool      `json:"dry_run"`
+	RunsDeleted    int64     `json:"runs_deleted"`     // runs past keep_runs_per_report, with their samples and analyses
+	ResultsPurged  int64     `json:"results_purged"`   // runs whose results were older than results_max_age
+	TracesDeleted  int64     `json:"traces_deleted"`   // AI traces older than traces_max_age
+	UploadsDeleted []string  `json:"uploads_deleted"`  // orphaned upload file names
+	UploadBytes    int64     `json:"upload_bytes"`     // size of the orphaned uploads
+	Errors         []string  `json:"errors,omitempty"` // steps that failed; the others still ran
+	StartedAt      time.Time `json:"started_at"`
+	DurationMS     int64     `json:"duration_ms"`
+}
+
+// RowEstimate is the result of the row-count pre-check run before report SQL
+type RowEstimate struct {
+	Rows     int64  `json:"rows"`
+	Method   string `json:"method"` // "explain" or "bounded_count"
+	Limit    int    `json:"limit"`
+	Exceeded bool   `json:"exceeded"`
+	Guidance string `json:"guidance,omitempty"`
+}
+
+// CostEstimate is the dry-run scan estimate of a report run on a warehouse
+// billed by bytes scanned, checked against the warehouse_cost budgets
+type CostEstimate struct {
+	DatasourceID  string  `json:"datasource_id"`
+	Bytes         int64   `json:"bytes"`
+	Cost          float64 `json:"cost"`
+	MaxRunBytes   int64   `json:"max_run_bytes,omitempty"`
+	MaxDailyBytes int64   `json:"max_daily_bytes,omitempty"`
+	DailyBytes    int64   `json:"daily_bytes"` // scanned by the datasource's report runs today, including running ones
+	Exceeded      bool    `json:"exceeded"`
+	Guidance      string  `json:"guidance,omitempty"`
+}
+
+// ReportSelector selects reports by explicit keys, folder or tag
+type ReportSelector struct {
+	Keys   []string `json:"keys,omitempty"`
+	Folder string   `json:"folder,omitempty"`
+	Tag    string   `json:"tag,omitempty"`
+}
+
+// BatchRunReportsRequest represents the request to run several reports with shared params
+type BatchRunReportsRequest struct {
+	ReportSelector
+	Params       map[string]interface{} `json:"params"`
+	DatasourceID *string                `json:"datasource_id,omitempty"`
+	User         string                 `json:"-"`
+}
+
+// BatchArchiveReportsRequest represents the request to archive several reports
+type BatchArchiveReportsRequest struct {
+	ReportSelector
+}
+
+// BatchExportReportsRequest represents the request to export several reports as one bundle
+type BatchExportReportsRequest struct {
+	ReportSelector
+	IncludeAllVersions bool `json:"include_all_versions,omitempty"`
+}
+
+// ReportBundle is a portable export of reports and their versions
+type ReportBundle struct {
+	ExportedAt time.Time           `json:"exported_at"`
+	Selector   ReportSelector      `json:"selector"`
+	Reports    []ReportBundleEntry `json:"reports"`
+}
+
+// ReportBundleEntry is one report within a bundle
+type ReportBundleEntry struct {
+	Report   Report          `json:"report"`
+	Versions []ReportVersion `json:"versions"`
+}
+
+// ============================================================================
+// Report Packages (.airpkg)
+// ============================================================================
+
+// AirPackageFormat identifies .airpkg archives
+const AirPackageFormat = "airpkg"
+
+// AirPackageFormatVersion is the current .airpkg layout version
+const AirPackageFormatVersion = 1
+
+// AirPackageManifest is manifest.json at the root of a .airpkg archive
+type AirPackageManifest struct {
+	Format             string            `json:"format"`
+	FormatVersion      int               `json:"format_version"`
+	Name               string            `json:"name"`
+	Description        string            `json:"description,omitempty"`
+	CreatedAt          time.Time         `json:"created_at"`
+	Reports            []string          `json:"reports"`             // report keys; each stored as reports/<key>.json
+	SchemaFingerprints map[string]string `json:"schema_fingerprints"` // table -> schema note hash at export time
+}
+
+// AirPackageReport is reports/<key>.json inside a .airpkg archive
+type AirPackageReport struct {
+	Key      string                    `json:"key"`
+	Title    string                    `json:"title"`
+	Owner    string                    `json:"owner,omitempty"`
+	Folder   string                    `json:"folder,omitempty"`
+	Tags     string                    `json:"tags,omitempty"`
+	Versions []AirPackageReportVersion `json:"versions"`
+}
+
+// AirPackageReportVersion is one report version inside a package
+type AirPackageReportVersion struct {
+	Version        int                    `json:"version"`
+	DatasourceKind string                 `json:"datasource_kind,omitempty"`
+	DefJSON        string                 `json:"def_json"`
+	ParamsSchema   map[string]interface{} `json:"params_schema,omitempty"`
+	ChartSpec      map[string]interface{} `json:"chart_spec,omitempty"`
+	RequiredTables []string               `json:"required_tables,omitempty"`
+	Scope          *AirPackageScope       `json:"scope,omitempty"`
+}
+
+// AirPackageScope is the scope version a report version was built from
+type AirPackageScope struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	ScopeMD string `json:"scope_md"`
+	IRJSON  string `json:"ir_json,omitempty"`
+}
+
+// ExportPackageRequest represents the request to export reports as a .airpkg
+type ExportPackageRequest struct {
+	ReportSelector
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// PackageInstallResult summarises a package import
+type PackageInstallResult struct {
+	Package   string   `json:"package"`
+	Installed []string `json:"installed"`
+	Skipped   []string `json:"skipped,omitempty"`
+	Warnings  []string `json:"warnings"`
 }
 
 // AnalyzeRunRequest represents the request to analyze a report run
@@ -289,6 +1413,107 @@ type AnalyzeRunRequest struct {
 	RubricVersion string `json:"rubric_version,omitempty"`
 }
 
+// CreateRunFeedbackRequest records a thumbs up/down on a run's SQL or analysis.
+// AnalysisID defaults to the run's latest analysis for analysis feedback.
+type CreateRunFeedbackRequest struct {
+	Target       string `json:"target" binding:"required,oneof=sql analysis"`
+	Rating       int    `json:"rating" binding:"required,oneof=1 -1"`
+	AnalysisID   *uint  `json:"analysis_id,omitempty"`
+	CorrectedSQL string `json:"corrected_sql,omitempty"`
+	Comment      string `json:"comment,omitempty"`
+	User         string `json:"-"`
+}
+
+// FeedbackPair is one exported prompt/response example with its rating
+type FeedbackPair struct {
+	FeedbackID      uint      `json:"feedback_id"`
+	RunID           uint      `json:"run_id"`
+	Target          string    `json:"target"`
+	Rating          int       `json:"rating"`
+	Prompt          string    `json:"prompt"`
+	Response        string    `json:"response"`
+	Corrected       string    `json:"corrected,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+	ReportVersionID uint      `json:"report_version_id"`
+	ScopeVersionID  *uint     `json:"scope_version_id,omitempty"`
+	RubricVersion   string    `json:"rubric_version,omitempty"`
+	ModelUsed       string    `json:"model_used,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// CreateSQLExampleRequest adds a verified question -> SQL example
+type CreateSQLExampleRequest struct {
+	Question string `json:"question" binding:"required"`
+	SQL      string `json:"sql" binding:"required"`
+}
+
+// UpdatePIIColumnRequest allows or masks a PII-tagged column
+type UpdatePIIColumnRequest struct {
+	Allowed *bool `json:"allowed" binding:"required"`
+}
+
+// CreateSavedPromptRequest saves a prompt to the caller's library
+type CreateSavedPromptRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Text        string `json:"text" binding:"required"`
+	Shared      bool   `json:"shared"`
+}
+
+// UpdateSavedPromptRequest changes the fields of a saved prompt that are set
+type UpdateSavedPromptRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Text        *string `json:"text"`
+	Shared      *bool   `json:"shared"`
+}
+
+// RunSavedPromptRequest runs a saved prompt. It is asked about the files of
+// SessionID when set, else about DatasourceID, else the caller's default
+// datasource.
+type RunSavedPromptRequest struct {
+	Params       map[string]string `json:"params"`
+	SessionID    uint              `json:"session_id,omitempty"`
+	DatasourceID string            `json:"datasource_id,omitempty"`
+	FileIDs      []string          `json:"-"` // uploaded files to ask about instead, e.g. a chat connection's dataset
+}
+
+// UpdateUserPreferencesRequest changes the caller's preferences. Omitted
+// fields keep their value; an empty string or zero clears one.
+type UpdateUserPreferencesRequest struct {
+	DefaultDatasource *string          `json:"default_datasource"`
+	ChatModel         *string          `json:"chat_model"`
+	Timezone          *string          `json:"timezone"`
+	DefaultRowLimit   *int             `json:"default_row_limit" binding:"omitempty,min=0"`
+	UILayout          *json.RawMessage `json:"ui_layout"`
+}
+
+// ReplayAITraceRequest re-issues a traced prompt. Empty fields keep the
+// original call's provider and model; Seed overrides the recorded seed.
+type ReplayAITraceRequest struct {
+	Provider string `json:"provider,omitempty" binding:"omitempty,oneof=ollama openai"`
+	Model    string `json:"model,omitempty"`
+	Seed     *int   `json:"seed,omitempty"`
+}
+
+// AITraceExportFilter selects the traces of a compliance export. Zero fields
+// do not filter.
+type AITraceExportFilter struct {
+	From      *time.Time
+	To        *time.Time
+	User      string
+	ReportKey string
+	Operation string
+	Redact    bool // apply the registered redaction hooks to each trace
+}
+
+// ReplayAITraceResponse is the replayed call and whether its response is
+// identical to the original's
+type ReplayAITraceResponse struct {
+	Trace           *AITrace `json:"trace"`
+	MatchesOriginal bool     `json:"matches_original"`
+}
+
 // StartSessionRequest represents the request to start a new learning session
 type StartSessionRequest struct {
 	FilePath       string                 `json:"file_path" binding:"required"`
@@ -297,6 +1522,16 @@ type StartSessionRequest struct {
 	Options        map[string]interface{} `json:"options,omitempty"`
 }
 
+// SetActiveDatasetRequest selects the uploaded file a session works with
+type SetActiveDatasetRequest struct {
+	FileID string `json:"file_id"`
+}
+
+// AddSessionDatasetRequest loads another uploaded file into a session's workspace
+type AddSessionDatasetRequest struct {
+	FileID string `json:"file_id" binding:"required"`
+}
+
 // CreateGeneratedReportRequest represents the request to create a generated report
 type CreateGeneratedReportRequest struct {
 	Name           string `json:"name" binding:"required"`
@@ -313,6 +1548,16 @@ type ExecuteReportRequest struct {
 	Parameters map[string]interface{} `json:"parameters" binding:"required"`
 }
 
+// PullModelRequest represents the request to pull an Ollama model
+type PullModelRequest struct {
+	Model string `json:"model" binding:"required"`
+}
+
+// WarmUpModelsRequest represents the request to load models into memory
+type WarmUpModelsRequest struct {
+	Models []string `json:"models,omitempty"` // empty = configured chat/SQL models
+}
+
 // ============================================================================
 // Database Migration
 // ============================================================================
@@ -324,13 +1569,43 @@ func AutoMigrate(db *gorm.DB) error {
 		&Scope{},
 		&ScopeVersion{},
 		&SchemaNote{},
+		&ContinuousAggregate{},
+		&ColumnAnnotation{},
+		&PIIColumn{},
+		&GlossaryTerm{},
 		&Report{},
 		&ReportVersion{},
+		&LineageEdge{},
+		&ReportValidation{},
+		&ReportAssertion{},
+		&ReportAssertionResult{},
+		&FreshnessMonitor{},
 		&ReportRun{},
+		&ReportBatch{},
+		&ReportBatchItem{},
+		&ReportMaterialization{},
+		&WebhookSubscription{},
+		&WebhookDelivery{},
+		&ReportNotification{},
+		&HistoryCursor{},
 		&ReportSample{},
 		&ReportAnalysis{},
+		&FileAnalysis{},
+		&SessionDataset{},
+		&DataDictionary{},
+		&SchemaNoteRevision{},
+		&RunFeedback{},
+		&SQLExample{},
+		&AITrace{},
 		&Session{},
 		&GeneratedReport{},
 		&ReportExecution{},
+		&UserPreference{},
+		&SavedPrompt{},
+		&ReportAccess{},
+		&ParamEnumCache{},
+		&ReportSummaryTemplate{},
+		&ShareLink{},
+		&ShareAccess{},
 	)
 }
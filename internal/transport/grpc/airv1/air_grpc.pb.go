@@ -0,0 +1,545 @@
+// gRPC surface of the AIR API for internal services. It mirrors the REST
+// operations in api/openapi.yaml. Errors carry the REST error code
+// (NOT_FOUND, VALIDATION, CONFLICT, SAFETY_BLOCKED, DATASOURCE_UNAVAILABLE,
+// LLM_TIMEOUT, INTERNAL) as the reason of a google.rpc.ErrorInfo detail.
+//
+// The API serves it on server.grpc_port when set. Calls carry the same JWT
+// as REST, as "authorization: Bearer <token>" metadata, when auth is enabled.
+// Regenerate Go code with `make proto-gen`.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: air/v1/air.proto
+
+package airv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DatasourceService_ListDatasources_FullMethodName  = "/air.v1.DatasourceService/ListDatasources"
+	DatasourceService_GetDatasource_FullMethodName    = "/air.v1.DatasourceService/GetDatasource"
+	DatasourceService_CreateDatasource_FullMethodName = "/air.v1.DatasourceService/CreateDatasource"
+	DatasourceService_DeleteDatasource_FullMethodName = "/air.v1.DatasourceService/DeleteDatasource"
+	DatasourceService_Learn_FullMethodName            = "/air.v1.DatasourceService/Learn"
+)
+
+// DatasourceServiceClient is the client API for DatasourceService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DatasourceServiceClient interface {
+	ListDatasources(ctx context.Context, in *ListDatasourcesRequest, opts ...grpc.CallOption) (*ListDatasourcesResponse, error)
+	GetDatasource(ctx context.Context, in *GetDatasourceRequest, opts ...grpc.CallOption) (*Datasource, error)
+	CreateDatasource(ctx context.Context, in *CreateDatasourceRequest, opts ...grpc.CallOption) (*Datasource, error)
+	DeleteDatasource(ctx context.Context, in *DeleteDatasourceRequest, opts ...grpc.CallOption) (*DeleteDatasourceResponse, error)
+	// Learn introspects the datasource schema, as POST /v1/learn
+	Learn(ctx context.Context, in *LearnRequest, opts ...grpc.CallOption) (*LearnResponse, error)
+}
+
+type datasourceServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDatasourceServiceClient(cc grpc.ClientConnInterface) DatasourceServiceClient {
+	return &datasourceServiceClient{cc}
+}
+
+func (c *datasourceServiceClient) ListDatasources(ctx context.Context, in *ListDatasourcesRequest, opts ...grpc.CallOption) (*ListDatasourcesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDatasourcesResponse)
+	err := c.cc.Invoke(ctx, DatasourceService_ListDatasources_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceServiceClient) GetDatasource(ctx context.Context, in *GetDatasourceRequest, opts ...grpc.CallOption) (*Datasource, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Datasource)
+	err := c.cc.Invoke(ctx, DatasourceService_GetDatasource_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceServiceClient) CreateDatasource(ctx context.Context, in *CreateDatasourceRequest, opts ...grpc.CallOption) (*Datasource, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Datasource)
+	err := c.cc.Invoke(ctx, DatasourceService_CreateDatasource_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceServiceClient) DeleteDatasource(ctx context.Context, in *DeleteDatasourceRequest, opts ...grpc.CallOption) (*DeleteDatasourceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteDatasourceResponse)
+	err := c.cc.Invoke(ctx, DatasourceService_DeleteDatasource_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *datasourceServiceClient) Learn(ctx context.Context, in *LearnRequest, opts ...grpc.CallOption) (*LearnResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LearnResponse)
+	err := c.cc.Invoke(ctx, DatasourceService_Learn_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DatasourceServiceServer is the server API for DatasourceService service.
+// All implementations must embed UnimplementedDatasourceServiceServer
+// for forward compatibility.
+type DatasourceServiceServer interface {
+	ListDatasources(context.Context, *ListDatasourcesRequest) (*ListDatasourcesResponse, error)
+	GetDatasource(context.Context, *GetDatasourceRequest) (*Datasource, error)
+	CreateDatasource(context.Context, *CreateDatasourceRequest) (*Datasource, error)
+	DeleteDatasource(context.Context, *DeleteDatasourceRequest) (*DeleteDatasourceResponse, error)
+	// Learn introspects the datasource schema, as POST /v1/learn
+	Learn(context.Context, *LearnRequest) (*LearnResponse, error)
+	mustEmbedUnimplementedDatasourceServiceServer()
+}
+
+// UnimplementedDatasourceServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDatasourceServiceServer struct{}
+
+func (UnimplementedDatasourceServiceServer) ListDatasources(context.Context, *ListDatasourcesRequest) (*ListDatasourcesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDatasources not implemented")
+}
+func (UnimplementedDatasourceServiceServer) GetDatasource(context.Context, *GetDatasourceRequest) (*Datasource, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDatasource not implemented")
+}
+func (UnimplementedDatasourceServiceServer) CreateDatasource(context.Context, *CreateDatasourceRequest) (*Datasource, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDatasource not implemented")
+}
+func (UnimplementedDatasourceServiceServer) DeleteDatasource(context.Context, *DeleteDatasourceRequest) (*DeleteDatasourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDatasource not implemented")
+}
+func (UnimplementedDatasourceServiceServer) Learn(context.Context, *LearnRequest) (*LearnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Learn not implemented")
+}
+func (UnimplementedDatasourceServiceServer) mustEmbedUnimplementedDatasourceServiceServer() {}
+func (UnimplementedDatasourceServiceServer) testEmbeddedByValue()                           {}
+
+// UnsafeDatasourceServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DatasourceServiceServer will
+// result in compilation errors.
+type UnsafeDatasourceServiceServer interface {
+	mustEmbedUnimplementedDatasourceServiceServer()
+}
+
+func RegisterDatasourceServiceServer(s grpc.ServiceRegistrar, srv DatasourceServiceServer) {
+	// If the following call pancis, it indicates UnimplementedDatasourceServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DatasourceService_ServiceDesc, srv)
+}
+
+func _DatasourceService_ListDatasources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDatasourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServiceServer).ListDatasources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DatasourceService_ListDatasources_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServiceServer).ListDatasources(ctx, req.(*ListDatasourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DatasourceService_GetDatasource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDatasourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServiceServer).GetDatasource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DatasourceService_GetDatasource_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServiceServer).GetDatasource(ctx, req.(*GetDatasourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DatasourceService_CreateDatasource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateDatasourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServiceServer).CreateDatasource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DatasourceService_CreateDatasource_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServiceServer).CreateDatasource(ctx, req.(*CreateDatasourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DatasourceService_DeleteDatasource_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteDatasourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServiceServer).DeleteDatasource(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DatasourceService_DeleteDatasource_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServiceServer).DeleteDatasource(ctx, req.(*DeleteDatasourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DatasourceService_Learn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LearnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatasourceServiceServer).Learn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DatasourceService_Learn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatasourceServiceServer).Learn(ctx, req.(*LearnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DatasourceService_ServiceDesc is the grpc.ServiceDesc for DatasourceService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DatasourceService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "air.v1.DatasourceService",
+	HandlerType: (*DatasourceServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDatasources",
+			Handler:    _DatasourceService_ListDatasources_Handler,
+		},
+		{
+			MethodName: "GetDatasource",
+			Handler:    _DatasourceService_GetDatasource_Handler,
+		},
+		{
+			MethodName: "CreateDatasource",
+			Handler:    _DatasourceService_CreateDatasource_Handler,
+		},
+		{
+			MethodName: "DeleteDatasource",
+			Handler:    _DatasourceService_DeleteDatasource_Handler,
+		},
+		{
+			MethodName: "Learn",
+			Handler:    _DatasourceService_Learn_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "air/v1/air.proto",
+}
+
+const (
+	ReportService_RunReport_FullMethodName       = "/air.v1.ReportService/RunReport"
+	ReportService_StreamReportRun_FullMethodName = "/air.v1.ReportService/StreamReportRun"
+)
+
+// ReportServiceClient is the client API for ReportService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ReportServiceClient interface {
+	// RunReport executes a report and returns the finished run
+	RunReport(ctx context.Context, in *RunReportRequest, opts ...grpc.CallOption) (*ReportRun, error)
+	// StreamReportRun executes a report and streams the run status followed by
+	// result rows in batches, so large results need not fit in one message
+	StreamReportRun(ctx context.Context, in *RunReportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReportRunEvent], error)
+}
+
+type reportServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReportServiceClient(cc grpc.ClientConnInterface) ReportServiceClient {
+	return &reportServiceClient{cc}
+}
+
+func (c *reportServiceClient) RunReport(ctx context.Context, in *RunReportRequest, opts ...grpc.CallOption) (*ReportRun, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportRun)
+	err := c.cc.Invoke(ctx, ReportService_RunReport_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reportServiceClient) StreamReportRun(ctx context.Context, in *RunReportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReportRunEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ReportService_ServiceDesc.Streams[0], ReportService_StreamReportRun_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunReportRequest, ReportRunEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReportService_StreamReportRunClient = grpc.ServerStreamingClient[ReportRunEvent]
+
+// ReportServiceServer is the server API for ReportService service.
+// All implementations must embed UnimplementedReportServiceServer
+// for forward compatibility.
+type ReportServiceServer interface {
+	// RunReport executes a report and returns the finished run
+	RunReport(context.Context, *RunReportRequest) (*ReportRun, error)
+	// StreamReportRun executes a report and streams the run status followed by
+	// result rows in batches, so large results need not fit in one message
+	StreamReportRun(*RunReportRequest, grpc.ServerStreamingServer[ReportRunEvent]) error
+	mustEmbedUnimplementedReportServiceServer()
+}
+
+// UnimplementedReportServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedReportServiceServer struct{}
+
+func (UnimplementedReportServiceServer) RunReport(context.Context, *RunReportRequest) (*ReportRun, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunReport not implemented")
+}
+func (UnimplementedReportServiceServer) StreamReportRun(*RunReportRequest, grpc.ServerStreamingServer[ReportRunEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamReportRun not implemented")
+}
+func (UnimplementedReportServiceServer) mustEmbedUnimplementedReportServiceServer() {}
+func (UnimplementedReportServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeReportServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReportServiceServer will
+// result in compilation errors.
+type UnsafeReportServiceServer interface {
+	mustEmbedUnimplementedReportServiceServer()
+}
+
+func RegisterReportServiceServer(s grpc.ServiceRegistrar, srv ReportServiceServer) {
+	// If the following call pancis, it indicates UnimplementedReportServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ReportService_ServiceDesc, srv)
+}
+
+func _ReportService_RunReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReportServiceServer).RunReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReportService_RunReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReportServiceServer).RunReport(ctx, req.(*RunReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReportService_StreamReportRun_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunReportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReportServiceServer).StreamReportRun(m, &grpc.GenericServerStream[RunReportRequest, ReportRunEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReportService_StreamReportRunServer = grpc.ServerStreamingServer[ReportRunEvent]
+
+// ReportService_ServiceDesc is the grpc.ServiceDesc for ReportService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ReportService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "air.v1.ReportService",
+	HandlerType: (*ReportServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RunReport",
+			Handler:    _ReportService_RunReport_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReportRun",
+			Handler:       _ReportService_StreamReportRun_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "air/v1/air.proto",
+}
+
+const (
+	ChatService_Chat_FullMethodName = "/air.v1.ChatService/Chat"
+)
+
+// ChatServiceClient is the client API for ChatService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ChatServiceClient interface {
+	// Chat streams the assistant reply of one completion as delta events, then
+	// done. The model clients do not stream tokens, so the reply currently
+	// arrives as a single delta.
+	Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChatEvent], error)
+}
+
+type chatServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChatServiceClient(cc grpc.ClientConnInterface) ChatServiceClient {
+	return &chatServiceClient{cc}
+}
+
+func (c *chatServiceClient) Chat(ctx context.Context, in *ChatRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChatEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChatService_ServiceDesc.Streams[0], ChatService_Chat_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ChatRequest, ChatEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatService_ChatClient = grpc.ServerStreamingClient[ChatEvent]
+
+// ChatServiceServer is the server API for ChatService service.
+// All implementations must embed UnimplementedChatServiceServer
+// for forward compatibility.
+type ChatServiceServer interface {
+	// Chat streams the assistant reply of one completion as delta events, then
+	// done. The model clients do not stream tokens, so the reply currently
+	// arrives as a single delta.
+	Chat(*ChatRequest, grpc.ServerStreamingServer[ChatEvent]) error
+	mustEmbedUnimplementedChatServiceServer()
+}
+
+// UnimplementedChatServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedChatServiceServer struct{}
+
+func (UnimplementedChatServiceServer) Chat(*ChatRequest, grpc.ServerStreamingServer[ChatEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Chat not implemented")
+}
+func (UnimplementedChatServiceServer) mustEmbedUnimplementedChatServiceServer() {}
+func (UnimplementedChatServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeChatServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChatServiceServer will
+// result in compilation errors.
+type UnsafeChatServiceServer interface {
+	mustEmbedUnimplementedChatServiceServer()
+}
+
+func RegisterChatServiceServer(s grpc.ServiceRegistrar, srv ChatServiceServer) {
+	// If the following call pancis, it indicates UnimplementedChatServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ChatService_ServiceDesc, srv)
+}
+
+func _ChatService_Chat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChatRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatServiceServer).Chat(m, &grpc.GenericServerStream[ChatRequest, ChatEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatService_ChatServer = grpc.ServerStreamingServer[ChatEvent]
+
+// ChatService_ServiceDesc is the grpc.ServiceDesc for ChatService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ChatService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "air.v1.ChatService",
+	HandlerType: (*ChatServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Chat",
+			Handler:       _ChatService_Chat_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "air/v1/air.proto",
+}
@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/gorilla/websocket"
+)
+
+// closeIdle is the close code sent to reaped connections; 4000-4999 are free
+// for applications
+const closeIdle = 4000
+
+// clientActivity holds a client's traffic counters. Fields are atomic because
+// the read and write pumps update them while stats are read.
+type clientActivity struct {
+	connectedAt  time.Time
+	lastActivity atomic.Int64 // unix nanoseconds of the last message from the client
+	lastPong     atomic.Int64 // unix nanoseconds of the last heartbeat pong
+	bytesIn      atomic.Int64
+	bytesOut     atomic.Int64
+	messagesIn   atomic.Int64
+	messagesOut  atomic.Int64
+}
+
+// ClientStats describes one connection for GetHubStats
+type ClientStats struct {
+	ClientID      string     `json:"client_id"`
+	UserID        string     `json:"user_id"`
+	Protocol      int        `json:"protocol"`
+	ConnectedAt   time.Time  `json:"connected_at"`
+	LastActivity  time.Time  `json:"last_activity"`
+	LastPong      *time.Time `json:"last_pong,omitempty"`
+	IdleSeconds   int64      `json:"idle_seconds"`
+	BytesIn       int64      `json:"bytes_in"`
+	BytesOut      int64      `json:"bytes_out"`
+	MessagesIn    int64      `json:"messages_in"`
+	MessagesOut   int64      `json:"messages_out"`
+	Subscriptions int        `json:"subscriptions"`
+}
+
+// HubStats summarizes the connections of a hub
+type HubStats struct {
+	Clients         []ClientStats  `json:"clients"`
+	UserConnections map[string]int `json:"user_connections"`
+	IdleTimeout     string         `json:"idle_timeout"`
+	ReapedTotal     int64          `json:"reaped_total"`
+	SlowDropped     int64          `json:"slow_dropped_total"`
+}
+
+// markConnected starts the activity clock of a new client
+func (c *Client) markConnected() {
+	now := time.Now()
+	c.activity.connectedAt = now
+	c.activity.lastActivity.Store(now.UnixNano())
+}
+
+// recordRead counts a message received from the client
+func (c *Client) recordRead(n int) {
+	c.activity.lastActivity.Store(time.Now().UnixNano())
+	c.activity.bytesIn.Add(int64(n))
+	c.activity.messagesIn.Add(1)
+}
+
+// recordWrite counts a message sent to the client
+func (c *Client) recordWrite(n int) {
+	c.activity.bytesOut.Add(int64(n))
+	c.activity.messagesOut.Add(1)
+}
+
+// recordPong notes a heartbeat answer. Pongs keep the connection open but do
+// not count as activity, so a tab left open with no traffic is still reaped.
+func (c *Client) recordPong() {
+	c.activity.lastPong.Store(time.Now().UnixNano())
+}
+
+// idleFor reports how long the client has sent nothing
+func (c *Client) idleFor(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, c.activity.lastActivity.Load()))
+}
+
+// stats snapshots the client's counters
+func (c *Client) stats(now time.Time) ClientStats {
+	c.mu.RLock()
+	subscriptions := len(c.Channels)
+	c.mu.RUnlock()
+
+	s := ClientStats{
+		ClientID:      c.ID,
+		UserID:        c.UserID,
+		Protocol:      c.Protocol,
+		ConnectedAt:   c.activity.connectedAt.UTC(),
+		LastActivity:  time.Unix(0, c.activity.lastActivity.Load()).UTC(),
+		IdleSeconds:   int64(c.idleFor(now) / time.Second),
+		BytesIn:       c.activity.bytesIn.Load(),
+		BytesOut:      c.activity.bytesOut.Load(),
+		MessagesIn:    c.activity.messagesIn.Load(),
+		MessagesOut:   c.activity.messagesOut.Load(),
+		Subscriptions: subscriptions,
+	}
+	if pong := c.activity.lastPong.Load(); pong != 0 {
+		at := time.Unix(0, pong).UTC()
+		s.LastPong = &at
+	}
+	return s
+}
+
+// Stats returns per-client activity and per-user connection counts, most
+// recently connected first
+func (h *Hub) Stats() HubStats {
+	h.Mu.RLock()
+	defer h.Mu.RUnlock()
+
+	now := time.Now()
+	stats := HubStats{
+		Clients:         make([]ClientStats, 0, len(h.Clients)),
+		UserConnections: make(map[string]int),
+		ReapedTotal:     h.reaped.Load(),
+		SlowDropped:     h.slowDropped.Load(),
+	}
+	if h.Config.IdleTimeout > 0 {
+		stats.IdleTimeout = h.Config.IdleTimeout.String()
+	}
+	for client := range h.Clients {
+		stats.Clients = append(stats.Clients, client.stats(now))
+		stats.UserConnections[client.UserID]++
+	}
+	sort.Slice(stats.Clients, func(i, j int) bool {
+		return stats.Clients[i].ConnectedAt.After(stats.Clients[j].ConnectedAt)
+	})
+	return stats
+}
+
+// reapInterval is how often idle connections are looked for
+func (h *Hub) reapInterval() time.Duration {
+	interval := h.Config.IdleTimeout / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// reapIdle closes connections that have sent nothing for longer than
+// websocket.idle_timeout. Closing the connection ends the read pump, which
+// unregisters the client as usual.
+func (h *Hub) reapIdle() {
+	now := time.Now()
+
+	h.Mu.RLock()
+	var idle []*Client
+	for client := range h.Clients {
+		if client.idleFor(now) > h.Config.IdleTimeout {
+			idle = append(idle, client)
+		}
+	}
+	h.Mu.RUnlock()
+
+	for _, client := range idle {
+		logger.LogInfo(logger.ServiceWS, "Reaping idle connection", map[string]interface{}{
+			"client_id":    client.ID,
+			"user_id":      client.UserID,
+			"idle_seconds": int64(client.idleFor(now) / time.Second),
+		})
+		message := websocket.FormatCloseMessage(closeIdle, "idle timeout")
+		client.Conn.WriteControl(websocket.CloseMessage, message, now.Add(time.Second))
+		client.Conn.Close()
+		h.reaped.Add(1)
+	}
+}
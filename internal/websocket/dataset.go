@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// SessionStore keeps the active dataset and the other loaded files on a
+// session record, so they survive reconnects and can be changed over the
+// REST API
+type SessionStore interface {
+	ActiveDataset(sessionID uint) (string, error)
+	SetActiveDataset(sessionID uint, fileID string) (*store.Session, error)
+	Datasets(sessionID uint) ([]string, error)
+}
+
+// activeDataset returns the file chat questions are answered from. A client
+// attached to a session reads it from the session on every question, so a
+// change made over REST or from another connection applies at once.
+func (c *Client) activeDataset() string {
+	if c.SessionID != 0 && c.Hub.Sessions != nil {
+		fileID, err := c.Hub.Sessions.ActiveDataset(c.SessionID)
+		if err == nil {
+			return fileID
+		}
+		logger.LogWarn(logger.ServiceWS, "Failed to read session dataset, using the connection's", map[string]interface{}{
+			"client_id":  c.ID,
+			"session_id": c.SessionID,
+			"error":      err.Error(),
+		})
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.selectedFile
+}
+
+// workspaceFiles lists the tabular files a question about the active dataset
+// may query: every CSV or TSV loaded into the client's session, so questions
+// can join them, or just the active one without a session
+func (c *Client) workspaceFiles(active string) []string {
+	files := []string{active}
+	if c.SessionID == 0 || c.Hub.Sessions == nil {
+		return files
+	}
+
+	loaded, err := c.Hub.Sessions.Datasets(c.SessionID)
+	if err != nil {
+		logger.LogWarn(logger.ServiceWS, "Failed to list session datasets, querying the active one", map[string]interface{}{
+			"client_id":  c.ID,
+			"session_id": c.SessionID,
+			"error":      err.Error(),
+		})
+		return files
+	}
+	for _, fileID := range loaded {
+		if fileID != active && services.IsTabularFile(fileID) {
+			files = append(files, fileID)
+		}
+	}
+	return files
+}
+
+// setActiveDataset selects a file for this connection and, when the client
+// is attached to a session, for the session too
+func (c *Client) setActiveDataset(fileID string) {
+	c.mu.Lock()
+	c.selectedFile = fileID
+	c.mu.Unlock()
+
+	if c.SessionID == 0 || c.Hub.Sessions == nil {
+		return
+	}
+	if _, err := c.Hub.Sessions.SetActiveDataset(c.SessionID, fileID); err != nil {
+		logger.LogError(logger.ServiceWS, "Failed to save session dataset", err, map[string]interface{}{
+			"client_id":  c.ID,
+			"session_id": c.SessionID,
+			"file_id":    fileID,
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+)
+
+// replyWait is how long a reply to the client's own request waits for room
+// in its send queue before the client is dropped as too slow, retrying every
+// replyRetry
+const (
+	replyWait  = time.Second
+	replyRetry = 10 * time.Millisecond
+)
+
+var (
+	// errClientClosed is returned when enqueueing to a client whose queue is closed
+	errClientClosed = errors.New("client is closed")
+	// errQueueFull is returned when a client's writer has fallen behind
+	errQueueFull = errors.New("client send queue is full")
+)
+
+// enqueue hands a message to the client's write pump without blocking. The
+// hub never waits on a client, so one slow connection cannot delay delivery
+// to the others.
+func (c *Client) enqueue(message []byte) error {
+	c.sendMu.RLock()
+	defer c.sendMu.RUnlock()
+
+	if c.sendClosed {
+		return errClientClosed
+	}
+	select {
+	case c.Send <- message:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+// enqueueReply hands a reply to the client's own request to its write pump,
+// waiting up to replyWait for room. A burst of broadcasts can fill the
+// queue for a moment, and the reply is what the client is waiting for, so
+// it is not given up on as quickly as a broadcast. Only the client's own
+// goroutines send replies, and the send lock is not held between tries, so
+// the wait delays no other client.
+func (c *Client) enqueueReply(message []byte) error {
+	deadline := time.Now().Add(replyWait)
+	for {
+		err := c.enqueue(message)
+		if err != errQueueFull || time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(replyRetry)
+	}
+}
+
+// closeSend closes the send queue once. The write pump then sends a close
+// frame and closes the connection, and the read pump unregisters the client.
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if !c.sendClosed {
+		c.sendClosed = true
+		close(c.Send)
+	}
+}
+
+// deliver enqueues a message to each client, disconnecting the ones that
+// cannot keep up. Callers collect recipients under the hub lock and call
+// deliver after releasing it.
+func (h *Hub) deliver(clients []*Client, message []byte) int {
+	delivered := 0
+	for _, client := range clients {
+		switch err := client.enqueue(message); err {
+		case nil:
+			delivered++
+		case errQueueFull:
+			h.dropSlow(client)
+		}
+	}
+	return delivered
+}
+
+// dropSlow disconnects a client whose send queue is full. It reconnects and
+// resubscribes, which is cheaper than holding up every other subscriber.
+func (h *Hub) dropSlow(client *Client) {
+	client.dropOnce.Do(func() {
+		logger.LogWarn(logger.ServiceWS, "Disconnecting slow client", map[string]interface{}{
+			"client_id":  client.ID,
+			"user_id":    client.UserID,
+			"queue_size": cap(client.Send),
+		})
+		h.slowDropped.Add(1)
+		client.closeSend()
+	})
+}
@@ -0,0 +1,34 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+)
+
+// FileAnalysisRecorder saves completed file analyses so they can be read
+// back over the REST API
+type FileAnalysisRecorder interface {
+	RecordFileAnalysis(ctx context.Context, userID, fileID, query, model, analysis string, insights, suggestions []string) (uint, error)
+}
+
+// recordFileAnalysis saves an analysis when the hub has a recorder, returning
+// its ID or 0. A failed save is logged; the client still gets its result.
+func (c *Client) recordFileAnalysis(fileID, query, model, analysis string, insights, suggestions []string) uint {
+	if c.Hub.FileAnalyses == nil {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, err := c.Hub.FileAnalyses.RecordFileAnalysis(ctx, c.UserID, fileID, query, model, analysis, insights, suggestions)
+	if err != nil {
+		logger.LogError(logger.ServiceWS, "Failed to save file analysis", err, map[string]interface{}{
+			"file_id":   fileID,
+			"client_id": c.ID,
+		})
+		return 0
+	}
+	return id
+}
@@ -9,11 +9,12 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NubeDev/air/internal/llm"
 	"github.com/NubeDev/air/internal/logger"
-	"github.com/NubeDev/air/internal/redis"
+	"github.com/NubeDev/air/internal/services"
 	"github.com/gorilla/websocket"
 )
 
@@ -34,8 +35,16 @@ type Client struct {
 	Send         chan []byte
 	Hub          *Hub
 	Channels     map[string]bool // Subscribed channels
+	Protocol     int             // Negotiated protocol version
+	SessionID    uint            // Session the active dataset is kept on; 0 keeps it on the connection
 	selectedFile string          // Currently selected file for analysis
+	activity     clientActivity
 	mu           sync.RWMutex
+
+	// Send is closed once, under sendMu, so enqueues never race the close
+	sendMu     sync.RWMutex
+	sendClosed bool
+	dropOnce   sync.Once
 }
 
 // Hub maintains the set of active clients and broadcasts messages to the clients
@@ -58,17 +67,34 @@ type Hub struct {
 	// Channel-specific messages
 	ChannelMessage chan ChannelMessage
 
-	// Redis client for pub/sub
-	Redis *redis.Client
+	// Transport carries channel messages between hubs
+	Transport Transport
 
 	// AI service for chat responses
 	AIService interface{}
 
+	// FileAnalyses saves completed file analyses; nil keeps them in the session only
+	FileAnalyses FileAnalysisRecorder
+
+	// Sessions keeps clients' active datasets on their session record
+	Sessions SessionStore
+
+	// Prompts offers and runs saved prompts in chat; nil disables them
+	Prompts PromptLibrary
+
 	// Configuration
 	Config *Config
 
 	// Mutex for thread safety
 	Mu sync.RWMutex
+
+	// Recent AI message times per user, for the per-minute quota
+	aiUsage   map[string][]time.Time
+	aiUsageMu sync.Mutex
+
+	// Connections closed for being idle, and for falling behind on delivery
+	reaped      atomic.Int64
+	slowDropped atomic.Int64
 }
 
 // ChannelMessage represents a message sent to a specific channel
@@ -86,10 +112,17 @@ type Config struct {
 	PongWait          time.Duration
 	MaxMessageSize    int64
 	EnableCompression bool
+	IdleTimeout       time.Duration // close connections that send nothing for this long; 0 disables
+
+	// AI message validation
+	DefaultModel        string            // used when a raw AI message names no model
+	ModelAliases        map[string]string // provider shorthands such as "llama" to model names
+	AllowedModels       []string          // models clients may request; anything else is rejected
+	AIMessagesPerMinute int               // per-user limit on chat and raw AI messages; 0 disables
 }
 
 // NewHub creates a new WebSocket hub
-func NewHub(redisClient *redis.Client, config *Config, aiService interface{}) *Hub {
+func NewHub(transport Transport, config *Config, aiService interface{}) *Hub {
 	hub := &Hub{
 		Clients:        make(map[*Client]bool),
 		Channels:       make(map[string]map[*Client]bool),
@@ -97,14 +130,15 @@ func NewHub(redisClient *redis.Client, config *Config, aiService interface{}) *H
 		Unregister:     make(chan *Client),
 		Broadcast:      make(chan []byte),
 		ChannelMessage: make(chan ChannelMessage),
-		Redis:          redisClient,
+		Transport:      transport,
 		Config:         config,
+		aiUsage:        make(map[string][]time.Time),
 	}
 
 	// Set AI service if it implements the required interface
 	if aiService != nil {
 		if ai, ok := aiService.(interface {
-			ChatCompletion(messages []llm.Message) (*llm.ChatResponse, error)
+			ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error)
 		}); ok {
 			hub.AIService = ai
 		}
@@ -115,11 +149,24 @@ func NewHub(redisClient *redis.Client, config *Config, aiService interface{}) *H
 
 // Run starts the hub
 func (h *Hub) Run(ctx context.Context) {
-	logger.LogInfo(logger.ServiceWS, "Starting WebSocket hub")
+	logger.LogInfo(logger.ServiceWS, "Starting WebSocket hub", map[string]interface{}{
+		"transport": h.Transport.Name(),
+	})
+
+	// Deliver messages published on any hub sharing the transport
+	go h.Transport.Run(ctx, func(channel string, message []byte) {
+		select {
+		case h.ChannelMessage <- ChannelMessage{Channel: channel, Message: message}:
+		case <-ctx.Done():
+		}
+	})
 
-	// Start Redis subscriber if Redis is available
-	if h.Redis != nil {
-		go h.runRedisSubscriber(ctx)
+	// A nil channel never fires, so reaping is off without an idle timeout
+	var reap <-chan time.Time
+	if h.Config.IdleTimeout > 0 {
+		ticker := time.NewTicker(h.reapInterval())
+		defer ticker.Stop()
+		reap = ticker.C
 	}
 
 	for {
@@ -136,53 +183,12 @@ func (h *Hub) Run(ctx context.Context) {
 		case channelMsg := <-h.ChannelMessage:
 			h.broadcastToChannel(channelMsg.Channel, channelMsg.Message)
 
-		case <-ctx.Done():
-			logger.LogInfo(logger.ServiceWS, "WebSocket hub shutting down")
-			return
-		}
-	}
-}
-
-// runRedisSubscriber subscribes to Redis channels and forwards messages
-func (h *Hub) runRedisSubscriber(ctx context.Context) {
-	if h.Redis == nil {
-		return
-	}
-
-	// Subscribe to all channels
-	channels := []string{
-		"websocket:chat:*",
-		"websocket:ai:*",
-		"websocket:typing:*",
-		"websocket:presence:*",
-		"websocket:system:*",
-	}
-
-	pubsub := h.Redis.Subscribe(ctx, channels...)
-	defer pubsub.Close()
-
-	logger.LogInfo(logger.ServiceWS, "Redis subscriber started", map[string]interface{}{
-		"channels": channels,
-	})
+		case <-reap:
+			go h.reapIdle()
 
-	for {
-		select {
 		case <-ctx.Done():
-			logger.LogInfo(logger.ServiceWS, "Redis subscriber shutting down")
+			logger.LogInfo(logger.ServiceWS, "WebSocket hub shutting down")
 			return
-		default:
-			msg, err := pubsub.ReceiveMessage(ctx)
-			if err != nil {
-				logger.LogError(logger.ServiceWS, "Redis subscription error", err)
-				time.Sleep(1 * time.Second)
-				continue
-			}
-
-			// Forward Redis message to WebSocket clients
-			h.ChannelMessage <- ChannelMessage{
-				Channel: msg.Channel,
-				Message: []byte(msg.Payload),
-			}
 		}
 	}
 }
@@ -194,6 +200,7 @@ func (h *Hub) registerClient(client *Client) {
 
 	h.Clients[client] = true
 	client.Channels = make(map[string]bool)
+	client.markConnected()
 
 	logger.LogInfo(logger.ServiceWS, "Client registered", map[string]interface{}{
 		"client_id":     client.ID,
@@ -201,6 +208,11 @@ func (h *Hub) registerClient(client *Client) {
 		"total_clients": len(h.Clients),
 	})
 
+	// Queue the welcome first so it precedes every reply; Send is buffered
+	if welcome, err := json.Marshal(client.welcomeMessage()); err == nil {
+		client.enqueue(welcome)
+	}
+
 	// Start client goroutines
 	go client.writePump()
 	go client.readPump()
@@ -223,7 +235,7 @@ func (h *Hub) unregisterClient(client *Client) {
 		}
 
 		delete(h.Clients, client)
-		close(client.Send)
+		client.closeSend()
 
 		logger.LogInfo(logger.ServiceWS, "Client unregistered", map[string]interface{}{
 			"client_id":     client.ID,
@@ -236,33 +248,26 @@ func (h *Hub) unregisterClient(client *Client) {
 // broadcastToAll broadcasts a message to all connected clients
 func (h *Hub) broadcastToAll(message []byte) {
 	h.Mu.RLock()
-	defer h.Mu.RUnlock()
-
+	clients := make([]*Client, 0, len(h.Clients))
 	for client := range h.Clients {
-		select {
-		case client.Send <- message:
-		default:
-			close(client.Send)
-			delete(h.Clients, client)
-		}
+		clients = append(clients, client)
 	}
+	h.Mu.RUnlock()
+
+	h.deliver(clients, message)
 }
 
 // broadcastToChannel broadcasts a message to clients subscribed to a specific channel
 func (h *Hub) broadcastToChannel(channel string, message []byte) {
 	h.Mu.RLock()
-	defer h.Mu.RUnlock()
-
-	if channelClients, exists := h.Channels[channel]; exists {
-		for client := range channelClients {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(h.Clients, client)
-			}
-		}
+	channelClients := h.Channels[channel]
+	clients := make([]*Client, 0, len(channelClients))
+	for client := range channelClients {
+		clients = append(clients, client)
 	}
+	h.Mu.RUnlock()
+
+	h.deliver(clients, message)
 }
 
 // SubscribeToChannel subscribes a client to a channel
@@ -315,21 +320,15 @@ func (h *Hub) SendToUser(userID string, message Message) error {
 	}
 
 	h.Mu.RLock()
-	defer h.Mu.RUnlock()
-
-	sent := false
+	var clients []*Client
 	for client := range h.Clients {
 		if client.UserID == userID {
-			select {
-			case client.Send <- messageBytes:
-				sent = true
-			default:
-				// Client channel is full, skip
-			}
+			clients = append(clients, client)
 		}
 	}
+	h.Mu.RUnlock()
 
-	if !sent {
+	if h.deliver(clients, messageBytes) == 0 {
 		logger.LogWarn(logger.ServiceWS, "No active clients found for user", map[string]interface{}{
 			"user_id": userID,
 		})
@@ -338,18 +337,14 @@ func (h *Hub) SendToUser(userID string, message Message) error {
 	return nil
 }
 
-// PublishToRedis publishes a message to Redis for distribution
-func (h *Hub) PublishToRedis(ctx context.Context, channel string, message Message) error {
-	if h.Redis == nil {
-		return fmt.Errorf("redis client is not available")
-	}
-
+// Publish sends a message to subscribers of a hub channel on every hub
+// sharing the transport
+func (h *Hub) Publish(ctx context.Context, channel string, message Message) error {
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
-
-	return h.Redis.Publish(ctx, channel, messageBytes)
+	return h.Transport.Publish(ctx, channel, messageBytes)
 }
 
 // readPump pumps messages from the websocket connection to the hub
@@ -362,6 +357,7 @@ func (c *Client) readPump() {
 	c.Conn.SetReadLimit(c.Hub.Config.MaxMessageSize)
 	c.Conn.SetReadDeadline(time.Now().Add(c.Hub.Config.PongWait))
 	c.Conn.SetPongHandler(func(string) error {
+		c.recordPong()
 		c.Conn.SetReadDeadline(time.Now().Add(c.Hub.Config.PongWait))
 		return nil
 	})
@@ -376,6 +372,18 @@ func (c *Client) readPump() {
 			}
 			break
 		}
+		c.recordRead(len(messageBytes))
+
+		// Check the message against the protocol before handling it
+		if msgType, err := validateInbound(messageBytes, c.Protocol); err != nil {
+			logger.LogWarn(logger.ServiceWS, "Rejected invalid message", map[string]interface{}{
+				"client_id": c.ID,
+				"type":      msgType,
+				"error":     err.Error(),
+			})
+			c.sendProtocolError(msgType, err)
+			continue
+		}
 
 		// Parse message
 		var message Message
@@ -413,12 +421,15 @@ func (c *Client) writePump() {
 				return
 			}
 			w.Write(message)
+			c.recordWrite(len(message))
 
 			// Add queued chat messages to the current websocket message
 			n := len(c.Send)
 			for i := 0; i < n; i++ {
+				queued := <-c.Send
 				w.Write([]byte{'\n'})
-				w.Write(<-c.Send)
+				w.Write(queued)
+				c.recordWrite(len(queued) + 1)
 			}
 
 			if err := w.Close(); err != nil {
@@ -452,7 +463,9 @@ func (c *Client) handleMessage(message Message) {
 			Timestamp: time.Now(),
 		}
 		responseBytes, _ := json.Marshal(response)
-		c.Send <- responseBytes
+		if err := c.enqueueReply(responseBytes); err == errQueueFull {
+			c.Hub.dropSlow(c)
+		}
 	case "file_analysis":
 		// Handle file analysis request
 		c.handleFileAnalysis(message)
@@ -468,24 +481,31 @@ func (c *Client) handleMessage(message Message) {
 	case "ephemeral_file_select":
 		// Handle file selection from ephemeral card
 		c.handleEphemeralFileSelect(message)
+	case "prompt_suggest":
+		// Offer saved prompts for chat autocomplete
+		c.handlePromptSuggest(message)
+	case "run_prompt":
+		// Run a saved prompt against the active dataset or a datasource
+		c.handleRunPrompt(message)
 	default:
-		// Forward message to Redis for distribution
+		// Forward message to the hubs for distribution
 		message.UserID = c.UserID
 		message.Timestamp = time.Now()
 
-		// Determine Redis channel based on message type
-		redisChannel := fmt.Sprintf("websocket:%s", message.Type)
+		// Determine hub channel based on message type
+		channel := message.Type
 		if message.Channel != "" {
-			redisChannel = fmt.Sprintf("websocket:%s:%s", message.Type, message.Channel)
+			channel = fmt.Sprintf("%s:%s", message.Type, message.Channel)
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		if err := c.Hub.PublishToRedis(ctx, redisChannel, message); err != nil {
-			logger.LogError(logger.ServiceWS, "Failed to publish message to Redis", err, map[string]interface{}{
+		if err := c.Hub.Publish(ctx, channel, message); err != nil {
+			logger.LogError(logger.ServiceWS, "Failed to publish message", err, map[string]interface{}{
 				"client_id": c.ID,
-				"channel":   redisChannel,
+				"channel":   channel,
+				"transport": c.Hub.Transport.Name(),
 			})
 		}
 	}
@@ -563,11 +583,18 @@ func (c *Client) performFileAnalysis(fileID, query, model string) {
 		return
 	}
 
+	// Save real analyses, not the placeholder sent without an AI service
+	var analysisID uint
+	if c.Hub.AIService != nil {
+		analysisID = c.recordFileAnalysis(fileID, query, model, analysis, insights, suggestions)
+	}
+
 	// Send analysis complete message
 	if c.isConnected() {
 		c.sendMessage(Message{
 			Type: "file_analysis_complete",
 			Payload: map[string]interface{}{
+				"analysis_id": analysisID,
 				"file_id":     fileID,
 				"query":       query,
 				"model":       model,
@@ -625,26 +652,14 @@ func (c *Client) sendMessage(message Message) {
 		"message_size": len(messageBytes),
 	})
 
-	// Check if client is still connected before sending
-	if !c.isConnected() {
+	switch err := c.enqueueReply(messageBytes); err {
+	case errClientClosed:
 		logger.LogWarn(logger.ServiceWS, "Client disconnected, skipping message", map[string]interface{}{
 			"client_id": c.ID,
 			"type":      message.Type,
 		})
-		return
-	}
-
-	// Try to send message with timeout
-	select {
-	case c.Send <- messageBytes:
-		// Message sent successfully
-	case <-time.After(1 * time.Second):
-		// Channel is likely closed or blocked
-		logger.LogWarn(logger.ServiceWS, "Failed to send message - channel closed or blocked", map[string]interface{}{
-			"client_id": c.ID,
-			"type":      message.Type,
-		})
-		return
+	case errQueueFull:
+		c.Hub.dropSlow(c)
 	}
 }
 
@@ -681,6 +696,10 @@ func (c *Client) handleChatMessage(message Message) {
 		model = "llama"
 	}
 
+	if !c.checkAIQuota("chat_error") {
+		return
+	}
+
 	logger.LogInfo(logger.ServiceWS, "Processing chat message", map[string]interface{}{
 		"content": content,
 		"model":   model,
@@ -709,19 +728,22 @@ func (c *Client) handleRawAIMessage(message Message) {
 		return
 	}
 
-	model, _ := message.Payload["model"].(string)
-	if model == "" {
-		model = "gpt-4o-mini" // Default to the actual OpenAI model name
+	requested, _ := message.Payload["model"].(string)
+	model, err := c.Hub.resolveModel(requested)
+	if err != nil {
+		logger.LogWarn(logger.ServiceWS, "Rejected raw AI message model", map[string]interface{}{
+			"model":   requested,
+			"user_id": c.UserID,
+		})
+		c.sendAIError("raw_ai_error", "model_not_allowed", err, map[string]interface{}{
+			"model":          requested,
+			"allowed_models": c.Hub.Config.AllowedModels,
+		})
+		return
 	}
 
-	// Map provider names to actual model names
-	switch model {
-	case "openai":
-		model = "gpt-4o-mini"
-	case "llama":
-		model = "llama3:latest"
-	case "sqlcoder":
-		model = "sqlcoder:7b"
+	if !c.checkAIQuota("raw_ai_error") {
+		return
 	}
 
 	logger.LogInfo(logger.ServiceWS, "Processing raw AI message", map[string]interface{}{
@@ -811,14 +833,14 @@ func (c *Client) callRawAIService(content, model string) (string, error) {
 
 	// Type assert to get the AiRaw method
 	aiService, ok := c.Hub.AIService.(interface {
-		AiRaw(messages []llm.Message, modelOverride string) (*llm.ChatResponse, error)
+		AiRaw(ctx context.Context, messages []llm.Message, modelOverride string) (*llm.ChatResponse, error)
 	})
 	if !ok {
 		return "AI service does not support raw mode.", nil
 	}
 
 	// Call the raw AI service
-	response, err := aiService.AiRaw(messages, model)
+	response, err := aiService.AiRaw(context.Background(), messages, model)
 	if err != nil {
 		return "", fmt.Errorf("raw AI service call failed: %w", err)
 	}
@@ -905,8 +927,10 @@ func (c *Client) callAIService(content, model string) (string, error) {
 		strings.Contains(strings.ToLower(content), "find") ||
 		strings.Contains(strings.ToLower(content), "list")
 
+	dataset := c.activeDataset()
+
 	// If user asks for analysis but has no file loaded, send file needed message
-	if isAnalysisRequest && c.selectedFile == "" {
+	if isAnalysisRequest && dataset == "" {
 		// Send ephemeral message to prompt for file selection
 		c.sendMessage(Message{
 			Type: "ephemeral_file_needed",
@@ -922,10 +946,17 @@ func (c *Client) callAIService(content, model string) (string, error) {
 	}
 
 	// If user asks for analysis and has a loaded file, analyze it
-	if isAnalysisRequest && c.selectedFile != "" {
+	if isAnalysisRequest && dataset != "" {
+		// Tabular files are answered with SQL over the whole file; the raw
+		// preview below is the fallback and the path for unstructured text
+		if services.IsTabularFile(dataset) {
+			if answer, ok := c.answerWithSQL(c.workspaceFiles(dataset), content); ok {
+				return answer, nil
+			}
+		}
 
 		// Get file data for analysis
-		fileData, err := c.getFileDataForAnalysis(c.selectedFile)
+		fileData, err := c.getFileDataForAnalysis(dataset)
 		if err == nil && fileData != "" {
 			messages = []llm.Message{
 				{
@@ -966,14 +997,14 @@ func (c *Client) callAIService(content, model string) (string, error) {
 
 	// Type assert to get the ChatCompletion method
 	aiService, ok := c.Hub.AIService.(interface {
-		ChatCompletion(messages []llm.Message) (*llm.ChatResponse, error)
+		ChatCompletion(ctx context.Context, messages []llm.Message) (*llm.ChatResponse, error)
 	})
 	if !ok {
 		return "AI service is not available.", nil
 	}
 
 	// Call the AI service
-	response, err := aiService.ChatCompletion(messages)
+	response, err := aiService.ChatCompletion(context.Background(), messages)
 	if err != nil {
 		return "", fmt.Errorf("AI service call failed: %w", err)
 	}
@@ -996,10 +1027,8 @@ func (c *Client) handleLoadDataset(message Message) {
 		return
 	}
 
-	// Set the selected file
-	c.mu.Lock()
-	c.selectedFile = filename
-	c.mu.Unlock()
+	// Set the selected file, on the session too when there is one
+	c.setActiveDataset(filename)
 
 	// Send success response
 	c.sendMessage(Message{
@@ -1063,6 +1092,32 @@ func (c *Client) getFileDataForAnalysis(fileID string) (string, error) {
 	return fileData, nil
 }
 
+// answerWithSQL answers a question about the loaded tabular files by
+// querying them. It reports false when the question could not be answered
+// that way, so the caller falls back to reading the active file as text.
+func (c *Client) answerWithSQL(fileIDs []string, question string) (string, bool) {
+	aiService, ok := c.Hub.AIService.(interface {
+		AnswerFileQuestion(ctx context.Context, paths []string, question string) (*services.FileAnswer, error)
+	})
+	if !ok {
+		return "", false
+	}
+
+	paths := make([]string, len(fileIDs))
+	for i, fileID := range fileIDs {
+		paths[i] = filepath.Join("uploads", fileID)
+	}
+	answer, err := aiService.AnswerFileQuestion(context.Background(), paths, question)
+	if err != nil {
+		logger.LogWarn(logger.ServiceWS, "SQL file answer failed, falling back to file preview", map[string]interface{}{
+			"file_ids": fileIDs,
+			"error":    err.Error(),
+		})
+		return "", false
+	}
+	return fmt.Sprintf("%s\n\n```sql\n%s\n```", answer.Answer, answer.SQL), true
+}
+
 // analyzeFileWithAI analyzes a file using real AI
 func (c *Client) analyzeFileWithAI(filePath, query, model string) (string, []string, []string, error) {
 	if c.Hub.AIService == nil {
@@ -1087,41 +1142,20 @@ func (c *Client) analyzeFileWithAI(filePath, query, model string) (string, []str
 	}
 	fileContent := string(buffer[:n])
 
-	// Create AI prompt for file analysis
-	messages := []llm.Message{
-		{
-			Role:    "system",
-			Content: "You are a data analysis expert. Analyze the provided file content and provide insights, suggestions, and a summary. Be specific and actionable.",
-		},
-		{
-			Role:    "user",
-			Content: fmt.Sprintf("Please analyze this file content:\n\n%s\n\nUser query: %s\n\nProvide:\n1. A summary analysis\n2. Key insights (as a JSON array)\n3. Actionable suggestions (as a JSON array)", fileContent, query),
-		},
-	}
-
-	// Type assert to get the ChatCompletion method
+	// The AI service prompts for strict JSON and validates the answer
 	aiService, ok := c.Hub.AIService.(interface {
-		ChatCompletion(messages []llm.Message) (*llm.ChatResponse, error)
+		AnalyzeFileContent(ctx context.Context, filename, content, query string) (*services.FileAnalysisResult, error)
 	})
 	if !ok {
 		return "", nil, nil, fmt.Errorf("AI service is not available")
 	}
 
-	// Call AI service
-	response, err := aiService.ChatCompletion(messages)
+	result, err := aiService.AnalyzeFileContent(context.Background(), filepath.Base(filePath), fileContent, query)
 	if err != nil {
 		return "", nil, nil, fmt.Errorf("AI analysis failed: %w", err)
 	}
 
-	// Parse the response (simplified - in production, you'd want more robust parsing)
-	analysis := response.Message.Content
-
-	// Extract insights and suggestions from AI response
-	// For now, return empty arrays - the AI response should contain the insights
-	insights := []string{}
-	suggestions := []string{}
-
-	return analysis, insights, suggestions, nil
+	return result.Summary, result.Insights, result.Suggestions, nil
 }
 
 // getAvailableFiles returns a list of available files for the client
@@ -1170,11 +1204,6 @@ func (c *Client) handleEphemeralFileSelect(message Message) {
 		return
 	}
 
-	// Set the selected file
-	c.mu.Lock()
-	c.selectedFile = fileID
-	c.mu.Unlock()
-
 	// Get file info for confirmation
 	files := c.getAvailableFiles()
 	var selectedFile map[string]interface{}
@@ -1186,6 +1215,8 @@ func (c *Client) handleEphemeralFileSelect(message Message) {
 	}
 
 	if selectedFile != nil {
+		c.setActiveDataset(fileID)
+
 		// Send confirmation message
 		c.sendMessage(Message{
 			Type: "ephemeral_file_loaded",
@@ -0,0 +1,236 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NubeDev/air/internal/config"
+	"github.com/NubeDev/air/internal/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+)
+
+// hubTransports are the transports every hub flow is checked on. Each
+// returns a transport factory; hubs made from one factory share messages.
+var hubTransports = []struct {
+	name string
+	new  func(t *testing.T) func() Transport
+}{
+	{"memory", func(t *testing.T) func() Transport {
+		transport := NewMemoryTransport()
+		return func() Transport { return transport }
+	}},
+	{"redis", func(t *testing.T) func() Transport {
+		server := miniredis.RunT(t)
+		return func() Transport {
+			client, err := redis.NewClient(&config.RedisConfig{Enabled: true, URL: "redis://" + server.Addr(), KeyPrefix: "test"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { client.Close() })
+			return NewRedisTransport(client)
+		}
+	}},
+}
+
+// startHub runs a hub on transport and serves WebSocket connections to it,
+// returning the hub and the URL clients dial
+func startHub(t *testing.T, transport Transport) (*Hub, string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	hub := NewHub(transport, &Config{
+		PingPeriod:     time.Minute,
+		PongWait:       2 * time.Minute,
+		MaxMessageSize: 1 << 16,
+	}, nil)
+	go hub.Run(ctx)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Register <- &Client{
+			ID:       r.URL.Query().Get("id"),
+			UserID:   r.URL.Query().Get("id"),
+			Conn:     conn,
+			Send:     make(chan []byte, 256),
+			Hub:      hub,
+			Protocol: 1,
+		}
+	}))
+	t.Cleanup(server.Close)
+	return hub, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// waitSubscribed returns once the given number of hubs listen on a Redis
+// transport, since messages published before then are not delivered
+func waitSubscribed(t *testing.T, transport Transport, hubs int64) {
+	redisTransport, ok := transport.(*redisTransport)
+	if !ok {
+		return
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		patterns, err := redisTransport.client.GetClient().PubSubNumPat(context.Background()).Result()
+		if err == nil && patterns >= hubs {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Redis subscriber did not start")
+}
+
+// testConn is a connected test client
+type testConn struct {
+	t       *testing.T
+	conn    *websocket.Conn
+	pending []Message // read but not yet returned
+}
+
+// dial connects a client and reads its welcome
+func dial(t *testing.T, url, id string) *testConn {
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?id="+id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &testConn{t: t, conn: conn}
+	if welcome := c.read(); welcome.Type != "welcome" {
+		t.Fatalf("first message is %q, want welcome", welcome.Type)
+	}
+	return c
+}
+
+func (c *testConn) send(message map[string]interface{}) {
+	if err := c.conn.WriteJSON(message); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read returns the next message. The write pump joins queued messages into
+// one frame, one per line.
+func (c *testConn) read() Message {
+	if len(c.pending) == 0 {
+		c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, frame, err := c.conn.ReadMessage()
+		if err != nil {
+			c.t.Fatalf("read: %v", err)
+		}
+		for _, line := range strings.Split(string(frame), "\n") {
+			var message Message
+			if err := json.Unmarshal([]byte(line), &message); err != nil {
+				c.t.Fatalf("invalid message %q: %v", line, err)
+			}
+			c.pending = append(c.pending, message)
+		}
+	}
+	message := c.pending[0]
+	c.pending = c.pending[1:]
+	return message
+}
+
+// subscribe subscribes to channel and waits until the hub has done so: the
+// pong to a following ping is sent after the subscribe is handled
+func (c *testConn) subscribe(channel string) {
+	c.send(map[string]interface{}{"type": "subscribe", "payload": map[string]interface{}{"channel": channel}})
+	c.send(map[string]interface{}{"type": "ping", "payload": map[string]interface{}{}})
+	if pong := c.read(); pong.Type != "pong" {
+		c.t.Fatalf("got %q, want pong", pong.Type)
+	}
+}
+
+func TestChannelMessagesReachSubscribers(t *testing.T) {
+	for _, tt := range hubTransports {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := tt.new(t)()
+			_, url := startHub(t, transport)
+			waitSubscribed(t, transport, 1)
+
+			subscriber := dial(t, url, "alice")
+			subscriber.subscribe("chat:general")
+			sender := dial(t, url, "bob")
+
+			sender.send(map[string]interface{}{"type": "chat", "channel": "general", "payload": map[string]interface{}{"text": "hello"}})
+			got := subscriber.read()
+			if got.Type != "chat" || got.UserID != "bob" || got.Payload["text"] != "hello" {
+				t.Fatalf("unexpected message %+v", got)
+			}
+		})
+	}
+}
+
+func TestRunEventsReachSubscribers(t *testing.T) {
+	for _, tt := range hubTransports {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := tt.new(t)()
+			hub, url := startHub(t, transport)
+			waitSubscribed(t, transport, 1)
+
+			subscriber := dial(t, url, "alice")
+			subscriber.subscribe("events:report.run.completed")
+
+			// As the handler's ForwardEvents publishes lifecycle events
+			err := hub.Publish(context.Background(), "events:report.run.completed", Message{
+				Type:      "report.run.completed",
+				Channel:   "events:report.run.completed",
+				Payload:   map[string]interface{}{"trace_id": "3f2a9c1d0b7e4a55", "rows": 3},
+				Timestamp: time.Now(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := subscriber.read()
+			if got.Type != "report.run.completed" || got.Payload["trace_id"] != "3f2a9c1d0b7e4a55" {
+				t.Fatalf("unexpected message %+v", got)
+			}
+		})
+	}
+}
+
+func TestFileAnalysisRepliesReachTheRequester(t *testing.T) {
+	for _, tt := range hubTransports {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := tt.new(t)()
+			_, url := startHub(t, transport)
+			waitSubscribed(t, transport, 1)
+
+			client := dial(t, url, "alice")
+			client.send(map[string]interface{}{"type": "file_analysis", "payload": map[string]interface{}{"file_id": "readings.csv", "query": "trends?"}})
+
+			var types []string
+			for _, want := range []string{"file_analysis_started", "file_analysis_complete"} {
+				got := client.read()
+				types = append(types, got.Type)
+				if got.Type != want || got.Payload["file_id"] != "readings.csv" {
+					t.Fatalf("got %v, want file_analysis_started then file_analysis_complete", types)
+				}
+			}
+		})
+	}
+}
+
+func TestChannelMessagesCrossRedisHubs(t *testing.T) {
+	newTransport := hubTransports[1].new(t)
+	transportA, transportB := newTransport(), newTransport()
+	_, urlA := startHub(t, transportA)
+	_, urlB := startHub(t, transportB)
+	waitSubscribed(t, transportA, 2)
+
+	subscriber := dial(t, urlB, "alice")
+	subscriber.subscribe("chat:general")
+	sender := dial(t, urlA, "bob")
+
+	sender.send(map[string]interface{}{"type": "chat", "channel": "general", "payload": map[string]interface{}{"text": "hello"}})
+	got := subscriber.read()
+	if got.Type != "chat" || got.UserID != "bob" || got.Payload["text"] != "hello" {
+		t.Fatalf("unexpected message %+v", got)
+	}
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrModelNotAllowed is returned for models outside the hub's allow-list
+	ErrModelNotAllowed = errors.New("model not allowed")
+	// ErrAIQuotaExceeded is returned when a user sent too many AI messages
+	ErrAIQuotaExceeded = errors.New("AI message quota exceeded")
+)
+
+// aiQuotaWindow is the window ai_messages_per_minute is counted over
+const aiQuotaWindow = time.Minute
+
+// resolveModel maps a client-requested model or alias to a model name and
+// checks it against the allow-list. An empty request uses the default model.
+func (h *Hub) resolveModel(requested string) (string, error) {
+	model := requested
+	if model == "" {
+		model = h.Config.DefaultModel
+	}
+	if alias, ok := h.Config.ModelAliases[model]; ok {
+		model = alias
+	}
+	for _, allowed := range h.Config.AllowedModels {
+		if model == allowed {
+			return model, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrModelNotAllowed, requested)
+}
+
+// allowAIMessage records an AI message for userID and reports whether it fits
+// the per-minute quota, with how long to wait when it does not
+func (h *Hub) allowAIMessage(userID string) (bool, time.Duration) {
+	limit := h.Config.AIMessagesPerMinute
+	if limit <= 0 {
+		return true, 0
+	}
+
+	h.aiUsageMu.Lock()
+	defer h.aiUsageMu.Unlock()
+
+	now := time.Now()
+	recent := h.aiUsage[userID][:0]
+	for _, sent := range h.aiUsage[userID] {
+		if now.Sub(sent) < aiQuotaWindow {
+			recent = append(recent, sent)
+		}
+	}
+	if len(recent) >= limit {
+		h.aiUsage[userID] = recent
+		return false, aiQuotaWindow - now.Sub(recent[0])
+	}
+	h.aiUsage[userID] = append(recent, now)
+	return true, 0
+}
+
+// checkAIQuota sends a quota error to the client when it is over its quota
+func (c *Client) checkAIQuota(errorType string) bool {
+	ok, retryAfter := c.Hub.allowAIMessage(c.UserID)
+	if !ok {
+		c.sendAIError(errorType, "quota_exceeded", ErrAIQuotaExceeded, map[string]interface{}{
+			"limit_per_minute":    c.Hub.Config.AIMessagesPerMinute,
+			"retry_after_seconds": int(retryAfter.Round(time.Second) / time.Second),
+		})
+	}
+	return ok
+}
+
+// sendAIError sends a structured error for a rejected AI message
+func (c *Client) sendAIError(errorType, code string, err error, details map[string]interface{}) {
+	payload := map[string]interface{}{
+		"code":  code,
+		"error": err.Error(),
+	}
+	for key, value := range details {
+		payload[key] = value
+	}
+	c.sendMessage(Message{
+		Type:      errorType,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/services"
+	"github.com/NubeDev/air/internal/store"
+)
+
+// promptRunTimeout bounds a saved prompt run started from chat
+const promptRunTimeout = 2 * time.Minute
+
+// PromptLibrary lists and runs the saved prompts a user can see
+type PromptLibrary interface {
+	ListPrompts(user, prefix string) ([]store.SavedPrompt, error)
+	RunPrompt(ctx context.Context, id uint, user string, req store.RunSavedPromptRequest) (*services.PromptRun, error)
+}
+
+// handlePromptSuggest answers prompt_suggest with the saved prompts whose
+// names start with the typed prefix
+func (c *Client) handlePromptSuggest(message Message) {
+	prefix, _ := message.Payload["prefix"].(string)
+	if c.Hub.Prompts == nil {
+		c.sendError("saved prompts are not available")
+		return
+	}
+
+	prompts, err := c.Hub.Prompts.ListPrompts(c.UserID, prefix)
+	if err != nil {
+		logger.LogError(logger.ServiceWS, "Failed to list saved prompts", err, map[string]interface{}{
+			"client_id": c.ID,
+			"prefix":    prefix,
+		})
+		c.sendError("failed to list saved prompts")
+		return
+	}
+
+	suggestions := make([]map[string]interface{}, len(prompts))
+	for i, prompt := range prompts {
+		suggestions[i] = map[string]interface{}{
+			"id":          prompt.ID,
+			"name":        prompt.Name,
+			"description": prompt.Description,
+			"text":        prompt.Text,
+			"params":      prompt.Params,
+			"shared":      prompt.Shared,
+		}
+	}
+	c.sendMessage(Message{
+		Type: "prompt_suggestions",
+		Payload: map[string]interface{}{
+			"prefix":  prefix,
+			"prompts": suggestions,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// handleRunPrompt runs a saved prompt against the client's tabular dataset.
+// Without an active CSV or TSV file it runs against the datasource in the
+// payload, else the user's default datasource.
+func (c *Client) handleRunPrompt(message Message) {
+	id, _ := message.Payload["prompt_id"].(float64)
+	if id <= 0 {
+		c.sendError("prompt_id is required")
+		return
+	}
+	if c.Hub.Prompts == nil {
+		c.sendError("saved prompts are not available")
+		return
+	}
+
+	req := store.RunSavedPromptRequest{Params: map[string]string{}}
+	req.DatasourceID, _ = message.Payload["datasource_id"].(string)
+	if params, ok := message.Payload["params"].(map[string]interface{}); ok {
+		for name, value := range params {
+			req.Params[name] = fmt.Sprint(value)
+		}
+	}
+	if active := c.activeDataset(); active != "" && services.IsTabularFile(active) {
+		req.SessionID = c.SessionID
+		req.FileIDs = c.workspaceFiles(active)
+	}
+
+	if !c.checkAIQuota("chat_error") {
+		return
+	}
+
+	c.sendMessage(Message{
+		Type: "chat_typing",
+		Payload: map[string]interface{}{
+			"is_typing": true,
+		},
+		Timestamp: time.Now(),
+	})
+
+	go c.processRunPrompt(uint(id), req)
+}
+
+// processRunPrompt runs a saved prompt and sends its answer as a chat response
+func (c *Client) processRunPrompt(id uint, req store.RunSavedPromptRequest) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogError(logger.ServiceWS, "Panic in processRunPrompt", fmt.Errorf("panic: %v", r), map[string]interface{}{
+				"prompt_id": id,
+				"client_id": c.ID,
+			})
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), promptRunTimeout)
+	defer cancel()
+	run, err := c.Hub.Prompts.RunPrompt(ctx, id, c.UserID, req)
+
+	c.sendMessage(Message{
+		Type: "chat_typing",
+		Payload: map[string]interface{}{
+			"is_typing": false,
+		},
+		Timestamp: time.Now(),
+	})
+
+	if err != nil {
+		logger.LogWarn(logger.ServiceWS, "Saved prompt run failed", map[string]interface{}{
+			"prompt_id": id,
+			"client_id": c.ID,
+			"error":     err.Error(),
+		})
+		c.sendAIError("chat_error", "prompt_failed", err, map[string]interface{}{"prompt_id": id})
+		return
+	}
+
+	content := run.Answer
+	if sql := strings.TrimSpace(run.SQL); sql != "" {
+		content += "\n\n```sql\n" + sql + "\n```"
+	}
+	c.sendMessage(Message{
+		Type: "chat_response",
+		Payload: map[string]interface{}{
+			"content": content,
+			"model":   run.Model,
+		},
+		Timestamp: time.Now(),
+	})
+}
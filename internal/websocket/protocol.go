@@ -0,0 +1,426 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProtocolVersion is the WebSocket protocol version this server speaks by
+// default. Clients ask for a version with the "air.v<N>" subprotocol or the
+// ?protocol=<N> query parameter when connecting.
+const ProtocolVersion = 1
+
+// SupportedProtocolVersions lists every version the server can speak
+var SupportedProtocolVersions = []int{1}
+
+// ErrUnsupportedProtocol is returned when a client asks only for versions the
+// server does not speak
+var ErrUnsupportedProtocol = errors.New("unsupported protocol version")
+
+// Subprotocol is the Sec-WebSocket-Protocol name for a version
+func Subprotocol(version int) string {
+	return fmt.Sprintf("air.v%d", version)
+}
+
+// Subprotocols returns the subprotocol names of the supported versions, newest first
+func Subprotocols() []string {
+	names := make([]string, 0, len(SupportedProtocolVersions))
+	for i := len(SupportedProtocolVersions) - 1; i >= 0; i-- {
+		names = append(names, Subprotocol(SupportedProtocolVersions[i]))
+	}
+	return names
+}
+
+// NegotiateProtocol picks the version for a connection from the ?protocol=
+// value and the requested subprotocols. A client that asks for nothing gets
+// ProtocolVersion.
+func NegotiateProtocol(query string, subprotocols []string) (int, error) {
+	var requested []string
+	if query != "" {
+		requested = append(requested, query)
+	}
+	for _, name := range subprotocols {
+		if v, ok := strings.CutPrefix(name, "air.v"); ok {
+			requested = append(requested, v)
+		}
+	}
+	if len(requested) == 0 {
+		return ProtocolVersion, nil
+	}
+
+	for _, raw := range requested {
+		version, err := strconv.Atoi(raw)
+		if err != nil {
+			continue
+		}
+		for _, supported := range SupportedProtocolVersions {
+			if version == supported {
+				return version, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: asked for %s, supported %v", ErrUnsupportedProtocol, strings.Join(requested, ", "), SupportedProtocolVersions)
+}
+
+// Message directions
+const (
+	FromClient = "client"
+	FromServer = "server"
+)
+
+// MessageSpec documents one message type of the protocol
+type MessageSpec struct {
+	Type        string
+	Direction   string
+	Description string
+	Payload     interface{} // zero value of the payload struct; nil for a freeform object
+	Since       int         // protocol version that introduced the message
+}
+
+// inboundPayload is implemented by payloads clients send, so they can be
+// checked before they are handled
+type inboundPayload interface {
+	validate() error
+}
+
+// SubscribePayload subscribes to or unsubscribes from a hub channel
+type SubscribePayload struct {
+	Channel string `json:"channel"`
+}
+
+func (p *SubscribePayload) validate() error { return requireFields("channel", p.Channel) }
+
+// PingPayload is empty; the server answers with pong
+type PingPayload struct{}
+
+func (p *PingPayload) validate() error { return nil }
+
+// FileAnalysisPayload asks for an AI analysis of an uploaded file
+type FileAnalysisPayload struct {
+	FileID string `json:"file_id"`
+	Query  string `json:"query"`
+	Model  string `json:"model,omitempty"`
+}
+
+func (p *FileAnalysisPayload) validate() error {
+	return requireFields("file_id", p.FileID, "query", p.Query)
+}
+
+// LoadDatasetPayload selects an uploaded file for chat questions
+type LoadDatasetPayload struct {
+	Filename string `json:"filename"`
+}
+
+func (p *LoadDatasetPayload) validate() error { return requireFields("filename", p.Filename) }
+
+// ChatMessagePayload is a chat question; raw_ai_message uses it too
+type ChatMessagePayload struct {
+	Content string `json:"content"`
+	Model   string `json:"model,omitempty"`
+}
+
+func (p *ChatMessagePayload) validate() error { return requireFields("content", p.Content) }
+
+// FileSelectPayload picks a file offered by ephemeral_file_needed
+type FileSelectPayload struct {
+	FileID string `json:"file_id"`
+}
+
+func (p *FileSelectPayload) validate() error { return requireFields("file_id", p.FileID) }
+
+// PromptSuggestPayload asks for the saved prompts whose names start with prefix
+type PromptSuggestPayload struct {
+	Prefix string `json:"prefix,omitempty"`
+}
+
+func (p *PromptSuggestPayload) validate() error { return nil }
+
+// RunPromptPayload runs a saved prompt with its params filled in
+type RunPromptPayload struct {
+	PromptID     uint              `json:"prompt_id"`
+	Params       map[string]string `json:"params,omitempty"`
+	DatasourceID string            `json:"datasource_id,omitempty"` // used when no CSV or TSV file is active
+}
+
+func (p *RunPromptPayload) validate() error {
+	if p.PromptID == 0 {
+		return errors.New("prompt_id is required")
+	}
+	return nil
+}
+
+// WelcomePayload is sent once a connection is registered
+type WelcomePayload struct {
+	Protocol          int      `json:"protocol"`
+	SupportedVersions []int    `json:"supported_versions"`
+	ClientID          string   `json:"client_id"`
+	UserID            string   `json:"user_id"`
+	MessageTypes      []string `json:"message_types"` // types the client may send
+}
+
+// ErrorPayload reports a message the server could not accept
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Error   string `json:"error"`
+	Type    string `json:"type,omitempty"` // type of the rejected message
+	Details string `json:"details,omitempty"`
+}
+
+// FileAnalysisStartedPayload acknowledges a file_analysis request
+type FileAnalysisStartedPayload struct {
+	FileID string `json:"file_id"`
+	Query  string `json:"query"`
+	Model  string `json:"model"`
+}
+
+// FileAnalysisCompletePayload carries the analysis of a file
+type FileAnalysisCompletePayload struct {
+	AnalysisID  uint     `json:"analysis_id,omitempty"` // saved analysis, readable at /v1/file-analyses/{id}
+	FileID      string   `json:"file_id"`
+	Query       string   `json:"query"`
+	Model       string   `json:"model"`
+	Analysis    string   `json:"analysis"`
+	Insights    []string `json:"insights"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// FileAnalysisErrorPayload reports a failed file analysis or a bad request
+type FileAnalysisErrorPayload struct {
+	FileID string `json:"file_id,omitempty"`
+	Error  string `json:"error"`
+}
+
+// ChatTypingPayload shows or hides the typing indicator
+type ChatTypingPayload struct {
+	IsTyping bool `json:"is_typing"`
+}
+
+// ChatResponsePayload is the answer to chat_message or raw_ai_message
+type ChatResponsePayload struct {
+	Content string `json:"content"`
+	Model   string `json:"model"`
+}
+
+// AIErrorPayload rejects a chat or raw AI message
+type AIErrorPayload struct {
+	Code              string   `json:"code"` // "model_not_allowed", "quota_exceeded" or "prompt_failed"
+	Error             string   `json:"error"`
+	PromptID          uint     `json:"prompt_id,omitempty"`
+	Model             string   `json:"model,omitempty"`
+	AllowedModels     []string `json:"allowed_models,omitempty"`
+	LimitPerMinute    int      `json:"limit_per_minute,omitempty"`
+	RetryAfterSeconds int      `json:"retry_after_seconds,omitempty"`
+}
+
+// LoadDatasetSuccessPayload confirms load_dataset
+type LoadDatasetSuccessPayload struct {
+	Filename string `json:"filename"`
+	Message  string `json:"message"`
+}
+
+// LoadDatasetErrorPayload rejects load_dataset
+type LoadDatasetErrorPayload struct {
+	Error string `json:"error"`
+}
+
+// FileInfo describes an uploaded file
+type FileInfo struct {
+	FileID     string `json:"file_id"`
+	Filename   string `json:"filename"`
+	FileSize   int64  `json:"file_size"`
+	UploadTime string `json:"upload_time"`
+	FileType   string `json:"file_type"`
+}
+
+// FileNeededPayload asks the user to pick a file before analysis
+type FileNeededPayload struct {
+	Message string     `json:"message"`
+	Files   []FileInfo `json:"files"`
+}
+
+// FileLoadedPayload confirms ephemeral_file_select
+type FileLoadedPayload struct {
+	FileID   string `json:"file_id"`
+	Filename string `json:"filename"`
+	FileSize int64  `json:"file_size"`
+}
+
+// PromptSuggestion is a saved prompt offered for autocomplete
+type PromptSuggestion struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Text        string   `json:"text"`
+	Params      []string `json:"params"`
+	Shared      bool     `json:"shared"`
+}
+
+// PromptSuggestionsPayload answers prompt_suggest
+type PromptSuggestionsPayload struct {
+	Prefix  string             `json:"prefix"`
+	Prompts []PromptSuggestion `json:"prompts"`
+}
+
+// Messages is the protocol: every message type with its payload
+var Messages = []MessageSpec{
+	{Type: "subscribe", Direction: FromClient, Payload: SubscribePayload{}, Since: 1, Description: "Receive messages published to a channel"},
+	{Type: "unsubscribe", Direction: FromClient, Payload: SubscribePayload{}, Since: 1, Description: "Stop receiving a channel"},
+	{Type: "ping", Direction: FromClient, Payload: PingPayload{}, Since: 1, Description: "Liveness check; answered with pong"},
+	{Type: "file_analysis", Direction: FromClient, Payload: FileAnalysisPayload{}, Since: 1, Description: "Analyze an uploaded file with AI"},
+	{Type: "load_dataset", Direction: FromClient, Payload: LoadDatasetPayload{}, Since: 1, Description: "Select an uploaded file for chat questions"},
+	{Type: "chat_message", Direction: FromClient, Payload: ChatMessagePayload{}, Since: 1, Description: "Ask the assistant a question"},
+	{Type: "raw_ai_message", Direction: FromClient, Payload: ChatMessagePayload{}, Since: 1, Description: "Send a prompt to an allowed model without system prompts"},
+	{Type: "ephemeral_file_select", Direction: FromClient, Payload: FileSelectPayload{}, Since: 1, Description: "Pick a file offered by ephemeral_file_needed"},
+	{Type: "prompt_suggest", Direction: FromClient, Payload: PromptSuggestPayload{}, Since: 1, Description: "List saved prompts whose names start with a prefix"},
+	{Type: "run_prompt", Direction: FromClient, Payload: RunPromptPayload{}, Since: 1, Description: "Run a saved prompt; answered with chat_response or chat_error"},
+
+	{Type: "welcome", Direction: FromServer, Payload: WelcomePayload{}, Since: 1, Description: "First message of a connection, with the negotiated protocol version"},
+	{Type: "error", Direction: FromServer, Payload: ErrorPayload{}, Since: 1, Description: "A client message was rejected"},
+	{Type: "pong", Direction: FromServer, Payload: PingPayload{}, Since: 1, Description: "Answer to ping"},
+	{Type: "file_analysis_started", Direction: FromServer, Payload: FileAnalysisStartedPayload{}, Since: 1, Description: "File analysis accepted"},
+	{Type: "file_analysis_complete", Direction: FromServer, Payload: FileAnalysisCompletePayload{}, Since: 1, Description: "File analysis result"},
+	{Type: "file_analysis_error", Direction: FromServer, Payload: FileAnalysisErrorPayload{}, Since: 1, Description: "File analysis failed or timed out"},
+	{Type: "chat_typing", Direction: FromServer, Payload: ChatTypingPayload{}, Since: 1, Description: "Assistant typing indicator"},
+	{Type: "chat_response", Direction: FromServer, Payload: ChatResponsePayload{}, Since: 1, Description: "Answer to chat_message"},
+	{Type: "chat_error", Direction: FromServer, Payload: AIErrorPayload{}, Since: 1, Description: "chat_message or run_prompt was rejected"},
+	{Type: "raw_ai_response", Direction: FromServer, Payload: ChatResponsePayload{}, Since: 1, Description: "Answer to raw_ai_message"},
+	{Type: "raw_ai_error", Direction: FromServer, Payload: AIErrorPayload{}, Since: 1, Description: "raw_ai_message was rejected"},
+	{Type: "load_dataset_success", Direction: FromServer, Payload: LoadDatasetSuccessPayload{}, Since: 1, Description: "Dataset selected"},
+	{Type: "load_dataset_error", Direction: FromServer, Payload: LoadDatasetErrorPayload{}, Since: 1, Description: "Dataset could not be selected"},
+	{Type: "ephemeral_file_needed", Direction: FromServer, Payload: FileNeededPayload{}, Since: 1, Description: "A question needs a file; offers the uploaded files"},
+	{Type: "ephemeral_file_loaded", Direction: FromServer, Payload: FileLoadedPayload{}, Since: 1, Description: "File picked with ephemeral_file_select"},
+	{Type: "prompt_suggestions", Direction: FromServer, Payload: PromptSuggestionsPayload{}, Since: 1, Description: "Saved prompts matching prompt_suggest"},
+
+	// Lifecycle events forwarded on channel "events:<event>"; the payload is the event data
+	{Type: "report.run.started", Direction: FromServer, Since: 1, Description: "A report run started executing"},
+	{Type: "report.run.completed", Direction: FromServer, Since: 1, Description: "A report run completed"},
+	{Type: "report.run.failed", Direction: FromServer, Since: 1, Description: "A report run failed"},
+	{Type: "report.snapshot.refreshed", Direction: FromServer, Since: 1, Description: "A report snapshot was refreshed"},
+	{Type: "analysis.completed", Direction: FromServer, Since: 1, Description: "An analysis job completed"},
+	{Type: "schema.drift.detected", Direction: FromServer, Since: 1, Description: "A datasource schema changed since it was learned"},
+	{Type: "report.stale", Direction: FromServer, Since: 1, Description: "Schema drift dropped a table or column a report version reads"},
+	{Type: "data.stale", Direction: FromServer, Since: 1, Description: "A monitored table's newest row is older than its freshness max age"},
+	{Type: "datasource.unhealthy", Direction: FromServer, Since: 1, Description: "A datasource health check failed"},
+}
+
+// clientMessageTypes lists the types a client may send in version
+func clientMessageTypes(version int) []string {
+	var types []string
+	for _, spec := range Messages {
+		if spec.Direction == FromClient && spec.Since <= version {
+			types = append(types, spec.Type)
+		}
+	}
+	return types
+}
+
+// inboundMessage is a client message before its payload is decoded
+type inboundMessage struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// validateInbound checks a raw client message against the protocol. Types
+// the protocol does not define are channel broadcasts and only need an object
+// payload; known types must decode into their payload struct and carry the
+// required fields.
+func validateInbound(data []byte, version int) (string, error) {
+	var msg inboundMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return "", fmt.Errorf("message is not valid JSON: %w", err)
+	}
+	if msg.Type == "" {
+		return "", errors.New("type is required")
+	}
+
+	for _, spec := range Messages {
+		if spec.Type != msg.Type {
+			continue
+		}
+		if spec.Direction != FromClient {
+			return msg.Type, fmt.Errorf("%s is sent by the server only", msg.Type)
+		}
+		if spec.Since > version {
+			return msg.Type, fmt.Errorf("%s needs protocol version %d", msg.Type, spec.Since)
+		}
+		dst := reflect.New(reflect.TypeOf(spec.Payload)).Interface().(inboundPayload)
+		return msg.Type, decodePayload(msg.Payload, dst)
+	}
+
+	var payload map[string]interface{}
+	if err := decodeObject(msg.Payload, &payload); err != nil {
+		return msg.Type, err
+	}
+	return msg.Type, nil
+}
+
+// decodePayload decodes and validates the payload of a known client message
+// type into dst
+func decodePayload(raw json.RawMessage, dst inboundPayload) error {
+	if err := decodeObject(raw, dst); err != nil {
+		return err
+	}
+	return dst.validate()
+}
+
+// decodeObject decodes a payload that is absent, null or a JSON object
+func decodeObject(raw json.RawMessage, dst interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	return nil
+}
+
+// welcomeMessage tells a new client the negotiated protocol version
+func (c *Client) welcomeMessage() Message {
+	version := c.Protocol
+	if version == 0 {
+		version = ProtocolVersion
+	}
+	return Message{
+		Type: "welcome",
+		Payload: map[string]interface{}{
+			"protocol":           version,
+			"supported_versions": SupportedProtocolVersions,
+			"client_id":          c.ID,
+			"user_id":            c.UserID,
+			"message_types":      clientMessageTypes(version),
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// sendProtocolError rejects a message that does not match the protocol
+func (c *Client) sendProtocolError(msgType string, err error) {
+	payload := map[string]interface{}{
+		"code":  "invalid_message",
+		"error": err.Error(),
+	}
+	if msgType != "" {
+		payload["type"] = msgType
+	}
+	c.sendMessage(Message{
+		Type:      "error",
+		Payload:   payload,
+		Timestamp: time.Now(),
+	})
+}
+
+// requireFields takes name, value pairs and reports the first empty value
+func requireFields(pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if strings.TrimSpace(pairs[i+1]) == "" {
+			return fmt.Errorf("%s is required", pairs[i])
+		}
+	}
+	return nil
+}
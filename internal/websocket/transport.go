@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/NubeDev/air/internal/logger"
+	"github.com/NubeDev/air/internal/redis"
+)
+
+// Transport carries channel messages published on one hub to the hubs that
+// should deliver them
+type Transport interface {
+	// Name identifies the transport in logs and stats
+	Name() string
+	// Publish sends a message to a hub channel such as "chat:general"
+	Publish(ctx context.Context, channel string, message []byte) error
+	// Run calls deliver for every published message until ctx is done
+	Run(ctx context.Context, deliver func(channel string, message []byte))
+}
+
+// memoryTransport delivers messages to the hub of this process only. It is
+// complete for single-node installs that run without Redis.
+type memoryTransport struct {
+	messages chan ChannelMessage
+}
+
+// NewMemoryTransport creates an in-process transport
+func NewMemoryTransport() Transport {
+	return &memoryTransport{messages: make(chan ChannelMessage, 256)}
+}
+
+func (t *memoryTransport) Name() string { return "memory" }
+
+func (t *memoryTransport) Publish(ctx context.Context, channel string, message []byte) error {
+	select {
+	case t.messages <- ChannelMessage{Channel: channel, Message: message}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *memoryTransport) Run(ctx context.Context, deliver func(channel string, message []byte)) {
+	for {
+		select {
+		case m := <-t.messages:
+			deliver(m.Channel, m.Message)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// redisChannelPrefix keeps hub channels apart from other Redis channels
+const redisChannelPrefix = "websocket:"
+
+// redisTransport fans messages out to the hubs of every replica through
+// Redis pub/sub
+type redisTransport struct {
+	client *redis.Client
+}
+
+// NewRedisTransport creates a transport over Redis pub/sub
+func NewRedisTransport(client *redis.Client) Transport {
+	return &redisTransport{client: client}
+}
+
+func (t *redisTransport) Name() string { return "redis" }
+
+func (t *redisTransport) Publish(ctx context.Context, channel string, message []byte) error {
+	return t.client.Publish(ctx, redisChannelPrefix+channel, message)
+}
+
+func (t *redisTransport) Run(ctx context.Context, deliver func(channel string, message []byte)) {
+	pubsub := t.client.PSubscribe(ctx, redisChannelPrefix+"*")
+	defer pubsub.Close()
+
+	logger.LogInfo(logger.ServiceWS, "Redis subscriber started", map[string]interface{}{
+		"pattern": redisChannelPrefix + "*",
+	})
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				logger.LogInfo(logger.ServiceWS, "Redis subscriber shutting down")
+				return
+			}
+			logger.LogError(logger.ServiceWS, "Redis subscription error", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		channel := strings.TrimPrefix(t.client.TrimKey(msg.Channel), redisChannelPrefix)
+		deliver(channel, []byte(msg.Payload))
+	}
+}
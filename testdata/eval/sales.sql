@@ -0,0 +1,42 @@
+-- Sample sales fixture for the NL->SQL eval suite. Portable across SQLite and
+-- PostgreSQL so the same suite runs locally and against the compose database.
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS customers;
+
+CREATE TABLE customers (
+    customer_id   VARCHAR(20) PRIMARY KEY,
+    customer_name VARCHAR(100) NOT NULL,
+    region        VARCHAR(50) NOT NULL,
+    segment       VARCHAR(20) NOT NULL
+);
+
+CREATE TABLE orders (
+    order_id         INTEGER PRIMARY KEY,
+    order_date       DATE NOT NULL,
+    customer_id      VARCHAR(20) NOT NULL REFERENCES customers (customer_id),
+    product_category VARCHAR(50) NOT NULL,
+    quantity         INTEGER NOT NULL,
+    total_amount     NUMERIC(12, 2) NOT NULL,
+    order_status     VARCHAR(20) NOT NULL
+);
+
+INSERT INTO customers (customer_id, customer_name, region, segment) VALUES
+    ('CUST-001', 'Alice Johnson', 'North America', 'premium'),
+    ('CUST-002', 'Bob Wilson', 'Europe', 'standard'),
+    ('CUST-003', 'Carol Brown', 'Asia', 'budget'),
+    ('CUST-004', 'David Lee', 'North America', 'premium'),
+    ('CUST-005', 'Eva Martinez', 'Europe', 'standard');
+
+INSERT INTO orders (order_id, order_date, customer_id, product_category, quantity, total_amount, order_status) VALUES
+    (1, '2024-01-15', 'CUST-001', 'Electronics', 2, 1799.98, 'delivered'),
+    (2, '2024-01-15', 'CUST-002', 'Clothing', 1, 299.99, 'delivered'),
+    (3, '2024-01-16', 'CUST-003', 'Home & Garden', 3, 449.97, 'delivered'),
+    (4, '2024-01-18', 'CUST-004', 'Electronics', 1, 1299.99, 'delivered'),
+    (5, '2024-01-22', 'CUST-001', 'Clothing', 4, 359.96, 'returned'),
+    (6, '2024-02-02', 'CUST-005', 'Electronics', 1, 649.00, 'delivered'),
+    (7, '2024-02-05', 'CUST-002', 'Home & Garden', 2, 219.98, 'delivered'),
+    (8, '2024-02-11', 'CUST-003', 'Electronics', 1, 899.99, 'cancelled'),
+    (9, '2024-02-20', 'CUST-004', 'Clothing', 3, 449.97, 'delivered'),
+    (10, '2024-03-01', 'CUST-005', 'Home & Garden', 5, 524.95, 'delivered'),
+    (11, '2024-03-07', 'CUST-001', 'Electronics', 1, 1099.00, 'delivered'),
+    (12, '2024-03-14', 'CUST-002', 'Electronics', 2, 798.00, 'delivered');